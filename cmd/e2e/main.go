@@ -0,0 +1,191 @@
+// Command e2e runs a scripted scenario against a running deployment: it
+// creates a todo, tags it, completes it, finds it through search and export
+// and deletes it again, checking every response and how long it took. Use
+// it as a post-deploy smoke test:
+//
+//	e2e -url https://todo.example.com -token "$SESSION_TOKEN"
+//
+// It exits 0 when every step passed, 1 when one failed or ran over its
+// budget and 2 on bad usage. Steps the deployment does not support (search
+// on Bolt) are reported as skipped. The todo it creates is deleted at the
+// end, even after a failure.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"todoapp/pkg/client"
+)
+
+// errSkipped marks a step the deployment does not support.
+var errSkipped = errors.New("not supported by this deployment")
+
+func main() {
+	base := flag.String("url", getEnv("E2E_URL", "http://localhost:8080"), "base URL of the deployment (E2E_URL)")
+	token := flag.String("token", os.Getenv("E2E_TOKEN"), "session token when accounts are enabled (E2E_TOKEN)")
+	budget := flag.Duration("budget", 2*time.Second, "time budget for each step")
+	timeout := flag.Duration("timeout", time.Minute, "time limit for the whole run")
+	flag.Parse()
+	if flag.NArg() > 0 || *budget <= 0 || *timeout <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	// No retries: a smoke test should see the failures clients would.
+	c, err := client.New(*base, client.WithToken(*token), client.WithHTTPClient(&http.Client{}), client.WithRetries(0, 0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: -url must be an http(s) URL, got %q\n", *base)
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if !run(ctx, c, *budget) {
+		os.Exit(1)
+	}
+}
+
+// run executes the scenario and reports whether it passed.
+func run(ctx context.Context, c *client.Client, budget time.Duration) bool {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	runID := "e2e" + hex.EncodeToString(suffix)
+	title := "Smoke test " + runID
+	fmt.Printf("e2e run %s against %s\n", runID, c.BaseURL())
+
+	var created client.Todo
+	failed := false
+	step := func(name string, fn func(ctx context.Context) error) bool {
+		stepCtx, cancel := context.WithTimeout(ctx, budget)
+		defer cancel()
+		start := time.Now()
+		err := fn(stepCtx)
+		took := time.Since(start).Round(time.Millisecond)
+		switch {
+		case errors.Is(err, errSkipped):
+			fmt.Printf("SKIP %-8s %8s  %v\n", name, took, err)
+			return true
+		case err == nil && took > budget:
+			err = fmt.Errorf("took longer than the %s budget", budget)
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+			err = fmt.Errorf("no answer within the %s budget", budget)
+		case client.StatusCode(err) == http.StatusUnauthorized:
+			err = errors.New("401 unauthorized: pass a session token with -token")
+		}
+		if err != nil {
+			fmt.Printf("FAIL %-8s %8s  %v\n", name, took, err)
+			failed = true
+			return false
+		}
+		fmt.Printf("PASS %-8s %8s\n", name, took)
+		return true
+	}
+
+	scenario := []struct {
+		name string
+		run  func(ctx context.Context) error
+	}{
+		{"create", func(ctx context.Context) error {
+			var err error
+			if created, err = c.CreateTodo(ctx, client.TodoInput{Title: title, Tags: []string{"e2e"}}); err != nil {
+				return err
+			}
+			if created.ID == 0 || created.Title != title || created.Completed {
+				return fmt.Errorf("unexpected todo %+v", created)
+			}
+			return nil
+		}},
+		{"tag", func(ctx context.Context) error {
+			got, err := c.PatchTodo(ctx, created.ID, client.TodoPatch{Tags: []string{"e2e", runID}})
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(got.Tags, runID) {
+				return fmt.Errorf("tags %v do not include %s", got.Tags, runID)
+			}
+			tagged, err := c.ListTodos(ctx, client.TodoFilter{Tag: runID})
+			if err != nil {
+				return err
+			}
+			if len(tagged) != 1 || tagged[0].ID != created.ID {
+				return fmt.Errorf("listing by tag returned %d todos, want the created one", len(tagged))
+			}
+			return nil
+		}},
+		{"complete", func(ctx context.Context) error {
+			completed := true
+			got, err := c.PatchTodo(ctx, created.ID, client.TodoPatch{Completed: &completed})
+			if err != nil {
+				return err
+			}
+			if !got.Completed {
+				return errors.New("todo is not completed")
+			}
+			return nil
+		}},
+		{"search", func(ctx context.Context) error {
+			results, err := c.SearchTodos(ctx, runID)
+			if client.IsNotImplemented(err) {
+				return errSkipped
+			}
+			if err != nil {
+				return err
+			}
+			if !slices.ContainsFunc(results, func(t client.Todo) bool { return t.ID == created.ID }) {
+				return fmt.Errorf("search for %s did not return the todo", runID)
+			}
+			return nil
+		}},
+		{"export", func(ctx context.Context) error {
+			exported, err := c.ExportTodos(ctx, client.TodoFilter{Tag: runID})
+			if client.IsNotImplemented(err) {
+				return errSkipped
+			}
+			if err != nil {
+				return err
+			}
+			if len(exported) != 1 || exported[0].Title != title || !exported[0].Completed {
+				return fmt.Errorf("export returned %+v, want the completed todo", exported)
+			}
+			return nil
+		}},
+	}
+	for _, st := range scenario {
+		if !step(st.name, st.run) {
+			break
+		}
+	}
+
+	if created.ID != 0 {
+		step("delete", func(ctx context.Context) error {
+			if err := c.DeleteTodo(ctx, created.ID); err != nil {
+				return err
+			}
+			if _, err := c.GetTodo(ctx, created.ID); !client.IsNotFound(err) {
+				return fmt.Errorf("todo still found after deleting it (%v)", err)
+			}
+			return nil
+		})
+	}
+	if failed {
+		fmt.Println("e2e FAILED")
+		return false
+	}
+	fmt.Println("e2e passed")
+	return true
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
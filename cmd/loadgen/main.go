@@ -0,0 +1,419 @@
+// Command loadgen sends a mix of todo API requests to a running deployment
+// at a fixed rate and reports latency percentiles per request type, to
+// measure changes such as connection pooling, caching or ML scoring:
+//
+//	loadgen -url http://localhost:8080 -rps 200 -concurrency 32 -duration 1m
+//
+// The mix weighs four requests: list (the default GET /api/todos, which the
+// list cache serves), get, update (a PATCH that rescores the todo) and
+// create (scored by the ML service when the server has one). Requests are
+// sent open-loop: one that comes due while every worker is busy is counted
+// as dropped rather than sent late, so an overloaded server shows up as
+// drops instead of a lower rate with flattering latencies.
+//
+// It seeds -seed todos before measuring and deletes every todo it created
+// at the end. Run it against a server without proof of work
+// (POW_DIFFICULTY=0) and with rate limits above -rps, or those answers are
+// counted as errors.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ops are the request types, in report order.
+var ops = []string{"list", "get", "update", "create"}
+
+var words = []string{
+	"review", "draft", "call", "email", "fix", "plan", "book", "pay", "order",
+	"report", "invoice", "groceries", "dentist", "release", "budget", "slides",
+}
+
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+type todo struct {
+	ID int64 `json:"id"`
+}
+
+func main() {
+	base := flag.String("url", getEnv("LOADGEN_URL", "http://localhost:8080"), "base URL of the deployment (LOADGEN_URL)")
+	token := flag.String("token", os.Getenv("LOADGEN_TOKEN"), "session token when accounts are enabled (LOADGEN_TOKEN)")
+	rps := flag.Int("rps", 50, "requests per second to send")
+	concurrency := flag.Int("concurrency", 10, "requests in flight at most")
+	duration := flag.Duration("duration", 30*time.Second, "how long to send requests")
+	mixFlag := flag.String("mix", "list=40,get=30,update=20,create=10", "relative weights of list, get, update and create requests")
+	seed := flag.Int("seed", 50, "todos to create before measuring")
+	timeout := flag.Duration("timeout", 10*time.Second, "time limit for a single request")
+	flag.Parse()
+	weights, err := parseMix(*mixFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: -mix: %v\n", err)
+		os.Exit(2)
+	}
+	if flag.NArg() > 0 || *rps <= 0 || *concurrency <= 0 || *duration <= 0 || *seed < 1 || *timeout <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	u, err := url.Parse(*base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fmt.Fprintf(os.Stderr, "loadgen: -url must be an http(s) URL, got %q\n", *base)
+		os.Exit(2)
+	}
+
+	// Keep a warm connection per worker, so the run measures the server
+	// rather than connection setup.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = *concurrency
+	c := &client{base: strings.TrimRight(*base, "/"), token: *token, http: &http.Client{Transport: transport, Timeout: *timeout}}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	suffix := make([]byte, 4)
+	for i := range suffix {
+		suffix[i] = byte(rand.IntN(256))
+	}
+	g := &generator{client: c, weights: weights, tag: "loadgen" + hex.EncodeToString(suffix), stats: newStats()}
+
+	fmt.Printf("loadgen %s: seeding %d todos on %s\n", g.tag, *seed, c.base)
+	err = g.seed(ctx, *seed)
+	if err == nil {
+		fmt.Printf("sending %d req/s with %d workers for %s (mix %s)\n", *rps, *concurrency, *duration, *mixFlag)
+		g.run(ctx, *rps, *concurrency, *duration)
+		g.stats.report(os.Stdout, *rps)
+	}
+	// Clean up even after an interrupt, on a fresh context.
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if n, cerr := g.cleanup(cleanupCtx); cerr != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: cleanup: %v (todos are tagged %s)\n", cerr, g.tag)
+	} else {
+		fmt.Printf("deleted %d todos\n", n)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: seed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseMix parses "list=40,get=30,..." into weights in ops order. Request
+// types left out get weight zero.
+func parseMix(s string) ([]int, error) {
+	weights := make([]int, len(ops))
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		name, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		i := slices.Index(ops, name)
+		if !ok || i < 0 {
+			return nil, fmt.Errorf("%q is not op=weight with op one of %s", part, strings.Join(ops, ", "))
+		}
+		w, err := strconv.Atoi(v)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight %q", v)
+		}
+		weights[i] = w
+		total += w
+	}
+	if total == 0 {
+		return nil, errors.New("weights must not all be zero")
+	}
+	return weights, nil
+}
+
+type generator struct {
+	client  *client
+	weights []int
+	tag     string
+	stats   *stats
+
+	mu  sync.Mutex
+	ids []int64
+}
+
+func (g *generator) seed(ctx context.Context, n int) error {
+	for range n {
+		status, err := g.create(ctx)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case http.StatusCreated:
+		case http.StatusPreconditionRequired:
+			return errors.New("the server requires proof of work; run it with POW_DIFFICULTY=0")
+		default:
+			return fmt.Errorf("create answered %d", status)
+		}
+	}
+	return nil
+}
+
+// run sends requests at rps until duration is over or ctx is done.
+func (g *generator) run(ctx context.Context, rps, concurrency int, duration time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range jobs {
+				g.do(ctx, op)
+			}
+		}()
+	}
+	start := time.Now()
+	tick := time.NewTicker(time.Second / time.Duration(rps))
+	defer tick.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-tick.C:
+			select {
+			case jobs <- g.pick():
+			default:
+				g.stats.dropped.Add(1)
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	g.stats.elapsed = time.Since(start)
+}
+
+// pick draws a request type according to the weights.
+func (g *generator) pick() string {
+	total := 0
+	for _, w := range g.weights {
+		total += w
+	}
+	n := rand.IntN(total)
+	for i, w := range g.weights {
+		if n < w {
+			return ops[i]
+		}
+		n -= w
+	}
+	return ops[len(ops)-1]
+}
+
+// do sends one request and records its outcome. Requests cut off by the
+// end of the run are not counted.
+func (g *generator) do(ctx context.Context, op string) {
+	start := time.Now()
+	var status int
+	var err error
+	switch op {
+	case "list":
+		status, err = g.client.do(ctx, http.MethodGet, "/api/todos", nil, nil)
+	case "get":
+		status, err = g.client.do(ctx, http.MethodGet, todoPath(g.randomID()), nil, nil)
+	case "update":
+		body := map[string]any{"title": title(), "completed": rand.IntN(4) == 0}
+		status, err = g.client.do(ctx, http.MethodPatch, todoPath(g.randomID()), body, nil)
+	case "create":
+		status, err = g.create(ctx)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	g.stats.record(op, time.Since(start), status, err)
+}
+
+// create adds a todo tagged with the run's tag and remembers its id.
+func (g *generator) create(ctx context.Context) (int, error) {
+	body := map[string]any{
+		"title":           title(),
+		"tags":            []string{g.tag},
+		"durationMinutes": 5 * rand.IntN(25),
+	}
+	var created todo
+	status, err := g.client.do(ctx, http.MethodPost, "/api/todos", body, &created)
+	if err == nil && status == http.StatusCreated {
+		g.mu.Lock()
+		g.ids = append(g.ids, created.ID)
+		g.mu.Unlock()
+	}
+	return status, err
+}
+
+func (g *generator) randomID() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.ids[rand.IntN(len(g.ids))]
+}
+
+// cleanup deletes every todo tagged with the run's tag.
+func (g *generator) cleanup(ctx context.Context) (int64, error) {
+	var resp struct {
+		Deleted int64 `json:"deleted"`
+	}
+	status, err := g.client.do(ctx, http.MethodDelete, "/api/todos?confirm=true&tag="+g.tag, nil, &resp)
+	if err != nil {
+		return 0, err
+	}
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("bulk delete answered %d", status)
+	}
+	return resp.Deleted, nil
+}
+
+func title() string {
+	n := 2 + rand.IntN(4)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = words[rand.IntN(len(words))]
+	}
+	return strings.Join(parts, " ")
+}
+
+func todoPath(id int64) string {
+	return "/api/todos/" + strconv.FormatInt(id, 10)
+}
+
+// do sends body as JSON and decodes a successful JSON response into out.
+func (c *client) do(ctx context.Context, method, path string, body, out any) (int, error) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, payload)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 && out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("decode response: %w", err)
+		}
+	}
+	// Drain the body so the connection is reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+type stats struct {
+	dropped atomic.Int64
+	elapsed time.Duration
+
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	statuses  map[int]int
+	failures  map[string]int
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+		statuses:  make(map[int]int),
+		failures:  make(map[string]int),
+	}
+}
+
+// record notes a request. Transport failures and 4xx/5xx answers count as
+// errors; only the latencies of successful requests are kept.
+func (s *stats) record(op string, took time.Duration, status int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case err != nil:
+		s.errors[op]++
+		s.failures[err.Error()]++
+	case status >= 400:
+		s.errors[op]++
+		s.statuses[status]++
+	default:
+		s.latencies[op] = append(s.latencies[op], took)
+		s.statuses[status]++
+	}
+}
+
+func (s *stats) report(w io.Writer, rps int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sent int
+	fmt.Fprintf(w, "\n%-8s %8s %7s %9s %9s %9s %9s\n", "op", "ok", "errors", "p50", "p90", "p99", "max")
+	for _, op := range ops {
+		lat := s.latencies[op]
+		sent += len(lat) + s.errors[op]
+		if len(lat) == 0 && s.errors[op] == 0 {
+			continue
+		}
+		slices.Sort(lat)
+		fmt.Fprintf(w, "%-8s %8d %7d %9s %9s %9s %9s\n", op, len(lat), s.errors[op],
+			percentile(lat, 50), percentile(lat, 90), percentile(lat, 99), percentile(lat, 100))
+	}
+	secs := s.elapsed.Seconds()
+	if secs == 0 {
+		secs = 1
+	}
+	fmt.Fprintf(w, "\nsent %d requests in %s (%.1f/s of %d/s), dropped %d with every worker busy\n",
+		sent, s.elapsed.Round(time.Millisecond), float64(sent)/secs, rps, s.dropped.Load())
+	codes := make([]int, 0, len(s.statuses))
+	for code := range s.statuses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "  status %d: %d\n", code, s.statuses[code])
+	}
+	for msg, n := range s.failures {
+		fmt.Fprintf(w, "  failed: %s: %d\n", msg, n)
+	}
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p int) string {
+	if len(sorted) == 0 {
+		return "-"
+	}
+	i := (len(sorted)*p+99)/100 - 1
+	return sorted[max(i, 0)].Round(10 * time.Microsecond).String()
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"todoapp/internal/config"
+	"todoapp/internal/db"
+)
+
+const auditUsage = "usage: server audit verify [-json]"
+
+// runAudit implements `server audit verify`: it checks the hash chain of
+// the todo history (see AUDIT_HASH_CHAIN) and prints every break in it, or
+// the chain's head hash for keeping elsewhere. With -json it prints the
+// report as JSON. The exit code is 1 when the chain is broken.
+func runAudit(logger *slog.Logger, cfg config.Config, args []string) int {
+	if len(args) == 0 || args[0] != "verify" || len(args) > 2 || (len(args) == 2 && args[1] != "-json") {
+		fmt.Println(auditUsage)
+		return 2
+	}
+	store, err := openStore(logger, cfg, db.WithoutMigrations())
+	if err != nil {
+		logger.Error("failed to open database", "error", err)
+		return 1
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+	verifier, ok := store.(db.AuditChainVerifier)
+	if !ok {
+		logger.Error("storage backend does not chain the audit log")
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	report, err := verifier.VerifyAuditChain(ctx)
+	if err != nil {
+		logger.Error("audit chain verification failed", "error", err)
+		return 1
+	}
+	if len(args) == 2 {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		for _, p := range report.Problems {
+			if p.EventID != 0 {
+				fmt.Printf("entry %d (event %d): %s\n", p.Seq, p.EventID, p.Problem)
+			} else {
+				fmt.Printf("entry %d: %s\n", p.Seq, p.Problem)
+			}
+		}
+		fmt.Printf("%d entries checked, %d redacted, %d removed with their account, %d unchained\n",
+			report.Entries, report.Redacted, report.Removed, report.Unchained)
+		if report.OK() {
+			fmt.Printf("audit chain ok, head %s\n", report.Head)
+		}
+	}
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"todoapp/internal/config"
+	"todoapp/internal/db"
+)
+
+// runSchemaCheck implements `server check`: it compares the live schema with
+// the expected migration state without migrating, printing every difference.
+// The exit code is 1 on drift so it can gate deploys.
+func runSchemaCheck(logger *slog.Logger, cfg config.Config) int {
+	store, err := openStore(logger, cfg, db.WithoutMigrations())
+	if err != nil {
+		logger.Error("failed to open database", "error", err)
+		return 1
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+
+	drift, err := detectSchemaDrift(store)
+	if err != nil {
+		logger.Error("schema check failed", "error", err)
+		return 1
+	}
+	for _, d := range drift {
+		fmt.Println(d)
+	}
+	if len(drift) > 0 {
+		return 1
+	}
+	fmt.Println("schema ok")
+	return 0
+}
+
+// detectSchemaDrift logs each difference between the live and expected schema.
+// Backends without a schema (Bolt) never report drift.
+func detectSchemaDrift(store db.Store) ([]db.SchemaDrift, error) {
+	checker, ok := store.(db.SchemaChecker)
+	if !ok {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	drift, err := checker.CheckSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range drift {
+		slog.Warn("schema.drift", "table", d.Table, "object", d.Object, "kind", d.Kind, "expected", d.Expected, "actual", d.Actual)
+	}
+	return drift, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/internal/config"
+)
+
+const configUsage = "usage: server config export | import <file>"
+
+// tunables are the settings `server config` carries between instances: they
+// shape behaviour (limits, schedules, feature switches) and are the same in
+// every environment. Secrets, credentials, addresses and URLs of other
+// services are left out, so an export is safe to commit and to promote;
+// each environment keeps setting those itself.
+var tunables = config.Names(config.Key.Tunable)
+
+// runConfig implements `server config`:
+//
+//	config export          write the tunable settings set in the environment
+//	                       as YAML to stdout
+//	config import <file>   check an exported file and print it as an env file
+//	                       (KEY=value lines) for docker --env-file or a
+//	                       Kubernetes ConfigMap
+//
+// An import is applied by starting the other instance with its output, or
+// by pointing its CONFIG_FILE at the exported file.
+func runConfig(args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "export":
+		if err := exportConfig(os.Stdout, time.Now()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case len(args) == 2 && args[0] == "import":
+		f, err := os.Open(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		settings, err := parseConfig(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", args[1], err)
+			return 1
+		}
+		for _, key := range sortedKeys(settings) {
+			if strings.ContainsAny(settings[key], "\n\r") {
+				fmt.Fprintf(os.Stderr, "%s: %s: multi-line values cannot be written to an env file\n", args[1], key)
+				return 1
+			}
+			fmt.Printf("%s=%s\n", key, settings[key])
+		}
+		return 0
+	default:
+		fmt.Println(configUsage)
+		return 2
+	}
+}
+
+// exportConfig writes the tunables set in the environment. Values are
+// double-quoted so YAML never reinterprets them ("true", "0755", "1e3").
+func exportConfig(w io.Writer, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# todo server settings, exported %s.\n", now.UTC().Format(time.RFC3339))
+	fmt.Fprintln(bw, "# Secrets and environment-specific addresses are not included.")
+	fmt.Fprintln(bw, "version: 1")
+	fmt.Fprintln(bw, "settings:")
+	for _, key := range tunables {
+		if v, ok := os.LookupEnv(key); ok && v != "" {
+			fmt.Fprintf(bw, "  %s: %s\n", key, strconv.Quote(v))
+		}
+	}
+	return bw.Flush()
+}
+
+// parseConfig reads a file written by exportConfig. Unknown settings are
+// errors, so a secret pasted into the file is not silently carried along.
+func parseConfig(r io.Reader) (map[string]string, error) {
+	return config.Parse(r, isTunable)
+}
+
+func isTunable(key string) bool {
+	k, ok := config.Lookup(key)
+	return ok && k.Tunable()
+}
+
+// applyConfigFile sets the settings of the file named by CONFIG_FILE, in
+// the format exportConfig writes, as environment variables, unless the
+// environment sets them already. Besides the tunables it may hold the core
+// settings, but no secrets.
+func applyConfigFile() error {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		return nil
+	}
+	settings, err := config.ParseFile(path, func(key string) bool {
+		k, ok := config.Lookup(key)
+		return ok && (k.Tunable() || k.Core && !k.Secret)
+	})
+	if err != nil {
+		return err
+	}
+	for key, value := range settings {
+		if os.Getenv(key) == "" {
+			if err := os.Setenv(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// listenFDStart is the first inherited descriptor under the systemd
+// socket-activation protocol (sd_listen_fds(3)).
+const listenFDStart = 3
+
+// listen returns the socket to serve on. A socket passed by systemd socket
+// activation, or by a previous instance of this process during an upgrade,
+// takes precedence over binding addr, so restarts never close the port.
+// addr is a TCP address (":8080") or "unix:///path/to.sock".
+func listen(addr string, sock socketOptions) (net.Listener, error) {
+	ln, err := inheritedListener()
+	if err != nil || ln != nil {
+		return ln, err
+	}
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		return listenUnix(path, sock)
+	}
+	return net.Listen("tcp", strings.TrimPrefix(addr, "tcp://"))
+}
+
+// socketOptions controls the permissions of a Unix domain socket.
+type socketOptions struct {
+	// Mode is applied to the socket file, e.g. 0o660 to let a reverse proxy
+	// in the same group connect.
+	Mode os.FileMode
+	// Group, when set, becomes the socket file's group.
+	Group string
+}
+
+// listenUnix binds a Unix domain socket at path, replacing a stale socket
+// left behind by a crashed process. The socket file is removed again when
+// the listener is closed.
+func listenUnix(path string, sock socketOptions) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := chmodSocket(path, sock); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func chmodSocket(path string, sock socketOptions) error {
+	if sock.Group != "" {
+		g, err := user.LookupGroup(sock.Group)
+		if err != nil {
+			return err
+		}
+		gid, err := strconv.Atoi(g.Gid)
+		if err != nil {
+			return err
+		}
+		if err := os.Chown(path, -1, gid); err != nil {
+			return fmt.Errorf("chown socket: %w", err)
+		}
+	}
+	if sock.Mode != 0 {
+		if err := os.Chmod(path, sock.Mode); err != nil {
+			return fmt.Errorf("chmod socket: %w", err)
+		}
+	}
+	return nil
+}
+
+// inheritedListener returns the first listener passed via LISTEN_FDS, or nil
+// when none was passed. LISTEN_PID, set by systemd, must name this process;
+// upgrades started by upgradeProcess leave it unset.
+func inheritedListener() (net.Listener, error) {
+	fds := os.Getenv("LISTEN_FDS")
+	if fds == "" {
+		return nil, nil
+	}
+	pid := os.Getenv("LISTEN_PID")
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	// Do not leak the variables to child processes.
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if n, err := strconv.Atoi(fds); err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	f := os.NewFile(listenFDStart, "listener")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited listener: %w", err)
+	}
+	// A socket handed over by upgradeProcess is ours to clean up; one owned
+	// by systemd must stay in place for the next activation.
+	if ul, ok := ln.(*net.UnixListener); ok && pid == "" {
+		ul.SetUnlinkOnClose(true)
+	}
+	return ln, nil
+}
+
+// upgradeProcess starts a new instance of this binary that inherits ln. The
+// caller should then shut down gracefully: both processes accept from the
+// same socket until the old one stops, so no connection is refused.
+func upgradeProcess(ln net.Listener) (*os.Process, error) {
+	filer, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, errors.New("listener cannot be inherited")
+	}
+	f, err := filer.File()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Env = append(os.Environ(), "LISTEN_FDS=1")
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	// The new process now owns the socket file; closing ours must not unlink it.
+	if ul, ok := ln.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	return cmd.Process, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"todoapp/internal/config"
+	"todoapp/internal/db"
+)
+
+const migrateUsage = "usage: server migrate up [version] | down [steps] | status"
+
+// runMigrate implements `server migrate`, so schema changes can run as a
+// separate deploy step (set MIGRATE_ON_START=false on the app):
+//
+//	migrate up [version]   apply pending migrations, up to version if given
+//	migrate down [steps]   revert the last steps migrations (default 1)
+//	migrate status         list versions and when they were applied
+func runMigrate(logger *slog.Logger, cfg config.Config, args []string) int {
+	if len(args) == 0 || len(args) > 2 || !slices.Contains([]string{"up", "down", "status"}, args[0]) {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	var n int64
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.ParseInt(args[1], 10, 64); err != nil || n <= 0 || args[0] == "status" {
+			fmt.Println(migrateUsage)
+			return 2
+		}
+	}
+
+	store, err := openStore(logger, cfg, db.WithoutMigrations())
+	if err != nil {
+		logger.Error("failed to open database", "error", err)
+		return 1
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+	migrator, ok := store.(db.Migrator)
+	if !ok {
+		logger.Error("storage backend has no versioned schema")
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	var done []int64
+	switch args[0] {
+	case "up":
+		done, err = migrator.MigrateUp(ctx, n)
+	case "down":
+		done, err = migrator.MigrateDown(ctx, int(max(n, 1)))
+	case "status":
+		return printMigrationStatus(ctx, logger, migrator)
+	}
+	if err != nil {
+		logger.Error("migration failed", "error", err)
+		return 1
+	}
+	if len(done) == 0 {
+		fmt.Println("nothing to do")
+	}
+	for _, v := range done {
+		fmt.Printf("%s %d\n", args[0], v)
+	}
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, logger *slog.Logger, migrator db.Migrator) int {
+	status, err := migrator.MigrationStatus(ctx)
+	if err != nil {
+		logger.Error("migration status failed", "error", err)
+		return 1
+	}
+	for _, m := range status {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Format(time.RFC3339)
+		}
+		name := m.Name
+		if name == "" {
+			name = "(unknown to this release)"
+		}
+		fmt.Printf("%04d  %-24s %s\n", m.Version, name, applied)
+	}
+	return 0
+}
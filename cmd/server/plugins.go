@@ -0,0 +1,10 @@
+package main
+
+import "todoapp/internal/hooks"
+
+// plugins are the compiled-in hooks.Plugin extensions. They run in order,
+// before any HOOK_URLS endpoints; add deployment-specific business rules
+// here, e.g.
+//
+//	var plugins = []hooks.Plugin{rules.RequireDueDate{}}
+var plugins []hooks.Plugin
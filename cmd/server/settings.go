@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"todoapp/internal/accesslog"
+	"todoapp/internal/config"
+	"todoapp/internal/cron"
+	"todoapp/internal/db"
+	"todoapp/internal/hooks"
+	"todoapp/internal/slo"
+)
+
+// settings are the parts of the configuration written in a feature's own
+// vocabulary, which config leaves as text for the packages that define it.
+type settings struct {
+	anonymize, rescore, reports cron.Schedule
+	accessLogFormat             accesslog.Format
+	objectives                  []slo.Objective
+}
+
+// parseSettings parses and checks them. Like config.Load, the error lists
+// every invalid setting, so startup names all of them at once.
+func parseSettings(cfg config.Config) (settings, error) {
+	var (
+		s    settings
+		errs []error
+	)
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+	schedule := func(key, expr string) cron.Schedule {
+		if expr == "" {
+			return cron.Schedule{}
+		}
+		sched, err := cron.Parse(expr)
+		if err != nil {
+			fail(key, "%v", err)
+		}
+		return sched
+	}
+	s.anonymize = schedule("ANONYMIZE_SCHEDULE", cfg.Jobs.Anonymize)
+	s.rescore = schedule("RESCORE_SCHEDULE", cfg.Jobs.Rescore)
+	s.reports = schedule("REPORT_SCHEDULE", cfg.Reports.Schedule)
+
+	var err error
+	if s.accessLogFormat, err = accesslog.ParseFormat(cfg.AccessLog.Format); err != nil {
+		fail("ACCESS_LOG_FORMAT", "must be combined or json, got %q", cfg.AccessLog.Format)
+	}
+	if s.objectives, err = slo.ParseObjectives(cfg.SLO.Objectives); err != nil {
+		fail("SLO_OBJECTIVES", "%v", err)
+	}
+
+	events := []string{hooks.BeforeCreate, hooks.AfterUpdate, hooks.OnComplete}
+	for _, event := range cfg.Hooks.Events {
+		if !slices.Contains(events, event) {
+			fail("HOOK_EVENTS", "must list %s, %s or %s, got %q", hooks.BeforeCreate, hooks.AfterUpdate, hooks.OnComplete, event)
+		}
+	}
+	for _, m := range cfg.Reminders.DefaultOffsets {
+		if m > db.MaxReminderOffset {
+			fail("REMINDER_DEFAULT_OFFSETS", "must be minutes between 0 and %d, got %d", db.MaxReminderOffset, m)
+		}
+	}
+	return s, errors.Join(errs...)
+}
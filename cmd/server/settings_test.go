@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"todoapp/internal/config"
+)
+
+func TestParseSettings(t *testing.T) {
+	cfg, err := config.Load(func(key string) (string, bool) {
+		v, ok := map[string]string{
+			"REPORT_SCHEDULE":  "tue 07:30",
+			"RESCORE_INTERVAL": "2h",
+			"SLO_OBJECTIVES":   "todos:99.9:300ms",
+		}[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := parseSettings(cfg)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case s.anonymize.String() != "@every 1h0m0s" || s.rescore.String() != "@every 2h0m0s" || s.reports.String() != "30 7 * * tue":
+		t.Errorf("schedules %v, %v and %v", s.anonymize, s.rescore, s.reports)
+	case s.accessLogFormat != "combined" || len(s.objectives) != 1:
+		t.Errorf("access log format %q, objectives %v", s.accessLogFormat, s.objectives)
+	}
+}
+
+func TestParseSettingsListsEveryError(t *testing.T) {
+	invalid := map[string]string{
+		"HOOK_EVENTS":              "before_delete",
+		"REMINDER_DEFAULT_OFFSETS": "60,999999",
+		"REPORT_SCHEDULE":          "mon 06:00 weekly",
+		"ANONYMIZE_SCHEDULE":       "every day",
+		"ACCESS_LOG_FORMAT":        "xml",
+		"SLO_OBJECTIVES":           "todos:101:300ms",
+	}
+	cfg, err := config.Load(func(key string) (string, bool) {
+		v, ok := invalid[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseSettings(cfg)
+	if err == nil {
+		t.Fatal("invalid settings accepted")
+	}
+	for key := range invalid {
+		if !strings.Contains(err.Error(), key+": ") {
+			t.Errorf("no error names %s:\n%v", key, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todoapp/internal/config"
+	"todoapp/internal/db"
+	"todoapp/internal/mlclient"
+)
+
+// warmUp prepares connections before the server starts accepting requests,
+// so the first requests after a deploy do not pay for connection setup.
+// WARMUP_DB_CONNS opens that many pooled database connections and
+// WARMUP_ML=true makes one keep-alive request to the ML service, both within
+// WARMUP_TIMEOUT (default 10s). Failures are logged and startup continues:
+// a cold connection is still better than no server.
+func warmUp(logger *slog.Logger, store db.Store, scorer *mlclient.Client, c config.Warmup) {
+	conns := c.DBConns
+	warmML := c.ML && scorer != nil
+	if conns <= 0 && !warmML {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	if warmer, ok := store.(db.Warmer); ok && conns > 0 {
+		ready, err := warmer.Warm(ctx, conns)
+		if err != nil {
+			logger.Warn("database warm-up incomplete", "ready", ready, "requested", conns, "error", err)
+		} else {
+			logger.Info("database pool warmed", "connections", ready)
+		}
+	}
+	if warmML {
+		if err := scorer.Warm(ctx); err != nil {
+			logger.Warn("ml warm-up failed", "error", err)
+		} else {
+			logger.Info("ml connection warmed")
+		}
+	}
+	logger.Info("warm-up finished", "took_ms", time.Since(start).Milliseconds())
+}
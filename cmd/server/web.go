@@ -0,0 +1,22 @@
+//go:build !apionly
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed web/*
+var embeddedWeb embed.FS
+
+// webFS is the embedded frontend.
+var webFS = mustSub(embeddedWeb, "web")
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
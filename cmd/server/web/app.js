@@ -1,12 +1,45 @@
 async function fetchJSON(url, options) {
-  const res = await fetch(url, options)
+  let res = await fetch(url, options)
+  if (res.status === 428) {
+    const challenge = await safeJSON(res)
+    if (challenge && challenge.challenge) {
+      const solution = await solveProofOfWork(challenge.challenge, challenge.difficulty)
+      const headers = { ...(options && options.headers), 'X-PoW': solution }
+      res = await fetch(url, { ...options, headers })
+    }
+  }
   if (!res.ok) {
     const err = await safeJSON(res)
-    throw new Error(err && err.error ? err.error : 'Request failed')
+    const e = new Error(err && err.error ? err.error : 'Request failed')
+    e.status = res.status
+    throw e
   }
   return res.json()
 }
 
+// solveProofOfWork finds a nonce so SHA-256("challenge:nonce") starts with
+// `difficulty` zero bits, as required by the server's anti-spam gate.
+async function solveProofOfWork(challenge, difficulty) {
+  const encoder = new TextEncoder()
+  for (let nonce = 0; ; nonce++) {
+    const solution = `${challenge}:${nonce}`
+    const digest = new Uint8Array(await crypto.subtle.digest('SHA-256', encoder.encode(solution)))
+    if (leadingZeroBits(digest) >= difficulty) return solution
+  }
+}
+
+function leadingZeroBits(bytes) {
+  let n = 0
+  for (const b of bytes) {
+    if (b === 0) {
+      n += 8
+      continue
+    }
+    return n + Math.clz32(b) - 24
+  }
+  return n
+}
+
 async function safeJSON(res) {
   try {
     return await res.json()
@@ -60,7 +93,11 @@ function renderTodo(todo) {
       title: text.value.trim(),
       completed: checkbox.checked,
       tags: parseTags(tagsInput.value),
-      durationMinutes: parseDuration(durationInput.value)
+      durationMinutes: parseDuration(durationInput.value),
+      dueAt: todo.dueAt,
+      startAt: todo.startAt,
+      effort: todo.effort,
+      reminderOffsets: todo.reminderOffsets
     }
     const updated = await fetchJSON(`/api/todos/${todo.id}`, {
       method: 'PUT',
@@ -142,7 +179,66 @@ function formatPriority(score) {
   return val.toFixed(2)
 }
 
-loadTodos().catch(err => {
+// showAuth swaps the todo form for the login form when the server has user
+// accounts enabled and no valid session cookie was sent.
+function showAuth(visible) {
+  document.getElementById('auth-form').hidden = !visible
+  document.getElementById('new-form').hidden = visible
+  document.getElementById('list').hidden = visible
+}
+
+document.getElementById('auth-form').addEventListener('submit', async (e) => {
+  e.preventDefault()
+  const action = e.submitter && e.submitter.value === 'signup' ? 'signup' : 'login'
+  try {
+    await fetchJSON(`/api/auth/${action}`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({
+        email: document.getElementById('email').value.trim(),
+        password: document.getElementById('password').value
+      })
+    })
+  } catch (err) {
+    alert(err.message)
+    return
+  }
+  document.getElementById('password').value = ''
+  showAuth(false)
+  loadTodos().then(subscribeToChanges).catch(err => alert(err.message))
+})
+
+// subscribeToChanges reloads the list whenever another tab or teammate
+// changes a todo. Bursts of events are coalesced into one reload.
+let events = null
+function subscribeToChanges() {
+  if (events || typeof EventSource === 'undefined') return
+  events = new EventSource('/api/todos/events')
+  let pending = null
+  const reload = () => {
+    clearTimeout(pending)
+    pending = setTimeout(() => loadTodos().catch(err => console.error(err)), 150)
+  }
+  for (const type of ['todo.created', 'todo.updated', 'todo.deleted', 'todos.changed']) {
+    events.addEventListener(type, reload)
+  }
+  events.addEventListener('todo.reminder', (e) => {
+    alert(JSON.parse(e.data).message)
+  })
+  events.addEventListener('rule.notification', (e) => {
+    const ev = JSON.parse(e.data)
+    alert(ev.todo ? `${ev.message}\n\n${ev.todo.title}` : ev.message)
+  })
+  // EventSource reconnects on its own; reload so nothing missed while
+  // disconnected stays stale.
+  events.addEventListener('open', reload)
+}
+
+loadTodos().then(subscribeToChanges).catch(err => {
+  if (err.status === 401) {
+    showAuth(true)
+    return
+  }
   console.error(err)
   alert(err.message)
 })
@@ -0,0 +1,8 @@
+//go:build apionly
+
+package main
+
+import "io/fs"
+
+// webFS is nil in API-only builds, which leave the frontend out of the binary.
+var webFS fs.FS
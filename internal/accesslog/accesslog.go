@@ -0,0 +1,123 @@
+// Package accesslog writes web-server style request logs, separate from the
+// application's structured logs.
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Format selects the line layout.
+type Format string
+
+const (
+	// Combined is the Apache/NGINX "combined" log format.
+	Combined Format = "combined"
+	// JSON writes one JSON object per request.
+	JSON Format = "json"
+)
+
+// ParseFormat validates a format name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case Combined, JSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown access log format %q", name)
+	}
+}
+
+// Logger writes one line per request to an io.Writer.
+type Logger struct {
+	out    io.Writer
+	format Format
+}
+
+// New returns a Logger writing in format to out. out must be safe for
+// concurrent use; RotatingFile is.
+func New(out io.Writer, format Format) *Logger {
+	return &Logger{out: out, format: format}
+}
+
+// Middleware logs every request after it has been served.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// A single Write per line keeps lines intact under concurrency.
+		_, _ = l.out.Write(l.line(r, start, status, ww.BytesWritten()))
+	})
+}
+
+type jsonLine struct {
+	Time       time.Time `json:"time"`
+	Remote     string    `json:"remote"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	DurationMs int64     `json:"durationMs"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	RequestID  string    `json:"requestId,omitempty"`
+}
+
+func (l *Logger) line(r *http.Request, start time.Time, status, size int) []byte {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if l.format == JSON {
+		data, _ := json.Marshal(jsonLine{
+			Time:       start.UTC(),
+			Remote:     remote,
+			Method:     r.Method,
+			URI:        r.RequestURI,
+			Proto:      r.Proto,
+			Status:     status,
+			Bytes:      size,
+			DurationMs: time.Since(start).Milliseconds(),
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+			RequestID:  middleware.GetReqID(r.Context()),
+		})
+		return append(data, '\n')
+	}
+
+	var b bytes.Buffer
+	bytesField := "-"
+	if size > 0 {
+		bytesField = strconv.Itoa(size)
+	}
+	fmt.Fprintf(&b, "%s - - [%s] %q %d %s %q %q\n",
+		dash(remote),
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+r.RequestURI+" "+r.Proto,
+		status,
+		bytesField,
+		dash(r.Referer()),
+		dash(r.UserAgent()),
+	)
+	return b.Bytes()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
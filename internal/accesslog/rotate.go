@@ -0,0 +1,131 @@
+package accesslog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat suffixes rotated files; it sorts chronologically.
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile is an append-only file that is rotated once it exceeds
+// MaxBytes or has been open longer than MaxAge. Rotated files are renamed to
+// "<path>.<timestamp>" and only the newest MaxBackups are kept. Zero values
+// disable the respective limit.
+type RotatingFile struct {
+	Path       string
+	MaxBytes   int64
+	MaxAge     time.Duration
+	MaxBackups int
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// Write appends p, rotating first when p would push the file past a limit.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) due(next int64) bool {
+	if f.MaxBytes > 0 && f.size > 0 && f.size+next > f.MaxBytes {
+		return true
+	}
+	return f.MaxAge > 0 && time.Since(f.openedAt) >= f.MaxAge
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+		return fmt.Errorf("access log dir: %w", err)
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open access log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	// An existing file keeps its age across restarts.
+	f.openedAt = time.Now()
+	if info.Size() > 0 {
+		f.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	backup := f.Path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(f.Path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("rotate access log: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.openedAt = time.Now()
+	return f.prune()
+}
+
+// prune removes the oldest backups beyond MaxBackups.
+func (f *RotatingFile) prune() error {
+	if f.MaxBackups <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(f.Path + ".*")
+	if err != nil {
+		return err
+	}
+	backups := slices.DeleteFunc(matches, func(m string) bool {
+		_, err := time.Parse(backupTimeFormat, strings.TrimPrefix(m, f.Path+"."))
+		return err != nil
+	})
+	slices.Sort(backups)
+	for len(backups) > f.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package alert watches rolling failure rates in-process and notifies an
+// operator when they cross a threshold, for self-hosted deployments without
+// a monitoring stack.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"todoapp/internal/rolling"
+)
+
+// bucketCount is the resolution of each rolling window.
+const bucketCount = 30
+
+// Rule fires when the failure rate of a signal exceeds Threshold over Window,
+// provided at least MinEvents were recorded so quiet periods do not alert on
+// a single failure.
+type Rule struct {
+	Signal    string
+	Threshold float64
+	Window    time.Duration
+	MinEvents int
+}
+
+// Alert describes a threshold crossing or its recovery.
+type Alert struct {
+	Signal    string    `json:"signal"`
+	Firing    bool      `json:"firing"`
+	Rate      float64   `json:"rate"`
+	Threshold float64   `json:"threshold"`
+	Events    int       `json:"events"`
+	Window    string    `json:"window"`
+	At        time.Time `json:"at"`
+}
+
+func (a Alert) String() string {
+	state := "resolved"
+	if a.Firing {
+		state = "FIRING"
+	}
+	return fmt.Sprintf("[%s] %s failure rate %.1f%% over %s (threshold %.1f%%, %d events)",
+		state, a.Signal, a.Rate*100, a.Window, a.Threshold*100, a.Events)
+}
+
+// Notifier delivers alerts.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Monitor counts outcomes per signal and evaluates rules periodically.
+type Monitor struct {
+	rules    []Rule
+	notifier Notifier
+
+	mu      sync.Mutex
+	windows map[string]*rolling.Window
+	firing  map[string]bool
+}
+
+// NewMonitor returns a Monitor for rules that reports through n.
+func NewMonitor(n Notifier, rules ...Rule) *Monitor {
+	m := &Monitor{
+		rules:    rules,
+		notifier: n,
+		windows:  make(map[string]*rolling.Window),
+		firing:   make(map[string]bool),
+	}
+	for _, r := range rules {
+		m.windows[r.Signal] = rolling.New(r.Window, bucketCount)
+	}
+	return m
+}
+
+// Record counts one outcome for signal. Signals without a rule are ignored.
+// A nil Monitor records nothing.
+func (m *Monitor) Record(signal string, failed bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w, ok := m.windows[signal]; ok {
+		w.Add(time.Now(), failed, false)
+	}
+}
+
+// Run evaluates the rules every interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.evaluate(ctx)
+		}
+	}
+}
+
+// evaluate notifies once when a rule starts firing and once when it recovers.
+func (m *Monitor) evaluate(ctx context.Context) {
+	now := time.Now()
+	var pending []Alert
+	m.mu.Lock()
+	for _, r := range m.rules {
+		c := m.windows[r.Signal].Sum(now)
+		rate := 0.0
+		if c.Total > 0 {
+			rate = float64(c.Failed) / float64(c.Total)
+		}
+		firing := c.Total >= r.MinEvents && rate > r.Threshold
+		if firing == m.firing[r.Signal] {
+			continue
+		}
+		m.firing[r.Signal] = firing
+		pending = append(pending, Alert{
+			Signal:    r.Signal,
+			Firing:    firing,
+			Rate:      rate,
+			Threshold: r.Threshold,
+			Events:    c.Total,
+			Window:    r.Window.String(),
+			At:        now.UTC(),
+		})
+	}
+	m.mu.Unlock()
+
+	for _, a := range pending {
+		slog.WarnContext(ctx, "alert.state_changed", "signal", a.Signal, "firing", a.Firing, "rate", a.Rate)
+		if err := m.notifier.Notify(ctx, a); err != nil {
+			slog.ErrorContext(ctx, "alert.notify_failed", "signal", a.Signal, "error", err)
+		}
+	}
+}
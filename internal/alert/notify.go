@@ -0,0 +1,90 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Webhook posts each alert as JSON. The body also carries a "text" field so
+// Slack and Mattermost incoming webhooks display it without a bridge.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (w Webhook) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(struct {
+		Alert
+		Text string `json:"text"`
+	}{a, a.String()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Email sends each alert through an SMTP relay. Auth is optional; relays on
+// localhost commonly accept unauthenticated mail. AppName, when set, prefixes
+// the subject so alerts from white-labeled instances can be told apart.
+type Email struct {
+	Addr    string
+	Auth    smtp.Auth
+	From    string
+	To      []string
+	AppName string
+}
+
+// Notify implements Notifier.
+func (e Email) Notify(ctx context.Context, a Alert) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	subject := a.String()
+	if e.AppName != "" {
+		subject = "[" + e.AppName + "] " + subject
+	}
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\nAt: %s\r\n", a.String(), a.At.Format(time.RFC3339))
+	return smtp.SendMail(e.Addr, e.Auth, e.From, e.To, []byte(msg.String()))
+}
+
+// Multi fans an alert out to several notifiers, returning their joined errors.
+type Multi []Notifier
+
+// Notify implements Notifier.
+func (m Multi) Notify(ctx context.Context, a Alert) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,189 @@
+// Package audit forwards the todo audit log to the collectors security teams
+// run for hosted deployments: a syslog server (RFC 5424) or a SIEM's HTTPS
+// intake. Entries describe who changed which todo and which fields; todo
+// contents, titles included, are never sent.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/telemetry"
+)
+
+// cursorName is the forwarder's cursor in db.AuditLog.
+const cursorName = "forwarder"
+
+// commitLag is how old a change must be before it is forwarded: ids are
+// taken when a transaction inserts, so a younger one may still be followed
+// by a lower id committing late.
+const commitLag = 5 * time.Second
+
+// Retry backoff after a failed batch.
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Entry is an audit log entry as forwarded.
+type Entry struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	TodoID    int64     `json:"todoId"`
+	OwnerID   int64     `json:"ownerId,omitempty"`
+	ActorID   int64     `json:"actorId,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	// Changed lists the fields an update changed, by their JSON names.
+	Changed []string `json:"changed,omitempty"`
+	// Hash is the entry's audit chain hash when the store chains history.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Sink delivers a batch of entries to a collector.
+type Sink interface {
+	Send(ctx context.Context, entries []Entry) error
+	Close() error
+}
+
+// NewSink returns the sink for rawURL: udp://, tcp:// or tls://host:port for
+// syslog, or an http(s):// collector URL, which is sent token as a bearer
+// token.
+func NewSink(rawURL, token string) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit forward url %q", rawURL)
+	}
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+		if u.Host == "" || u.Port() == "" {
+			return nil, fmt.Errorf("audit forward url %q needs a host and port", rawURL)
+		}
+		return newSyslogSink(u.Scheme, u.Host), nil
+	case "http", "https":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid audit forward url %q", rawURL)
+		}
+		return newHTTPSink(rawURL, token), nil
+	default:
+		return nil, fmt.Errorf("audit forward url %q must be udp, tcp, tls, http or https", rawURL)
+	}
+}
+
+// Forwarder sends new audit log entries to Sink, in batches of up to Batch
+// every Interval. Delivery is at least once: the cursor moves after a batch
+// is accepted, so a crash in between sends it again.
+type Forwarder struct {
+	Log      db.AuditLog
+	Sink     Sink
+	Interval time.Duration
+	Batch    int
+}
+
+// Run forwards entries until ctx is cancelled. A failed batch is retried,
+// with backoff, before any later entry is sent.
+func (f *Forwarder) Run(ctx context.Context) {
+	defer f.Sink.Close()
+	wait := time.Duration(0)
+	backoff := minBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		n, err := f.forward(ctx)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			slog.WarnContext(ctx, "audit.forward_failed", "error", err, "retry_in", backoff.String())
+			wait, backoff = backoff, min(2*backoff, maxBackoff)
+		case n == f.Batch:
+			wait, backoff = 0, minBackoff
+		default:
+			wait, backoff = f.Interval, minBackoff
+		}
+	}
+}
+
+// forward sends the next batch and returns its size.
+func (f *Forwarder) forward(ctx context.Context) (int, error) {
+	after, err := f.Log.AuditCursor(ctx, cursorName)
+	if err != nil {
+		return 0, fmt.Errorf("read cursor: %w", err)
+	}
+	changes, err := f.Log.AuditEntries(ctx, after, time.Now().Add(-commitLag), f.Batch)
+	if err != nil {
+		return 0, fmt.Errorf("read audit log: %w", err)
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	entries := make([]Entry, len(changes))
+	for i, c := range changes {
+		entries[i] = entryOf(c)
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	err = f.Sink.Send(sendCtx, entries)
+	cancel()
+	if err != nil {
+		telemetry.AuditForwarded.WithLabelValues("failed").Add(float64(len(entries)))
+		return 0, err
+	}
+	telemetry.AuditForwarded.WithLabelValues("sent").Add(float64(len(entries)))
+	if err := f.Log.SetAuditCursor(ctx, cursorName, changes[len(changes)-1].ID); err != nil {
+		return 0, fmt.Errorf("save cursor: %w", err)
+	}
+	slog.DebugContext(ctx, "audit.forwarded", "entries", len(entries))
+	return len(entries), nil
+}
+
+func entryOf(c db.AuditEntry) Entry {
+	return Entry{
+		ID:        c.ID,
+		Time:      c.CreatedAt.UTC(),
+		Action:    c.Action,
+		TodoID:    c.TodoID,
+		OwnerID:   c.OwnerID,
+		ActorID:   c.ActorID,
+		RequestID: c.RequestID,
+		Changed:   changedFields(c.Old, c.New),
+		Hash:      c.Hash,
+	}
+}
+
+// changedFields names the fields that differ between old and new, both
+// present. UpdatedAt changes with every update and is left out.
+func changedFields(old, new *db.Todo) []string {
+	if old == nil || new == nil {
+		return nil
+	}
+	var before, after map[string]json.RawMessage
+	if b, err := json.Marshal(old); err != nil || json.Unmarshal(b, &before) != nil {
+		return nil
+	}
+	if b, err := json.Marshal(new); err != nil || json.Unmarshal(b, &after) != nil {
+		return nil
+	}
+	var changed []string
+	for name, v := range after {
+		if name != "updatedAt" && string(before[name]) != string(v) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpSink posts each batch as a JSON array, the shape HTTP event
+// collectors (Splunk HEC's raw endpoint, Elastic, Sumo Logic, ...) accept.
+type httpSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newHTTPSink(url, token string) *httpSink {
+	return &httpSink{url: url, token: token, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Send posts the entries; any status but 2xx is an error.
+func (s *httpSink) Send(ctx context.Context, entries []Entry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("audit collector: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit collector: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,114 @@
+// Package blob uploads files to object storage: a local directory, Amazon
+// S3, Google Cloud Storage through its S3-compatible XML API, or any other
+// S3-compatible service such as MinIO.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Bucket stores objects by key. Keys use "/" as separator.
+type Bucket interface {
+	// Put uploads body under key, replacing any existing object. body is
+	// read to the end, possibly more than once.
+	Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error
+}
+
+// Credentials are the HMAC access keys of an S3-compatible service. For
+// GCS they are the HMAC keys of a service account.
+type Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// gcsEndpoint is the S3-compatible endpoint of Google Cloud Storage.
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// Open returns the bucket rawURL names:
+//
+//	file:///var/exports                       a local directory
+//	s3://bucket/prefix?region=eu-west-1       Amazon S3
+//	s3://bucket/prefix?endpoint=http://minio:9000
+//	gs://bucket/prefix                        Google Cloud Storage
+//
+// The path after the bucket, if any, prefixes every key. creds are used by
+// s3 and gs buckets.
+func Open(rawURL string, creds Credentials) (Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("blob: invalid url: %w", err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, errors.New("blob: file url needs a path")
+		}
+		return Dir{Root: filepath.FromSlash(u.Path)}, nil
+	case "s3", "gs":
+		if u.Host == "" {
+			return nil, errors.New("blob: url needs a bucket")
+		}
+		if creds.AccessKey == "" || creds.SecretKey == "" {
+			return nil, errors.New("blob: access and secret keys are required")
+		}
+		b := &S3{
+			Endpoint:    u.Query().Get("endpoint"),
+			Region:      u.Query().Get("region"),
+			Bucket:      u.Host,
+			Prefix:      prefix,
+			Credentials: creds,
+		}
+		if u.Scheme == "gs" {
+			b.Endpoint, b.Region = gcsEndpoint, "auto"
+		}
+		if b.Region == "" {
+			b.Region = "us-east-1"
+		}
+		if b.Endpoint == "" {
+			b.Endpoint = "https://s3." + b.Region + ".amazonaws.com"
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("blob: unsupported scheme %q", u.Scheme)
+	}
+}
+
+// Dir stores objects as files under Root, for a mounted volume or a
+// directory some other tool syncs.
+type Dir struct {
+	Root string
+}
+
+// Put implements Bucket. The file appears complete or not at all.
+func (d Dir) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("blob: invalid key %q", key)
+	}
+	path := filepath.Join(d.Root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
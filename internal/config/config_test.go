@@ -0,0 +1,137 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestKeysAreWhatLoadReads(t *testing.T) {
+	read := make(map[string]bool)
+	if _, err := Load(func(key string) (string, bool) {
+		read[key] = true
+		return "", false
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range Keys {
+		if !read[key.Name] {
+			t.Errorf("%s is in Keys but not read", key.Name)
+		}
+	}
+	for key := range read {
+		if _, ok := Lookup(key); !ok {
+			t.Errorf("%s is read but missing from Keys", key)
+		}
+	}
+	if names := Names(func(Key) bool { return true }); len(slices.Compact(names)) != len(Keys) {
+		t.Error("Keys lists a variable twice")
+	}
+}
+
+func TestSecretsAreNotTunable(t *testing.T) {
+	for _, key := range Keys {
+		looksSecret := strings.Contains(key.Name, "SECRET") || strings.Contains(key.Name, "TOKEN") ||
+			strings.Contains(key.Name, "PASSWORD") || strings.HasPrefix(key.Name, "ENCRYPTION_")
+		if looksSecret && !key.Secret {
+			t.Errorf("%s is not marked Secret", key.Name)
+		}
+		if key.Secret && key.Tunable() {
+			t.Errorf("%s is a tunable secret", key.Name)
+		}
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := Load(env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case c.Listen != ":8080" || c.SocketMode != 0o660:
+		t.Errorf("listen %q mode %o", c.Listen, c.SocketMode)
+	case !c.Storage.Migrate || c.Storage.SchemaDrift != "warn" || !c.API.Effort || !c.Static.Serve:
+		t.Errorf("switches %+v %+v", c.Storage, c.API)
+	case c.ML.Retries != 1 || c.ML.CacheTTL != 5*time.Minute || c.ML.Ready != "optional":
+		t.Errorf("ml %+v", c.ML)
+	case c.Jobs.Anonymize != "@every 1h0m0s" || c.Jobs.Rescore != "":
+		t.Errorf("schedules %q and %q", c.Jobs.Anonymize, c.Jobs.Rescore)
+	case c.AccessLog.Format != "combined" || c.Reports.Schedule != "0 6 * * mon":
+		t.Errorf("access log %+v reports %+v", c.AccessLog, c.Reports)
+	case c.Alerts.ErrorRate != 0.05 || c.Auth.SessionTTL != 168*time.Hour || c.Webhooks.Concurrency != 2:
+		t.Errorf("alerts %+v auth %+v webhooks %+v", c.Alerts, c.Auth, c.Webhooks)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load(env(map[string]string{
+		"PORT":                     "9000",
+		"LISTEN_SOCKET_MODE":       "0600",
+		"HOOK_EVENTS":              "before_create, on_complete",
+		"REMINDER_DEFAULT_OFFSETS": "1440,60",
+		"REPORT_SCHEDULE":          "tue 07:30",
+		"RESCORE_SCHEDULE":         "0 3 * * *",
+		"ML_HEDGE_BUDGET":          "0.05",
+		"MIGRATE_ON_START":         "false",
+		"ENCRYPTION_KEY":           "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		"AWS_ACCESS_KEY_ID":        "aws",
+		"REPORT_ACCESS_KEY_ID":     "report",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case c.Listen != ":9000" || c.SocketMode != 0o600:
+		t.Errorf("listen %q mode %o", c.Listen, c.SocketMode)
+	case !slices.Equal(c.Hooks.Events, []string{"before_create", "on_complete"}):
+		t.Errorf("hook events %q", c.Hooks.Events)
+	case !slices.Equal(c.Reminders.DefaultOffsets, []int{1440, 60}):
+		t.Errorf("reminder offsets %v", c.Reminders.DefaultOffsets)
+	case c.Reports.Schedule != "30 7 * * tue" || c.Jobs.Rescore != "0 3 * * *":
+		t.Errorf("schedules %q and %q", c.Reports.Schedule, c.Jobs.Rescore)
+	case c.ML.HedgeBudget != 0.05 || c.Storage.Migrate:
+		t.Errorf("ml %+v storage %+v", c.ML, c.Storage)
+	case len(c.Storage.EncryptionKeys) != 1 || len(c.Storage.EncryptionKeys[0]) != 32:
+		t.Errorf("encryption keys %q", c.Storage.EncryptionKeys)
+	case c.Reports.Credentials.AccessKey != "report" || c.EventLog.Credentials.AccessKey != "aws":
+		t.Errorf("credentials %+v and %+v", c.Reports.Credentials, c.EventLog.Credentials)
+	}
+}
+
+func TestLoadListsEveryError(t *testing.T) {
+	invalid := map[string]string{
+		"ML_RETRIES":               "-1",
+		"ML_HEDGE_BUDGET":          "2",
+		"ML_CACHE_TTL":             "5 minutes",
+		"AUDIT_HASH_CHAIN":         "yes please",
+		"SCHEMA_DRIFT":             "loud",
+		"ADMIN_ADDR":               "localhost",
+		"LISTEN_SOCKET_MODE":       "rw",
+		"LOG_REDACT_PATTERNS":      "ok;(",
+		"HOOK_URLS":                "ftp://hooks.example.com",
+		"REMINDER_DEFAULT_OFFSETS": "60,soon",
+		"REPORT_SCHEDULE":          "mon 6am",
+		"ENCRYPTION_KEY":           "c2hvcnQ=",
+		"SCIM_TOKEN":               "scim",
+		"STATIC_DIR":               "/does/not/exist",
+		"USAGE_REPORTING":          "true",
+		"LOAD_SHED_INITIAL_LIMIT":  "500",
+	}
+	_, err := Load(env(invalid))
+	if err == nil {
+		t.Fatal("invalid settings accepted")
+	}
+	for key := range invalid {
+		if !strings.Contains(err.Error(), key+": ") {
+			t.Errorf("no error names %s:\n%v", key, err)
+		}
+	}
+}
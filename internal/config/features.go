@@ -0,0 +1,712 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Features are the settings beyond the core. Intervals of background jobs
+// disable the job when zero.
+type Features struct {
+	// Listen overrides PORT with a full address (LISTEN); "unix:///path"
+	// serves on a Unix domain socket with SocketMode (LISTEN_SOCKET_MODE,
+	// octal, default 0660) and SocketGroup (LISTEN_SOCKET_GROUP).
+	Listen      string
+	SocketMode  os.FileMode
+	SocketGroup string
+	// AdminToken enables the admin API (ADMIN_TOKEN). AdminAddr serves it
+	// and /debug on a separate internal listener (ADMIN_ADDR).
+	AdminToken string
+	AdminAddr  string
+	// GRPCAddr serves todo.v1.TodoService on a second port (GRPC_ADDR).
+	GRPCAddr string
+	// ServiceName names the process in traces (OTEL_SERVICE_NAME, default
+	// todo-api). Tracing is set when OTEL_EXPORTER_OTLP_ENDPOINT or
+	// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT exports spans.
+	ServiceName string
+	Tracing     bool
+	// RedactKeys (LOG_REDACT_KEYS, comma-separated) and RedactPatterns
+	// (LOG_REDACT_PATTERNS, regular expressions separated by ";") extend
+	// the built-in log redaction.
+	RedactKeys     []string
+	RedactPatterns []string
+
+	Storage   Storage
+	ML        ML
+	Limits    Limits
+	API       API
+	Auth      Auth
+	Static    Static
+	Metrics   Metrics
+	Jobs      Jobs
+	Webhooks  Webhooks
+	Hooks     Hooks
+	Reminders Reminders
+	AccessLog AccessLog
+	Alerts    Alerts
+	SLO       SLO
+	Branding  Branding
+	Reports   Reports
+	EventLog  EventLog
+	Audit     AuditForward
+	Usage     Usage
+	Warmup    Warmup
+	Inbound   Inbound
+}
+
+// Storage configures the database beyond the pool.
+type Storage struct {
+	// Cockroach switches the SQL store to the CockroachDB dialect
+	// (COCKROACH).
+	Cockroach bool
+	// Migrate applies pending migrations on start (MIGRATE_ON_START,
+	// default true); otherwise `server migrate up` does.
+	Migrate bool
+	// AuditChain hash-chains the todo history (AUDIT_HASH_CHAIN).
+	AuditChain bool
+	// SchemaDrift is warn (default), fail or off (SCHEMA_DRIFT).
+	SchemaDrift string
+	// EncryptionKeys are the decoded base64 master keys for title
+	// encryption: ENCRYPTION_KEY first, then ENCRYPTION_PREVIOUS_KEYS
+	// (comma-separated). None leaves titles in plain text.
+	EncryptionKeys [][]byte
+	// CacheTTL enables the in-process GetTodo cache (TODO_CACHE_TTL).
+	CacheTTL time.Duration
+	// CredentialsCommand (DB_CREDENTIALS_COMMAND) or CredentialsFile
+	// (DB_CREDENTIALS_FILE) supply a database token, reused for
+	// CredentialsTTL (DB_CREDENTIALS_TTL, default 10m).
+	CredentialsCommand string
+	CredentialsFile    string
+	CredentialsTTL     time.Duration
+}
+
+// ML tunes the scoring client; zero disables each mechanism.
+type ML struct {
+	Retries         int           // ML_RETRIES, default 1
+	RetryBackoff    time.Duration // ML_RETRY_BACKOFF, default 100ms
+	BreakerFailures int           // ML_BREAKER_FAILURES, default 5
+	BreakerCooldown time.Duration // ML_BREAKER_COOLDOWN, default 30s
+	CacheSize       int           // ML_CACHE_SIZE, default 1000
+	CacheTTL        time.Duration // ML_CACHE_TTL, default 5m
+	// HedgeBudget is the share of scores that may be hedged
+	// (ML_HEDGE_BUDGET, between 0 and 1).
+	HedgeBudget         float64
+	CalibrationInterval time.Duration // ML_CALIBRATION_INTERVAL
+	UserFeaturesTTL     time.Duration // ML_USER_FEATURES_TTL
+	// DeferBelow (ML_DEFER_BELOW) defers scoring of requests with less
+	// time left, at most DeferQueue (ML_DEFER_QUEUE, default 1000) waiting.
+	DeferBelow time.Duration
+	DeferQueue int
+	// Ready is whether /ready checks the ML service: optional (default),
+	// required or off (READY_ML).
+	Ready string
+}
+
+// Limits bound what users may store and send; zero means no limit.
+type Limits struct {
+	MaxOpenTodos  int64 // MAX_OPEN_TODOS
+	MaxTotalTodos int64 // MAX_TOTAL_TODOS
+	// ProofOfWork is the difficulty of the stamp todo creation requires
+	// (POW_DIFFICULTY).
+	ProofOfWork int
+	// IPRate and UserRate cap requests per second per client address and
+	// per user (RATE_LIMIT_IP_RPS, RATE_LIMIT_USER_RPS), with bursts of
+	// IPBurst and UserBurst (RATE_LIMIT_IP_BURST, default 20;
+	// RATE_LIMIT_USER_BURST, default 40).
+	IPRate    float64
+	IPBurst   int
+	UserRate  float64
+	UserBurst int
+	// LoadShedding caps concurrent API requests with an adaptive limit
+	// (LOAD_SHEDDING) between ShedMin and ShedMax, starting at ShedInitial
+	// (LOAD_SHED_MIN_LIMIT, LOAD_SHED_MAX_LIMIT, LOAD_SHED_INITIAL_LIMIT;
+	// defaults 10, 200 and 20).
+	LoadShedding bool
+	ShedMin      int
+	ShedMax      int
+	ShedInitial  int
+}
+
+// API shapes the REST API.
+type API struct {
+	Hypermedia bool // HYPERMEDIA_LINKS
+	Effort     bool // EFFORT_TRACKING, default true
+	StatusPage bool // STATUS_PAGE
+	// PriorityHalfLife decays effectivePriority (PRIORITY_HALF_LIFE).
+	PriorityHalfLife time.Duration
+	// Search weights blend relevance, priority and recency
+	// (SEARCH_WEIGHT_TEXT, default 1; SEARCH_WEIGHT_PRIORITY,
+	// SEARCH_WEIGHT_RECENCY; SEARCH_RECENCY_HALF_LIFE).
+	SearchText      float64
+	SearchPriority  float64
+	SearchRecency   float64
+	RecencyHalfLife time.Duration
+	// ListCacheEntries bounds the encoded default lists kept in memory
+	// (LIST_CACHE_ENTRIES, default 1024; 0 disables the cache).
+	ListCacheEntries int
+	// UndoWindow is how long a batch completion can be undone
+	// (COMPLETE_UNDO_WINDOW, default 10s).
+	UndoWindow time.Duration
+	// ReadyTimeout bounds each /ready check (READY_TIMEOUT, default 2s).
+	ReadyTimeout time.Duration
+	// SpecValidation is log or enforce (OPENAPI_VALIDATION); empty skips
+	// checking bodies against the OpenAPI document.
+	SpecValidation string
+	// IDSecret spells ids as opaque strings (ID_OBFUSCATION_SECRET).
+	IDSecret string
+	// JournalSize keeps that many mutating requests (JOURNAL_SIZE), for
+	// replay against JournalReplayURL with JournalReplayToken
+	// (JOURNAL_REPLAY_URL, JOURNAL_REPLAY_TOKEN).
+	JournalSize        int
+	JournalReplayURL   string
+	JournalReplayToken string
+	// CDNMaxAge lets a CDN keep public responses (CDN_MAX_AGE), purged
+	// through CDNProvider's API (CDN_PROVIDER, CDN_PURGE_URL,
+	// CDN_PURGE_TOKEN).
+	CDNMaxAge     time.Duration
+	CDNProvider   string
+	CDNPurgeURL   string
+	CDNPurgeToken string
+}
+
+// Auth configures user accounts, enabled by Secret.
+type Auth struct {
+	// Secret signs sessions (AUTH_SECRET, at least 32 bytes), which last
+	// SessionTTL (AUTH_SESSION_TTL, default 168h).
+	Secret     string
+	SessionTTL time.Duration
+	// SCIMToken serves SCIM provisioning to bearers of it (SCIM_TOKEN).
+	SCIMToken string
+	// SigningWindow requires signed API requests (REQUEST_SIGNING_WINDOW).
+	SigningWindow time.Duration
+}
+
+// Static configures the frontend.
+type Static struct {
+	// Serve is false for a pure API (SERVE_STATIC, default true).
+	Serve bool
+	// Dir serves the frontend from disk (STATIC_DIR), reloading open pages
+	// on changes when LiveReload (STATIC_LIVE_RELOAD).
+	Dir        string
+	LiveReload bool
+}
+
+// Metrics configures metrics export.
+type Metrics struct {
+	// Mode (METRICS) is "true" to serve Prometheus metrics at /metrics or
+	// "statsd" to push them.
+	Mode string
+	// StatsD is where statsd metrics go (STATSD_ADDR, default
+	// 127.0.0.1:8125) every StatsDInterval (STATSD_INTERVAL, default 10s),
+	// prefixed with StatsDPrefix (STATSD_PREFIX) and, unless StatsDPlain
+	// (STATSD_FLAVOR=plain), tagged with StatsDTags (STATSD_TAGS).
+	StatsD         string
+	StatsDInterval time.Duration
+	StatsDPrefix   string
+	StatsDTags     []string
+	StatsDPlain    bool
+	// FlushInterval adds in-memory event counts to the daily totals
+	// (STATS_FLUSH_INTERVAL, default 1m), RollupInterval precomputes past
+	// days' stats (STATS_ROLLUP_INTERVAL).
+	FlushInterval  time.Duration
+	RollupInterval time.Duration
+}
+
+// Jobs schedules the background jobs. Schedules are cron expressions,
+// "@every <interval>" for an interval, or empty when the job never runs.
+type Jobs struct {
+	// Anonymize anonymizes completed todos untouched for AnonymizeAfterDays
+	// (ANONYMIZE_AFTER_DAYS), only counting them when AnonymizeDryRun
+	// (ANONYMIZE_DRY_RUN), on ANONYMIZE_SCHEDULE or every
+	// ANONYMIZE_INTERVAL (default 1h).
+	AnonymizeAfterDays int
+	AnonymizeDryRun    bool
+	Anonymize          string
+	// Rescore re-scores todos on RESCORE_SCHEDULE or every
+	// RESCORE_INTERVAL.
+	Rescore string
+	// RulesInterval checks due_soon rules (RULES_INTERVAL, default 1m).
+	RulesInterval time.Duration
+	// SubscriptionInterval checks saved searches (SUBSCRIPTION_INTERVAL,
+	// default 5m).
+	SubscriptionInterval time.Duration
+	// StaleAfterDays nudges about untouched todos (STALE_NUDGE_AFTER_DAYS),
+	// checked every StaleInterval (STALE_NUDGE_INTERVAL, default 1h).
+	StaleAfterDays int
+	StaleInterval  time.Duration
+}
+
+// Webhooks configures delivery of user webhooks.
+type Webhooks struct {
+	// Interval retries queued deliveries (WEBHOOK_INTERVAL, default 5s).
+	Interval time.Duration
+	// Concurrency bounds deliveries per webhook (WEBHOOK_CONCURRENCY,
+	// default 2); PauseAfter failures in a row pause one
+	// (WEBHOOK_PAUSE_AFTER, default 20; 0 never pauses).
+	Concurrency int
+	PauseAfter  int
+}
+
+// Hooks adds external todo hooks.
+type Hooks struct {
+	// URLs are called (HOOK_URLS, comma-separated) for Events (HOOK_EVENTS,
+	// comma-separated hook names; default all), signed with
+	// Secret (HOOK_SECRET). FailOpen lets creates through when an endpoint
+	// is down (HOOK_FAIL_OPEN).
+	URLs     []string
+	Events   []string
+	Secret   string
+	FailOpen bool
+}
+
+// Reminders configures due date reminders.
+type Reminders struct {
+	// Interval checks for reminders coming due (REMINDER_INTERVAL,
+	// default 1m).
+	Interval time.Duration
+	// DefaultOffsets are the minutes before the due date to remind about
+	// todos without offsets (REMINDER_DEFAULT_OFFSETS, comma-separated;
+	// the store bounds them).
+	DefaultOffsets []int
+	// WebhookURL also posts reminders (REMINDER_WEBHOOK_URL), signed with
+	// WebhookSecret (REMINDER_WEBHOOK_SECRET).
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// AccessLog configures the access log, written to Path (ACCESS_LOG).
+type AccessLog struct {
+	Path       string
+	Format     string        // ACCESS_LOG_FORMAT, default combined
+	MaxSizeMB  int           // ACCESS_LOG_MAX_SIZE_MB, default 100
+	MaxAge     time.Duration // ACCESS_LOG_MAX_AGE
+	MaxBackups int           // ACCESS_LOG_MAX_BACKUPS, default 7
+}
+
+// Alerts configures the error-rate alerter.
+type Alerts struct {
+	// WebhookURL receives alerts (ALERT_WEBHOOK_URL).
+	WebhookURL string
+	// SMTPAddr mails them from EmailFrom to EmailTo (ALERT_SMTP_ADDR,
+	// ALERT_EMAIL_FROM, ALERT_EMAIL_TO comma-separated), authenticating
+	// with SMTPUser and SMTPPassword when set.
+	SMTPAddr     string
+	SMTPUser     string
+	SMTPPassword string
+	EmailFrom    string
+	EmailTo      []string
+	// ErrorRate and MLFailureRate are the alerting fractions
+	// (ALERT_ERROR_RATE, default 0.05; ALERT_ML_FAILURE_RATE, default 0.5)
+	// over Window (ALERT_WINDOW, default 5m).
+	ErrorRate     float64
+	MLFailureRate float64
+	Window        time.Duration
+}
+
+// SLO configures the service level objectives.
+type SLO struct {
+	Objectives string        // SLO_OBJECTIVES, see slo.ParseObjectives
+	Period     time.Duration // SLO_PERIOD, default 720h
+}
+
+// Branding white-labels the frontend (BRAND_*); server.Branding validates
+// the values.
+type Branding struct {
+	AppName         string // BRAND_APP_NAME
+	LogoURL         string // BRAND_LOGO_URL
+	PrimaryColor    string // BRAND_PRIMARY_COLOR
+	BackgroundColor string // BRAND_BACKGROUND_COLOR
+	TextColor       string // BRAND_TEXT_COLOR
+}
+
+// Reports configures the scheduled report export to URL
+// (REPORT_EXPORT_URL, a blob.Open url).
+type Reports struct {
+	URL string
+	// Credentials are REPORT_ACCESS_KEY_ID and REPORT_SECRET_ACCESS_KEY,
+	// or the AWS_* variables.
+	Credentials Credentials
+	// Format is csv (default) or json (REPORT_FORMAT).
+	Format string
+	// Schedule is REPORT_SCHEDULE as a cron expression; the variable may
+	// also be a weekday and UTC time (default "mon 06:00").
+	Schedule string
+	// WebhookURL is notified after each run (REPORT_WEBHOOK_URL), signed
+	// with WebhookSecret (REPORT_WEBHOOK_SECRET).
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// EventLog configures the event log export to URL (EVENT_LOG_URL, a
+// blob.Open url).
+type EventLog struct {
+	URL string
+	// Credentials are EVENT_LOG_ACCESS_KEY_ID and
+	// EVENT_LOG_SECRET_ACCESS_KEY, or the AWS_* variables.
+	Credentials Credentials
+	// SpoolDir holds files until they are uploaded (EVENT_LOG_SPOOL_DIR,
+	// default under the temporary directory).
+	SpoolDir string
+	// Buffer is how many events may wait (EVENT_LOG_BUFFER, default 4096).
+	Buffer int
+}
+
+// AuditForward streams the audit log to URL (AUDIT_FORWARD_URL) with Token
+// (AUDIT_FORWARD_TOKEN), in batches of Batch (AUDIT_FORWARD_BATCH, default
+// 100) every Interval (AUDIT_FORWARD_INTERVAL, default 5s).
+type AuditForward struct {
+	URL      string
+	Token    string
+	Interval time.Duration
+	Batch    int
+}
+
+// Usage configures opt-in anonymous usage reports (USAGE_REPORTING), posted
+// to URL (USAGE_REPORT_URL) every Interval (USAGE_REPORT_INTERVAL, default
+// 24h, at least 1h).
+type Usage struct {
+	Enabled  bool
+	URL      string
+	Interval time.Duration
+}
+
+// Warmup prepares connections before serving: DBConns pooled connections
+// (WARMUP_DB_CONNS) and, when ML (WARMUP_ML), one to the ML service, within
+// Timeout (WARMUP_TIMEOUT, default 10s).
+type Warmup struct {
+	DBConns int
+	ML      bool
+	Timeout time.Duration
+}
+
+// Inbound are the signing secrets of the third-party webhooks received;
+// an integration without one is not registered.
+type Inbound struct {
+	GitHubSecret  string // GITHUB_WEBHOOK_SECRET
+	StripeSecret  string // STRIPE_WEBHOOK_SECRET
+	GenericSecret string // INBOUND_WEBHOOK_SECRET
+}
+
+// features loads the Features; port is the default of LISTEN. Every
+// variable is read whether or not its feature is enabled, so none goes
+// unchecked.
+func (l *loader) features(port string) Features {
+	f := Features{
+		Listen:         l.str("LISTEN", ":"+port),
+		SocketGroup:    l.str("LISTEN_SOCKET_GROUP", ""),
+		AdminToken:     l.str("ADMIN_TOKEN", ""),
+		AdminAddr:      l.addr("ADMIN_ADDR", ""),
+		GRPCAddr:       l.addr("GRPC_ADDR", ""),
+		ServiceName:    l.str("OTEL_SERVICE_NAME", "todo-api"),
+		Tracing:        l.str("OTEL_EXPORTER_OTLP_ENDPOINT", l.str("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")) != "",
+		RedactKeys:     l.list("LOG_REDACT_KEYS", ","),
+		RedactPatterns: l.list("LOG_REDACT_PATTERNS", ";"),
+		Storage: Storage{
+			Cockroach:          l.bool("COCKROACH", false),
+			Migrate:            l.bool("MIGRATE_ON_START", true),
+			AuditChain:         l.bool("AUDIT_HASH_CHAIN", false),
+			SchemaDrift:        l.oneOf("SCHEMA_DRIFT", "warn", "warn", "fail", "off"),
+			CacheTTL:           l.duration("TODO_CACHE_TTL", 0, true),
+			CredentialsCommand: l.str("DB_CREDENTIALS_COMMAND", ""),
+			CredentialsFile:    l.str("DB_CREDENTIALS_FILE", ""),
+			CredentialsTTL:     l.duration("DB_CREDENTIALS_TTL", 10*time.Minute, false),
+		},
+		ML: ML{
+			Retries:             l.int("ML_RETRIES", 1, 0),
+			RetryBackoff:        l.duration("ML_RETRY_BACKOFF", 100*time.Millisecond, true),
+			BreakerFailures:     l.int("ML_BREAKER_FAILURES", 5, 0),
+			BreakerCooldown:     l.duration("ML_BREAKER_COOLDOWN", 30*time.Second, true),
+			CacheSize:           l.int("ML_CACHE_SIZE", 1000, 0),
+			CacheTTL:            l.duration("ML_CACHE_TTL", 5*time.Minute, true),
+			HedgeBudget:         l.float("ML_HEDGE_BUDGET", 0, 0, 1),
+			CalibrationInterval: l.duration("ML_CALIBRATION_INTERVAL", 0, true),
+			UserFeaturesTTL:     l.duration("ML_USER_FEATURES_TTL", 0, true),
+			DeferBelow:          l.duration("ML_DEFER_BELOW", 0, true),
+			DeferQueue:          l.int("ML_DEFER_QUEUE", 1000, 1),
+			Ready:               l.oneOf("READY_ML", "optional", "optional", "required", "off"),
+		},
+		Limits: Limits{
+			MaxOpenTodos:  int64(l.int("MAX_OPEN_TODOS", 0, 0)),
+			MaxTotalTodos: int64(l.int("MAX_TOTAL_TODOS", 0, 0)),
+			ProofOfWork:   l.int("POW_DIFFICULTY", 0, 0),
+			IPRate:        l.float("RATE_LIMIT_IP_RPS", 0, 0, math.Inf(1)),
+			IPBurst:       l.int("RATE_LIMIT_IP_BURST", 20, 1),
+			UserRate:      l.float("RATE_LIMIT_USER_RPS", 0, 0, math.Inf(1)),
+			UserBurst:     l.int("RATE_LIMIT_USER_BURST", 40, 1),
+			LoadShedding:  l.bool("LOAD_SHEDDING", false),
+			ShedMin:       l.int("LOAD_SHED_MIN_LIMIT", 10, 1),
+			ShedMax:       l.int("LOAD_SHED_MAX_LIMIT", 200, 1),
+			ShedInitial:   l.int("LOAD_SHED_INITIAL_LIMIT", 20, 1),
+		},
+		API: API{
+			Hypermedia:         l.bool("HYPERMEDIA_LINKS", false),
+			Effort:             l.bool("EFFORT_TRACKING", true),
+			StatusPage:         l.bool("STATUS_PAGE", false),
+			PriorityHalfLife:   l.duration("PRIORITY_HALF_LIFE", 0, true),
+			SearchText:         l.float("SEARCH_WEIGHT_TEXT", 1, 0, math.Inf(1)),
+			SearchPriority:     l.float("SEARCH_WEIGHT_PRIORITY", 0, 0, math.Inf(1)),
+			SearchRecency:      l.float("SEARCH_WEIGHT_RECENCY", 0, 0, math.Inf(1)),
+			RecencyHalfLife:    l.duration("SEARCH_RECENCY_HALF_LIFE", 0, true),
+			ListCacheEntries:   l.int("LIST_CACHE_ENTRIES", 1024, 0),
+			UndoWindow:         l.duration("COMPLETE_UNDO_WINDOW", 10*time.Second, true),
+			ReadyTimeout:       l.duration("READY_TIMEOUT", 2*time.Second, false),
+			SpecValidation:     l.oneOf("OPENAPI_VALIDATION", "", "log", "enforce"),
+			IDSecret:           l.str("ID_OBFUSCATION_SECRET", ""),
+			JournalSize:        l.int("JOURNAL_SIZE", 0, 0),
+			JournalReplayURL:   l.url("JOURNAL_REPLAY_URL"),
+			JournalReplayToken: l.str("JOURNAL_REPLAY_TOKEN", ""),
+			CDNMaxAge:          l.duration("CDN_MAX_AGE", 0, true),
+			CDNProvider:        l.oneOf("CDN_PROVIDER", "", "fastly", "cloudflare"),
+			CDNPurgeURL:        l.url("CDN_PURGE_URL"),
+			CDNPurgeToken:      l.str("CDN_PURGE_TOKEN", ""),
+		},
+		Auth: Auth{
+			Secret:        l.str("AUTH_SECRET", ""),
+			SessionTTL:    l.duration("AUTH_SESSION_TTL", 168*time.Hour, false),
+			SCIMToken:     l.str("SCIM_TOKEN", ""),
+			SigningWindow: l.duration("REQUEST_SIGNING_WINDOW", 0, true),
+		},
+		Static: Static{
+			Serve:      l.bool("SERVE_STATIC", true),
+			Dir:        l.str("STATIC_DIR", ""),
+			LiveReload: l.bool("STATIC_LIVE_RELOAD", false),
+		},
+		Metrics: Metrics{
+			Mode:           l.oneOf("METRICS", "", "true", "false", "statsd"),
+			StatsD:         l.addr("STATSD_ADDR", "127.0.0.1:8125"),
+			StatsDInterval: l.duration("STATSD_INTERVAL", 10*time.Second, false),
+			StatsDPrefix:   l.str("STATSD_PREFIX", ""),
+			StatsDTags:     l.list("STATSD_TAGS", ","),
+			StatsDPlain:    l.oneOf("STATSD_FLAVOR", "dogstatsd", "dogstatsd", "plain") == "plain",
+			FlushInterval:  l.duration("STATS_FLUSH_INTERVAL", time.Minute, true),
+			RollupInterval: l.duration("STATS_ROLLUP_INTERVAL", 0, true),
+		},
+		Jobs: Jobs{
+			AnonymizeAfterDays:   l.int("ANONYMIZE_AFTER_DAYS", 0, 0),
+			AnonymizeDryRun:      l.bool("ANONYMIZE_DRY_RUN", false),
+			Anonymize:            l.schedule("ANONYMIZE_SCHEDULE", "ANONYMIZE_INTERVAL", time.Hour),
+			Rescore:              l.schedule("RESCORE_SCHEDULE", "RESCORE_INTERVAL", 0),
+			RulesInterval:        l.duration("RULES_INTERVAL", time.Minute, true),
+			SubscriptionInterval: l.duration("SUBSCRIPTION_INTERVAL", 5*time.Minute, true),
+			StaleAfterDays:       l.int("STALE_NUDGE_AFTER_DAYS", 0, 0),
+			StaleInterval:        l.duration("STALE_NUDGE_INTERVAL", time.Hour, true),
+		},
+		Webhooks: Webhooks{
+			Interval:    l.duration("WEBHOOK_INTERVAL", 5*time.Second, true),
+			Concurrency: l.int("WEBHOOK_CONCURRENCY", 2, 1),
+			PauseAfter:  l.int("WEBHOOK_PAUSE_AFTER", 20, 0),
+		},
+		Hooks: Hooks{
+			URLs:     l.list("HOOK_URLS", ","),
+			Events:   l.list("HOOK_EVENTS", ","),
+			Secret:   l.str("HOOK_SECRET", ""),
+			FailOpen: l.bool("HOOK_FAIL_OPEN", false),
+		},
+		Reminders: Reminders{
+			Interval:      l.duration("REMINDER_INTERVAL", time.Minute, true),
+			WebhookURL:    l.url("REMINDER_WEBHOOK_URL"),
+			WebhookSecret: l.str("REMINDER_WEBHOOK_SECRET", ""),
+		},
+		AccessLog: AccessLog{
+			Path:       l.str("ACCESS_LOG", ""),
+			Format:     l.str("ACCESS_LOG_FORMAT", "combined"),
+			MaxSizeMB:  l.int("ACCESS_LOG_MAX_SIZE_MB", 100, 0),
+			MaxAge:     l.duration("ACCESS_LOG_MAX_AGE", 0, true),
+			MaxBackups: l.int("ACCESS_LOG_MAX_BACKUPS", 7, 0),
+		},
+		Alerts: Alerts{
+			WebhookURL:    l.url("ALERT_WEBHOOK_URL"),
+			SMTPAddr:      l.addr("ALERT_SMTP_ADDR", ""),
+			SMTPUser:      l.str("ALERT_SMTP_USER", ""),
+			SMTPPassword:  l.str("ALERT_SMTP_PASSWORD", ""),
+			EmailFrom:     l.str("ALERT_EMAIL_FROM", ""),
+			EmailTo:       l.list("ALERT_EMAIL_TO", ","),
+			ErrorRate:     l.float("ALERT_ERROR_RATE", 0.05, 0, 1),
+			MLFailureRate: l.float("ALERT_ML_FAILURE_RATE", 0.5, 0, 1),
+			Window:        l.duration("ALERT_WINDOW", 5*time.Minute, false),
+		},
+		SLO: SLO{
+			Objectives: l.str("SLO_OBJECTIVES", ""),
+			Period:     l.duration("SLO_PERIOD", 720*time.Hour, false),
+		},
+		Branding: Branding{
+			AppName:         l.str("BRAND_APP_NAME", ""),
+			LogoURL:         l.str("BRAND_LOGO_URL", ""),
+			PrimaryColor:    l.str("BRAND_PRIMARY_COLOR", ""),
+			BackgroundColor: l.str("BRAND_BACKGROUND_COLOR", ""),
+			TextColor:       l.str("BRAND_TEXT_COLOR", ""),
+		},
+		Reports: Reports{
+			URL:           l.str("REPORT_EXPORT_URL", ""),
+			Credentials:   l.credentials("REPORT_ACCESS_KEY_ID", "REPORT_SECRET_ACCESS_KEY"),
+			Format:        l.oneOf("REPORT_FORMAT", "csv", "csv", "json"),
+			Schedule:      l.weekly("REPORT_SCHEDULE", "mon 06:00"),
+			WebhookURL:    l.url("REPORT_WEBHOOK_URL"),
+			WebhookSecret: l.str("REPORT_WEBHOOK_SECRET", ""),
+		},
+		EventLog: EventLog{
+			URL:         l.str("EVENT_LOG_URL", ""),
+			Credentials: l.credentials("EVENT_LOG_ACCESS_KEY_ID", "EVENT_LOG_SECRET_ACCESS_KEY"),
+			SpoolDir:    l.str("EVENT_LOG_SPOOL_DIR", filepath.Join(os.TempDir(), "todo-events")),
+			Buffer:      l.int("EVENT_LOG_BUFFER", 4096, 1),
+		},
+		Audit: AuditForward{
+			URL:      l.str("AUDIT_FORWARD_URL", ""),
+			Token:    l.str("AUDIT_FORWARD_TOKEN", ""),
+			Interval: l.duration("AUDIT_FORWARD_INTERVAL", 5*time.Second, false),
+			Batch:    l.int("AUDIT_FORWARD_BATCH", 100, 1),
+		},
+		Usage: Usage{
+			Enabled:  l.bool("USAGE_REPORTING", false),
+			URL:      l.url("USAGE_REPORT_URL"),
+			Interval: l.duration("USAGE_REPORT_INTERVAL", 24*time.Hour, false),
+		},
+		Warmup: Warmup{
+			DBConns: l.int("WARMUP_DB_CONNS", 0, 0),
+			ML:      l.bool("WARMUP_ML", false),
+			Timeout: l.duration("WARMUP_TIMEOUT", 10*time.Second, false),
+		},
+		Inbound: Inbound{
+			GitHubSecret:  l.str("GITHUB_WEBHOOK_SECRET", ""),
+			StripeSecret:  l.str("STRIPE_WEBHOOK_SECRET", ""),
+			GenericSecret: l.str("INBOUND_WEBHOOK_SECRET", ""),
+		},
+	}
+
+	mode := l.str("LISTEN_SOCKET_MODE", "0660")
+	if m, err := strconv.ParseUint(mode, 8, 32); err != nil || m > 0o777 {
+		l.fail("LISTEN_SOCKET_MODE", "must be octal permissions such as 0660, got %q", mode)
+	} else {
+		f.SocketMode = os.FileMode(m)
+	}
+	for _, p := range f.RedactPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			l.fail("LOG_REDACT_PATTERNS", "invalid pattern %q: %v", p, err)
+		}
+	}
+
+	master := l.str("ENCRYPTION_KEY", "")
+	previous := l.list("ENCRYPTION_PREVIOUS_KEYS", ",")
+	if master == "" && len(previous) > 0 {
+		l.fail("ENCRYPTION_PREVIOUS_KEYS", "requires ENCRYPTION_KEY")
+	} else if master != "" {
+		for i, v := range append([]string{master}, previous...) {
+			key, err := base64.StdEncoding.DecodeString(v)
+			switch {
+			case err != nil || len(key) != 32 && i == 0:
+				l.fail("ENCRYPTION_KEY", "must be a base64 32-byte key such as `openssl rand -base64 32` prints")
+			case err != nil || len(key) != 32:
+				l.fail("ENCRYPTION_PREVIOUS_KEYS", "key %d must be a base64 32-byte key", i)
+			}
+			f.Storage.EncryptionKeys = append(f.Storage.EncryptionKeys, key)
+		}
+	}
+	if f.Storage.CredentialsCommand != "" && f.Storage.CredentialsFile != "" {
+		l.fail("DB_CREDENTIALS_FILE", "must not be set together with DB_CREDENTIALS_COMMAND")
+	}
+
+	if f.Limits.ShedMin > f.Limits.ShedMax || f.Limits.ShedInitial < f.Limits.ShedMin || f.Limits.ShedInitial > f.Limits.ShedMax {
+		l.fail("LOAD_SHED_INITIAL_LIMIT", "must be between LOAD_SHED_MIN_LIMIT (%d) and LOAD_SHED_MAX_LIMIT (%d), got %d",
+			f.Limits.ShedMin, f.Limits.ShedMax, f.Limits.ShedInitial)
+	}
+
+	if f.Auth.Secret != "" && len(f.Auth.Secret) < 32 {
+		l.fail("AUTH_SECRET", "must be at least 32 bytes, got %d", len(f.Auth.Secret))
+	}
+	if f.Auth.SCIMToken != "" && f.Auth.Secret == "" {
+		l.fail("SCIM_TOKEN", "requires AUTH_SECRET")
+	}
+	if f.Auth.SigningWindow > 0 && f.Auth.Secret == "" {
+		l.fail("REQUEST_SIGNING_WINDOW", "requires AUTH_SECRET")
+	}
+
+	if f.Static.Dir != "" {
+		if info, err := os.Stat(f.Static.Dir); err != nil || !info.IsDir() {
+			l.fail("STATIC_DIR", "must be a directory, got %q", f.Static.Dir)
+		}
+	} else if f.Static.LiveReload {
+		l.fail("STATIC_LIVE_RELOAD", "requires STATIC_DIR")
+	}
+
+	for _, u := range f.Hooks.URLs {
+		l.checkURL("HOOK_URLS", u)
+	}
+
+	for _, v := range l.list("REMINDER_DEFAULT_OFFSETS", ",") {
+		m, err := strconv.Atoi(v)
+		if err != nil || m < 0 {
+			l.fail("REMINDER_DEFAULT_OFFSETS", "must be whole minutes, got %q", v)
+			continue
+		}
+		f.Reminders.DefaultOffsets = append(f.Reminders.DefaultOffsets, m)
+	}
+
+	if f.Alerts.SMTPAddr != "" && (f.Alerts.EmailFrom == "" || len(f.Alerts.EmailTo) == 0) {
+		l.fail("ALERT_SMTP_ADDR", "requires ALERT_EMAIL_FROM and ALERT_EMAIL_TO")
+	}
+
+	if f.Usage.Enabled && f.Usage.URL == "" {
+		l.fail("USAGE_REPORTING", "requires USAGE_REPORT_URL")
+	}
+	if f.Usage.Interval > 0 && f.Usage.Interval < time.Hour {
+		l.fail("USAGE_REPORT_INTERVAL", "must be at least 1h, got %s", f.Usage.Interval)
+	}
+	return f
+}
+
+// schedule reads the cron expression in scheduleKey or, without one, the
+// interval in intervalKey as an "@every" expression. A zero interval gives
+// no expression.
+func (l *loader) schedule(scheduleKey, intervalKey string, def time.Duration) string {
+	interval := l.duration(intervalKey, def, true)
+	if expr := l.str(scheduleKey, ""); expr != "" {
+		return expr
+	}
+	switch {
+	case interval <= 0:
+		return ""
+	case interval < time.Second:
+		l.fail(intervalKey, "must be at least 1s, got %s", interval)
+	}
+	return "@every " + interval.String()
+}
+
+// weekly reads key as a cron expression or, as before cron schedules, a
+// weekday and UTC time such as "mon 06:00", which it turns into one.
+func (l *loader) weekly(key, def string) string {
+	v := l.str(key, def)
+	if day, clock, ok := strings.Cut(strings.ToLower(v), " "); ok && !strings.Contains(clock, " ") {
+		at, err := time.Parse("15:04", clock)
+		if err != nil {
+			l.fail(key, "want a cron expression or a weekday and HH:MM such as \"mon 06:00\", got %q", v)
+			return ""
+		}
+		return fmt.Sprintf("%d %d * * %s", at.Minute(), at.Hour(), day)
+	}
+	return v
+}
+
+// Credentials are an object store's keys, the same fields as
+// blob.Credentials.
+type Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// credentials reads an object store's keys from accessKey and secretKey,
+// falling back to the standard AWS_* variables.
+func (l *loader) credentials(accessKey, secretKey string) Credentials {
+	return Credentials{
+		AccessKey:    l.str(accessKey, l.str("AWS_ACCESS_KEY_ID", "")),
+		SecretKey:    l.str(secretKey, l.str("AWS_SECRET_ACCESS_KEY", "")),
+		SessionToken: l.str("AWS_SESSION_TOKEN", ""),
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"cmp"
+	"slices"
+)
+
+// A Key is a variable Load reads.
+type Key struct {
+	Name string
+	// Core keys configure the server itself; the rest belong to a feature.
+	Core bool
+	// Secret keys hold passwords, tokens and key material.
+	Secret bool
+	// Local keys differ between environments: addresses, URLs of other
+	// services, paths and account names.
+	Local bool
+}
+
+// Tunable reports whether k shapes behaviour (limits, schedules, feature
+// switches) the same way in every environment, so a settings file may
+// carry it from one instance to another.
+func (k Key) Tunable() bool { return !k.Secret && !k.Local }
+
+// Keys are the variables Load reads, sorted by name.
+var Keys = sortKeys(slices.Concat(
+	named(Key{Core: true, Local: true},
+		"PORT",
+		"DATABASE_URL",
+		"ML_SERVICE_URL",
+		"TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_AUTOCERT_DIR", "TLS_AUTOCERT_EMAIL",
+		"TRUSTED_PROXIES",
+		"WEBHOOK_ALLOWED_NETWORKS",
+	),
+	named(Key{Core: true},
+		"ML_TIMEOUT",
+		"LOG_LEVEL",
+		"HTTP_READ_TIMEOUT", "HTTP_READ_HEADER_TIMEOUT", "HTTP_WRITE_TIMEOUT", "HTTP_IDLE_TIMEOUT",
+		"SHUTDOWN_TIMEOUT",
+		"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONNECT_TIMEOUT",
+	),
+	named(Key{Secret: true},
+		"ADMIN_TOKEN",
+		"ALERT_SMTP_PASSWORD",
+		"AUDIT_FORWARD_TOKEN",
+		"AUTH_SECRET",
+		"AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN",
+		"CDN_PURGE_TOKEN",
+		"ENCRYPTION_KEY", "ENCRYPTION_PREVIOUS_KEYS",
+		"EVENT_LOG_SECRET_ACCESS_KEY",
+		"GITHUB_WEBHOOK_SECRET",
+		"HOOK_SECRET",
+		"ID_OBFUSCATION_SECRET",
+		"INBOUND_WEBHOOK_SECRET",
+		"JOURNAL_REPLAY_TOKEN",
+		"REMINDER_WEBHOOK_SECRET",
+		"REPORT_SECRET_ACCESS_KEY", "REPORT_WEBHOOK_SECRET",
+		"SCIM_TOKEN",
+		"STRIPE_WEBHOOK_SECRET",
+	),
+	named(Key{Local: true},
+		"ACCESS_LOG",
+		"ADMIN_ADDR",
+		"ALERT_EMAIL_FROM", "ALERT_EMAIL_TO", "ALERT_SMTP_ADDR", "ALERT_SMTP_USER", "ALERT_WEBHOOK_URL",
+		"AUDIT_FORWARD_URL",
+		"AWS_ACCESS_KEY_ID",
+		"CDN_PURGE_URL",
+		"COCKROACH",
+		"DB_CREDENTIALS_COMMAND", "DB_CREDENTIALS_FILE", "DB_CREDENTIALS_TTL",
+		"EVENT_LOG_ACCESS_KEY_ID", "EVENT_LOG_SPOOL_DIR", "EVENT_LOG_URL",
+		"GRPC_ADDR",
+		"HOOK_URLS",
+		"JOURNAL_REPLAY_URL",
+		"LISTEN", "LISTEN_SOCKET_GROUP", "LISTEN_SOCKET_MODE",
+		"OPENAPI_VALIDATION",
+		"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_SERVICE_NAME",
+		"REMINDER_WEBHOOK_URL",
+		"REPORT_ACCESS_KEY_ID", "REPORT_EXPORT_URL", "REPORT_WEBHOOK_URL",
+		"SERVE_STATIC",
+		"STATIC_DIR", "STATIC_LIVE_RELOAD",
+		"STATSD_ADDR", "STATSD_TAGS",
+		"USAGE_REPORT_URL",
+	),
+	named(Key{},
+		"ACCESS_LOG_FORMAT", "ACCESS_LOG_MAX_AGE", "ACCESS_LOG_MAX_BACKUPS", "ACCESS_LOG_MAX_SIZE_MB",
+		"ALERT_ERROR_RATE", "ALERT_ML_FAILURE_RATE", "ALERT_WINDOW",
+		"ANONYMIZE_AFTER_DAYS", "ANONYMIZE_DRY_RUN", "ANONYMIZE_INTERVAL", "ANONYMIZE_SCHEDULE",
+		"AUDIT_FORWARD_BATCH", "AUDIT_FORWARD_INTERVAL", "AUDIT_HASH_CHAIN",
+		"AUTH_SESSION_TTL",
+		"BRAND_APP_NAME", "BRAND_BACKGROUND_COLOR", "BRAND_LOGO_URL", "BRAND_PRIMARY_COLOR",
+		"BRAND_TEXT_COLOR",
+		"CDN_MAX_AGE", "CDN_PROVIDER",
+		"COMPLETE_UNDO_WINDOW",
+		"EFFORT_TRACKING",
+		"EVENT_LOG_BUFFER",
+		"HOOK_EVENTS", "HOOK_FAIL_OPEN",
+		"HYPERMEDIA_LINKS",
+		"JOURNAL_SIZE",
+		"LIST_CACHE_ENTRIES",
+		"LOAD_SHEDDING", "LOAD_SHED_INITIAL_LIMIT", "LOAD_SHED_MAX_LIMIT", "LOAD_SHED_MIN_LIMIT",
+		"LOG_REDACT_KEYS", "LOG_REDACT_PATTERNS",
+		"MAX_OPEN_TODOS", "MAX_TOTAL_TODOS",
+		"METRICS",
+		"MIGRATE_ON_START",
+		"ML_BREAKER_COOLDOWN", "ML_BREAKER_FAILURES", "ML_CACHE_SIZE", "ML_CACHE_TTL",
+		"ML_CALIBRATION_INTERVAL", "ML_DEFER_BELOW", "ML_DEFER_QUEUE", "ML_HEDGE_BUDGET", "ML_RETRIES",
+		"ML_RETRY_BACKOFF", "ML_USER_FEATURES_TTL",
+		"POW_DIFFICULTY",
+		"PRIORITY_HALF_LIFE",
+		"RATE_LIMIT_IP_BURST", "RATE_LIMIT_IP_RPS", "RATE_LIMIT_USER_BURST", "RATE_LIMIT_USER_RPS",
+		"READY_ML", "READY_TIMEOUT",
+		"REMINDER_DEFAULT_OFFSETS", "REMINDER_INTERVAL",
+		"REPORT_FORMAT", "REPORT_SCHEDULE",
+		"REQUEST_SIGNING_WINDOW",
+		"RESCORE_INTERVAL", "RESCORE_SCHEDULE",
+		"RULES_INTERVAL",
+		"SCHEMA_DRIFT",
+		"SEARCH_RECENCY_HALF_LIFE", "SEARCH_WEIGHT_PRIORITY", "SEARCH_WEIGHT_RECENCY",
+		"SEARCH_WEIGHT_TEXT",
+		"SLO_OBJECTIVES", "SLO_PERIOD",
+		"STALE_NUDGE_AFTER_DAYS", "STALE_NUDGE_INTERVAL",
+		"STATSD_FLAVOR", "STATSD_INTERVAL", "STATSD_PREFIX",
+		"STATS_FLUSH_INTERVAL", "STATS_ROLLUP_INTERVAL",
+		"STATUS_PAGE",
+		"SUBSCRIPTION_INTERVAL",
+		"TODO_CACHE_TTL",
+		"USAGE_REPORTING", "USAGE_REPORT_INTERVAL",
+		"WARMUP_DB_CONNS", "WARMUP_ML", "WARMUP_TIMEOUT",
+		"WEBHOOK_CONCURRENCY", "WEBHOOK_INTERVAL", "WEBHOOK_PAUSE_AFTER",
+	),
+))
+
+var byName = func() map[string]Key {
+	m := make(map[string]Key, len(Keys))
+	for _, k := range Keys {
+		m[k.Name] = k
+	}
+	return m
+}()
+
+// Lookup returns the key named name, if Load reads it.
+func Lookup(name string) (Key, bool) {
+	k, ok := byName[name]
+	return k, ok
+}
+
+// Names returns the names of the Keys for which keep returns true, sorted.
+func Names(keep func(Key) bool) []string {
+	var out []string
+	for _, k := range Keys {
+		if keep(k) {
+			out = append(out, k.Name)
+		}
+	}
+	return out
+}
+
+// named returns a key of kind k for each name.
+func named(k Key, names ...string) []Key {
+	out := make([]Key, len(names))
+	for i, name := range names {
+		out[i] = k
+		out[i].Name = name
+	}
+	return out
+}
+
+func sortKeys(keys []Key) []Key {
+	slices.SortFunc(keys, func(a, b Key) int { return cmp.Compare(a.Name, b.Name) })
+	return keys
+}
@@ -0,0 +1,217 @@
+// Package cron parses cron expressions and computes when they next fire.
+//
+// An expression has the five standard fields, evaluated in UTC:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field is "*", a value, a range ("1-5"), a step ("*/15", "0-30/10")
+// or a comma-separated list of those. Months and weekdays may be named
+// ("jan", "mon"); Sunday is 0 or 7. As in Vixie cron, when both
+// day-of-month and day-of-week are restricted a day matching either fires.
+// The macros @yearly (@annually), @monthly, @weekly, @daily (@midnight) and
+// @hourly stand for their usual expressions, and "@every 90m" fires at a
+// fixed interval from the previous run.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchYears bounds the search for the next match, for expressions that
+// never fire, such as "0 0 30 2 *".
+const searchYears = 5
+
+// Schedule is a parsed expression.
+type Schedule struct {
+	expr  string
+	every time.Duration
+
+	minute, hour, dom, month, dow uint64 // bit i set when value i matches
+	domStar, dowStar              bool
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Parse parses expr.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	s := Schedule{expr: expr}
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Second {
+			return Schedule{}, fmt.Errorf("cron %q: @every needs a duration of at least 1s", expr)
+		}
+		s.every = d
+		return s, nil
+	}
+	fields := strings.Fields(strings.ToLower(expr))
+	if len(fields) == 1 {
+		m, ok := macros[fields[0]]
+		if !ok {
+			return Schedule{}, fmt.Errorf("cron %q: unknown macro", expr)
+		}
+		fields = strings.Fields(m)
+	}
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", expr, len(fields))
+	}
+	var err error
+	parsers := []struct {
+		dst      *uint64
+		min, max int
+		names    []string
+		nameBase int
+	}{
+		{&s.minute, 0, 59, nil, 0},
+		{&s.hour, 0, 23, nil, 0},
+		{&s.dom, 1, 31, nil, 0},
+		{&s.month, 1, 12, monthNames, 1},
+		{&s.dow, 0, 7, dayNames, 0},
+	}
+	for i, p := range parsers {
+		if *p.dst, err = parseField(fields[i], p.min, p.max, p.names, p.nameBase); err != nil {
+			return Schedule{}, fmt.Errorf("cron %q: field %d: %w", expr, i+1, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is Sunday too
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// parseField returns the bit set of the values field matches.
+func parseField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(loStr, min, max, names, nameBase); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseValue(hiStr, min, max, names, nameBase); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max // "5/15" is "5-max/15"
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("range %q runs backwards", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, min, max int, names []string, nameBase int) (int, error) {
+	for i, name := range names {
+		if s == name {
+			return i + nameBase, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("%d is outside %d-%d", v, min, max)
+	}
+	return v, nil
+}
+
+// MustParse is Parse for expressions known to be valid.
+func MustParse(expr string) Schedule {
+	s, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the expression as given.
+func (s Schedule) String() string {
+	return s.expr
+}
+
+// IsZero reports whether s is the zero Schedule, which never fires.
+func (s Schedule) IsZero() bool {
+	return s.expr == ""
+}
+
+// ErrNever is returned by Next for expressions that never fire.
+var ErrNever = errors.New("cron: expression never fires")
+
+// Next returns the first time strictly after t that s fires, to the
+// minute (or, for @every, t plus the interval).
+func (s Schedule) Next(t time.Time) (time.Time, error) {
+	if s.IsZero() {
+		return time.Time{}, ErrNever
+	}
+	if s.every > 0 {
+		return t.Add(s.every), nil
+	}
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(searchYears, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, nil
+	}
+	return time.Time{}, ErrNever
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SchemaReport summarizes the database layout for the admin schema endpoint.
+type SchemaReport struct {
+	Migrations []AppliedMigration `json:"migrations"`
+	Tables     []TableStats       `json:"tables"`
+	Indexes    []IndexStats       `json:"indexes"`
+}
+
+// AppliedMigration is a row of the schema_migrations table.
+type AppliedMigration struct {
+	Version   int64     `json:"version"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// TableStats holds row counts, sizes and a bloat estimate for a table.
+// Row counts come from the planner statistics, so they are estimates.
+type TableStats struct {
+	Name            string     `json:"name"`
+	LiveRows        int64      `json:"liveRows"`
+	DeadRows        int64      `json:"deadRows"`
+	TotalBytes      int64      `json:"totalBytes"`
+	BloatRatio      float64    `json:"bloatRatio"`
+	LastVacuum      *time.Time `json:"lastVacuum,omitempty"`
+	LastAutovacuum  *time.Time `json:"lastAutovacuum,omitempty"`
+	LastAnalyze     *time.Time `json:"lastAnalyze,omitempty"`
+	LastAutoanalyze *time.Time `json:"lastAutoanalyze,omitempty"`
+}
+
+// IndexStats holds size and usage information for an index.
+type IndexStats struct {
+	Table string `json:"table"`
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	Scans int64  `json:"scans"`
+}
+
+// SchemaReport collects migration versions, table and index statistics.
+func (s *SQLStore) SchemaReport(ctx context.Context) (SchemaReport, error) {
+	tableStats, indexStats := s.tableStats, s.indexStats
+	switch s.dialect.name {
+	case mysqlDialect.name:
+		tableStats, indexStats = s.mysqlTableStats, s.mysqlIndexStats
+	case cockroachDialect.name:
+		// CockroachDB has no pg_stat_* views or relation sizes.
+		tableStats, indexStats = s.cockroachTableStats, noIndexStats
+	case sqliteDialect.name:
+		tableStats, indexStats = s.sqliteTableStats, noIndexStats
+	}
+
+	var report SchemaReport
+	var err error
+	if report.Migrations, err = s.appliedMigrations(ctx); err != nil {
+		return SchemaReport{}, err
+	}
+	if report.Tables, err = tableStats(ctx); err != nil {
+		return SchemaReport{}, err
+	}
+	if report.Indexes, err = indexStats(ctx); err != nil {
+		return SchemaReport{}, err
+	}
+	return report, nil
+}
+
+func (s *SQLStore) appliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	rows, err := s.SQL.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations ORDER BY version ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+	defer rows.Close()
+
+	out := []AppliedMigration{}
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) tableStats(ctx context.Context) ([]TableStats, error) {
+	rows, err := s.SQL.QueryContext(ctx,
+		`SELECT relname,
+		        n_live_tup,
+		        n_dead_tup,
+		        pg_total_relation_size(relid),
+		        last_vacuum,
+		        last_autovacuum,
+		        last_analyze,
+		        last_autoanalyze
+		 FROM pg_stat_user_tables
+		 ORDER BY relname ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("table stats: %w", err)
+	}
+	defer rows.Close()
+
+	out := []TableStats{}
+	for rows.Next() {
+		var t TableStats
+		if err := rows.Scan(
+			&t.Name,
+			&t.LiveRows,
+			&t.DeadRows,
+			&t.TotalBytes,
+			&t.LastVacuum,
+			&t.LastAutovacuum,
+			&t.LastAnalyze,
+			&t.LastAutoanalyze,
+		); err != nil {
+			return nil, err
+		}
+		// Dead tuples that autovacuum has not reclaimed yet are a cheap
+		// approximation of table bloat without the pgstattuple extension.
+		if total := t.LiveRows + t.DeadRows; total > 0 {
+			t.BloatRatio = float64(t.DeadRows) / float64(total)
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) indexStats(ctx context.Context) ([]IndexStats, error) {
+	rows, err := s.SQL.QueryContext(ctx,
+		`SELECT relname, indexrelname, pg_relation_size(indexrelid), idx_scan
+		 FROM pg_stat_user_indexes
+		 ORDER BY relname ASC, indexrelname ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("index stats: %w", err)
+	}
+	defer rows.Close()
+
+	out := []IndexStats{}
+	for rows.Next() {
+		var i IndexStats
+		if err := rows.Scan(&i.Table, &i.Name, &i.Bytes, &i.Scans); err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) cockroachTableStats(ctx context.Context) ([]TableStats, error) {
+	rows, err := s.SQL.QueryContext(ctx,
+		`SELECT table_name, estimated_row_count
+		 FROM crdb_internal.table_row_statistics
+		 ORDER BY table_name ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("table stats: %w", err)
+	}
+	defer rows.Close()
+
+	out := []TableStats{}
+	for rows.Next() {
+		var t TableStats
+		if err := rows.Scan(&t.Name, &t.LiveRows); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func noIndexStats(context.Context) ([]IndexStats, error) {
+	return []IndexStats{}, nil
+}
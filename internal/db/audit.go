@@ -0,0 +1,369 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var todoEventsBucket = []byte("todo_events")
+
+// Actions recorded in a todo's history.
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+	// ActionMoved is an update that only changed the todo's list.
+	ActionMoved = "moved"
+	// ActionRedacted follows anonymization in a chained history: it
+	// commits to the todo's rewritten snapshots and carries none itself.
+	ActionRedacted = "redacted"
+	// ActionPurged is chained when an account is deleted with its
+	// history. It names no todo (TodoID is zero) and commits to the
+	// entries it removed.
+	ActionPurged = "purged"
+)
+
+// TodoChange is one entry of a todo's history: the todo before and after a
+// create, update, move or delete, who made it and in which request. Old is
+// nil for creates and New for deletes.
+type TodoChange struct {
+	ID        int64     `json:"id"`
+	TodoID    int64     `json:"todoId"`
+	Action    string    `json:"action"`
+	Old       *Todo     `json:"old"`
+	New       *Todo     `json:"new"`
+	ActorID   int64     `json:"actorId,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// HistoryStore is implemented by backends that record every todo mutation
+// in the same transaction as the mutation itself.
+type HistoryStore interface {
+	// TodoHistory returns the changes to todo id, oldest first. History
+	// outlives the todo, so a deleted todo still has one.
+	TodoHistory(ctx context.Context, id int64) ([]TodoChange, error)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID tags the todo changes made with the returned context with
+// the id of the request that made them.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// todoChange is a mutation waiting to be recorded. The entries only the
+// audit chain records, redactions and purges, have no snapshots: they
+// name their todo and owner in marker, and set the content hash.
+type todoChange struct {
+	action   string
+	old, new *Todo
+	marker   Todo
+	content  string
+}
+
+func (c todoChange) todo() *Todo {
+	switch {
+	case c.new != nil:
+		return c.new
+	case c.old != nil:
+		return c.old
+	}
+	return &c.marker
+}
+
+// contentHash is what the chain commits to of c's stored snapshots.
+func (c todoChange) contentHash(old, new *string) string {
+	if c.content != "" {
+		return c.content
+	}
+	return snapshotDigest(old, new)
+}
+
+// historyRows caps the rows of one multi-row INSERT into todo_events.
+const historyRows = 100
+
+// recordChanges writes changes to todo_events within tx, chaining them
+// when the audit chain is enabled.
+func (s *SQLStore) recordChanges(ctx context.Context, tx *sql.Tx, changes []todoChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	actor, request, now := ownerValue(ctx), requestIDFrom(ctx), s.now()
+	actorID, _ := OwnerFrom(ctx)
+	var head chainHead
+	if s.auditChain {
+		var err error
+		if head, err = s.sqlChainHead(ctx, tx); err != nil {
+			return fmt.Errorf("record todo history: %w", err)
+		}
+	}
+	for len(changes) > 0 {
+		batch := changes[:min(historyRows, len(changes))]
+		changes = changes[len(batch):]
+		values := make([]string, 0, len(batch))
+		args := make([]any, 0, len(batch)*12)
+		for _, c := range batch {
+			oldValue, err := s.snapshot(ctx, c.old)
+			if err != nil {
+				return err
+			}
+			newValue, err := s.snapshot(ctx, c.new)
+			if err != nil {
+				return err
+			}
+			var owner any
+			t := c.todo()
+			if t.OwnerID != 0 {
+				owner = t.OwnerID
+			}
+			var seq, contentHash, prevHash, hash any
+			if s.auditChain {
+				link := chainLink{Seq: head.Seq + 1, ContentHash: c.contentHash(oldValue, newValue), PrevHash: head.Hash}
+				change := TodoChange{TodoID: t.ID, Action: c.action, ActorID: actorID, RequestID: request, CreatedAt: now}
+				link.Hash = linkHash(link.Seq, link.PrevHash, change, t.OwnerID, link.ContentHash)
+				seq, contentHash, prevHash, hash = link.Seq, link.ContentHash, link.PrevHash, link.Hash
+				head = chainHead{Seq: link.Seq, Hash: link.Hash}
+			}
+			values = append(values, "("+placeholders(len(args)+1, 12)+")")
+			args = append(args, t.ID, c.action, oldValue, newValue, owner, actor, request, now, seq, contentHash, prevHash, hash)
+		}
+		_, err := tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO todo_events (todo_id, action, old_value, new_value, owner_id, actor_id, request_id, created_at, chain_seq, content_hash, prev_hash, hash)
+			 VALUES `+strings.Join(values, ", ")), args...)
+		if err != nil {
+			return fmt.Errorf("record todo history: %w", err)
+		}
+	}
+	if s.auditChain {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE audit_chain SET seq = $1, head = $2 WHERE id = 1`), head.Seq, head.Hash); err != nil {
+			return fmt.Errorf("record todo history: %w", err)
+		}
+	}
+	return nil
+}
+
+// snapshot encodes t for todo_events, sealing the title as in todos.
+func (s *SQLStore) snapshot(ctx context.Context, t *Todo) (*string, error) {
+	if t == nil {
+		return nil, nil
+	}
+	sealed := *t
+	var err error
+	if sealed.Title, err = s.sealTitle(ctx, t.ID, t.OwnerID, t.Title); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("encode todo: %w", err)
+	}
+	v := string(data)
+	return &v, nil
+}
+
+// TodoHistory returns the recorded changes to todo id.
+func (s *SQLStore) TodoHistory(ctx context.Context, id int64) ([]TodoChange, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT id, todo_id, action, old_value, new_value, actor_id, request_id, created_at
+		 FROM todo_events WHERE todo_id = $1`+owned+` ORDER BY id`),
+		append([]any{id}, ownerArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []TodoChange{}
+	for rows.Next() {
+		var c TodoChange
+		var oldValue, newValue sql.NullString
+		var actor sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.TodoID, &c.Action, &oldValue, &newValue, &actor, &c.RequestID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		c.ActorID = actor.Int64
+		if c.Old, err = s.openSnapshot(ctx, oldValue); err != nil {
+			return nil, err
+		}
+		if c.New, err = s.openSnapshot(ctx, newValue); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) openSnapshot(ctx context.Context, v sql.NullString) (*Todo, error) {
+	if !v.Valid {
+		return nil, nil
+	}
+	var t Todo
+	if err := json.Unmarshal([]byte(v.String), &t); err != nil {
+		return nil, fmt.Errorf("decode todo history: %w", err)
+	}
+	var err error
+	if t.Title, err = s.openTitle(ctx, t.ID, t.OwnerID, t.Title); err != nil {
+		return nil, err
+	}
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	return &t, nil
+}
+
+// historyIDs caps the ids bound in one statement by rewriteTodos.
+const historyIDs = 500
+
+// rewriteTodos runs a set-based write on the todos matching cond within tx
+// and records a change for each. The rows are read and locked first and
+// stmt is then run on exactly their ids, so a row matching cond only after
+// the read is neither changed nor missing from the history. stmt is an
+// UPDATE or DELETE without a WHERE clause; its placeholders are bound to
+// stmtArgs. It returns the number of rows changed.
+func (s *SQLStore) rewriteTodos(ctx context.Context, tx *sql.Tx, cond string, condArgs []any, stmt string, stmtArgs []any) (int64, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE ` + cond
+	if s.dialect.name != sqliteDialect.name {
+		query += ` FOR UPDATE`
+	}
+	before, err := s.queryTodos(ctx, tx, query, condArgs...)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	changes := make([]todoChange, 0, len(before))
+	for len(before) > 0 {
+		batch := before[:min(historyIDs, len(before))]
+		before = before[len(batch):]
+		ids := make([]any, len(batch))
+		for i, t := range batch {
+			ids[i] = t.ID
+		}
+		res, err := tx.ExecContext(ctx, s.dialect.rebind(stmt+` WHERE id IN (`+placeholders(len(stmtArgs)+1, len(ids))+`)`), append(append([]any{}, stmtArgs...), ids...)...)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		n += affected
+		after, err := s.queryTodos(ctx, tx, `SELECT `+todoColumns+` FROM todos WHERE id IN (`+placeholders(1, len(ids))+`)`, ids...)
+		if err != nil {
+			return 0, err
+		}
+		byID := make(map[int64]*Todo, len(after))
+		for i := range after {
+			byID[after[i].ID] = &after[i]
+		}
+		for i := range batch {
+			if t, ok := byID[batch[i].ID]; ok {
+				changes = append(changes, todoChange{action: ActionUpdated, old: &batch[i], new: t})
+			} else {
+				changes = append(changes, todoChange{action: ActionDeleted, old: &batch[i]})
+			}
+		}
+	}
+	return n, s.recordChanges(ctx, tx, changes)
+}
+
+// queryTodos reads the todos a query over todoColumns returns within tx.
+func (s *SQLStore) queryTodos(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]Todo, error) {
+	rows, err := tx.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Todo
+	for rows.Next() {
+		t, err := s.scanTodo(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// recordBoltChange appends a change to the todo_events bucket within tx.
+// Keys are the todo id followed by a sequence number, so a todo's history
+// is one ordered range.
+func (s *BoltStore) recordBoltChange(ctx context.Context, tx *bolt.Tx, action string, old, new *Todo) error {
+	return s.putBoltChange(ctx, tx, todoChange{action: action, old: old, new: new})
+}
+
+// putBoltChange records c within tx.
+func (s *BoltStore) putBoltChange(ctx context.Context, tx *bolt.Tx, c todoChange) error {
+	b := tx.Bucket(todoEventsBucket)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	e := boltTodoChange{
+		TodoChange: TodoChange{
+			ID:        int64(seq),
+			TodoID:    c.todo().ID,
+			Action:    c.action,
+			Old:       c.old,
+			New:       c.new,
+			RequestID: requestIDFrom(ctx),
+			CreatedAt: s.now(),
+		},
+		OwnerID: c.todo().OwnerID,
+	}
+	e.ActorID, _ = OwnerFrom(ctx)
+	if s.auditChain {
+		if err := s.chainBoltChange(tx, &e, c); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode todo history: %w", err)
+	}
+	return b.Put(append(boltKey(e.TodoID), boltKey(e.ID)...), data)
+}
+
+// boltTodoChange is a TodoChange as stored, with the owner it is scoped to
+// and, when chained, its place in the audit chain.
+type boltTodoChange struct {
+	TodoChange
+	OwnerID  int64      `json:"ownerId,omitempty"`
+	Chain    *chainLink `json:"chain,omitempty"`
+	Redacted bool       `json:"redacted,omitempty"`
+}
+
+// TodoHistory returns the recorded changes to todo id.
+func (s *BoltStore) TodoHistory(ctx context.Context, id int64) ([]TodoChange, error) {
+	out := []TodoChange{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		prefix := boltKey(id)
+		c := tx.Bucket(todoEventsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var e boltTodoChange
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("decode todo history: %w", err)
+			}
+			if visible(ctx, e.OwnerID) {
+				out = append(out, e.TodoChange)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
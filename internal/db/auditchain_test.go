@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// recordHistory creates, updates, completes and deletes todos, recording
+// seven changes.
+func recordHistory(ctx context.Context, t *testing.T, s Store) {
+	t.Helper()
+	for _, title := range []string{"write report", "file taxes"} {
+		todo, err := s.CreateTodo(ctx, SaveTodoInput{Title: title, Tags: []string{"work"}})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if _, err := s.UpdateTodo(ctx, todo.ID, SaveTodoInput{Title: title + "!", Tags: []string{"work"}}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if _, err := s.UpdateTodo(ctx, todo.ID, SaveTodoInput{Title: title + "!", Completed: true}); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+		if title == "file taxes" {
+			if err := s.DeleteTodo(ctx, todo.ID); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+		}
+	}
+}
+
+func verifyChain(t *testing.T, s testStore) AuditChainReport {
+	t.Helper()
+	report, err := s.VerifyAuditChain(context.Background())
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	return report
+}
+
+// rewriteEvent sets the action, the redacted flag ("true" or "false"), or
+// the title of the new snapshot, of the stored entry at chain position seq
+// behind the store's back.
+func rewriteEvent(t *testing.T, s testStore, seq int64, field, value string) {
+	t.Helper()
+	switch s := s.(type) {
+	case *SQLStore:
+		var arg any = value
+		stmt := `UPDATE todo_events SET action = ? WHERE chain_seq = ?`
+		switch field {
+		case "title":
+			stmt = `UPDATE todo_events SET new_value = json_set(new_value, '$.title', ?) WHERE chain_seq = ?`
+		case "redacted":
+			stmt, arg = `UPDATE todo_events SET redacted = ? WHERE chain_seq = ?`, value == "true"
+		}
+		if _, err := s.SQL.Exec(stmt, arg, seq); err != nil {
+			t.Fatal(err)
+		}
+	case *BoltStore:
+		err := s.DB.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(todoEventsBucket)
+			c := b.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				// Fields are kept as stored, so only the rewritten one
+				// changes.
+				var e map[string]json.RawMessage
+				if err := json.Unmarshal(v, &e); err != nil {
+					return err
+				}
+				var chain *chainLink
+				if err := json.Unmarshal(e["chain"], &chain); err != nil || chain == nil || chain.Seq != seq {
+					continue
+				}
+				if field == "title" {
+					var snapshot map[string]any
+					if err := json.Unmarshal(e["new"], &snapshot); err != nil {
+						return err
+					}
+					snapshot["title"] = value
+					e["new"], _ = json.Marshal(snapshot)
+				} else if field == "redacted" {
+					e[field], _ = json.Marshal(value == "true")
+				} else {
+					e[field], _ = json.Marshal(value)
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					return err
+				}
+				return b.Put(k, data)
+			}
+			t.Fatalf("no entry at %d", seq)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// deleteEvent removes the stored entry at chain position seq.
+func deleteEvent(t *testing.T, s testStore, seq int64) {
+	t.Helper()
+	switch s := s.(type) {
+	case *SQLStore:
+		if _, err := s.SQL.Exec(`DELETE FROM todo_events WHERE chain_seq = ?`, seq); err != nil {
+			t.Fatal(err)
+		}
+	case *BoltStore:
+		err := s.DB.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(todoEventsBucket)
+			c := b.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				var e boltTodoChange
+				if err := json.Unmarshal(v, &e); err != nil {
+					return err
+				}
+				if e.Chain != nil && e.Chain.Seq == seq {
+					return b.Delete(k)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditChain(t *testing.T) {
+	for name, s := range openTestStores(t, WithAuditChain()) {
+		t.Run(name, func(t *testing.T) {
+			recordHistory(context.Background(), t, s)
+			report := verifyChain(t, s)
+			if !report.OK() || report.Entries != 7 || report.Unchained != 0 || len(report.Head) != 64 {
+				t.Fatalf("intact chain: %+v", report)
+			}
+
+			// Anonymization rewrites snapshots through the store.
+			anonymizer := s.(TodoAnonymizer)
+			if n, err := anonymizer.AnonymizeTodos(context.Background(), time.Now().Add(time.Hour), false); err != nil || n != 1 {
+				t.Fatalf("anonymize: %d, %v", n, err)
+			}
+			report = verifyChain(t, s)
+			if !report.OK() || report.Entries != 8 || report.Redacted != 3 {
+				t.Fatalf("after anonymizing: %+v", report)
+			}
+
+			rewriteEvent(t, s, 2, "action", ActionDeleted)
+			report = verifyChain(t, s)
+			if report.OK() || report.Problems[0].Seq != 2 || report.Problems[0].Problem != "entry was altered" {
+				t.Fatalf("after rewriting entry 2: %+v", report)
+			}
+			rewriteEvent(t, s, 2, "action", ActionUpdated)
+			if report := verifyChain(t, s); !report.OK() {
+				t.Fatalf("after restoring entry 2: %+v", report)
+			}
+
+			deleteEvent(t, s, 5)
+			report = verifyChain(t, s)
+			if report.OK() || report.Problems[0].Seq != 5 || !strings.Contains(report.Problems[0].Problem, "missing") {
+				t.Fatalf("after deleting entry 5: %+v", report)
+			}
+
+			deleteEvent(t, s, 7)
+			report = verifyChain(t, s)
+			if last := report.Problems[len(report.Problems)-1]; last.Seq != 7 || !strings.Contains(last.Problem, "missing") {
+				t.Fatalf("after deleting the last entry: %+v", report)
+			}
+
+			rewriteEvent(t, s, 4, "title", "pay nothing")
+			report = verifyChain(t, s)
+			if report.Problems[0].Seq != 4 || report.Problems[0].Problem != "snapshots were altered" {
+				t.Fatalf("after rewriting the snapshot of entry 4: %+v", report)
+			}
+		})
+	}
+}
+
+func TestAuditChainRedaction(t *testing.T) {
+	for name, s := range openTestStores(t, WithAuditChain()) {
+		t.Run(name, func(t *testing.T) {
+			recordHistory(context.Background(), t, s)
+			if _, err := s.(TodoAnonymizer).AnonymizeTodos(context.Background(), time.Now().Add(time.Hour), false); err != nil {
+				t.Fatal(err)
+			}
+
+			// Flagging an entry redacted does not exempt it.
+			rewriteEvent(t, s, 5, "redacted", "true")
+			rewriteEvent(t, s, 5, "title", "pay nothing")
+			report := verifyChain(t, s)
+			if report.OK() || report.Problems[0].Seq != 5 || report.Problems[0].Problem != "redacted without a redaction entry" {
+				t.Fatalf("after flagging entry 5: %+v", report)
+			}
+			rewriteEvent(t, s, 5, "redacted", "false")
+
+			// Nor are entries the store redacted open to rewriting.
+			rewriteEvent(t, s, 2, "title", "pay nothing")
+			report = verifyChain(t, s)
+			if len(report.Problems) != 2 || report.Problems[1].Seq != 8 || report.Problems[1].Problem != "the snapshots it redacted were altered" {
+				t.Fatalf("after rewriting redacted entry 2: %+v", report)
+			}
+		})
+	}
+}
+
+func TestAuditChainAccountDeletion(t *testing.T) {
+	s := openTestStores(t, WithAuditChain())["sqlite"].(*SQLStore)
+	ctx := context.Background()
+	recordHistory(context.Background(), t, s)
+	u, err := s.CreateUser(ctx, "ada@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordHistory(WithOwner(ctx, u.ID), t, s)
+	recordHistory(context.Background(), t, s)
+	if err := s.DeleteAccount(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	report := verifyChain(t, s)
+	if !report.OK() || report.Entries != 15 || report.Removed != 7 {
+		t.Fatalf("after deleting the account: %+v", report)
+	}
+
+	deleteEvent(t, s, 15)
+	report = verifyChain(t, s)
+	if report.OK() || report.Problems[0].Seq != 15 || !strings.Contains(report.Problems[0].Problem, "missing") {
+		t.Fatalf("after deleting entry 15: %+v", report)
+	}
+	if err := s.DeleteAccount(ctx, u.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("deleting again: %v", err)
+	}
+}
+
+func TestAuditChainOff(t *testing.T) {
+	for name, s := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			recordHistory(context.Background(), t, s)
+			report := verifyChain(t, s)
+			if !report.OK() || report.Entries != 0 || report.Unchained != 7 {
+				t.Fatalf("unchained history: %+v", report)
+			}
+		})
+	}
+}
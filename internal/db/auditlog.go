@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// AuditEntry is a recorded todo change with the owner of the todo, as read
+// across owners for forwarding to an external audit collector.
+type AuditEntry struct {
+	TodoChange
+	OwnerID int64 `json:"ownerId,omitempty"`
+	// Hash is the entry's audit chain hash, when chained; a collector
+	// keeping them can tell whether the chain was rewritten later.
+	Hash string `json:"hash,omitempty"`
+}
+
+// AuditLog is implemented by backends whose todo history can be read in the
+// order it was recorded, for forwarding it elsewhere.
+type AuditLog interface {
+	// AuditEntries returns up to limit changes with ids above after that
+	// were recorded before before, oldest first. The cutoff leaves time for
+	// transactions that took an id earlier to commit, so none is skipped.
+	AuditEntries(ctx context.Context, after int64, before time.Time, limit int) ([]AuditEntry, error)
+	// AuditCursor returns the id of the last change the named consumer has
+	// handled, zero before its first.
+	AuditCursor(ctx context.Context, name string) (int64, error)
+	// SetAuditCursor records that the named consumer has handled every
+	// change up to id.
+	SetAuditCursor(ctx context.Context, name string, id int64) error
+}
+
+// AuditEntries reads todo_events in id order.
+func (s *SQLStore) AuditEntries(ctx context.Context, after int64, before time.Time, limit int) ([]AuditEntry, error) {
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(fmt.Sprintf(
+		`SELECT id, todo_id, action, old_value, new_value, owner_id, actor_id, request_id, created_at, hash
+		 FROM todo_events WHERE id > $1 AND created_at < $2 ORDER BY id LIMIT %d`, limit)),
+		after, before.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var oldValue, newValue, hash sql.NullString
+		var owner, actor sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.TodoID, &e.Action, &oldValue, &newValue, &owner, &actor, &e.RequestID, &e.CreatedAt, &hash); err != nil {
+			return nil, err
+		}
+		e.OwnerID, e.ActorID, e.Hash = owner.Int64, actor.Int64, hash.String
+		if e.Old, err = s.openSnapshot(ctx, oldValue); err != nil {
+			return nil, err
+		}
+		if e.New, err = s.openSnapshot(ctx, newValue); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// AuditCursor reads the consumer's row of audit_cursors.
+func (s *SQLStore) AuditCursor(ctx context.Context, name string) (int64, error) {
+	var id int64
+	err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT last_id FROM audit_cursors WHERE name = $1`), name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// SetAuditCursor upserts the consumer's row of audit_cursors.
+func (s *SQLStore) SetAuditCursor(ctx context.Context, name string, id int64) error {
+	upsert := `INSERT INTO audit_cursors (name, last_id) VALUES ($1, $2)
+		 ON CONFLICT (name) DO UPDATE SET last_id = EXCLUDED.last_id`
+	if s.dialect.name == mysqlDialect.name {
+		upsert = `INSERT INTO audit_cursors (name, last_id) VALUES ($1, $2)
+		 ON DUPLICATE KEY UPDATE last_id = VALUES(last_id)`
+	}
+	_, err := s.SQL.ExecContext(ctx, s.dialect.rebind(upsert), name, id)
+	return err
+}
+
+var auditCursorsBucket = []byte("audit_cursors")
+
+// AuditEntries scans the whole history: Bolt keys it by todo, not by id.
+// Writes are serialized, so ids are recorded in order and before is not
+// needed to avoid gaps; it is applied all the same.
+func (s *BoltStore) AuditEntries(ctx context.Context, after int64, before time.Time, limit int) ([]AuditEntry, error) {
+	var out []AuditEntry
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(todoEventsBucket).ForEach(func(_, v []byte) error {
+			var e boltTodoChange
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("decode todo history: %w", err)
+			}
+			if e.ID > after && e.CreatedAt.Before(before) {
+				entry := AuditEntry{TodoChange: e.TodoChange, OwnerID: e.OwnerID}
+				if e.Chain != nil {
+					entry.Hash = e.Chain.Hash
+				}
+				out = append(out, entry)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(out, func(a, b AuditEntry) int { return int(a.ID - b.ID) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// AuditCursor reads the consumer's cursor.
+func (s *BoltStore) AuditCursor(ctx context.Context, name string) (int64, error) {
+	var id int64
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(auditCursorsBucket).Get([]byte(name)); len(v) == 8 {
+			id = int64(binary.BigEndian.Uint64(v))
+		}
+		return nil
+	})
+	return id, err
+}
+
+// SetAuditCursor stores the consumer's cursor.
+func (s *BoltStore) SetAuditCursor(ctx context.Context, name string, id int64) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(auditCursorsBucket).Put([]byte(name), binary.BigEndian.AppendUint64(nil, uint64(id)))
+	})
+}
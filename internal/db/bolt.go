@@ -0,0 +1,570 @@
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var todosBucket = []byte("todos")
+
+// BoltStore is an embedded, single-file Store for single-user "personal mode"
+// deployments that do not want to run PostgreSQL.
+type BoltStore struct {
+	DB *bolt.DB
+	// auditChain hash-chains recorded history; see WithAuditChain.
+	auditChain bool
+	stamps
+}
+
+// NewBoltStore opens (or creates) the BoltDB file at path. Of opts only
+// WithClock, WithUUIDs and WithAuditChain apply.
+func NewBoltStore(path string, opts ...Option) (*BoltStore, error) {
+	if path == "" {
+		return nil, errors.New("bolt path must not be empty")
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{todosBucket, todoEventsBucket, viewsBucket, listsBucket, usersBucket, userEmailsBucket, rulesBucket, ruleRunsBucket, webhooksBucket, deliveriesBucket, intakeHooksBucket, preferencesBucket, subscriptionsBucket, subscriptionMatchesBucket, domainsBucket, timeEntriesBucket, listSnapshotsBucket, eventCountsBucket, auditCursorsBucket, auditChainBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init bolt: %w", err)
+	}
+	o := buildOptions(opts)
+	return &BoltStore{DB: db, auditChain: o.auditChain, stamps: newStamps(o)}, nil
+}
+
+// Close closes the underlying Bolt file.
+func (s *BoltStore) Close() error {
+	if s == nil || s.DB == nil {
+		return nil
+	}
+	return s.DB.Close()
+}
+
+// Ping checks the Bolt file is open.
+func (s *BoltStore) Ping(context.Context) error {
+	return s.DB.View(func(*bolt.Tx) error { return nil })
+}
+
+// ListTodos returns all todos ordered by creation. Keys are sequential ids,
+// so bucket order already matches created_at ascending.
+func (s *BoltStore) ListTodos(ctx context.Context) ([]Todo, error) {
+	if _, ok := OwnerFrom(ctx); ok {
+		return s.ListTodosFiltered(ctx, TodoFilter{})
+	}
+	out := []Todo{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(todosBucket).ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			out = append(out, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListTodosFiltered returns the todos matching filter ordered by creation.
+func (s *BoltStore) ListTodosFiltered(ctx context.Context, filter TodoFilter) ([]Todo, error) {
+	out := []Todo{}
+	err := s.StreamTodos(ctx, filter, func(t Todo) error {
+		out = append(out, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StreamTodos calls fn for every todo matching filter in creation order.
+// fn runs inside a read transaction and must not write to the store.
+func (s *BoltStore) StreamTodos(ctx context.Context, filter TodoFilter, fn func(Todo) error) error {
+	filter = filter.scoped(ctx)
+	return s.DB.View(func(tx *bolt.Tx) error {
+		archived, err := boltArchivedLists(tx, filter)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(todosBucket).ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if !filter.matches(t) || (t.ListID != nil && archived[*t.ListID]) {
+				return nil
+			}
+			return fn(t)
+		})
+	})
+}
+
+// boltArchivedLists returns the ids of the archived lists when filter hides
+// their todos.
+func boltArchivedLists(tx *bolt.Tx, filter TodoFilter) (map[int64]bool, error) {
+	if !filter.HideArchived {
+		return nil, nil
+	}
+	archived := map[int64]bool{}
+	err := tx.Bucket(listsBucket).ForEach(func(_, v []byte) error {
+		var l List
+		if err := json.Unmarshal(v, &l); err != nil {
+			return err
+		}
+		if l.Archived {
+			archived[l.ID] = true
+		}
+		return nil
+	})
+	return archived, err
+}
+
+// CountTodos returns the total, open and overdue todo counts.
+func (s *BoltStore) CountTodos(ctx context.Context) (TodoCounts, error) {
+	all, err := s.ListTodos(ctx)
+	if err != nil {
+		return TodoCounts{}, err
+	}
+	now := s.now()
+	c := TodoCounts{Total: int64(len(all))}
+	for _, t := range all {
+		if !t.Completed {
+			c.Open++
+			if t.DueAt != nil && t.DueAt.Before(now) {
+				c.Overdue++
+			}
+		}
+	}
+	return c, nil
+}
+
+// GetTodo returns a todo by id.
+func (s *BoltStore) GetTodo(ctx context.Context, id int64) (Todo, error) {
+	var t Todo
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(todosBucket).Get(boltKey(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		var err error
+		if t, err = decodeBoltTodo(v); err != nil {
+			return err
+		}
+		if !visible(ctx, t.OwnerID) {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	return t, nil
+}
+
+// CreateTodo creates a new todo.
+func (s *BoltStore) CreateTodo(ctx context.Context, input SaveTodoInput) (Todo, error) {
+	if err := validateInput(input); err != nil {
+		return Todo{}, err
+	}
+	var t Todo
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		if err := boltListRef(ctx, tx, input.ListID); err != nil {
+			return err
+		}
+		b := tx.Bucket(todosBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		uuid, err := s.newUUID()
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		t = Todo{ID: int64(seq), UUID: uuid, CreatedAt: now}
+		t.OwnerID, _ = OwnerFrom(ctx)
+		applyInput(&t, input, now)
+		if err := putBoltTodo(b, t); err != nil {
+			return err
+		}
+		return s.recordBoltChange(ctx, tx, ActionCreated, nil, &t)
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	slog.InfoContext(ctx, "todo.created", "id", t.ID, "title", t.Title)
+	return t, nil
+}
+
+// UpdateTodo updates fields for a todo by id.
+func (s *BoltStore) UpdateTodo(ctx context.Context, id int64, input SaveTodoInput) (Todo, error) {
+	return s.updateTodo(ctx, id, input, nil)
+}
+
+// UpdateTodoIfUnmodified updates a todo only while its UpdatedAt still
+// equals version, returning ErrPreconditionFailed otherwise.
+func (s *BoltStore) UpdateTodoIfUnmodified(ctx context.Context, id int64, input SaveTodoInput, version time.Time) (Todo, error) {
+	return s.updateTodo(ctx, id, input, &version)
+}
+
+func (s *BoltStore) updateTodo(ctx context.Context, id int64, input SaveTodoInput, version *time.Time) (Todo, error) {
+	if err := validateInput(input); err != nil {
+		return Todo{}, err
+	}
+	var t Todo
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(todosBucket)
+		v := b.Get(boltKey(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		var err error
+		if t, err = decodeBoltTodo(v); err != nil {
+			return err
+		}
+		if !visible(ctx, t.OwnerID) {
+			return ErrNotFound
+		}
+		if version != nil && !t.UpdatedAt.Equal(*version) {
+			return ErrPreconditionFailed
+		}
+		if err := boltListRef(ctx, tx, input.ListID); err != nil {
+			return err
+		}
+		old := t
+		applyInput(&t, input, s.now())
+		if err := putBoltTodo(b, t); err != nil {
+			return err
+		}
+		return s.recordBoltChange(ctx, tx, ActionUpdated, &old, &t)
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	slog.InfoContext(ctx, "todo.updated", "id", t.ID, "title", t.Title, "completed", t.Completed)
+	return t, nil
+}
+
+// DeleteTodo deletes a todo by id.
+func (s *BoltStore) DeleteTodo(ctx context.Context, id int64) error {
+	var found bool
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(todosBucket)
+		key := boltKey(id)
+		v := b.Get(key)
+		if v == nil {
+			return nil
+		}
+		t, err := decodeBoltTodo(v)
+		if err != nil || !visible(ctx, t.OwnerID) {
+			return err
+		}
+		found = true
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		return s.recordBoltChange(ctx, tx, ActionDeleted, &t, nil)
+	})
+	if err != nil {
+		return err
+	}
+	if found {
+		slog.InfoContext(ctx, "todo.deleted", "id", id, "rows", 1)
+	} else {
+		slog.WarnContext(ctx, "todo.delete.miss", "id", id)
+	}
+	return nil
+}
+
+// DeleteTodos deletes every todo matching filter in one write transaction.
+func (s *BoltStore) DeleteTodos(ctx context.Context, filter TodoFilter) (int64, error) {
+	filter = filter.scoped(ctx)
+	var n int64
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(todosBucket)
+		var matched []Todo
+		err := b.ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if filter.matches(t) {
+				matched = append(matched, t)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Todos are collected first: deleting during ForEach skips entries.
+		for i := range matched {
+			if err := b.Delete(boltKey(matched[i].ID)); err != nil {
+				return err
+			}
+			if err := s.recordBoltChange(ctx, tx, ActionDeleted, &matched[i], nil); err != nil {
+				return err
+			}
+		}
+		n = int64(len(matched))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "todo.bulk_deleted", "rows", n)
+	return n, nil
+}
+
+// RetagTodos rewrites the tags of every matching todo in one write
+// transaction, skipping those already tagged as asked.
+func (s *BoltStore) RetagTodos(ctx context.Context, filter TodoFilter, add, remove []string, dryRun bool) (int64, error) {
+	add, remove, err := validateRetag(add, remove)
+	if err != nil {
+		return 0, err
+	}
+	filter = filter.scoped(ctx)
+	var n int64
+	retag := func(tx *bolt.Tx) error {
+		b := tx.Bucket(todosBucket)
+		var changed []Todo
+		err := b.ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if filter.matches(t) && !slices.Equal(t.Tags, retagged(t.Tags, add, remove)) {
+				changed = append(changed, t)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		n = int64(len(changed))
+		if dryRun {
+			return nil
+		}
+		now := s.now()
+		for i := range changed {
+			old := changed[i]
+			t := old
+			t.Tags, t.UpdatedAt = retagged(old.Tags, add, remove), now
+			if err := putBoltTodo(b, t); err != nil {
+				return err
+			}
+			if err := s.recordBoltChange(ctx, tx, ActionUpdated, &old, &t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if dryRun {
+		err = s.DB.View(retag)
+	} else {
+		err = s.DB.Update(retag)
+	}
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "todo.bulk_retagged", "add", add, "remove", remove, "dry_run", dryRun, "rows", n)
+	return n, nil
+}
+
+// BulkSave applies ops in order within one write transaction.
+func (s *BoltStore) BulkSave(ctx context.Context, ops []BulkOp) ([]Todo, error) {
+	if err := validateBulk(ops); err != nil {
+		return nil, err
+	}
+	out := make([]Todo, len(ops))
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		now := s.now()
+		owner, _ := OwnerFrom(ctx)
+		for i, op := range ops {
+			if err := s.applyBoltOp(ctx, tx, op, owner, now, &out[i]); err != nil {
+				return &BulkError{Index: i, Err: err}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "todo.bulk_saved", "operations", len(ops))
+	return out, nil
+}
+
+// ImportTodos inserts todos in one write transaction, skipping duplicates of
+// the caller's existing todos.
+func (s *BoltStore) ImportTodos(ctx context.Context, todos []ImportTodo) (ImportResult, error) {
+	todos, err := prepareImport(todos, s.now())
+	if err != nil {
+		return ImportResult{}, err
+	}
+	var res ImportResult
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		res = ImportResult{}
+		b := tx.Bucket(todosBucket)
+		seen := make(map[string]bool)
+		err := b.ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if visible(ctx, t.OwnerID) {
+				seen[importKey(t.Title, t.CreatedAt)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		owner, _ := OwnerFrom(ctx)
+		for _, it := range todos {
+			key := importKey(it.Input.Title, it.CreatedAt)
+			if seen[key] {
+				res.Skipped++
+				continue
+			}
+			seen[key] = true
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			uuid, err := s.newUUID()
+			if err != nil {
+				return err
+			}
+			t := Todo{ID: int64(seq), UUID: uuid, OwnerID: owner, CreatedAt: it.CreatedAt}
+			applyInput(&t, it.Input, it.UpdatedAt)
+			if err := putBoltTodo(b, t); err != nil {
+				return err
+			}
+			if err := s.recordBoltChange(ctx, tx, ActionCreated, nil, &t); err != nil {
+				return err
+			}
+			res.Imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	slog.InfoContext(ctx, "todo.imported", "imported", res.Imported, "skipped", res.Skipped)
+	return res, nil
+}
+
+func (s *BoltStore) applyBoltOp(ctx context.Context, tx *bolt.Tx, op BulkOp, owner int64, now time.Time, out *Todo) error {
+	b := tx.Bucket(todosBucket)
+	if op.Action != BulkDelete {
+		if err := boltListRef(ctx, tx, op.Input.ListID); err != nil {
+			return err
+		}
+	}
+	if op.Action == BulkCreate {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		uuid := op.UUID
+		if uuid == "" {
+			if uuid, err = s.newUUID(); err != nil {
+				return err
+			}
+		}
+		*out = Todo{ID: int64(seq), UUID: uuid, OwnerID: owner, CreatedAt: now}
+		applyInput(out, op.Input, now)
+		if err := putBoltTodo(b, *out); err != nil {
+			return err
+		}
+		return s.recordBoltChange(ctx, tx, ActionCreated, nil, out)
+	}
+	v := b.Get(boltKey(op.ID))
+	if v == nil {
+		return ErrNotFound
+	}
+	t, err := decodeBoltTodo(v)
+	if err != nil {
+		return err
+	}
+	if !visible(ctx, t.OwnerID) {
+		return ErrNotFound
+	}
+	if op.Action == BulkDelete {
+		if err := b.Delete(boltKey(op.ID)); err != nil {
+			return err
+		}
+		return s.recordBoltChange(ctx, tx, ActionDeleted, &t, nil)
+	}
+	old := t
+	applyInput(&t, op.Input, now)
+	*out = t
+	if err := putBoltTodo(b, t); err != nil {
+		return err
+	}
+	return s.recordBoltChange(ctx, tx, ActionUpdated, &old, out)
+}
+
+func applyInput(t *Todo, input SaveTodoInput, now time.Time) {
+	t.Title = input.Title
+	t.Completed = input.Completed
+	t.Tags = input.Tags
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	t.DurationMinutes = input.DurationMinutes
+	t.PriorityScore = input.PriorityScore
+	t.DueAt = input.DueAt
+	t.StartAt = input.StartAt
+	t.Effort = input.Effort
+	t.ReminderOffsets = input.ReminderOffsets
+	t.Recurrence = input.Recurrence
+	t.ListID = input.ListID
+	t.UpdatedAt = now
+}
+
+func boltKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+func putBoltTodo(b *bolt.Bucket, t Todo) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("encode todo: %w", err)
+	}
+	return b.Put(boltKey(t.ID), data)
+}
+
+func decodeBoltTodo(data []byte) (Todo, error) {
+	var t Todo
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Todo{}, fmt.Errorf("decode todo: %w", err)
+	}
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	return t, nil
+}
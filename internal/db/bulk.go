@@ -0,0 +1,453 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BulkUpdater is implemented by backends that can apply set-based changes in
+// a single statement instead of one round trip per todo.
+type BulkUpdater interface {
+	MarkCompleted(ctx context.Context, ids []int64) (int64, error)
+	AddTagToAll(ctx context.Context, filter TodoFilter, tag string) (int64, error)
+	ClearCompletedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Retagger is implemented by backends that can add and remove tags on every
+// todo matching a filter in one operation.
+type Retagger interface {
+	// RetagTodos adds the tags of add each todo matching filter lacks and
+	// removes those of remove, returning how many todos changed. With
+	// dryRun nothing is written and the count is of todos that would.
+	RetagTodos(ctx context.Context, filter TodoFilter, add, remove []string, dryRun bool) (int64, error)
+}
+
+// MaxRetagTags caps the tags a retag adds or removes, each.
+const MaxRetagTags = 20
+
+// ErrInvalidRetag wraps the reasons RetagTodos rejects its tags.
+var ErrInvalidRetag = errors.New("invalid retag")
+
+// validateRetag normalizes tags as todos store them (trimmed, lower case,
+// no duplicates) and checks there is something to do.
+func validateRetag(add, remove []string) (addOut, removeOut []string, err error) {
+	clean := func(tags []string) ([]string, error) {
+		if len(tags) > MaxRetagTags {
+			return nil, fmt.Errorf("%w: at most %d tags to add or remove", ErrInvalidRetag, MaxRetagTags)
+		}
+		out := []string{}
+		for _, tag := range tags {
+			tag = strings.TrimSpace(strings.ToLower(tag))
+			if tag == "" || len(tag) > 32 {
+				return nil, fmt.Errorf("%w: tags must be 1 to 32 characters", ErrInvalidRetag)
+			}
+			if !slices.Contains(out, tag) {
+				out = append(out, tag)
+			}
+		}
+		return out, nil
+	}
+	if addOut, err = clean(add); err != nil {
+		return nil, nil, err
+	}
+	if removeOut, err = clean(remove); err != nil {
+		return nil, nil, err
+	}
+	if len(addOut) == 0 && len(removeOut) == 0 {
+		return nil, nil, fmt.Errorf("%w: add or remove at least one tag", ErrInvalidRetag)
+	}
+	for _, tag := range addOut {
+		if slices.Contains(removeOut, tag) {
+			return nil, nil, fmt.Errorf("%w: tag %q is both added and removed", ErrInvalidRetag, tag)
+		}
+	}
+	return addOut, removeOut, nil
+}
+
+// retagged returns tags with add appended where missing and remove taken
+// out, as the SQL retag expression computes it.
+func retagged(tags, add, remove []string) []string {
+	out := []string{}
+	for _, tag := range append(slices.Clone(tags), add...) {
+		if !slices.Contains(out, tag) && !slices.Contains(remove, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// BulkDeleter is implemented by backends that can delete every todo matching
+// a filter in one operation.
+type BulkDeleter interface {
+	DeleteTodos(ctx context.Context, filter TodoFilter) (int64, error)
+}
+
+// BulkSaver is implemented by backends that can apply a batch of creates,
+// updates and deletes atomically.
+type BulkSaver interface {
+	// BulkSave applies ops in order within one transaction and returns the
+	// resulting todo for each (the zero Todo for deletes). If any op fails,
+	// none are applied and the error is a *BulkError naming it.
+	BulkSave(ctx context.Context, ops []BulkOp) ([]Todo, error)
+}
+
+// BulkAction is what a BulkOp does.
+type BulkAction string
+
+const (
+	BulkCreate BulkAction = "create"
+	BulkUpdate BulkAction = "update"
+	BulkDelete BulkAction = "delete"
+)
+
+// BulkOp is one operation of a BulkSave batch. ID is unused for creates and
+// Input for deletes. Updating or deleting a missing todo fails the batch.
+type BulkOp struct {
+	Action BulkAction
+	ID     int64
+	Input  SaveTodoInput
+	// UUID, when set, is the uuid a create gives its todo instead of a new
+	// one, so a deleted todo can be recreated as itself.
+	UUID string
+}
+
+// BulkError reports the operation that aborted a BulkSave.
+type BulkError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
+
+// validateBulk checks every op before anything is written.
+func validateBulk(ops []BulkOp) error {
+	for i, op := range ops {
+		var err error
+		switch op.Action {
+		case BulkCreate:
+			if err = validateInput(op.Input); err == nil && op.UUID != "" && !validUUID(op.UUID) {
+				err = errors.New("invalid uuid")
+			}
+		case BulkUpdate:
+			if err = validateInput(op.Input); err == nil && op.ID <= 0 {
+				err = errors.New("invalid id")
+			}
+		case BulkDelete:
+			if op.ID <= 0 {
+				err = errors.New("invalid id")
+			}
+		default:
+			err = fmt.Errorf("unknown action %q", op.Action)
+		}
+		if err != nil {
+			return &BulkError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// bulkInsertRows caps the rows of one multi-row INSERT; at 15 parameters a
+// row that stays far below every driver's placeholder limit.
+const bulkInsertRows = 100
+
+// BulkSave applies ops in one transaction. Consecutive creates are written
+// with multi-row INSERTs of up to bulkInsertRows todos.
+func (s *SQLStore) BulkSave(ctx context.Context, ops []BulkOp) ([]Todo, error) {
+	if err := validateBulk(ops); err != nil {
+		return nil, err
+	}
+	var out []Todo
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		out = make([]Todo, len(ops))
+		var pending []int
+		flush := func() error {
+			for len(pending) > 0 {
+				batch := pending[:min(bulkInsertRows, len(pending))]
+				pending = pending[len(batch):]
+				if err := s.bulkInsert(ctx, tx, ops, batch, out); err != nil {
+					return &BulkError{Index: batch[0], Err: err}
+				}
+			}
+			return nil
+		}
+		for i, op := range ops {
+			if op.Action == BulkCreate {
+				pending = append(pending, i)
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			var err error
+			if op.Action == BulkUpdate {
+				out[i], err = s.bulkUpdate(ctx, tx, op.ID, op.Input)
+			} else {
+				err = s.bulkDelete(ctx, tx, op.ID)
+			}
+			if err != nil {
+				return &BulkError{Index: i, Err: err}
+			}
+		}
+		return flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if op.Action == BulkDelete {
+			s.cache.invalidate(op.ID)
+		} else {
+			s.cache.put(out[i])
+		}
+	}
+	slog.InfoContext(ctx, "todo.bulk_saved", "operations", len(ops))
+	return out, nil
+}
+
+// bulkInsert creates the todos of ops[batch] with a single INSERT and stores
+// them in out. Rows are matched back by uuid, since neither RETURNING nor
+// auto-increment ids promise the order of a multi-row insert.
+func (s *SQLStore) bulkInsert(ctx context.Context, tx *sql.Tx, ops []BulkOp, batch []int, out []Todo) error {
+	owner, now := ownerValue(ctx), s.now()
+	values := make([]string, 0, len(batch))
+	args := make([]any, 0, len(batch)*15)
+	uuids := make([]any, 0, len(batch))
+	index := make(map[string]int, len(batch))
+	titles := make(map[string]string, len(batch))
+	for _, i := range batch {
+		input := ops[i].Input
+		if err := s.checkListRef(ctx, tx, input.ListID); err != nil {
+			return err
+		}
+		tagsJSON, err := encodeTags(input.Tags)
+		if err != nil {
+			return err
+		}
+		reminders, err := encodeReminderOffsets(input.ReminderOffsets)
+		if err != nil {
+			return err
+		}
+		uuid := ops[i].UUID
+		if uuid == "" {
+			if uuid, err = s.newUUID(); err != nil {
+				return err
+			}
+		}
+		values = append(values, "("+placeholders(len(args)+1, 15)+")")
+		args = append(args, uuid, s.insertTitle(input.Title), input.Completed, string(tagsJSON), input.DurationMinutes, input.PriorityScore, input.DueAt, input.StartAt, input.Effort, reminders, input.Recurrence, input.ListID, owner, now, now)
+		uuids = append(uuids, uuid)
+		index[uuid] = i
+		titles[uuid] = input.Title
+	}
+	insert := `INSERT INTO todos (uuid, title, completed, tags, duration_minutes, priority_score, due_at, start_at, effort, reminder_offsets, recurrence, list_id, owner_id, created_at, updated_at)
+		 VALUES ` + strings.Join(values, ", ")
+
+	var rows *sql.Rows
+	var err error
+	if s.dialect.returning {
+		rows, err = tx.QueryContext(ctx, s.dialect.rebind(insert+` RETURNING `+todoColumns), args...)
+	} else {
+		if _, err = tx.ExecContext(ctx, s.dialect.rebind(insert), args...); err != nil {
+			return err
+		}
+		rows, err = tx.QueryContext(ctx, s.dialect.rebind(`SELECT `+todoColumns+` FROM todos WHERE uuid IN (`+placeholders(1, len(uuids))+`)`), uuids...)
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := s.scanTodo(ctx, rows)
+		if err != nil {
+			return err
+		}
+		if t.Tags == nil {
+			t.Tags = []string{}
+		}
+		out[index[t.UUID]] = t
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	inserted := make([]*Todo, len(batch))
+	changes := make([]todoChange, len(batch))
+	for j, i := range batch {
+		inserted[j] = &out[i]
+		changes[j] = todoChange{action: ActionCreated, new: &out[i]}
+	}
+	if err := s.sealInserted(ctx, tx, titles, inserted...); err != nil {
+		return err
+	}
+	return s.recordChanges(ctx, tx, changes)
+}
+
+func (s *SQLStore) bulkUpdate(ctx context.Context, tx *sql.Tx, id int64, input SaveTodoInput) (Todo, error) {
+	if err := s.checkListRef(ctx, tx, input.ListID); err != nil {
+		return Todo{}, err
+	}
+	old, err := s.lockTodo(ctx, tx, id)
+	if err != nil {
+		return Todo{}, err
+	}
+	update, args, err := s.updateStatement(ctx, id, old.OwnerID, input, nil)
+	if err != nil {
+		return Todo{}, err
+	}
+	var t Todo
+	if s.dialect.returning {
+		if t, err = s.scanTodo(ctx, tx.QueryRowContext(ctx, s.dialect.rebind(update+` RETURNING `+todoColumns), args...)); errors.Is(err, sql.ErrNoRows) {
+			err = ErrNotFound
+		}
+	} else if _, err = tx.ExecContext(ctx, s.dialect.rebind(update), args...); err == nil {
+		t, err = s.txTodo(ctx, tx, id)
+	}
+	if err != nil {
+		return Todo{}, err
+	}
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	return t, s.recordChanges(ctx, tx, []todoChange{{action: ActionUpdated, old: &old, new: &t}})
+}
+
+func (s *SQLStore) bulkDelete(ctx context.Context, tx *sql.Tx, id int64) error {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	n, err := s.rewriteTodos(ctx, tx, `id = $1`+owned, append([]any{id}, ownerArgs...), `DELETE FROM todos`, nil)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkCompleted marks every listed todo as completed and returns how many rows changed.
+func (s *SQLStore) MarkCompleted(ctx context.Context, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", len(ids))
+	n, err := s.rewriteCount(ctx,
+		`NOT completed AND id IN (`+placeholders(1, len(ids))+`)`+owned, append(args, ownerArgs...),
+		`UPDATE todos SET completed = TRUE, updated_at = $1`, []any{s.now()},
+	)
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "todo.bulk_completed", "requested", len(ids), "rows", n)
+	return n, nil
+}
+
+// AddTagToAll appends tag to every todo matching filter that does not carry it yet.
+func (s *SQLStore) AddTagToAll(ctx context.Context, filter TodoFilter, tag string) (int64, error) {
+	tag = strings.TrimSpace(strings.ToLower(tag))
+	if tag == "" {
+		return 0, errors.New("tag must not be empty")
+	}
+	cond, args := filter.scoped(ctx).where(s.dialect, 1)
+	n, err := s.rewriteCount(ctx,
+		`NOT `+s.dialect.hasTag("$1")+` AND `+cond, append([]any{tag}, args...),
+		`UPDATE todos SET tags = `+s.dialect.appendTag("tags", "$1")+`, updated_at = $2`, []any{tag, s.now()},
+	)
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "todo.bulk_tagged", "tag", tag, "rows", n)
+	return n, nil
+}
+
+// RetagTodos rewrites the tags of the matching todos with one UPDATE. Only
+// todos whose tags change are touched, so the count and history leave out
+// those already tagged as asked.
+func (s *SQLStore) RetagTodos(ctx context.Context, filter TodoFilter, add, remove []string, dryRun bool) (int64, error) {
+	add, remove, err := validateRetag(add, remove)
+	if err != nil {
+		return 0, err
+	}
+	addJSON, err := encodeTags(add)
+	if err != nil {
+		return 0, err
+	}
+	removeJSON, err := encodeTags(remove)
+	if err != nil {
+		return 0, err
+	}
+	// SQLite keeps tags as text; json() compacts it like json_group_array.
+	current := "tags"
+	if s.dialect.name == sqliteDialect.name {
+		current = "json(tags)"
+	}
+	cond, args := filter.scoped(ctx).where(s.dialect, 2)
+	cond = current + ` <> ` + s.dialect.retag("$1", "$2") + ` AND ` + cond
+	args = append([]any{string(addJSON), string(removeJSON)}, args...)
+
+	var n int64
+	if dryRun {
+		err = s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM todos WHERE `+cond), args...).Scan(&n)
+	} else {
+		n, err = s.rewriteCount(ctx, cond, args,
+			`UPDATE todos SET tags = `+s.dialect.retag("$1", "$2")+`, updated_at = $3`, []any{string(addJSON), string(removeJSON), s.now()})
+	}
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "todo.bulk_retagged", "add", add, "remove", remove, "dry_run", dryRun, "rows", n)
+	return n, nil
+}
+
+// ClearCompletedBefore deletes completed todos last modified before the cutoff.
+func (s *SQLStore) ClearCompletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	n, err := s.rewriteCount(ctx, `completed AND updated_at < $1`+owned, append([]any{before.UTC()}, ownerArgs...), `DELETE FROM todos`, nil)
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "todo.bulk_cleared", "before", before, "rows", n)
+	return n, nil
+}
+
+// DeleteTodos deletes every todo matching filter in one transaction and
+// returns how many rows were removed.
+func (s *SQLStore) DeleteTodos(ctx context.Context, filter TodoFilter) (int64, error) {
+	cond, args := filter.scoped(ctx).where(s.dialect, 0)
+	n, err := s.rewriteCount(ctx, cond, args, `DELETE FROM todos`, nil)
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "todo.bulk_deleted", "rows", n)
+	return n, nil
+}
+
+// rewriteCount runs a set-based write with rewriteTodos in its own
+// transaction and returns the affected row count. The cache cannot tell
+// which rows changed, so it is dropped entirely.
+func (s *SQLStore) rewriteCount(ctx context.Context, cond string, condArgs []any, stmt string, stmtArgs []any) (int64, error) {
+	var n int64
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		n, err = s.rewriteTodos(ctx, tx, cond, condArgs, stmt, stmtArgs)
+		return err
+	})
+	s.cache.clear()
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// todoCache is a small process-local cache of single todos. Writes through the
+// owning store refresh or invalidate entries; the TTL bounds staleness from
+// writes made by other replicas. A nil *todoCache is a valid, disabled cache.
+type todoCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu    sync.Mutex
+	items map[int64]cacheEntry
+}
+
+type cacheEntry struct {
+	todo      Todo
+	expiresAt time.Time
+}
+
+func newTodoCache(ttl time.Duration, maxEntries int) *todoCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if maxEntries <= 0 {
+		maxEntries = 1024
+	}
+	return &todoCache{ttl: ttl, maxEntries: maxEntries, items: make(map[int64]cacheEntry)}
+}
+
+func (c *todoCache) get(id int64) (Todo, bool) {
+	if c == nil {
+		return Todo{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[id]
+	if !ok {
+		return Todo{}, false
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(c.items, id)
+		return Todo{}, false
+	}
+	return cloneTodo(e.todo), true
+}
+
+func (c *todoCache) put(t Todo) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[t.ID]; !ok && len(c.items) >= c.maxEntries {
+		c.evictLocked()
+	}
+	c.items[t.ID] = cacheEntry{todo: cloneTodo(t), expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *todoCache) invalidate(id int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.items, id)
+	c.mu.Unlock()
+}
+
+func (c *todoCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	clear(c.items)
+	c.mu.Unlock()
+}
+
+// evictLocked drops expired entries, or an arbitrary one if none have expired.
+func (c *todoCache) evictLocked() {
+	now := time.Now()
+	for id, e := range c.items {
+		if now.After(e.expiresAt) {
+			delete(c.items, id)
+		}
+	}
+	if len(c.items) < c.maxEntries {
+		return
+	}
+	for id := range c.items {
+		delete(c.items, id)
+		return
+	}
+}
+
+// cloneTodo copies slice fields so callers cannot mutate cached values.
+func cloneTodo(t Todo) Todo {
+	t.Tags = append([]string{}, t.Tags...)
+	t.ReminderOffsets = slices.Clone(t.ReminderOffsets)
+	return t
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func BenchmarkGetTodo(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"uncached", nil},
+		{"cached", []Option{WithTodoCache(time.Minute, 1024)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s, err := NewSQLiteStore(filepath.Join(b.TempDir(), "todo.sqlite"), bc.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Cleanup(func() { _ = s.Close() })
+			ctx := context.Background()
+			var ids []int64
+			for i := range 100 {
+				todo, err := s.CreateTodo(ctx, SaveTodoInput{Title: fmt.Sprintf("todo %d", i), Tags: []string{"work"}})
+				if err != nil {
+					b.Fatal(err)
+				}
+				ids = append(ids, todo.ID)
+			}
+			b.ResetTimer()
+			for i := range b.N {
+				if _, err := s.GetTodo(ctx, ids[i%len(ids)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
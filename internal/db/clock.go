@@ -0,0 +1,64 @@
+package db
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// WithClock stamps the todos written through the store, and their history,
+// with the time now returns instead of the system time, so fixtures and
+// recorded responses come out the same on every run. Database-assigned ids
+// stay sequential and start at 1 on a fresh database. UpsertTodoByUUID
+// keeps using the database's clock.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.clock = now
+	}
+}
+
+// WithUUIDs takes the UUIDs of new todos from next instead of generating
+// random ones. next must return canonical lowercase UUIDs that are unique
+// within the store; see SequentialUUIDs.
+func WithUUIDs(next func() (string, error)) Option {
+	return func(o *options) {
+		o.uuids = next
+	}
+}
+
+// SequentialUUIDs returns a generator of version 4 shaped UUIDs numbered
+// from 1: 00000000-0000-4000-8000-000000000001, ...
+func SequentialUUIDs() func() (string, error) {
+	var n atomic.Uint64
+	return func() (string, error) {
+		v := n.Add(1)
+		return fmt.Sprintf("%08x-0000-4000-8000-%012x", v>>48, v&(1<<48-1)), nil
+	}
+}
+
+// stamps are the clock and UUID source of a store.
+type stamps struct {
+	clock func() time.Time
+	uuids func() (string, error)
+}
+
+func newStamps(o options) stamps {
+	st := stamps{clock: o.clock, uuids: o.uuids}
+	if st.clock == nil {
+		st.clock = time.Now
+	}
+	if st.uuids == nil {
+		st.uuids = newUUID
+	}
+	return st
+}
+
+// now returns the time to stamp a write with, in UTC and truncated to the
+// microseconds every backend keeps.
+func (st stamps) now() time.Time {
+	return st.clock().UTC().Truncate(time.Microsecond)
+}
+
+func (st stamps) newUUID() (string, error) {
+	return st.uuids()
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"modernc.org/sqlite"
+)
+
+// collationTags are the locales collate has tailorings for. Locales not
+// among them collate as the closest one without a variant such as German
+// phonebook order, which has to be asked for by name.
+var (
+	collationTags    = collate.Supported()
+	collationPlain   = slices.DeleteFunc(slices.Clone(collationTags), func(t language.Tag) bool { return strings.Contains(t.String(), "-u-") })
+	collationMatcher = language.NewMatcher(collationPlain)
+)
+
+// The SQLite driver only hands collations to connections opened after they
+// are registered, so every supported locale is registered up front, each
+// compared by the same collator the in-memory sort uses.
+func init() {
+	for _, tag := range collationTags {
+		c := collate.New(tag)
+		var mu sync.Mutex // A collator is not safe for concurrent use.
+		sqlite.MustRegisterCollationUtf8(sqliteCollation(tag), func(a, b string) int {
+			mu.Lock()
+			defer mu.Unlock()
+			return c.CompareString(a, b)
+		})
+	}
+}
+
+// collationTag returns the supported locale closest to the BCP 47 tag
+// locale, the root collation for an empty or unknown one.
+func collationTag(locale string) language.Tag {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return language.Und
+	}
+	if slices.Contains(collationTags, tag) {
+		return tag
+	}
+	_, i, conf := collationMatcher.Match(tag)
+	if conf == language.No {
+		return language.Und
+	}
+	return collationPlain[i]
+}
+
+func sqliteCollation(tag language.Tag) string {
+	return "todo_" + strings.ReplaceAll(tag.String(), "-", "_")
+}
+
+// titleCollation returns the COLLATE clause ordering titles for locale, or
+// an empty string where the database has no matching collation and titles
+// sort by its default. Names come from the database's own catalog or from
+// the registered SQLite collations, never from the request, and lookups
+// are cached per locale.
+func (s *SQLStore) titleCollation(ctx context.Context, locale string) (string, error) {
+	tag := collationTag(locale)
+	if clause, ok := s.collations.Load(tag); ok {
+		return clause.(string), nil
+	}
+	var clause string
+	switch s.dialect.name {
+	case sqliteDialect.name:
+		clause = " COLLATE " + sqliteCollation(tag)
+	case cockroachDialect.name:
+		// CockroachDB collates with the same library, by language tag.
+		clause = ` COLLATE "` + tag.String() + `"`
+	case postgresDialect.name:
+		base, _ := tag.Base()
+		name, err := s.firstCollation(ctx, `SELECT collname FROM pg_collation WHERE collname = $1`,
+			base.String()+"-x-icu", "und-x-icu")
+		if err != nil {
+			return "", err
+		}
+		if name != "" {
+			clause = ` COLLATE "` + name + `"`
+		}
+	case mysqlDialect.name:
+		base, _ := tag.Base()
+		name, err := s.firstCollation(ctx, `SELECT collation_name FROM information_schema.collations WHERE collation_name = $1`,
+			"utf8mb4_"+base.String()+"_0900_as_cs", "utf8mb4_0900_as_cs")
+		if err != nil {
+			return "", err
+		}
+		if name != "" {
+			clause = " COLLATE " + name
+		}
+	}
+	s.collations.Store(tag, clause)
+	return clause, nil
+}
+
+// firstCollation returns the first of names that query, looking up the name
+// bound to $1, finds; empty if there is none.
+func (s *SQLStore) firstCollation(ctx context.Context, query string, names ...string) (string, error) {
+	for _, name := range names {
+		var found string
+		err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(query), name).Scan(&found)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return found, nil
+	}
+	return "", nil
+}
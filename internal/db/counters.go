@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// EventCount is how often a named event happened on a UTC day.
+type EventCount struct {
+	Name  string `json:"name"`
+	Day   string `json:"day"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
+
+// EventCounter is implemented by backends that keep the daily event totals
+// internal/stats flushes.
+type EventCounter interface {
+	// AddEventCounts adds counts, keyed by event name, to the totals of
+	// day ('YYYY-MM-DD').
+	AddEventCounts(ctx context.Context, day string, counts map[string]int64) error
+	// EventCounts lists the totals from day since on, oldest first.
+	EventCounts(ctx context.Context, since string) ([]EventCount, error)
+}
+
+// AddEventCounts upserts every count in one transaction.
+func (s *SQLStore) AddEventCounts(ctx context.Context, day string, counts map[string]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	upsert := `INSERT INTO event_counts (name, day, total) VALUES ($1, $2, $3)
+		 ON CONFLICT (name, day) DO UPDATE SET total = event_counts.total + EXCLUDED.total`
+	if s.dialect.name == mysqlDialect.name {
+		upsert = `INSERT INTO event_counts (name, day, total) VALUES ($1, $2, $3)
+		 ON DUPLICATE KEY UPDATE total = total + VALUES(total)`
+	}
+	return s.WithTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, s.dialect.rebind(upsert))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for name, n := range counts {
+			if _, err := stmt.ExecContext(ctx, name, day, n); err != nil {
+				return fmt.Errorf("add event count %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// EventCounts lists the totals from day since on, oldest first.
+func (s *SQLStore) EventCounts(ctx context.Context, since string) ([]EventCount, error) {
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT name, day, total FROM event_counts WHERE day >= $1 ORDER BY day, name`), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []EventCount{}
+	for rows.Next() {
+		var c EventCount
+		var day any
+		if err := rows.Scan(&c.Name, &day, &c.Count); err != nil {
+			return nil, err
+		}
+		c.Day = dayOf(day)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// eventCountsBucket keys totals by day, a slash and the event name, so a
+// range of days is a contiguous key range.
+var eventCountsBucket = []byte("event_counts")
+
+// AddEventCounts adds every count in one transaction.
+func (s *BoltStore) AddEventCounts(ctx context.Context, day string, counts map[string]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventCountsBucket)
+		for name, n := range counts {
+			key := []byte(day + "/" + name)
+			var total uint64
+			if v := b.Get(key); len(v) == 8 {
+				total = binary.BigEndian.Uint64(v)
+			}
+			if err := b.Put(key, binary.BigEndian.AppendUint64(nil, total+uint64(n))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// EventCounts lists the totals from day since on, oldest first.
+func (s *BoltStore) EventCounts(ctx context.Context, since string) ([]EventCount, error) {
+	out := []EventCount{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventCountsBucket).Cursor()
+		for k, v := c.Seek([]byte(since)); k != nil; k, v = c.Next() {
+			day, name, ok := strings.Cut(string(k), "/")
+			if !ok || len(v) != 8 {
+				continue
+			}
+			out = append(out, EventCount{Name: name, Day: day, Count: int64(binary.BigEndian.Uint64(v))})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CredentialsProvider supplies the password used when opening a new database
+// connection. Managed-identity setups (RDS IAM auth, Cloud SQL IAM login)
+// return short-lived tokens here instead of a static password in the DSN.
+type CredentialsProvider interface {
+	Password(ctx context.Context) (string, error)
+}
+
+// CommandCredentials runs a shell command and uses its trimmed stdout as the
+// password, e.g. `aws rds generate-db-auth-token ...` or
+// `gcloud sql generate-login-token`.
+type CommandCredentials struct {
+	Command string
+}
+
+// Password runs the configured command.
+func (c CommandCredentials) Password(ctx context.Context) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("credentials command: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", errors.New("credentials command returned an empty token")
+	}
+	return token, nil
+}
+
+// FileCredentials reads the password from a file that a sidecar (Vault agent,
+// a projected Kubernetes volume) keeps up to date.
+type FileCredentials struct {
+	Path string
+}
+
+// Password reads the configured file.
+func (f FileCredentials) Password(ctx context.Context) (string, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", fmt.Errorf("credentials file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("credentials file is empty")
+	}
+	return token, nil
+}
+
+// CachedCredentials reuses a token for TTL before asking the wrapped provider
+// again, so a burst of new pool connections does not mint a token each.
+// TTL should be comfortably shorter than the token lifetime (15 minutes for RDS).
+type CachedCredentials struct {
+	Provider CredentialsProvider
+	TTL      time.Duration
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewCachedCredentials wraps p with a refreshing cache.
+func NewCachedCredentials(p CredentialsProvider, ttl time.Duration) *CachedCredentials {
+	return &CachedCredentials{Provider: p, TTL: ttl}
+}
+
+// Password returns the cached token, refreshing it once it has expired.
+func (c *CachedCredentials) Password(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+	token, err := c.Provider.Password(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	c.expiresAt = time.Now().Add(c.TTL)
+	return token, nil
+}
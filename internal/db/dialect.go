@@ -0,0 +1,253 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// dialect captures the SQL differences between the supported databases.
+// Queries are written with PostgreSQL-style $n placeholders and rebound
+// for drivers that only understand "?".
+type dialect struct {
+	name   string
+	driver string
+	// returning reports whether INSERT/UPDATE ... RETURNING is available.
+	returning bool
+	// questionBinds switches $n placeholders to positional "?".
+	questionBinds bool
+	// now is the expression yielding the current timestamp.
+	now string
+	// migrationsTable creates the schema_migrations table.
+	migrationsTable string
+	// lockMigrations and unlockMigrations serialize migration runs across
+	// replicas starting together; empty where the database has no advisory
+	// locks.
+	lockMigrations, unlockMigrations string
+	// schema is the state migrations are expected to leave behind, used
+	// for drift detection.
+	schema []expectedTable
+	// columnQuery lists column names and types for the table bound to $1.
+	columnQuery string
+	// indexQuery lists index names for the table bound to $1.
+	indexQuery string
+}
+
+// rebind rewrites $n placeholders for dialects that use "?". Arguments must
+// appear in the query in the same order as they are passed.
+func (d dialect) rebind(query string) string {
+	if !d.questionBinds {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		if query[i] != '$' {
+			b.WriteByte(query[i])
+			continue
+		}
+		j := i + 1
+		for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+			j++
+		}
+		if _, err := strconv.Atoi(query[i+1 : j]); err != nil {
+			b.WriteByte(query[i])
+			continue
+		}
+		b.WriteByte('?')
+		i = j - 1
+	}
+	return b.String()
+}
+
+// hasTag renders a condition that is true when the tags column contains the
+// tag bound to param.
+func (d dialect) hasTag(param string) string {
+	switch d.name {
+	case mysqlDialect.name:
+		return "JSON_CONTAINS(tags, JSON_QUOTE(" + param + "))"
+	case sqliteDialect.name:
+		return "EXISTS (SELECT 1 FROM json_each(tags) WHERE json_each.value = " + param + ")"
+	}
+	return "tags @> jsonb_build_array(" + param + "::text)"
+}
+
+// appendTag renders an expression appending the tag bound to param to col.
+func (d dialect) appendTag(col, param string) string {
+	switch d.name {
+	case mysqlDialect.name:
+		return "JSON_ARRAY_APPEND(" + col + ", '$', " + param + ")"
+	case sqliteDialect.name:
+		return "json_insert(" + col + ", '$[#]', " + param + ")"
+	}
+	return col + " || jsonb_build_array(" + param + "::text)"
+}
+
+// retag renders the tags column with the tags of the JSON array bound to
+// add appended where missing and those of the array bound to remove taken
+// out. The remaining tags keep their order.
+func (d dialect) retag(add, remove string) string {
+	switch d.name {
+	case mysqlDialect.name:
+		return "(SELECT COALESCE(JSON_ARRAYAGG(e.value), JSON_ARRAY()) FROM" +
+			" (SELECT t.value, MIN(t.ord) AS ord FROM JSON_TABLE(JSON_MERGE_PRESERVE(tags, CAST(" + add + " AS JSON)), '$[*]'" +
+			" COLUMNS (ord FOR ORDINALITY, value VARCHAR(255) PATH '$')) AS t GROUP BY t.value ORDER BY ord) AS e" +
+			" WHERE NOT JSON_CONTAINS(CAST(" + remove + " AS JSON), JSON_QUOTE(e.value)))"
+	case sqliteDialect.name:
+		return "(SELECT json_group_array(value) FROM" +
+			" (SELECT value FROM (SELECT value, key AS ord FROM json_each(tags) UNION ALL SELECT value, 1000 + key FROM json_each(" + add + "))" +
+			" GROUP BY value ORDER BY MIN(ord))" +
+			" WHERE value NOT IN (SELECT value FROM json_each(" + remove + ")))"
+	}
+	return "(SELECT COALESCE(jsonb_agg(e.value ORDER BY e.ord), '[]'::jsonb) FROM" +
+		" (SELECT value, MIN(ord) AS ord FROM jsonb_array_elements_text(tags || " + add + "::jsonb) WITH ORDINALITY AS t(value, ord) GROUP BY value) AS e" +
+		" WHERE NOT " + remove + "::jsonb @> jsonb_build_array(e.value))"
+}
+
+// day renders the UTC calendar day of the timestamp col.
+func (d dialect) day(col string) string {
+	switch d.name {
+	case mysqlDialect.name:
+		return "DATE(" + col + ")"
+	case sqliteDialect.name:
+		return "date(" + col + ")"
+	}
+	return "(" + col + " AT TIME ZONE 'UTC')::date"
+}
+
+// eachTag renders a FROM item yielding a row per tag of the JSON array
+// col, the tag being alias.value.
+func (d dialect) eachTag(col, alias string) string {
+	switch d.name {
+	case mysqlDialect.name:
+		return "JSON_TABLE(" + col + ", '$[*]' COLUMNS (value TEXT PATH '$')) AS " + alias
+	case sqliteDialect.name:
+		return "json_each(" + col + ") AS " + alias
+	}
+	return "jsonb_array_elements_text(" + col + ") AS " + alias + "(value)"
+}
+
+// insertIgnore turns an INSERT into one that silently skips rows violating
+// a unique constraint.
+func (d dialect) insertIgnore(insert string) string {
+	if d.name == mysqlDialect.name {
+		return strings.Replace(insert, "INSERT INTO", "INSERT IGNORE INTO", 1)
+	}
+	return insert + " ON CONFLICT DO NOTHING"
+}
+
+// postgresSchema is shared by PostgreSQL and CockroachDB, which report the
+// same information_schema types for the columns used here.
+var postgresSchema = []expectedTable{
+	{
+		name: "todos",
+		columns: map[string]string{
+			"id":               "bigint",
+			"uuid":             "uuid",
+			"title":            "text",
+			"completed":        "boolean",
+			"tags":             "jsonb",
+			"duration_minutes": "integer",
+			"priority_score":   "double precision",
+			"due_at":           "timestamp with time zone",
+			"start_at":         "timestamp with time zone",
+			"effort":           "integer",
+			"reminder_offsets": "jsonb",
+			"recurrence":       "text",
+			"list_id":          "bigint",
+			"owner_id":         "bigint",
+			"created_at":       "timestamp with time zone",
+			"updated_at":       "timestamp with time zone",
+		},
+		indexes: []string{"todos_pkey", "idx_todos_completed", "idx_todos_uuid", "idx_todos_open_created", "idx_todos_priority_id", "idx_todos_duration_id", "idx_todos_created_id", "idx_todos_owner_created", "idx_todos_list_created"},
+	},
+	{
+		name:    "schema_migrations",
+		columns: map[string]string{"version": "bigint", "applied_at": "timestamp with time zone"},
+	},
+}
+
+var postgresDialect = dialect{
+	name:       "postgres",
+	driver:     "pgx",
+	returning:  true,
+	now:        "NOW()",
+	indexQuery: `SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1`,
+	columnQuery: `SELECT column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1`,
+	schema: postgresSchema,
+	migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	lockMigrations:   `SELECT pg_advisory_lock(7305187141)`,
+	unlockMigrations: `SELECT pg_advisory_unlock(7305187141)`,
+}
+
+// cockroachDialect speaks the PostgreSQL wire protocol but differs in a few
+// places: SERIAL columns default to unique_rowid(), whose values exceed the
+// 2^53 integers JavaScript clients can represent, so ids come from an explicit
+// sequence, and the historical ALTER TABLE upgrades are unnecessary because
+// CockroachDB deployments always start from the full schema.
+var cockroachDialect = dialect{
+	name:       "cockroach",
+	driver:     "pgx",
+	returning:  true,
+	now:        "NOW()",
+	indexQuery: `SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1`,
+	columnQuery: `SELECT column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1`,
+	schema: postgresSchema,
+	migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT8 PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+}
+
+// mysqlDialect targets MySQL 8.0+ and MariaDB 10.5+. Neither supports
+// UPDATE ... RETURNING, so writes are followed by a read.
+var mysqlDialect = dialect{
+	name:          "mysql",
+	driver:        "mysql",
+	questionBinds: true,
+	now:           "CURRENT_TIMESTAMP(6)",
+	indexQuery:    `SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = $1`,
+	columnQuery: `SELECT column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = $1`,
+	schema: []expectedTable{
+		{
+			name: "todos",
+			columns: map[string]string{
+				"id":               "bigint",
+				"uuid":             "char",
+				"title":            "varchar",
+				"completed":        "tinyint",
+				"tags":             "json|longtext",
+				"duration_minutes": "int",
+				"priority_score":   "double",
+				"due_at":           "datetime",
+				"start_at":         "datetime",
+				"effort":           "int",
+				"reminder_offsets": "json|longtext",
+				"recurrence":       "varchar",
+				"list_id":          "bigint",
+				"owner_id":         "bigint",
+				"created_at":       "datetime",
+				"updated_at":       "datetime",
+			},
+			indexes: []string{"PRIMARY", "idx_todos_completed", "idx_todos_uuid", "idx_todos_completed_created", "idx_todos_priority_id", "idx_todos_duration_id", "idx_todos_created_id", "idx_todos_owner_created", "idx_todos_list_created"},
+		},
+		{
+			name:    "schema_migrations",
+			columns: map[string]string{"version": "bigint", "applied_at": "datetime"},
+		},
+	},
+	migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		applied_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+	) ENGINE=InnoDB`,
+	lockMigrations:   `SELECT GET_LOCK('todo_schema_migrations', 300)`,
+	unlockMigrations: `SELECT RELEASE_LOCK('todo_schema_migrations')`,
+}
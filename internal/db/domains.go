@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrDomainNotFound is returned when a custom domain is not registered.
+	ErrDomainNotFound = errors.New("domain not found")
+	// ErrDomainTaken is returned when registering a domain twice.
+	ErrDomainTaken = errors.New("domain already registered")
+)
+
+// DomainVerificationPrefix is prepended to a domain to name the TXT record
+// that proves control of it.
+const DomainVerificationPrefix = "_todo-verify."
+
+// Domain is a custom domain the server answers on. It must be verified,
+// by publishing Token in a TXT record at DomainVerificationPrefix+Name,
+// before certificates are requested for it.
+type Domain struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// VerifiedAt is when the TXT record was found; nil until then.
+	VerifiedAt *time.Time `json:"verifiedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// DomainStore is implemented by backends that keep custom domains.
+type DomainStore interface {
+	ListDomains(ctx context.Context) ([]Domain, error)
+	GetDomain(ctx context.Context, name string) (Domain, error)
+	// AddDomain registers an unverified domain with a fresh token.
+	AddDomain(ctx context.Context, name string) (Domain, error)
+	MarkDomainVerified(ctx context.Context, name string) (Domain, error)
+	DeleteDomain(ctx context.Context, name string) error
+}
+
+var domainLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// NormalizeDomain validates a fully qualified host name and lower-cases it,
+// dropping a trailing dot.
+func NormalizeDomain(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	labels := strings.Split(name, ".")
+	if len(name) > 253 || len(labels) < 2 || net.ParseIP(name) != nil {
+		return "", errors.New("domain must be a fully qualified host name like todo.example.com")
+	}
+	for _, label := range labels {
+		if !domainLabelPattern.MatchString(label) {
+			return "", errors.New("domain must be a fully qualified host name like todo.example.com")
+		}
+	}
+	return name, nil
+}
+
+func newDomainToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate domain token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+const domainColumns = `name, token, verified_at, created_at`
+
+func scanDomain(row rowScanner) (Domain, error) {
+	var d Domain
+	err := row.Scan(&d.Name, &d.Token, &d.VerifiedAt, &d.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Domain{}, ErrDomainNotFound
+	}
+	return d, err
+}
+
+// ListDomains returns the custom domains ordered by name.
+func (s *SQLStore) ListDomains(ctx context.Context) ([]Domain, error) {
+	rows, err := s.SQL.QueryContext(ctx, `SELECT `+domainColumns+` FROM custom_domains ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Domain{}
+	for rows.Next() {
+		d, err := scanDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// GetDomain returns a custom domain by name.
+func (s *SQLStore) GetDomain(ctx context.Context, name string) (Domain, error) {
+	return scanDomain(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+domainColumns+` FROM custom_domains WHERE name = $1`), name))
+}
+
+// AddDomain registers an unverified domain.
+func (s *SQLStore) AddDomain(ctx context.Context, name string) (Domain, error) {
+	name, err := NormalizeDomain(name)
+	if err != nil {
+		return Domain{}, err
+	}
+	token, err := newDomainToken()
+	if err != nil {
+		return Domain{}, err
+	}
+	_, err = s.SQL.ExecContext(ctx, s.dialect.rebind(`INSERT INTO custom_domains (name, token, created_at) VALUES ($1, $2, $3)`), name, token, s.now())
+	if isUniqueViolation(err) {
+		return Domain{}, ErrDomainTaken
+	}
+	if err != nil {
+		return Domain{}, err
+	}
+	slog.InfoContext(ctx, "domain.added", "domain", name)
+	return s.GetDomain(ctx, name)
+}
+
+// MarkDomainVerified records that a domain's TXT record was found.
+func (s *SQLStore) MarkDomainVerified(ctx context.Context, name string) (Domain, error) {
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`UPDATE custom_domains SET verified_at = $1 WHERE name = $2 AND verified_at IS NULL`), s.now(), name)
+	if err != nil {
+		return Domain{}, err
+	}
+	d, err := s.GetDomain(ctx, name)
+	if err != nil {
+		return Domain{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		slog.InfoContext(ctx, "domain.verified", "domain", name)
+	}
+	return d, nil
+}
+
+// DeleteDomain removes a custom domain.
+func (s *SQLStore) DeleteDomain(ctx context.Context, name string) error {
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`DELETE FROM custom_domains WHERE name = $1`), name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		slog.InfoContext(ctx, "domain.deleted", "domain", name)
+	}
+	return nil
+}
+
+var domainsBucket = []byte("custom_domains")
+
+// ListDomains returns the custom domains ordered by name.
+func (s *BoltStore) ListDomains(ctx context.Context) ([]Domain, error) {
+	out := []Domain{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(domainsBucket).ForEach(func(_, v []byte) error {
+			var d Domain
+			if err := json.Unmarshal(v, &d); err != nil {
+				return fmt.Errorf("decode domain: %w", err)
+			}
+			out = append(out, d)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetDomain returns a custom domain by name.
+func (s *BoltStore) GetDomain(ctx context.Context, name string) (Domain, error) {
+	var d Domain
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return getBoltDomain(tx, name, &d)
+	})
+	return d, err
+}
+
+// AddDomain registers an unverified domain.
+func (s *BoltStore) AddDomain(ctx context.Context, name string) (Domain, error) {
+	name, err := NormalizeDomain(name)
+	if err != nil {
+		return Domain{}, err
+	}
+	token, err := newDomainToken()
+	if err != nil {
+		return Domain{}, err
+	}
+	d := Domain{Name: name, Token: token, CreatedAt: s.now()}
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(domainsBucket).Get([]byte(name)) != nil {
+			return ErrDomainTaken
+		}
+		return putBoltDomain(tx, d)
+	})
+	if err != nil {
+		return Domain{}, err
+	}
+	slog.InfoContext(ctx, "domain.added", "domain", name)
+	return d, nil
+}
+
+// MarkDomainVerified records that a domain's TXT record was found.
+func (s *BoltStore) MarkDomainVerified(ctx context.Context, name string) (Domain, error) {
+	var d Domain
+	verified := false
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		if err := getBoltDomain(tx, name, &d); err != nil {
+			return err
+		}
+		if d.VerifiedAt != nil {
+			return nil
+		}
+		now := s.now()
+		d.VerifiedAt, verified = &now, true
+		return putBoltDomain(tx, d)
+	})
+	if err != nil {
+		return Domain{}, err
+	}
+	if verified {
+		slog.InfoContext(ctx, "domain.verified", "domain", name)
+	}
+	return d, nil
+}
+
+// DeleteDomain removes a custom domain.
+func (s *BoltStore) DeleteDomain(ctx context.Context, name string) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(domainsBucket)
+		if b.Get([]byte(name)) == nil {
+			return nil
+		}
+		slog.InfoContext(ctx, "domain.deleted", "domain", name)
+		return b.Delete([]byte(name))
+	})
+}
+
+func getBoltDomain(tx *bolt.Tx, name string, d *Domain) error {
+	v := tx.Bucket(domainsBucket).Get([]byte(name))
+	if v == nil {
+		return ErrDomainNotFound
+	}
+	if err := json.Unmarshal(v, d); err != nil {
+		return fmt.Errorf("decode domain: %w", err)
+	}
+	return nil
+}
+
+func putBoltDomain(tx *bolt.Tx, d Domain) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("encode domain: %w", err)
+	}
+	return tx.Bucket(domainsBucket).Put([]byte(d.Name), data)
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// expectedTable describes the columns and indexes migrations should have
+// produced. Column types are information_schema data_type values; "|"
+// separates accepted alternatives (MariaDB reports JSON as longtext).
+// Extra columns and indexes in the live schema are not considered drift.
+type expectedTable struct {
+	name    string
+	columns map[string]string
+	indexes []string
+}
+
+// SchemaDrift is a single difference between the live and expected schema.
+type SchemaDrift struct {
+	Table    string `json:"table"`
+	Object   string `json:"object,omitempty"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+func (d SchemaDrift) String() string {
+	switch d.Kind {
+	case "column_type":
+		return fmt.Sprintf("%s.%s: type is %s, expected %s", d.Table, d.Object, d.Actual, d.Expected)
+	case "missing_table":
+		return fmt.Sprintf("%s: table missing", d.Table)
+	default:
+		return fmt.Sprintf("%s.%s: %s", d.Table, d.Object, strings.ReplaceAll(d.Kind, "_", " "))
+	}
+}
+
+// SchemaChecker is implemented by backends that can detect schema drift.
+type SchemaChecker interface {
+	CheckSchema(ctx context.Context) ([]SchemaDrift, error)
+}
+
+// CheckSchema compares the live schema against what the migrations for the
+// store's dialect are expected to have produced.
+func (s *SQLStore) CheckSchema(ctx context.Context) ([]SchemaDrift, error) {
+	out := []SchemaDrift{}
+	for _, want := range s.dialect.schema {
+		columns, err := s.liveColumns(ctx, want.name)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			out = append(out, SchemaDrift{Table: want.name, Kind: "missing_table"})
+			continue
+		}
+		for name, wantType := range want.columns {
+			got, ok := columns[name]
+			if !ok {
+				out = append(out, SchemaDrift{Table: want.name, Object: name, Kind: "missing_column", Expected: wantType})
+				continue
+			}
+			if !typeMatches(wantType, got) {
+				out = append(out, SchemaDrift{Table: want.name, Object: name, Kind: "column_type", Expected: wantType, Actual: got})
+			}
+		}
+
+		indexes, err := s.liveIndexes(ctx, want.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range want.indexes {
+			if _, ok := indexes[name]; !ok {
+				out = append(out, SchemaDrift{Table: want.name, Object: name, Kind: "missing_index"})
+			}
+		}
+	}
+	return out, nil
+}
+
+func typeMatches(want, got string) bool {
+	for _, alt := range strings.Split(want, "|") {
+		if strings.EqualFold(alt, got) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SQLStore) liveColumns(ctx context.Context, table string) (map[string]string, error) {
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(s.dialect.columnQuery), table)
+	if err != nil {
+		return nil, fmt.Errorf("inspect columns: %w", err)
+	}
+	defer rows.Close()
+
+	out := map[string]string{}
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			return nil, err
+		}
+		out[name] = dataType
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) liveIndexes(ctx context.Context, table string) (map[string]struct{}, error) {
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(s.dialect.indexQuery), table)
+	if err != nil {
+		return nil, fmt.Errorf("inspect indexes: %w", err)
+	}
+	defer rows.Close()
+
+	out := map[string]struct{}{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		out[name] = struct{}{}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,282 @@
+package db
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sealedPrefix marks an encrypted title. It is followed by the base64 nonce
+// and ciphertext, sealed with the data key of the todo's owner (owner 0 for
+// unowned todos) and authenticating the todo id and owner id, so a sealed
+// title copied to another todo or owner does not open. Titles without a
+// prefix are plain text, written before encryption was enabled, and are
+// read as they are.
+const sealedPrefix = "enc:v2:"
+
+// legacyPrefix marks titles sealed before they were bound to their todo:
+// it is followed by the id of the owner whose data key sealed them, a colon
+// and the sealed data, which authenticates only "title". They are still
+// read, and sealed anew when the todo is next written.
+const legacyPrefix = "enc:v1:"
+
+// Encryption encrypts todo titles at rest with AES-256-GCM, using a data key
+// per user. Data keys are generated on first use and stored in the data_keys
+// table wrapped by the master key, so a copy of the database alone reveals
+// neither. Titles are encrypted in the application: full-text search is
+// unavailable while encryption is enabled.
+type Encryption struct {
+	// masters holds the current master key first, then previous ones still
+	// accepted for unwrapping during a rotation.
+	masters []cipher.AEAD
+
+	mu   sync.Mutex
+	keys map[int64]cipher.AEAD
+	// ownersReady is set once every owner of a todo has a data key.
+	ownersReady bool
+}
+
+// NewEncryption returns an Encryption wrapping data keys with master, a
+// 32-byte key. previous are older master keys: data keys they wrapped can
+// still be read, while new ones are wrapped by master.
+func NewEncryption(master []byte, previous ...[]byte) (*Encryption, error) {
+	e := &Encryption{keys: make(map[int64]cipher.AEAD)}
+	for i, key := range append([][]byte{master}, previous...) {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %d: must be 32 bytes, got %d", i, len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		e.masters = append(e.masters, aead)
+	}
+	return e, nil
+}
+
+// WithEncryption encrypts todo titles with e. Only the SQL stores support it.
+func WithEncryption(e *Encryption) Option {
+	return func(o *options) {
+		o.encryption = e
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with aead under a random nonce, which it prepends.
+func seal(aead cipher.AEAD, plain, aad []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return aead.Seal(nonce, nonce, plain, aad)
+}
+
+func unseal(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], aad)
+}
+
+// titleAAD is the additional data a title of todo id, owned by owner, is
+// sealed with.
+func titleAAD(id, owner int64) []byte {
+	return []byte("todo:" + strconv.FormatInt(id, 10) + ":owner:" + strconv.FormatInt(owner, 10))
+}
+
+// sealTitle encrypts the title of todo id with the data key of its owner.
+// Without encryption it returns title.
+func (s *SQLStore) sealTitle(ctx context.Context, id, owner int64, title string) (string, error) {
+	if s.crypt == nil {
+		return title, nil
+	}
+	aead, err := s.dataKey(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	sealed := seal(aead, []byte(title), titleAAD(id, owner))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// openTitle decrypts the title of todo id, owned by owner, written by
+// sealTitle; plain titles are returned as they are.
+func (s *SQLStore) openTitle(ctx context.Context, id, owner int64, stored string) (string, error) {
+	data, ok := strings.CutPrefix(stored, sealedPrefix)
+	aad := titleAAD(id, owner)
+	if !ok {
+		rest, legacy := strings.CutPrefix(stored, legacyPrefix)
+		if !legacy {
+			return stored, nil
+		}
+		ownerStr, sealed, ok := strings.Cut(rest, ":")
+		var err error
+		if owner, err = strconv.ParseInt(ownerStr, 10, 64); !ok || err != nil {
+			return "", errors.New("malformed encrypted title")
+		}
+		data, aad = sealed, []byte("title")
+	}
+	if s.crypt == nil {
+		return "", errors.New("title is encrypted but no encryption key is configured")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		return "", errors.New("malformed encrypted title")
+	}
+	aead, err := s.dataKey(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	plain, err := unseal(aead, sealed, aad)
+	if err != nil {
+		return "", fmt.Errorf("decrypt title: %w", err)
+	}
+	return string(plain), nil
+}
+
+// insertTitle is the title an INSERT writes for title. A sealed title needs
+// the todo's id, so with encryption the INSERT writes an empty title that
+// sealInserted replaces within the same transaction.
+func (s *SQLStore) insertTitle(title string) string {
+	if s.crypt != nil {
+		return ""
+	}
+	return title
+}
+
+// sealInserted seals the titles of todos, just written by tx with
+// insertTitle, from titles by uuid, and sets them on todos in plain text.
+func (s *SQLStore) sealInserted(ctx context.Context, tx *sql.Tx, titles map[string]string, todos ...*Todo) error {
+	if s.crypt == nil {
+		return nil
+	}
+	for _, t := range todos {
+		t.Title = titles[t.UUID]
+		sealed, err := s.sealTitle(ctx, t.ID, t.OwnerID, t.Title)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE todos SET title = $1 WHERE id = $2`), sealed, t.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareDataKeys loads or creates, ahead of a transaction, the data keys
+// it may seal titles with, so no key is created from a second connection
+// while the transaction holds locks. That is the key of the user ctx is
+// scoped to. Unscoped transactions write todos of any owner, so the first
+// one creates a key for every owner of a todo that lacks one; owners that
+// appear later get theirs from the scoped writes creating their todos.
+func (s *SQLStore) prepareDataKeys(ctx context.Context) error {
+	if s.crypt == nil {
+		return nil
+	}
+	if owner, ok := OwnerFrom(ctx); ok {
+		_, err := s.dataKey(ctx, owner)
+		return err
+	}
+	e := s.crypt
+	e.mu.Lock()
+	ready := e.ownersReady
+	e.mu.Unlock()
+	if ready {
+		return nil
+	}
+	rows, err := s.SQL.QueryContext(ctx, `SELECT DISTINCT COALESCE(t.owner_id, 0) FROM todos t
+		WHERE NOT EXISTS (SELECT 1 FROM data_keys k WHERE k.owner_id = COALESCE(t.owner_id, 0))`)
+	if err != nil {
+		return fmt.Errorf("find owners without a data key: %w", err)
+	}
+	var owners []int64
+	for rows.Next() {
+		var owner int64
+		if err := rows.Scan(&owner); err != nil {
+			rows.Close()
+			return err
+		}
+		owners = append(owners, owner)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// Unscoped writes also create unowned todos, sealed with owner 0's key.
+	for _, owner := range append(owners, 0) {
+		if _, err := s.dataKey(ctx, owner); err != nil {
+			return err
+		}
+	}
+	e.mu.Lock()
+	e.ownersReady = true
+	e.mu.Unlock()
+	return nil
+}
+
+// dataKey returns owner's data key, creating it on first use. Keys are
+// read outside any transaction in progress: a key is committed before
+// anything is sealed with it.
+func (s *SQLStore) dataKey(ctx context.Context, owner int64) (cipher.AEAD, error) {
+	e := s.crypt
+	e.mu.Lock()
+	aead, ok := e.keys[owner]
+	e.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	aad := []byte("data_key:" + strconv.FormatInt(owner, 10))
+	var wrapped string
+	err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT wrapped_key FROM data_keys WHERE owner_id = $1`), owner).Scan(&wrapped)
+	if errors.Is(err, sql.ErrNoRows) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		wrapped = base64.StdEncoding.EncodeToString(seal(e.masters[0], key, aad))
+		_, err = s.SQL.ExecContext(ctx, s.dialect.rebind(`INSERT INTO data_keys (owner_id, wrapped_key) VALUES ($1, $2)`), owner, wrapped)
+		if err != nil {
+			// Another request or replica may have created it first.
+			err = s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT wrapped_key FROM data_keys WHERE owner_id = $1`), owner).Scan(&wrapped)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load data key: %w", err)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("data key of owner %d is malformed", owner)
+	}
+	var key []byte
+	for _, master := range e.masters {
+		if key, err = unseal(master, sealed, aad); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("data key of owner %d: no master key unwraps it", owner)
+	}
+	if aead, err = newGCM(key); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.keys[owner] = aead
+	e.mu.Unlock()
+	return aead, nil
+}
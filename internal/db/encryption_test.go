@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func openEncryptedStore(t *testing.T) *SQLStore {
+	t.Helper()
+	crypt, err := NewEncryption([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "todo.sqlite"), WithEncryption(crypt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// asUser creates a user and returns a context scoped to them and their id.
+func asUser(t *testing.T, s *SQLStore, email string) (context.Context, int64) {
+	t.Helper()
+	u, err := s.CreateUser(context.Background(), email, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return WithOwner(context.Background(), u.ID), u.ID
+}
+
+// storedTitle is the title column of todo id as written.
+func storedTitle(t *testing.T, s *SQLStore, id int64) string {
+	t.Helper()
+	var title string
+	if err := s.SQL.QueryRow(`SELECT title FROM todos WHERE id = ?`, id).Scan(&title); err != nil {
+		t.Fatal(err)
+	}
+	return title
+}
+
+func setStoredTitle(t *testing.T, s *SQLStore, id int64, title string) {
+	t.Helper()
+	if _, err := s.SQL.Exec(`UPDATE todos SET title = ? WHERE id = ?`, title, id); err != nil {
+		t.Fatal(err)
+	}
+	s.cache.invalidate(id)
+}
+
+func TestEncryptedTitlesRoundTrip(t *testing.T) {
+	s := openEncryptedStore(t)
+	ctx, _ := asUser(t, s, "ada@example.com")
+	created, err := s.CreateTodo(ctx, SaveTodoInput{Title: "see the doctor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bulk, err := s.BulkSave(ctx, []BulkOp{{Action: BulkCreate, Input: SaveTodoInput{Title: "buy stamps"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upserted, _, err := s.UpsertTodoByUUID(ctx, "7d3c1f3e-8f1a-4a57-9a0e-2b6a4f1c9d20", SaveTodoInput{Title: "renew passport"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ImportTodos(ctx, []ImportTodo{{Input: SaveTodoInput{Title: "file taxes"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateTodo(ctx, created.ID, SaveTodoInput{Title: "see the dentist"}); err != nil {
+		t.Fatal(err)
+	}
+
+	todos, err := s.ListTodos(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, todo := range todos {
+		titles = append(titles, todo.Title)
+		if stored := storedTitle(t, s, todo.ID); !strings.HasPrefix(stored, sealedPrefix) || strings.Contains(stored, todo.Title) {
+			t.Errorf("todo %d is stored as %q", todo.ID, stored)
+		}
+	}
+	for _, want := range []string{"see the dentist", "buy stamps", "renew passport", "file taxes"} {
+		if !strings.Contains(strings.Join(titles, "\n"), want) {
+			t.Errorf("titles %q lack %q", titles, want)
+		}
+	}
+	if bulk[0].Title != "buy stamps" || upserted.Title != "renew passport" {
+		t.Errorf("returned titles %q and %q", bulk[0].Title, upserted.Title)
+	}
+	history, err := s.TodoHistory(ctx, created.ID)
+	if err != nil || len(history) != 2 || history[1].Old.Title != "see the doctor" || history[1].New.Title != "see the dentist" {
+		t.Fatalf("history %+v, %v", history, err)
+	}
+}
+
+func TestEncryptedTitleIsBoundToItsTodo(t *testing.T) {
+	s := openEncryptedStore(t)
+	ada, _ := asUser(t, s, "ada@example.com")
+	bob, bobID := asUser(t, s, "bob@example.com")
+	secret, err := s.CreateTodo(ada, SaveTodoInput{Title: "secret plans"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.CreateTodo(ada, SaveTodoInput{Title: "groceries"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := s.CreateTodo(bob, SaveTodoInput{Title: "bob's todo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := storedTitle(t, s, secret.ID)
+	setStoredTitle(t, s, other.ID, sealed)
+	if got, err := s.GetTodo(ada, other.ID); err == nil {
+		t.Errorf("a title moved to another todo opened as %q", got.Title)
+	}
+	if _, err := s.SQL.Exec(`UPDATE todos SET owner_id = ? WHERE id = ?`, bobID, secret.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.cache.invalidate(secret.ID)
+	if got, err := s.GetTodo(bob, secret.ID); err == nil {
+		t.Errorf("a todo moved to another owner opened as %q", got.Title)
+	}
+	if got, err := s.GetTodo(bob, foreign.ID); err != nil || got.Title != "bob's todo" {
+		t.Errorf("untouched todo: %q, %v", got.Title, err)
+	}
+}
+
+func TestUnscopedWritesSealWithTheOwnersKey(t *testing.T) {
+	s := openEncryptedStore(t)
+	ada, adaID := asUser(t, s, "ada@example.com")
+	todo, err := s.CreateTodo(ada, SaveTodoInput{Title: "water plants"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A background job, like the rescorer, writes without an owner.
+	if _, err := s.UpdateTodo(context.Background(), todo.ID, SaveTodoInput{Title: "water plants", PriorityScore: 0.8}); err != nil {
+		t.Fatal(err)
+	}
+	data, ok := strings.CutPrefix(storedTitle(t, s, todo.ID), sealedPrefix)
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if !ok || err != nil {
+		t.Fatalf("stored title is not sealed: %v", err)
+	}
+	key, err := s.dataKey(ada, adaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := unseal(key, sealed, titleAAD(todo.ID, adaID)); err != nil || string(plain) != "water plants" {
+		t.Errorf("the owner's key opens %q, %v", plain, err)
+	}
+}
+
+func TestLegacyEncryptedTitlesAreRead(t *testing.T) {
+	s := openEncryptedStore(t)
+	ctx, owner := asUser(t, s, "ada@example.com")
+	todo, err := s.CreateTodo(ctx, SaveTodoInput{Title: "placeholder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.dataKey(ctx, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyPrefix + strconv.FormatInt(owner, 10) + ":" + base64.RawStdEncoding.EncodeToString(seal(key, []byte("old title"), []byte("title")))
+	setStoredTitle(t, s, todo.ID, legacy)
+	got, err := s.GetTodo(ctx, todo.ID)
+	if err != nil || got.Title != "old title" {
+		t.Fatalf("legacy title: %q, %v", got.Title, err)
+	}
+	if _, err := s.UpdateTodo(ctx, todo.ID, SaveTodoInput{Title: got.Title, Completed: true}); err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedTitle(t, s, todo.ID); !strings.HasPrefix(stored, sealedPrefix) {
+		t.Errorf("an update kept the legacy title %q", stored)
+	}
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// UserFeatures are aggregates over one owner's todos that the ML model
+// weighs a todo against: how reliably they finish things, how much is open
+// and how long their tagged work usually takes.
+type UserFeatures struct {
+	Todos          int64   `json:"todos"`
+	CompletionRate float64 `json:"completionRate"`
+	OpenTodos      int64   `json:"openTodos"`
+	// AverageMinutesByTag covers the todos with a duration.
+	AverageMinutesByTag map[string]float64 `json:"averageMinutesByTag"`
+	// DurationRatio is how tracked time compares with estimates (see
+	// EstimateAccuracy), overall and per tag; zero, or no tag, until
+	// minRatioTodos completed todos have both.
+	DurationRatio      float64            `json:"durationRatio,omitempty"`
+	DurationRatioByTag map[string]float64 `json:"durationRatioByTag,omitempty"`
+}
+
+// minRatioTodos is how many tracked todos a duration ratio needs before it
+// is a feature rather than noise.
+const minRatioTodos = 3
+
+// addDurationRatios sets the duration ratios of f from a.
+func (f *UserFeatures) addDurationRatios(a EstimateAccuracy) {
+	if a.Todos >= minRatioTodos {
+		f.DurationRatio = a.Ratio
+	}
+	for tag, acc := range a.ByTag {
+		if acc.Todos >= minRatioTodos {
+			if f.DurationRatioByTag == nil {
+				f.DurationRatioByTag = map[string]float64{}
+			}
+			f.DurationRatioByTag[tag] = acc.Ratio
+		}
+	}
+}
+
+// FeatureStore is implemented by backends that aggregate UserFeatures
+// without returning the todos themselves.
+type FeatureStore interface {
+	// UserFeatures aggregates the todos of the owner ctx is scoped to, or
+	// every todo when it is not.
+	UserFeatures(ctx context.Context) (UserFeatures, error)
+}
+
+// UserFeatures aggregates with two queries: the counts, and the average
+// duration per tag; EstimateAccuracy adds the duration ratios.
+func (s *SQLStore) UserFeatures(ctx context.Context) (UserFeatures, error) {
+	f := UserFeatures{AverageMinutesByTag: map[string]float64{}}
+	owned, ownerArgs := ownerCond(ctx, "t.owner_id", 0)
+	var completed int64
+	err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(
+		`SELECT COUNT(*), COALESCE(SUM(CASE WHEN t.completed THEN 1 ELSE 0 END), 0)
+		 FROM todos t WHERE 1 = 1`+owned), ownerArgs...).Scan(&f.Todos, &completed)
+	if err != nil {
+		return UserFeatures{}, fmt.Errorf("aggregate user features: %w", err)
+	}
+	f.OpenTodos = f.Todos - completed
+	if f.Todos > 0 {
+		f.CompletionRate = float64(completed) / float64(f.Todos)
+	}
+
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT tag.value, COUNT(*), SUM(t.duration_minutes)
+		 FROM todos t, `+s.dialect.eachTag("t.tags", "tag")+`
+		 WHERE t.duration_minutes > 0`+owned+` GROUP BY 1`), ownerArgs...)
+	if err != nil {
+		return UserFeatures{}, fmt.Errorf("aggregate user features: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		var todos, minutes int64
+		if err := rows.Scan(&tag, &todos, &minutes); err != nil {
+			return UserFeatures{}, err
+		}
+		f.AverageMinutesByTag[tag] = float64(minutes) / float64(todos)
+	}
+	if err := rows.Err(); err != nil {
+		return UserFeatures{}, err
+	}
+	acc, err := s.EstimateAccuracy(ctx)
+	if err != nil {
+		return UserFeatures{}, err
+	}
+	f.addDurationRatios(acc)
+	return f, nil
+}
+
+// UserFeatures aggregates in one pass over the todos, and another for the
+// duration ratios.
+func (s *BoltStore) UserFeatures(ctx context.Context) (UserFeatures, error) {
+	f := UserFeatures{AverageMinutesByTag: map[string]float64{}}
+	var completed int64
+	counts, minutes := map[string]int64{}, map[string]int64{}
+	err := s.StreamTodos(ctx, TodoFilter{}, func(t Todo) error {
+		f.Todos++
+		if t.Completed {
+			completed++
+		}
+		if t.DurationMinutes > 0 {
+			for _, tag := range t.Tags {
+				counts[tag]++
+				minutes[tag] += int64(t.DurationMinutes)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return UserFeatures{}, err
+	}
+	f.OpenTodos = f.Todos - completed
+	if f.Todos > 0 {
+		f.CompletionRate = float64(completed) / float64(f.Todos)
+	}
+	for tag, n := range counts {
+		f.AverageMinutesByTag[tag] = float64(minutes[tag]) / float64(n)
+	}
+	acc, err := s.EstimateAccuracy(ctx)
+	if err != nil {
+		return UserFeatures{}, err
+	}
+	f.addDurationRatios(acc)
+	return f, nil
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TodoFilter selects todos for list and bulk operations. Zero values match everything.
+type TodoFilter struct {
+	Completed *bool
+	Tag       string
+	// CreatedBefore keeps todos created strictly before this instant.
+	CreatedBefore time.Time
+	// UpdatedSince keeps todos last modified at or after this instant,
+	// UpdatedBefore those last modified strictly before it.
+	UpdatedSince, UpdatedBefore time.Time
+	// AvailableAt drops todos deferred past this instant (start date later).
+	AvailableAt time.Time
+	// DueAfter and DueBefore keep todos due strictly after and at or before
+	// these instants; todos without a due date never match either.
+	DueAfter, DueBefore time.Time
+	// ListID keeps the todos of one list.
+	ListID int64
+	// HideArchived drops the todos of archived lists. matches cannot tell
+	// which lists are archived; in-memory backends check it themselves.
+	HideArchived bool
+
+	// owner restricts the filter to one user's todos; see scoped.
+	owner *int64
+}
+
+// scoped restricts f to the owner ctx is scoped to, if any.
+func (f TodoFilter) scoped(ctx context.Context) TodoFilter {
+	if id, ok := OwnerFrom(ctx); ok {
+		f.owner = &id
+	}
+	return f
+}
+
+// where renders the filter as a SQL condition (without the WHERE keyword)
+// using $n placeholders starting after the first offset arguments, and returns
+// the matching arguments. An empty filter renders "TRUE".
+func (f TodoFilter) where(d dialect, offset int) (string, []any) {
+	var conds []string
+	var args []any
+	next := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(offset+len(args))
+	}
+	// Completion is rendered as a literal predicate rather than a bound
+	// parameter so the planner can match the partial "open items" index.
+	if f.Completed != nil {
+		if *f.Completed {
+			conds = append(conds, "completed")
+		} else {
+			conds = append(conds, "NOT completed")
+		}
+	}
+	if f.owner != nil {
+		conds = append(conds, "owner_id = "+next(*f.owner))
+	}
+	if f.ListID != 0 {
+		conds = append(conds, "list_id = "+next(f.ListID))
+	}
+	if f.HideArchived {
+		conds = append(conds, "(list_id IS NULL OR list_id NOT IN (SELECT id FROM lists WHERE archived))")
+	}
+	if f.Tag != "" {
+		conds = append(conds, d.hasTag(next(f.Tag)))
+	}
+	if !f.CreatedBefore.IsZero() {
+		conds = append(conds, "created_at < "+next(f.CreatedBefore.UTC()))
+	}
+	if !f.UpdatedSince.IsZero() {
+		conds = append(conds, "updated_at >= "+next(f.UpdatedSince.UTC()))
+	}
+	if !f.UpdatedBefore.IsZero() {
+		conds = append(conds, "updated_at < "+next(f.UpdatedBefore.UTC()))
+	}
+	if !f.AvailableAt.IsZero() {
+		conds = append(conds, "(start_at IS NULL OR start_at <= "+next(f.AvailableAt.UTC())+")")
+	}
+	if !f.DueAfter.IsZero() {
+		conds = append(conds, "due_at > "+next(f.DueAfter.UTC()))
+	}
+	if !f.DueBefore.IsZero() {
+		conds = append(conds, "due_at <= "+next(f.DueBefore.UTC()))
+	}
+	if len(conds) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// matches applies the filter in memory, for backends without a query language.
+func (f TodoFilter) matches(t Todo) bool {
+	if f.Completed != nil && t.Completed != *f.Completed {
+		return false
+	}
+	if f.owner != nil && t.OwnerID != *f.owner {
+		return false
+	}
+	if f.ListID != 0 && (t.ListID == nil || *t.ListID != f.ListID) {
+		return false
+	}
+	if f.Tag != "" && !slices.Contains(t.Tags, f.Tag) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !t.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if !f.UpdatedSince.IsZero() && t.UpdatedAt.Before(f.UpdatedSince) {
+		return false
+	}
+	if !f.UpdatedBefore.IsZero() && !t.UpdatedAt.Before(f.UpdatedBefore) {
+		return false
+	}
+	if !f.AvailableAt.IsZero() && t.StartAt != nil && t.StartAt.After(f.AvailableAt) {
+		return false
+	}
+	if !f.DueAfter.IsZero() && (t.DueAt == nil || !t.DueAt.After(f.DueAfter)) {
+		return false
+	}
+	if !f.DueBefore.IsZero() && (t.DueAt == nil || t.DueAt.After(f.DueBefore)) {
+		return false
+	}
+	return true
+}
+
+// placeholders returns "$start, $start+1, ..." for n arguments.
+func placeholders(start, n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = "$" + strconv.Itoa(start+i)
+	}
+	return strings.Join(parts, ", ")
+}
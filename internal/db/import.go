@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportTodo is a todo to import along with its original timestamps. A zero
+// CreatedAt means now; a zero UpdatedAt means CreatedAt.
+type ImportTodo struct {
+	Input     SaveTodoInput
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ImportResult counts what an import did.
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// Importer is implemented by backends that can import todos keeping their
+// timestamps. A todo with the same title and created_at as an existing one,
+// or an earlier one in the same import, is skipped, so importing an export
+// twice is harmless. Invalid todos fail the import with a *BulkError.
+type Importer interface {
+	ImportTodos(ctx context.Context, todos []ImportTodo) (ImportResult, error)
+}
+
+// prepareImport validates todos and returns a copy with the timestamps
+// defaulted to now, in UTC and truncated to the microseconds every backend
+// keeps, so they compare equal once stored.
+func prepareImport(todos []ImportTodo, now time.Time) ([]ImportTodo, error) {
+	out := make([]ImportTodo, len(todos))
+	for i, t := range todos {
+		if err := validateInput(t.Input); err != nil {
+			return nil, &BulkError{Index: i, Err: err}
+		}
+		t.CreatedAt = t.CreatedAt.UTC().Truncate(time.Microsecond)
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		t.UpdatedAt = t.UpdatedAt.UTC().Truncate(time.Microsecond)
+		if t.UpdatedAt.Before(t.CreatedAt) {
+			t.UpdatedAt = t.CreatedAt
+		}
+		out[i] = t
+	}
+	return out, nil
+}
+
+func importKey(title string, createdAt time.Time) string {
+	return strconv.FormatInt(createdAt.UTC().Truncate(time.Microsecond).UnixMicro(), 10) + "\x00" + title
+}
+
+// ImportTodos inserts todos in one transaction, bulkInsertRows at a time,
+// looking up each batch's possible duplicates by created_at first.
+func (s *SQLStore) ImportTodos(ctx context.Context, todos []ImportTodo) (ImportResult, error) {
+	todos, err := prepareImport(todos, s.now())
+	if err != nil {
+		return ImportResult{}, err
+	}
+	var res ImportResult
+	err = s.WithTx(ctx, func(tx *sql.Tx) error {
+		res = ImportResult{}
+		seen := make(map[string]bool)
+		for len(todos) > 0 {
+			batch := todos[:min(bulkInsertRows, len(todos))]
+			todos = todos[len(batch):]
+			if err := s.existingImportKeys(ctx, tx, batch, seen); err != nil {
+				return err
+			}
+			fresh := make([]ImportTodo, 0, len(batch))
+			for _, t := range batch {
+				key := importKey(t.Input.Title, t.CreatedAt)
+				if seen[key] {
+					res.Skipped++
+					continue
+				}
+				seen[key] = true
+				fresh = append(fresh, t)
+			}
+			if len(fresh) == 0 {
+				continue
+			}
+			if err := s.insertImported(ctx, tx, fresh); err != nil {
+				return err
+			}
+			res.Imported += len(fresh)
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	slog.InfoContext(ctx, "todo.imported", "imported", res.Imported, "skipped", res.Skipped)
+	return res, nil
+}
+
+// existingImportKeys adds the keys of stored todos created at the same
+// instants as any of batch to seen.
+func (s *SQLStore) existingImportKeys(ctx context.Context, tx *sql.Tx, batch []ImportTodo, seen map[string]bool) error {
+	args := make([]any, len(batch))
+	for i, t := range batch {
+		args[i] = t.CreatedAt
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", len(args))
+	rows, err := tx.QueryContext(ctx, s.dialect.rebind(
+		`SELECT id, owner_id, title, created_at FROM todos WHERE created_at IN (`+placeholders(1, len(args))+`)`+owned),
+		append(args, ownerArgs...)...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var owner sql.NullInt64
+		var title string
+		var created time.Time
+		if err := rows.Scan(&id, &owner, &title, &created); err != nil {
+			return err
+		}
+		if title, err = s.openTitle(ctx, id, owner.Int64, title); err != nil {
+			return err
+		}
+		seen[importKey(title, created)] = true
+	}
+	return rows.Err()
+}
+
+func (s *SQLStore) insertImported(ctx context.Context, tx *sql.Tx, todos []ImportTodo) error {
+	owner := ownerValue(ctx)
+	values := make([]string, 0, len(todos))
+	args := make([]any, 0, len(todos)*14)
+	uuids := make([]any, 0, len(todos))
+	titles := make(map[string]string, len(todos))
+	for _, t := range todos {
+		tagsJSON, err := encodeTags(t.Input.Tags)
+		if err != nil {
+			return err
+		}
+		reminders, err := encodeReminderOffsets(t.Input.ReminderOffsets)
+		if err != nil {
+			return err
+		}
+		uuid, err := s.newUUID()
+		if err != nil {
+			return err
+		}
+		in := t.Input
+		values = append(values, "("+placeholders(len(args)+1, 14)+")")
+		args = append(args, uuid, s.insertTitle(in.Title), in.Completed, string(tagsJSON), in.DurationMinutes, in.PriorityScore, in.DueAt, in.StartAt, in.Effort, reminders, in.Recurrence, owner, t.CreatedAt, t.UpdatedAt)
+		uuids = append(uuids, uuid)
+		titles[uuid] = in.Title
+	}
+	_, err := tx.ExecContext(ctx, s.dialect.rebind(
+		`INSERT INTO todos (uuid, title, completed, tags, duration_minutes, priority_score, due_at, start_at, effort, reminder_offsets, recurrence, owner_id, created_at, updated_at)
+		 VALUES `+strings.Join(values, ", ")),
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	// The imported rows are read back for their history, as ids are known
+	// only now.
+	created, err := s.queryTodos(ctx, tx, `SELECT `+todoColumns+` FROM todos WHERE uuid IN (`+placeholders(1, len(uuids))+`)`, uuids...)
+	if err != nil {
+		return err
+	}
+	inserted := make([]*Todo, len(created))
+	changes := make([]todoChange, len(created))
+	for i := range created {
+		inserted[i] = &created[i]
+		changes[i] = todoChange{action: ActionCreated, new: &created[i]}
+	}
+	if err := s.sealInserted(ctx, tx, titles, inserted...); err != nil {
+		return err
+	}
+	return s.recordChanges(ctx, tx, changes)
+}
@@ -0,0 +1,467 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrIntakeHookNotFound is returned when an intake hook does not exist, or
+// no hook has the token presented.
+var ErrIntakeHookNotFound = errors.New("intake hook not found")
+
+var intakeHooksBucket = []byte("intake_hooks")
+
+const (
+	maxIntakeHookName = 255
+	maxIntakeTemplate = 16 << 10
+)
+
+// IntakeHook creates a todo for its owner from each JSON payload posted to
+// /api/hooks/{token}, so alerting systems can file tasks. Only a hash of
+// the token is stored; Token is set just after it was generated.
+type IntakeHook struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Template is a text/template rendering a todo create request (JSON)
+	// from the payload; empty means the payload is that request itself.
+	Template   string     `json:"template"`
+	Enabled    bool       `json:"enabled"`
+	Token      string     `json:"token,omitempty"`
+	OwnerID    int64      `json:"ownerId,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Map renders the todo create request for body, the JSON posted to the
+// hook. The template is executed on the decoded body.
+func (h IntakeHook) Map(body []byte) ([]byte, error) {
+	if h.Template == "" {
+		return body, nil
+	}
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("decode intake payload: %w", err)
+	}
+	tmpl, err := template.New("intake").Funcs(webhookTemplateFuncs).Parse(h.Template)
+	if err != nil {
+		return nil, fmt.Errorf("parse intake template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("render intake template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("intake template did not render JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveIntakeHookInput represents the fields accepted for intake hook
+// create/update.
+type SaveIntakeHookInput struct {
+	Name     string
+	Template string
+	Enabled  bool
+}
+
+// IntakeHookStore is implemented by backends that support intake hooks.
+type IntakeHookStore interface {
+	ListIntakeHooks(ctx context.Context) ([]IntakeHook, error)
+	// CreateIntakeHook creates a hook with a new token, returned in Token.
+	CreateIntakeHook(ctx context.Context, input SaveIntakeHookInput) (IntakeHook, error)
+	UpdateIntakeHook(ctx context.Context, id int64, input SaveIntakeHookInput) (IntakeHook, error)
+	DeleteIntakeHook(ctx context.Context, id int64) error
+	// RotateIntakeHookToken replaces a hook's token, returned in Token; the
+	// old one stops working.
+	RotateIntakeHookToken(ctx context.Context, id int64) (IntakeHook, error)
+	// UseIntakeHook returns the hook token identifies, whoever owns it, and
+	// records that it was used.
+	UseIntakeHook(ctx context.Context, token string) (IntakeHook, error)
+}
+
+func validateIntakeHookInput(input *SaveIntakeHookInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(input.Name) > maxIntakeHookName {
+		return errors.New("name too long")
+	}
+	if len(input.Template) > maxIntakeTemplate {
+		return errors.New("template too long")
+	}
+	if input.Template != "" {
+		if _, err := template.New("intake").Funcs(webhookTemplateFuncs).Parse(input.Template); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	return nil
+}
+
+// newIntakeToken returns a random token and the hash stored for it.
+func newIntakeToken() (token, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate intake token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashIntakeToken(token), nil
+}
+
+func hashIntakeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const intakeHookColumns = `id, name, template, enabled, owner_id, created_at, updated_at, last_used_at`
+
+func scanIntakeHook(row rowScanner) (IntakeHook, error) {
+	var h IntakeHook
+	var tmpl sql.NullString
+	var owner sql.NullInt64
+	if err := row.Scan(&h.ID, &h.Name, &tmpl, &h.Enabled, &owner, &h.CreatedAt, &h.UpdatedAt, &h.LastUsedAt); err != nil {
+		return IntakeHook{}, err
+	}
+	h.Template, h.OwnerID = tmpl.String, owner.Int64
+	return h, nil
+}
+
+// ListIntakeHooks returns all intake hooks ordered by id.
+func (s *SQLStore) ListIntakeHooks(ctx context.Context) ([]IntakeHook, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 0)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT `+intakeHookColumns+` FROM intake_hooks WHERE TRUE`+owned+` ORDER BY id ASC`), ownerArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []IntakeHook{}
+	for rows.Next() {
+		h, err := scanIntakeHook(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) getIntakeHook(ctx context.Context, id int64) (IntakeHook, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	h, err := scanIntakeHook(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+intakeHookColumns+` FROM intake_hooks WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return IntakeHook{}, ErrIntakeHookNotFound
+	}
+	return h, err
+}
+
+// CreateIntakeHook creates a new intake hook.
+func (s *SQLStore) CreateIntakeHook(ctx context.Context, input SaveIntakeHookInput) (IntakeHook, error) {
+	if err := validateIntakeHookInput(&input); err != nil {
+		return IntakeHook{}, err
+	}
+	token, hash, err := newIntakeToken()
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	now := s.now()
+	insert := `INSERT INTO intake_hooks (name, token_hash, template, enabled, owner_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	args := []any{input.Name, hash, input.Template, input.Enabled, ownerValue(ctx), now, now}
+
+	var h IntakeHook
+	if s.dialect.returning {
+		if h, err = scanIntakeHook(s.SQL.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+intakeHookColumns), args...)); err != nil {
+			return IntakeHook{}, err
+		}
+	} else {
+		res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(insert), args...)
+		if err != nil {
+			return IntakeHook{}, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return IntakeHook{}, err
+		}
+		if h, err = s.getIntakeHook(ctx, id); err != nil {
+			return IntakeHook{}, err
+		}
+	}
+	h.Token = token
+	slog.InfoContext(ctx, "intake_hook.created", "id", h.ID)
+	return h, nil
+}
+
+// UpdateIntakeHook updates an intake hook by id; its token is kept.
+func (s *SQLStore) UpdateIntakeHook(ctx context.Context, id int64, input SaveIntakeHookInput) (IntakeHook, error) {
+	if err := validateIntakeHookInput(&input); err != nil {
+		return IntakeHook{}, err
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 5)
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(
+		`UPDATE intake_hooks SET name = $1, template = $2, enabled = $3, updated_at = $4 WHERE id = $5`+owned),
+		append([]any{input.Name, input.Template, input.Enabled, s.now(), id}, ownerArgs...)...,
+	)
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return IntakeHook{}, err
+	} else if n == 0 {
+		return IntakeHook{}, ErrIntakeHookNotFound
+	}
+	slog.InfoContext(ctx, "intake_hook.updated", "id", id)
+	return s.getIntakeHook(ctx, id)
+}
+
+// DeleteIntakeHook deletes an intake hook.
+func (s *SQLStore) DeleteIntakeHook(ctx context.Context, id int64) error {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`DELETE FROM intake_hooks WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		slog.InfoContext(ctx, "intake_hook.deleted", "id", id)
+	}
+	return nil
+}
+
+// RotateIntakeHookToken gives the intake hook a new token.
+func (s *SQLStore) RotateIntakeHookToken(ctx context.Context, id int64) (IntakeHook, error) {
+	token, hash, err := newIntakeToken()
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 3)
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`UPDATE intake_hooks SET token_hash = $1, updated_at = $2 WHERE id = $3`+owned),
+		append([]any{hash, s.now(), id}, ownerArgs...)...)
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return IntakeHook{}, err
+	} else if n == 0 {
+		return IntakeHook{}, ErrIntakeHookNotFound
+	}
+	h, err := s.getIntakeHook(ctx, id)
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	h.Token = token
+	slog.InfoContext(ctx, "intake_hook.rotated", "id", id)
+	return h, nil
+}
+
+// UseIntakeHook looks the intake hook up by the hash of token.
+func (s *SQLStore) UseIntakeHook(ctx context.Context, token string) (IntakeHook, error) {
+	hash := hashIntakeToken(token)
+	h, err := scanIntakeHook(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+intakeHookColumns+` FROM intake_hooks WHERE token_hash = $1`), hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return IntakeHook{}, ErrIntakeHookNotFound
+	}
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	now := s.now()
+	if _, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`UPDATE intake_hooks SET last_used_at = $1 WHERE id = $2`), now, h.ID); err != nil {
+		return IntakeHook{}, err
+	}
+	h.LastUsedAt = &now
+	return h, nil
+}
+
+// boltIntakeHook is an IntakeHook as stored, with the hash of its token.
+type boltIntakeHook struct {
+	IntakeHook
+	TokenHash string `json:"tokenHash"`
+}
+
+// ListIntakeHooks returns all intake hooks ordered by id.
+func (s *BoltStore) ListIntakeHooks(ctx context.Context) ([]IntakeHook, error) {
+	out := []IntakeHook{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(intakeHooksBucket).ForEach(func(_, v []byte) error {
+			var h boltIntakeHook
+			if err := json.Unmarshal(v, &h); err != nil {
+				return fmt.Errorf("decode intake hook: %w", err)
+			}
+			if visible(ctx, h.OwnerID) {
+				out = append(out, h.IntakeHook)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateIntakeHook creates a new intake hook.
+func (s *BoltStore) CreateIntakeHook(ctx context.Context, input SaveIntakeHookInput) (IntakeHook, error) {
+	if err := validateIntakeHookInput(&input); err != nil {
+		return IntakeHook{}, err
+	}
+	token, hash, err := newIntakeToken()
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	var h boltIntakeHook
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intakeHooksBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		h = boltIntakeHook{
+			IntakeHook: IntakeHook{ID: int64(seq), Name: input.Name, Template: input.Template, Enabled: input.Enabled, CreatedAt: now, UpdatedAt: now},
+			TokenHash:  hash,
+		}
+		h.OwnerID, _ = OwnerFrom(ctx)
+		return putBoltIntakeHook(b, h)
+	})
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	h.Token = token
+	slog.InfoContext(ctx, "intake_hook.created", "id", h.ID)
+	return h.IntakeHook, nil
+}
+
+// UpdateIntakeHook updates an intake hook by id; its token is kept.
+func (s *BoltStore) UpdateIntakeHook(ctx context.Context, id int64, input SaveIntakeHookInput) (IntakeHook, error) {
+	if err := validateIntakeHookInput(&input); err != nil {
+		return IntakeHook{}, err
+	}
+	h, err := s.updateBoltIntakeHook(ctx, id, func(h *boltIntakeHook) {
+		h.Name, h.Template, h.Enabled, h.UpdatedAt = input.Name, input.Template, input.Enabled, s.now()
+	})
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	slog.InfoContext(ctx, "intake_hook.updated", "id", id)
+	return h.IntakeHook, nil
+}
+
+// DeleteIntakeHook deletes an intake hook.
+func (s *BoltStore) DeleteIntakeHook(ctx context.Context, id int64) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intakeHooksBucket)
+		if _, err := getBoltIntakeHook(ctx, b, id); err != nil {
+			if errors.Is(err, ErrIntakeHookNotFound) {
+				return nil
+			}
+			return err
+		}
+		if err := b.Delete(boltKey(id)); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "intake_hook.deleted", "id", id)
+		return nil
+	})
+}
+
+// RotateIntakeHookToken gives the intake hook a new token.
+func (s *BoltStore) RotateIntakeHookToken(ctx context.Context, id int64) (IntakeHook, error) {
+	token, hash, err := newIntakeToken()
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	h, err := s.updateBoltIntakeHook(ctx, id, func(h *boltIntakeHook) {
+		h.TokenHash, h.UpdatedAt = hash, s.now()
+	})
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	h.Token = token
+	slog.InfoContext(ctx, "intake_hook.rotated", "id", id)
+	return h.IntakeHook, nil
+}
+
+// UseIntakeHook looks the intake hook up by the hash of token. Hooks are
+// few per deployment, so they are scanned rather than indexed.
+func (s *BoltStore) UseIntakeHook(ctx context.Context, token string) (IntakeHook, error) {
+	hash := hashIntakeToken(token)
+	var found boltIntakeHook
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intakeHooksBucket)
+		err := b.ForEach(func(_, v []byte) error {
+			var h boltIntakeHook
+			if err := json.Unmarshal(v, &h); err != nil {
+				return fmt.Errorf("decode intake hook: %w", err)
+			}
+			if h.TokenHash == hash {
+				found = h
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if found.ID == 0 {
+			return ErrIntakeHookNotFound
+		}
+		now := s.now()
+		found.LastUsedAt = &now
+		return putBoltIntakeHook(b, found)
+	})
+	if err != nil {
+		return IntakeHook{}, err
+	}
+	return found.IntakeHook, nil
+}
+
+// updateBoltIntakeHook applies fn to the intake hook id visible to ctx.
+func (s *BoltStore) updateBoltIntakeHook(ctx context.Context, id int64, fn func(*boltIntakeHook)) (boltIntakeHook, error) {
+	var h boltIntakeHook
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(intakeHooksBucket)
+		var err error
+		if h, err = getBoltIntakeHook(ctx, b, id); err != nil {
+			return err
+		}
+		fn(&h)
+		return putBoltIntakeHook(b, h)
+	})
+	return h, err
+}
+
+func getBoltIntakeHook(ctx context.Context, b *bolt.Bucket, id int64) (boltIntakeHook, error) {
+	v := b.Get(boltKey(id))
+	if v == nil {
+		return boltIntakeHook{}, ErrIntakeHookNotFound
+	}
+	var h boltIntakeHook
+	if err := json.Unmarshal(v, &h); err != nil {
+		return boltIntakeHook{}, fmt.Errorf("decode intake hook: %w", err)
+	}
+	if !visible(ctx, h.OwnerID) {
+		return boltIntakeHook{}, ErrIntakeHookNotFound
+	}
+	return h, nil
+}
+
+func putBoltIntakeHook(b *bolt.Bucket, h boltIntakeHook) error {
+	h.Token = ""
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("encode intake hook: %w", err)
+	}
+	return b.Put(boltKey(h.ID), data)
+}
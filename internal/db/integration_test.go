@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSQLDialects runs the same round trip against every SQL dialect
+// available: SQLite always, and PostgreSQL, MySQL/MariaDB and CockroachDB
+// when TEST_POSTGRES_DSN, TEST_MYSQL_DSN or TEST_COCKROACH_DSN name a
+// database (an empty one; the test migrates it down and up again). CI sets
+// them from service containers, see .github/workflows/ci.yml.
+func TestSQLDialects(t *testing.T) {
+	servers := []struct {
+		name string
+		env  string
+		open func(dsn string) (*SQLStore, error)
+	}{
+		{"postgres", "TEST_POSTGRES_DSN", func(dsn string) (*SQLStore, error) { return NewPostgresStore(dsn) }},
+		{"mysql", "TEST_MYSQL_DSN", func(dsn string) (*SQLStore, error) { return NewMySQLStore(dsn) }},
+		{"cockroach", "TEST_COCKROACH_DSN", func(dsn string) (*SQLStore, error) { return NewCockroachStore(dsn) }},
+	}
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "todo.sqlite"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		testDialect(t, s)
+	})
+	for _, srv := range servers {
+		t.Run(srv.name, func(t *testing.T) {
+			dsn := os.Getenv(srv.env)
+			if dsn == "" {
+				t.Skipf("%s is not set", srv.env)
+			}
+			s, err := srv.open(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = s.Close() })
+			testDialect(t, s)
+		})
+	}
+}
+
+func testDialect(t *testing.T, s *SQLStore) {
+	ctx := context.Background()
+	checkSchema := func(when string) {
+		t.Helper()
+		drift, err := s.CheckSchema(ctx)
+		if err != nil || len(drift) > 0 {
+			t.Fatalf("schema %s: %v %v", when, drift, err)
+		}
+	}
+	checkSchema("after opening")
+
+	todo, err := s.CreateTodo(ctx, SaveTodoInput{Title: "file taxes", Tags: []string{"home", "money"}, DurationMinutes: 45})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if todo.ID == 0 || todo.UUID == "" || todo.CreatedAt.IsZero() {
+		t.Fatalf("create returned %+v", todo)
+	}
+	if _, err := s.CreateTodo(ctx, SaveTodoInput{Title: "water plants", Completed: true, Tags: []string{"home"}}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	got, err := s.GetTodo(ctx, todo.ID)
+	if err != nil || got.Title != "file taxes" || len(got.Tags) != 2 || got.DurationMinutes != 45 {
+		t.Fatalf("get: %+v, %v", got, err)
+	}
+
+	open := false
+	items, err := s.ListTodosFiltered(ctx, TodoFilter{Completed: &open, Tag: "money"})
+	if err != nil || len(items) != 1 || items[0].ID != todo.ID {
+		t.Fatalf("open todos tagged money: %+v, %v", items, err)
+	}
+	updated, err := s.UpdateTodo(ctx, todo.ID, SaveTodoInput{Title: "file taxes", Completed: true, Tags: []string{"money"}})
+	if err != nil || !updated.Completed || updated.UpdatedAt.Before(todo.UpdatedAt) {
+		t.Fatalf("update: %+v, %v", updated, err)
+	}
+	if items, err := s.ListTodosFiltered(ctx, TodoFilter{Completed: &open}); err != nil || len(items) != 0 {
+		t.Fatalf("open todos after completing: %+v, %v", items, err)
+	}
+
+	const uuid = "7d3c1f3e-8f1a-4a57-9a0e-2b6a4f1c9d20"
+	first, created, err := s.UpsertTodoByUUID(ctx, uuid, SaveTodoInput{Title: "renew passport"})
+	if err != nil || !created {
+		t.Fatalf("first upsert: %+v, %v, %v", first, created, err)
+	}
+	// created is not checked here: it compares created_at with updated_at,
+	// which can still be equal when both calls land in the same tick.
+	second, _, err := s.UpsertTodoByUUID(ctx, uuid, SaveTodoInput{Title: "renew passport", Completed: true})
+	if err != nil || second.ID != first.ID || !second.Completed {
+		t.Fatalf("second upsert: %+v, %v", second, err)
+	}
+
+	if err := s.DeleteTodo(ctx, todo.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.GetTodo(ctx, todo.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after delete: %v", err)
+	}
+
+	statuses, err := s.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MigrateDown(ctx, len(statuses)); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if _, err := s.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("migrate up again: %v", err)
+	}
+	checkSchema("after migrating down and up")
+}
@@ -0,0 +1,843 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrListNotFound is returned when a list does not exist.
+var ErrListNotFound = errors.New("list not found")
+
+// ErrListLocked is returned when updating or deleting a list under legal hold.
+var ErrListLocked = errors.New("list is under legal hold")
+
+// ListIcons is the set of icon names a list may use. The frontend ships a
+// glyph for each, so arbitrary values would render as blanks.
+var ListIcons = []string{
+	"list", "inbox", "star", "flag", "heart", "home", "briefcase",
+	"cart", "book", "calendar", "bolt", "leaf",
+}
+
+var listColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// List groups todos under a name with optional presentation hints.
+type List struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Icon  string `json:"icon"`
+	// LegalHold makes the list immutable: it cannot be updated or deleted
+	// until an admin lifts the hold.
+	LegalHold bool `json:"legalHold"`
+	// Archived lists and their todos are left out of the default views.
+	Archived bool `json:"archived"`
+	// DefaultTags are added to the todos created through the list.
+	DefaultTags []string `json:"defaultTags,omitempty"`
+	// DefaultReminders are the reminder offsets of the todos created
+	// through the list that set none; nil leaves them to the server-wide
+	// defaults.
+	DefaultReminders []int     `json:"defaultReminders"`
+	OwnerID          int64     `json:"ownerId,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// SaveListInput represents the fields accepted for list create/update.
+type SaveListInput struct {
+	Name             string
+	Color            string
+	Icon             string
+	DefaultTags      []string
+	DefaultReminders []int
+}
+
+// ListDeletion says what DeleteList does with the todos of the list. The
+// zero value leaves them in no list.
+type ListDeletion struct {
+	// DeleteTodos deletes the todos along with the list.
+	DeleteTodos bool
+	// MoveTo, if not zero, moves the todos to that list instead.
+	MoveTo int64
+}
+
+func (d ListDeletion) validate(id int64) error {
+	if d.DeleteTodos && d.MoveTo != 0 {
+		return errors.New("todos cannot be both deleted and moved")
+	}
+	if d.MoveTo == id {
+		return errors.New("todos cannot be moved to the list being deleted")
+	}
+	return nil
+}
+
+// ListStore is implemented by backends that support lists. Todos join a
+// list through SaveTodoInput.ListID and are listed by TodoFilter.ListID.
+type ListStore interface {
+	ListLists(ctx context.Context) ([]List, error)
+	GetList(ctx context.Context, id int64) (List, error)
+	CreateList(ctx context.Context, input SaveListInput) (List, error)
+	UpdateList(ctx context.Context, id int64, input SaveListInput) (List, error)
+	// DeleteList deletes a list and deletes, moves or unassigns its todos
+	// as todos says. Moving to a list that does not exist fails with
+	// ErrListNotFound.
+	DeleteList(ctx context.Context, id int64, todos ListDeletion) error
+	// SetListHold places or lifts a legal hold on a list.
+	SetListHold(ctx context.Context, id int64, hold bool) (List, error)
+	// ArchiveList archives or restores a list.
+	ArchiveList(ctx context.Context, id int64, archived bool) (List, error)
+	// DuplicateList copies a list, named name, along with its open todos
+	// in one transaction, returning the copy and how many todos it got.
+	// Recurring todos are copied with their recurrence, so the copy can
+	// serve as a template.
+	DuplicateList(ctx context.Context, id int64, name string) (List, int, error)
+}
+
+// validateListInput checks and normalizes input. Colors are "#rrggbb" hex
+// and stored lower-case; empty color and icon mean "use the default".
+func validateListInput(input *SaveListInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Color = strings.ToLower(strings.TrimSpace(input.Color))
+	input.Icon = strings.TrimSpace(input.Icon)
+	if input.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	if len(input.Name) > 100 {
+		return errors.New("name too long")
+	}
+	if input.Color != "" && !listColorPattern.MatchString(input.Color) {
+		return errors.New("color must be a hex value like #1e90ff")
+	}
+	if input.Icon != "" && !slices.Contains(ListIcons, input.Icon) {
+		return fmt.Errorf("icon must be one of %s", strings.Join(ListIcons, ", "))
+	}
+	tags := make([]string, 0, len(input.DefaultTags))
+	for _, tag := range input.DefaultTags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		if len(tag) > 32 {
+			return errors.New("default tag too long")
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) > maxListDefaultTags {
+		return fmt.Errorf("at most %d default tags", maxListDefaultTags)
+	}
+	input.DefaultTags = tags
+	if err := validateReminderOffsets(input.DefaultReminders); err != nil {
+		return fmt.Errorf("default reminders: %w", err)
+	}
+	return nil
+}
+
+// maxListDefaultTags bounds the tags a list adds to its todos.
+const maxListDefaultTags = 10
+
+// input returns the input recreating l's settings under name.
+func (l List) input(name string) SaveListInput {
+	return SaveListInput{Name: name, Color: l.Color, Icon: l.Icon, DefaultTags: l.DefaultTags, DefaultReminders: l.DefaultReminders}
+}
+
+// listDefaultsArgs returns the column values of input's defaults.
+func listDefaultsArgs(input SaveListInput) (tags, reminders any, err error) {
+	tagsJSON, err := encodeTags(input.DefaultTags)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reminders, err = encodeReminderOffsets(input.DefaultReminders); err != nil {
+		return nil, nil, err
+	}
+	return string(tagsJSON), reminders, nil
+}
+
+const listColumns = `id, name, color, icon, legal_hold, archived, default_tags, default_reminders, owner_id, created_at, updated_at`
+
+func scanList(row rowScanner) (List, error) {
+	var l List
+	var tagsRaw, remindersRaw []byte
+	var owner sql.NullInt64
+	if err := row.Scan(&l.ID, &l.Name, &l.Color, &l.Icon, &l.LegalHold, &l.Archived, &tagsRaw, &remindersRaw, &owner, &l.CreatedAt, &l.UpdatedAt); err != nil {
+		return List{}, err
+	}
+	l.OwnerID = owner.Int64
+	if len(tagsRaw) > 0 {
+		if err := json.Unmarshal(tagsRaw, &l.DefaultTags); err != nil {
+			return List{}, fmt.Errorf("decode default tags: %w", err)
+		}
+	}
+	if remindersRaw != nil {
+		if err := json.Unmarshal(remindersRaw, &l.DefaultReminders); err != nil {
+			return List{}, fmt.Errorf("decode default reminders: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// ListLists returns all lists ordered by name.
+func (s *SQLStore) ListLists(ctx context.Context) ([]List, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 0)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT `+listColumns+` FROM lists WHERE TRUE`+owned+` ORDER BY name ASC, id ASC`), ownerArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []List{}
+	for rows.Next() {
+		l, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// GetList returns a list by id.
+func (s *SQLStore) GetList(ctx context.Context, id int64) (List, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	l, err := scanList(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+listColumns+` FROM lists WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return List{}, ErrListNotFound
+	}
+	return l, err
+}
+
+// CreateList creates a new list.
+func (s *SQLStore) CreateList(ctx context.Context, input SaveListInput) (List, error) {
+	if err := validateListInput(&input); err != nil {
+		return List{}, err
+	}
+	tags, reminders, err := listDefaultsArgs(input)
+	if err != nil {
+		return List{}, err
+	}
+	insert := `INSERT INTO lists (name, color, icon, default_tags, default_reminders, owner_id) VALUES ($1, $2, $3, $4, $5, $6)`
+	args := []any{input.Name, input.Color, input.Icon, tags, reminders, ownerValue(ctx)}
+
+	var l List
+	if s.dialect.returning {
+		if l, err = scanList(s.SQL.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+listColumns), args...)); err != nil {
+			return List{}, err
+		}
+	} else {
+		res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(insert), args...)
+		if err != nil {
+			return List{}, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return List{}, err
+		}
+		if l, err = s.GetList(ctx, id); err != nil {
+			return List{}, err
+		}
+	}
+	slog.InfoContext(ctx, "list.created", "id", l.ID, "name", l.Name)
+	return l, nil
+}
+
+// UpdateList updates a list by id. Lists under legal hold are left as they
+// are.
+func (s *SQLStore) UpdateList(ctx context.Context, id int64, input SaveListInput) (List, error) {
+	if err := validateListInput(&input); err != nil {
+		return List{}, err
+	}
+	tags, reminders, err := listDefaultsArgs(input)
+	if err != nil {
+		return List{}, err
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 6)
+	update := `UPDATE lists SET name = $1, color = $2, icon = $3, default_tags = $4, default_reminders = $5, updated_at = ` + s.dialect.now + ` WHERE id = $6 AND legal_hold = FALSE` + owned
+	args := append([]any{input.Name, input.Color, input.Icon, tags, reminders, id}, ownerArgs...)
+
+	var l List
+	if s.dialect.returning {
+		l, err = scanList(s.SQL.QueryRowContext(ctx, s.dialect.rebind(update+` RETURNING `+listColumns), args...))
+		if errors.Is(err, sql.ErrNoRows) {
+			return List{}, s.listUnchanged(ctx, id)
+		}
+		if err != nil {
+			return List{}, err
+		}
+	} else {
+		res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(update), args...)
+		if err != nil {
+			return List{}, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return List{}, s.listUnchanged(ctx, id)
+		}
+		if l, err = s.GetList(ctx, id); err != nil {
+			return List{}, err
+		}
+	}
+	slog.InfoContext(ctx, "list.updated", "id", l.ID, "name", l.Name)
+	return l, nil
+}
+
+// DeleteList deletes a list by id, unless it is under legal hold. Its todos
+// are dealt with first in the same transaction, bumping updated_at of those
+// kept; the foreign key only unassigns todos added concurrently.
+func (s *SQLStore) DeleteList(ctx context.Context, id int64, todos ListDeletion) error {
+	if err := todos.validate(id); err != nil {
+		return err
+	}
+	var n int64
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		n = 0
+		owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+		var hold bool
+		err := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT legal_hold FROM lists WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...).Scan(&hold)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hold {
+			return ErrListLocked
+		}
+		switch {
+		case todos.DeleteTodos:
+			_, err = s.rewriteTodos(ctx, tx, `list_id = $1`, []any{id}, `DELETE FROM todos`, nil)
+		case todos.MoveTo != 0:
+			if err = s.checkListRef(ctx, tx, &todos.MoveTo); err != nil {
+				return err
+			}
+			_, err = s.rewriteTodos(ctx, tx, `list_id = $1`, []any{id}, `UPDATE todos SET list_id = $1, updated_at = $2`, []any{todos.MoveTo, s.now()})
+		default:
+			_, err = s.rewriteTodos(ctx, tx, `list_id = $1`, []any{id}, `UPDATE todos SET list_id = NULL, updated_at = $1`, []any{s.now()})
+		}
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM lists WHERE id = $1`), id)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	// The todos changed in bulk, so cached ones may be stale.
+	s.cache.clear()
+	if err != nil || n == 0 {
+		return err
+	}
+	slog.InfoContext(ctx, "list.deleted", "id", id, "delete_todos", todos.DeleteTodos, "move_to", todos.MoveTo)
+	return nil
+}
+
+// SetListHold places or lifts a legal hold on a list.
+func (s *SQLStore) SetListHold(ctx context.Context, id int64, hold bool) (List, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 2)
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`UPDATE lists SET legal_hold = $1, updated_at = `+s.dialect.now+` WHERE id = $2`+owned), append([]any{hold, id}, ownerArgs...)...)
+	if err != nil {
+		return List{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return List{}, ErrListNotFound
+	}
+	l, err := s.GetList(ctx, id)
+	if err != nil {
+		return List{}, err
+	}
+	slog.InfoContext(ctx, "list.hold_changed", "id", id, "legal_hold", hold)
+	return l, nil
+}
+
+// ArchiveList archives or restores a list. Archiving only hides, so lists
+// under legal hold may be archived too.
+func (s *SQLStore) ArchiveList(ctx context.Context, id int64, archived bool) (List, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 2)
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`UPDATE lists SET archived = $1, updated_at = `+s.dialect.now+` WHERE id = $2`+owned), append([]any{archived, id}, ownerArgs...)...)
+	if err != nil {
+		return List{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return List{}, ErrListNotFound
+	}
+	l, err := s.GetList(ctx, id)
+	if err != nil {
+		return List{}, err
+	}
+	slog.InfoContext(ctx, "list.archive_changed", "id", id, "archived", archived)
+	return l, nil
+}
+
+// duplicateName names the copy of a list when no name is given.
+func duplicateName(name string) string {
+	copyName := name + " (copy)"
+	if len(copyName) > 100 {
+		copyName = name[:100-len(" (copy)")] + " (copy)"
+	}
+	return copyName
+}
+
+// duplicateOps returns the BulkCreate ops copying todos into list id.
+func duplicateOps(todos []Todo, id int64) []BulkOp {
+	ops := make([]BulkOp, len(todos))
+	for i, t := range todos {
+		ops[i] = BulkOp{Action: BulkCreate, Input: SaveTodoInput{
+			Title:           t.Title,
+			Tags:            t.Tags,
+			DurationMinutes: t.DurationMinutes,
+			PriorityScore:   t.PriorityScore,
+			DueAt:           t.DueAt,
+			StartAt:         t.StartAt,
+			Effort:          t.Effort,
+			ReminderOffsets: t.ReminderOffsets,
+			Recurrence:      t.Recurrence,
+			ListID:          &id,
+		}}
+	}
+	return ops
+}
+
+// DuplicateList copies a list and its open todos in one transaction. The
+// copy is neither archived nor under legal hold.
+func (s *SQLStore) DuplicateList(ctx context.Context, id int64, name string) (List, int, error) {
+	src, err := s.GetList(ctx, id)
+	if err != nil {
+		return List{}, 0, err
+	}
+	if name == "" {
+		name = duplicateName(src.Name)
+	}
+	input := src.input(name)
+	if err := validateListInput(&input); err != nil {
+		return List{}, 0, err
+	}
+	tags, reminders, err := listDefaultsArgs(input)
+	if err != nil {
+		return List{}, 0, err
+	}
+	var l List
+	var copies []Todo
+	err = s.WithTx(ctx, func(tx *sql.Tx) error {
+		insert := `INSERT INTO lists (name, color, icon, default_tags, default_reminders, owner_id) VALUES ($1, $2, $3, $4, $5, $6)`
+		args := []any{input.Name, input.Color, input.Icon, tags, reminders, ownerValue(ctx)}
+		if s.dialect.returning {
+			if l, err = scanList(tx.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+listColumns), args...)); err != nil {
+				return err
+			}
+		} else {
+			res, err := tx.ExecContext(ctx, s.dialect.rebind(insert), args...)
+			if err != nil {
+				return err
+			}
+			newID, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			if l, err = scanList(tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+listColumns+` FROM lists WHERE id = $1`), newID)); err != nil {
+				return err
+			}
+		}
+		owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+		open, err := s.queryTodos(ctx, tx, `SELECT `+todoColumns+` FROM todos WHERE list_id = $1 AND NOT completed`+owned+` ORDER BY id ASC`, append([]any{id}, ownerArgs...)...)
+		if err != nil {
+			return err
+		}
+		ops := duplicateOps(open, l.ID)
+		copies = make([]Todo, len(ops))
+		for start := 0; start < len(ops); start += bulkInsertRows {
+			batch := make([]int, 0, bulkInsertRows)
+			for i := start; i < min(start+bulkInsertRows, len(ops)); i++ {
+				batch = append(batch, i)
+			}
+			if err := s.bulkInsert(ctx, tx, ops, batch, copies); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return List{}, 0, err
+	}
+	for _, t := range copies {
+		s.cache.put(t)
+	}
+	slog.InfoContext(ctx, "list.duplicated", "id", id, "copy_id", l.ID, "todos", len(copies))
+	return l, len(copies), nil
+}
+
+// listUnchanged explains why a write to list id matched no row: it is
+// either missing or under legal hold.
+func (s *SQLStore) listUnchanged(ctx context.Context, id int64) error {
+	l, err := s.GetList(ctx, id)
+	if err != nil {
+		return err
+	}
+	if l.LegalHold {
+		return ErrListLocked
+	}
+	return ErrListNotFound
+}
+
+// rowQuerier is a *sql.DB or *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// checkListRef fails with ErrListNotFound unless id is nil or names a list
+// visible to ctx, so todos cannot join another user's list.
+func (s *SQLStore) checkListRef(ctx context.Context, q rowQuerier, id *int64) error {
+	if id == nil {
+		return nil
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	var one int
+	err := q.QueryRowContext(ctx, s.dialect.rebind(`SELECT 1 FROM lists WHERE id = $1`+owned), append([]any{*id}, ownerArgs...)...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrListNotFound
+	}
+	return err
+}
+
+var listsBucket = []byte("lists")
+
+// ListLists returns all lists ordered by name.
+func (s *BoltStore) ListLists(ctx context.Context) ([]List, error) {
+	out := []List{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(listsBucket).ForEach(func(_, v []byte) error {
+			var l List
+			if err := json.Unmarshal(v, &l); err != nil {
+				return fmt.Errorf("decode list: %w", err)
+			}
+			if visible(ctx, l.OwnerID) {
+				out = append(out, l)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(out, func(a, b List) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+// GetList returns a list by id.
+func (s *BoltStore) GetList(ctx context.Context, id int64) (List, error) {
+	var l List
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(listsBucket).Get(boltKey(id))
+		if v == nil {
+			return ErrListNotFound
+		}
+		if err := json.Unmarshal(v, &l); err != nil {
+			return err
+		}
+		if !visible(ctx, l.OwnerID) {
+			return ErrListNotFound
+		}
+		return nil
+	})
+	return l, err
+}
+
+// CreateList creates a new list.
+func (s *BoltStore) CreateList(ctx context.Context, input SaveListInput) (List, error) {
+	if err := validateListInput(&input); err != nil {
+		return List{}, err
+	}
+	var l List
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(listsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		l = List{ID: int64(seq), Name: input.Name, Color: input.Color, Icon: input.Icon, DefaultTags: input.DefaultTags, DefaultReminders: input.DefaultReminders, CreatedAt: now, UpdatedAt: now}
+		l.OwnerID, _ = OwnerFrom(ctx)
+		return putBoltList(b, l)
+	})
+	if err != nil {
+		return List{}, err
+	}
+	slog.InfoContext(ctx, "list.created", "id", l.ID, "name", l.Name)
+	return l, nil
+}
+
+// UpdateList updates a list by id.
+func (s *BoltStore) UpdateList(ctx context.Context, id int64, input SaveListInput) (List, error) {
+	if err := validateListInput(&input); err != nil {
+		return List{}, err
+	}
+	var l List
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(listsBucket)
+		v := b.Get(boltKey(id))
+		if v == nil {
+			return ErrListNotFound
+		}
+		if err := json.Unmarshal(v, &l); err != nil {
+			return err
+		}
+		if !visible(ctx, l.OwnerID) {
+			return ErrListNotFound
+		}
+		if l.LegalHold {
+			return ErrListLocked
+		}
+		l.Name, l.Color, l.Icon = input.Name, input.Color, input.Icon
+		l.DefaultTags, l.DefaultReminders = input.DefaultTags, input.DefaultReminders
+		l.UpdatedAt = time.Now().UTC()
+		return putBoltList(b, l)
+	})
+	if err != nil {
+		return List{}, err
+	}
+	slog.InfoContext(ctx, "list.updated", "id", l.ID, "name", l.Name)
+	return l, nil
+}
+
+// DeleteList deletes a list by id and deletes, moves or unassigns its todos.
+func (s *BoltStore) DeleteList(ctx context.Context, id int64, todos ListDeletion) error {
+	if err := todos.validate(id); err != nil {
+		return err
+	}
+	var deleted bool
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		deleted = false
+		b := tx.Bucket(listsBucket)
+		v := b.Get(boltKey(id))
+		if v == nil {
+			return nil
+		}
+		var l List
+		if err := json.Unmarshal(v, &l); err != nil {
+			return err
+		}
+		if !visible(ctx, l.OwnerID) {
+			return nil
+		}
+		if l.LegalHold {
+			return ErrListLocked
+		}
+		if todos.MoveTo != 0 {
+			if err := boltListRef(ctx, tx, &todos.MoveTo); err != nil {
+				return err
+			}
+		}
+		if err := s.updateBoltListTodos(ctx, tx, id, todos); err != nil {
+			return err
+		}
+		if err := deleteBoltListSnapshots(tx, id); err != nil {
+			return err
+		}
+		deleted = true
+		return b.Delete(boltKey(id))
+	})
+	if err != nil || !deleted {
+		return err
+	}
+	slog.InfoContext(ctx, "list.deleted", "id", id, "delete_todos", todos.DeleteTodos, "move_to", todos.MoveTo)
+	return nil
+}
+
+// updateBoltListTodos deletes, moves or unassigns the todos of list id.
+func (s *BoltStore) updateBoltListTodos(ctx context.Context, tx *bolt.Tx, id int64, todos ListDeletion) error {
+	b := tx.Bucket(todosBucket)
+	var members []Todo
+	err := b.ForEach(func(_, v []byte) error {
+		t, err := decodeBoltTodo(v)
+		if err != nil {
+			return err
+		}
+		if t.ListID != nil && *t.ListID == id {
+			members = append(members, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Todos are collected first: writing during ForEach skips entries.
+	now := s.now()
+	for _, t := range members {
+		old := t
+		if todos.DeleteTodos {
+			if err := b.Delete(boltKey(t.ID)); err != nil {
+				return err
+			}
+			if err := s.recordBoltChange(ctx, tx, ActionDeleted, &old, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		t.ListID = nil
+		if todos.MoveTo != 0 {
+			t.ListID = &todos.MoveTo
+		}
+		t.UpdatedAt = now
+		if err := putBoltTodo(b, t); err != nil {
+			return err
+		}
+		if err := s.recordBoltChange(ctx, tx, ActionUpdated, &old, &t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltListRef fails with ErrListNotFound unless id is nil or names a list
+// visible to ctx.
+func boltListRef(ctx context.Context, tx *bolt.Tx, id *int64) error {
+	if id == nil {
+		return nil
+	}
+	v := tx.Bucket(listsBucket).Get(boltKey(*id))
+	if v == nil {
+		return ErrListNotFound
+	}
+	var l List
+	if err := json.Unmarshal(v, &l); err != nil {
+		return err
+	}
+	if !visible(ctx, l.OwnerID) {
+		return ErrListNotFound
+	}
+	return nil
+}
+
+// SetListHold places or lifts a legal hold on a list.
+func (s *BoltStore) SetListHold(ctx context.Context, id int64, hold bool) (List, error) {
+	var l List
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(listsBucket)
+		v := b.Get(boltKey(id))
+		if v == nil {
+			return ErrListNotFound
+		}
+		if err := json.Unmarshal(v, &l); err != nil {
+			return err
+		}
+		if !visible(ctx, l.OwnerID) {
+			return ErrListNotFound
+		}
+		l.LegalHold = hold
+		l.UpdatedAt = time.Now().UTC()
+		return putBoltList(b, l)
+	})
+	if err != nil {
+		return List{}, err
+	}
+	slog.InfoContext(ctx, "list.hold_changed", "id", id, "legal_hold", hold)
+	return l, nil
+}
+
+// ArchiveList archives or restores a list.
+func (s *BoltStore) ArchiveList(ctx context.Context, id int64, archived bool) (List, error) {
+	var l List
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(listsBucket)
+		v := b.Get(boltKey(id))
+		if v == nil {
+			return ErrListNotFound
+		}
+		if err := json.Unmarshal(v, &l); err != nil {
+			return err
+		}
+		if !visible(ctx, l.OwnerID) {
+			return ErrListNotFound
+		}
+		l.Archived = archived
+		l.UpdatedAt = time.Now().UTC()
+		return putBoltList(b, l)
+	})
+	if err != nil {
+		return List{}, err
+	}
+	slog.InfoContext(ctx, "list.archive_changed", "id", id, "archived", archived)
+	return l, nil
+}
+
+// DuplicateList copies a list and its open todos in one write transaction.
+func (s *BoltStore) DuplicateList(ctx context.Context, id int64, name string) (List, int, error) {
+	var l List
+	var n int
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(listsBucket)
+		v := b.Get(boltKey(id))
+		if v == nil {
+			return ErrListNotFound
+		}
+		var src List
+		if err := json.Unmarshal(v, &src); err != nil {
+			return err
+		}
+		if !visible(ctx, src.OwnerID) {
+			return ErrListNotFound
+		}
+		if name == "" {
+			name = duplicateName(src.Name)
+		}
+		input := src.input(name)
+		if err := validateListInput(&input); err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		l = List{ID: int64(seq), Name: input.Name, Color: input.Color, Icon: input.Icon, DefaultTags: input.DefaultTags, DefaultReminders: input.DefaultReminders, CreatedAt: now, UpdatedAt: now}
+		l.OwnerID, _ = OwnerFrom(ctx)
+		if err := putBoltList(b, l); err != nil {
+			return err
+		}
+
+		var open []Todo
+		err = tx.Bucket(todosBucket).ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if t.ListID != nil && *t.ListID == id && !t.Completed && visible(ctx, t.OwnerID) {
+				open = append(open, t)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Todos are collected first: writing during ForEach skips entries.
+		todoNow := s.now()
+		for _, op := range duplicateOps(open, l.ID) {
+			var t Todo
+			if err := s.applyBoltOp(ctx, tx, op, l.OwnerID, todoNow, &t); err != nil {
+				return err
+			}
+		}
+		n = len(open)
+		return nil
+	})
+	if err != nil {
+		return List{}, 0, err
+	}
+	slog.InfoContext(ctx, "list.duplicated", "id", id, "copy_id", l.ID, "todos", n)
+	return l, n, nil
+}
+
+func putBoltList(b *bolt.Bucket, l List) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("encode list: %w", err)
+	}
+	return b.Put(boltKey(l.ID), data)
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"todoapp/internal/db/migrations"
+)
+
+// MigrationStatus is one schema version and when it was applied, if it was.
+type MigrationStatus struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	// AppliedAt is nil for pending versions.
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Migrator is implemented by backends with a versioned schema. Applied
+// versions are recorded in the schema_migrations table.
+type Migrator interface {
+	// MigrateUp applies pending migrations up to and including target, or
+	// all of them when target is 0, and returns the versions applied.
+	MigrateUp(ctx context.Context, target int64) ([]int64, error)
+	// MigrateDown reverts the steps most recently applied migrations and
+	// returns the versions reverted.
+	MigrateDown(ctx context.Context, steps int) ([]int64, error)
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+}
+
+// migrate brings the schema up to date when the store is opened.
+func (s *SQLStore) migrate() error {
+	if _, err := s.MigrateUp(context.Background(), 0); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
+// MigrateUp applies pending migrations in version order.
+func (s *SQLStore) MigrateUp(ctx context.Context, target int64) ([]int64, error) {
+	var done []int64
+	err := s.withMigrations(ctx, func(conn *sql.Conn, all []migrations.Migration, applied map[int64]time.Time) error {
+		if target > int64(len(all)) {
+			return fmt.Errorf("unknown schema version %d (latest is %d)", target, len(all))
+		}
+		for _, m := range all {
+			if target > 0 && m.Version > target {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			for _, stmt := range m.Up {
+				if _, err := conn.ExecContext(ctx, stmt); err != nil {
+					// MySQL has no IF NOT EXISTS for columns and indexes, so
+					// re-running an interrupted migration reports a duplicate.
+					if isDuplicateObject(err) {
+						continue
+					}
+					return fmt.Errorf("version %d (%s): %w", m.Version, m.Name, err)
+				}
+			}
+			record := s.dialect.insertIgnore(`INSERT INTO schema_migrations (version) VALUES ($1)`)
+			if _, err := conn.ExecContext(ctx, s.dialect.rebind(record), m.Version); err != nil {
+				return fmt.Errorf("record version %d: %w", m.Version, err)
+			}
+			slog.InfoContext(ctx, "schema.migrated", "version", m.Version, "name", m.Name, "direction", "up")
+			done = append(done, m.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverts the most recently applied migrations, newest first.
+func (s *SQLStore) MigrateDown(ctx context.Context, steps int) ([]int64, error) {
+	var done []int64
+	err := s.withMigrations(ctx, func(conn *sql.Conn, all []migrations.Migration, applied map[int64]time.Time) error {
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		slices.Sort(versions)
+		slices.Reverse(versions)
+		for _, v := range versions[:min(steps, len(versions))] {
+			if v > int64(len(all)) {
+				return fmt.Errorf("version %d was applied by a newer release and cannot be reverted by this one", v)
+			}
+			m := all[v-1]
+			if m.Down == nil {
+				return fmt.Errorf("version %d (%s) cannot be reverted", m.Version, m.Name)
+			}
+			for _, stmt := range m.Down {
+				if _, err := conn.ExecContext(ctx, stmt); err != nil {
+					if isMissingObject(err) {
+						continue
+					}
+					return fmt.Errorf("revert version %d (%s): %w", m.Version, m.Name, err)
+				}
+			}
+			if _, err := conn.ExecContext(ctx, s.dialect.rebind(`DELETE FROM schema_migrations WHERE version = $1`), m.Version); err != nil {
+				return fmt.Errorf("unrecord version %d: %w", m.Version, err)
+			}
+			slog.InfoContext(ctx, "schema.migrated", "version", m.Version, "name", m.Name, "direction", "down")
+			done = append(done, m.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatus lists every known version, plus any applied version this
+// release does not know about, in version order.
+func (s *SQLStore) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	var out []MigrationStatus
+	err := s.withMigrations(ctx, func(_ *sql.Conn, all []migrations.Migration, applied map[int64]time.Time) error {
+		for _, m := range all {
+			st := MigrationStatus{Version: m.Version, Name: m.Name}
+			if at, ok := applied[m.Version]; ok {
+				st.AppliedAt = &at
+			}
+			out = append(out, st)
+		}
+		for v, at := range applied {
+			if v > int64(len(all)) {
+				out = append(out, MigrationStatus{Version: v, AppliedAt: &at})
+			}
+		}
+		slices.SortFunc(out, func(a, b MigrationStatus) int { return cmp.Compare(a.Version, b.Version) })
+		return nil
+	})
+	return out, err
+}
+
+// withMigrations runs fn on a dedicated connection holding the migration
+// lock, with the dialect's migrations and the applied versions.
+func (s *SQLStore) withMigrations(ctx context.Context, fn func(conn *sql.Conn, all []migrations.Migration, applied map[int64]time.Time) error) error {
+	all, err := migrations.For(s.dialect.name)
+	if err != nil {
+		return err
+	}
+	conn, err := s.SQL.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Advisory locks belong to the session, so they are taken and released
+	// on the same connection. Without one, replicas racing through the same
+	// migration rely on the statements being idempotent.
+	if s.dialect.lockMigrations != "" {
+		if _, err := conn.ExecContext(ctx, s.dialect.lockMigrations); err != nil {
+			return fmt.Errorf("lock migrations: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), s.dialect.unlockMigrations); err != nil {
+				slog.Warn("schema.unlock_failed", "error", err)
+			}
+		}()
+	}
+	if _, err := conn.ExecContext(ctx, s.dialect.migrationsTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, all, applied)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var v int64
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at.UTC()
+	}
+	return applied, rows.Err()
+}
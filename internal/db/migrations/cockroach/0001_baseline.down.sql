@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS todos;
+
+DROP SEQUENCE IF EXISTS todos_id_seq;
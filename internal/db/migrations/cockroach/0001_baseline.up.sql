@@ -0,0 +1,14 @@
+CREATE SEQUENCE IF NOT EXISTS todos_id_seq;
+
+CREATE TABLE IF NOT EXISTS todos (
+	id INT8 PRIMARY KEY DEFAULT nextval('todos_id_seq'),
+	title STRING NOT NULL,
+	completed BOOL NOT NULL DEFAULT FALSE,
+	tags JSONB NOT NULL DEFAULT '[]'::JSONB,
+	duration_minutes INT4 NOT NULL DEFAULT 0,
+	priority_score FLOAT8 NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_todos_completed ON todos(completed);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos@idx_todos_uuid CASCADE;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS uuid;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS todos@idx_todos_open_created;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS inbound_webhooks;
+
+DROP SEQUENCE IF EXISTS inbound_webhooks_id_seq;
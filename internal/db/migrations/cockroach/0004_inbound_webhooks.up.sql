@@ -0,0 +1,11 @@
+CREATE SEQUENCE IF NOT EXISTS inbound_webhooks_id_seq;
+
+CREATE TABLE IF NOT EXISTS inbound_webhooks (
+	id INT8 PRIMARY KEY DEFAULT nextval('inbound_webhooks_id_seq'),
+	provider STRING NOT NULL,
+	delivery_id STRING NOT NULL,
+	event STRING NOT NULL DEFAULT '',
+	payload STRING NOT NULL,
+	received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (provider, delivery_id)
+);
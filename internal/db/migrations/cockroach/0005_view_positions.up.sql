@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS todo_view_positions (
+	view_key STRING NOT NULL,
+	todo_id INT8 NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	sort_order INT4 NOT NULL,
+	pinned BOOL NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (view_key, todo_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE todos DROP COLUMN IF EXISTS start_at;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS due_at;
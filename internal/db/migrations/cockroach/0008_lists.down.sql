@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS lists;
+
+DROP SEQUENCE IF EXISTS lists_id_seq;
@@ -0,0 +1,10 @@
+CREATE SEQUENCE IF NOT EXISTS lists_id_seq;
+
+CREATE TABLE IF NOT EXISTS lists (
+	id INT8 PRIMARY KEY DEFAULT nextval('lists_id_seq'),
+	name STRING NOT NULL,
+	color STRING NOT NULL DEFAULT '',
+	icon STRING NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS todos@idx_todos_created_id;
+
+DROP INDEX IF EXISTS todos@idx_todos_duration_id;
+
+DROP INDEX IF EXISTS todos@idx_todos_priority_id;
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS lists@idx_lists_owner;
+
+DROP INDEX IF EXISTS todos@idx_todos_owner_created;
+
+-- Todos and lists created by accounts become unowned rather than deleted.
+ALTER TABLE lists DROP COLUMN IF EXISTS owner_id;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS users;
+
+DROP SEQUENCE IF EXISTS users_id_seq;
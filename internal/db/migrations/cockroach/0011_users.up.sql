@@ -0,0 +1,16 @@
+CREATE SEQUENCE IF NOT EXISTS users_id_seq;
+
+CREATE TABLE IF NOT EXISTS users (
+	id INT8 PRIMARY KEY DEFAULT nextval('users_id_seq'),
+	email STRING NOT NULL UNIQUE,
+	password_hash STRING NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE lists ADD COLUMN IF NOT EXISTS owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_todos_owner_created ON todos(owner_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_lists_owner ON lists(owner_id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS rule_runs;
+
+DROP SEQUENCE IF EXISTS rule_runs_id_seq;
+
+DROP TABLE IF EXISTS rules;
+
+DROP SEQUENCE IF EXISTS rules_id_seq;
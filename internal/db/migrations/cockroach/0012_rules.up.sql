@@ -0,0 +1,31 @@
+CREATE SEQUENCE IF NOT EXISTS rules_id_seq;
+
+CREATE TABLE IF NOT EXISTS rules (
+	id INT8 PRIMARY KEY DEFAULT nextval('rules_id_seq'),
+	name STRING NOT NULL,
+	trigger_event STRING NOT NULL,
+	due_within_minutes INT4 NOT NULL DEFAULT 0,
+	conditions JSONB NOT NULL DEFAULT '[]'::JSONB,
+	actions JSONB NOT NULL DEFAULT '[]'::JSONB,
+	enabled BOOL NOT NULL DEFAULT TRUE,
+	owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rules_owner ON rules(owner_id);
+
+CREATE SEQUENCE IF NOT EXISTS rule_runs_id_seq;
+
+-- todo_id is not a foreign key: the log outlives the todos it mentions.
+CREATE TABLE IF NOT EXISTS rule_runs (
+	id INT8 PRIMARY KEY DEFAULT nextval('rule_runs_id_seq'),
+	rule_id INT8 NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+	todo_id INT8 NOT NULL,
+	trigger_event STRING NOT NULL,
+	status STRING NOT NULL,
+	detail STRING NOT NULL DEFAULT '',
+	ran_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_runs_rule ON rule_runs(rule_id, id);
@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS recurrence STRING NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+
+DROP SEQUENCE IF EXISTS webhook_deliveries_id_seq;
+
+DROP TABLE IF EXISTS webhooks;
+
+DROP SEQUENCE IF EXISTS webhooks_id_seq;
@@ -0,0 +1,33 @@
+CREATE SEQUENCE IF NOT EXISTS webhooks_id_seq;
+
+CREATE TABLE IF NOT EXISTS webhooks (
+	id INT8 PRIMARY KEY DEFAULT nextval('webhooks_id_seq'),
+	url STRING NOT NULL,
+	secret STRING NOT NULL DEFAULT '',
+	events JSONB NOT NULL DEFAULT '[]'::JSONB,
+	enabled BOOL NOT NULL DEFAULT TRUE,
+	owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_owner ON webhooks(owner_id);
+
+CREATE SEQUENCE IF NOT EXISTS webhook_deliveries_id_seq;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id INT8 PRIMARY KEY DEFAULT nextval('webhook_deliveries_id_seq'),
+	webhook_id INT8 NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	event STRING NOT NULL,
+	payload STRING NOT NULL,
+	status STRING NOT NULL,
+	attempts INT4 NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_status_code INT4 NOT NULL DEFAULT 0,
+	last_error STRING NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
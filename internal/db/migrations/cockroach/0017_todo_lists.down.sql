@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos@idx_todos_list_created;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS list_id;
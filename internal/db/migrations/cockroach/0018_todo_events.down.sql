@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS todo_events;
+
+DROP SEQUENCE IF EXISTS todo_events_id_seq;
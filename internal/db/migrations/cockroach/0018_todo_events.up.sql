@@ -0,0 +1,17 @@
+-- todo_events is the audit history of todo mutations. Rows outlive the todo
+-- they describe, so todo_id has no foreign key; they go with their owner.
+CREATE SEQUENCE IF NOT EXISTS todo_events_id_seq;
+
+CREATE TABLE IF NOT EXISTS todo_events (
+	id INT8 PRIMARY KEY DEFAULT nextval('todo_events_id_seq'),
+	todo_id INT8 NOT NULL,
+	action STRING NOT NULL,
+	old_value STRING NULL,
+	new_value STRING NULL,
+	owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id INT8 NULL,
+	request_id STRING NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_events_todo ON todo_events(todo_id, id);
@@ -0,0 +1,3 @@
+ALTER TABLE webhooks DROP COLUMN IF EXISTS throttled_until;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS paused_at;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS consecutive_failures;
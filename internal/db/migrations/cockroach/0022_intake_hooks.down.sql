@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS intake_hooks;
+
+DROP SEQUENCE IF EXISTS intake_hooks_id_seq;
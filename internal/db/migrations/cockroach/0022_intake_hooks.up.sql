@@ -0,0 +1,15 @@
+CREATE SEQUENCE IF NOT EXISTS intake_hooks_id_seq;
+
+CREATE TABLE IF NOT EXISTS intake_hooks (
+	id INT8 PRIMARY KEY DEFAULT nextval('intake_hooks_id_seq'),
+	name STRING NOT NULL,
+	token_hash STRING NOT NULL UNIQUE,
+	template STRING NOT NULL DEFAULT '',
+	enabled BOOL NOT NULL DEFAULT TRUE,
+	owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_used_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_intake_hooks_owner ON intake_hooks(owner_id);
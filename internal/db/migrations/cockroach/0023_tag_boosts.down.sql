@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS tag_boosts;
+
+DROP SEQUENCE IF EXISTS tag_boosts_id_seq;
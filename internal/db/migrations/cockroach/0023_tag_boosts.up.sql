@@ -0,0 +1,10 @@
+CREATE SEQUENCE IF NOT EXISTS tag_boosts_id_seq;
+
+CREATE TABLE IF NOT EXISTS tag_boosts (
+	id INT8 PRIMARY KEY DEFAULT nextval('tag_boosts_id_seq'),
+	owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE,
+	tag STRING NOT NULL,
+	boost FLOAT8 NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_boosts_owner ON tag_boosts(owner_id);
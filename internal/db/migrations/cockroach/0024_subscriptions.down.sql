@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS subscription_matches;
+DROP TABLE IF EXISTS subscriptions;
+
+DROP SEQUENCE IF EXISTS subscriptions_id_seq;
@@ -0,0 +1,21 @@
+CREATE SEQUENCE IF NOT EXISTS subscriptions_id_seq;
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+	id INT8 PRIMARY KEY DEFAULT nextval('subscriptions_id_seq'),
+	name STRING NOT NULL,
+	tag STRING NOT NULL DEFAULT '',
+	list_id INT8 NULL REFERENCES lists(id) ON DELETE CASCADE,
+	due_within_hours INT8 NOT NULL DEFAULT 0,
+	owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_owner ON subscriptions(owner_id);
+
+-- The todos each subscription has notified about, so none is notified twice.
+CREATE TABLE IF NOT EXISTS subscription_matches (
+	subscription_id INT8 NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+	todo_id INT8 NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	notified_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (subscription_id, todo_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS deactivated;
+
+ALTER TABLE users DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id STRING NULL;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated BOOL NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS time_entries;
+
+DROP SEQUENCE IF EXISTS time_entries_id_seq;
@@ -0,0 +1,12 @@
+-- time_entries is the time tracked against each todo, compared with its
+-- duration_minutes estimate by estimate accuracy stats.
+CREATE SEQUENCE IF NOT EXISTS time_entries_id_seq;
+
+CREATE TABLE IF NOT EXISTS time_entries (
+	id INT8 PRIMARY KEY DEFAULT nextval('time_entries_id_seq'),
+	todo_id INT8 NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	minutes INT8 NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_time_entries_todo ON time_entries(todo_id, id);
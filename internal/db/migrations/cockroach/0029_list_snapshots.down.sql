@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS list_snapshots;
+
+DROP SEQUENCE IF EXISTS list_snapshots_id_seq;
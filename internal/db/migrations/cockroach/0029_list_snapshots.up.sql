@@ -0,0 +1,15 @@
+-- list_snapshots records the todos of a list at a point in time. Each row
+-- holds only what changed since the list's previous snapshot; the oldest
+-- holds everything.
+CREATE SEQUENCE IF NOT EXISTS list_snapshots_id_seq;
+
+CREATE TABLE IF NOT EXISTS list_snapshots (
+	id INT8 PRIMARY KEY DEFAULT nextval('list_snapshots_id_seq'),
+	list_id INT8 NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+	todos INT8 NOT NULL,
+	data JSONB NOT NULL,
+	owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_list_snapshots_list ON list_snapshots(list_id, id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS locale_preferences;
+
+DROP SEQUENCE IF EXISTS locale_preferences_id_seq;
@@ -0,0 +1,9 @@
+CREATE SEQUENCE IF NOT EXISTS locale_preferences_id_seq;
+
+CREATE TABLE IF NOT EXISTS locale_preferences (
+	id INT8 PRIMARY KEY DEFAULT nextval('locale_preferences_id_seq'),
+	owner_id INT8 NULL REFERENCES users(id) ON DELETE CASCADE,
+	locale STRING NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_locale_preferences_owner ON locale_preferences(owner_id);
@@ -0,0 +1,2 @@
+ALTER TABLE lists DROP COLUMN IF EXISTS default_reminders;
+ALTER TABLE lists DROP COLUMN IF EXISTS default_tags;
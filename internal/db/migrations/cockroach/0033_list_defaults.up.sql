@@ -0,0 +1,3 @@
+-- Tags and reminder offsets given to the todos created through a list.
+ALTER TABLE lists ADD COLUMN IF NOT EXISTS default_tags JSONB NULL;
+ALTER TABLE lists ADD COLUMN IF NOT EXISTS default_reminders JSONB NULL;
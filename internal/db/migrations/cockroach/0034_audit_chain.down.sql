@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS audit_chain;
+DROP INDEX IF EXISTS todo_events@idx_todo_events_chain;
+ALTER TABLE todo_events DROP COLUMN IF EXISTS redacted;
+ALTER TABLE todo_events DROP COLUMN IF EXISTS hash;
+ALTER TABLE todo_events DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE todo_events DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE todo_events DROP COLUMN IF EXISTS chain_seq;
@@ -0,0 +1,19 @@
+-- Hash chain over todo_events for tamper evidence: each chained entry holds
+-- the hash of the one before it, and audit_chain the last position and hash,
+-- so removed or rewritten entries show when the chain is verified.
+ALTER TABLE todo_events ADD COLUMN IF NOT EXISTS chain_seq INT8 NULL;
+ALTER TABLE todo_events ADD COLUMN IF NOT EXISTS content_hash STRING NULL;
+ALTER TABLE todo_events ADD COLUMN IF NOT EXISTS prev_hash STRING NULL;
+ALTER TABLE todo_events ADD COLUMN IF NOT EXISTS hash STRING NULL;
+-- redacted marks entries whose snapshots were rewritten by anonymization.
+ALTER TABLE todo_events ADD COLUMN IF NOT EXISTS redacted BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todo_events_chain ON todo_events(chain_seq);
+
+CREATE TABLE IF NOT EXISTS audit_chain (
+	id INT8 PRIMARY KEY,
+	seq INT8 NOT NULL,
+	head STRING NOT NULL
+);
+
+INSERT INTO audit_chain (id, seq, head) VALUES (1, 0, '') ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,10 @@
+-- audit_removals records the chained todo_events entries deleted with their
+-- account, so verifying the chain tells them apart from entries removed
+-- behind the store's back. Each row names the purge entry that chained the
+-- deletion and whose content hash covers the rows it names.
+CREATE TABLE IF NOT EXISTS audit_removals (
+	seq INT8 PRIMARY KEY,
+	prev_hash STRING NOT NULL,
+	hash STRING NOT NULL,
+	purge_seq INT8 NOT NULL
+);
@@ -0,0 +1,112 @@
+// Package migrations holds the numbered SQL migrations for each supported
+// database. Files live in a directory per dialect and are named
+// NNNN_name.up.sql and NNNN_name.down.sql; versions start at 1 and must be
+// contiguous. Up migrations are written to be idempotent, so a migration
+// interrupted half-way can simply be run again.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed postgres/*.sql cockroach/*.sql mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+// Migration is one schema version.
+type Migration struct {
+	Version int64
+	Name    string
+	// Up and Down are the statements applying and reverting the version;
+	// Down is nil when the version has no down migration.
+	Up, Down []string
+}
+
+// For returns the migrations of the named dialect ("postgres", "cockroach",
+// "mysql" or "sqlite") in version order.
+func For(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("migrations for %s: %w", dialect, err)
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		base, direction, ok := cutDirection(e.Name())
+		if !ok {
+			return nil, fmt.Errorf("migration %s/%s: name must end in .up.sql or .down.sql", dialect, e.Name())
+		}
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(num, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s/%s: name must start with a positive version", dialect, e.Name())
+		}
+		data, err := files.ReadFile(path.Join(dialect, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = Split(string(data))
+		} else {
+			m.Down = Split(string(data))
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	for i, m := range out {
+		if m.Version != int64(i+1) {
+			return nil, fmt.Errorf("migrations for %s: version %d missing", dialect, i+1)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %s/%04d: no up migration", dialect, m.Version)
+		}
+	}
+	return out, nil
+}
+
+func cutDirection(name string) (base, direction string, ok bool) {
+	if base, ok := strings.CutSuffix(name, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok := strings.CutSuffix(name, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// Split breaks a migration file into statements. A statement ends at a line
+// ending in ";"; lines starting with "--" are comments and are dropped. The
+// drivers are not configured for multi-statement execution, so statements
+// are run one by one.
+func Split(sql string) []string {
+	var out []string
+	var stmt []string
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" && len(stmt) == 0 || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		stmt = append(stmt, line)
+		if strings.HasSuffix(trimmed, ";") {
+			out = append(out, strings.TrimSpace(strings.Join(stmt, "\n")))
+			stmt = nil
+		}
+	}
+	if s := strings.TrimSpace(strings.Join(stmt, "\n")); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	title VARCHAR(200) NOT NULL,
+	completed BOOLEAN NOT NULL DEFAULT FALSE,
+	tags JSON NOT NULL,
+	duration_minutes INT NOT NULL DEFAULT 0,
+	priority_score DOUBLE NOT NULL DEFAULT 0,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	INDEX idx_todos_completed (completed)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
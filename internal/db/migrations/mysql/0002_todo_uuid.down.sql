@@ -0,0 +1,3 @@
+DROP INDEX idx_todos_uuid ON todos;
+
+ALTER TABLE todos DROP COLUMN uuid;
@@ -0,0 +1,7 @@
+ALTER TABLE todos ADD COLUMN uuid CHAR(36) NULL;
+
+UPDATE todos SET uuid = UUID() WHERE uuid IS NULL;
+
+ALTER TABLE todos MODIFY uuid CHAR(36) NOT NULL;
+
+CREATE UNIQUE INDEX idx_todos_uuid ON todos(uuid);
@@ -0,0 +1 @@
+DROP INDEX idx_todos_completed_created ON todos;
@@ -0,0 +1,2 @@
+-- MySQL has no partial indexes; a composite index serves the open view.
+CREATE INDEX idx_todos_completed_created ON todos(completed, created_at);
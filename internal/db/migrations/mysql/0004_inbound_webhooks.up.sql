@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS inbound_webhooks (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	provider VARCHAR(64) NOT NULL,
+	delivery_id VARCHAR(255) NOT NULL,
+	event VARCHAR(255) NOT NULL DEFAULT '',
+	payload MEDIUMTEXT NOT NULL,
+	received_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	UNIQUE KEY uq_inbound_webhooks_delivery (provider, delivery_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS todo_view_positions (
+	view_key VARCHAR(64) NOT NULL,
+	todo_id BIGINT NOT NULL,
+	sort_order INT NOT NULL,
+	pinned BOOLEAN NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (view_key, todo_id),
+	CONSTRAINT fk_todo_view_positions_todo FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN due_at DATETIME(6) NULL;
+
+ALTER TABLE todos ADD COLUMN start_at DATETIME(6) NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS lists (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	color CHAR(7) NOT NULL DEFAULT '',
+	icon VARCHAR(32) NOT NULL DEFAULT '',
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
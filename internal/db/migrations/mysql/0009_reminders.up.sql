@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN reminder_offsets JSON NULL;
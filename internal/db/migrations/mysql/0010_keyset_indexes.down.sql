@@ -0,0 +1,5 @@
+DROP INDEX idx_todos_created_id ON todos;
+
+DROP INDEX idx_todos_duration_id ON todos;
+
+DROP INDEX idx_todos_priority_id ON todos;
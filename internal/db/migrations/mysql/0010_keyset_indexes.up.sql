@@ -0,0 +1,5 @@
+CREATE INDEX idx_todos_priority_id ON todos(priority_score, id);
+
+CREATE INDEX idx_todos_duration_id ON todos(duration_minutes, id);
+
+CREATE INDEX idx_todos_created_id ON todos(created_at, id);
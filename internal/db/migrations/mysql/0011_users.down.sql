@@ -0,0 +1,14 @@
+-- The foreign keys go first: idx_todos_owner_created backs fk_todos_owner
+-- and cannot be dropped while the constraint exists.
+ALTER TABLE lists DROP FOREIGN KEY fk_lists_owner;
+
+ALTER TABLE todos DROP FOREIGN KEY fk_todos_owner;
+
+DROP INDEX idx_todos_owner_created ON todos;
+
+-- Todos and lists created by accounts become unowned rather than deleted.
+ALTER TABLE lists DROP COLUMN owner_id;
+
+ALTER TABLE todos DROP COLUMN owner_id;
+
+DROP TABLE IF EXISTS users;
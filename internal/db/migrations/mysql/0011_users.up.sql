@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS users (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	email VARCHAR(254) NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	UNIQUE KEY uq_users_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Column and constraint go in one statement so a re-run fails
+-- as a whole on the duplicate column and is skipped.
+ALTER TABLE todos ADD COLUMN owner_id BIGINT NULL,
+	ADD CONSTRAINT fk_todos_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE lists ADD COLUMN owner_id BIGINT NULL,
+	ADD CONSTRAINT fk_lists_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_todos_owner_created ON todos(owner_id, created_at);
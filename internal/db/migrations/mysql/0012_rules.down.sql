@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS rule_runs;
+
+DROP TABLE IF EXISTS rules;
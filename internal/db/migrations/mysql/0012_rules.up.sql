@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS rules (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	trigger_event VARCHAR(32) NOT NULL,
+	due_within_minutes INT NOT NULL DEFAULT 0,
+	conditions JSON NOT NULL,
+	actions JSON NOT NULL,
+	enabled TINYINT(1) NOT NULL DEFAULT 1,
+	owner_id BIGINT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	KEY idx_rules_owner (owner_id),
+	CONSTRAINT fk_rules_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- todo_id is not a foreign key: the log outlives the todos it mentions.
+CREATE TABLE IF NOT EXISTS rule_runs (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	rule_id BIGINT NOT NULL,
+	todo_id BIGINT NOT NULL,
+	trigger_event VARCHAR(32) NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	detail TEXT NOT NULL,
+	ran_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	KEY idx_rule_runs_rule (rule_id, id),
+	CONSTRAINT fk_rule_runs_rule FOREIGN KEY (rule_id) REFERENCES rules(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
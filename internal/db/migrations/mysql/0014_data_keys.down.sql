@@ -0,0 +1,3 @@
+ALTER TABLE todos MODIFY title VARCHAR(200) NOT NULL;
+
+DROP TABLE IF EXISTS data_keys;
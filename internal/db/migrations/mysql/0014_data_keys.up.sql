@@ -0,0 +1,11 @@
+-- Per-user data keys for title encryption, wrapped by the master key.
+-- owner_id 0 holds the key of todos without an owner; it is not a foreign
+-- key for that reason.
+CREATE TABLE IF NOT EXISTS data_keys (
+	owner_id BIGINT NOT NULL PRIMARY KEY,
+	wrapped_key VARCHAR(255) NOT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Encrypted titles are longer than the 200 characters allowed in plain text.
+ALTER TABLE todos MODIFY title VARCHAR(512) NOT NULL;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	url VARCHAR(2048) NOT NULL,
+	secret VARCHAR(256) NOT NULL DEFAULT '',
+	events JSON NOT NULL,
+	enabled TINYINT(1) NOT NULL DEFAULT 1,
+	owner_id BIGINT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	KEY idx_webhooks_owner (owner_id),
+	CONSTRAINT fk_webhooks_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	webhook_id BIGINT NOT NULL,
+	event VARCHAR(32) NOT NULL,
+	payload MEDIUMTEXT NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	last_status_code INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	KEY idx_webhook_deliveries_due (status, next_attempt_at),
+	KEY idx_webhook_deliveries_webhook (webhook_id, id),
+	CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE lists ADD COLUMN legal_hold TINYINT(1) NOT NULL DEFAULT 0;
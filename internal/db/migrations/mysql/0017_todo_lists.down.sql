@@ -0,0 +1,7 @@
+-- The foreign key goes first: idx_todos_list_created backs fk_todos_list
+-- and cannot be dropped while the constraint exists.
+ALTER TABLE todos DROP FOREIGN KEY fk_todos_list;
+
+DROP INDEX idx_todos_list_created ON todos;
+
+ALTER TABLE todos DROP COLUMN list_id;
@@ -0,0 +1,7 @@
+-- A todo belongs to at most one list. Deleting a list unassigns its todos
+-- unless the API moved or deleted them first. Column and constraint go in
+-- one statement so a re-run fails as a whole and is skipped.
+ALTER TABLE todos ADD COLUMN list_id BIGINT NULL,
+	ADD CONSTRAINT fk_todos_list FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_todos_list_created ON todos(list_id, created_at);
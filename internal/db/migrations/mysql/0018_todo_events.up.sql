@@ -0,0 +1,15 @@
+-- todo_events is the audit history of todo mutations. Rows outlive the todo
+-- they describe, so todo_id has no foreign key; they go with their owner.
+CREATE TABLE IF NOT EXISTS todo_events (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	todo_id BIGINT NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	old_value MEDIUMTEXT NULL,
+	new_value MEDIUMTEXT NULL,
+	owner_id BIGINT NULL,
+	actor_id BIGINT NULL,
+	request_id VARCHAR(255) NOT NULL DEFAULT '',
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	KEY idx_todo_events_todo (todo_id, id),
+	CONSTRAINT fk_todo_events_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
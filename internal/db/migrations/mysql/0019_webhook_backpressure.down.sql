@@ -0,0 +1,4 @@
+ALTER TABLE webhooks
+	DROP COLUMN throttled_until,
+	DROP COLUMN paused_at,
+	DROP COLUMN consecutive_failures;
@@ -0,0 +1,4 @@
+ALTER TABLE webhooks
+	ADD COLUMN consecutive_failures INT NOT NULL DEFAULT 0,
+	ADD COLUMN paused_at DATETIME(6) NULL,
+	ADD COLUMN throttled_until DATETIME(6) NULL;
@@ -0,0 +1,24 @@
+-- todo_stats_daily is the rollup behind GET /api/stats: for each day,
+-- owner and kind ("day", "tag" or "priority") aggregates of the todos
+-- created that day. The rollup job rebuilds it wholesale and records the
+-- days it covers, [from_day, to_day), in todo_stats_rollup.
+CREATE TABLE IF NOT EXISTS todo_stats_daily (
+	day DATE NOT NULL,
+	owner_id BIGINT NULL,
+	kind VARCHAR(16) NOT NULL,
+	bucket TEXT NOT NULL,
+	todos BIGINT NOT NULL DEFAULT 0,
+	completed BIGINT NOT NULL DEFAULT 0,
+	minutes BIGINT NOT NULL DEFAULT 0,
+	KEY idx_todo_stats_daily_owner_day (owner_id, day),
+	CONSTRAINT fk_todo_stats_daily_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS todo_stats_rollup (
+	id INT NOT NULL PRIMARY KEY,
+	from_day DATE NULL,
+	to_day DATE NULL,
+	refreshed_at DATETIME(6) NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT IGNORE INTO todo_stats_rollup (id) VALUES (1);
@@ -0,0 +1,3 @@
+ALTER TABLE webhooks
+	DROP COLUMN payload_template,
+	DROP COLUMN event_filter;
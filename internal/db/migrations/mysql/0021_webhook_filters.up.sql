@@ -0,0 +1,3 @@
+ALTER TABLE webhooks
+	ADD COLUMN event_filter JSON NULL,
+	ADD COLUMN payload_template TEXT NULL;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS intake_hooks (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	token_hash CHAR(64) NOT NULL,
+	template TEXT NULL,
+	enabled TINYINT(1) NOT NULL DEFAULT 1,
+	owner_id BIGINT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	last_used_at DATETIME(6) NULL,
+	UNIQUE KEY uq_intake_hooks_token (token_hash),
+	KEY idx_intake_hooks_owner (owner_id),
+	CONSTRAINT fk_intake_hooks_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
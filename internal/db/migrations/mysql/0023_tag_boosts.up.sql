@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tag_boosts (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	owner_id BIGINT NULL,
+	tag VARCHAR(255) NOT NULL,
+	boost DOUBLE NOT NULL,
+	KEY idx_tag_boosts_owner (owner_id),
+	CONSTRAINT fk_tag_boosts_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	tag VARCHAR(32) NOT NULL DEFAULT '',
+	list_id BIGINT NULL,
+	due_within_hours INT NOT NULL DEFAULT 0,
+	owner_id BIGINT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	KEY idx_subscriptions_owner (owner_id),
+	CONSTRAINT fk_subscriptions_list FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+	CONSTRAINT fk_subscriptions_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- The todos each subscription has notified about, so none is notified twice.
+CREATE TABLE IF NOT EXISTS subscription_matches (
+	subscription_id BIGINT NOT NULL,
+	todo_id BIGINT NOT NULL,
+	notified_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	PRIMARY KEY (subscription_id, todo_id),
+	CONSTRAINT fk_subscription_matches_subscription FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE,
+	CONSTRAINT fk_subscription_matches_todo FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE lists ADD COLUMN archived TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN deactivated,
+	DROP COLUMN external_id;
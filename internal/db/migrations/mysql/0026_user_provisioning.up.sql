@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN external_id VARCHAR(255) NULL,
+	ADD COLUMN deactivated TINYINT(1) NOT NULL DEFAULT 0;
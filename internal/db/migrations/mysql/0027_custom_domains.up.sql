@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS custom_domains (
+	name VARCHAR(253) NOT NULL PRIMARY KEY,
+	token VARCHAR(64) NOT NULL,
+	verified_at DATETIME(6) NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,10 @@
+-- time_entries is the time tracked against each todo, compared with its
+-- duration_minutes estimate by estimate accuracy stats.
+CREATE TABLE IF NOT EXISTS time_entries (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	todo_id BIGINT NOT NULL,
+	minutes INT NOT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	KEY idx_time_entries_todo (todo_id, id),
+	CONSTRAINT fk_time_entries_todo FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
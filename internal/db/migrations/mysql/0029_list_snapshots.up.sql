@@ -0,0 +1,14 @@
+-- list_snapshots records the todos of a list at a point in time. Each row
+-- holds only what changed since the list's previous snapshot; the oldest
+-- holds everything.
+CREATE TABLE IF NOT EXISTS list_snapshots (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	list_id BIGINT NOT NULL,
+	todos INT NOT NULL,
+	data JSON NOT NULL,
+	owner_id BIGINT NULL,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	KEY idx_list_snapshots_list (list_id, id),
+	CONSTRAINT fk_list_snapshots_list FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+	CONSTRAINT fk_list_snapshots_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS locale_preferences (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	owner_id BIGINT NULL,
+	locale VARCHAR(64) NOT NULL,
+	KEY idx_locale_preferences_owner (owner_id),
+	CONSTRAINT fk_locale_preferences_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+ALTER TABLE lists DROP COLUMN default_reminders;
+ALTER TABLE lists DROP COLUMN default_tags;
@@ -0,0 +1,3 @@
+-- Tags and reminder offsets given to the todos created through a list.
+ALTER TABLE lists ADD COLUMN default_tags JSON NULL;
+ALTER TABLE lists ADD COLUMN default_reminders JSON NULL;
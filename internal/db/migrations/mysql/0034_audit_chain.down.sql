@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS audit_chain;
+ALTER TABLE todo_events
+	DROP INDEX idx_todo_events_chain,
+	DROP COLUMN redacted,
+	DROP COLUMN hash,
+	DROP COLUMN prev_hash,
+	DROP COLUMN content_hash,
+	DROP COLUMN chain_seq;
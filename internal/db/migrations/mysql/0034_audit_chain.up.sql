@@ -0,0 +1,19 @@
+-- Hash chain over todo_events for tamper evidence: each chained entry holds
+-- the hash of the one before it, and audit_chain the last position and hash,
+-- so removed or rewritten entries show when the chain is verified.
+ALTER TABLE todo_events
+	ADD COLUMN chain_seq BIGINT NULL,
+	ADD COLUMN content_hash CHAR(64) NULL,
+	ADD COLUMN prev_hash CHAR(64) NULL,
+	ADD COLUMN hash CHAR(64) NULL,
+	-- redacted marks entries whose snapshots were rewritten by anonymization.
+	ADD COLUMN redacted BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD UNIQUE KEY idx_todo_events_chain (chain_seq);
+
+CREATE TABLE IF NOT EXISTS audit_chain (
+	id INT NOT NULL PRIMARY KEY,
+	seq BIGINT NOT NULL,
+	head CHAR(64) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT IGNORE INTO audit_chain (id, seq, head) VALUES (1, 0, '');
@@ -0,0 +1,10 @@
+-- audit_removals records the chained todo_events entries deleted with their
+-- account, so verifying the chain tells them apart from entries removed
+-- behind the store's back. Each row names the purge entry that chained the
+-- deletion and whose content hash covers the rows it names.
+CREATE TABLE IF NOT EXISTS audit_removals (
+	seq BIGINT NOT NULL PRIMARY KEY,
+	prev_hash CHAR(64) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	purge_seq BIGINT NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
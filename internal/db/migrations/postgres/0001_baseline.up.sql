@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id BIGSERIAL PRIMARY KEY,
+	title TEXT NOT NULL,
+	completed BOOLEAN NOT NULL DEFAULT FALSE,
+	tags JSONB NOT NULL DEFAULT '[]'::jsonb,
+	duration_minutes INTEGER NOT NULL DEFAULT 0,
+	priority_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS duration_minutes INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS priority_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_todos_completed ON todos(completed);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_todos_uuid;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS uuid;
@@ -0,0 +1,3 @@
+-- Most reads only want open items; a partial index keeps that view
+-- cheap no matter how many completed todos accumulate.
+CREATE INDEX IF NOT EXISTS idx_todos_open_created ON todos(created_at) WHERE NOT completed;
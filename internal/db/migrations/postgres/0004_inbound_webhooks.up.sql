@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS inbound_webhooks (
+	id BIGSERIAL PRIMARY KEY,
+	provider TEXT NOT NULL,
+	delivery_id TEXT NOT NULL,
+	event TEXT NOT NULL DEFAULT '',
+	payload TEXT NOT NULL,
+	received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (provider, delivery_id)
+);
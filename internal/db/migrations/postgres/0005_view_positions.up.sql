@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS todo_view_positions (
+	view_key TEXT NOT NULL,
+	todo_id BIGINT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	sort_order INTEGER NOT NULL,
+	pinned BOOLEAN NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (view_key, todo_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ NULL;
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS start_at TIMESTAMPTZ NULL;
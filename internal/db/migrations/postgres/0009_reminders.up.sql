@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS reminder_offsets JSONB NULL;
@@ -0,0 +1,7 @@
+-- Keyset pagination orders by (column, id); these keep sorted pages
+-- index-only instead of sorting the whole table per request.
+CREATE INDEX IF NOT EXISTS idx_todos_priority_id ON todos(priority_score, id);
+
+CREATE INDEX IF NOT EXISTS idx_todos_duration_id ON todos(duration_minutes, id);
+
+CREATE INDEX IF NOT EXISTS idx_todos_created_id ON todos(created_at, id);
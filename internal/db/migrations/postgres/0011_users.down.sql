@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_lists_owner;
+
+DROP INDEX IF EXISTS idx_todos_owner_created;
+
+-- Todos and lists created by accounts become unowned rather than deleted.
+ALTER TABLE lists DROP COLUMN IF EXISTS owner_id;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS users;
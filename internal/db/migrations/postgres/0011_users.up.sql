@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	email TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Existing rows keep a NULL owner and stay visible only to
+-- unauthenticated deployments.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE lists ADD COLUMN IF NOT EXISTS owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_todos_owner_created ON todos(owner_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_lists_owner ON lists(owner_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_todos_search;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS search_vector;
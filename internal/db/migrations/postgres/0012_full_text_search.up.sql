@@ -0,0 +1,9 @@
+-- Titles rank above tags. A generated column is rewritten by PostgreSQL
+-- on every insert and update, so no write path has to maintain it.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', title), 'A') ||
+		setweight(jsonb_to_tsvector('english', tags, '["string"]'), 'B')
+	) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_todos_search ON todos USING GIN (search_vector);
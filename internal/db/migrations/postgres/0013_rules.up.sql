@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS rules (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	trigger_event TEXT NOT NULL,
+	due_within_minutes INTEGER NOT NULL DEFAULT 0,
+	conditions JSONB NOT NULL DEFAULT '[]'::jsonb,
+	actions JSONB NOT NULL DEFAULT '[]'::jsonb,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rules_owner ON rules(owner_id);
+
+-- todo_id is not a foreign key: the log outlives the todos it mentions.
+CREATE TABLE IF NOT EXISTS rule_runs (
+	id BIGSERIAL PRIMARY KEY,
+	rule_id BIGINT NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+	todo_id BIGINT NOT NULL,
+	trigger_event TEXT NOT NULL,
+	status TEXT NOT NULL,
+	detail TEXT NOT NULL DEFAULT '',
+	ran_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_runs_rule ON rule_runs(rule_id, id);
@@ -0,0 +1,8 @@
+-- Per-user data keys for title encryption, wrapped by the master key.
+-- owner_id 0 holds the key of todos without an owner; it is not a foreign
+-- key for that reason.
+CREATE TABLE IF NOT EXISTS data_keys (
+	owner_id BIGINT PRIMARY KEY,
+	wrapped_key TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
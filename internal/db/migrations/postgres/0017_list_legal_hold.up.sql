@@ -0,0 +1 @@
+ALTER TABLE lists ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- A todo belongs to at most one list. Deleting a list unassigns its todos
+-- unless the API moved or deleted them first.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS list_id BIGINT NULL REFERENCES lists(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_todos_list_created ON todos(list_id, created_at);
@@ -0,0 +1,15 @@
+-- todo_events is the audit history of todo mutations. Rows outlive the todo
+-- they describe, so todo_id has no foreign key; they go with their owner.
+CREATE TABLE IF NOT EXISTS todo_events (
+	id BIGSERIAL PRIMARY KEY,
+	todo_id BIGINT NOT NULL,
+	action TEXT NOT NULL,
+	old_value TEXT NULL,
+	new_value TEXT NULL,
+	owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id BIGINT NULL,
+	request_id TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_events_todo ON todo_events(todo_id, id);
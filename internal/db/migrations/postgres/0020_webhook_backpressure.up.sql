@@ -0,0 +1,3 @@
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS paused_at TIMESTAMPTZ NULL;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS throttled_until TIMESTAMPTZ NULL;
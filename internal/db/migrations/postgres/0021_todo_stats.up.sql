@@ -0,0 +1,24 @@
+-- todo_stats_daily is the rollup behind GET /api/stats: for each day,
+-- owner and kind ("day", "tag" or "priority") aggregates of the todos
+-- created that day. The rollup job rebuilds it wholesale and records the
+-- days it covers, [from_day, to_day), in todo_stats_rollup.
+CREATE TABLE IF NOT EXISTS todo_stats_daily (
+	day DATE NOT NULL,
+	owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	bucket TEXT NOT NULL,
+	todos BIGINT NOT NULL DEFAULT 0,
+	completed BIGINT NOT NULL DEFAULT 0,
+	minutes BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_stats_daily_owner_day ON todo_stats_daily(owner_id, day);
+
+CREATE TABLE IF NOT EXISTS todo_stats_rollup (
+	id INTEGER PRIMARY KEY,
+	from_day DATE NULL,
+	to_day DATE NULL,
+	refreshed_at TIMESTAMPTZ NULL
+);
+
+INSERT INTO todo_stats_rollup (id) VALUES (1) ON CONFLICT DO NOTHING;
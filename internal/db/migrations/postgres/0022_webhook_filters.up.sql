@@ -0,0 +1,2 @@
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS event_filter JSONB NULL;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS payload_template TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tag_boosts (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+	tag TEXT NOT NULL,
+	boost DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_boosts_owner ON tag_boosts(owner_id);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	tag TEXT NOT NULL DEFAULT '',
+	list_id BIGINT NULL REFERENCES lists(id) ON DELETE CASCADE,
+	due_within_hours INTEGER NOT NULL DEFAULT 0,
+	owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_owner ON subscriptions(owner_id);
+
+-- The todos each subscription has notified about, so none is notified twice.
+CREATE TABLE IF NOT EXISTS subscription_matches (
+	subscription_id BIGINT NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+	todo_id BIGINT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	notified_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (subscription_id, todo_id)
+);
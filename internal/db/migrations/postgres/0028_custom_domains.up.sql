@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS custom_domains (
+	name TEXT PRIMARY KEY,
+	token TEXT NOT NULL,
+	verified_at TIMESTAMPTZ NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,10 @@
+-- time_entries is the time tracked against each todo, compared with its
+-- duration_minutes estimate by estimate accuracy stats.
+CREATE TABLE IF NOT EXISTS time_entries (
+	id BIGSERIAL PRIMARY KEY,
+	todo_id BIGINT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	minutes INTEGER NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_time_entries_todo ON time_entries(todo_id, id);
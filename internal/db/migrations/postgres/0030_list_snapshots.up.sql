@@ -0,0 +1,13 @@
+-- list_snapshots records the todos of a list at a point in time. Each row
+-- holds only what changed since the list's previous snapshot; the oldest
+-- holds everything.
+CREATE TABLE IF NOT EXISTS list_snapshots (
+	id BIGSERIAL PRIMARY KEY,
+	list_id BIGINT NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+	todos INTEGER NOT NULL,
+	data JSONB NOT NULL,
+	owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_list_snapshots_list ON list_snapshots(list_id, id);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS locale_preferences (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+	locale TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_locale_preferences_owner ON locale_preferences(owner_id);
@@ -0,0 +1,6 @@
+-- audit_cursors records how far each consumer of todo_events (the audit
+-- forwarder) has got, so a restart resumes where it stopped.
+CREATE TABLE IF NOT EXISTS audit_cursors (
+	name TEXT PRIMARY KEY,
+	last_id BIGINT NOT NULL DEFAULT 0
+);
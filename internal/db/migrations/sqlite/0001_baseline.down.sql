@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS lists;
+
+DROP TABLE IF EXISTS todo_view_positions;
+
+DROP TABLE IF EXISTS inbound_webhooks;
+
+DROP TABLE IF EXISTS todos;
+
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,71 @@
+-- SQLite databases always start from the full schema: the backend was
+-- added after every earlier PostgreSQL and MySQL migration. Timestamps are
+-- text in the format the driver writes time parameters in, so they sort
+-- and compare correctly; see sqliteNow.
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
+
+CREATE TABLE IF NOT EXISTS todos (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	uuid TEXT NOT NULL,
+	title TEXT NOT NULL,
+	completed BOOLEAN NOT NULL DEFAULT FALSE,
+	tags TEXT NOT NULL DEFAULT '[]',
+	duration_minutes INTEGER NOT NULL DEFAULT 0,
+	priority_score REAL NOT NULL DEFAULT 0,
+	due_at DATETIME NULL,
+	start_at DATETIME NULL,
+	effort INTEGER NULL,
+	reminder_offsets TEXT NULL,
+	owner_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+	updated_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
+
+CREATE INDEX IF NOT EXISTS idx_todos_completed ON todos(completed);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_uuid ON todos(uuid);
+
+CREATE INDEX IF NOT EXISTS idx_todos_open_created ON todos(created_at) WHERE NOT completed;
+
+CREATE INDEX IF NOT EXISTS idx_todos_priority_id ON todos(priority_score, id);
+
+CREATE INDEX IF NOT EXISTS idx_todos_duration_id ON todos(duration_minutes, id);
+
+CREATE INDEX IF NOT EXISTS idx_todos_created_id ON todos(created_at, id);
+
+CREATE INDEX IF NOT EXISTS idx_todos_owner_created ON todos(owner_id, created_at);
+
+CREATE TABLE IF NOT EXISTS inbound_webhooks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	provider TEXT NOT NULL,
+	delivery_id TEXT NOT NULL,
+	event TEXT NOT NULL DEFAULT '',
+	payload TEXT NOT NULL,
+	received_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+	UNIQUE (provider, delivery_id)
+);
+
+CREATE TABLE IF NOT EXISTS todo_view_positions (
+	view_key TEXT NOT NULL,
+	todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	sort_order INTEGER NOT NULL,
+	pinned BOOLEAN NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (view_key, todo_id)
+);
+
+CREATE TABLE IF NOT EXISTS lists (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	color TEXT NOT NULL DEFAULT '',
+	icon TEXT NOT NULL DEFAULT '',
+	owner_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+	updated_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
+
+CREATE INDEX IF NOT EXISTS idx_lists_owner ON lists(owner_id);
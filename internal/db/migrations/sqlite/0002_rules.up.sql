@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS rules (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	trigger_event TEXT NOT NULL,
+	due_within_minutes INTEGER NOT NULL DEFAULT 0,
+	conditions TEXT NOT NULL DEFAULT '[]',
+	actions TEXT NOT NULL DEFAULT '[]',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	owner_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+	updated_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
+
+CREATE INDEX IF NOT EXISTS idx_rules_owner ON rules(owner_id);
+
+-- todo_id is not a foreign key: the log outlives the todos it mentions.
+CREATE TABLE IF NOT EXISTS rule_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	rule_id INTEGER NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+	todo_id INTEGER NOT NULL,
+	trigger_event TEXT NOT NULL,
+	status TEXT NOT NULL,
+	detail TEXT NOT NULL DEFAULT '',
+	ran_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_runs_rule ON rule_runs(rule_id, id);
@@ -0,0 +1 @@
+ALTER TABLE lists ADD COLUMN legal_hold BOOLEAN NOT NULL DEFAULT FALSE;
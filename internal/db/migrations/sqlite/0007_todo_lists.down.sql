@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_todos_list_created;
+
+ALTER TABLE todos DROP COLUMN list_id;
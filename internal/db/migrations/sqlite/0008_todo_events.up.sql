@@ -0,0 +1,15 @@
+-- todo_events is the audit history of todo mutations. Rows outlive the todo
+-- they describe, so todo_id has no foreign key; they go with their owner.
+CREATE TABLE IF NOT EXISTS todo_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	old_value TEXT NULL,
+	new_value TEXT NULL,
+	owner_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id INTEGER NULL,
+	request_id TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_events_todo ON todo_events(todo_id, id);
@@ -0,0 +1,3 @@
+ALTER TABLE webhooks DROP COLUMN throttled_until;
+ALTER TABLE webhooks DROP COLUMN paused_at;
+ALTER TABLE webhooks DROP COLUMN consecutive_failures;
@@ -0,0 +1,3 @@
+ALTER TABLE webhooks ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE webhooks ADD COLUMN paused_at DATETIME NULL;
+ALTER TABLE webhooks ADD COLUMN throttled_until DATETIME NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS todo_stats_rollup;
+DROP TABLE IF EXISTS todo_stats_daily;
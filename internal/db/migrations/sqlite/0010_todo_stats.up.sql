@@ -0,0 +1,25 @@
+-- todo_stats_daily is the rollup behind GET /api/stats: for each day,
+-- owner and kind ("day", "tag" or "priority") aggregates of the todos
+-- created that day. The rollup job rebuilds it wholesale and records the
+-- days it covers, [from_day, to_day), in todo_stats_rollup. Days are
+-- 'YYYY-MM-DD' text.
+CREATE TABLE IF NOT EXISTS todo_stats_daily (
+	day TEXT NOT NULL,
+	owner_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	bucket TEXT NOT NULL,
+	todos INTEGER NOT NULL DEFAULT 0,
+	completed INTEGER NOT NULL DEFAULT 0,
+	minutes INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_stats_daily_owner_day ON todo_stats_daily(owner_id, day);
+
+CREATE TABLE IF NOT EXISTS todo_stats_rollup (
+	id INTEGER PRIMARY KEY,
+	from_day TEXT NULL,
+	to_day TEXT NULL,
+	refreshed_at DATETIME NULL
+);
+
+INSERT OR IGNORE INTO todo_stats_rollup (id) VALUES (1);
@@ -0,0 +1,2 @@
+ALTER TABLE webhooks DROP COLUMN payload_template;
+ALTER TABLE webhooks DROP COLUMN event_filter;
@@ -0,0 +1,2 @@
+ALTER TABLE webhooks ADD COLUMN event_filter TEXT NULL;
+ALTER TABLE webhooks ADD COLUMN payload_template TEXT NOT NULL DEFAULT '';
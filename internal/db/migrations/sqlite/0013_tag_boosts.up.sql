@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tag_boosts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+	tag TEXT NOT NULL,
+	boost REAL NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_boosts_owner ON tag_boosts(owner_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS subscription_matches;
+DROP TABLE IF EXISTS subscriptions;
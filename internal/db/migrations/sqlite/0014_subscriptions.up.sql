@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	tag TEXT NOT NULL DEFAULT '',
+	list_id INTEGER NULL REFERENCES lists(id) ON DELETE CASCADE,
+	due_within_hours INTEGER NOT NULL DEFAULT 0,
+	owner_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_owner ON subscriptions(owner_id);
+
+-- The todos each subscription has notified about, so none is notified twice.
+CREATE TABLE IF NOT EXISTS subscription_matches (
+	subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+	todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	notified_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+	PRIMARY KEY (subscription_id, todo_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE lists ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN external_id TEXT NULL;
+
+ALTER TABLE users ADD COLUMN deactivated BOOLEAN NOT NULL DEFAULT FALSE;
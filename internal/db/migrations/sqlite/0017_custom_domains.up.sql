@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS custom_domains (
+	name TEXT PRIMARY KEY,
+	token TEXT NOT NULL,
+	verified_at DATETIME NULL,
+	created_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
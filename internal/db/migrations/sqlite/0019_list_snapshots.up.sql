@@ -0,0 +1,13 @@
+-- list_snapshots records the todos of a list at a point in time. Each row
+-- holds only what changed since the list's previous snapshot; the oldest
+-- holds everything.
+CREATE TABLE IF NOT EXISTS list_snapshots (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	list_id INTEGER NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+	todos INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	owner_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at DATETIME NOT NULL DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00')
+);
+
+CREATE INDEX IF NOT EXISTS idx_list_snapshots_list ON list_snapshots(list_id, id);
@@ -0,0 +1,9 @@
+-- event_counts holds the daily totals of high-frequency events (score cache
+-- lookups, SSE messages, ...) that internal/stats counts in memory and
+-- flushes periodically. Days are UTC 'YYYY-MM-DD' text.
+CREATE TABLE IF NOT EXISTS event_counts (
+	name TEXT NOT NULL,
+	day TEXT NOT NULL,
+	total INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (name, day)
+);
@@ -0,0 +1,3 @@
+-- Tags and reminder offsets given to the todos created through a list.
+ALTER TABLE lists ADD COLUMN default_tags TEXT NULL;
+ALTER TABLE lists ADD COLUMN default_reminders TEXT NULL;
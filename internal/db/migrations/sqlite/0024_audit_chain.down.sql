@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS audit_chain;
+DROP INDEX IF EXISTS idx_todo_events_chain;
+ALTER TABLE todo_events DROP COLUMN redacted;
+ALTER TABLE todo_events DROP COLUMN hash;
+ALTER TABLE todo_events DROP COLUMN prev_hash;
+ALTER TABLE todo_events DROP COLUMN content_hash;
+ALTER TABLE todo_events DROP COLUMN chain_seq;
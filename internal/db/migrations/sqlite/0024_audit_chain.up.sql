@@ -0,0 +1,19 @@
+-- Hash chain over todo_events for tamper evidence: each chained entry holds
+-- the hash of the one before it, and audit_chain the last position and hash,
+-- so removed or rewritten entries show when the chain is verified.
+ALTER TABLE todo_events ADD COLUMN chain_seq INTEGER NULL;
+ALTER TABLE todo_events ADD COLUMN content_hash TEXT NULL;
+ALTER TABLE todo_events ADD COLUMN prev_hash TEXT NULL;
+ALTER TABLE todo_events ADD COLUMN hash TEXT NULL;
+-- redacted marks entries whose snapshots were rewritten by anonymization.
+ALTER TABLE todo_events ADD COLUMN redacted BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todo_events_chain ON todo_events(chain_seq);
+
+CREATE TABLE IF NOT EXISTS audit_chain (
+	id INTEGER PRIMARY KEY,
+	seq INTEGER NOT NULL,
+	head TEXT NOT NULL
+);
+
+INSERT OR IGNORE INTO audit_chain (id, seq, head) VALUES (1, 0, '');
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// TodoMover is implemented by backends that move todos between lists.
+type TodoMover interface {
+	// MoveTodo moves todo id to list listID, or out of its list when
+	// listID is nil, recording the change as ActionMoved. The todo leaves
+	// the manual ordering of its old list's view and, when the new list's
+	// view has one, joins it at the end. Moving a todo to the list it is
+	// in changes nothing. A missing list fails with ErrListNotFound.
+	MoveTodo(ctx context.Context, id int64, listID *int64) (Todo, error)
+}
+
+// ListViewKey is the view under which list id keeps its manual ordering.
+func ListViewKey(id int64) string {
+	return "list:" + strconv.FormatInt(id, 10)
+}
+
+func sameList(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// MoveTodo changes the list of a todo and its list view positions in one
+// transaction.
+func (s *SQLStore) MoveTodo(ctx context.Context, id int64, listID *int64) (Todo, error) {
+	var old, t Todo
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := s.checkListRef(ctx, tx, listID); err != nil {
+			return err
+		}
+		var err error
+		if old, err = s.lockTodo(ctx, tx, id); err != nil {
+			return err
+		}
+		if sameList(old.ListID, listID) {
+			t = old
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE todos SET list_id = $1, updated_at = $2 WHERE id = $3`), listID, s.now(), id); err != nil {
+			return err
+		}
+		if t, err = s.txTodo(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := s.moveViewPosition(ctx, tx, id, old.ListID, listID); err != nil {
+			return err
+		}
+		return s.recordChanges(ctx, tx, []todoChange{{action: ActionMoved, old: &old, new: &t}})
+	})
+	if err != nil {
+		s.cache.invalidate(id)
+		return Todo{}, err
+	}
+	s.cache.put(t)
+	if !sameList(old.ListID, listID) {
+		slog.InfoContext(ctx, "todo.moved", "id", id, "from", old.ListID, "to", listID)
+	}
+	return t, nil
+}
+
+// moveViewPosition takes todo id out of the view of list from and appends
+// it to the view of list to, keeping whether it was pinned. A list whose
+// view was never ordered is left to the default order.
+func (s *SQLStore) moveViewPosition(ctx context.Context, tx *sql.Tx, id int64, from, to *int64) error {
+	var pinned bool
+	if from != nil {
+		err := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT pinned FROM todo_view_positions WHERE view_key = $1 AND todo_id = $2`), ListViewKey(*from), id).Scan(&pinned)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM todo_view_positions WHERE view_key = $1 AND todo_id = $2`), ListViewKey(*from), id); err != nil {
+			return err
+		}
+	}
+	if to == nil {
+		return nil
+	}
+	view := ListViewKey(*to)
+	// A position left over from an earlier stay in the list is stale.
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM todo_view_positions WHERE view_key = $1 AND todo_id = $2`), view, id); err != nil {
+		return err
+	}
+	owned, ownerArgs := ownerCond(ctx, "t.owner_id", 4)
+	_, err := tx.ExecContext(ctx, s.dialect.rebind(
+		`INSERT INTO todo_view_positions (view_key, todo_id, sort_order, pinned)
+		 SELECT $1, $2, MAX(p.sort_order) + 1, $3 FROM todo_view_positions p
+		 JOIN todos t ON t.id = p.todo_id
+		 WHERE p.view_key = $4`+owned+`
+		 HAVING COUNT(*) > 0`), append([]any{view, id, pinned, view}, ownerArgs...)...)
+	return err
+}
+
+// MoveTodo changes the list of a todo and its list view positions in one
+// write transaction.
+func (s *BoltStore) MoveTodo(ctx context.Context, id int64, listID *int64) (Todo, error) {
+	var old, t Todo
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(todosBucket)
+		v := b.Get(boltKey(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		var err error
+		if old, err = decodeBoltTodo(v); err != nil {
+			return err
+		}
+		if !visible(ctx, old.OwnerID) {
+			return ErrNotFound
+		}
+		if err := boltListRef(ctx, tx, listID); err != nil {
+			return err
+		}
+		t = old
+		if sameList(old.ListID, listID) {
+			return nil
+		}
+		t.ListID = listID
+		t.UpdatedAt = s.now()
+		if err := putBoltTodo(b, t); err != nil {
+			return err
+		}
+		if err := moveBoltViewPosition(ctx, tx, id, old.ListID, listID); err != nil {
+			return err
+		}
+		return s.recordBoltChange(ctx, tx, ActionMoved, &old, &t)
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	if !sameList(old.ListID, listID) {
+		slog.InfoContext(ctx, "todo.moved", "id", id, "from", old.ListID, "to", listID)
+	}
+	return t, nil
+}
+
+// moveBoltViewPosition is moveViewPosition for Bolt, where each view is one
+// sorted entry.
+func moveBoltViewPosition(ctx context.Context, tx *bolt.Tx, id int64, from, to *int64) error {
+	views := tx.Bucket(viewsBucket)
+	load := func(list int64) ([]ViewPosition, []byte, error) {
+		key := boltViewKey(ctx, ListViewKey(list))
+		var positions []ViewPosition
+		if v := views.Get(key); v != nil {
+			if err := json.Unmarshal(v, &positions); err != nil {
+				return nil, nil, err
+			}
+		}
+		return positions, key, nil
+	}
+	store := func(key []byte, positions []ViewPosition) error {
+		data, err := json.Marshal(positions)
+		if err != nil {
+			return err
+		}
+		return views.Put(key, data)
+	}
+	isTodo := func(p ViewPosition) bool { return p.TodoID == id }
+
+	var pinned bool
+	if from != nil {
+		positions, key, err := load(*from)
+		if err != nil {
+			return err
+		}
+		if i := slices.IndexFunc(positions, isTodo); i >= 0 {
+			pinned = positions[i].Pinned
+			if err := store(key, slices.Delete(positions, i, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if to == nil {
+		return nil
+	}
+	positions, key, err := load(*to)
+	if err != nil {
+		return err
+	}
+	stale := len(positions)
+	positions = slices.DeleteFunc(positions, isTodo)
+	if len(positions) == 0 {
+		if stale > 0 {
+			return store(key, positions)
+		}
+		return nil
+	}
+	last := 0
+	for _, p := range positions {
+		last = max(last, p.Position)
+	}
+	positions = append(positions, ViewPosition{TodoID: id, Position: last + 1, Pinned: pinned})
+	sortViewPositions(positions)
+	return store(key, positions)
+}
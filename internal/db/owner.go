@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"strconv"
+)
+
+type ownerKey struct{}
+
+// WithOwner scopes every todo, list and view operation run with the returned
+// context to the user with the given id: reads only see that user's rows and
+// creates are attributed to them. Contexts without an owner see everything,
+// which keeps single-user deployments working unchanged.
+func WithOwner(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, ownerKey{}, userID)
+}
+
+// OwnerFrom returns the user id ctx is scoped to, if any.
+func OwnerFrom(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(ownerKey{}).(int64)
+	return id, ok
+}
+
+// ownerCond renders " AND <col> = $n" for a scoped context, with n following
+// offset, or nothing for an unscoped one.
+func ownerCond(ctx context.Context, col string, offset int) (string, []any) {
+	id, ok := OwnerFrom(ctx)
+	if !ok {
+		return "", nil
+	}
+	return " AND " + col + " = $" + strconv.Itoa(offset+1), []any{id}
+}
+
+// ownerValue is the owner_id to store for a new row: the scoped user, or
+// NULL when unscoped.
+func ownerValue(ctx context.Context) any {
+	if id, ok := OwnerFrom(ctx); ok {
+		return id
+	}
+	return nil
+}
+
+// visible reports whether a row owned by ownerID may be seen through ctx.
+func visible(ctx context.Context, ownerID int64) bool {
+	id, ok := OwnerFrom(ctx)
+	return !ok || id == ownerID
+}
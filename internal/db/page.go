@@ -0,0 +1,293 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"golang.org/x/text/collate"
+)
+
+// MaxPageSize caps ListOptions.Limit.
+const MaxPageSize = 500
+
+// Sort keys accepted by ListOptions.
+const (
+	SortCreatedAt = "created_at"
+	SortPriority  = "priority_score"
+	SortDuration  = "duration"
+	// SortTitle orders by title, collated for ListOptions.Locale. The SQL
+	// stores sort in the database with a matching collation, except while
+	// titles are encrypted at rest, when they are sorted in memory.
+	SortTitle = "title"
+)
+
+// sortColumns maps sort keys to their columns.
+var sortColumns = map[string]string{
+	SortCreatedAt: "created_at",
+	SortPriority:  "priority_score",
+	SortDuration:  "duration_minutes",
+}
+
+// ErrInvalidCursor is returned for a cursor that was not issued for the
+// requested sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions orders and pages a filtered list. Pagination is keyset-based:
+// Cursor is the opaque NextCursor of the previous page, so pages stay stable
+// while todos are added or removed. Ties are broken by id.
+type ListOptions struct {
+	Sort   string
+	Desc   bool
+	Limit  int
+	Cursor string
+	// Locale is the BCP 47 tag titles are collated for under SortTitle;
+	// empty uses the root collation.
+	Locale string
+}
+
+// PagedLister is implemented by backends that can sort and page todo lists.
+type PagedLister interface {
+	// ListTodosPage returns one page and the cursor of the next one, which is
+	// empty on the last page.
+	ListTodosPage(ctx context.Context, filter TodoFilter, opts ListOptions) (items []Todo, next string, err error)
+}
+
+// ValidSort reports whether key is a supported sort key.
+func ValidSort(key string) bool {
+	_, ok := sortColumns[key]
+	return ok || key == SortTitle
+}
+
+// cursor is the decoded position after which the next page starts.
+type cursor struct {
+	Sort   string          `json:"s"`
+	Desc   bool            `json:"d"`
+	Locale string          `json:"l,omitempty"`
+	Key    json.RawMessage `json:"k"`
+	ID     int64           `json:"i"`
+}
+
+func (o ListOptions) normalized() ListOptions {
+	if o.Sort == "" {
+		o.Sort = SortCreatedAt
+	}
+	if o.Limit <= 0 || o.Limit > MaxPageSize {
+		o.Limit = MaxPageSize
+	}
+	return o
+}
+
+// sortKey returns t's value for the sort key.
+func sortKey(sort string, t Todo) any {
+	switch sort {
+	case SortPriority:
+		return t.PriorityScore
+	case SortDuration:
+		return t.DurationMinutes
+	case SortTitle:
+		return t.Title
+	default:
+		return t.CreatedAt.UTC()
+	}
+}
+
+func encodeCursor(o ListOptions, t Todo) string {
+	key, _ := json.Marshal(sortKey(o.Sort, t))
+	data, _ := json.Marshal(cursor{Sort: o.Sort, Desc: o.Desc, Locale: o.Locale, Key: key, ID: t.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the sort value and id encoded in o.Cursor.
+func decodeCursor(o ListOptions) (any, int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != o.Sort || c.Desc != o.Desc || c.Locale != o.Locale {
+		return nil, 0, ErrInvalidCursor
+	}
+	var key any
+	switch o.Sort {
+	case SortPriority:
+		var v float64
+		err = json.Unmarshal(c.Key, &v)
+		key = v
+	case SortDuration:
+		var v int
+		err = json.Unmarshal(c.Key, &v)
+		key = v
+	case SortTitle:
+		var v string
+		err = json.Unmarshal(c.Key, &v)
+		key = v
+	default:
+		var v time.Time
+		err = json.Unmarshal(c.Key, &v)
+		key = v
+	}
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	return key, c.ID, nil
+}
+
+// ListTodosPage returns a sorted page of todos matching filter.
+func (s *SQLStore) ListTodosPage(ctx context.Context, filter TodoFilter, opts ListOptions) ([]Todo, string, error) {
+	opts = opts.normalized()
+	col, ok := sortColumns[opts.Sort]
+	if opts.Sort == SortTitle {
+		// Ciphertext sorts meaninglessly, so encrypted titles are decrypted
+		// and sorted in memory.
+		if s.crypt != nil {
+			items, err := s.ListTodosFiltered(ctx, filter)
+			if err != nil {
+				return nil, "", err
+			}
+			return sortedPage(items, opts)
+		}
+		collation, err := s.titleCollation(ctx, opts.Locale)
+		if err != nil {
+			return nil, "", err
+		}
+		col, ok = "title"+collation, true
+	}
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported sort %q", opts.Sort)
+	}
+	dir, cmpOp := "ASC", ">"
+	if opts.Desc {
+		dir, cmpOp = "DESC", "<"
+	}
+
+	cond, args := filter.scoped(ctx).where(s.dialect, 0)
+	if opts.Cursor != "" {
+		key, id, err := decodeCursor(opts)
+		if err != nil {
+			return nil, "", err
+		}
+		// The key is bound twice because "?" dialects cannot reuse a placeholder.
+		n := len(args)
+		cond += fmt.Sprintf(" AND (%s %s $%d OR (%s = $%d AND id %s $%d))", col, cmpOp, n+1, col, n+2, cmpOp, n+3)
+		args = append(args, key, key, id)
+	}
+	// One extra row tells whether another page follows.
+	query := fmt.Sprintf(`SELECT %s FROM todos WHERE %s ORDER BY %s %s, id %s LIMIT %d`,
+		todoColumns, cond, col, dir, dir, opts.Limit+1)
+
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	out := []Todo{}
+	for rows.Next() {
+		t, err := s.scanTodo(ctx, rows)
+		if err != nil {
+			return nil, "", err
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	return pageOf(out, opts)
+}
+
+// ListTodosPage returns a sorted page of todos matching filter. Bolt has no
+// secondary indexes, so matching todos are sorted in memory.
+func (s *BoltStore) ListTodosPage(ctx context.Context, filter TodoFilter, opts ListOptions) ([]Todo, string, error) {
+	opts = opts.normalized()
+	if !ValidSort(opts.Sort) {
+		return nil, "", fmt.Errorf("unsupported sort %q", opts.Sort)
+	}
+	items, err := s.ListTodosFiltered(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	return sortedPage(items, opts)
+}
+
+// sortedPage sorts items in memory and returns the page opts asks for.
+func sortedPage(items []Todo, opts ListOptions) ([]Todo, string, error) {
+	compareKeys := opts.keyComparer()
+	compare := func(a, b Todo) int {
+		c := compareKeys(sortKey(opts.Sort, a), sortKey(opts.Sort, b))
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if opts.Desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(items, compare)
+
+	if opts.Cursor != "" {
+		key, id, err := decodeCursor(opts)
+		if err != nil {
+			return nil, "", err
+		}
+		start := slices.IndexFunc(items, func(t Todo) bool {
+			c := compareKeys(sortKey(opts.Sort, t), key)
+			if c == 0 {
+				c = cmp.Compare(t.ID, id)
+			}
+			if opts.Desc {
+				c = -c
+			}
+			return c > 0
+		})
+		if start < 0 {
+			start = len(items)
+		}
+		items = items[start:]
+	}
+	if len(items) > opts.Limit+1 {
+		items = items[:opts.Limit+1]
+	}
+	return pageOf(items, opts)
+}
+
+// pageOf trims the look-ahead row fetched past the limit and derives the
+// next cursor from the last row kept.
+func pageOf(items []Todo, opts ListOptions) ([]Todo, string, error) {
+	if len(items) <= opts.Limit {
+		return items, "", nil
+	}
+	items = items[:opts.Limit]
+	return items, encodeCursor(opts, items[len(items)-1]), nil
+}
+
+// keyComparer returns the comparison of sort keys. Titles are compared by
+// the collation of the locale, so accents, case and scripts sort as a
+// reader of that language expects rather than by byte order.
+func (o ListOptions) keyComparer() func(a, b any) int {
+	if o.Sort == SortTitle {
+		// A collator is not safe for concurrent use; each list gets its own.
+		c := collate.New(collationTag(o.Locale))
+		return func(a, b any) int {
+			return c.CompareString(a.(string), b.(string))
+		}
+	}
+	return compareKeys
+}
+
+func compareKeys(a, b any) int {
+	switch x := a.(type) {
+	case float64:
+		return cmp.Compare(x, b.(float64))
+	case int:
+		return cmp.Compare(x, b.(int))
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// titlePages lists every todo sorted by title, pageSize at a time.
+func titlePages(t *testing.T, s Store, locale string, desc bool, pageSize int) []string {
+	t.Helper()
+	var titles []string
+	opts := ListOptions{Sort: SortTitle, Desc: desc, Limit: pageSize, Locale: locale}
+	for {
+		items, next, err := s.(PagedLister).ListTodosPage(context.Background(), TodoFilter{}, opts)
+		if err != nil {
+			t.Fatalf("page: %v", err)
+		}
+		for _, todo := range items {
+			titles = append(titles, todo.Title)
+		}
+		if next == "" {
+			return titles
+		}
+		opts.Cursor = next
+	}
+}
+
+func TestTitleSort(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"", []string{"apple", "Apple", "äpple", "banana", "zebra", "zebra"}},
+		{"de-DE", []string{"apple", "Apple", "äpple", "banana", "zebra", "zebra"}},
+		{"sv", []string{"apple", "Apple", "banana", "zebra", "zebra", "äpple"}},
+	}
+	for name, s := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, title := range []string{"zebra", "äpple", "banana", "Apple", "zebra", "apple"} {
+				if _, err := s.CreateTodo(context.Background(), SaveTodoInput{Title: title}); err != nil {
+					t.Fatalf("create: %v", err)
+				}
+			}
+			for _, tt := range tests {
+				if got := titlePages(t, s, tt.locale, false, 2); !slices.Equal(got, tt.want) {
+					t.Errorf("locale %q: %q, want %q", tt.locale, got, tt.want)
+				}
+				want := slices.Clone(tt.want)
+				slices.Reverse(want)
+				if got := titlePages(t, s, tt.locale, true, 4); !slices.Equal(got, want) {
+					t.Errorf("locale %q descending: %q, want %q", tt.locale, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// queryPlan returns the detail lines of SQLite's EXPLAIN QUERY PLAN for query.
+func queryPlan(t *testing.T, s *SQLStore, query string, args ...any) string {
+	t.Helper()
+	rows, err := s.SQL.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatalf("explain: %v", err)
+		}
+		lines = append(lines, detail)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TestOpenTodosUsePartialIndex checks the migrated SQLite schema serves the
+// open-items view from the partial idx_todos_open_created index, in order,
+// and that the index is only picked where its predicate holds.
+func TestOpenTodosUsePartialIndex(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "todo.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	open, done := false, true
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		filter TodoFilter
+		want   string
+		reject string
+	}{
+		{"open", context.Background(), TodoFilter{Completed: &open}, "USING INDEX idx_todos_open_created", "TEMP B-TREE"},
+		{"completed", context.Background(), TodoFilter{Completed: &done}, "", "idx_todos_open_created"},
+		{"open of one owner", WithOwner(context.Background(), 3), TodoFilter{Completed: &open}, "USING INDEX idx_todos_owner_created (owner_id=?)", "TEMP B-TREE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := s.streamQuery(tt.ctx, tt.filter)
+			plan := queryPlan(t, s, query, args...)
+			if !strings.Contains(plan, tt.want) || tt.reject != "" && strings.Contains(plan, tt.reject) {
+				t.Errorf("plan for %s:\n%s\nwant %q without %q", query, plan, tt.want, tt.reject)
+			}
+		})
+	}
+}
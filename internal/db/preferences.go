@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/text/language"
+)
+
+var preferencesBucket = []byte("preferences")
+
+// MaxTagBoosts bounds how many tags Preferences may boost.
+const MaxTagBoosts = 100
+
+// Preferences are a user's settings for how their todos are prioritised
+// and sorted.
+type Preferences struct {
+	// TagBoosts maps a tag to what is added to the ML priority score of
+	// todos carrying it, from -1 to 1: 0.2 for "urgent", -0.1 for
+	// "someday". A todo's boosts add up; the result stays within [0, 1].
+	TagBoosts map[string]float64 `json:"tagBoosts"`
+	// Locale is the BCP 47 language tag ("de", "sv-SE") titles are
+	// collated for when lists are sorted by title; empty sorts them by the
+	// root collation.
+	Locale string `json:"locale,omitempty"`
+}
+
+// Boost returns the total boost of tags.
+func (p Preferences) Boost(tags []string) float64 {
+	var sum float64
+	for _, tag := range tags {
+		sum += p.TagBoosts[tag]
+	}
+	return sum
+}
+
+// Apply boosts an ML score by tags, keeping it within [0, 1] and rounding
+// away float noise.
+func (p Preferences) Apply(score float64, tags []string) float64 {
+	boost := p.Boost(tags)
+	if boost == 0 {
+		return score
+	}
+	return math.Round(math.Max(0, math.Min(1, score+boost))*1e4) / 1e4
+}
+
+// PreferenceStore is implemented by backends that keep per-user
+// preferences. Both methods act on the owner ctx is scoped to; unscoped,
+// on the preferences of unowned todos.
+type PreferenceStore interface {
+	Preferences(ctx context.Context) (Preferences, error)
+	// SetPreferences replaces the preferences, returning them as stored.
+	SetPreferences(ctx context.Context, p Preferences) (Preferences, error)
+}
+
+// validatePreferences normalises tags as todos store them (trimmed, lower
+// case) and the locale to its canonical form, and checks the boosts.
+func validatePreferences(p *Preferences) error {
+	if p.Locale = strings.TrimSpace(p.Locale); p.Locale != "" {
+		tag, err := language.Parse(p.Locale)
+		if err != nil || tag == language.Und {
+			return fmt.Errorf("invalid locale %q", p.Locale)
+		}
+		p.Locale = tag.String()
+	}
+	if len(p.TagBoosts) > MaxTagBoosts {
+		return fmt.Errorf("at most %d tag boosts", MaxTagBoosts)
+	}
+	boosts := make(map[string]float64, len(p.TagBoosts))
+	for tag, boost := range p.TagBoosts {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > 32 {
+			return errors.New("boosted tags must be 1 to 32 characters, like todo tags")
+		}
+		if math.IsNaN(boost) || boost < -1 || boost > 1 {
+			return fmt.Errorf("boost for %q must be between -1 and 1", tag)
+		}
+		if boost != 0 {
+			boosts[tag] = boost
+		}
+	}
+	p.TagBoosts = boosts
+	return nil
+}
+
+// preferencesOwner renders the condition picking the rows of the owner ctx
+// is scoped to, or the unowned ones.
+func preferencesOwner(ctx context.Context, offset int) (string, []any) {
+	if id, ok := OwnerFrom(ctx); ok {
+		return fmt.Sprintf("owner_id = $%d", offset+1), []any{id}
+	}
+	return "owner_id IS NULL", nil
+}
+
+// Preferences reads the tag boosts and the locale.
+func (s *SQLStore) Preferences(ctx context.Context) (Preferences, error) {
+	cond, args := preferencesOwner(ctx, 0)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT tag, boost FROM tag_boosts WHERE `+cond), args...)
+	if err != nil {
+		return Preferences{}, fmt.Errorf("load preferences: %w", err)
+	}
+	defer rows.Close()
+	p := Preferences{TagBoosts: map[string]float64{}}
+	for rows.Next() {
+		var tag string
+		var boost float64
+		if err := rows.Scan(&tag, &boost); err != nil {
+			return Preferences{}, err
+		}
+		p.TagBoosts[tag] = boost
+	}
+	if err := rows.Err(); err != nil {
+		return Preferences{}, err
+	}
+	err = s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT locale FROM locale_preferences WHERE `+cond), args...).Scan(&p.Locale)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Preferences{}, fmt.Errorf("load preferences: %w", err)
+	}
+	return p, nil
+}
+
+// SetPreferences replaces the tag boosts and the locale in one transaction.
+func (s *SQLStore) SetPreferences(ctx context.Context, p Preferences) (Preferences, error) {
+	if err := validatePreferences(&p); err != nil {
+		return Preferences{}, err
+	}
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		cond, args := preferencesOwner(ctx, 0)
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM tag_boosts WHERE `+cond), args...); err != nil {
+			return err
+		}
+		for tag, boost := range p.TagBoosts {
+			_, err := tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO tag_boosts (owner_id, tag, boost) VALUES ($1, $2, $3)`),
+				ownerValue(ctx), tag, boost)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM locale_preferences WHERE `+cond), args...); err != nil {
+			return err
+		}
+		if p.Locale == "" {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO locale_preferences (owner_id, locale) VALUES ($1, $2)`), ownerValue(ctx), p.Locale)
+		return err
+	})
+	if err != nil {
+		return Preferences{}, fmt.Errorf("save preferences: %w", err)
+	}
+	return p, nil
+}
+
+// Preferences reads the preferences stored under the owner's id.
+func (s *BoltStore) Preferences(ctx context.Context) (Preferences, error) {
+	id, _ := OwnerFrom(ctx)
+	p := Preferences{TagBoosts: map[string]float64{}}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(preferencesBucket).Get(boltKey(id))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &p)
+	})
+	if err != nil {
+		return Preferences{}, fmt.Errorf("load preferences: %w", err)
+	}
+	return p, nil
+}
+
+// SetPreferences replaces the preferences stored under the owner's id.
+func (s *BoltStore) SetPreferences(ctx context.Context, p Preferences) (Preferences, error) {
+	if err := validatePreferences(&p); err != nil {
+		return Preferences{}, err
+	}
+	id, _ := OwnerFrom(ctx)
+	data, err := json.Marshal(p)
+	if err != nil {
+		return Preferences{}, err
+	}
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(preferencesBucket).Put(boltKey(id), data)
+	})
+	if err != nil {
+		return Preferences{}, fmt.Errorf("save preferences: %w", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// todoInput is a valid SaveTodoInput for testing/quick.
+type todoInput struct{ SaveTodoInput }
+
+// propertyRunes are drawn from for titles and tags: ASCII, accents, other
+// scripts, emoji and JSON metacharacters.
+var propertyRunes = []rune(`abcxyzABC 019-_.,"'\/{}[]:éüßçÅøŁ中文字ひらがなкирилл😀🎉` + "\t")
+
+func randomText(r *rand.Rand, minLen, maxLen int) string {
+	var b strings.Builder
+	for n := minLen + r.Intn(maxLen-minLen+1); b.Len() < n; {
+		b.WriteRune(propertyRunes[r.Intn(len(propertyRunes))])
+	}
+	return b.String()
+}
+
+// randomTime returns a whole-second UTC time within a few years of 2024,
+// which every backend stores exactly.
+func randomTime(r *rand.Rand) *time.Time {
+	t := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(r.Int63n(3*365*24*3600)) * time.Second)
+	return &t
+}
+
+func (todoInput) Generate(r *rand.Rand, size int) reflect.Value {
+	in := SaveTodoInput{
+		Title:           strings.TrimSpace(randomText(r, 1, 60)) + "!",
+		Completed:       r.Intn(2) == 0,
+		DurationMinutes: r.Intn(24 * 60),
+		PriorityScore:   float64(r.Intn(10000)) / 100,
+	}
+	for range r.Intn(5) {
+		in.Tags = append(in.Tags, randomText(r, 1, 12))
+	}
+	if r.Intn(2) == 0 {
+		in.DueAt = randomTime(r)
+		if r.Intn(2) == 0 {
+			start := in.DueAt.Add(-time.Duration(r.Intn(72)) * time.Hour)
+			in.StartAt = &start
+		}
+	}
+	if r.Intn(2) == 0 {
+		effort := r.Intn(1001)
+		in.Effort = &effort
+	}
+	switch r.Intn(3) {
+	case 0:
+		in.ReminderOffsets = []int{}
+	case 1:
+		for range 1 + r.Intn(3) {
+			in.ReminderOffsets = append(in.ReminderOffsets, r.Intn(MaxReminderOffset+1))
+		}
+	}
+	if r.Intn(3) == 0 {
+		in.Recurrence = "FREQ=WEEKLY;BYDAY=MO"
+	}
+	return reflect.ValueOf(todoInput{in})
+}
+
+// checkSaved reports the first field of todo that does not match in.
+func checkSaved(t *testing.T, todo Todo, in SaveTodoInput) bool {
+	t.Helper()
+	timesEqual := func(a, b *time.Time) bool {
+		return a == nil && b == nil || a != nil && b != nil && a.Equal(*b)
+	}
+	switch {
+	case todo.Title != in.Title:
+		t.Logf("title %q, want %q", todo.Title, in.Title)
+	case todo.Completed != in.Completed:
+		t.Logf("completed %v, want %v", todo.Completed, in.Completed)
+	case !slices.Equal(todo.Tags, in.Tags):
+		t.Logf("tags %q, want %q", todo.Tags, in.Tags)
+	case todo.DurationMinutes != in.DurationMinutes || todo.PriorityScore != in.PriorityScore:
+		t.Logf("duration %d and priority %v, want %d and %v", todo.DurationMinutes, todo.PriorityScore, in.DurationMinutes, in.PriorityScore)
+	case !timesEqual(todo.DueAt, in.DueAt) || !timesEqual(todo.StartAt, in.StartAt):
+		t.Logf("due %v and start %v, want %v and %v", todo.DueAt, todo.StartAt, in.DueAt, in.StartAt)
+	case (todo.Effort == nil) != (in.Effort == nil) || in.Effort != nil && *todo.Effort != *in.Effort:
+		t.Logf("effort %v, want %v", todo.Effort, in.Effort)
+	case (todo.ReminderOffsets == nil) != (in.ReminderOffsets == nil) || !slices.Equal(todo.ReminderOffsets, in.ReminderOffsets):
+		t.Logf("reminder offsets %v, want %v", todo.ReminderOffsets, in.ReminderOffsets)
+	case todo.Recurrence != in.Recurrence:
+		t.Logf("recurrence %q, want %q", todo.Recurrence, in.Recurrence)
+	default:
+		return true
+	}
+	return false
+}
+
+func TestStoreProperties(t *testing.T) {
+	config := &quick.Config{MaxCount: 60, Rand: rand.New(rand.NewSource(1))}
+	for name, s := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			t.Run("create then get", func(t *testing.T) {
+				roundTrip := func(in todoInput) bool {
+					created, err := s.CreateTodo(ctx, in.SaveTodoInput)
+					if err != nil {
+						t.Logf("create: %v", err)
+						return false
+					}
+					got, err := s.GetTodo(ctx, created.ID)
+					if err != nil {
+						t.Logf("get: %v", err)
+						return false
+					}
+					return checkSaved(t, created, in.SaveTodoInput) && checkSaved(t, got, in.SaveTodoInput) && got.UUID == created.UUID
+				}
+				if err := quick.Check(roundTrip, config); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			t.Run("update is idempotent", func(t *testing.T) {
+				idempotent := func(first, second todoInput) bool {
+					created, err := s.CreateTodo(ctx, first.SaveTodoInput)
+					if err != nil {
+						t.Logf("create: %v", err)
+						return false
+					}
+					once, err := s.UpdateTodo(ctx, created.ID, second.SaveTodoInput)
+					if err != nil {
+						t.Logf("update: %v", err)
+						return false
+					}
+					twice, err := s.UpdateTodo(ctx, created.ID, second.SaveTodoInput)
+					if err != nil {
+						t.Logf("update again: %v", err)
+						return false
+					}
+					return checkSaved(t, once, second.SaveTodoInput) && checkSaved(t, twice, second.SaveTodoInput) &&
+						twice.ID == created.ID && twice.UUID == created.UUID && twice.CreatedAt.Equal(created.CreatedAt)
+				}
+				if err := quick.Check(idempotent, config); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			t.Run("listing", func(t *testing.T) {
+				all, err := s.ListTodos(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(all) != 2*config.MaxCount {
+					t.Fatalf("listed %d todos, want %d", len(all), 2*config.MaxCount)
+				}
+				if !slices.IsSortedFunc(all, func(a, b Todo) int { return a.CreatedAt.Compare(b.CreatedAt) }) {
+					t.Fatal("todos are not listed oldest first")
+				}
+
+				// Keyset pages put together are the whole list in order,
+				// whatever the page size.
+				pages := func(size uint8, desc bool) bool {
+					opts := ListOptions{Sort: SortPriority, Desc: desc, Limit: 1 + int(size)%40}
+					var got []int64
+					for {
+						items, next, err := s.(PagedLister).ListTodosPage(ctx, TodoFilter{}, opts)
+						if err != nil {
+							t.Logf("page: %v", err)
+							return false
+						}
+						for _, todo := range items {
+							got = append(got, todo.ID)
+						}
+						if next == "" {
+							break
+						}
+						opts.Cursor = next
+					}
+					want := slices.Clone(all)
+					slices.SortFunc(want, func(a, b Todo) int {
+						c := cmp.Or(cmp.Compare(a.PriorityScore, b.PriorityScore), cmp.Compare(a.ID, b.ID))
+						if desc {
+							return -c
+						}
+						return c
+					})
+					for i, todo := range want {
+						if i >= len(got) || got[i] != todo.ID {
+							t.Logf("page size %d: ids %v, want the order of %v", opts.Limit, got, want)
+							return false
+						}
+					}
+					return len(got) == len(want)
+				}
+				if err := quick.Check(pages, &quick.Config{MaxCount: 20, Rand: config.Rand}); err != nil {
+					t.Fatal(err)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrInvalidUser wraps the reasons a provisioned user is rejected.
+var ErrInvalidUser = errors.New("invalid user")
+
+// ProvisionedUser holds the fields of a user an identity provider manages.
+type ProvisionedUser struct {
+	Email       string
+	ExternalID  string
+	Deactivated bool
+}
+
+// UserProvisioner is implemented by backends that let an identity provider
+// create, update and deactivate accounts. Provisioned users get no
+// password, so they cannot log in with one.
+type UserProvisioner interface {
+	UserStore
+	// ListUsers returns the users ordered by id, skipping offset and at
+	// most limit of them, along with how many there are in all. A non-empty
+	// email keeps only the user registered with it.
+	ListUsers(ctx context.Context, email string, offset, limit int) ([]User, int, error)
+	ProvisionUser(ctx context.Context, input ProvisionedUser) (User, error)
+	// UpdateUser replaces the provisioned fields of user id. Taking an
+	// email another user has fails with ErrEmailTaken.
+	UpdateUser(ctx context.Context, id int64, input ProvisionedUser) (User, error)
+}
+
+// validateProvisionedUser checks and normalizes input.
+func validateProvisionedUser(input *ProvisionedUser) error {
+	email, err := NormalizeEmail(input.Email)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidUser, err)
+	}
+	input.Email = email
+	input.ExternalID = strings.TrimSpace(input.ExternalID)
+	if len(input.ExternalID) > 255 {
+		return fmt.Errorf("%w: external id too long", ErrInvalidUser)
+	}
+	return nil
+}
+
+// nullString stores "" as NULL.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// ListUsers returns a page of users ordered by id.
+func (s *SQLStore) ListUsers(ctx context.Context, email string, offset, limit int) ([]User, int, error) {
+	cond, args := "TRUE", []any{}
+	if email != "" {
+		cond, args = "email = $1", []any{strings.ToLower(strings.TrimSpace(email))}
+	}
+	var total int
+	if err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM users WHERE `+cond), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	n := len(args)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(fmt.Sprintf(`SELECT `+userColumns+` FROM users WHERE `+cond+` ORDER BY id LIMIT $%d OFFSET $%d`, n+1, n+2)),
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	out := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, u)
+	}
+	return out, total, rows.Err()
+}
+
+// ProvisionUser creates a user without a password.
+func (s *SQLStore) ProvisionUser(ctx context.Context, input ProvisionedUser) (User, error) {
+	if err := validateProvisionedUser(&input); err != nil {
+		return User{}, err
+	}
+	insert := `INSERT INTO users (email, password_hash, external_id, deactivated) VALUES ($1, '', $2, $3)`
+	args := []any{input.Email, nullString(input.ExternalID), input.Deactivated}
+	var u User
+	var err error
+	if s.dialect.returning {
+		u, err = scanUser(s.SQL.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+userColumns), args...))
+	} else {
+		var res sql.Result
+		if res, err = s.SQL.ExecContext(ctx, s.dialect.rebind(insert), args...); err == nil {
+			var id int64
+			if id, err = res.LastInsertId(); err == nil {
+				u, err = s.GetUser(ctx, id)
+			}
+		}
+	}
+	if isUniqueViolation(err) {
+		return User{}, ErrEmailTaken
+	}
+	if err != nil {
+		return User{}, err
+	}
+	slog.InfoContext(ctx, "user.provisioned", "user_id", u.ID)
+	return u, nil
+}
+
+// UpdateUser replaces the provisioned fields of a user.
+func (s *SQLStore) UpdateUser(ctx context.Context, id int64, input ProvisionedUser) (User, error) {
+	if err := validateProvisionedUser(&input); err != nil {
+		return User{}, err
+	}
+	_, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`UPDATE users SET email = $1, external_id = $2, deactivated = $3 WHERE id = $4`),
+		input.Email, nullString(input.ExternalID), input.Deactivated, id)
+	if isUniqueViolation(err) {
+		return User{}, ErrEmailTaken
+	}
+	if err != nil {
+		return User{}, err
+	}
+	// MySQL counts unchanged rows as unaffected, so a missing user shows
+	// in the read back instead.
+	u, err := s.GetUser(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	slog.InfoContext(ctx, "user.updated", "user_id", id, "deactivated", u.Deactivated)
+	return u, nil
+}
+
+// ListUsers returns a page of users ordered by id.
+func (s *BoltStore) ListUsers(ctx context.Context, email string, offset, limit int) ([]User, int, error) {
+	out := []User{}
+	total := 0
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if email != "" {
+			key := tx.Bucket(userEmailsBucket).Get([]byte(strings.ToLower(strings.TrimSpace(email))))
+			if key == nil {
+				return nil
+			}
+			var bu boltUser
+			if err := getBoltUser(tx, key, &bu); err != nil {
+				return err
+			}
+			if total = 1; offset == 0 && limit > 0 {
+				out = append(out, bu.User)
+			}
+			return nil
+		}
+		return tx.Bucket(usersBucket).ForEach(func(_, v []byte) error {
+			if total++; total <= offset || len(out) >= limit {
+				return nil
+			}
+			var bu boltUser
+			if err := json.Unmarshal(v, &bu); err != nil {
+				return fmt.Errorf("decode user: %w", err)
+			}
+			out = append(out, bu.User)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// ProvisionUser creates a user without a password.
+func (s *BoltStore) ProvisionUser(ctx context.Context, input ProvisionedUser) (User, error) {
+	if err := validateProvisionedUser(&input); err != nil {
+		return User{}, err
+	}
+	var u User
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		emails := tx.Bucket(userEmailsBucket)
+		if emails.Get([]byte(input.Email)) != nil {
+			return ErrEmailTaken
+		}
+		users := tx.Bucket(usersBucket)
+		seq, err := users.NextSequence()
+		if err != nil {
+			return err
+		}
+		u = User{ID: int64(seq), Email: input.Email, ExternalID: input.ExternalID, Deactivated: input.Deactivated, CreatedAt: time.Now().UTC()}
+		if err := putBoltUser(users, boltUser{User: u}); err != nil {
+			return err
+		}
+		return emails.Put([]byte(u.Email), boltKey(u.ID))
+	})
+	if err != nil {
+		return User{}, err
+	}
+	slog.InfoContext(ctx, "user.provisioned", "user_id", u.ID)
+	return u, nil
+}
+
+// UpdateUser replaces the provisioned fields of a user, moving its email
+// index entry when the email changes.
+func (s *BoltStore) UpdateUser(ctx context.Context, id int64, input ProvisionedUser) (User, error) {
+	if err := validateProvisionedUser(&input); err != nil {
+		return User{}, err
+	}
+	var bu boltUser
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		if err := getBoltUser(tx, boltKey(id), &bu); err != nil {
+			return err
+		}
+		if input.Email != bu.Email {
+			emails := tx.Bucket(userEmailsBucket)
+			if emails.Get([]byte(input.Email)) != nil {
+				return ErrEmailTaken
+			}
+			if err := emails.Delete([]byte(bu.Email)); err != nil {
+				return err
+			}
+			if err := emails.Put([]byte(input.Email), boltKey(id)); err != nil {
+				return err
+			}
+		}
+		bu.Email, bu.ExternalID, bu.Deactivated = input.Email, input.ExternalID, input.Deactivated
+		return putBoltUser(tx.Bucket(usersBucket), bu)
+	})
+	if err != nil {
+		return User{}, err
+	}
+	slog.InfoContext(ctx, "user.updated", "user_id", id, "deactivated", bu.Deactivated)
+	return bu.User, nil
+}
+
+func putBoltUser(users *bolt.Bucket, bu boltUser) error {
+	data, err := json.Marshal(bu)
+	if err != nil {
+		return fmt.Errorf("encode user: %w", err)
+	}
+	return users.Put(boltKey(bu.ID), data)
+}
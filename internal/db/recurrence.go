@@ -0,0 +1,188 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRecurrenceInterval bounds INTERVAL in a recurrence rule.
+const maxRecurrenceInterval = 1000
+
+// Recurrence is a parsed recurrence rule, a subset of RFC 5545 RRULE:
+// FREQ=DAILY|WEEKLY|MONTHLY|YEARLY with optional INTERVAL, BYDAY (weekly
+// only), COUNT and UNTIL, e.g. "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH".
+type Recurrence struct {
+	Freq     string
+	Interval int
+	// ByDay holds the weekdays a weekly rule falls on; empty means the
+	// weekday of the previous occurrence.
+	ByDay []time.Weekday
+	// Count is how many occurrences remain, this one included; zero means
+	// unlimited.
+	Count int
+	Until time.Time
+}
+
+var rruleDays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// ParseRecurrence parses an RRULE value, with or without the "RRULE:" prefix.
+func ParseRecurrence(rule string) (Recurrence, error) {
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+	r := Recurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Recurrence{}, fmt.Errorf("invalid recurrence part %q", part)
+		}
+		switch key {
+		case "FREQ":
+			if !slices.Contains([]string{"DAILY", "WEEKLY", "MONTHLY", "YEARLY"}, value) {
+				return Recurrence{}, errors.New("recurrence FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+			}
+			r.Freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxRecurrenceInterval {
+				return Recurrence{}, fmt.Errorf("recurrence INTERVAL must be between 1 and %d", maxRecurrenceInterval)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Recurrence{}, errors.New("recurrence COUNT must be positive")
+			}
+			r.Count = n
+		case "UNTIL":
+			t, err := time.Parse("20060102T150405Z", value)
+			if err != nil {
+				t, err = time.Parse("20060102", value)
+			}
+			if err != nil {
+				return Recurrence{}, errors.New("recurrence UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ")
+			}
+			r.Until = t
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := rruleDays[d]
+				if !ok {
+					return Recurrence{}, fmt.Errorf("invalid recurrence BYDAY %q", d)
+				}
+				if !slices.Contains(r.ByDay, wd) {
+					r.ByDay = append(r.ByDay, wd)
+				}
+			}
+		default:
+			return Recurrence{}, fmt.Errorf("unsupported recurrence part %s", key)
+		}
+	}
+	if r.Freq == "" {
+		return Recurrence{}, errors.New("recurrence FREQ is required")
+	}
+	if len(r.ByDay) > 0 && r.Freq != "WEEKLY" {
+		return Recurrence{}, errors.New("recurrence BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return Recurrence{}, errors.New("recurrence COUNT and UNTIL are mutually exclusive")
+	}
+	slices.Sort(r.ByDay)
+	return r, nil
+}
+
+// String renders r in canonical RRULE form.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			for name, d := range rruleDays {
+				if d == wd {
+					days[i] = name
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence after the previous one at prev and
+// strictly after now, along with the rule the next todo should carry (COUNT
+// counts down). ok is false once the rule is exhausted. Monthly and yearly
+// rules falling on a day the target month lacks use its last day.
+func (r Recurrence) Next(prev, now time.Time) (next time.Time, rest Recurrence, ok bool) {
+	if r.Count == 1 {
+		return time.Time{}, r, false
+	}
+	next = prev
+	// Overdue occurrences are skipped; the cap keeps a daily rule whose
+	// todo lay dormant for years from spinning.
+	for i := 0; i < 100000; i++ {
+		next = r.step(next)
+		if next.After(now) {
+			break
+		}
+	}
+	if !r.Until.IsZero() && next.After(r.Until) {
+		return time.Time{}, r, false
+	}
+	if r.Count > 0 {
+		r.Count--
+	}
+	return next, r, true
+}
+
+// step returns the occurrence following t.
+func (r Recurrence) step(t time.Time) time.Time {
+	switch r.Freq {
+	case "DAILY":
+		return t.AddDate(0, 0, r.Interval)
+	case "WEEKLY":
+		if len(r.ByDay) == 0 {
+			return t.AddDate(0, 0, 7*r.Interval)
+		}
+		week := weekOf(t)
+		for d := 1; ; d++ {
+			c := t.AddDate(0, 0, d)
+			weeks := int(weekOf(c).Sub(week).Hours()/24) / 7
+			if weeks%r.Interval == 0 && slices.Contains(r.ByDay, c.Weekday()) {
+				return c
+			}
+		}
+	case "MONTHLY":
+		return addMonthsClamped(t, r.Interval)
+	default:
+		return addMonthsClamped(t, 12*r.Interval)
+	}
+}
+
+// weekOf returns midnight of the Monday starting t's week, in t's location.
+func weekOf(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// addMonthsClamped adds n months to t, clamping the day to the last day of
+// the target month instead of overflowing into the next one.
+func addMonthsClamped(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(d, last)-1)
+}
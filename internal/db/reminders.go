@@ -0,0 +1,63 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	// maxReminderOffsets bounds how many reminders one todo may carry.
+	maxReminderOffsets = 10
+	// MaxReminderOffset is the furthest ahead of the due date a reminder may
+	// fire, in minutes.
+	MaxReminderOffset = 365 * 24 * 60
+)
+
+func validateReminderOffsets(offsets []int) error {
+	if len(offsets) > maxReminderOffsets {
+		return errors.New("too many reminder offsets")
+	}
+	for _, m := range offsets {
+		if m < 0 || m > MaxReminderOffset {
+			return errors.New("reminder offsets must be between 0 and 525600 minutes")
+		}
+	}
+	return nil
+}
+
+// encodeReminderOffsets returns the column value for offsets: NULL when the
+// todo uses the defaults, a JSON array otherwise.
+func encodeReminderOffsets(offsets []int) (any, error) {
+	if offsets == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(offsets)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// ReminderTimes returns when reminders for t should fire, earliest first,
+// using t's own offsets or defaults when it has none. Todos without a due
+// date, or already completed, have no reminders.
+func (t Todo) ReminderTimes(defaults []int) []time.Time {
+	if t.DueAt == nil || t.Completed {
+		return nil
+	}
+	offsets := t.ReminderOffsets
+	if offsets == nil {
+		offsets = defaults
+	}
+	out := make([]time.Time, 0, len(offsets))
+	for _, m := range offsets {
+		at := t.DueAt.Add(-time.Duration(m) * time.Minute)
+		if !slices.ContainsFunc(out, at.Equal) {
+			out = append(out, at)
+		}
+	}
+	slices.SortFunc(out, func(a, b time.Time) int { return a.Compare(b) })
+	return out
+}
@@ -0,0 +1,391 @@
+package db
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// AnonymizedPrefix starts the title of an anonymized todo.
+const AnonymizedPrefix = "anonymized:"
+
+// TodoAnonymizer is implemented by backends that can anonymize old todos in
+// place instead of deleting them.
+type TodoAnonymizer interface {
+	// AnonymizeTodos replaces the title of every completed todo last
+	// updated before cutoff, and the title in each entry of its history,
+	// and in the list snapshots holding it, with AnonymizedPrefix and a
+	// hash of the old title. Tags, durations, scores and timestamps are
+	// kept, so stats over them stay accurate; UpdatedAt is left as it was
+	// and only the audit chain, when on, records the change (see
+	// ActionRedacted), so the ListVersion does not change and callers
+	// caching lists must drop them. With dryRun nothing
+	// changes. It returns how many todos were, or would be, anonymized.
+	AnonymizeTodos(ctx context.Context, cutoff time.Time, dryRun bool) (int, error)
+}
+
+// AnonymizedTitle is the title anonymizing the todo with uuid gives title.
+// It hashes title with the uuid, so equal titles of different todos do not
+// hash alike.
+func AnonymizedTitle(uuid, title string) string {
+	sum := sha256.Sum256([]byte(uuid + "\n" + title))
+	return AnonymizedPrefix + hex.EncodeToString(sum[:8])
+}
+
+// anonymizeBatch caps the todos anonymized in one transaction.
+const anonymizeBatch = 200
+
+// AnonymizeTodos anonymizes old completed todos in transactions of up to
+// anonymizeBatch todos.
+func (s *SQLStore) AnonymizeTodos(ctx context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 2)
+	cond := `completed AND updated_at < $1 AND title NOT LIKE $2` + owned
+	args := append([]any{cutoff, AnonymizedPrefix + "%"}, ownerArgs...)
+	if dryRun {
+		var n int
+		err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM todos WHERE `+cond), args...).Scan(&n)
+		return n, err
+	}
+	query := `SELECT id, uuid, title FROM todos WHERE ` + cond + fmt.Sprintf(` ORDER BY id LIMIT $%d`, len(args)+1)
+	if s.dialect.name != sqliteDialect.name {
+		query += ` FOR UPDATE`
+	}
+	total := 0
+	for {
+		var ids []int64
+		err := s.WithTx(ctx, func(tx *sql.Tx) error {
+			ids = ids[:0]
+			titles, byUUID, err := s.anonymizeCandidates(ctx, tx, query, append(args, anonymizeBatch))
+			if err != nil {
+				return err
+			}
+			for id, title := range titles {
+				if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE todos SET title = $1 WHERE id = $2`), title, id); err != nil {
+					return err
+				}
+				ids = append(ids, id)
+			}
+			if err := s.anonymizeHistory(ctx, tx, titles); err != nil {
+				return err
+			}
+			return s.anonymizeSnapshots(ctx, tx, byUUID)
+		})
+		for _, id := range ids {
+			s.cache.invalidate(id)
+		}
+		if err != nil {
+			return total, err
+		}
+		total += len(ids)
+		if len(ids) < anonymizeBatch {
+			break
+		}
+	}
+	if total > 0 {
+		slog.InfoContext(ctx, "todo.anonymized", "count", total, "cutoff", cutoff)
+	}
+	return total, nil
+}
+
+// anonymizeCandidates returns the anonymized title of each todo query
+// selects, by id and by uuid. The stored title is hashed, sealed or not.
+func (s *SQLStore) anonymizeCandidates(ctx context.Context, tx *sql.Tx, query string, args []any) (byID map[int64]string, byUUID map[string]string, err error) {
+	rows, err := tx.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	byID, byUUID = make(map[int64]string), make(map[string]string)
+	for rows.Next() {
+		var id int64
+		var uuid, title string
+		if err := rows.Scan(&id, &uuid, &title); err != nil {
+			return nil, nil, err
+		}
+		byID[id] = AnonymizedTitle(uuid, title)
+		byUUID[uuid] = byID[id]
+	}
+	return byID, byUUID, rows.Err()
+}
+
+// anonymizeHistory sets the title of every history entry of the todos in
+// titles. With the audit chain on, a redaction entry then commits each
+// todo's rewritten entries to the chain.
+func (s *SQLStore) anonymizeHistory(ctx context.Context, tx *sql.Tx, titles map[int64]string) error {
+	if len(titles) == 0 {
+		return nil
+	}
+	ids := make([]any, 0, len(titles))
+	for id := range titles {
+		ids = append(ids, id)
+	}
+	rows, err := tx.QueryContext(ctx, s.dialect.rebind(`SELECT id, todo_id, old_value, new_value, owner_id, chain_seq FROM todo_events WHERE todo_id IN (`+placeholders(1, len(ids))+`)`), ids...)
+	if err != nil {
+		return err
+	}
+	type event struct {
+		id, todoID int64
+		old, new   sql.NullString
+		owner, seq sql.NullInt64
+	}
+	var events []event
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(&e.id, &e.todoID, &e.old, &e.new, &e.owner, &e.seq); err != nil {
+			rows.Close()
+			return err
+		}
+		title := titles[e.todoID]
+		if e.old, err = retitleSnapshot(e.old, title); err != nil {
+			rows.Close()
+			return err
+		}
+		if e.new, err = retitleSnapshot(e.new, title); err != nil {
+			rows.Close()
+			return err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	redacted := make(map[int64][]redactedSnapshot)
+	owners := make(map[int64]int64)
+	for _, e := range events {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE todo_events SET old_value = $1, new_value = $2, redacted = TRUE WHERE id = $3`), e.old, e.new, e.id); err != nil {
+			return err
+		}
+		if e.seq.Valid {
+			redacted[e.todoID] = append(redacted[e.todoID], redactedSnapshot{seq: e.seq.Int64, digest: snapshotDigest(stringPtr(e.old), stringPtr(e.new))})
+			owners[e.todoID] = e.owner.Int64
+		}
+	}
+	if !s.auditChain {
+		return nil
+	}
+	return s.recordChanges(ctx, tx, redactions(redacted, owners))
+}
+
+// redactions returns the redaction entries for the redacted entries of
+// each todo, by todo id.
+func redactions(redacted map[int64][]redactedSnapshot, owners map[int64]int64) []todoChange {
+	changes := make([]todoChange, 0, len(redacted))
+	for _, id := range slices.Sorted(maps.Keys(redacted)) {
+		entries := redacted[id]
+		slices.SortFunc(entries, func(a, b redactedSnapshot) int { return cmp.Compare(a.seq, b.seq) })
+		changes = append(changes, todoChange{action: ActionRedacted, marker: Todo{ID: id, OwnerID: owners[id]}, content: redactionDigest(entries)})
+	}
+	return changes
+}
+
+// stringPtr is v as the *string snapshots are digested as.
+func stringPtr(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+// anonymizeSnapshots sets the title of the todos in titles, by uuid, in
+// every list snapshot recording them. A todo may have moved between lists
+// since a snapshot was taken, so every snapshot is looked at.
+func (s *SQLStore) anonymizeSnapshots(ctx context.Context, tx *sql.Tx, titles map[string]string) error {
+	if len(titles) == 0 {
+		return nil
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT id, data FROM list_snapshots`)
+	if err != nil {
+		return err
+	}
+	changed := make(map[int64][]byte)
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		var diff snapshotDiff
+		if err := json.Unmarshal(data, &diff); err != nil {
+			rows.Close()
+			return fmt.Errorf("decode list snapshot: %w", err)
+		}
+		if !diff.retitle(titles) {
+			continue
+		}
+		if changed[id], err = json.Marshal(diff); err != nil {
+			rows.Close()
+			return fmt.Errorf("encode list snapshot: %w", err)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, data := range changed {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE list_snapshots SET data = $1 WHERE id = $2`), string(data), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retitleSnapshot replaces the title of a todo_events snapshot.
+func retitleSnapshot(v sql.NullString, title string) (sql.NullString, error) {
+	if !v.Valid {
+		return v, nil
+	}
+	var t Todo
+	if err := json.Unmarshal([]byte(v.String), &t); err != nil {
+		return v, fmt.Errorf("decode todo history: %w", err)
+	}
+	t.Title = title
+	data, err := json.Marshal(t)
+	if err != nil {
+		return v, fmt.Errorf("encode todo history: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// AnonymizeTodos anonymizes old completed todos in one write transaction.
+func (s *BoltStore) AnonymizeTodos(ctx context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	n := 0
+	fn := func(tx *bolt.Tx) error {
+		todos := tx.Bucket(todosBucket)
+		var matched []Todo
+		err := todos.ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if t.Completed && t.UpdatedAt.Before(cutoff) && !strings.HasPrefix(t.Title, AnonymizedPrefix) && visible(ctx, t.OwnerID) {
+				matched = append(matched, t)
+			}
+			return nil
+		})
+		if err != nil || dryRun {
+			n = len(matched)
+			return err
+		}
+		events := tx.Bucket(todoEventsBucket)
+		titles := make(map[string]string, len(matched))
+		for _, t := range matched {
+			t.Title = AnonymizedTitle(t.UUID, t.Title)
+			titles[t.UUID] = t.Title
+			if err := putBoltTodo(todos, t); err != nil {
+				return err
+			}
+			redacted, err := anonymizeBoltHistory(events, t.ID, t.Title)
+			if err != nil {
+				return err
+			}
+			if s.auditChain {
+				change := todoChange{action: ActionRedacted, marker: Todo{ID: t.ID, OwnerID: t.OwnerID}, content: redactionDigest(redacted)}
+				if err := s.putBoltChange(ctx, tx, change); err != nil {
+					return err
+				}
+			}
+		}
+		n = len(matched)
+		return anonymizeBoltSnapshots(tx.Bucket(listSnapshotsBucket), titles)
+	}
+	var err error
+	if dryRun {
+		err = s.DB.View(fn)
+	} else {
+		err = s.DB.Update(fn)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 && !dryRun {
+		slog.InfoContext(ctx, "todo.anonymized", "count", n, "cutoff", cutoff)
+	}
+	return n, nil
+}
+
+// anonymizeBoltHistory sets the title of every history entry of todo id
+// and returns the chained ones, as redacted, in chain order.
+func anonymizeBoltHistory(events *bolt.Bucket, id int64, title string) ([]redactedSnapshot, error) {
+	prefix := boltKey(id)
+	type entry struct{ key, value []byte }
+	var entries []entry
+	var redacted []redactedSnapshot
+	c := events.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var e boltTodoChange
+		if err := json.Unmarshal(v, &e); err != nil {
+			return nil, fmt.Errorf("decode todo history: %w", err)
+		}
+		for _, snapshot := range []*Todo{e.Old, e.New} {
+			if snapshot != nil {
+				snapshot.Title = title
+			}
+		}
+		e.Redacted = true
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("encode todo history: %w", err)
+		}
+		entries = append(entries, entry{bytes.Clone(k), data})
+		if e.Chain != nil {
+			old, new, err := boltSnapshots(e.Old, e.New)
+			if err != nil {
+				return nil, err
+			}
+			redacted = append(redacted, redactedSnapshot{seq: e.Chain.Seq, digest: snapshotDigest(old, new)})
+		}
+	}
+	// Writing while the cursor walks the bucket would invalidate it.
+	for _, e := range entries {
+		if err := events.Put(e.key, e.value); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(redacted, func(a, b redactedSnapshot) int { return cmp.Compare(a.seq, b.seq) })
+	return redacted, nil
+}
+
+// anonymizeBoltSnapshots sets the title of the todos in titles, by uuid, in
+// every list snapshot recording them.
+func anonymizeBoltSnapshots(b *bolt.Bucket, titles map[string]string) error {
+	type entry struct{ key, value []byte }
+	var entries []entry
+	err := b.ForEach(func(k, v []byte) error {
+		var snap boltListSnapshot
+		if err := json.Unmarshal(v, &snap); err != nil {
+			return fmt.Errorf("decode list snapshot: %w", err)
+		}
+		if !snap.Diff.retitle(titles) {
+			return nil
+		}
+		data, err := json.Marshal(snap)
+		if err != nil {
+			return fmt.Errorf("encode list snapshot: %w", err)
+		}
+		entries = append(entries, entry{bytes.Clone(k), data})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := b.Put(e.key, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnonymizeScrubsListSnapshots(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			lists := s.(ListStore)
+			snaps := s.(ListSnapshotStore)
+			list, err := lists.CreateList(ctx, SaveListInput{Name: "Health"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.CreateTodo(ctx, SaveTodoInput{Title: "see the doctor", Completed: true, ListID: &list.ID}); err != nil {
+				t.Fatal(err)
+			}
+			snap, err := snaps.SnapshotList(ctx, list.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, err := s.(TodoAnonymizer).AnonymizeTodos(ctx, time.Now().Add(time.Hour), false); err != nil || n != 1 {
+				t.Fatalf("anonymize: %d, %v", n, err)
+			}
+			todos, err := snaps.SnapshotTodos(ctx, list.ID, snap.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(todos) != 1 || !strings.HasPrefix(todos[0].Title, AnonymizedPrefix) {
+				t.Fatalf("snapshot still holds %+v", todos)
+			}
+		})
+	}
+}
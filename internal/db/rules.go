@@ -0,0 +1,685 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrRuleNotFound is returned when a rule does not exist.
+var ErrRuleNotFound = errors.New("rule not found")
+
+// Rule triggers: a todo was created, was completed, or its due date is
+// DueWithinMinutes away.
+const (
+	RuleTriggerCreated   = "created"
+	RuleTriggerCompleted = "completed"
+	RuleTriggerDueSoon   = "due_soon"
+)
+
+// Rule actions.
+const (
+	RuleActionAddTag      = "add_tag"
+	RuleActionSetPriority = "set_priority"
+	RuleActionNotify      = "notify"
+	RuleActionMoveToList  = "move_to_list"
+)
+
+// Outcomes recorded in a rule's execution log.
+const (
+	RuleRunApplied = "applied"
+	RuleRunFailed  = "failed"
+)
+
+const (
+	maxRuleConditions = 10
+	maxRuleActions    = 10
+	// defaultDueWithin is how early a due_soon rule fires when unset.
+	defaultDueWithin = 60
+	// maxDueWithin keeps due_soon rules within a week of the due date.
+	maxDueWithin = 7 * 24 * 60
+	// maxRuleRuns is how many log entries are kept per rule.
+	maxRuleRuns = 100
+)
+
+// RuleCondition is one test a todo must pass: it carries Tag, its title
+// matches the RE2 pattern TitleMatches, or it is in list ListID. Exactly
+// one is set.
+type RuleCondition struct {
+	Tag          string `json:"tag,omitempty"`
+	TitleMatches string `json:"titleMatches,omitempty"`
+	ListID       *int64 `json:"listId,omitempty"`
+}
+
+// RuleAction is one step of a rule: add_tag adds Tag, set_priority sets the
+// priority score to Priority, notify sends Message to the owner's open
+// clients and move_to_list moves the todo to list ListID.
+type RuleAction struct {
+	Type     string   `json:"type"`
+	Tag      string   `json:"tag,omitempty"`
+	Priority *float64 `json:"priority,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	ListID   *int64   `json:"listId,omitempty"`
+}
+
+// Rule applies its actions to todos passing all of its conditions whenever
+// its trigger fires.
+type Rule struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Trigger string `json:"trigger"`
+	// DueWithinMinutes is how long before the due date a due_soon rule
+	// fires; zero for other triggers.
+	DueWithinMinutes int             `json:"dueWithinMinutes,omitempty"`
+	Conditions       []RuleCondition `json:"conditions"`
+	Actions          []RuleAction    `json:"actions"`
+	Enabled          bool            `json:"enabled"`
+	OwnerID          int64           `json:"ownerId,omitempty"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
+}
+
+// SaveRuleInput represents the fields accepted for rule create/update.
+type SaveRuleInput struct {
+	Name             string
+	Trigger          string
+	DueWithinMinutes int
+	Conditions       []RuleCondition
+	Actions          []RuleAction
+	Enabled          bool
+}
+
+// RuleRun is one entry of a rule's execution log.
+type RuleRun struct {
+	ID      int64  `json:"id"`
+	RuleID  int64  `json:"ruleId"`
+	TodoID  int64  `json:"todoId"`
+	Trigger string `json:"trigger"`
+	Status  string `json:"status"`
+	// Detail lists the actions applied, or the error that stopped them.
+	Detail string    `json:"detail"`
+	RanAt  time.Time `json:"ranAt"`
+}
+
+// RuleStore is implemented by backends that support automation rules.
+// Only the latest runs of each rule are kept.
+type RuleStore interface {
+	ListRules(ctx context.Context) ([]Rule, error)
+	GetRule(ctx context.Context, id int64) (Rule, error)
+	CreateRule(ctx context.Context, input SaveRuleInput) (Rule, error)
+	UpdateRule(ctx context.Context, id int64, input SaveRuleInput) (Rule, error)
+	DeleteRule(ctx context.Context, id int64) error
+	RecordRuleRun(ctx context.Context, run RuleRun) error
+	ListRuleRuns(ctx context.Context, ruleID int64, limit int) ([]RuleRun, error)
+}
+
+// validateRuleInput checks and normalizes input: tags are lower-cased and
+// a due_soon rule without a window fires an hour ahead.
+func validateRuleInput(input *SaveRuleInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	if len(input.Name) > 100 {
+		return errors.New("name too long")
+	}
+	switch input.Trigger {
+	case RuleTriggerDueSoon:
+		if input.DueWithinMinutes == 0 {
+			input.DueWithinMinutes = defaultDueWithin
+		}
+		if input.DueWithinMinutes < 1 || input.DueWithinMinutes > maxDueWithin {
+			return fmt.Errorf("dueWithinMinutes must be between 1 and %d", maxDueWithin)
+		}
+	case RuleTriggerCreated, RuleTriggerCompleted:
+		input.DueWithinMinutes = 0
+	default:
+		return errors.New("trigger must be one of created, completed, due_soon")
+	}
+
+	if len(input.Conditions) > maxRuleConditions {
+		return errors.New("too many conditions")
+	}
+	conditions := make([]RuleCondition, len(input.Conditions))
+	for i, c := range input.Conditions {
+		c.Tag = strings.ToLower(strings.TrimSpace(c.Tag))
+		set := 0
+		for _, ok := range []bool{c.Tag != "", c.TitleMatches != "", c.ListID != nil} {
+			if ok {
+				set++
+			}
+		}
+		switch {
+		case set != 1:
+			return fmt.Errorf("condition %d: set exactly one of tag, titleMatches and listId", i+1)
+		case c.ListID != nil && *c.ListID <= 0:
+			return fmt.Errorf("condition %d: invalid listId", i+1)
+		case len(c.Tag) > 32:
+			return fmt.Errorf("condition %d: tag too long", i+1)
+		case len(c.TitleMatches) > 200:
+			return fmt.Errorf("condition %d: titleMatches too long", i+1)
+		}
+		if c.TitleMatches != "" {
+			if _, err := regexp.Compile(c.TitleMatches); err != nil {
+				return fmt.Errorf("condition %d: invalid titleMatches: %v", i+1, err)
+			}
+		}
+		conditions[i] = c
+	}
+	input.Conditions = conditions
+
+	if len(input.Actions) == 0 {
+		return errors.New("at least one action is required")
+	}
+	if len(input.Actions) > maxRuleActions {
+		return errors.New("too many actions")
+	}
+	actions := make([]RuleAction, len(input.Actions))
+	for i, a := range input.Actions {
+		switch a.Type {
+		case RuleActionAddTag:
+			a = RuleAction{Type: a.Type, Tag: strings.ToLower(strings.TrimSpace(a.Tag))}
+			if a.Tag == "" || len(a.Tag) > 32 {
+				return fmt.Errorf("action %d: tag must be 1 to 32 characters", i+1)
+			}
+		case RuleActionSetPriority:
+			a = RuleAction{Type: a.Type, Priority: a.Priority}
+			if a.Priority == nil || math.IsNaN(*a.Priority) || math.IsInf(*a.Priority, 0) {
+				return fmt.Errorf("action %d: priority must be a number", i+1)
+			}
+		case RuleActionNotify:
+			a = RuleAction{Type: a.Type, Message: strings.TrimSpace(a.Message)}
+			if a.Message == "" || len(a.Message) > 200 {
+				return fmt.Errorf("action %d: message must be 1 to 200 characters", i+1)
+			}
+		case RuleActionMoveToList:
+			a = RuleAction{Type: a.Type, ListID: a.ListID}
+			if a.ListID == nil || *a.ListID <= 0 {
+				return fmt.Errorf("action %d: invalid listId", i+1)
+			}
+		default:
+			return fmt.Errorf("action %d: type must be one of add_tag, set_priority, notify, move_to_list", i+1)
+		}
+		actions[i] = a
+	}
+	input.Actions = actions
+	return nil
+}
+
+// ruleLists returns the lists input refers to.
+func ruleLists(input SaveRuleInput) []*int64 {
+	var ids []*int64
+	for _, c := range input.Conditions {
+		if c.ListID != nil {
+			ids = append(ids, c.ListID)
+		}
+	}
+	for _, a := range input.Actions {
+		if a.ListID != nil {
+			ids = append(ids, a.ListID)
+		}
+	}
+	return ids
+}
+
+// Matches reports whether t passes every condition of the rule. A list
+// condition naming a deleted list matches nothing.
+func (r Rule) Matches(t Todo) bool {
+	for _, c := range r.Conditions {
+		if c.Tag != "" && !slices.Contains(t.Tags, c.Tag) {
+			return false
+		}
+		if c.ListID != nil && !sameList(t.ListID, c.ListID) {
+			return false
+		}
+		if c.TitleMatches != "" {
+			re, err := regexp.Compile(c.TitleMatches)
+			if err != nil || !re.MatchString(t.Title) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkRuleLists fails with ErrListNotFound unless every list input refers
+// to is visible. A list deleted later leaves the rule in place.
+func (s *SQLStore) checkRuleLists(ctx context.Context, input SaveRuleInput) error {
+	for _, id := range ruleLists(input) {
+		if err := s.checkListRef(ctx, s.SQL, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const ruleColumns = `id, name, trigger_event, due_within_minutes, conditions, actions, enabled, owner_id, created_at, updated_at`
+
+func scanRule(row rowScanner) (Rule, error) {
+	var r Rule
+	var conditions, actions []byte
+	var owner sql.NullInt64
+	err := row.Scan(&r.ID, &r.Name, &r.Trigger, &r.DueWithinMinutes, &conditions, &actions, &r.Enabled, &owner, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return Rule{}, err
+	}
+	r.OwnerID = owner.Int64
+	if err := json.Unmarshal(conditions, &r.Conditions); err != nil {
+		return Rule{}, fmt.Errorf("decode rule conditions: %w", err)
+	}
+	if err := json.Unmarshal(actions, &r.Actions); err != nil {
+		return Rule{}, fmt.Errorf("decode rule actions: %w", err)
+	}
+	return r, nil
+}
+
+// encodeRule returns the conditions and actions column values of input.
+func encodeRule(input SaveRuleInput) (conditions, actions string, err error) {
+	c, err := json.Marshal(input.Conditions)
+	if err != nil {
+		return "", "", err
+	}
+	a, err := json.Marshal(input.Actions)
+	if err != nil {
+		return "", "", err
+	}
+	return string(c), string(a), nil
+}
+
+// ListRules returns all rules ordered by id.
+func (s *SQLStore) ListRules(ctx context.Context) ([]Rule, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 0)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT `+ruleColumns+` FROM rules WHERE TRUE`+owned+` ORDER BY id ASC`), ownerArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Rule{}
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// GetRule returns a rule by id.
+func (s *SQLStore) GetRule(ctx context.Context, id int64) (Rule, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	r, err := scanRule(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+ruleColumns+` FROM rules WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Rule{}, ErrRuleNotFound
+	}
+	return r, err
+}
+
+// CreateRule creates a new rule.
+func (s *SQLStore) CreateRule(ctx context.Context, input SaveRuleInput) (Rule, error) {
+	if err := validateRuleInput(&input); err != nil {
+		return Rule{}, err
+	}
+	if err := s.checkRuleLists(ctx, input); err != nil {
+		return Rule{}, err
+	}
+	conditions, actions, err := encodeRule(input)
+	if err != nil {
+		return Rule{}, err
+	}
+	insert := `INSERT INTO rules (name, trigger_event, due_within_minutes, conditions, actions, enabled, owner_id) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	args := []any{input.Name, input.Trigger, input.DueWithinMinutes, conditions, actions, input.Enabled, ownerValue(ctx)}
+
+	var r Rule
+	if s.dialect.returning {
+		if r, err = scanRule(s.SQL.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+ruleColumns), args...)); err != nil {
+			return Rule{}, err
+		}
+	} else {
+		res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(insert), args...)
+		if err != nil {
+			return Rule{}, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return Rule{}, err
+		}
+		if r, err = s.GetRule(ctx, id); err != nil {
+			return Rule{}, err
+		}
+	}
+	slog.InfoContext(ctx, "rule.created", "id", r.ID, "trigger", r.Trigger)
+	return r, nil
+}
+
+// UpdateRule updates a rule by id.
+func (s *SQLStore) UpdateRule(ctx context.Context, id int64, input SaveRuleInput) (Rule, error) {
+	if err := validateRuleInput(&input); err != nil {
+		return Rule{}, err
+	}
+	if err := s.checkRuleLists(ctx, input); err != nil {
+		return Rule{}, err
+	}
+	conditions, actions, err := encodeRule(input)
+	if err != nil {
+		return Rule{}, err
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 7)
+	update := `UPDATE rules SET name = $1, trigger_event = $2, due_within_minutes = $3, conditions = $4, actions = $5, enabled = $6, updated_at = ` + s.dialect.now + ` WHERE id = $7` + owned
+	args := append([]any{input.Name, input.Trigger, input.DueWithinMinutes, conditions, actions, input.Enabled, id}, ownerArgs...)
+
+	var r Rule
+	if s.dialect.returning {
+		r, err = scanRule(s.SQL.QueryRowContext(ctx, s.dialect.rebind(update+` RETURNING `+ruleColumns), args...))
+		if errors.Is(err, sql.ErrNoRows) {
+			return Rule{}, ErrRuleNotFound
+		}
+		if err != nil {
+			return Rule{}, err
+		}
+	} else {
+		if _, err := s.SQL.ExecContext(ctx, s.dialect.rebind(update), args...); err != nil {
+			return Rule{}, err
+		}
+		if r, err = s.GetRule(ctx, id); err != nil {
+			return Rule{}, err
+		}
+	}
+	slog.InfoContext(ctx, "rule.updated", "id", r.ID, "trigger", r.Trigger)
+	return r, nil
+}
+
+// DeleteRule deletes a rule and its execution log.
+func (s *SQLStore) DeleteRule(ctx context.Context, id int64) error {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`DELETE FROM rules WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		slog.InfoContext(ctx, "rule.deleted", "id", id)
+	}
+	return nil
+}
+
+// RecordRuleRun appends run to its rule's log and drops entries beyond the
+// latest maxRuleRuns.
+func (s *SQLStore) RecordRuleRun(ctx context.Context, run RuleRun) error {
+	return s.WithTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.dialect.rebind(
+			`INSERT INTO rule_runs (rule_id, todo_id, trigger_event, status, detail) VALUES ($1, $2, $3, $4, $5)`),
+			run.RuleID, run.TodoID, run.Trigger, run.Status, run.Detail,
+		)
+		if err != nil {
+			return err
+		}
+		var oldest int64
+		err = tx.QueryRowContext(ctx, s.dialect.rebind(
+			`SELECT id FROM rule_runs WHERE rule_id = $1 ORDER BY id DESC LIMIT 1 OFFSET $2`),
+			run.RuleID, maxRuleRuns,
+		).Scan(&oldest)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM rule_runs WHERE rule_id = $1 AND id <= $2`), run.RuleID, oldest)
+		return err
+	})
+}
+
+// ListRuleRuns returns up to limit of a rule's latest runs, newest first.
+func (s *SQLStore) ListRuleRuns(ctx context.Context, ruleID int64, limit int) ([]RuleRun, error) {
+	if limit <= 0 || limit > maxRuleRuns {
+		limit = maxRuleRuns
+	}
+	owned, ownerArgs := ownerCond(ctx, "rules.owner_id", 2)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT rule_runs.id, rule_runs.rule_id, rule_runs.todo_id, rule_runs.trigger_event, rule_runs.status, rule_runs.detail, rule_runs.ran_at
+		 FROM rule_runs JOIN rules ON rules.id = rule_runs.rule_id
+		 WHERE rule_runs.rule_id = $1`+owned+`
+		 ORDER BY rule_runs.id DESC LIMIT $2`),
+		append([]any{ruleID, limit}, ownerArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []RuleRun{}
+	for rows.Next() {
+		var run RuleRun
+		if err := rows.Scan(&run.ID, &run.RuleID, &run.TodoID, &run.Trigger, &run.Status, &run.Detail, &run.RanAt); err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
+
+var (
+	rulesBucket = []byte("rules")
+	// ruleRunsBucket keys runs by rule id followed by a sequence number, so
+	// each rule's log is a contiguous, ordered key range.
+	ruleRunsBucket = []byte("rule_runs")
+)
+
+// ListRules returns all rules ordered by id.
+func (s *BoltStore) ListRules(ctx context.Context) ([]Rule, error) {
+	out := []Rule{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(rulesBucket).ForEach(func(_, v []byte) error {
+			var r Rule
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("decode rule: %w", err)
+			}
+			if visible(ctx, r.OwnerID) {
+				out = append(out, r)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetRule returns a rule by id.
+func (s *BoltStore) GetRule(ctx context.Context, id int64) (Rule, error) {
+	var r Rule
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		r, err = getBoltRule(ctx, tx, id)
+		return err
+	})
+	return r, err
+}
+
+// CreateRule creates a new rule.
+func (s *BoltStore) CreateRule(ctx context.Context, input SaveRuleInput) (Rule, error) {
+	if err := validateRuleInput(&input); err != nil {
+		return Rule{}, err
+	}
+	var r Rule
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(rulesBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		for _, listID := range ruleLists(input) {
+			if err := boltListRef(ctx, tx, listID); err != nil {
+				return err
+			}
+		}
+		now := time.Now().UTC()
+		r = applyRuleInput(Rule{ID: int64(seq), CreatedAt: now}, input, now)
+		r.OwnerID, _ = OwnerFrom(ctx)
+		return putBoltRule(b, r)
+	})
+	if err != nil {
+		return Rule{}, err
+	}
+	slog.InfoContext(ctx, "rule.created", "id", r.ID, "trigger", r.Trigger)
+	return r, nil
+}
+
+// UpdateRule updates a rule by id.
+func (s *BoltStore) UpdateRule(ctx context.Context, id int64, input SaveRuleInput) (Rule, error) {
+	if err := validateRuleInput(&input); err != nil {
+		return Rule{}, err
+	}
+	var r Rule
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		existing, err := getBoltRule(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		for _, listID := range ruleLists(input) {
+			if err := boltListRef(ctx, tx, listID); err != nil {
+				return err
+			}
+		}
+		r = applyRuleInput(existing, input, time.Now().UTC())
+		return putBoltRule(tx.Bucket(rulesBucket), r)
+	})
+	if err != nil {
+		return Rule{}, err
+	}
+	slog.InfoContext(ctx, "rule.updated", "id", r.ID, "trigger", r.Trigger)
+	return r, nil
+}
+
+// DeleteRule deletes a rule and its execution log.
+func (s *BoltStore) DeleteRule(ctx context.Context, id int64) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		if _, err := getBoltRule(ctx, tx, id); err != nil {
+			if errors.Is(err, ErrRuleNotFound) {
+				return nil
+			}
+			return err
+		}
+		if err := tx.Bucket(rulesBucket).Delete(boltKey(id)); err != nil {
+			return err
+		}
+		runs := tx.Bucket(ruleRunsBucket)
+		prefix := boltKey(id)
+		c := runs.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+			if err := runs.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordRuleRun appends run to its rule's log and drops entries beyond the
+// latest maxRuleRuns.
+func (s *BoltStore) RecordRuleRun(ctx context.Context, run RuleRun) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		runs := tx.Bucket(ruleRunsBucket)
+		seq, err := runs.NextSequence()
+		if err != nil {
+			return err
+		}
+		run.ID = int64(seq)
+		run.RanAt = time.Now().UTC()
+		data, err := json.Marshal(run)
+		if err != nil {
+			return fmt.Errorf("encode rule run: %w", err)
+		}
+		prefix := boltKey(run.RuleID)
+		if err := runs.Put(append(prefix, boltKey(run.ID)...), data); err != nil {
+			return err
+		}
+		var keys [][]byte
+		c := runs.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+		for _, k := range keys[:max(0, len(keys)-maxRuleRuns)] {
+			if err := runs.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListRuleRuns returns up to limit of a rule's latest runs, newest first.
+func (s *BoltStore) ListRuleRuns(ctx context.Context, ruleID int64, limit int) ([]RuleRun, error) {
+	if limit <= 0 || limit > maxRuleRuns {
+		limit = maxRuleRuns
+	}
+	out := []RuleRun{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if _, err := getBoltRule(ctx, tx, ruleID); err != nil {
+			return err
+		}
+		prefix := boltKey(ruleID)
+		c := tx.Bucket(ruleRunsBucket).Cursor()
+		k, v := c.Seek(boltKey(ruleID + 1))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix) && len(out) < limit; k, v = c.Prev() {
+			var run RuleRun
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("decode rule run: %w", err)
+			}
+			out = append(out, run)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func getBoltRule(ctx context.Context, tx *bolt.Tx, id int64) (Rule, error) {
+	v := tx.Bucket(rulesBucket).Get(boltKey(id))
+	if v == nil {
+		return Rule{}, ErrRuleNotFound
+	}
+	var r Rule
+	if err := json.Unmarshal(v, &r); err != nil {
+		return Rule{}, fmt.Errorf("decode rule: %w", err)
+	}
+	if !visible(ctx, r.OwnerID) {
+		return Rule{}, ErrRuleNotFound
+	}
+	return r, nil
+}
+
+func applyRuleInput(r Rule, input SaveRuleInput, now time.Time) Rule {
+	r.Name, r.Trigger, r.DueWithinMinutes = input.Name, input.Trigger, input.DueWithinMinutes
+	r.Conditions, r.Actions, r.Enabled = input.Conditions, input.Actions, input.Enabled
+	r.UpdatedAt = now
+	return r
+}
+
+func putBoltRule(b *bolt.Bucket, r Rule) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("encode rule: %w", err)
+	}
+	return b.Put(boltKey(r.ID), data)
+}
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ScoreUpdater is implemented by backends that can rewrite priority scores
+// in bulk. Re-scoring is derived data rather than an edit, so updated_at is
+// left alone and the todos do not show up as changed to sync clients.
+type ScoreUpdater interface {
+	// UpdatePriorityScores sets the priority_score of each todo id in
+	// scores; ids that no longer exist are skipped.
+	UpdatePriorityScores(ctx context.Context, scores map[int64]float64) error
+}
+
+// UpdatePriorityScores writes scores in one transaction.
+func (s *SQLStore) UpdatePriorityScores(ctx context.Context, scores map[int64]float64) error {
+	if len(scores) == 0 {
+		return nil
+	}
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, s.dialect.rebind(`UPDATE todos SET priority_score = $1 WHERE id = $2`))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for id, score := range scores {
+			if _, err := stmt.ExecContext(ctx, score, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for id := range scores {
+		s.cache.invalidate(id)
+	}
+	slog.InfoContext(ctx, "todo.scores_updated", "rows", len(scores))
+	return nil
+}
+
+// UpdatePriorityScores writes scores in one transaction.
+func (s *BoltStore) UpdatePriorityScores(ctx context.Context, scores map[int64]float64) error {
+	if len(scores) == 0 {
+		return nil
+	}
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(todosBucket)
+		for id, score := range scores {
+			v := b.Get(boltKey(id))
+			if v == nil {
+				continue
+			}
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			t.PriorityScore = score
+			if err := putBoltTodo(b, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "todo.scores_updated", "rows", len(scores))
+	return nil
+}
+
+// scoreBands are the recency bands ScoreDistribution splits todos into by
+// when they were last updated; older todos fall in a final "older" band.
+var scoreBands = []struct {
+	name string
+	age  time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// ScoreBand is the distribution of the stored scores of the todos last
+// updated within one recency band.
+type ScoreBand struct {
+	Band  string  `json:"band"` // "24h", "7d", "30d" or "older"
+	Todos int64   `json:"todos"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	// Constant is set when more than one todo shares a single score,
+	// which usually means a broken model or a fallback scorer.
+	Constant bool `json:"constant"`
+	// Histogram has PriorityBuckets entries over [0, 1], lowest first;
+	// scores outside the range count in the first or last bucket.
+	Histogram []PriorityBucket `json:"histogram"`
+}
+
+// ScoreDistributor is implemented by backends that can histogram stored
+// priority scores, for spotting model drift.
+type ScoreDistributor interface {
+	// ScoreDistribution covers every todo, newest band first. Bands without
+	// todos are included with zero counts.
+	ScoreDistribution(ctx context.Context) ([]ScoreBand, error)
+}
+
+func newScoreBands() []ScoreBand {
+	out := make([]ScoreBand, len(scoreBands)+1)
+	for i := range out {
+		out[i].Band = "older"
+		if i < len(scoreBands) {
+			out[i].Band = scoreBands[i].name
+		}
+		out[i].Histogram = make([]PriorityBucket, PriorityBuckets)
+		for j := range out[i].Histogram {
+			out[i].Histogram[j].Min = float64(j) / PriorityBuckets
+			out[i].Histogram[j].Max = float64(j+1) / PriorityBuckets
+		}
+	}
+	return out
+}
+
+// add counts todos scored from lo to hi, summing to sum, in bucket.
+func (b *ScoreBand) add(bucket int, todos int64, lo, hi, sum float64) {
+	if b.Todos == 0 || lo < b.Min {
+		b.Min = lo
+	}
+	if b.Todos == 0 || hi > b.Max {
+		b.Max = hi
+	}
+	b.Mean += sum
+	b.Todos += todos
+	b.Histogram[bucket].Todos += todos
+}
+
+func finishScoreBands(bands []ScoreBand) {
+	for i := range bands {
+		if bands[i].Todos > 0 {
+			bands[i].Mean /= float64(bands[i].Todos)
+		}
+		bands[i].Constant = bands[i].Todos > 1 && bands[i].Min == bands[i].Max
+	}
+}
+
+// scoreBand returns the index into newScoreBands of a todo last updated at
+// updated.
+func scoreBand(now, updated time.Time) int {
+	for i, b := range scoreBands {
+		if !updated.Before(now.Add(-b.age)) {
+			return i
+		}
+	}
+	return len(scoreBands)
+}
+
+// ScoreDistribution aggregates by band and bucket in one query.
+func (s *SQLStore) ScoreDistribution(ctx context.Context) ([]ScoreBand, error) {
+	now := time.Now().UTC()
+	var band strings.Builder
+	band.WriteString("CASE")
+	args := make([]any, len(scoreBands))
+	for i, b := range scoreBands {
+		fmt.Fprintf(&band, " WHEN updated_at >= $%d THEN %d", i+1, i)
+		args[i] = now.Add(-b.age)
+	}
+	fmt.Fprintf(&band, " ELSE %d END", len(scoreBands))
+
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT `+band.String()+`, `+priorityBucket("priority_score")+`,
+		 COUNT(*), MIN(priority_score), MAX(priority_score), SUM(priority_score)
+		 FROM todos GROUP BY 1, 2`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate scores: %w", err)
+	}
+	defer rows.Close()
+	bands := newScoreBands()
+	for rows.Next() {
+		var b int
+		var bucket string
+		var todos int64
+		var lo, hi, sum float64
+		if err := rows.Scan(&b, &bucket, &todos, &lo, &hi, &sum); err != nil {
+			return nil, err
+		}
+		i, err := strconv.Atoi(bucket)
+		if err != nil || b < 0 || b >= len(bands) || i < 0 || i >= PriorityBuckets {
+			continue
+		}
+		bands[b].add(i, todos, lo, hi, sum)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	finishScoreBands(bands)
+	return bands, nil
+}
+
+// ScoreDistribution aggregates in one pass over the todos.
+func (s *BoltStore) ScoreDistribution(ctx context.Context) ([]ScoreBand, error) {
+	now := time.Now().UTC()
+	bands := newScoreBands()
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(todosBucket).ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			bucket := min(max(int(t.PriorityScore*PriorityBuckets), 0), PriorityBuckets-1)
+			bands[scoreBand(now, t.UpdatedAt)].add(bucket, 1, t.PriorityScore, t.PriorityScore, t.PriorityScore)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	finishScoreBands(bands)
+	return bands, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+)
+
+// MaxSearchResults caps the limit of a search.
+const MaxSearchResults = 100
+
+// ErrSearchUnsupported is returned by SearchTodos on databases without
+// full-text search, only PostgreSQL having it, and while titles are
+// encrypted.
+var ErrSearchUnsupported = errors.New("full-text search not supported by storage backend")
+
+// SearchResult is a todo matching a search. Snippet is the title as HTML
+// with the matched words wrapped in <mark>; everything else is escaped.
+type SearchResult struct {
+	Todo
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// Searcher is implemented by backends with full-text search over todo titles
+// and tags. Query uses web search syntax: words are ANDed, "quoted phrases"
+// match in order, "or" separates alternatives and a leading "-" excludes a
+// word. Results are ordered by rank, best first.
+type Searcher interface {
+	SearchTodos(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// Markers ts_headline puts around matches. Control characters cannot come
+// from the user, since they are stripped from the title before escaping.
+const (
+	matchStart = "\x01"
+	matchStop  = "\x02"
+)
+
+// SearchTodos matches query against the search_vector column, which
+// PostgreSQL keeps up to date on every write and indexes with GIN.
+func (s *SQLStore) SearchTodos(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	// Encrypted titles cannot be indexed.
+	if s.dialect.name != postgresDialect.name || s.crypt != nil {
+		return nil, ErrSearchUnsupported
+	}
+	if limit <= 0 || limit > MaxSearchResults {
+		limit = MaxSearchResults
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 2)
+	rows, err := s.SQL.QueryContext(ctx,
+		`SELECT `+todoColumns+`, ts_rank(search_vector, q.query) AS rank,
+			ts_headline('english', regexp_replace(title, '[\x01\x02]', '', 'g'), q.query,
+				'HighlightAll=true, StartSel="`+matchStart+`", StopSel="`+matchStop+`"')
+		 FROM todos, websearch_to_tsquery('english', $1) AS q(query)
+		 WHERE search_vector @@ q.query`+owned+`
+		 ORDER BY rank DESC, id ASC
+		 LIMIT $2`,
+		append([]any{query, limit}, ownerArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		var headline string
+		if res.Todo, err = s.scanTodo(ctx, rows, &res.Rank, &headline); err != nil {
+			return nil, err
+		}
+		res.Snippet = highlight(headline)
+		out = append(out, res)
+	}
+	return out, rows.Err()
+}
+
+// highlight escapes a ts_headline result and turns its match markers into
+// <mark> elements.
+func highlight(headline string) string {
+	return strings.NewReplacer(matchStart, "<mark>", matchStop, "</mark>").Replace(html.EscapeString(headline))
+}
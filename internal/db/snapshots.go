@@ -0,0 +1,463 @@
+package db
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrSnapshotNotFound is returned when a list snapshot does not exist.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// maxListSnapshots is how many snapshots a list keeps; taking another folds
+// the oldest into the next.
+const maxListSnapshots = 20
+
+// ListSnapshot records the todos of a list at a point in time, so they can
+// be put back after an accidental bulk edit.
+type ListSnapshot struct {
+	ID     int64 `json:"id"`
+	ListID int64 `json:"listId"`
+	// Todos is how many todos the list had.
+	Todos     int       `json:"todos"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ListSnapshotStore is implemented by backends that can snapshot lists.
+type ListSnapshotStore interface {
+	// SnapshotList records the todos of list id as they are now. It fails
+	// with ErrListNotFound when the list is not visible.
+	SnapshotList(ctx context.Context, id int64) (ListSnapshot, error)
+	// ListSnapshots lists the snapshots of list id, newest first.
+	ListSnapshots(ctx context.Context, id int64) ([]ListSnapshot, error)
+	// SnapshotTodos returns the todos of list id as snapshotID recorded
+	// them, ordered by id.
+	SnapshotTodos(ctx context.Context, id, snapshotID int64) ([]Todo, error)
+}
+
+// snapshotDiff is what a snapshot stores: the todos, by uuid, added or
+// changed since the previous snapshot of the list, and the uuids of those
+// gone from it. A list's oldest snapshot sets every todo.
+type snapshotDiff struct {
+	Set     map[string]Todo `json:"set,omitempty"`
+	Removed []string        `json:"removed,omitempty"`
+}
+
+func (d snapshotDiff) apply(state map[string]Todo) {
+	for _, uuid := range d.Removed {
+		delete(state, uuid)
+	}
+	for uuid, t := range d.Set {
+		state[uuid] = t
+	}
+}
+
+// retitle sets the title of the todos in titles, by uuid, and reports
+// whether d recorded any of them.
+func (d snapshotDiff) retitle(titles map[string]string) bool {
+	changed := false
+	for uuid, t := range d.Set {
+		if title, ok := titles[uuid]; ok {
+			t.Title = title
+			d.Set[uuid] = t
+			changed = true
+		}
+	}
+	return changed
+}
+
+// diffSnapshot returns the diff turning the state prev into todos.
+func diffSnapshot(prev map[string]Todo, todos []Todo) (snapshotDiff, error) {
+	d := snapshotDiff{Set: map[string]Todo{}}
+	current := make(map[string]bool, len(todos))
+	for _, t := range todos {
+		current[t.UUID] = true
+		if old, ok := prev[t.UUID]; ok {
+			a, err := json.Marshal(old)
+			if err != nil {
+				return snapshotDiff{}, fmt.Errorf("encode todo: %w", err)
+			}
+			b, err := json.Marshal(t)
+			if err != nil {
+				return snapshotDiff{}, fmt.Errorf("encode todo: %w", err)
+			}
+			if bytes.Equal(a, b) {
+				continue
+			}
+		}
+		d.Set[t.UUID] = t
+	}
+	for uuid := range prev {
+		if !current[uuid] {
+			d.Removed = append(d.Removed, uuid)
+		}
+	}
+	slices.Sort(d.Removed)
+	return d, nil
+}
+
+// snapshotState returns the todos of state ordered by id.
+func snapshotState(state map[string]Todo) []Todo {
+	out := make([]Todo, 0, len(state))
+	for _, t := range state {
+		if t.Tags == nil {
+			t.Tags = []string{}
+		}
+		out = append(out, t)
+	}
+	slices.SortFunc(out, func(a, b Todo) int { return cmp.Compare(a.ID, b.ID) })
+	return out
+}
+
+// storedSnapshot is a list_snapshots row with its diff decoded. Titles in
+// the diff are sealed as in todos.
+type storedSnapshot struct {
+	id   int64
+	diff snapshotDiff
+}
+
+// snapshotDiffs returns the snapshots of list id up to snapshot upTo, or
+// all of them when upTo is zero, oldest first.
+func (s *SQLStore) snapshotDiffs(ctx context.Context, tx *sql.Tx, id, upTo int64) ([]storedSnapshot, error) {
+	query, args := `SELECT id, data FROM list_snapshots WHERE list_id = $1`, []any{id}
+	if upTo != 0 {
+		query += ` AND id <= $2`
+		args = append(args, upTo)
+	}
+	rows, err := tx.QueryContext(ctx, s.dialect.rebind(query+` ORDER BY id`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []storedSnapshot
+	for rows.Next() {
+		var snap storedSnapshot
+		var data []byte
+		if err := rows.Scan(&snap.id, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &snap.diff); err != nil {
+			return nil, fmt.Errorf("decode list snapshot: %w", err)
+		}
+		out = append(out, snap)
+	}
+	return out, rows.Err()
+}
+
+// openSnapshotState replays snaps and opens the titles of the result.
+func (s *SQLStore) openSnapshotState(ctx context.Context, snaps []storedSnapshot) (map[string]Todo, error) {
+	state := map[string]Todo{}
+	for _, snap := range snaps {
+		snap.diff.apply(state)
+	}
+	for uuid, t := range state {
+		var err error
+		if t.Title, err = s.openTitle(ctx, t.ID, t.OwnerID, t.Title); err != nil {
+			return nil, err
+		}
+		state[uuid] = t
+	}
+	return state, nil
+}
+
+// SnapshotList diffs the list against its previous snapshot in a
+// transaction holding the list's row, so two snapshots taken at once cannot
+// both diff against the same one.
+func (s *SQLStore) SnapshotList(ctx context.Context, id int64) (ListSnapshot, error) {
+	var snap ListSnapshot
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+		lock := `SELECT id FROM lists WHERE id = $1` + owned
+		if s.dialect.name != sqliteDialect.name {
+			lock += ` FOR UPDATE`
+		}
+		var listID int64
+		err := tx.QueryRowContext(ctx, s.dialect.rebind(lock), append([]any{id}, ownerArgs...)...).Scan(&listID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrListNotFound
+		} else if err != nil {
+			return err
+		}
+		prev, err := s.snapshotDiffs(ctx, tx, id, 0)
+		if err != nil {
+			return err
+		}
+		state, err := s.openSnapshotState(ctx, prev)
+		if err != nil {
+			return err
+		}
+		todos, err := s.queryTodos(ctx, tx, `SELECT `+todoColumns+` FROM todos WHERE list_id = $1`+owned+` ORDER BY id`, append([]any{id}, ownerArgs...)...)
+		if err != nil {
+			return err
+		}
+		diff, err := diffSnapshot(state, todos)
+		if err != nil {
+			return err
+		}
+		for uuid, t := range diff.Set {
+			if t.Title, err = s.sealTitle(ctx, t.ID, t.OwnerID, t.Title); err != nil {
+				return err
+			}
+			diff.Set[uuid] = t
+		}
+		data, err := json.Marshal(diff)
+		if err != nil {
+			return fmt.Errorf("encode list snapshot: %w", err)
+		}
+
+		insert := `INSERT INTO list_snapshots (list_id, todos, data, owner_id) VALUES ($1, $2, $3, $4)`
+		args := []any{id, len(todos), string(data), ownerValue(ctx)}
+		snap = ListSnapshot{ListID: id, Todos: len(todos)}
+		if s.dialect.returning {
+			err = tx.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING id, created_at`), args...).Scan(&snap.ID, &snap.CreatedAt)
+		} else {
+			var res sql.Result
+			if res, err = tx.ExecContext(ctx, s.dialect.rebind(insert), args...); err != nil {
+				return err
+			}
+			if snap.ID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+			err = tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT created_at FROM list_snapshots WHERE id = $1`), snap.ID).Scan(&snap.CreatedAt)
+		}
+		if err != nil {
+			return err
+		}
+		if len(prev) < maxListSnapshots {
+			return nil
+		}
+		return s.foldSnapshots(ctx, tx, prev[0], prev[1])
+	})
+	if err != nil {
+		return ListSnapshot{}, err
+	}
+	slog.InfoContext(ctx, "list.snapshot_taken", "id", id, "snapshot_id", snap.ID, "todos", snap.Todos)
+	return snap, nil
+}
+
+// foldSnapshots deletes a list's oldest snapshot, rewriting the next one to
+// set every todo in its stead.
+func (s *SQLStore) foldSnapshots(ctx context.Context, tx *sql.Tx, oldest, next storedSnapshot) error {
+	state := map[string]Todo{}
+	oldest.diff.apply(state)
+	next.diff.apply(state)
+	data, err := json.Marshal(snapshotDiff{Set: state})
+	if err != nil {
+		return fmt.Errorf("encode list snapshot: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE list_snapshots SET data = $1 WHERE id = $2`), string(data), next.id); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM list_snapshots WHERE id = $1`), oldest.id)
+	return err
+}
+
+// ListSnapshots lists the snapshots of list id, newest first.
+func (s *SQLStore) ListSnapshots(ctx context.Context, id int64) ([]ListSnapshot, error) {
+	if _, err := s.GetList(ctx, id); err != nil {
+		return nil, err
+	}
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT id, list_id, todos, created_at FROM list_snapshots WHERE list_id = $1 ORDER BY id DESC`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []ListSnapshot{}
+	for rows.Next() {
+		var snap ListSnapshot
+		if err := rows.Scan(&snap.ID, &snap.ListID, &snap.Todos, &snap.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, snap)
+	}
+	return out, rows.Err()
+}
+
+// SnapshotTodos replays the snapshots of list id up to snapshotID.
+func (s *SQLStore) SnapshotTodos(ctx context.Context, id, snapshotID int64) ([]Todo, error) {
+	if _, err := s.GetList(ctx, id); err != nil {
+		return nil, err
+	}
+	var state map[string]Todo
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		snaps, err := s.snapshotDiffs(ctx, tx, id, snapshotID)
+		if err != nil {
+			return err
+		}
+		if len(snaps) == 0 || snaps[len(snaps)-1].id != snapshotID {
+			return ErrSnapshotNotFound
+		}
+		state, err = s.openSnapshotState(ctx, snaps)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshotState(state), nil
+}
+
+// listSnapshotsBucket keys snapshots by list id followed by snapshot id, so
+// each list's snapshots are a contiguous key range, oldest first.
+var listSnapshotsBucket = []byte("list_snapshots")
+
+type boltListSnapshot struct {
+	ListSnapshot
+	Diff snapshotDiff `json:"diff"`
+}
+
+// boltListSnapshots returns the snapshots of list id, oldest first, with
+// their keys.
+func boltListSnapshots(tx *bolt.Tx, id int64) (keys [][]byte, snaps []boltListSnapshot, err error) {
+	prefix := boltKey(id)
+	c := tx.Bucket(listSnapshotsBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var snap boltListSnapshot
+		if err := json.Unmarshal(v, &snap); err != nil {
+			return nil, nil, fmt.Errorf("decode list snapshot: %w", err)
+		}
+		keys = append(keys, bytes.Clone(k))
+		snaps = append(snaps, snap)
+	}
+	return keys, snaps, nil
+}
+
+func putBoltListSnapshot(b *bolt.Bucket, key []byte, snap boltListSnapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encode list snapshot: %w", err)
+	}
+	return b.Put(key, data)
+}
+
+// deleteBoltListSnapshots deletes the snapshots of list id.
+func deleteBoltListSnapshots(tx *bolt.Tx, id int64) error {
+	keys, _, err := boltListSnapshots(tx, id)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket(listSnapshotsBucket)
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapshotList diffs the list against its previous snapshot in one write
+// transaction.
+func (s *BoltStore) SnapshotList(ctx context.Context, id int64) (ListSnapshot, error) {
+	var snap boltListSnapshot
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		if err := boltListRef(ctx, tx, &id); err != nil {
+			return err
+		}
+		keys, prev, err := boltListSnapshots(tx, id)
+		if err != nil {
+			return err
+		}
+		state := map[string]Todo{}
+		for _, p := range prev {
+			p.Diff.apply(state)
+		}
+		var todos []Todo
+		err = tx.Bucket(todosBucket).ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if t.ListID != nil && *t.ListID == id && visible(ctx, t.OwnerID) {
+				todos = append(todos, t)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		diff, err := diffSnapshot(state, todos)
+		if err != nil {
+			return err
+		}
+		b := tx.Bucket(listSnapshotsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		snap = boltListSnapshot{
+			ListSnapshot: ListSnapshot{ID: int64(seq), ListID: id, Todos: len(todos), CreatedAt: time.Now().UTC()},
+			Diff:         diff,
+		}
+		if err := putBoltListSnapshot(b, append(boltKey(id), boltKey(snap.ID)...), snap); err != nil {
+			return err
+		}
+		if len(prev) < maxListSnapshots {
+			return nil
+		}
+		folded := map[string]Todo{}
+		prev[0].Diff.apply(folded)
+		prev[1].Diff.apply(folded)
+		prev[1].Diff = snapshotDiff{Set: folded}
+		if err := putBoltListSnapshot(b, keys[1], prev[1]); err != nil {
+			return err
+		}
+		return b.Delete(keys[0])
+	})
+	if err != nil {
+		return ListSnapshot{}, err
+	}
+	slog.InfoContext(ctx, "list.snapshot_taken", "id", id, "snapshot_id", snap.ID, "todos", snap.Todos)
+	return snap.ListSnapshot, nil
+}
+
+// ListSnapshots lists the snapshots of list id, newest first.
+func (s *BoltStore) ListSnapshots(ctx context.Context, id int64) ([]ListSnapshot, error) {
+	out := []ListSnapshot{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if err := boltListRef(ctx, tx, &id); err != nil {
+			return err
+		}
+		_, snaps, err := boltListSnapshots(tx, id)
+		for i := len(snaps) - 1; i >= 0; i-- {
+			out = append(out, snaps[i].ListSnapshot)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SnapshotTodos replays the snapshots of list id up to snapshotID.
+func (s *BoltStore) SnapshotTodos(ctx context.Context, id, snapshotID int64) ([]Todo, error) {
+	state := map[string]Todo{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if err := boltListRef(ctx, tx, &id); err != nil {
+			return err
+		}
+		_, snaps, err := boltListSnapshots(tx, id)
+		if err != nil {
+			return err
+		}
+		for _, snap := range snaps {
+			snap.Diff.apply(state)
+			if snap.ID == snapshotID {
+				return nil
+			}
+		}
+		return ErrSnapshotNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshotState(state), nil
+}
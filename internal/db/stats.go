@@ -0,0 +1,299 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PriorityBuckets is how many equal-width ranges of the [0, 1] priority
+// score TodoStats counts todos in.
+const PriorityBuckets = 10
+
+// TodoStats are aggregates over the todos created from Since (midnight UTC)
+// up to now. Completion is as of now, or for days served from the rollup,
+// as of its last refresh.
+type TodoStats struct {
+	Since          time.Time `json:"since"`
+	Created        int64     `json:"created"`
+	Completed      int64     `json:"completed"`
+	CompletionRate float64   `json:"completionRate"`
+	// Days has an entry for every day of the range, oldest first.
+	Days []DayStats `json:"days"`
+	// DurationByTag covers the todos with a duration, most used tag first.
+	DurationByTag []TagDuration `json:"durationByTag"`
+	// Priority has PriorityBuckets entries, lowest scores first.
+	Priority []PriorityBucket `json:"priority"`
+}
+
+// DayStats counts the todos created on Date and how many of them are done.
+type DayStats struct {
+	Date      string `json:"date"`
+	Created   int64  `json:"created"`
+	Completed int64  `json:"completed"`
+}
+
+// TagDuration is the average duration of the todos carrying Tag.
+type TagDuration struct {
+	Tag            string  `json:"tag"`
+	Todos          int64   `json:"todos"`
+	AverageMinutes float64 `json:"averageMinutes"`
+}
+
+// PriorityBucket counts the todos scored from Min up to (excluding, but for
+// the last bucket) Max.
+type PriorityBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Todos int64   `json:"todos"`
+}
+
+// StatsStore is implemented by backends that aggregate todo statistics
+// without returning the todos themselves.
+type StatsStore interface {
+	TodoStats(ctx context.Context, since time.Time) (TodoStats, error)
+}
+
+// StatsRollup is implemented by backends that can precompute TodoStats per
+// day, so requests only aggregate the todos created since the last refresh.
+type StatsRollup interface {
+	// RefreshStatsRollup rebuilds the rollup for the days from since up to,
+	// excluding, today.
+	RefreshStatsRollup(ctx context.Context, since time.Time) error
+}
+
+// Kinds of statsRow.
+const (
+	statsDay      = "day"
+	statsTag      = "tag"
+	statsPriority = "priority"
+)
+
+// statsRow is one aggregate of the todos created on day: per day their
+// number and how many are done, per tag (bucket) the number with a
+// duration and its total, per priority bucket their number.
+type statsRow struct {
+	day, kind, bucket         string
+	todos, completed, minutes int64
+}
+
+// utcDay returns midnight UTC of t's day.
+func utcDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// summarizeStats folds rows into the stats of the days from since to now.
+func summarizeStats(since, now time.Time, rows []statsRow) TodoStats {
+	since = utcDay(since)
+	st := TodoStats{Since: since, Days: []DayStats{}, DurationByTag: []TagDuration{}, Priority: make([]PriorityBucket, PriorityBuckets)}
+	index := map[string]int{}
+	for d := since; !d.After(now); d = d.AddDate(0, 0, 1) {
+		index[d.Format(time.DateOnly)] = len(st.Days)
+		st.Days = append(st.Days, DayStats{Date: d.Format(time.DateOnly)})
+	}
+	for i := range st.Priority {
+		st.Priority[i].Min = float64(i) / PriorityBuckets
+		st.Priority[i].Max = float64(i+1) / PriorityBuckets
+	}
+	tags := map[string]*TagDuration{}
+	minutes := map[string]int64{}
+	for _, r := range rows {
+		i, ok := index[r.day]
+		if !ok {
+			continue
+		}
+		switch r.kind {
+		case statsDay:
+			st.Days[i].Created += r.todos
+			st.Days[i].Completed += r.completed
+			st.Created += r.todos
+			st.Completed += r.completed
+		case statsTag:
+			t := tags[r.bucket]
+			if t == nil {
+				t = &TagDuration{Tag: r.bucket}
+				tags[r.bucket] = t
+			}
+			t.Todos += r.todos
+			minutes[r.bucket] += r.minutes
+		case statsPriority:
+			if b, err := strconv.Atoi(r.bucket); err == nil && b >= 0 && b < PriorityBuckets {
+				st.Priority[b].Todos += r.todos
+			}
+		}
+	}
+	if st.Created > 0 {
+		st.CompletionRate = float64(st.Completed) / float64(st.Created)
+	}
+	for tag, t := range tags {
+		t.AverageMinutes = float64(minutes[tag]) / float64(t.Todos)
+		st.DurationByTag = append(st.DurationByTag, *t)
+	}
+	slices.SortFunc(st.DurationByTag, func(a, b TagDuration) int {
+		if c := cmp.Compare(b.Todos, a.Todos); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+	return st
+}
+
+// priorityBucket renders the PriorityBuckets bucket of the score col as
+// text, '0' for the lowest.
+func priorityBucket(col string) string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i := 1; i < PriorityBuckets; i++ {
+		fmt.Fprintf(&b, " WHEN %s < %g THEN '%d'", col, float64(i)/PriorityBuckets, i-1)
+	}
+	fmt.Fprintf(&b, " ELSE '%d' END", PriorityBuckets-1)
+	return b.String()
+}
+
+// statsSelects are the aggregates behind TodoStats over the todos t
+// matching cond, yielding statsRow columns (day, kind, bucket, todos,
+// completed, minutes) and with perOwner t.owner_id after the day.
+func (s *SQLStore) statsSelects(cond string, perOwner bool) []string {
+	day, owner, group, bucket := s.dialect.day("t.created_at"), "", "1", "3"
+	if perOwner {
+		owner, group, bucket = "t.owner_id, ", "1, 2", "4"
+	}
+	return []string{
+		`SELECT ` + day + `, ` + owner + `'` + statsDay + `', '', COUNT(*), SUM(CASE WHEN t.completed THEN 1 ELSE 0 END), 0
+		 FROM todos t WHERE ` + cond + ` GROUP BY ` + group,
+		`SELECT ` + day + `, ` + owner + `'` + statsTag + `', tag.value, COUNT(*), 0, SUM(t.duration_minutes)
+		 FROM todos t, ` + s.dialect.eachTag("t.tags", "tag") + `
+		 WHERE t.duration_minutes > 0 AND ` + cond + ` GROUP BY ` + group + `, ` + bucket,
+		`SELECT ` + day + `, ` + owner + `'` + statsPriority + `', ` + priorityBucket("t.priority_score") + `, COUNT(*), 0, 0
+		 FROM todos t WHERE ` + cond + ` GROUP BY ` + group + `, ` + bucket,
+	}
+}
+
+// TodoStats aggregates the todos created from since on: days the rollup
+// covers are read from it, the rest from todos.
+func (s *SQLStore) TodoStats(ctx context.Context, since time.Time) (TodoStats, error) {
+	since = utcDay(since)
+	var fromDay, toDay any
+	err := s.SQL.QueryRowContext(ctx, `SELECT from_day, to_day FROM todo_stats_rollup WHERE id = 1`).Scan(&fromDay, &toDay)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return TodoStats{}, err
+	}
+	from, to := dayOf(fromDay), dayOf(toDay)
+
+	var rows []statsRow
+	liveSince := since
+	if from != "" && from <= since.Format(time.DateOnly) && to > since.Format(time.DateOnly) {
+		owned, ownerArgs := ownerCond(ctx, "owner_id", 2)
+		rolled, err := s.queryStats(ctx, `SELECT day, kind, bucket, SUM(todos), SUM(completed), SUM(minutes)
+			 FROM todo_stats_daily WHERE day >= $1 AND day < $2`+owned+` GROUP BY 1, 2, 3`,
+			append([]any{since.Format(time.DateOnly), to}, ownerArgs...)...)
+		if err != nil {
+			return TodoStats{}, err
+		}
+		rows = rolled
+		if liveSince, err = time.Parse(time.DateOnly, to); err != nil {
+			return TodoStats{}, err
+		}
+	}
+	owned, ownerArgs := ownerCond(ctx, "t.owner_id", 1)
+	for _, q := range s.statsSelects(`t.created_at >= $1`+owned, false) {
+		live, err := s.queryStats(ctx, q, append([]any{liveSince}, ownerArgs...)...)
+		if err != nil {
+			return TodoStats{}, err
+		}
+		rows = append(rows, live...)
+	}
+	return summarizeStats(since, s.now(), rows), nil
+}
+
+func (s *SQLStore) queryStats(ctx context.Context, query string, args ...any) ([]statsRow, error) {
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query todo stats: %w", err)
+	}
+	defer rows.Close()
+	var out []statsRow
+	for rows.Next() {
+		var r statsRow
+		var day any
+		if err := rows.Scan(&day, &r.kind, &r.bucket, &r.todos, &r.completed, &r.minutes); err != nil {
+			return nil, err
+		}
+		r.day = dayOf(day)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// dayOf formats a day column as 'YYYY-MM-DD'. Drivers return dates as
+// time.Time or text; NULL is "".
+func dayOf(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.DateOnly)
+	case string:
+		return v[:min(len(v), len(time.DateOnly))]
+	case []byte:
+		return string(v[:min(len(v), len(time.DateOnly))])
+	}
+	return ""
+}
+
+// RefreshStatsRollup rebuilds todo_stats_daily for the days from since up
+// to today, which is left to the live aggregates as its todos still change.
+// Refreshes on several replicas queue on the todo_stats_rollup row.
+func (s *SQLStore) RefreshStatsRollup(ctx context.Context, since time.Time) error {
+	from, to := utcDay(since), utcDay(s.now())
+	return s.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE todo_stats_rollup SET refreshed_at = $1 WHERE id = 1`), s.now()); err != nil {
+			return fmt.Errorf("lock stats rollup: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM todo_stats_daily`); err != nil {
+			return err
+		}
+		for _, q := range s.statsSelects(`t.created_at >= $1 AND t.created_at < $2`, true) {
+			_, err := tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO todo_stats_daily (day, owner_id, kind, bucket, todos, completed, minutes) `+q), from, to)
+			if err != nil {
+				return fmt.Errorf("roll up todo stats: %w", err)
+			}
+		}
+		_, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE todo_stats_rollup SET from_day = $1, to_day = $2 WHERE id = 1`),
+			from.Format(time.DateOnly), to.Format(time.DateOnly))
+		return err
+	})
+}
+
+// TodoStats aggregates the todos created from since on.
+func (s *BoltStore) TodoStats(ctx context.Context, since time.Time) (TodoStats, error) {
+	since = utcDay(since)
+	var rows []statsRow
+	err := s.StreamTodos(ctx, TodoFilter{}, func(t Todo) error {
+		if t.CreatedAt.Before(since) {
+			return nil
+		}
+		day := t.CreatedAt.UTC().Format(time.DateOnly)
+		r := statsRow{day: day, kind: statsDay, todos: 1}
+		if t.Completed {
+			r.completed = 1
+		}
+		bucket := min(max(int(t.PriorityScore*PriorityBuckets), 0), PriorityBuckets-1)
+		rows = append(rows, r, statsRow{day: day, kind: statsPriority, bucket: strconv.Itoa(bucket), todos: 1})
+		if t.DurationMinutes > 0 {
+			for _, tag := range t.Tags {
+				rows = append(rows, statsRow{day: day, kind: statsTag, bucket: tag, todos: 1, minutes: int64(t.DurationMinutes)})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return TodoStats{}, err
+	}
+	return summarizeStats(since, s.now(), rows), nil
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a todo does not exist.
+var ErrNotFound = errors.New("todo not found")
+
+// ErrPreconditionFailed is returned by a conditional update when the todo
+// changed after the version the caller based its write on.
+var ErrPreconditionFailed = errors.New("todo was modified concurrently")
+
+// Store is the storage layer the HTTP server depends on. Backends beyond
+// PostgreSQL (BoltDB, SQLite) implement it so the app can run without a
+// database server.
+type Store interface {
+	ListTodos(ctx context.Context) ([]Todo, error)
+	GetTodo(ctx context.Context, id int64) (Todo, error)
+	CreateTodo(ctx context.Context, input SaveTodoInput) (Todo, error)
+	UpdateTodo(ctx context.Context, id int64, input SaveTodoInput) (Todo, error)
+	DeleteTodo(ctx context.Context, id int64) error
+	Close() error
+}
+
+// FilteredLister is implemented by backends that can list a filtered subset of todos.
+type FilteredLister interface {
+	ListTodosFiltered(ctx context.Context, filter TodoFilter) ([]Todo, error)
+}
+
+// TodoStreamer is implemented by backends that can iterate todos without
+// materializing the whole result. Iteration stops at the first error from fn.
+type TodoStreamer interface {
+	StreamTodos(ctx context.Context, filter TodoFilter, fn func(Todo) error) error
+}
+
+// TodoCounter is implemented by backends that can count todos cheaply.
+type TodoCounter interface {
+	CountTodos(ctx context.Context) (TodoCounts, error)
+}
+
+// TodoCounts holds the number of todos in total, still open, and open
+// past their due time.
+type TodoCounts struct {
+	Total   int64 `json:"total"`
+	Open    int64 `json:"open"`
+	Overdue int64 `json:"overdue"`
+}
+
+// SchemaReporter is implemented by backends that can describe their schema.
+type SchemaReporter interface {
+	SchemaReport(ctx context.Context) (SchemaReport, error)
+}
+
+// ConditionalUpdater is implemented by backends that can update a todo only
+// if it is still at the version the caller read, identified by UpdatedAt, so
+// concurrent edits cannot silently overwrite each other.
+type ConditionalUpdater interface {
+	UpdateTodoIfUnmodified(ctx context.Context, id int64, input SaveTodoInput, version time.Time) (Todo, error)
+}
+
+// Warmer is implemented by backends with a connection pool that can be
+// filled ahead of traffic.
+type Warmer interface {
+	Warm(ctx context.Context, conns int) (int, error)
+}
+
+// Pinger is implemented by backends that can check their database answers.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Option configures how a Store is opened.
+type Option func(*options)
+
+type options struct {
+	credentials    CredentialsProvider
+	skipMigrations bool
+	cacheTTL       time.Duration
+	cacheEntries   int
+	encryption     *Encryption
+	pool           *Pool
+	connectTimeout *time.Duration
+	clock          func() time.Time
+	uuids          func() (string, error)
+	auditChain     bool
+}
+
+// Pool sizes an SQL store's connection pool.
+type Pool struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration // zero keeps connections open indefinitely
+}
+
+// WithPool sizes the connection pool of an SQL store, replacing the default
+// of 10 open and 5 idle connections recycled every 30 minutes.
+func WithPool(p Pool) Option {
+	return func(o *options) {
+		o.pool = &p
+	}
+}
+
+// WithConnectTimeout bounds how long opening an SQL store keeps retrying a
+// database that does not answer yet, backing off between attempts, so the
+// server can start alongside its database. The default is 30 seconds; zero
+// tries once.
+func WithConnectTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.connectTimeout = &d
+	}
+}
+
+// WithCredentials makes every new pooled connection ask p for its password,
+// overriding any password embedded in the DSN.
+func WithCredentials(p CredentialsProvider) Option {
+	return func(o *options) {
+		o.credentials = p
+	}
+}
+
+// WithTodoCache keeps recently read or written todos in memory for ttl, so the
+// read-then-write update flow only hits the database once. Writes through the
+// store keep the cache coherent; ttl bounds staleness across replicas.
+func WithTodoCache(ttl time.Duration, maxEntries int) Option {
+	return func(o *options) {
+		o.cacheTTL = ttl
+		o.cacheEntries = maxEntries
+	}
+}
+
+// WithoutMigrations opens the store without applying migrations, for tooling
+// that must observe the schema as it is.
+func WithoutMigrations() Option {
+	return func(o *options) {
+		o.skipMigrations = true
+	}
+}
+
+func buildOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// UUIDUpserter is implemented by backends that can create-or-update a todo
+// keyed by its UUID in a single idempotent statement.
+type UUIDUpserter interface {
+	UpsertTodoByUUID(ctx context.Context, uuid string, input SaveTodoInput) (t Todo, created bool, err error)
+}
+
+// Open returns the Store matching the DSN scheme. "bolt://<path>" opens an
+// embedded BoltDB file, "sqlite://<path>" an SQLite file, "mysql://" a
+// MySQL/MariaDB server; anything else is treated as a PostgreSQL DSN.
+func Open(dsn string, opts ...Option) (Store, error) {
+	if path, ok := strings.CutPrefix(dsn, "bolt://"); ok {
+		if buildOptions(opts).encryption != nil {
+			return nil, errors.New("title encryption not supported by bolt")
+		}
+		return NewBoltStore(path, opts...)
+	}
+	if path, ok := strings.CutPrefix(dsn, "sqlite://"); ok {
+		return NewSQLiteStore(path, opts...)
+	}
+	if strings.HasPrefix(dsn, "mysql://") {
+		return NewMySQLStore(dsn, opts...)
+	}
+	return NewPostgresStore(dsn, opts...)
+}
+
+// Backend names the database behind store: "bolt", or the SQL dialect
+// ("postgres", "cockroach", "mysql", "sqlite").
+func Backend(store Store) string {
+	switch s := store.(type) {
+	case *BoltStore:
+		return "bolt"
+	case *SQLStore:
+		return s.dialect.name
+	}
+	return "unknown"
+}
+
+// Todo represents a todo item.
+type Todo struct {
+	ID              int64    `json:"id"`
+	UUID            string   `json:"uuid"`
+	Title           string   `json:"title"`
+	Completed       bool     `json:"completed"`
+	Tags            []string `json:"tags"`
+	DurationMinutes int      `json:"durationMinutes"`
+	PriorityScore   float64  `json:"priorityScore"`
+	// EffectivePriority is PriorityScore decayed by the time since the todo
+	// was last updated. The server sets it on the way out; it is not stored.
+	EffectivePriority *float64 `json:"effectivePriority,omitempty"`
+	// DueAt is the deadline; StartAt defers the todo until that time, hiding
+	// it from default views. Both are optional.
+	DueAt   *time.Time `json:"dueAt"`
+	StartAt *time.Time `json:"startAt"`
+	// Effort is an optional estimate in points, feeding velocity stats.
+	Effort *int `json:"effort,omitempty"`
+	// ReminderOffsets overrides the default reminders for this todo, in
+	// minutes before DueAt. Nil means "use the defaults"; empty means none.
+	ReminderOffsets []int `json:"reminderOffsets"`
+	// Recurrence is an RRULE (see ParseRecurrence); completing a recurring
+	// todo creates its next occurrence. Empty for one-off todos.
+	Recurrence string `json:"recurrence,omitempty"`
+	// ListID is the list the todo belongs to; nil for todos in no list.
+	ListID *int64 `json:"listId"`
+	// OwnerID is the user the todo belongs to; zero for unowned todos
+	// created without authentication.
+	OwnerID   int64     `json:"ownerId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SaveTodoInput represents the fields accepted for create/update operations.
+type SaveTodoInput struct {
+	Title           string
+	Completed       bool
+	Tags            []string
+	DurationMinutes int
+	PriorityScore   float64
+	DueAt           *time.Time
+	StartAt         *time.Time
+	Effort          *int
+	ReminderOffsets []int
+	Recurrence      string
+	// ListID must name a list visible to the caller; the store fails with
+	// ErrListNotFound otherwise.
+	ListID *int64
+}
+
+func validateInput(input SaveTodoInput) error {
+	if len(input.Title) == 0 {
+		return errors.New("title must not be empty")
+	}
+	if len(input.Title) > 200 {
+		return errors.New("title too long")
+	}
+	if input.DurationMinutes < 0 {
+		return errors.New("duration must be >= 0")
+	}
+	if input.Effort != nil && (*input.Effort < 0 || *input.Effort > 1000) {
+		return errors.New("effort must be between 0 and 1000")
+	}
+	if err := validateReminderOffsets(input.ReminderOffsets); err != nil {
+		return err
+	}
+	if input.Recurrence != "" {
+		if len(input.Recurrence) > 255 {
+			return errors.New("recurrence too long")
+		}
+		if _, err := ParseRecurrence(input.Recurrence); err != nil {
+			return err
+		}
+	}
+	if input.DueAt != nil && input.StartAt != nil && input.StartAt.After(*input.DueAt) {
+		return errors.New("start date must not be after due date")
+	}
+	return nil
+}
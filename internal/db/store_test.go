@@ -0,0 +1,32 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// testStore is what the tests use of a backend.
+type testStore interface {
+	Store
+	AuditChainVerifier
+}
+
+// openTestStores opens a fresh Bolt and SQLite store in t's temporary
+// directory, closed when t ends.
+func openTestStores(t *testing.T, opts ...Option) map[string]testStore {
+	t.Helper()
+	dir := t.TempDir()
+	bolt, err := NewBoltStore(filepath.Join(dir, "todo.bolt"), opts...)
+	if err != nil {
+		t.Fatalf("open bolt: %v", err)
+	}
+	sqlite, err := NewSQLiteStore(filepath.Join(dir, "todo.sqlite"), opts...)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = bolt.Close()
+		_ = sqlite.Close()
+	})
+	return map[string]testStore{"bolt": bolt, "sqlite": sqlite}
+}
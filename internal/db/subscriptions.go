@@ -0,0 +1,331 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrSubscriptionNotFound is returned when a subscription does not exist.
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// maxDueWithinHours keeps subscription due windows within a month.
+const maxDueWithinHours = 31 * 24
+
+// Subscription is a saved filter its owner is notified about whenever an
+// open todo starts matching it: "anything tagged finance due this week" is
+// Tag "finance" with DueWithinHours 168. Each todo is notified once per
+// subscription.
+type Subscription struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Tag    string `json:"tag,omitempty"`
+	ListID *int64 `json:"listId,omitempty"`
+	// DueWithinHours keeps todos due within that many hours, overdue ones
+	// included; zero matches regardless of due date.
+	DueWithinHours int       `json:"dueWithinHours,omitempty"`
+	OwnerID        int64     `json:"ownerId,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// Filter returns the filter selecting the todos matching s at now.
+func (s Subscription) Filter(now time.Time) TodoFilter {
+	open := false
+	f := TodoFilter{Completed: &open, Tag: s.Tag}
+	if s.ListID != nil {
+		f.ListID = *s.ListID
+	}
+	if s.DueWithinHours > 0 {
+		f.DueBefore = dueWithin(now, s.DueWithinHours)
+	}
+	return f
+}
+
+// dueWithin returns the due date bound of a window of hours from now.
+func dueWithin(now time.Time, hours int) time.Time {
+	return now.Add(time.Duration(hours) * time.Hour)
+}
+
+// SaveSubscriptionInput represents the fields accepted for subscription
+// create.
+type SaveSubscriptionInput struct {
+	Name           string
+	Tag            string
+	ListID         *int64
+	DueWithinHours int
+}
+
+// SubscriptionStore is implemented by backends that support saved search
+// subscriptions.
+type SubscriptionStore interface {
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	CreateSubscription(ctx context.Context, input SaveSubscriptionInput) (Subscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	// MarkNotified records that subscription id notified about todoIDs and
+	// returns those it had not notified about before.
+	MarkNotified(ctx context.Context, id int64, todoIDs []int64) ([]int64, error)
+}
+
+// validateSubscriptionInput checks and normalizes input; a subscription
+// matching every open todo is refused.
+func validateSubscriptionInput(input *SaveSubscriptionInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	if len(input.Name) > 100 {
+		return errors.New("name too long")
+	}
+	input.Tag = strings.ToLower(strings.TrimSpace(input.Tag))
+	if len(input.Tag) > 32 {
+		return errors.New("tag too long")
+	}
+	if input.DueWithinHours < 0 || input.DueWithinHours > maxDueWithinHours {
+		return fmt.Errorf("dueWithinHours must be between 0 and %d", maxDueWithinHours)
+	}
+	if input.Tag == "" && input.ListID == nil && input.DueWithinHours == 0 {
+		return errors.New("set at least one of tag, listId and dueWithinHours")
+	}
+	return nil
+}
+
+const subscriptionColumns = `id, name, tag, list_id, due_within_hours, owner_id, created_at`
+
+func scanSubscription(row rowScanner) (Subscription, error) {
+	var sub Subscription
+	var list, owner sql.NullInt64
+	if err := row.Scan(&sub.ID, &sub.Name, &sub.Tag, &list, &sub.DueWithinHours, &owner, &sub.CreatedAt); err != nil {
+		return Subscription{}, err
+	}
+	if list.Valid {
+		sub.ListID = &list.Int64
+	}
+	sub.OwnerID = owner.Int64
+	return sub, nil
+}
+
+// ListSubscriptions returns all subscriptions ordered by id.
+func (s *SQLStore) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 0)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT `+subscriptionColumns+` FROM subscriptions WHERE TRUE`+owned+` ORDER BY id ASC`), ownerArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
+
+// CreateSubscription creates a new subscription.
+func (s *SQLStore) CreateSubscription(ctx context.Context, input SaveSubscriptionInput) (Subscription, error) {
+	if err := validateSubscriptionInput(&input); err != nil {
+		return Subscription{}, err
+	}
+	if err := s.checkListRef(ctx, s.SQL, input.ListID); err != nil {
+		return Subscription{}, err
+	}
+	insert := `INSERT INTO subscriptions (name, tag, list_id, due_within_hours, owner_id) VALUES ($1, $2, $3, $4, $5)`
+	args := []any{input.Name, input.Tag, input.ListID, input.DueWithinHours, ownerValue(ctx)}
+
+	var sub Subscription
+	var err error
+	if s.dialect.returning {
+		if sub, err = scanSubscription(s.SQL.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+subscriptionColumns), args...)); err != nil {
+			return Subscription{}, err
+		}
+	} else {
+		res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(insert), args...)
+		if err != nil {
+			return Subscription{}, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return Subscription{}, err
+		}
+		if sub, err = scanSubscription(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1`), id)); err != nil {
+			return Subscription{}, err
+		}
+	}
+	slog.InfoContext(ctx, "subscription.created", "id", sub.ID)
+	return sub, nil
+}
+
+// DeleteSubscription deletes a subscription and its notification record.
+func (s *SQLStore) DeleteSubscription(ctx context.Context, id int64) error {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`DELETE FROM subscriptions WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		slog.InfoContext(ctx, "subscription.deleted", "id", id)
+	}
+	return nil
+}
+
+// MarkNotified inserts a match per todo; the primary key turns todos
+// already notified about into no-ops.
+func (s *SQLStore) MarkNotified(ctx context.Context, id int64, todoIDs []int64) ([]int64, error) {
+	fresh := []int64{}
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		insert := s.dialect.rebind(s.dialect.insertIgnore(`INSERT INTO subscription_matches (subscription_id, todo_id) VALUES ($1, $2)`))
+		for _, todoID := range todoIDs {
+			res, err := tx.ExecContext(ctx, insert, id, todoID)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return err
+			} else if n > 0 {
+				fresh = append(fresh, todoID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record subscription matches: %w", err)
+	}
+	return fresh, nil
+}
+
+var (
+	subscriptionsBucket = []byte("subscriptions")
+	// subscriptionMatchesBucket keys matches by subscription id followed by
+	// todo id, so each subscription's matches are a contiguous key range.
+	subscriptionMatchesBucket = []byte("subscription_matches")
+)
+
+// ListSubscriptions returns all subscriptions ordered by id.
+func (s *BoltStore) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	out := []Subscription{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(subscriptionsBucket).ForEach(func(_, v []byte) error {
+			var sub Subscription
+			if err := json.Unmarshal(v, &sub); err != nil {
+				return fmt.Errorf("decode subscription: %w", err)
+			}
+			if visible(ctx, sub.OwnerID) {
+				out = append(out, sub)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateSubscription creates a new subscription.
+func (s *BoltStore) CreateSubscription(ctx context.Context, input SaveSubscriptionInput) (Subscription, error) {
+	if err := validateSubscriptionInput(&input); err != nil {
+		return Subscription{}, err
+	}
+	if input.ListID != nil {
+		if _, err := s.GetList(ctx, *input.ListID); err != nil {
+			return Subscription{}, err
+		}
+	}
+	var sub Subscription
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(subscriptionsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		sub = Subscription{
+			ID:             int64(seq),
+			Name:           input.Name,
+			Tag:            input.Tag,
+			ListID:         input.ListID,
+			DueWithinHours: input.DueWithinHours,
+			CreatedAt:      time.Now().UTC(),
+		}
+		sub.OwnerID, _ = OwnerFrom(ctx)
+		data, err := json.Marshal(sub)
+		if err != nil {
+			return fmt.Errorf("encode subscription: %w", err)
+		}
+		return b.Put(boltKey(sub.ID), data)
+	})
+	if err != nil {
+		return Subscription{}, err
+	}
+	slog.InfoContext(ctx, "subscription.created", "id", sub.ID)
+	return sub, nil
+}
+
+// DeleteSubscription deletes a subscription and its notification record.
+func (s *BoltStore) DeleteSubscription(ctx context.Context, id int64) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(subscriptionsBucket)
+		v := b.Get(boltKey(id))
+		if v == nil {
+			return nil
+		}
+		var sub Subscription
+		if err := json.Unmarshal(v, &sub); err != nil {
+			return fmt.Errorf("decode subscription: %w", err)
+		}
+		if !visible(ctx, sub.OwnerID) {
+			return nil
+		}
+		if err := b.Delete(boltKey(id)); err != nil {
+			return err
+		}
+		matches := tx.Bucket(subscriptionMatchesBucket)
+		prefix := boltKey(id)
+		c := matches.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+			if err := matches.Delete(k); err != nil {
+				return err
+			}
+		}
+		slog.InfoContext(ctx, "subscription.deleted", "id", id)
+		return nil
+	})
+}
+
+// MarkNotified records a match per todo not recorded yet. Matches of
+// deleted todos stay until the subscription is deleted.
+func (s *BoltStore) MarkNotified(ctx context.Context, id int64, todoIDs []int64) ([]int64, error) {
+	fresh := []int64{}
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		matches := tx.Bucket(subscriptionMatchesBucket)
+		now, err := time.Now().UTC().MarshalText()
+		if err != nil {
+			return err
+		}
+		for _, todoID := range todoIDs {
+			key := append(boltKey(id), boltKey(todoID)...)
+			if matches.Get(key) != nil {
+				continue
+			}
+			if err := matches.Put(key, now); err != nil {
+				return err
+			}
+			fresh = append(fresh, todoID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record subscription matches: %w", err)
+	}
+	return fresh, nil
+}
@@ -0,0 +1,291 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// maxTimeEntryMinutes caps one time entry at a day.
+const maxTimeEntryMinutes = 24 * 60
+
+// TimeEntry is time spent on a todo, as tracked by a client's timer or
+// entered by hand.
+type TimeEntry struct {
+	ID        int64     `json:"id"`
+	TodoID    int64     `json:"todoId"`
+	Minutes   int       `json:"minutes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Accuracy compares estimated with tracked minutes over a set of todos.
+type Accuracy struct {
+	Todos            int64 `json:"todos"`
+	EstimatedMinutes int64 `json:"estimatedMinutes"`
+	TrackedMinutes   int64 `json:"trackedMinutes"`
+	// Ratio is TrackedMinutes over EstimatedMinutes: 1.5 means the work
+	// took half as long again as estimated.
+	Ratio float64 `json:"ratio"`
+}
+
+func (a *Accuracy) add(estimated, tracked int64) {
+	a.Todos++
+	a.EstimatedMinutes += estimated
+	a.TrackedMinutes += tracked
+}
+
+func (a *Accuracy) finish() {
+	if a.EstimatedMinutes > 0 {
+		a.Ratio = float64(a.TrackedMinutes) / float64(a.EstimatedMinutes)
+	}
+}
+
+// EstimateAccuracy is how tracked time compared with DurationMinutes over
+// the completed todos that have both.
+type EstimateAccuracy struct {
+	Accuracy
+	ByTag map[string]Accuracy `json:"byTag"`
+}
+
+// TimeTracker is implemented by backends that record time spent on todos.
+type TimeTracker interface {
+	// TrackTime records minutes spent on todo id. It fails with
+	// ErrNotFound when the todo does not exist or is not visible.
+	TrackTime(ctx context.Context, id int64, minutes int) (TimeEntry, error)
+	// TimeEntries lists the time tracked on todo id, oldest first.
+	TimeEntries(ctx context.Context, id int64) ([]TimeEntry, error)
+	// EstimateAccuracy compares the estimates of the todos of the owner
+	// ctx is scoped to, or of every todo when it is not, with the time
+	// tracked on them.
+	EstimateAccuracy(ctx context.Context) (EstimateAccuracy, error)
+}
+
+func validateTimeEntry(minutes int) error {
+	if minutes <= 0 || minutes > maxTimeEntryMinutes {
+		return fmt.Errorf("minutes must be between 1 and %d", maxTimeEntryMinutes)
+	}
+	return nil
+}
+
+const timeEntryColumns = `id, todo_id, minutes, created_at`
+
+func scanTimeEntry(row rowScanner) (TimeEntry, error) {
+	var e TimeEntry
+	err := row.Scan(&e.ID, &e.TodoID, &e.Minutes, &e.CreatedAt)
+	return e, err
+}
+
+// TrackTime records minutes spent on todo id.
+func (s *SQLStore) TrackTime(ctx context.Context, id int64, minutes int) (TimeEntry, error) {
+	if err := validateTimeEntry(minutes); err != nil {
+		return TimeEntry{}, err
+	}
+	if _, err := s.GetTodo(ctx, id); err != nil {
+		return TimeEntry{}, err
+	}
+	insert := `INSERT INTO time_entries (todo_id, minutes) VALUES ($1, $2)`
+	var e TimeEntry
+	var err error
+	if s.dialect.returning {
+		if e, err = scanTimeEntry(s.SQL.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+timeEntryColumns), id, minutes)); err != nil {
+			return TimeEntry{}, err
+		}
+	} else {
+		res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(insert), id, minutes)
+		if err != nil {
+			return TimeEntry{}, err
+		}
+		entryID, err := res.LastInsertId()
+		if err != nil {
+			return TimeEntry{}, err
+		}
+		if e, err = scanTimeEntry(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+timeEntryColumns+` FROM time_entries WHERE id = $1`), entryID)); err != nil {
+			return TimeEntry{}, err
+		}
+	}
+	slog.InfoContext(ctx, "todo.time_tracked", "id", id, "minutes", minutes)
+	return e, nil
+}
+
+// TimeEntries lists the time tracked on todo id, oldest first.
+func (s *SQLStore) TimeEntries(ctx context.Context, id int64) ([]TimeEntry, error) {
+	if _, err := s.GetTodo(ctx, id); err != nil {
+		return nil, err
+	}
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT `+timeEntryColumns+` FROM time_entries WHERE todo_id = $1 ORDER BY id`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []TimeEntry{}
+	for rows.Next() {
+		e, err := scanTimeEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// trackedTodos joins each todo to the minutes tracked on it, leaving out
+// todos with none.
+const trackedTodos = `todos t, (SELECT todo_id, SUM(minutes) AS minutes FROM time_entries GROUP BY todo_id) e`
+
+// EstimateAccuracy aggregates with two queries: the totals, and the
+// totals per tag.
+func (s *SQLStore) EstimateAccuracy(ctx context.Context) (EstimateAccuracy, error) {
+	a := EstimateAccuracy{ByTag: map[string]Accuracy{}}
+	owned, ownerArgs := ownerCond(ctx, "t.owner_id", 0)
+	cond := ` WHERE e.todo_id = t.id AND t.completed AND t.duration_minutes > 0` + owned
+	err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(
+		`SELECT COUNT(*), COALESCE(SUM(t.duration_minutes), 0), COALESCE(SUM(e.minutes), 0)
+		 FROM `+trackedTodos+cond), ownerArgs...).Scan(&a.Todos, &a.EstimatedMinutes, &a.TrackedMinutes)
+	if err != nil {
+		return EstimateAccuracy{}, fmt.Errorf("aggregate estimate accuracy: %w", err)
+	}
+	a.finish()
+
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT tag.value, COUNT(*), SUM(t.duration_minutes), SUM(e.minutes)
+		 FROM `+trackedTodos+`, `+s.dialect.eachTag("t.tags", "tag")+cond+` GROUP BY 1`), ownerArgs...)
+	if err != nil {
+		return EstimateAccuracy{}, fmt.Errorf("aggregate estimate accuracy: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		var acc Accuracy
+		if err := rows.Scan(&tag, &acc.Todos, &acc.EstimatedMinutes, &acc.TrackedMinutes); err != nil {
+			return EstimateAccuracy{}, err
+		}
+		acc.finish()
+		a.ByTag[tag] = acc
+	}
+	return a, rows.Err()
+}
+
+// timeEntriesBucket keys entries by todo id followed by entry id, so each
+// todo's entries are a contiguous key range.
+var timeEntriesBucket = []byte("time_entries")
+
+// visibleBoltTodo returns todo id if it exists and is visible.
+func visibleBoltTodo(ctx context.Context, tx *bolt.Tx, id int64) (Todo, error) {
+	v := tx.Bucket(todosBucket).Get(boltKey(id))
+	if v == nil {
+		return Todo{}, ErrNotFound
+	}
+	t, err := decodeBoltTodo(v)
+	if err != nil {
+		return Todo{}, err
+	}
+	if !visible(ctx, t.OwnerID) {
+		return Todo{}, ErrNotFound
+	}
+	return t, nil
+}
+
+// TrackTime records minutes spent on todo id. Entries of deleted todos
+// stay, but no longer count.
+func (s *BoltStore) TrackTime(ctx context.Context, id int64, minutes int) (TimeEntry, error) {
+	if err := validateTimeEntry(minutes); err != nil {
+		return TimeEntry{}, err
+	}
+	var e TimeEntry
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		if _, err := visibleBoltTodo(ctx, tx, id); err != nil {
+			return err
+		}
+		b := tx.Bucket(timeEntriesBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		e = TimeEntry{ID: int64(seq), TodoID: id, Minutes: minutes, CreatedAt: time.Now().UTC()}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("encode time entry: %w", err)
+		}
+		return b.Put(append(boltKey(id), boltKey(e.ID)...), data)
+	})
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	slog.InfoContext(ctx, "todo.time_tracked", "id", id, "minutes", minutes)
+	return e, nil
+}
+
+// TimeEntries lists the time tracked on todo id, oldest first.
+func (s *BoltStore) TimeEntries(ctx context.Context, id int64) ([]TimeEntry, error) {
+	out := []TimeEntry{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if _, err := visibleBoltTodo(ctx, tx, id); err != nil {
+			return err
+		}
+		return eachBoltTimeEntry(tx, id, func(e TimeEntry) {
+			out = append(out, e)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func eachBoltTimeEntry(tx *bolt.Tx, id int64, fn func(TimeEntry)) error {
+	prefix := boltKey(id)
+	c := tx.Bucket(timeEntriesBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var e TimeEntry
+		if err := json.Unmarshal(v, &e); err != nil {
+			return fmt.Errorf("decode time entry: %w", err)
+		}
+		fn(e)
+	}
+	return nil
+}
+
+// EstimateAccuracy aggregates in one pass over the todos.
+func (s *BoltStore) EstimateAccuracy(ctx context.Context) (EstimateAccuracy, error) {
+	a := EstimateAccuracy{ByTag: map[string]Accuracy{}}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(todosBucket).ForEach(func(_, v []byte) error {
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if !t.Completed || t.DurationMinutes <= 0 || !visible(ctx, t.OwnerID) {
+				return nil
+			}
+			var tracked int64
+			if err := eachBoltTimeEntry(tx, t.ID, func(e TimeEntry) { tracked += int64(e.Minutes) }); err != nil {
+				return err
+			}
+			if tracked == 0 {
+				return nil
+			}
+			estimated := int64(t.DurationMinutes)
+			a.add(estimated, tracked)
+			for _, tag := range t.Tags {
+				acc := a.ByTag[tag]
+				acc.add(estimated, tracked)
+				a.ByTag[tag] = acc
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return EstimateAccuracy{}, err
+	}
+	a.finish()
+	for tag, acc := range a.ByTag {
+		acc.finish()
+		a.ByTag[tag] = acc
+	}
+	return a, nil
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"todoapp/internal/telemetry"
+)
+
+// queryTracer times every pgx statement into telemetry.DBQueryDuration and
+// records it as a child span of the request that issued it. The MySQL driver
+// has no equivalent hook, so MySQL statements are neither timed nor traced.
+type queryTracer struct {
+	dialect string
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	at        time.Time
+	statement string
+	sql       string
+}
+
+func (t queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	kind := statementKind(data.SQL)
+	ctx, _ = telemetry.Tracer().Start(ctx, "db."+kind,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", dbSystem(t.dialect)),
+			attribute.String("db.statement", data.SQL),
+		))
+	return context.WithValue(ctx, queryStartKey{}, queryStart{at: time.Now(), statement: kind, sql: data.SQL})
+}
+
+func (t queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.at)
+	telemetry.DBQueryDuration.WithLabelValues(t.dialect, start.statement, telemetry.Outcome(data.Err)).Observe(elapsed.Seconds())
+	if ql, ok := ctx.Value(queryLogKey{}).(*QueryLog); ok {
+		ql.record(ctx, start.sql, elapsed, data)
+	}
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+}
+
+// statementKind returns the lower-cased leading keyword of a statement,
+// which keeps the metric's label set small.
+func statementKind(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexAny(sql, " \t\n("); i > 0 {
+		sql = sql[:i]
+	}
+	switch kind := strings.ToLower(sql); kind {
+	case "select", "insert", "update", "delete", "with", "begin", "commit", "rollback", "create", "alter", "drop":
+		return kind
+	}
+	return "other"
+}
+
+func dbSystem(dialect string) string {
+	if dialect == cockroachDialect.name {
+		return "cockroachdb"
+	}
+	return "postgresql"
+}
+
+type queryLogKey struct{}
+
+// QueryLog collects the statements run for one request when their logging
+// was asked for; see WithQueryLog.
+type QueryLog struct {
+	mu         sync.Mutex
+	statements int
+	total      time.Duration
+}
+
+// WithQueryLog returns a context whose statements are each logged, with
+// their text (never their arguments) and timing, and the QueryLog totalling
+// them. Only the pgx tracer sees statements, so nothing is logged for MySQL
+// or SQLite.
+func WithQueryLog(ctx context.Context) (context.Context, *QueryLog) {
+	ql := &QueryLog{}
+	return context.WithValue(ctx, queryLogKey{}, ql), ql
+}
+
+// Totals reports how many statements ran and their summed time.
+func (l *QueryLog) Totals() (int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.statements, l.total
+}
+
+func (l *QueryLog) record(ctx context.Context, sql string, elapsed time.Duration, data pgx.TraceQueryEndData) {
+	l.mu.Lock()
+	l.statements++
+	l.total += elapsed
+	l.mu.Unlock()
+	attrs := []any{"statement", sql, "duration_ms", float64(elapsed.Microseconds()) / 1000, "rows", data.CommandTag.RowsAffected()}
+	if data.Err != nil {
+		attrs = append(attrs, "error", data.Err)
+	}
+	slog.InfoContext(ctx, "db.query", attrs...)
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxTxAttempts bounds how often WithTx re-runs a transaction that failed
+// with a retryable serialization error.
+const maxTxAttempts = 5
+
+// WithTx runs fn inside a transaction, committing on success and rolling back
+// on error. CockroachDB runs every transaction at SERIALIZABLE and expects
+// clients to retry on SQLSTATE 40001, so such failures re-run fn from the
+// start with a short backoff. fn must therefore be safe to call more than once.
+// With encryption, the data keys fn may seal titles with are made ready
+// first (see prepareDataKeys).
+func (s *SQLStore) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if err := s.prepareDataKeys(ctx); err != nil {
+		return err
+	}
+	backoff := 20 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, fn)
+		if err == nil || !isRetryable(err) || attempt == maxTxAttempts {
+			return err
+		}
+		slog.WarnContext(ctx, "db.tx_retry", "dialect", s.dialect.name, "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *SQLStore) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// isRetryable reports whether err is a serialization failure that the
+// client is expected to retry (PostgreSQL and CockroachDB SQLSTATE 40001).
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
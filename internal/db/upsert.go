@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// UpsertTodoByUUID inserts the todo if no row has the given uuid, otherwise
+// overwrites the existing row's fields. It is a single statement, followed
+// in the same transaction by sealing the title when encryption is on, so
+// sync and import paths can replay it safely. created reports whether a row
+// was inserted.
+func (s *SQLStore) UpsertTodoByUUID(ctx context.Context, uuid string, input SaveTodoInput) (Todo, bool, error) {
+	uuid = strings.ToLower(uuid)
+	if !validUUID(uuid) {
+		return Todo{}, false, errors.New("invalid uuid")
+	}
+	if err := validateInput(input); err != nil {
+		return Todo{}, false, err
+	}
+	tagsJSON, err := encodeTags(input.Tags)
+	if err != nil {
+		return Todo{}, false, err
+	}
+	reminders, err := encodeReminderOffsets(input.ReminderOffsets)
+	if err != nil {
+		return Todo{}, false, err
+	}
+	if err := s.checkUUIDOwner(ctx, uuid); err != nil {
+		return Todo{}, false, err
+	}
+	args := []any{uuid, s.insertTitle(input.Title), input.Completed, string(tagsJSON), input.DurationMinutes, input.PriorityScore, input.DueAt, input.StartAt, input.Effort, reminders, input.Recurrence, ownerValue(ctx)}
+
+	var t Todo
+	var created bool
+	err = s.WithTx(ctx, func(tx *sql.Tx) error {
+		if s.dialect.returning {
+			// Both timestamps default to the transaction time on insert, while the
+			// update branch only moves updated_at, so equality identifies inserts.
+			row := tx.QueryRowContext(ctx, s.dialect.rebind(
+				`INSERT INTO todos (uuid, title, completed, tags, duration_minutes, priority_score, due_at, start_at, effort, reminder_offsets, recurrence, owner_id)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+				 ON CONFLICT (uuid) DO UPDATE
+				 SET title = EXCLUDED.title,
+				     completed = EXCLUDED.completed,
+				     tags = EXCLUDED.tags,
+				     duration_minutes = EXCLUDED.duration_minutes,
+				     priority_score = EXCLUDED.priority_score,
+				     due_at = EXCLUDED.due_at,
+				     start_at = EXCLUDED.start_at,
+				     effort = EXCLUDED.effort,
+				     reminder_offsets = EXCLUDED.reminder_offsets,
+				     recurrence = EXCLUDED.recurrence,
+				     updated_at = `+s.dialect.now+`
+				 RETURNING `+todoColumns+`, created_at = updated_at`),
+				args...,
+			)
+			if t, err = s.scanTodo(ctx, row, &created); err != nil {
+				return err
+			}
+		} else {
+			res, err := tx.ExecContext(ctx, s.dialect.rebind(
+				`INSERT INTO todos (uuid, title, completed, tags, duration_minutes, priority_score, due_at, start_at, effort, reminder_offsets, recurrence, owner_id)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+				 ON DUPLICATE KEY UPDATE
+				     title = VALUES(title),
+				     completed = VALUES(completed),
+				     tags = VALUES(tags),
+				     duration_minutes = VALUES(duration_minutes),
+				     priority_score = VALUES(priority_score),
+				     due_at = VALUES(due_at),
+				     start_at = VALUES(start_at),
+				     effort = VALUES(effort),
+				     reminder_offsets = VALUES(reminder_offsets),
+				     recurrence = VALUES(recurrence),
+				     updated_at = `+s.dialect.now),
+				args...,
+			)
+			if err != nil {
+				return err
+			}
+			// MySQL reports 1 affected row for an insert and 2 for an update.
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			created = n == 1
+			row := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+todoColumns+` FROM todos WHERE uuid = $1`), uuid)
+			if t, err = s.scanTodo(ctx, row); err != nil {
+				return err
+			}
+		}
+		return s.sealInserted(ctx, tx, map[string]string{uuid: input.Title}, &t)
+	})
+	if err != nil {
+		return Todo{}, false, err
+	}
+	s.cache.put(t)
+	slog.InfoContext(ctx, "todo.upserted", "id", t.ID, "uuid", t.UUID, "created", created)
+	return t, created, nil
+}
+
+// errUUIDTaken is returned when an upsert names a uuid owned by another user.
+var errUUIDTaken = errors.New("uuid already in use")
+
+// checkUUIDOwner refuses to upsert over another user's todo. The upsert
+// statements cannot express the ownership check themselves on MySQL, so it
+// runs as a separate read; uuids are random, so the race is only reachable
+// by a client deliberately reusing someone else's uuid.
+func (s *SQLStore) checkUUIDOwner(ctx context.Context, uuid string) error {
+	if _, ok := OwnerFrom(ctx); !ok {
+		return nil
+	}
+	var owner sql.NullInt64
+	err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT owner_id FROM todos WHERE uuid = $1`), uuid).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !visible(ctx, owner.Int64) {
+		return errUUIDTaken
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrUserNotFound is returned when a user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrEmailTaken is returned when signing up with a registered email.
+	ErrEmailTaken = errors.New("email already registered")
+)
+
+// User is an account that owns todos and lists.
+type User struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+	// ExternalID is the identity provider's id for a provisioned user.
+	ExternalID string `json:"externalId,omitempty"`
+	// Deactivated users cannot log in, and with provisioning enabled their
+	// sessions stop working; their todos and lists are kept.
+	Deactivated bool      `json:"deactivated,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// UserStore is implemented by backends that keep user accounts. Password
+// hashing is the caller's concern; the store only persists the hash.
+type UserStore interface {
+	CreateUser(ctx context.Context, email, passwordHash string) (User, error)
+	// UserByEmail returns the user and their password hash.
+	UserByEmail(ctx context.Context, email string) (User, string, error)
+	GetUser(ctx context.Context, id int64) (User, error)
+}
+
+// AccountDeleter is implemented by backends that can delete an account
+// with everything it owns.
+type AccountDeleter interface {
+	// DeleteAccount deletes user id with their todos, lists and history.
+	// With the audit chain on, the chained entries it deletes are noted as
+	// removed rather than left missing (see WithAuditChain). It fails with
+	// ErrUserNotFound when the user does not exist.
+	DeleteAccount(ctx context.Context, id int64) error
+}
+
+// NormalizeEmail validates a bare email address and lower-cases it, so the
+// same mailbox cannot register twice with different capitalisation.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 254 {
+		return "", errors.New("invalid email address")
+	}
+	return email, nil
+}
+
+const userColumns = `id, email, external_id, deactivated, created_at`
+
+// scanUser scans userColumns, followed by extra columns into extra.
+func scanUser(row rowScanner, extra ...any) (User, error) {
+	var u User
+	var external sql.NullString
+	err := row.Scan(append([]any{&u.ID, &u.Email, &external, &u.Deactivated, &u.CreatedAt}, extra...)...)
+	u.ExternalID = external.String
+	return u, err
+}
+
+// CreateUser registers a new user.
+func (s *SQLStore) CreateUser(ctx context.Context, email, passwordHash string) (User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return User{}, err
+	}
+	insert := `INSERT INTO users (email, password_hash) VALUES ($1, $2)`
+	var u User
+	if s.dialect.returning {
+		u, err = scanUser(s.SQL.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+userColumns), email, passwordHash))
+	} else {
+		var res sql.Result
+		if res, err = s.SQL.ExecContext(ctx, s.dialect.rebind(insert), email, passwordHash); err == nil {
+			var id int64
+			if id, err = res.LastInsertId(); err == nil {
+				u, err = s.GetUser(ctx, id)
+			}
+		}
+	}
+	if isUniqueViolation(err) {
+		return User{}, ErrEmailTaken
+	}
+	if err != nil {
+		return User{}, err
+	}
+	slog.InfoContext(ctx, "user.created", "user_id", u.ID)
+	return u, nil
+}
+
+// UserByEmail returns the user registered with email and their password hash.
+func (s *SQLStore) UserByEmail(ctx context.Context, email string) (User, string, error) {
+	var hash string
+	u, err := scanUser(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+userColumns+`, password_hash FROM users WHERE email = $1`),
+		strings.ToLower(strings.TrimSpace(email))), &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, "", ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, "", err
+	}
+	return u, hash, nil
+}
+
+// GetUser returns a user by id.
+func (s *SQLStore) GetUser(ctx context.Context, id int64) (User, error) {
+	u, err := scanUser(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+userColumns+` FROM users WHERE id = $1`), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	return u, err
+}
+
+// DeleteAccount deletes the user row; the owner foreign keys delete the
+// rest.
+func (s *SQLStore) DeleteAccount(ctx context.Context, id int64) error {
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		if s.auditChain {
+			if err := s.purgeHistory(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		res, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM users WHERE id = $1`), id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrUserNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "user.deleted", "user_id", id)
+	return nil
+}
+
+var (
+	usersBucket      = []byte("users")
+	userEmailsBucket = []byte("user_emails")
+)
+
+// boltUser is the stored form of a user; the hash never leaves the store
+// through User.
+type boltUser struct {
+	User
+	PasswordHash string `json:"passwordHash"`
+}
+
+// CreateUser registers a new user.
+func (s *BoltStore) CreateUser(ctx context.Context, email, passwordHash string) (User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return User{}, err
+	}
+	var u User
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		emails := tx.Bucket(userEmailsBucket)
+		if emails.Get([]byte(email)) != nil {
+			return ErrEmailTaken
+		}
+		users := tx.Bucket(usersBucket)
+		seq, err := users.NextSequence()
+		if err != nil {
+			return err
+		}
+		u = User{ID: int64(seq), Email: email, CreatedAt: time.Now().UTC()}
+		if err := putBoltUser(users, boltUser{User: u, PasswordHash: passwordHash}); err != nil {
+			return err
+		}
+		return emails.Put([]byte(email), boltKey(u.ID))
+	})
+	if err != nil {
+		return User{}, err
+	}
+	slog.InfoContext(ctx, "user.created", "user_id", u.ID)
+	return u, nil
+}
+
+// UserByEmail returns the user registered with email and their password hash.
+func (s *BoltStore) UserByEmail(ctx context.Context, email string) (User, string, error) {
+	var bu boltUser
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(userEmailsBucket).Get([]byte(strings.ToLower(strings.TrimSpace(email))))
+		if key == nil {
+			return ErrUserNotFound
+		}
+		return getBoltUser(tx, key, &bu)
+	})
+	if err != nil {
+		return User{}, "", err
+	}
+	return bu.User, bu.PasswordHash, nil
+}
+
+// GetUser returns a user by id.
+func (s *BoltStore) GetUser(ctx context.Context, id int64) (User, error) {
+	var bu boltUser
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return getBoltUser(tx, boltKey(id), &bu)
+	})
+	return bu.User, err
+}
+
+func getBoltUser(tx *bolt.Tx, key []byte, bu *boltUser) error {
+	v := tx.Bucket(usersBucket).Get(key)
+	if v == nil {
+		return ErrUserNotFound
+	}
+	if err := json.Unmarshal(v, bu); err != nil {
+		return fmt.Errorf("decode user: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation reports a unique constraint violation: SQLSTATE 23505 on
+// PostgreSQL and CockroachDB, error 1062 on MySQL.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1062
+	}
+	return isSQLiteUniqueViolation(err)
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ListVersion summarises the todos visible to a context. Any create, update
+// or delete changes Count or LastUpdated, and re-scoring (which leaves
+// updated_at alone) changes ScoreTotal, so two equal versions describe the
+// same list (up to the precision of updated_at) and a cached rendering of it
+// can be reused. Lists count too: archiving one hides its todos, and
+// deleting one unassigns them, without necessarily touching the todos.
+type ListVersion struct {
+	Count       int64
+	LastUpdated time.Time
+	ScoreTotal  float64
+	// NextStart is the earliest start date after the instant the version was
+	// taken at, when a deferred todo joins the default list without any
+	// write; zero if nothing is deferred.
+	NextStart time.Time
+	// Lists and ListsUpdated are the number of lists and when one last
+	// changed.
+	Lists        int64
+	ListsUpdated time.Time
+}
+
+// ListVersioner is implemented by backends that can compute a ListVersion
+// with a single aggregate query, much cheaper than listing the todos.
+type ListVersioner interface {
+	ListVersion(ctx context.Context, now time.Time) (ListVersion, error)
+}
+
+// ListVersion returns the version of the todos visible to ctx as of now.
+func (s *SQLStore) ListVersion(ctx context.Context, now time.Time) (ListVersion, error) {
+	args := []any{now.UTC()}
+	todosCond, todosArgs := ownerCond(ctx, "owner_id", len(args))
+	args = append(args, todosArgs...)
+	listsCond, listsArgs := ownerCond(ctx, "owner_id", len(args))
+	args = append(args, listsArgs...)
+	var (
+		v                      ListVersion
+		last, start, listsLast nullTime
+		total                  sql.NullFloat64
+	)
+	err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT t.n, t.last_updated, t.score_total, t.next_start, l.n, l.last_updated
+		FROM (SELECT COUNT(*) AS n, MAX(updated_at) AS last_updated, SUM(priority_score) AS score_total,
+				MIN(CASE WHEN start_at > $1 THEN start_at END) AS next_start
+			FROM todos WHERE TRUE`+todosCond+`) t
+		CROSS JOIN (SELECT COUNT(*) AS n, MAX(updated_at) AS last_updated FROM lists WHERE TRUE`+listsCond+`) l`),
+		args...).Scan(&v.Count, &last, &total, &start, &v.Lists, &listsLast)
+	if err != nil {
+		return ListVersion{}, err
+	}
+	if last.Valid {
+		v.LastUpdated = last.Time.UTC()
+	}
+	v.ScoreTotal = total.Float64
+	if start.Valid {
+		v.NextStart = start.Time.UTC()
+	}
+	if listsLast.Valid {
+		v.ListsUpdated = listsLast.Time.UTC()
+	}
+	return v, nil
+}
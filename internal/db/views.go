@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ViewPosition places a todo within a named view. Pinned todos sort ahead of
+// the rest; within each group, lower Position comes first.
+type ViewPosition struct {
+	TodoID   int64 `json:"id"`
+	Position int   `json:"position"`
+	Pinned   bool  `json:"pinned"`
+}
+
+// ViewOrderer is implemented by backends that keep manual orderings per view.
+// A view is any client-chosen key ("today", "tag:work"), so rearranging one
+// view leaves every other view untouched.
+type ViewOrderer interface {
+	ViewOrder(ctx context.Context, view string) ([]ViewPosition, error)
+	SetViewOrder(ctx context.Context, view string, positions []ViewPosition) error
+}
+
+var viewKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9:_.-]{0,63}$`)
+
+// ValidViewKey reports whether key can name a view.
+func ValidViewKey(key string) bool {
+	return viewKeyPattern.MatchString(key)
+}
+
+var errInvalidViewKey = errors.New("invalid view key")
+
+// ViewOrder returns the stored positions for view ordered as displayed.
+func (s *SQLStore) ViewOrder(ctx context.Context, view string) ([]ViewPosition, error) {
+	owned, ownerArgs := ownerCond(ctx, "t.owner_id", 1)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT p.todo_id, p.sort_order, p.pinned FROM todo_view_positions p
+		 JOIN todos t ON t.id = p.todo_id
+		 WHERE p.view_key = $1`+owned+`
+		 ORDER BY p.pinned DESC, p.sort_order ASC`), append([]any{view}, ownerArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ViewPosition{}
+	for rows.Next() {
+		var p ViewPosition
+		if err := rows.Scan(&p.TodoID, &p.Position, &p.Pinned); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// SetViewOrder replaces the ordering of view. Positions naming todos that no
+// longer exist are dropped rather than failing the whole update. In a scoped
+// context only the owner's todos are touched, so users sharing a view key
+// never disturb each other's orderings.
+func (s *SQLStore) SetViewOrder(ctx context.Context, view string, positions []ViewPosition) error {
+	if !ValidViewKey(view) {
+		return errInvalidViewKey
+	}
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		// The owner argument is the same for both statements; only its
+		// placeholder position differs.
+		owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+		clear := `DELETE FROM todo_view_positions WHERE view_key = $1`
+		if owned != "" {
+			clear += ` AND todo_id IN (SELECT id FROM todos WHERE TRUE` + owned + `)`
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(clear), append([]any{view}, ownerArgs...)...); err != nil {
+			return err
+		}
+		owned, _ = ownerCond(ctx, "owner_id", 4)
+		insert := s.dialect.rebind(
+			`INSERT INTO todo_view_positions (view_key, todo_id, sort_order, pinned)
+			 SELECT $1, id, $2, $3 FROM todos WHERE id = $4` + owned)
+		for _, p := range positions {
+			if _, err := tx.ExecContext(ctx, insert, append([]any{view, p.Position, p.Pinned, p.TodoID}, ownerArgs...)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "todo.view_reordered", "view", view, "items", len(positions))
+	return nil
+}
+
+var viewsBucket = []byte("view_positions")
+
+// ViewOrder returns the stored positions for view ordered as displayed.
+func (s *BoltStore) ViewOrder(ctx context.Context, view string) ([]ViewPosition, error) {
+	out := []ViewPosition{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(viewsBucket).Get(boltViewKey(ctx, view))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &out)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decode view order: %w", err)
+	}
+	return out, nil
+}
+
+// SetViewOrder replaces the ordering of view. Positions naming todos that do
+// not exist are dropped.
+func (s *BoltStore) SetViewOrder(ctx context.Context, view string, positions []ViewPosition) error {
+	if !ValidViewKey(view) {
+		return errInvalidViewKey
+	}
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		todos := tx.Bucket(todosBucket)
+		kept := make([]ViewPosition, 0, len(positions))
+		for _, p := range positions {
+			v := todos.Get(boltKey(p.TodoID))
+			if v == nil {
+				continue
+			}
+			t, err := decodeBoltTodo(v)
+			if err != nil {
+				return err
+			}
+			if visible(ctx, t.OwnerID) {
+				kept = append(kept, p)
+			}
+		}
+		sortViewPositions(kept)
+		data, err := json.Marshal(kept)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(viewsBucket).Put(boltViewKey(ctx, view), data)
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "todo.view_reordered", "view", view, "items", len(positions))
+	return nil
+}
+
+// boltViewKey namespaces view keys per owner. View keys cannot contain "/",
+// so owned keys never collide with unowned ones.
+func boltViewKey(ctx context.Context, view string) []byte {
+	if id, ok := OwnerFrom(ctx); ok {
+		return []byte(strconv.FormatInt(id, 10) + "/" + view)
+	}
+	return []byte(view)
+}
+
+// sortViewPositions orders positions pinned-first, then by Position.
+func sortViewPositions(positions []ViewPosition) {
+	slices.SortStableFunc(positions, func(a, b ViewPosition) int {
+		if a.Pinned != b.Pinned {
+			if a.Pinned {
+				return -1
+			}
+			return 1
+		}
+		return a.Position - b.Position
+	})
+}
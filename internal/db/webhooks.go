@@ -0,0 +1,1056 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrWebhookNotFound is returned when a webhook does not exist.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Todo lifecycle events a webhook can subscribe to.
+const (
+	WebhookEventCreated   = "todo.created"
+	WebhookEventUpdated   = "todo.updated"
+	WebhookEventCompleted = "todo.completed"
+	WebhookEventDeleted   = "todo.deleted"
+)
+
+// WebhookEvents lists every event, in the order they are documented.
+var WebhookEvents = []string{WebhookEventCreated, WebhookEventUpdated, WebhookEventCompleted, WebhookEventDeleted}
+
+// Delivery states: pending deliveries are retried until they succeed or
+// run out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	maxWebhookURL      = 2048
+	maxWebhookSecret   = 256
+	maxWebhookTemplate = 16 << 10
+	// maxWebhookFilter caps the lists and the tags of a webhook filter.
+	maxWebhookFilter = 50
+	// maxWebhookPayload caps a rendered payload template.
+	maxWebhookPayload = 1 << 20
+	// maxWebhookDeliveries is how many finished deliveries are kept per
+	// webhook; pending ones are never dropped.
+	maxWebhookDeliveries = 100
+)
+
+// Webhook posts todo lifecycle events to URL, signed with Secret when set.
+type Webhook struct {
+	ID     int64  `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Events are the events delivered; empty means all of them.
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	OwnerID   int64     `json:"ownerId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// ConsecutiveFailures counts the failed attempts since the last
+	// successful one.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// PausedAt is set when the webhook was paused for failing persistently;
+	// its deliveries wait until it is resumed.
+	PausedAt *time.Time `json:"pausedAt,omitempty"`
+	// ThrottledUntil is set when the receiver asked to slow down (429 or
+	// Retry-After); no delivery is attempted before then.
+	ThrottledUntil *time.Time `json:"throttledUntil,omitempty"`
+	// Filter narrows the events delivered to those of matching todos.
+	Filter WebhookFilter `json:"filter"`
+	// Template, when set, is a text/template rendering the JSON request body
+	// from a WebhookPayload in place of the payload's own encoding, e.g. to
+	// post to a Slack incoming webhook directly.
+	Template string `json:"template,omitempty"`
+}
+
+// WebhookFilter selects the todos whose events a webhook receives. Each
+// set criterion must match; an empty filter matches every todo. With the
+// todo.completed event alone it narrows a webhook to, say, completions in
+// one list carrying one tag.
+type WebhookFilter struct {
+	// ListIDs matches todos in one of the lists.
+	ListIDs []int64 `json:"listIds,omitempty"`
+	// Tags matches todos carrying at least one of the tags.
+	Tags []string `json:"tags,omitempty"`
+	// DueWithinHours matches todos due within that many hours of the
+	// event, overdue ones included, as in a Subscription.
+	DueWithinHours int `json:"dueWithinHours,omitempty"`
+}
+
+// Matches reports whether t passes the filter at the time of the event.
+func (f WebhookFilter) Matches(t Todo) bool {
+	if f.DueWithinHours > 0 && !(TodoFilter{DueBefore: dueWithin(time.Now(), f.DueWithinHours)}).matches(t) {
+		return false
+	}
+	if len(f.ListIDs) > 0 && (t.ListID == nil || !slices.Contains(f.ListIDs, *t.ListID)) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(t.Tags, func(tag string) bool { return slices.Contains(f.Tags, tag) }) {
+		return false
+	}
+	return true
+}
+
+func (f WebhookFilter) empty() bool {
+	return len(f.ListIDs) == 0 && len(f.Tags) == 0 && f.DueWithinHours == 0
+}
+
+// WebhookPayload is the event a delivery carries: its JSON encoding is the
+// default request body, and the data of a webhook's Template.
+type WebhookPayload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Todo       Todo      `json:"todo"`
+}
+
+// webhookTemplateFuncs are available to payload templates: json encodes a
+// value, so strings can be embedded in JSON bodies safely, and join joins
+// a list of strings.
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": strings.Join,
+}
+
+// Render returns the request body delivering p to w: p's JSON encoding, or
+// the output of w's Template, which must be JSON.
+func (w Webhook) Render(p WebhookPayload) ([]byte, error) {
+	if w.Template == "" {
+		return json.Marshal(p)
+	}
+	tmpl, err := template.New("payload").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(w.Template)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, fmt.Errorf("render webhook template: %w", err)
+	}
+	if buf.Len() > maxWebhookPayload {
+		return nil, errors.New("rendered webhook payload too large")
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("webhook template did not render JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// dispatchable reports whether deliveries to w may be attempted at now.
+func (w Webhook) dispatchable(now time.Time) bool {
+	return w.PausedAt == nil && (w.ThrottledUntil == nil || !w.ThrottledUntil.After(now))
+}
+
+// Wants reports whether w is enabled, subscribed to event and its filter
+// matches t.
+func (w Webhook) Wants(event string, t Todo) bool {
+	return w.Enabled && (len(w.Events) == 0 || slices.Contains(w.Events, event)) && w.Filter.Matches(t)
+}
+
+// SaveWebhookInput represents the fields accepted for webhook create/update.
+type SaveWebhookInput struct {
+	URL      string
+	Secret   string
+	Events   []string
+	Enabled  bool
+	Filter   WebhookFilter
+	Template string
+}
+
+// OutboundDelivery is one event queued for an outbound webhook, with the outcome of
+// its latest attempt.
+type OutboundDelivery struct {
+	ID        int64           `json:"id"`
+	WebhookID int64           `json:"webhookId"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	// NextAttemptAt is when a pending delivery is next tried.
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	// LastStatusCode is the HTTP status of the latest attempt, zero when
+	// it got no response; LastError says what went wrong.
+	LastStatusCode int       `json:"lastStatusCode,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// WebhookStore is implemented by backends that support outbound webhooks
+// and their delivery queue. Only the latest finished deliveries of each
+// webhook are kept.
+type WebhookStore interface {
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	CreateWebhook(ctx context.Context, input SaveWebhookInput) (Webhook, error)
+	UpdateWebhook(ctx context.Context, id int64, input SaveWebhookInput) (Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	// EnqueueDelivery queues d as pending, due immediately.
+	EnqueueDelivery(ctx context.Context, d OutboundDelivery) error
+	// ClaimDueDeliveries returns up to limit pending deliveries due by now,
+	// oldest first, moving their next attempt lease ahead so other
+	// replicas skip them while they are in flight.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboundDelivery, error)
+	// RecordDeliveryAttempt stores the status, attempts, next attempt and
+	// last outcome of d.
+	RecordDeliveryAttempt(ctx context.Context, d OutboundDelivery) error
+	ListOutboundDeliveries(ctx context.Context, webhookID int64, limit int) ([]OutboundDelivery, error)
+	// CountPendingDeliveries returns how many deliveries of every webhook
+	// are waiting to be delivered or retried.
+	CountPendingDeliveries(ctx context.Context) (int, error)
+	// RecordEndpointOutcome resets a webhook's consecutive failures after a
+	// successful attempt, or counts a failed one and pauses the webhook
+	// once pauseAfter have failed in a row (zero never pauses). It reports
+	// whether this call paused it.
+	RecordEndpointOutcome(ctx context.Context, id int64, failed bool, pauseAfter int) (paused bool, err error)
+	// ThrottleWebhook holds back a webhook's deliveries until until, unless
+	// it is held back longer already.
+	ThrottleWebhook(ctx context.Context, id int64, until time.Time) error
+	// ResumeWebhook unpauses a webhook, clears its failures and throttle and
+	// makes its pending deliveries due immediately.
+	ResumeWebhook(ctx context.Context, id int64) (Webhook, error)
+}
+
+// validateWebhookInput checks and normalizes input: events are deduplicated
+// and ordered as WebhookEvents lists them.
+func validateWebhookInput(input *SaveWebhookInput) error {
+	input.URL = strings.TrimSpace(input.URL)
+	u, err := url.Parse(input.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if len(input.URL) > maxWebhookURL {
+		return errors.New("url too long")
+	}
+	if len(input.Secret) > maxWebhookSecret {
+		return errors.New("secret too long")
+	}
+	for _, e := range input.Events {
+		if !slices.Contains(WebhookEvents, e) {
+			return fmt.Errorf("unknown event %q; want one of %s", e, strings.Join(WebhookEvents, ", "))
+		}
+	}
+	events := []string{}
+	for _, e := range WebhookEvents {
+		if slices.Contains(input.Events, e) {
+			events = append(events, e)
+		}
+	}
+	input.Events = events
+
+	if len(input.Filter.ListIDs) > maxWebhookFilter || len(input.Filter.Tags) > maxWebhookFilter {
+		return fmt.Errorf("filter allows at most %d lists and %d tags", maxWebhookFilter, maxWebhookFilter)
+	}
+	var lists []int64
+	for _, id := range input.Filter.ListIDs {
+		if id <= 0 {
+			return errors.New("filter list ids must be positive")
+		}
+		if !slices.Contains(lists, id) {
+			lists = append(lists, id)
+		}
+	}
+	var tags []string
+	for _, tag := range input.Filter.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return errors.New("filter tags must not be empty")
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if input.Filter.DueWithinHours < 0 || input.Filter.DueWithinHours > maxDueWithinHours {
+		return fmt.Errorf("filter dueWithinHours must be between 0 and %d", maxDueWithinHours)
+	}
+	input.Filter = WebhookFilter{ListIDs: lists, Tags: tags, DueWithinHours: input.Filter.DueWithinHours}
+
+	if len(input.Template) > maxWebhookTemplate {
+		return errors.New("template too long")
+	}
+	if input.Template != "" {
+		if _, err := template.New("payload").Funcs(webhookTemplateFuncs).Parse(input.Template); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	return nil
+}
+
+// encodeFilter returns f as stored in event_filter: NULL when empty.
+func encodeFilter(f WebhookFilter) (any, error) {
+	if f.empty() {
+		return nil, nil
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+const webhookColumns = `id, url, secret, events, enabled, owner_id, created_at, updated_at, consecutive_failures, paused_at, throttled_until, event_filter, payload_template`
+
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var w Webhook
+	var events, filter []byte
+	var owner sql.NullInt64
+	var tmpl sql.NullString
+	err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Enabled, &owner, &w.CreatedAt, &w.UpdatedAt,
+		&w.ConsecutiveFailures, &w.PausedAt, &w.ThrottledUntil, &filter, &tmpl)
+	if err != nil {
+		return Webhook{}, err
+	}
+	w.OwnerID, w.Template = owner.Int64, tmpl.String
+	if err := json.Unmarshal(events, &w.Events); err != nil {
+		return Webhook{}, fmt.Errorf("decode webhook events: %w", err)
+	}
+	if len(filter) > 0 {
+		if err := json.Unmarshal(filter, &w.Filter); err != nil {
+			return Webhook{}, fmt.Errorf("decode webhook filter: %w", err)
+		}
+	}
+	return w, nil
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at`
+
+func scanDelivery(row rowScanner) (OutboundDelivery, error) {
+	var d OutboundDelivery
+	var payload []byte
+	err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return OutboundDelivery{}, err
+	}
+	d.Payload = json.RawMessage(payload)
+	return d, nil
+}
+
+// ListWebhooks returns all webhooks ordered by id.
+func (s *SQLStore) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 0)
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(`SELECT `+webhookColumns+` FROM webhooks WHERE TRUE`+owned+` ORDER BY id ASC`), ownerArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// GetWebhook returns a webhook by id.
+func (s *SQLStore) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	w, err := scanWebhook(s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return w, err
+}
+
+// CreateWebhook creates a new webhook.
+func (s *SQLStore) CreateWebhook(ctx context.Context, input SaveWebhookInput) (Webhook, error) {
+	if err := validateWebhookInput(&input); err != nil {
+		return Webhook{}, err
+	}
+	events, err := json.Marshal(input.Events)
+	if err != nil {
+		return Webhook{}, err
+	}
+	filter, err := encodeFilter(input.Filter)
+	if err != nil {
+		return Webhook{}, err
+	}
+	insert := `INSERT INTO webhooks (url, secret, events, enabled, owner_id, event_filter, payload_template) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	args := []any{input.URL, input.Secret, string(events), input.Enabled, ownerValue(ctx), filter, input.Template}
+
+	var w Webhook
+	if s.dialect.returning {
+		if w, err = scanWebhook(s.SQL.QueryRowContext(ctx, s.dialect.rebind(insert+` RETURNING `+webhookColumns), args...)); err != nil {
+			return Webhook{}, err
+		}
+	} else {
+		res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(insert), args...)
+		if err != nil {
+			return Webhook{}, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return Webhook{}, err
+		}
+		if w, err = s.GetWebhook(ctx, id); err != nil {
+			return Webhook{}, err
+		}
+	}
+	slog.InfoContext(ctx, "webhook.created", "id", w.ID)
+	return w, nil
+}
+
+// UpdateWebhook updates a webhook by id.
+func (s *SQLStore) UpdateWebhook(ctx context.Context, id int64, input SaveWebhookInput) (Webhook, error) {
+	if err := validateWebhookInput(&input); err != nil {
+		return Webhook{}, err
+	}
+	events, err := json.Marshal(input.Events)
+	if err != nil {
+		return Webhook{}, err
+	}
+	filter, err := encodeFilter(input.Filter)
+	if err != nil {
+		return Webhook{}, err
+	}
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 7)
+	update := `UPDATE webhooks SET url = $1, secret = $2, events = $3, enabled = $4, event_filter = $5, payload_template = $6, updated_at = ` + s.dialect.now + ` WHERE id = $7` + owned
+	args := append([]any{input.URL, input.Secret, string(events), input.Enabled, filter, input.Template, id}, ownerArgs...)
+
+	var w Webhook
+	if s.dialect.returning {
+		w, err = scanWebhook(s.SQL.QueryRowContext(ctx, s.dialect.rebind(update+` RETURNING `+webhookColumns), args...))
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, ErrWebhookNotFound
+		}
+		if err != nil {
+			return Webhook{}, err
+		}
+	} else {
+		if _, err := s.SQL.ExecContext(ctx, s.dialect.rebind(update), args...); err != nil {
+			return Webhook{}, err
+		}
+		if w, err = s.GetWebhook(ctx, id); err != nil {
+			return Webhook{}, err
+		}
+	}
+	slog.InfoContext(ctx, "webhook.updated", "id", w.ID)
+	return w, nil
+}
+
+// DeleteWebhook deletes a webhook and its deliveries.
+func (s *SQLStore) DeleteWebhook(ctx context.Context, id int64) error {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`DELETE FROM webhooks WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		slog.InfoContext(ctx, "webhook.deleted", "id", id)
+	}
+	return nil
+}
+
+// EnqueueDelivery queues d and drops the webhook's finished deliveries
+// beyond the latest maxWebhookDeliveries.
+func (s *SQLStore) EnqueueDelivery(ctx context.Context, d OutboundDelivery) error {
+	return s.WithTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.dialect.rebind(
+			`INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, last_error) VALUES ($1, $2, $3, $4, $5, '')`),
+			d.WebhookID, d.Event, string(d.Payload), DeliveryPending, time.Now().UTC(),
+		)
+		if err != nil {
+			return err
+		}
+		var oldest int64
+		err = tx.QueryRowContext(ctx, s.dialect.rebind(
+			`SELECT id FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT 1 OFFSET $2`),
+			d.WebhookID, maxWebhookDeliveries,
+		).Scan(&oldest)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(
+			`DELETE FROM webhook_deliveries WHERE webhook_id = $1 AND id <= $2 AND status <> $3`),
+			d.WebhookID, oldest, DeliveryPending,
+		)
+		return err
+	})
+}
+
+// ClaimDueDeliveries claims each due delivery with a conditional update, so
+// of several replicas polling at once only one gets it. Deliveries of
+// paused or throttled webhooks are not due.
+func (s *SQLStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboundDelivery, error) {
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries
+		 WHERE status = $1 AND next_attempt_at <= $2
+		   AND webhook_id IN (SELECT id FROM webhooks WHERE paused_at IS NULL AND (throttled_until IS NULL OR throttled_until <= $3))
+		 ORDER BY next_attempt_at ASC, id ASC LIMIT $4`),
+		DeliveryPending, now.UTC(), now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var due []OutboundDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := []OutboundDelivery{}
+	for _, d := range due {
+		res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(
+			`UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE id = $2 AND status = $3 AND next_attempt_at <= $4`),
+			now.Add(lease).UTC(), d.ID, DeliveryPending, now.UTC(),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// RecordDeliveryAttempt stores the outcome of an attempt at d.
+func (s *SQLStore) RecordDeliveryAttempt(ctx context.Context, d OutboundDelivery) error {
+	_, err := s.SQL.ExecContext(ctx, s.dialect.rebind(
+		`UPDATE webhook_deliveries
+		 SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, updated_at = `+s.dialect.now+`
+		 WHERE id = $6`),
+		d.Status, d.Attempts, d.NextAttemptAt.UTC(), d.LastStatusCode, d.LastError, d.ID,
+	)
+	return err
+}
+
+// ListOutboundDeliveries returns up to limit of a webhook's latest deliveries,
+// newest first.
+func (s *SQLStore) ListOutboundDeliveries(ctx context.Context, webhookID int64, limit int) ([]OutboundDelivery, error) {
+	if limit <= 0 || limit > maxWebhookDeliveries {
+		limit = maxWebhookDeliveries
+	}
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2`),
+		webhookID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []OutboundDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// CountPendingDeliveries counts the pending deliveries.
+func (s *SQLStore) CountPendingDeliveries(ctx context.Context) (int, error) {
+	var n int
+	err := s.SQL.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM webhook_deliveries WHERE status = $1`), DeliveryPending).Scan(&n)
+	return n, err
+}
+
+// RecordEndpointOutcome updates the webhook's failure count, pausing it
+// when pauseAfter is reached.
+func (s *SQLStore) RecordEndpointOutcome(ctx context.Context, id int64, failed bool, pauseAfter int) (bool, error) {
+	if !failed {
+		_, err := s.SQL.ExecContext(ctx, s.dialect.rebind(`UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures <> 0`), id)
+		return false, err
+	}
+	var paused bool
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		paused = false
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE webhooks SET consecutive_failures = consecutive_failures + 1 WHERE id = $1`), id); err != nil {
+			return err
+		}
+		if pauseAfter <= 0 {
+			return nil
+		}
+		res, err := tx.ExecContext(ctx, s.dialect.rebind(
+			`UPDATE webhooks SET paused_at = $1 WHERE id = $2 AND paused_at IS NULL AND consecutive_failures >= $3`),
+			time.Now().UTC(), id, pauseAfter,
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		paused = n == 1
+		return err
+	})
+	if paused {
+		slog.WarnContext(ctx, "webhook.paused", "id", id, "consecutive_failures", pauseAfter)
+	}
+	return paused, err
+}
+
+// ThrottleWebhook holds back the webhook's deliveries until until.
+func (s *SQLStore) ThrottleWebhook(ctx context.Context, id int64, until time.Time) error {
+	_, err := s.SQL.ExecContext(ctx, s.dialect.rebind(
+		`UPDATE webhooks SET throttled_until = $1 WHERE id = $2 AND (throttled_until IS NULL OR throttled_until < $3)`),
+		until.UTC(), id, until.UTC(),
+	)
+	return err
+}
+
+// ResumeWebhook unpauses the webhook and makes its pending deliveries due.
+func (s *SQLStore) ResumeWebhook(ctx context.Context, id int64) (Webhook, error) {
+	owned, ownerArgs := ownerCond(ctx, "owner_id", 1)
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, s.dialect.rebind(
+			`UPDATE webhooks SET consecutive_failures = 0, paused_at = NULL, throttled_until = NULL WHERE id = $1`+owned),
+			append([]any{id}, ownerArgs...)...,
+		)
+		if err != nil {
+			return err
+		}
+		// MySQL counts only changed rows, so a webhook that was not paused
+		// is told apart from a missing one by looking it up.
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			var found int64
+			err := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT id FROM webhooks WHERE id = $1`+owned), append([]any{id}, ownerArgs...)...).Scan(&found)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrWebhookNotFound
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(
+			`UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE webhook_id = $2 AND status = $3`),
+			time.Now().UTC(), id, DeliveryPending,
+		)
+		return err
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	slog.InfoContext(ctx, "webhook.resumed", "id", id)
+	return s.GetWebhook(ctx, id)
+}
+
+var (
+	webhooksBucket = []byte("webhooks")
+	// deliveriesBucket keys deliveries by webhook id followed by delivery
+	// id, so each webhook's deliveries are a contiguous, ordered key range.
+	deliveriesBucket = []byte("webhook_deliveries")
+)
+
+// ListWebhooks returns all webhooks ordered by id.
+func (s *BoltStore) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	out := []Webhook{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).ForEach(func(_, v []byte) error {
+			var w Webhook
+			if err := json.Unmarshal(v, &w); err != nil {
+				return fmt.Errorf("decode webhook: %w", err)
+			}
+			if visible(ctx, w.OwnerID) {
+				out = append(out, w)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetWebhook returns a webhook by id.
+func (s *BoltStore) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	var w Webhook
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		w, err = getBoltWebhook(ctx, tx, id)
+		return err
+	})
+	return w, err
+}
+
+// CreateWebhook creates a new webhook.
+func (s *BoltStore) CreateWebhook(ctx context.Context, input SaveWebhookInput) (Webhook, error) {
+	if err := validateWebhookInput(&input); err != nil {
+		return Webhook{}, err
+	}
+	var w Webhook
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(webhooksBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		w = applyWebhookInput(Webhook{ID: int64(seq), CreatedAt: now}, input, now)
+		w.OwnerID, _ = OwnerFrom(ctx)
+		return putBoltWebhook(b, w)
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	slog.InfoContext(ctx, "webhook.created", "id", w.ID)
+	return w, nil
+}
+
+// UpdateWebhook updates a webhook by id.
+func (s *BoltStore) UpdateWebhook(ctx context.Context, id int64, input SaveWebhookInput) (Webhook, error) {
+	if err := validateWebhookInput(&input); err != nil {
+		return Webhook{}, err
+	}
+	var w Webhook
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		existing, err := getBoltWebhook(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		w = applyWebhookInput(existing, input, time.Now().UTC())
+		return putBoltWebhook(tx.Bucket(webhooksBucket), w)
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	slog.InfoContext(ctx, "webhook.updated", "id", w.ID)
+	return w, nil
+}
+
+// DeleteWebhook deletes a webhook and its deliveries.
+func (s *BoltStore) DeleteWebhook(ctx context.Context, id int64) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		if _, err := getBoltWebhook(ctx, tx, id); err != nil {
+			if errors.Is(err, ErrWebhookNotFound) {
+				return nil
+			}
+			return err
+		}
+		if err := tx.Bucket(webhooksBucket).Delete(boltKey(id)); err != nil {
+			return err
+		}
+		deliveries := tx.Bucket(deliveriesBucket)
+		prefix := boltKey(id)
+		c := deliveries.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+			if err := deliveries.Delete(k); err != nil {
+				return err
+			}
+		}
+		slog.InfoContext(ctx, "webhook.deleted", "id", id)
+		return nil
+	})
+}
+
+// EnqueueDelivery queues d and drops the webhook's finished deliveries
+// beyond the latest maxWebhookDeliveries.
+func (s *BoltStore) EnqueueDelivery(ctx context.Context, d OutboundDelivery) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		deliveries := tx.Bucket(deliveriesBucket)
+		seq, err := deliveries.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		d.ID, d.Status, d.Attempts = int64(seq), DeliveryPending, 0
+		d.NextAttemptAt, d.CreatedAt, d.UpdatedAt = now, now, now
+		prefix := boltKey(d.WebhookID)
+		if err := putBoltDelivery(deliveries, append(prefix, boltKey(d.ID)...), d); err != nil {
+			return err
+		}
+		// Keys are ordered oldest first; the newest finished ones are kept.
+		var finished [][]byte
+		total := 0
+		c := deliveries.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			total++
+			var existing OutboundDelivery
+			if err := json.Unmarshal(v, &existing); err != nil {
+				return fmt.Errorf("decode webhook delivery: %w", err)
+			}
+			if existing.Status != DeliveryPending {
+				finished = append(finished, bytes.Clone(k))
+			}
+		}
+		for _, k := range finished[:min(len(finished), max(0, total-maxWebhookDeliveries))] {
+			if err := deliveries.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimDueDeliveries returns the pending deliveries due by now, moving their
+// next attempt ahead by lease. Deliveries of paused or throttled webhooks
+// are not due.
+func (s *BoltStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboundDelivery, error) {
+	out := []OutboundDelivery{}
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		deliveries := tx.Bucket(deliveriesBucket)
+		type claim struct {
+			key []byte
+			d   OutboundDelivery
+		}
+		var due []claim
+		held := map[int64]bool{}
+		err := tx.Bucket(webhooksBucket).ForEach(func(_, v []byte) error {
+			var w Webhook
+			if err := json.Unmarshal(v, &w); err != nil {
+				return fmt.Errorf("decode webhook: %w", err)
+			}
+			held[w.ID] = !w.dispatchable(now)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = deliveries.ForEach(func(k, v []byte) error {
+			var d OutboundDelivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return fmt.Errorf("decode webhook delivery: %w", err)
+			}
+			if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) && !held[d.WebhookID] {
+				due = append(due, claim{bytes.Clone(k), d})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		slices.SortStableFunc(due, func(a, b claim) int {
+			if c := a.d.NextAttemptAt.Compare(b.d.NextAttemptAt); c != 0 {
+				return c
+			}
+			return int(a.d.ID - b.d.ID)
+		})
+		for _, c := range due[:min(len(due), limit)] {
+			out = append(out, c.d)
+			c.d.NextAttemptAt = now.Add(lease)
+			if err := putBoltDelivery(deliveries, c.key, c.d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RecordDeliveryAttempt stores the outcome of an attempt at d.
+func (s *BoltStore) RecordDeliveryAttempt(ctx context.Context, d OutboundDelivery) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		deliveries := tx.Bucket(deliveriesBucket)
+		key := append(boltKey(d.WebhookID), boltKey(d.ID)...)
+		if deliveries.Get(key) == nil {
+			// The webhook was deleted while the delivery was in flight.
+			return nil
+		}
+		d.UpdatedAt = time.Now().UTC()
+		return putBoltDelivery(deliveries, key, d)
+	})
+}
+
+// ListOutboundDeliveries returns up to limit of a webhook's latest deliveries,
+// newest first.
+func (s *BoltStore) ListOutboundDeliveries(ctx context.Context, webhookID int64, limit int) ([]OutboundDelivery, error) {
+	if limit <= 0 || limit > maxWebhookDeliveries {
+		limit = maxWebhookDeliveries
+	}
+	out := []OutboundDelivery{}
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		prefix := boltKey(webhookID)
+		c := tx.Bucket(deliveriesBucket).Cursor()
+		k, v := c.Seek(boltKey(webhookID + 1))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix) && len(out) < limit; k, v = c.Prev() {
+			var d OutboundDelivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return fmt.Errorf("decode webhook delivery: %w", err)
+			}
+			out = append(out, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CountPendingDeliveries counts the pending deliveries.
+func (s *BoltStore) CountPendingDeliveries(ctx context.Context) (int, error) {
+	n := 0
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deliveriesBucket).ForEach(func(_, v []byte) error {
+			var d OutboundDelivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return fmt.Errorf("decode webhook delivery: %w", err)
+			}
+			if d.Status == DeliveryPending {
+				n++
+			}
+			return nil
+		})
+	})
+	return n, err
+}
+
+// RecordEndpointOutcome updates the webhook's failure count, pausing it
+// when pauseAfter is reached.
+func (s *BoltStore) RecordEndpointOutcome(ctx context.Context, id int64, failed bool, pauseAfter int) (bool, error) {
+	var paused bool
+	err := s.updateBoltWebhook(id, func(w *Webhook) {
+		if !failed {
+			w.ConsecutiveFailures = 0
+			return
+		}
+		w.ConsecutiveFailures++
+		if pauseAfter > 0 && w.PausedAt == nil && w.ConsecutiveFailures >= pauseAfter {
+			now := time.Now().UTC()
+			w.PausedAt, paused = &now, true
+		}
+	})
+	if paused {
+		slog.WarnContext(ctx, "webhook.paused", "id", id, "consecutive_failures", pauseAfter)
+	}
+	return paused, err
+}
+
+// ThrottleWebhook holds back the webhook's deliveries until until.
+func (s *BoltStore) ThrottleWebhook(ctx context.Context, id int64, until time.Time) error {
+	return s.updateBoltWebhook(id, func(w *Webhook) {
+		if w.ThrottledUntil == nil || w.ThrottledUntil.Before(until) {
+			until := until.UTC()
+			w.ThrottledUntil = &until
+		}
+	})
+}
+
+// updateBoltWebhook applies fn to webhook id, regardless of its owner. A
+// webhook deleted meanwhile is left alone.
+func (s *BoltStore) updateBoltWebhook(id int64, fn func(*Webhook)) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		w, err := getBoltWebhook(context.Background(), tx, id)
+		if errors.Is(err, ErrWebhookNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(&w)
+		return putBoltWebhook(tx.Bucket(webhooksBucket), w)
+	})
+}
+
+// ResumeWebhook unpauses the webhook and makes its pending deliveries due.
+func (s *BoltStore) ResumeWebhook(ctx context.Context, id int64) (Webhook, error) {
+	var w Webhook
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		if w, err = getBoltWebhook(ctx, tx, id); err != nil {
+			return err
+		}
+		w.ConsecutiveFailures, w.PausedAt, w.ThrottledUntil = 0, nil, nil
+		if err := putBoltWebhook(tx.Bucket(webhooksBucket), w); err != nil {
+			return err
+		}
+		deliveries := tx.Bucket(deliveriesBucket)
+		prefix := boltKey(id)
+		pending := map[string]OutboundDelivery{}
+		c := deliveries.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var d OutboundDelivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return fmt.Errorf("decode webhook delivery: %w", err)
+			}
+			if d.Status == DeliveryPending {
+				pending[string(k)] = d
+			}
+		}
+		now := time.Now().UTC()
+		for k, d := range pending {
+			d.NextAttemptAt = now
+			if err := putBoltDelivery(deliveries, []byte(k), d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	slog.InfoContext(ctx, "webhook.resumed", "id", id)
+	return w, nil
+}
+
+func getBoltWebhook(ctx context.Context, tx *bolt.Tx, id int64) (Webhook, error) {
+	v := tx.Bucket(webhooksBucket).Get(boltKey(id))
+	if v == nil {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	var w Webhook
+	if err := json.Unmarshal(v, &w); err != nil {
+		return Webhook{}, fmt.Errorf("decode webhook: %w", err)
+	}
+	if !visible(ctx, w.OwnerID) {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+func applyWebhookInput(w Webhook, input SaveWebhookInput, now time.Time) Webhook {
+	w.URL, w.Secret, w.Events, w.Enabled = input.URL, input.Secret, input.Events, input.Enabled
+	w.Filter, w.Template = input.Filter, input.Template
+	w.UpdatedAt = now
+	return w
+}
+
+func putBoltWebhook(b *bolt.Bucket, w Webhook) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("encode webhook: %w", err)
+	}
+	return b.Put(boltKey(w.ID), data)
+}
+
+func putBoltDelivery(b *bolt.Bucket, key []byte, d OutboundDelivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("encode webhook delivery: %w", err)
+	}
+	return b.Put(key, data)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// WebhookDelivery is an inbound webhook payload kept for debugging.
+type WebhookDelivery struct {
+	ID         int64     `json:"id"`
+	Provider   string    `json:"provider"`
+	DeliveryID string    `json:"deliveryId"`
+	Event      string    `json:"event"`
+	Payload    []byte    `json:"-"`
+	ReceivedAt time.Time `json:"receivedAt"`
+}
+
+// WebhookDeliveryStore is implemented by backends that persist inbound deliveries.
+type WebhookDeliveryStore interface {
+	RecordWebhookDelivery(ctx context.Context, d WebhookDelivery) (duplicate bool, err error)
+	ListWebhookDeliveries(ctx context.Context, provider string, limit int) ([]WebhookDelivery, error)
+}
+
+// RecordWebhookDelivery stores a delivery. The (provider, delivery_id) unique
+// key makes replays a no-op, reported as duplicate.
+func (s *SQLStore) RecordWebhookDelivery(ctx context.Context, d WebhookDelivery) (bool, error) {
+	res, err := s.SQL.ExecContext(ctx, s.dialect.rebind(s.dialect.insertIgnore(
+		`INSERT INTO inbound_webhooks (provider, delivery_id, event, payload, received_at)
+		 VALUES ($1, $2, $3, $4, $5)`)),
+		d.Provider, d.DeliveryID, d.Event, string(d.Payload), d.ReceivedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+// ListWebhookDeliveries returns the most recent deliveries, optionally for one provider.
+func (s *SQLStore) ListWebhookDeliveries(ctx context.Context, provider string, limit int) ([]WebhookDelivery, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	rows, err := s.SQL.QueryContext(ctx, s.dialect.rebind(
+		`SELECT id, provider, delivery_id, event, payload, received_at
+		 FROM inbound_webhooks
+		 WHERE ($1 = '' OR provider = $2)
+		 ORDER BY received_at DESC
+		 LIMIT $3`), provider, provider, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		if err := rows.Scan(&d.ID, &d.Provider, &d.DeliveryID, &d.Event, &payload, &d.ReceivedAt); err != nil {
+			return nil, err
+		}
+		d.Payload = []byte(payload)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,343 @@
+// Package grpc serves todo.v1.TodoService (proto/todo/v1/todo.proto), the
+// gRPC counterpart of the REST todo endpoints. It runs on the REST API's
+// server.Server, so both share the store, scorer, hooks and live events.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"todoapp/internal/auth"
+	"todoapp/internal/db"
+	"todoapp/internal/grpc/todopb"
+	"todoapp/internal/hooks"
+	"todoapp/internal/logging"
+	"todoapp/internal/server"
+)
+
+// callTimeout bounds unary calls, as the REST handlers bound requests.
+const callTimeout = 5 * time.Second
+
+// Todos is the todo service the API exposes; *server.Server implements it.
+type Todos interface {
+	ListTodos(ctx context.Context, filter db.TodoFilter) ([]db.Todo, error)
+	CreateTodo(ctx context.Context, input db.SaveTodoInput) (db.Todo, error)
+	UpdateTodo(ctx context.Context, id int64, input db.SaveTodoInput) (db.Todo, error)
+	DeleteTodo(ctx context.Context, id int64) error
+	WatchTodos(ctx context.Context, send func(server.TodoEvent) error) error
+}
+
+// activeUsers is implemented by Todos that deactivate users, whose
+// sessions then stop working.
+type activeUsers interface {
+	UserActive(ctx context.Context, id int64) (bool, error)
+}
+
+// Option configures the gRPC server.
+type Option func(*service)
+
+// WithAuth requires every call to carry a session token issued by signer in
+// its "authorization: Bearer <token>" metadata, and scopes it to that user
+// as the REST API does.
+func WithAuth(signer *auth.Signer) Option {
+	return func(s *service) {
+		s.sessions = signer
+	}
+}
+
+type service struct {
+	todopb.UnimplementedTodoServiceServer
+
+	todos    Todos
+	sessions *auth.Signer
+}
+
+// NewServer returns a gRPC server with TodoService registered on todos.
+// Stop it with GracefulStop after todos' streams were drained
+// (server.Server.DrainStreams), or WatchTodos calls keep it waiting.
+func NewServer(todos Todos, opts ...Option) *grpclib.Server {
+	svc := &service{todos: todos}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	gs := grpclib.NewServer(
+		grpclib.UnaryInterceptor(svc.unaryInterceptor),
+		grpclib.StreamInterceptor(svc.streamInterceptor),
+	)
+	todopb.RegisterTodoServiceServer(gs, svc)
+	return gs
+}
+
+func (s *service) unaryInterceptor(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, err)
+	return resp, err
+}
+
+func (s *service) streamInterceptor(srv any, ss grpclib.ServerStream, info *grpclib.StreamServerInfo, handler grpclib.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	err = handler(srv, scopedStream{ServerStream: ss, ctx: ctx})
+	logCall(ctx, info.FullMethod, err)
+	return err
+}
+
+// scopedStream replaces a stream's context with the authenticated one.
+type scopedStream struct {
+	grpclib.ServerStream
+	ctx context.Context
+}
+
+func (s scopedStream) Context() context.Context { return s.ctx }
+
+// authenticate scopes ctx to the user whose session token the call carries.
+// Without accounts every call is let through unscoped.
+func (s *service) authenticate(ctx context.Context) (context.Context, error) {
+	if s.sessions == nil {
+		return ctx, nil
+	}
+	var token string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		token, _ = strings.CutPrefix(v[0], "Bearer ")
+	}
+	id, err := s.sessions.Verify(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "login required")
+	}
+	if users, ok := s.todos.(activeUsers); ok {
+		active, err := users.UserActive(ctx, id)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to load user")
+		}
+		if !active {
+			return nil, status.Error(codes.Unauthenticated, "account deactivated")
+		}
+	}
+	return logging.With(db.WithOwner(ctx, id), "user_id", id), nil
+}
+
+func logCall(ctx context.Context, method string, err error) {
+	switch status.Code(err) {
+	case codes.OK, codes.Canceled:
+		slog.DebugContext(ctx, "grpc.call", "method", method)
+	case codes.Internal:
+		slog.ErrorContext(ctx, "grpc.call_failed", "method", method, "error", err)
+	default:
+		slog.InfoContext(ctx, "grpc.call_rejected", "method", method, "code", status.Code(err).String())
+	}
+}
+
+func (s *service) ListTodos(ctx context.Context, req *todopb.ListTodosRequest) (*todopb.ListTodosResponse, error) {
+	filter := db.TodoFilter{
+		Completed: req.Completed,
+		Tag:       strings.TrimSpace(strings.ToLower(req.Tag)),
+		ListID:    req.ListId,
+	}
+	// Deferred todos stay out of the default view until their start date.
+	if !req.IncludeDeferred {
+		filter.AvailableAt = time.Now()
+	}
+	items, err := s.todos.ListTodos(ctx, filter)
+	if err != nil {
+		return nil, statusError(err, "failed to list todos")
+	}
+	resp := &todopb.ListTodosResponse{Todos: make([]*todopb.Todo, len(items))}
+	for i, t := range items {
+		resp.Todos[i] = todoProto(t)
+	}
+	return resp, nil
+}
+
+func (s *service) CreateTodo(ctx context.Context, req *todopb.CreateTodoRequest) (*todopb.Todo, error) {
+	dueAt, err := timeOf(req.DueAt, "due_at")
+	if err != nil {
+		return nil, err
+	}
+	startAt, err := timeOf(req.StartAt, "start_at")
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.todos.CreateTodo(ctx, db.SaveTodoInput{
+		Title:           req.Title,
+		Tags:            req.Tags,
+		DurationMinutes: int(req.DurationMinutes),
+		DueAt:           dueAt,
+		StartAt:         startAt,
+		Effort:          intPtr(req.Effort),
+		ReminderOffsets: ints(req.ReminderOffsets),
+		Recurrence:      req.Recurrence,
+		ListID:          req.ListId,
+	})
+	if err != nil {
+		return nil, statusError(err, "")
+	}
+	return todoProto(item), nil
+}
+
+func (s *service) UpdateTodo(ctx context.Context, req *todopb.UpdateTodoRequest) (*todopb.Todo, error) {
+	if req.Id <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	dueAt, err := timeOf(req.DueAt, "due_at")
+	if err != nil {
+		return nil, err
+	}
+	startAt, err := timeOf(req.StartAt, "start_at")
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.todos.UpdateTodo(ctx, req.Id, db.SaveTodoInput{
+		Title:           req.Title,
+		Completed:       req.Completed,
+		Tags:            req.Tags,
+		DurationMinutes: int(req.DurationMinutes),
+		DueAt:           dueAt,
+		StartAt:         startAt,
+		Effort:          intPtr(req.Effort),
+		ReminderOffsets: ints(req.ReminderOffsets),
+		Recurrence:      req.Recurrence,
+		ListID:          req.ListId,
+	})
+	if err != nil {
+		return nil, statusError(err, "")
+	}
+	return todoProto(item), nil
+}
+
+func (s *service) DeleteTodo(ctx context.Context, req *todopb.DeleteTodoRequest) (*todopb.DeleteTodoResponse, error) {
+	if req.Id <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	if err := s.todos.DeleteTodo(ctx, req.Id); err != nil {
+		return nil, statusError(err, "failed to delete")
+	}
+	return &todopb.DeleteTodoResponse{}, nil
+}
+
+func (s *service) WatchTodos(_ *todopb.WatchTodosRequest, stream grpclib.ServerStreamingServer[todopb.TodoEvent]) error {
+	err := s.todos.WatchTodos(stream.Context(), func(ev server.TodoEvent) error {
+		msg := &todopb.TodoEvent{Type: ev.Type, Id: ev.ID, Message: ev.Message}
+		if ev.Todo != nil {
+			msg.Todo = todoProto(*ev.Todo)
+		}
+		return stream.Send(msg)
+	})
+	if err == nil || status.Code(err) != codes.Unknown {
+		return err
+	}
+	return statusError(err, "watch failed")
+}
+
+// statusError maps err to a gRPC status as the REST handlers map it to an
+// HTTP status. Errors with no better code are Internal with the message
+// internal, or, when internal is empty, InvalidArgument with err's text,
+// which is how the store reports invalid input.
+func statusError(err error, internal string) error {
+	var limit *server.LimitError
+	var rejection *hooks.Rejection
+	switch {
+	case errors.As(err, &limit):
+		return status.Error(codes.ResourceExhausted, limit.Reason)
+	case errors.As(err, &rejection):
+		return status.Error(codes.InvalidArgument, rejection.Reason)
+	case errors.Is(err, server.ErrHookFailed):
+		return status.Error(codes.Unavailable, "todo hook failed")
+	case errors.Is(err, server.ErrDraining):
+		return status.Error(codes.Unavailable, "server is restarting")
+	case errors.Is(err, server.ErrWatchLagged):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, server.ErrFilterUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, db.ErrNotFound):
+		return status.Error(codes.NotFound, "todo not found")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "timed out")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "canceled")
+	case internal == "":
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, internal)
+	}
+}
+
+func todoProto(t db.Todo) *todopb.Todo {
+	p := &todopb.Todo{
+		Id:              t.ID,
+		Uuid:            t.UUID,
+		Title:           t.Title,
+		Completed:       t.Completed,
+		Tags:            t.Tags,
+		DurationMinutes: int32(t.DurationMinutes),
+		PriorityScore:   t.PriorityScore,
+		Recurrence:      t.Recurrence,
+		ListId:          t.ListID,
+		CreatedAt:       timestamppb.New(t.CreatedAt),
+		UpdatedAt:       timestamppb.New(t.UpdatedAt),
+	}
+	if t.DueAt != nil {
+		p.DueAt = timestamppb.New(*t.DueAt)
+	}
+	if t.StartAt != nil {
+		p.StartAt = timestamppb.New(*t.StartAt)
+	}
+	if t.Effort != nil {
+		e := int32(*t.Effort)
+		p.Effort = &e
+	}
+	for _, m := range t.ReminderOffsets {
+		p.ReminderOffsets = append(p.ReminderOffsets, int32(m))
+	}
+	return p
+}
+
+// timeOf converts an optional timestamp, rejecting out-of-range ones.
+func timeOf(ts *timestamppb.Timestamp, field string) (*time.Time, error) {
+	if ts == nil {
+		return nil, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s", field)
+	}
+	t := ts.AsTime()
+	return &t, nil
+}
+
+func intPtr(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+	n := int(*v)
+	return &n
+}
+
+// ints converts reminder offsets; none means the defaults, as a proto3
+// repeated field cannot tell an empty list from an unset one.
+func ints(v []int32) []int {
+	if len(v) == 0 {
+		return nil
+	}
+	out := make([]int, len(v))
+	for i, n := range v {
+		out[i] = int(n)
+	}
+	return out
+}
@@ -0,0 +1,131 @@
+// Package hooks runs extensions at fixed points of a todo's lifecycle, so a
+// deployment can enforce its own business rules without patching handlers.
+// Extensions are Go plugins compiled into the binary and registered in main,
+// or external HTTP endpoints (see HTTP).
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// Lifecycle points, as named to HTTP hooks.
+const (
+	BeforeCreate = "before_create"
+	AfterUpdate  = "after_update"
+	OnComplete   = "on_complete"
+)
+
+// observerTimeout bounds one run of the after-update and on-complete hooks.
+const observerTimeout = 10 * time.Second
+
+// Plugin is an extension. It takes part in a lifecycle point by also
+// implementing the matching interface below.
+type Plugin interface {
+	Name() string
+}
+
+// BeforeCreator may change a todo before it is created, or refuse it by
+// returning a *Rejection. Any other error fails the create.
+type BeforeCreator interface {
+	BeforeCreate(ctx context.Context, input *db.SaveTodoInput) error
+}
+
+// AfterUpdater observes every update made to a single todo.
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context, before, after db.Todo)
+}
+
+// Completer observes a todo being completed.
+type Completer interface {
+	OnComplete(ctx context.Context, t db.Todo)
+}
+
+// Rejection refuses a write for a reason shown to the client.
+type Rejection struct {
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return r.Reason
+}
+
+// Registry runs plugins in registration order. A nil Registry runs nothing.
+type Registry struct {
+	plugins []Plugin
+}
+
+// NewRegistry returns a Registry running plugins.
+func NewRegistry(plugins ...Plugin) *Registry {
+	return &Registry{plugins: plugins}
+}
+
+// Register adds p after the plugins already registered.
+func (r *Registry) Register(p Plugin) {
+	r.plugins = append(r.plugins, p)
+}
+
+// Len returns how many plugins are registered.
+func (r *Registry) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.plugins)
+}
+
+// BeforeCreate lets each plugin change input in turn, stopping at the first
+// error. Callers must normalize input again afterwards.
+func (r *Registry) BeforeCreate(ctx context.Context, input *db.SaveTodoInput) error {
+	if r == nil {
+		return nil
+	}
+	for _, p := range r.plugins {
+		bc, ok := p.(BeforeCreator)
+		if !ok {
+			continue
+		}
+		if err := bc.BeforeCreate(ctx, input); err != nil {
+			return fmt.Errorf("%s: %w", p.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Updated runs the after-update hooks for a todo that changed from before to
+// after, and the on-complete hooks if that completed it. They run in the
+// background, so a slow hook never delays the response.
+func (r *Registry) Updated(ctx context.Context, before, after db.Todo) {
+	if r.Len() == 0 {
+		return
+	}
+	completed := after.Completed && !before.Completed
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, observerTimeout)
+		defer cancel()
+		for _, p := range r.plugins {
+			observe(ctx, p, func() {
+				if au, ok := p.(AfterUpdater); ok {
+					au.AfterUpdate(ctx, before, after)
+				}
+				if c, ok := p.(Completer); ok && completed {
+					c.OnComplete(ctx, after)
+				}
+			})
+		}
+	}()
+}
+
+// observe runs fn, keeping a panicking plugin from taking the process down.
+func observe(ctx context.Context, p Plugin, fn func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			slog.ErrorContext(ctx, "hooks.panic", "plugin", p.Name(), "panic", fmt.Sprint(v))
+		}
+	}()
+	fn()
+}
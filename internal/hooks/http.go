@@ -0,0 +1,177 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// HTTP is a hook served by an external endpoint. Each lifecycle point in
+// Events (all when empty) is POSTed as JSON {"event", "todo"}, plus "before"
+// for after_update, and signed with an X-Hook-Signature of
+// "sha256=<hex HMAC of the body>" when Secret is set.
+//
+// For before_create the endpoint answers 200, optionally with {"todo": {...}}
+// overriding any of the todo's fields, or 422 with {"error": "..."} to refuse
+// it. Any other outcome fails the create, unless FailOpen is set. Failures of
+// the other events are only logged.
+type HTTP struct {
+	URL      string
+	Secret   string
+	Events   []string
+	FailOpen bool
+	Client   *http.Client
+}
+
+// draft is the wire form of a todo about to be created.
+type draft struct {
+	Title           string     `json:"title"`
+	Completed       bool       `json:"completed"`
+	Tags            []string   `json:"tags"`
+	DurationMinutes int        `json:"durationMinutes"`
+	DueAt           *time.Time `json:"dueAt"`
+	StartAt         *time.Time `json:"startAt"`
+	Effort          *int       `json:"effort"`
+	ReminderOffsets []int      `json:"reminderOffsets"`
+	Recurrence      string     `json:"recurrence"`
+	ListID          *int64     `json:"listId"`
+}
+
+// Name implements Plugin.
+func (h HTTP) Name() string {
+	if u, err := url.Parse(h.URL); err == nil {
+		return "http:" + u.Host
+	}
+	return "http"
+}
+
+func (h HTTP) wants(event string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, event)
+}
+
+// BeforeCreate implements BeforeCreator.
+func (h HTTP) BeforeCreate(ctx context.Context, input *db.SaveTodoInput) error {
+	if !h.wants(BeforeCreate) {
+		return nil
+	}
+	d := draft{
+		Title:           input.Title,
+		Completed:       input.Completed,
+		Tags:            input.Tags,
+		DurationMinutes: input.DurationMinutes,
+		DueAt:           input.DueAt,
+		StartAt:         input.StartAt,
+		Effort:          input.Effort,
+		ReminderOffsets: input.ReminderOffsets,
+		Recurrence:      input.Recurrence,
+		ListID:          input.ListID,
+	}
+	status, body, err := h.post(ctx, map[string]any{"event": BeforeCreate, "todo": d})
+	if err == nil && status == http.StatusUnprocessableEntity {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &resp) != nil || resp.Error == "" {
+			resp.Error = "rejected by " + h.Name()
+		}
+		return &Rejection{Reason: resp.Error}
+	}
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("status %d", status)
+	}
+	if err == nil && len(bytes.TrimSpace(body)) > 0 {
+		// Fields the response leaves out keep their current values.
+		resp := struct {
+			Todo *draft `json:"todo"`
+		}{&d}
+		if json.Unmarshal(body, &resp) != nil {
+			err = errors.New("invalid response body")
+		}
+	}
+	if err != nil {
+		if h.FailOpen {
+			slog.WarnContext(ctx, "hooks.http_failed", "event", BeforeCreate, "hook", h.Name(), "error", err)
+			return nil
+		}
+		return err
+	}
+	*input = db.SaveTodoInput{
+		Title:           d.Title,
+		Completed:       d.Completed,
+		Tags:            d.Tags,
+		DurationMinutes: d.DurationMinutes,
+		PriorityScore:   input.PriorityScore,
+		DueAt:           d.DueAt,
+		StartAt:         d.StartAt,
+		Effort:          d.Effort,
+		ReminderOffsets: d.ReminderOffsets,
+		Recurrence:      d.Recurrence,
+		ListID:          d.ListID,
+	}
+	return nil
+}
+
+// AfterUpdate implements AfterUpdater.
+func (h HTTP) AfterUpdate(ctx context.Context, before, after db.Todo) {
+	h.notify(ctx, AfterUpdate, map[string]any{"event": AfterUpdate, "todo": after, "before": before})
+}
+
+// OnComplete implements Completer.
+func (h HTTP) OnComplete(ctx context.Context, t db.Todo) {
+	h.notify(ctx, OnComplete, map[string]any{"event": OnComplete, "todo": t})
+}
+
+func (h HTTP) notify(ctx context.Context, event string, payload any) {
+	if !h.wants(event) {
+		return
+	}
+	status, _, err := h.post(ctx, payload)
+	if err == nil && status >= 300 {
+		err = fmt.Errorf("status %d", status)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "hooks.http_failed", "event", event, "hook", h.Name(), "error", err)
+	}
+}
+
+// post sends payload and returns the response status and up to 1MB of body.
+func (h HTTP) post(ctx context.Context, payload any) (int, []byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Hook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, respBody, err
+}
@@ -0,0 +1,161 @@
+// Package idcodec turns the int64 ids of stored records into short opaque
+// strings for public URLs and back, so that ids cannot be enumerated or used
+// to tell how many records there are.
+//
+// The Obfuscator is in the spirit of hashids and sqids: a keyed, reversible
+// scrambling of the id written in a shuffled base-62 alphabet. It is
+// obfuscation, not encryption; authorization still guards every record.
+package idcodec
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+)
+
+// ErrInvalid is returned by Decode for strings no id encodes to.
+var ErrInvalid = errors.New("idcodec: invalid id")
+
+// Codec encodes positive ids. Decode(Encode(id)) returns id, and Decode
+// accepts no other form of it, so each id has exactly one public spelling.
+type Codec interface {
+	Encode(id int64) string
+	Decode(s string) (int64, error)
+}
+
+// Domains hands out a Codec per kind of record, so that records of
+// different kinds sharing an id get unrelated public ids.
+type Domains interface {
+	Domain(name string) Codec
+}
+
+const (
+	alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// rounds of the Feistel network scrambling the id.
+	rounds = 4
+	// maxLength is the length of the largest uint64 in base 62.
+	maxLength = 11
+)
+
+// Obfuscator is a Codec keyed by a secret: deployments with different
+// secrets spell ids differently, and without the secret consecutive ids
+// look unrelated.
+type Obfuscator struct {
+	key       [sha256.Size]byte
+	alphabet  string
+	index     [256]int8
+	minLength int
+}
+
+// NewObfuscator returns an Obfuscator keyed by secret whose encodings are at
+// least minLength characters long, padded with the alphabet's zero digit.
+// Changing either changes every public id.
+func NewObfuscator(secret string, minLength int) (*Obfuscator, error) {
+	if secret == "" {
+		return nil, errors.New("idcodec: secret must not be empty")
+	}
+	if minLength < 0 || minLength > maxLength {
+		return nil, errors.New("idcodec: minimum length must be between 0 and 11")
+	}
+	return newObfuscator(sha256.Sum256([]byte(secret)), minLength), nil
+}
+
+// Domain implements Domains: the returned Obfuscator's key is o's tweaked
+// by name, so each domain has its own alphabet and permutation.
+func (o *Obfuscator) Domain(name string) Codec {
+	return newObfuscator(sha256.Sum256(append(o.key[:], "domain:"+name...)), o.minLength)
+}
+
+func newObfuscator(key [sha256.Size]byte, minLength int) *Obfuscator {
+	o := &Obfuscator{key: key, minLength: minLength}
+
+	// Shuffle the alphabet (Fisher-Yates) with a stream derived from the key.
+	var stream []byte
+	for n := byte(0); len(stream) < 4*len(alphabet); n++ {
+		sum := sha256.Sum256(append(o.key[:], 'a', n))
+		stream = append(stream, sum[:]...)
+	}
+	chars := []byte(alphabet)
+	for i := len(chars) - 1; i > 0; i-- {
+		j := int(binary.BigEndian.Uint32(stream[4*i:]) % uint32(i+1))
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+	o.alphabet = string(chars)
+	for i := range o.index {
+		o.index[i] = -1
+	}
+	for i, c := range chars {
+		o.index[c] = int8(i)
+	}
+	return o
+}
+
+// Encode implements Codec. ids below 1 are encoded as the zero digit
+// string, which Decode refuses.
+func (o *Obfuscator) Encode(id int64) string {
+	if id < 1 {
+		return strings.Repeat(o.alphabet[:1], max(o.minLength, 1))
+	}
+	v := o.permute(uint64(id))
+	var buf [maxLength]byte
+	i := len(buf)
+	for v > 0 {
+		i--
+		buf[i] = o.alphabet[v%62]
+		v /= 62
+	}
+	s := string(buf[i:])
+	if pad := max(o.minLength, 1) - len(s); pad > 0 {
+		s = strings.Repeat(o.alphabet[:1], pad) + s
+	}
+	return s
+}
+
+// Decode implements Codec.
+func (o *Obfuscator) Decode(s string) (int64, error) {
+	if len(s) == 0 || len(s) > maxLength {
+		return 0, ErrInvalid
+	}
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		d := o.index[s[i]]
+		if d < 0 || v > (math.MaxUint64-uint64(d))/62 {
+			return 0, ErrInvalid
+		}
+		v = v*62 + uint64(d)
+	}
+	id := o.unpermute(v)
+	if id < 1 || id > math.MaxInt64 || o.Encode(int64(id)) != s {
+		return 0, ErrInvalid
+	}
+	return int64(id), nil
+}
+
+// permute scrambles v with a balanced Feistel network over its two 32-bit
+// halves, a bijection on uint64 that unpermute reverses.
+func (o *Obfuscator) permute(v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := 0; i < rounds; i++ {
+		l, r = r, l^o.round(i, r)
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+func (o *Obfuscator) unpermute(v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := rounds - 1; i >= 0; i-- {
+		l, r = r^o.round(i, l), l
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+func (o *Obfuscator) round(i int, half uint32) uint32 {
+	var in [sha256.Size + 5]byte
+	copy(in[:], o.key[:])
+	in[sha256.Size] = byte(i)
+	binary.BigEndian.PutUint32(in[sha256.Size+1:], half)
+	sum := sha256.Sum256(in[:])
+	return binary.BigEndian.Uint32(sum[:])
+}
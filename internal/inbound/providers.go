@@ -0,0 +1,138 @@
+package inbound
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitHub verifies X-Hub-Signature-256 (HMAC-SHA256 of the body). GitHub does
+// not sign a timestamp, so replays are caught by X-GitHub-Delivery alone.
+type GitHub struct {
+	Secret string
+}
+
+// Verify implements Verifier.
+func (g GitHub) Verify(header http.Header, body []byte, _ time.Time) (string, string, error) {
+	h := header.Get
+	sig, ok := strings.CutPrefix(h("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return "", "", errors.New("missing X-Hub-Signature-256")
+	}
+	if !validHexMAC(g.Secret, sig, body) {
+		return "", "", errors.New("signature mismatch")
+	}
+	id := h("X-GitHub-Delivery")
+	if id == "" {
+		return "", "", errors.New("missing X-GitHub-Delivery")
+	}
+	return id, h("X-GitHub-Event"), nil
+}
+
+// Stripe verifies the Stripe-Signature header ("t=<unix>,v1=<hex>"), signed
+// over "<t>.<body>", and rejects timestamps outside Tolerance.
+type Stripe struct {
+	Secret    string
+	Tolerance time.Duration
+}
+
+// Verify implements Verifier.
+func (s Stripe) Verify(header http.Header, body []byte, now time.Time) (string, string, error) {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if err := checkTimestamp(ts, now, s.tolerance()); err != nil {
+		return "", "", err
+	}
+	signed := append([]byte(ts+"."), body...)
+	valid := false
+	for _, sig := range sigs {
+		if validHexMAC(s.Secret, sig, signed) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", "", errors.New("signature mismatch")
+	}
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		return "", "", errors.New("missing event id")
+	}
+	return event.ID, event.Type, nil
+}
+
+func (s Stripe) tolerance() time.Duration {
+	if s.Tolerance <= 0 {
+		return 5 * time.Minute
+	}
+	return s.Tolerance
+}
+
+// Generic verifies integrations that follow the app's own convention:
+// X-Webhook-Signature is "sha256=<hex>" over "<X-Webhook-Timestamp>.<body>",
+// and X-Webhook-Id identifies the delivery.
+type Generic struct {
+	Secret    string
+	Tolerance time.Duration
+}
+
+// Verify implements Verifier.
+func (g Generic) Verify(header http.Header, body []byte, now time.Time) (string, string, error) {
+	h := header.Get
+	ts := h("X-Webhook-Timestamp")
+	tolerance := g.Tolerance
+	if tolerance <= 0 {
+		tolerance = 5 * time.Minute
+	}
+	if err := checkTimestamp(ts, now, tolerance); err != nil {
+		return "", "", err
+	}
+	sig, ok := strings.CutPrefix(h("X-Webhook-Signature"), "sha256=")
+	if !ok || !validHexMAC(g.Secret, sig, append([]byte(ts+"."), body...)) {
+		return "", "", errors.New("signature mismatch")
+	}
+	id := h("X-Webhook-Id")
+	if id == "" {
+		return "", "", errors.New("missing X-Webhook-Id")
+	}
+	return id, h("X-Webhook-Event"), nil
+}
+
+func checkTimestamp(ts string, now time.Time, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid timestamp")
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return errors.New("timestamp outside tolerance")
+	}
+	return nil
+}
+
+func validHexMAC(secret, sigHex string, data []byte) bool {
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
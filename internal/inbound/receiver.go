@@ -0,0 +1,132 @@
+// Package inbound receives webhooks from third-party integrations (GitHub,
+// Stripe, ...), verifying each provider's signature scheme, rejecting
+// replays and storing payloads for debugging.
+package inbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+var (
+	// ErrUnknownProvider is returned for providers that were never registered.
+	ErrUnknownProvider = errors.New("unknown webhook provider")
+	// ErrInvalidSignature is returned when a delivery fails verification.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Verifier authenticates a delivery for one provider and extracts its
+// delivery id and event type.
+type Verifier interface {
+	Verify(header http.Header, body []byte, now time.Time) (deliveryID, event string, err error)
+}
+
+// Handler processes a verified, first-seen delivery.
+type Handler func(ctx context.Context, d db.WebhookDelivery) error
+
+// Recorder persists deliveries; duplicate reports an already seen delivery id.
+type Recorder interface {
+	RecordWebhookDelivery(ctx context.Context, d db.WebhookDelivery) (duplicate bool, err error)
+}
+
+type provider struct {
+	verifier Verifier
+	handler  Handler
+}
+
+// Receiver dispatches inbound deliveries to registered providers.
+type Receiver struct {
+	recorder Recorder
+
+	mu        sync.Mutex
+	providers map[string]provider
+	// seen is the replay window used when no Recorder is available.
+	seen map[string]time.Time
+}
+
+// replayWindow is how long delivery ids are remembered in memory.
+const replayWindow = 24 * time.Hour
+
+// NewReceiver returns a Receiver. recorder may be nil, in which case replay
+// protection is in-memory only and payloads are not stored.
+func NewReceiver(recorder Recorder) *Receiver {
+	return &Receiver{
+		recorder:  recorder,
+		providers: make(map[string]provider),
+		seen:      make(map[string]time.Time),
+	}
+}
+
+// Register adds a provider. handler may be nil to only verify and store.
+func (r *Receiver) Register(name string, v Verifier, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[name] = provider{verifier: v, handler: h}
+}
+
+// Receive verifies and records a delivery, then runs the provider handler
+// unless the delivery is a replay. duplicate is true for replays, which
+// callers should acknowledge so providers stop retrying.
+func (r *Receiver) Receive(ctx context.Context, name string, header http.Header, body []byte) (duplicate bool, err error) {
+	r.mu.Lock()
+	p, ok := r.providers[name]
+	r.mu.Unlock()
+	if !ok {
+		return false, ErrUnknownProvider
+	}
+
+	now := time.Now().UTC()
+	id, event, err := p.verifier.Verify(header, body, now)
+	if err != nil {
+		slog.WarnContext(ctx, "inbound.rejected", "provider", name, "error", err)
+		return false, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	d := db.WebhookDelivery{
+		Provider:   name,
+		DeliveryID: id,
+		Event:      event,
+		Payload:    body,
+		ReceivedAt: now,
+	}
+	if r.recorder != nil {
+		duplicate, err = r.recorder.RecordWebhookDelivery(ctx, d)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		duplicate = r.markSeen(name+"/"+id, now)
+	}
+	if duplicate {
+		slog.InfoContext(ctx, "inbound.replay", "provider", name, "delivery_id", id)
+		return true, nil
+	}
+
+	slog.InfoContext(ctx, "inbound.received", "provider", name, "delivery_id", id, "event", event)
+	if p.handler != nil {
+		return false, p.handler(ctx, d)
+	}
+	return false, nil
+}
+
+func (r *Receiver) markSeen(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, at := range r.seen {
+		if now.Sub(at) > replayWindow {
+			delete(r.seen, k)
+		}
+	}
+	if _, ok := r.seen[key]; ok {
+		return true
+	}
+	r.seen[key] = now
+	return false
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type ctxKey struct{}
+
+// With returns a copy of ctx carrying extra log attributes (slog key-value
+// pairs or slog.Attr values). ContextHandler adds them to every record logged
+// with that context, so request-scoped fields such as request_id, user_id and
+// tenant_id are attached once by middleware rather than at each call site.
+func With(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	prev := attrsFrom(ctx)
+	attrs := make([]slog.Attr, len(prev), len(prev)+len(args))
+	copy(attrs, prev)
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs
+}
+
+// ContextHandler adds the attributes stored by With to each record. Only the
+// *Context logging functions pass a context through.
+type ContextHandler struct {
+	next slog.Handler
+}
+
+// NewContextHandler wraps next.
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{next: next}
+}
+
+// Enabled implements slog.Handler.
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *ContextHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if attrs := attrsFrom(ctx); len(attrs) > 0 {
+		rec = rec.Clone()
+		rec.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, rec)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{next: h.next.WithGroup(name)}
+}
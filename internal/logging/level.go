@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ParseLevel reads one of the level names debug, info, warn and error.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q; want debug, info, warn or error", name)
+}
+
+// ToggleDebug switches lv to debug, or back to base when it is at debug
+// already (to info when base is debug too), and returns the new level.
+func ToggleDebug(lv *slog.LevelVar, base slog.Level) slog.Level {
+	next := slog.LevelDebug
+	if lv.Level() == slog.LevelDebug {
+		next = base
+		if next == slog.LevelDebug {
+			next = slog.LevelInfo
+		}
+	}
+	lv.Set(next)
+	return next
+}
@@ -0,0 +1,146 @@
+// Package logging provides slog handlers shared by the server's loggers.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces sensitive values in log output.
+const Redacted = "[REDACTED]"
+
+// defaultKeys are attribute keys whose values are always redacted. A key
+// matches when it contains one of these, case-insensitively.
+var defaultKeys = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "dsn", "api_key", "apikey"}
+
+type rule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// defaultRules scrub secrets embedded in free text such as error messages.
+// DSNs come first so their user:password@host part is not mistaken for an email.
+var defaultRules = []rule{
+	{regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]*:)[^@\s]+@`), "${1}" + Redacted + "@"},
+	{regexp.MustCompile(`(?i)\b(password|passwd|pwd)=[^\s&;]+`), "${1}=" + Redacted},
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`), "${1} " + Redacted},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), Redacted},
+}
+
+// Redactor decides what to hide from log records.
+type Redactor struct {
+	keys  []string
+	rules []rule
+}
+
+// NewRedactor returns a Redactor with the default keys and patterns plus the
+// given extra keys and regular expressions, whose matches are replaced whole.
+func NewRedactor(extraKeys, extraPatterns []string) (*Redactor, error) {
+	r := &Redactor{keys: append([]string{}, defaultKeys...), rules: append([]rule{}, defaultRules...)}
+	for _, k := range extraKeys {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			r.keys = append(r.keys, k)
+		}
+	}
+	for _, p := range extraPatterns {
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", p, err)
+		}
+		r.rules = append(r.rules, rule{re: re, repl: Redacted})
+	}
+	return r, nil
+}
+
+// String scrubs secrets from free text.
+func (r *Redactor) String(s string) string {
+	for _, rl := range r.rules {
+		s = rl.re.ReplaceAllString(s, rl.repl)
+	}
+	return s
+}
+
+func (r *Redactor) sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Attr returns a redacted copy of a.
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	if r.sensitiveKey(a.Key) && v.Kind() != slog.KindGroup {
+		return slog.String(a.Key, Redacted)
+	}
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.String(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		out := make([]any, len(attrs))
+		for i, ga := range attrs {
+			out[i] = r.Attr(ga)
+		}
+		return slog.Group(a.Key, out...)
+	case slog.KindAny:
+		// Errors and Stringers are flattened to text so embedded DSNs and
+		// tokens can be scrubbed; other values are left to the handler.
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.String(x.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.String(x.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// RedactingHandler scrubs every record before passing it to the next handler.
+type RedactingHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+// NewRedactingHandler wraps next.
+func NewRedactingHandler(next slog.Handler, r *Redactor) *RedactingHandler {
+	return &RedactingHandler{next: next, redactor: r}
+}
+
+// Enabled implements slog.Handler.
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *RedactingHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.redactor.String(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactor.Attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		scrubbed[i] = h.redactor.Attr(a)
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(scrubbed), redactor: h.redactor}
+}
+
+// WithGroup implements slog.Handler.
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// account logs as a group, the way domain types implement slog.LogValuer.
+type account struct{ name, apiKey string }
+
+func (a account) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", a.name), slog.String("api_key", a.apiKey))
+}
+
+func TestRedactingHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     string // as in LOG_REDACT_KEYS
+		patterns string // as in LOG_REDACT_PATTERNS
+		log      func(l *slog.Logger)
+		want     []string
+		hidden   []string
+	}{
+		{
+			name:   "default keys",
+			log:    func(l *slog.Logger) { l.Info("login", "user", "ada", "Password", "hunter2", "session_token", "tok-1") },
+			want:   []string{`"user":"ada"`, `"Password":"[REDACTED]"`, `"session_token":"[REDACTED]"`},
+			hidden: []string{"hunter2", "tok-1"},
+		},
+		{
+			name: "default patterns",
+			log: func(l *slog.Logger) {
+				l.Info("mail ada@example.com", "err", errors.New("dial postgres://app:s3cret@db:5432/todo"))
+			},
+			want:   []string{`"msg":"mail [REDACTED]"`, `postgres://app:[REDACTED]@db:5432/todo`},
+			hidden: []string{"ada@example.com", "s3cret"},
+		},
+		{
+			name:   "extra keys",
+			keys:   " SSN ,,employee_number",
+			log:    func(l *slog.Logger) { l.Info("hr", "user_ssn", "123", "Employee_Number", "E77", "team", "core") },
+			want:   []string{`"user_ssn":"[REDACTED]"`, `"Employee_Number":"[REDACTED]"`, `"team":"core"`},
+			hidden: []string{"123", "E77"},
+		},
+		{
+			name:     "extra patterns",
+			patterns: `\b\d{3}-\d{2}-\d{4}\b;;acct_[a-z0-9]+`,
+			log:      func(l *slog.Logger) { l.Info("ssn 123-45-6789 on file", "note", "see acct_9f3k for details") },
+			want:     []string{`"msg":"ssn [REDACTED] on file"`, `"note":"see [REDACTED] for details"`},
+			hidden:   []string{"123-45-6789", "acct_9f3k"},
+		},
+		{
+			name:     "nested attrs",
+			keys:     "pin",
+			patterns: `card-\d+`,
+			log: func(l *slog.Logger) {
+				l.Info("paid", slog.Group("payment",
+					slog.String("method", "card-4242"),
+					slog.Group("auth", slog.String("pin", "0000"), slog.String("cookie", "c=1"), slog.Int("attempt", 2)),
+				))
+			},
+			want:   []string{`"payment":{"method":"[REDACTED]","auth":{"pin":"[REDACTED]","cookie":"[REDACTED]","attempt":2}}`},
+			hidden: []string{"4242", "0000", "c=1"},
+		},
+		{
+			name:   "log valuers",
+			log:    func(l *slog.Logger) { l.Info("synced", "account", account{name: "ada", apiKey: "k-123"}) },
+			want:   []string{`"account":{"name":"ada","api_key":"[REDACTED]"}`},
+			hidden: []string{"k-123"},
+		},
+		{
+			name: "handler groups and attrs",
+			keys: "tenant_ref",
+			log: func(l *slog.Logger) {
+				l.With("authorization", "Bearer abc.def").WithGroup("req").With("tenant_ref", "t-9").Info("served", "path", "/api/todos?password=pw1")
+			},
+			want:   []string{`"authorization":"[REDACTED]"`, `"req":{"tenant_ref":"[REDACTED]","path":"/api/todos?password=[REDACTED]"}`},
+			hidden: []string{"abc.def", "t-9", "pw1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRedactor(strings.Split(tt.keys, ","), strings.Split(tt.patterns, ";"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			tt.log(slog.New(NewRedactingHandler(slog.NewJSONHandler(&buf, nil), r)))
+			out := buf.String()
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("output lacks %s:\n%s", w, out)
+				}
+			}
+			for _, h := range tt.hidden {
+				if strings.Contains(out, h) {
+					t.Errorf("output leaks %q:\n%s", h, out)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRedactorRejectsInvalidPatterns(t *testing.T) {
+	for _, patterns := range [][]string{{"("}, {`ok-\d+`, "[a-"}, {`(?P<x`}} {
+		if _, err := NewRedactor(nil, patterns); err == nil || !strings.Contains(err.Error(), "redaction pattern") {
+			t.Errorf("patterns %q: error %v", patterns, err)
+		}
+	}
+}
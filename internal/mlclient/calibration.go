@@ -0,0 +1,153 @@
+package mlclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Calibration methods.
+const (
+	CalibrationIdentity = "identity"
+	CalibrationMinMax   = "minmax"
+	CalibrationIsotonic = "isotonic"
+)
+
+// Calibration maps a model's raw scores onto the common [0, 1] scale, so
+// scores stored under different model versions stay comparable. The ML
+// service publishes the parameters for its current model.
+type Calibration struct {
+	ModelVersion string `json:"model_version"`
+	Method       string `json:"method"`
+	// Min and Max are the raw scores mapped to 0 and 1 (minmax).
+	Min float64 `json:"min,omitempty"`
+	Max float64 `json:"max,omitempty"`
+	// X and Y are the breakpoints of the fitted step function (isotonic):
+	// raw scores ascending and their calibrated values, non-decreasing.
+	// Scores in between are interpolated linearly.
+	X []float64 `json:"x,omitempty"`
+	Y []float64 `json:"y,omitempty"`
+}
+
+// Apply calibrates a raw score.
+func (c Calibration) Apply(raw float64) float64 {
+	v := raw
+	switch c.Method {
+	case CalibrationMinMax:
+		v = (raw - c.Min) / (c.Max - c.Min)
+	case CalibrationIsotonic:
+		i, _ := slices.BinarySearch(c.X, raw)
+		switch {
+		case i == 0:
+			v = c.Y[0]
+		case i == len(c.X):
+			v = c.Y[len(c.Y)-1]
+		default:
+			x0, x1, y0, y1 := c.X[i-1], c.X[i], c.Y[i-1], c.Y[i]
+			v = y0 + (y1-y0)*(raw-x0)/(x1-x0)
+		}
+	}
+	return math.Max(0, math.Min(1, v))
+}
+
+func (c Calibration) validate() error {
+	switch c.Method {
+	case CalibrationIdentity:
+	case CalibrationMinMax:
+		if !(c.Max > c.Min) {
+			return errors.New("minmax calibration needs max > min")
+		}
+	case CalibrationIsotonic:
+		if len(c.X) == 0 || len(c.X) != len(c.Y) {
+			return errors.New("isotonic calibration needs as many x as y, at least one")
+		}
+		for i := 1; i < len(c.X); i++ {
+			if c.X[i] <= c.X[i-1] || c.Y[i] < c.Y[i-1] {
+				return errors.New("isotonic calibration needs x ascending and y non-decreasing")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown calibration method %q", c.Method)
+	}
+	return nil
+}
+
+// identity leaves scores as the model returns them.
+var identity = Calibration{Method: CalibrationIdentity}
+
+// WithCalibration applies the service's calibration to every score. It is
+// the identity until RefreshCalibration first succeeds.
+func WithCalibration() Option {
+	return func(c *Client) {
+		c.calibration.Store(&identity)
+	}
+}
+
+// Calibration returns the calibration applied to scores.
+func (c *Client) Calibration() Calibration {
+	if c == nil {
+		return identity
+	}
+	if cal := c.calibration.Load(); cal != nil {
+		return *cal
+	}
+	return identity
+}
+
+// RefreshCalibration fetches the service's calibration, reporting whether
+// it changed. A new calibration drops the cached scores, which were
+// calibrated with the old one. Without WithCalibration it does nothing.
+func (c *Client) RefreshCalibration(ctx context.Context) (changed bool, err error) {
+	if c == nil || c.baseURL == "" {
+		return false, errors.New("ml client disabled")
+	}
+	current := c.calibration.Load()
+	if current == nil {
+		return false, nil
+	}
+	ctx, done := observe(ctx, "calibration")
+	defer func() { done(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/calibration", nil)
+	if err != nil {
+		return false, fmt.Errorf("build request: %w", err)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("call ml service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		return false, &statusError{code: resp.StatusCode, body: string(data)}
+	}
+	var cal Calibration
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&cal); err != nil {
+		return false, fmt.Errorf("decode calibration: %w", err)
+	}
+	if err := cal.validate(); err != nil {
+		return false, err
+	}
+	if sameCalibration(*current, cal) {
+		return false, nil
+	}
+	c.calibration.Store(&cal)
+	if c.cache != nil {
+		c.cache.clear()
+	}
+	return true, nil
+}
+
+func sameCalibration(a, b Calibration) bool {
+	return a.ModelVersion == b.ModelVersion && a.Method == b.Method && a.Min == b.Min && a.Max == b.Max &&
+		slices.Equal(a.X, b.X) && slices.Equal(a.Y, b.Y)
+}
@@ -9,23 +9,45 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"todoapp/internal/stats"
+	"todoapp/internal/telemetry"
 )
 
 // Client calls the Python ML scoring service.
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+
+	retries int
+	backoff time.Duration
+	breaker *breaker
+	cache   *scoreCache
+	hedger  *hedger
+	// calibration is nil without WithCalibration.
+	calibration atomic.Pointer[Calibration]
 }
 
-// NewClient returns a configured ML client. Timeout applies per request.
-func NewClient(baseURL string, timeout time.Duration) *Client {
-	return &Client{
+// NewClient returns a configured ML client. Timeout applies per request,
+// and so to each retry separately.
+func NewClient(baseURL string, timeout time.Duration, opts ...Option) *Client {
+	c := &Client{
 		baseURL: strings.TrimRight(baseURL, "/"),
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // TodoPayload mirrors the ML service schema (snake_case fields).
@@ -35,6 +57,23 @@ type TodoPayload struct {
 	Tags            []string   `json:"tags"`
 	DurationMinutes int        `json:"duration_minutes"`
 	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	// User describes the todo's owner, when the server sends features.
+	User *UserFeatures `json:"user_features,omitempty"`
+}
+
+// UserFeatures are aggregates over the owner's todos.
+type UserFeatures struct {
+	CompletionRate float64 `json:"completion_rate"`
+	OpenTodos      int64   `json:"open_todos"`
+	// AvgDurationByTag covers the todo's own tags the owner has timed
+	// before.
+	AvgDurationByTag map[string]float64 `json:"avg_duration_by_tag,omitempty"`
+	// DurationCorrection is what the owner's estimates should be
+	// multiplied by, learnt from the time tracked on their todos;
+	// DurationCorrectionByTag covers the todo's own tags. Both are absent
+	// until enough todos have been tracked.
+	DurationCorrection      float64            `json:"duration_correction,omitempty"`
+	DurationCorrectionByTag map[string]float64 `json:"duration_correction_by_tag,omitempty"`
 }
 
 type scoreRequest struct {
@@ -47,40 +86,198 @@ type scoreResponse struct {
 	} `json:"results"`
 }
 
+// Warm calls the service's health endpoint, leaving a keep-alive connection
+// in the pool for the first scoring request.
+func (c *Client) Warm(ctx context.Context) (err error) {
+	if c == nil || c.baseURL == "" {
+		return errors.New("ml client disabled")
+	}
+	ctx, done := observe(ctx, "health")
+	defer func() { done(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/health", nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("call ml service: %w", err)
+	}
+	defer resp.Body.Close()
+	// The connection is only reused once the body has been read to EOF.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ml service health: status=%d", resp.StatusCode)
+	}
+	return nil
+}
+
 // Score sends a single todo to the ML service and returns its priority score.
 func (c *Client) Score(ctx context.Context, todo TodoPayload) (float64, error) {
+	scores, err := c.ScoreBatch(ctx, []TodoPayload{todo})
+	if err != nil {
+		return 0, err
+	}
+	return scores[0], nil
+}
+
+// ScoreBatch scores several todos in one request, returning their priority
+// scores in the same order. Cached scores are reused and only the rest are
+// sent; while the circuit is open it fails with ErrCircuitOpen.
+func (c *Client) ScoreBatch(ctx context.Context, todos []TodoPayload) ([]float64, error) {
 	if c == nil || c.baseURL == "" {
-		return 0, errors.New("ml client disabled")
+		return nil, errors.New("ml client disabled")
 	}
+	if len(todos) == 0 {
+		return []float64{}, nil
+	}
+	scores := make([]float64, len(todos))
+	var keys [][32]byte
+	var missing []int
+	if c.cache != nil {
+		now := time.Now()
+		keys = make([][32]byte, len(todos))
+		for i, t := range todos {
+			keys[i] = cacheKey(t)
+			if score, ok := c.cache.get(keys[i], now); ok {
+				scores[i] = score
+				telemetry.MLCacheLookups.WithLabelValues("hit").Inc()
+				stats.MLCacheHits.Inc()
+				continue
+			}
+			telemetry.MLCacheLookups.WithLabelValues("miss").Inc()
+			stats.MLCacheMisses.Inc()
+			missing = append(missing, i)
+		}
+		if len(missing) == 0 {
+			return scores, nil
+		}
+	}
+
+	batch := todos
+	if missing != nil {
+		batch = make([]TodoPayload, len(missing))
+		for j, i := range missing {
+			batch[j] = todos[i]
+		}
+	}
+	fresh, err := c.scoreWithRetry(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if cal := c.calibration.Load(); cal != nil {
+		for i, raw := range fresh {
+			fresh[i] = cal.Apply(raw)
+		}
+	}
+	if missing == nil {
+		copy(scores, fresh)
+	} else {
+		for j, i := range missing {
+			scores[i] = fresh[j]
+		}
+	}
+	if c.cache != nil {
+		now := time.Now()
+		for i := range todos {
+			c.cache.put(keys[i], scores[i], now)
+		}
+	}
+	return scores, nil
+}
+
+// scoreWithRetry sends todos through the circuit breaker, retrying
+// transient failures.
+func (c *Client) scoreWithRetry(ctx context.Context, todos []TodoPayload) ([]float64, error) {
+	for attempt := 0; ; attempt++ {
+		if c.breaker != nil && !c.breaker.allow(time.Now()) {
+			telemetry.MLRequests.WithLabelValues("score", "short_circuit").Inc()
+			return nil, ErrCircuitOpen
+		}
+		scores, err := c.scoreHedged(ctx, todos)
+		if c.breaker != nil {
+			c.breaker.record(err, time.Now())
+		}
+		if err == nil || attempt >= c.retries || !transient(err) || ctx.Err() != nil {
+			return scores, err
+		}
+		telemetry.MLRetries.Inc()
+		if serr := sleep(ctx, c.retryDelay(attempt+1)); serr != nil {
+			return nil, err
+		}
+	}
+}
+
+// score makes one call to the service.
+func (c *Client) score(ctx context.Context, todos []TodoPayload) (_ []float64, err error) {
+	ctx, done := observe(ctx, "score", attribute.Int("ml.batch_size", len(todos)))
+	defer func() { done(err) }()
 
-	body, err := json.Marshal(scoreRequest{Todos: []TodoPayload{todo}})
+	body, err := json.Marshal(scoreRequest{Todos: todos})
 	if err != nil {
-		return 0, fmt.Errorf("encode request: %w", err)
+		return nil, fmt.Errorf("encode request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/score", bytes.NewReader(body))
 	if err != nil {
-		return 0, fmt.Errorf("build request: %w", err)
+		return nil, fmt.Errorf("build request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("call ml service: %w", err)
+		return nil, fmt.Errorf("call ml service: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		data, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
-		return 0, fmt.Errorf("ml service error: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(data)))
+		return nil, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(data))}
 	}
 
 	var sr scoreResponse
 	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
-		return 0, fmt.Errorf("decode response: %w", err)
+		return nil, fmt.Errorf("decode response: %w", err)
 	}
-	if len(sr.Results) == 0 {
-		return 0, errors.New("ml response missing results")
+	if len(sr.Results) != len(todos) {
+		return nil, fmt.Errorf("ml response has %d results for %d todos", len(sr.Results), len(todos))
+	}
+	scores := make([]float64, len(sr.Results))
+	for i, r := range sr.Results {
+		scores[i] = r.PriorityScore
+	}
+	return scores, nil
+}
+
+// statusError is a non-200 answer from the service.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("ml service error: status=%d body=%s", e.code, e.body)
+}
+
+// observe starts a span and a timer for one call to the service; the
+// returned function records its outcome.
+func observe(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := telemetry.Tracer().Start(ctx, "ml."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		outcome := telemetry.Outcome(err)
+		if errors.Is(err, context.Canceled) {
+			// E.g. the loser of a hedged call; not the service's failure.
+			outcome = "cancelled"
+		}
+		telemetry.MLRequests.WithLabelValues(op, outcome).Inc()
+		telemetry.MLDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
 	}
-	return sr.Results[0].PriorityScore, nil
 }
@@ -0,0 +1,161 @@
+package mlclient
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"todoapp/internal/telemetry"
+)
+
+const (
+	// hedgeSamples is how many recent latencies the hedge delay is the p95
+	// of; no call is hedged before hedgeMinSamples were seen.
+	hedgeSamples    = 200
+	hedgeMinSamples = 20
+	// hedgeMaxTokens caps the hedges saved up while traffic was fast, so a
+	// slow spell cannot double the load for long.
+	hedgeMaxTokens = 10
+)
+
+type latencySensitiveKey struct{}
+
+// LatencySensitive marks ctx for calls a user waits on. With WithHedging,
+// only those are hedged; batch work is not worth the extra load.
+func LatencySensitive(ctx context.Context) context.Context {
+	return context.WithValue(ctx, latencySensitiveKey{}, true)
+}
+
+func latencySensitive(ctx context.Context) bool {
+	v, _ := ctx.Value(latencySensitiveKey{}).(bool)
+	return v
+}
+
+// WithHedging sends a second, hedged request for a latency-sensitive call
+// still unanswered after the p95 latency of recent ones, and takes the
+// first response, cancelling the other. budget bounds hedges to that share
+// of calls (0.05: one in twenty); 0 disables hedging.
+func WithHedging(budget float64) Option {
+	return func(c *Client) {
+		if budget > 0 {
+			c.hedger = &hedger{budget: min(budget, 1)}
+		}
+	}
+}
+
+// hedger tracks recent latencies and the hedging budget: every call earns
+// budget tokens, every hedge spends one.
+type hedger struct {
+	budget float64
+
+	mu        sync.Mutex
+	latencies []time.Duration // ring of the last hedgeSamples
+	next      int
+	tokens    float64
+}
+
+// delay returns the p95 of recent latencies, false until enough were seen.
+func (h *hedger) delay() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeMinSamples {
+		return 0, false
+	}
+	sorted := slices.Clone(h.latencies)
+	slices.Sort(sorted)
+	return sorted[len(sorted)*95/100], true
+}
+
+func (h *hedger) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, d)
+		return
+	}
+	h.latencies[h.next] = d
+	h.next = (h.next + 1) % hedgeSamples
+}
+
+func (h *hedger) earn() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens = min(h.tokens+h.budget, hedgeMaxTokens)
+}
+
+func (h *hedger) spend() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+// scoreHedged makes one call, hedged when the client and ctx allow it. A
+// failed response waits for the other one, if any.
+func (c *Client) scoreHedged(ctx context.Context, todos []TodoPayload) ([]float64, error) {
+	if c.hedger == nil || !latencySensitive(ctx) {
+		return c.score(ctx, todos)
+	}
+	c.hedger.earn()
+	delay, ok := c.hedger.delay()
+	if deadline, has := ctx.Deadline(); ok && has && time.Until(deadline) <= delay {
+		// The hedge could not answer in time.
+		ok = false
+	}
+	if !ok {
+		start := time.Now()
+		scores, err := c.score(ctx, todos)
+		if err == nil {
+			c.hedger.observe(time.Since(start))
+		}
+		return scores, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		scores []float64
+		err    error
+		hedge  bool
+	}
+	results := make(chan result, 2)
+	start := time.Now()
+	call := func(hedge bool) {
+		scores, err := c.score(ctx, todos)
+		results <- result{scores, err, hedge}
+	}
+	go call(false)
+	pending := 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		if r.err == nil {
+			c.hedger.observe(time.Since(start))
+		}
+		return r.scores, r.err
+	case <-timer.C:
+		if c.hedger.spend() {
+			telemetry.MLHedges.WithLabelValues("sent").Inc()
+			go call(true)
+			pending++
+		} else {
+			telemetry.MLHedges.WithLabelValues("over_budget").Inc()
+		}
+	}
+	var r result
+	for ; pending > 0; pending-- {
+		if r = <-results; r.err == nil {
+			c.hedger.observe(time.Since(start))
+			if r.hedge {
+				telemetry.MLHedges.WithLabelValues("won").Inc()
+			}
+			return r.scores, nil
+		}
+	}
+	return nil, r.err
+}
@@ -0,0 +1,248 @@
+package mlclient
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"todoapp/internal/telemetry"
+)
+
+// ErrCircuitOpen is returned without calling the service while the circuit
+// breaker is open. Callers fall back as for any other failure.
+var ErrCircuitOpen = errors.New("ml service circuit open")
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithRetry retries a call failing with a transient error (network error,
+// timeout, 429 or 5xx) up to retries more times, waiting backoff, then
+// twice as long each time, with jitter. The caller's context bounds the
+// whole sequence.
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = max(retries, 0)
+		c.backoff = backoff
+	}
+}
+
+// WithBreaker opens the circuit after failures consecutive transient
+// failures: calls then fail at once with ErrCircuitOpen for cooldown, after
+// which one trial call decides whether it closes again. onChange, if not
+// nil, is called on every state change; it runs under the breaker's lock
+// and must not call the client.
+func WithBreaker(failures int, cooldown time.Duration, onChange func(BreakerState)) Option {
+	return func(c *Client) {
+		if failures > 0 {
+			c.breaker = &breaker{threshold: failures, cooldown: cooldown, onChange: onChange}
+		}
+	}
+}
+
+// WithCache keeps up to size scores for ttl, keyed on the scored payload,
+// so a todo saved again unchanged is not sent to the service twice.
+func WithCache(size int, ttl time.Duration) Option {
+	return func(c *Client) {
+		if size > 0 && ttl > 0 {
+			c.cache = &scoreCache{size: size, ttl: ttl, entries: make(map[[32]byte]*list.Element), order: list.New()}
+		}
+	}
+}
+
+// transient reports whether err may succeed if retried, and counts against
+// the service's health. Other errors (4xx, bad responses) mean the service
+// answered and retrying would not help.
+func transient(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code == 429 || se.code >= 500
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// sleep waits d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryDelay is the wait before retry n (from 1), with ±25% jitter so
+// replicas do not retry in step.
+func (c *Client) retryDelay(n int) time.Duration {
+	d := c.backoff << (n - 1)
+	return d - d/4 + time.Duration(rand.Int64N(int64(d/2)+1))
+}
+
+// BreakerState is the state of the circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets calls through.
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets a single trial call through.
+	BreakerHalfOpen
+	// BreakerOpen fails calls without trying.
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half_open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	onChange  func(BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a call may go ahead.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setLocked(BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// record notes the outcome of an allowed call. Transient errors count as
+// failures; a call the caller cancelled says nothing about the service.
+func (b *breaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err == nil || !transient(err):
+		b.failures = 0
+		b.setLocked(BreakerClosed)
+	default:
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+			b.openedAt = now
+			b.setLocked(BreakerOpen)
+		}
+	}
+}
+
+func (b *breaker) current() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *breaker) setLocked(s BreakerState) {
+	if s == b.state {
+		return
+	}
+	b.state = s
+	telemetry.MLBreakerState.Set(float64(s))
+	if b.onChange != nil {
+		b.onChange(s)
+	}
+}
+
+// BreakerState reports the circuit breaker's state; it is always closed
+// without WithBreaker.
+func (c *Client) BreakerState() BreakerState {
+	if c == nil || c.breaker == nil {
+		return BreakerClosed
+	}
+	return c.breaker.current()
+}
+
+// scoreCache is a fixed-size cache of scores that expire after ttl,
+// evicting the least recently stored first.
+type scoreCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[[32]byte]*list.Element
+	order   *list.List // of *cacheEntry, most recent first
+}
+
+type cacheEntry struct {
+	key     [32]byte
+	score   float64
+	expires time.Time
+}
+
+func cacheKey(p TodoPayload) [32]byte {
+	data, _ := json.Marshal(p)
+	return sha256.Sum256(data)
+}
+
+func (c *scoreCache) get(key [32]byte, now time.Time) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return 0, false
+	}
+	e := el.Value.(*cacheEntry)
+	if now.After(e.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return 0, false
+	}
+	return e.score, true
+}
+
+func (c *scoreCache) put(key [32]byte, score float64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, score: score, expires: now.Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// clear drops every score.
+func (c *scoreCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.order.Init()
+}
@@ -0,0 +1,60 @@
+// Package rolling counts request outcomes over a rolling duration, for the
+// in-process alerting, SLO and health checks.
+package rolling
+
+import "time"
+
+// Counts are the outcomes recorded in a window: every event, and those that
+// failed or were slow.
+type Counts struct {
+	Total, Failed, Slow int
+}
+
+// Window is a ring of time buckets covering a rolling duration. Buckets
+// older than the window are dropped whole, so the window spans between d
+// and d plus one bucket. A Window is not safe for concurrent use.
+type Window struct {
+	width   time.Duration
+	buckets []bucket
+}
+
+type bucket struct {
+	slot int64
+	Counts
+}
+
+// New returns a window covering d with the given number of buckets, each at
+// least a second wide.
+func New(d time.Duration, buckets int) *Window {
+	return &Window{width: max(d/time.Duration(buckets), time.Second), buckets: make([]bucket, buckets)}
+}
+
+// Add records one outcome at now.
+func (w *Window) Add(now time.Time, failed, slow bool) {
+	slot := now.UnixNano() / int64(w.width)
+	b := &w.buckets[slot%int64(len(w.buckets))]
+	if b.slot != slot {
+		*b = bucket{slot: slot}
+	}
+	b.Total++
+	if failed {
+		b.Failed++
+	}
+	if slow {
+		b.Slow++
+	}
+}
+
+// Sum returns the outcomes recorded in the window ending at now.
+func (w *Window) Sum(now time.Time) Counts {
+	var c Counts
+	current := now.UnixNano() / int64(w.width)
+	for _, b := range w.buckets {
+		if current-b.slot < int64(len(w.buckets)) {
+			c.Total += b.Total
+			c.Failed += b.Failed
+			c.Slow += b.Slow
+		}
+	}
+	return c
+}
@@ -0,0 +1,44 @@
+package rolling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	w := New(time.Minute, 6) // 10s buckets
+	w.Add(start, false, false)
+	w.Add(start.Add(5*time.Second), true, false)
+	w.Add(start.Add(25*time.Second), false, true)
+
+	tests := []struct {
+		at   time.Duration
+		want Counts
+	}{
+		{30 * time.Second, Counts{Total: 3, Failed: 1, Slow: 1}},
+		{59 * time.Second, Counts{Total: 3, Failed: 1, Slow: 1}},
+		{60 * time.Second, Counts{Total: 1, Slow: 1}},
+		{80 * time.Second, Counts{}},
+	}
+	for _, tt := range tests {
+		if got := w.Sum(start.Add(tt.at)); got != tt.want {
+			t.Errorf("at +%s: %+v, want %+v", tt.at, got, tt.want)
+		}
+	}
+
+	// A bucket is reset when its slot comes round again.
+	w.Add(start.Add(65*time.Second), true, false)
+	if got, want := w.Sum(start.Add(65*time.Second)), (Counts{Total: 2, Failed: 1, Slow: 1}); got != want {
+		t.Errorf("after wrapping: %+v, want %+v", got, want)
+	}
+}
+
+func TestWindowMinimumWidth(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	w := New(10*time.Second, 60) // buckets widen to a second, so 60s
+	w.Add(start, true, false)
+	if got := w.Sum(start.Add(59 * time.Second)); got.Failed != 1 {
+		t.Errorf("event dropped after 59s: %+v", got)
+	}
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Log writes each reminder to the structured log.
+type Log struct{}
+
+// Notify implements Notifier.
+func (Log) Notify(ctx context.Context, r Reminder) error {
+	slog.InfoContext(ctx, "reminder.due", "todo_id", r.Todo.ID, "owner_id", r.Todo.OwnerID,
+		"title", r.Todo.Title, "due_at", r.Todo.DueAt, "offset_minutes", r.OffsetMinutes)
+	return nil
+}
+
+// Webhook posts each reminder as JSON. The body also carries a "text" field
+// so Slack and Mattermost incoming webhooks display it without a bridge.
+// With Secret set, X-Reminder-Signature holds "sha256=" and the hex
+// HMAC-SHA256 of the body.
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (w Webhook) Notify(ctx context.Context, r Reminder) error {
+	body, err := json.Marshal(struct {
+		Event string `json:"event"`
+		Reminder
+		Text string `json:"text"`
+	}{"todo.reminder", r, r.String()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Reminder-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("reminder webhook: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Multi fans a reminder out to several notifiers, returning their joined
+// errors.
+type Multi []Notifier
+
+// Notify implements Notifier.
+func (m Multi) Notify(ctx context.Context, r Reminder) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+// Package scheduler fires reminders as the due dates of open todos approach,
+// at the offsets each todo carries or the configured defaults.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// Reminder is one reminder of a todo coming due.
+type Reminder struct {
+	Todo db.Todo `json:"todo"`
+	// At is when the reminder was due to fire; OffsetMinutes is how long
+	// before the todo's due date that is.
+	At            time.Time `json:"at"`
+	OffsetMinutes int       `json:"offsetMinutes"`
+}
+
+func (r Reminder) String() string {
+	if r.OffsetMinutes == 0 {
+		return fmt.Sprintf("%q is due now", r.Todo.Title)
+	}
+	return fmt.Sprintf("%q is due in %s", r.Todo.Title, (time.Duration(r.OffsetMinutes) * time.Minute).String())
+}
+
+// Notifier delivers reminders.
+type Notifier interface {
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// NotifierFunc adapts a function to Notifier.
+type NotifierFunc func(ctx context.Context, r Reminder) error
+
+// Notify implements Notifier.
+func (f NotifierFunc) Notify(ctx context.Context, r Reminder) error {
+	return f(ctx, r)
+}
+
+// Scheduler periodically scans open todos for reminders that came due since
+// its previous pass.
+type Scheduler struct {
+	store    db.TodoStreamer
+	notifier Notifier
+	defaults []int
+}
+
+// New returns a Scheduler reading todos from store and reporting through n.
+// defaults are the reminder offsets, in minutes before the due date, of
+// todos that set none of their own.
+func New(store db.TodoStreamer, n Notifier, defaults ...int) *Scheduler {
+	return &Scheduler{store: store, notifier: n, defaults: defaults}
+}
+
+// Run checks for reminders every interval until ctx is cancelled. Each pass
+// covers the time since the last successful one, so a failed pass is caught
+// up by the next; reminders that came due while the process was down are
+// not sent.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	since := time.Now()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := s.fire(runCtx, since, now); err != nil {
+				slog.WarnContext(ctx, "reminder.scan_failed", "error", err)
+			} else {
+				since = now
+			}
+			cancel()
+		}
+	}
+}
+
+// fire notifies every reminder falling in (since, now].
+func (s *Scheduler) fire(ctx context.Context, since, now time.Time) error {
+	// A reminder fires at most MaxReminderOffset ahead of the due date and
+	// never after it, which bounds the due dates worth reading.
+	open := false
+	filter := db.TodoFilter{
+		Completed: &open,
+		DueAfter:  since,
+		DueBefore: now.Add(db.MaxReminderOffset * time.Minute),
+	}
+	// Collect first: notifiers may be slow, and the read stays open while
+	// streaming.
+	var due []Reminder
+	err := s.store.StreamTodos(ctx, filter, func(t db.Todo) error {
+		for _, at := range t.ReminderTimes(s.defaults) {
+			if at.After(since) && !at.After(now) {
+				due = append(due, Reminder{Todo: t, At: at, OffsetMinutes: int(t.DueAt.Sub(at).Minutes())})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range due {
+		if err := s.notifier.Notify(ctx, r); err != nil {
+			slog.WarnContext(ctx, "reminder.notify_failed", "todo_id", r.Todo.ID, "error", err)
+		}
+	}
+	return nil
+}
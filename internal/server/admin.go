@@ -0,0 +1,229 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"todoapp/internal/db"
+	"todoapp/internal/logging"
+	"todoapp/internal/stats"
+	"todoapp/internal/telemetry"
+)
+
+// adminRoutes registers the admin API, guarded by the admin token.
+func (s *Server) adminRoutes(r chi.Router) {
+	r.Use(s.requireAdmin)
+	r.Get("/schema", s.handleAdminSchema)
+	r.Get("/webhooks/inbound", s.handleAdminInboundWebhooks)
+	r.Get("/webhooks/paused", s.handleAdminPausedWebhooks)
+	r.Post("/webhooks/{id}/resume", s.handleAdminResumeWebhook)
+	r.Get("/slo", s.handleAdminSLO)
+	r.Get("/health-score", s.handleAdminHealthScore)
+	r.Get("/jobs/stats", s.handleAdminJobStats)
+	r.Get("/event-counts", s.handleAdminEventCounts)
+	r.Post("/cdn/purge", s.handleAdminPurgeCDN)
+	r.Get("/log-level", s.handleAdminGetLogLevel)
+	r.Put("/log-level", s.handleAdminSetLogLevel)
+	r.Get("/ml/calibration", s.handleAdminCalibration)
+	r.Post("/ml/recalibrate", s.handleAdminRecalibrate)
+	r.Get("/score-distribution", s.handleAdminScoreDistribution)
+	r.Get("/deprecations", s.handleAdminDeprecations)
+	r.Get("/schedules", s.handleAdminSchedules)
+	r.Post("/schedules/{name}/run", s.handleAdminRunSchedule)
+	r.Get("/reports", s.handleAdminReports)
+	r.Post("/reports", s.handleAdminRunReport)
+	r.Put("/lists/{id}/hold", s.handleAdminListHold)
+	r.Delete("/lists/{id}/hold", s.handleAdminListHold)
+	// Admin requests are not scoped to a user, so the export and stats
+	// below span every user of the deployment.
+	r.Get("/export", s.handleExportTodos)
+	r.Get("/stats", s.handleStats)
+	// Product-level metrics for a Prometheus job scraping with the admin
+	// token; unlike /metrics they count every user's todos.
+	r.Handle("/metrics", telemetry.BusinessHandler())
+	r.Get("/retention", s.handleAdminRetention)
+	r.Post("/retention/anonymize", s.handleAdminAnonymize)
+	r.Get("/journal", s.handleAdminJournal)
+	r.Get("/journal/{id}", s.handleAdminJournalEntry)
+	r.Post("/journal/{id}/replay", s.handleAdminReplayJournal)
+	r.Get("/domains", s.handleAdminListDomains)
+	r.Post("/domains", s.handleAdminAddDomain)
+	r.Post("/domains/{name}/verify", s.handleAdminVerifyDomain)
+	r.Delete("/domains/{name}", s.handleAdminDeleteDomain)
+	r.Delete("/users/{id}", s.handleAdminDeleteUser)
+}
+
+// AdminHandler serves operator endpoints for an internal-only port: the admin
+// API and the Go runtime profiles under /debug (pprof, expvar). The profiles
+// are unauthenticated, so this handler must never face the public ingress.
+func (s *Server) AdminHandler() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(logContext)
+	r.Use(instrument)
+	r.Use(middleware.Recoverer)
+	r.Use(requestLogger)
+	r.Use(s.debugQueries)
+	r.Route("/api/admin", s.adminRoutes)
+	r.Route("/admin", s.adminUIRoutes)
+	r.With(s.requireAdmin).Post("/api/todos/rescore", s.handleRescore)
+	if s.metrics {
+		r.Handle("/metrics", telemetry.Handler())
+	}
+	r.Mount("/debug", middleware.Profiler())
+	return r
+}
+
+// requireAdmin rejects requests that do not carry the configured admin bearer token.
+// When no token is configured the admin API is disabled entirely.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			writeError(w, http.StatusNotFound, "admin api disabled")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, http.StatusUnauthorized, "admin token required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleAdminSchema(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := s.store.(db.SchemaReporter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "not supported by storage backend")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	report, err := reporter.SchemaReport(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "admin.schema_failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to inspect schema")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleAdminDeleteUser deletes an account with its todos, lists and
+// history. SCIM deprovisioning only deactivates accounts; this is for
+// erasure requests.
+func (s *Server) handleAdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	deleter, ok := s.store.(db.AccountDeleter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "account deletion not supported by storage backend")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), time.Minute)
+	defer cancel()
+	if err := deleter.DeleteAccount(ctx, id); err != nil {
+		if errors.Is(err, db.ErrUserNotFound) {
+			writeError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		slog.ErrorContext(ctx, "admin.delete_user_failed", "user_id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+	s.lists.clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAdminSLO(w http.ResponseWriter, r *http.Request) {
+	if s.objectives == nil {
+		writeError(w, http.StatusNotFound, "no slo objectives configured")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"objectives": s.objectives.Summary()})
+}
+
+// handleAdminEventCounts reports the daily event totals flushed by
+// internal/stats over the last ?days= days (default 7), and the counts not
+// yet flushed.
+func (s *Server) handleAdminEventCounts(w http.ResponseWriter, r *http.Request) {
+	counter, ok := s.store.(db.EventCounter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "event counts not supported by storage backend")
+		return
+	}
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 366 {
+			writeError(w, http.StatusBadRequest, "days must be between 1 and 366")
+			return
+		}
+		days = n
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	since := time.Now().UTC().AddDate(0, 0, 1-days).Format(time.DateOnly)
+	counts, err := counter.EventCounts(ctx, since)
+	if err != nil {
+		slog.ErrorContext(ctx, "admin.event_counts_failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to list event counts")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"days": counts, "pending": stats.Pending()})
+}
+
+// WithLogLevel lets admins change the level of lv, the process's logger,
+// through /api/admin/log-level.
+func WithLogLevel(lv *slog.LevelVar) Option {
+	return func(s *Server) {
+		s.logLevel = lv
+	}
+}
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func (s *Server) handleAdminGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if s.logLevel == nil {
+		writeError(w, http.StatusNotImplemented, "log level is not adjustable")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"level": strings.ToLower(s.logLevel.Level().String())})
+}
+
+// handleAdminSetLogLevel switches the log level until the next change or
+// restart, which returns to LOG_LEVEL.
+func (s *Server) handleAdminSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if s.logLevel == nil {
+		writeError(w, http.StatusNotImplemented, "log level is not adjustable")
+		return
+	}
+	var req logLevelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	level, err := logging.ParseLevel(req.Level)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	from := s.logLevel.Level()
+	s.logLevel.Set(level)
+	// Logged at warn so that it shows at every level but error.
+	slog.WarnContext(r.Context(), "log.level_changed", "from", from.String(), "to", level.String(), "via", "admin")
+	writeJSON(w, http.StatusOK, map[string]string{"level": strings.ToLower(level.String())})
+}
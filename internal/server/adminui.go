@@ -0,0 +1,288 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+	"todoapp/internal/usage"
+)
+
+// adminUIFiles holds the admin UI's page templates and stylesheet.
+//
+//go:embed adminui
+var adminUIFiles embed.FS
+
+// adminUIPages are the page templates, each parsed with the layout.
+var adminUIPages = func() map[string]*template.Template {
+	pages := map[string]*template.Template{}
+	for _, name := range []string{"login", "overview", "users", "jobs", "webhooks", "migrations"} {
+		pages[name] = template.Must(template.ParseFS(adminUIFiles, "adminui/layout.html", "adminui/"+name+".html"))
+	}
+	return pages
+}()
+
+const (
+	// adminCookie holds an admin UI session: its expiry in Unix seconds, a
+	// dot and an HMAC of both keyed by the admin token. The token itself
+	// never leaves the login form, and rotating it ends every session.
+	adminCookie     = "todo_admin"
+	adminSessionTTL = 12 * time.Hour
+	// adminUIPolicy replaces the frontend's policy on admin pages, which
+	// need nothing but their stylesheet and same-origin forms.
+	adminUIPolicy = "default-src 'none'; style-src 'self'; form-action 'self'; base-uri 'none'; frame-ancestors 'none'"
+	// adminUsersPerPage is the page size of the users page.
+	adminUsersPerPage = 50
+)
+
+// adminPage is what a page template is rendered with.
+type adminPage struct {
+	Page  string
+	Title string
+	Error string
+	Data  any
+}
+
+// adminUIRoutes registers the server-rendered admin UI at /admin. It offers
+// read-only views of users, jobs, webhooks, features and migrations, and
+// resuming paused webhooks. Logging in takes the admin token.
+func (s *Server) adminUIRoutes(r chi.Router) {
+	r.Use(adminUIHeaders)
+	r.Get("/admin.css", serveAdminCSS)
+	r.Get("/login", s.handleAdminUILoginPage)
+	r.Post("/login", s.handleAdminUILogin)
+	r.Post("/logout", s.handleAdminUILogout)
+	r.Group(func(r chi.Router) {
+		r.Use(s.requireAdminSession)
+		r.Get("/", s.handleAdminUIOverview)
+		r.Get("/users", s.handleAdminUIUsers)
+		r.Get("/jobs", s.handleAdminUIJobs)
+		r.Get("/webhooks", s.handleAdminUIWebhooks)
+		r.Post("/webhooks/{id}/resume", s.handleAdminUIResumeWebhook)
+		r.Get("/migrations", s.handleAdminUIMigrations)
+	})
+}
+
+func adminUIHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", adminUIPolicy)
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func serveAdminCSS(w http.ResponseWriter, r *http.Request) {
+	css, _ := adminUIFiles.ReadFile("adminui/admin.css")
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	_, _ = w.Write(css)
+}
+
+// renderAdminPage renders page, buffering it so a template error becomes a
+// plain 500 rather than half a page.
+func renderAdminPage(w http.ResponseWriter, r *http.Request, status int, page adminPage) {
+	var buf bytes.Buffer
+	if err := adminUIPages[page.Page].ExecuteTemplate(&buf, "layout", page); err != nil {
+		slog.ErrorContext(r.Context(), "admin.render_failed", "page", page.Page, "error", err)
+		http.Error(w, "failed to render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// adminSessionMAC signs an admin UI session expiring at expires.
+func (s *Server) adminSessionMAC(expires string) string {
+	mac := hmac.New(sha256.New, []byte(s.adminToken))
+	mac.Write([]byte("admin-ui." + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// requireAdminSession sends browsers without a valid admin session to the
+// login page. Like the admin API, the UI is off when no token is set.
+func (s *Server) requireAdminSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if c, err := r.Cookie(adminCookie); err == nil {
+			expires, mac, _ := strings.Cut(c.Value, ".")
+			secs, err := strconv.ParseInt(expires, 10, 64)
+			if err == nil && time.Now().Unix() < secs && hmac.Equal([]byte(mac), []byte(s.adminSessionMAC(expires))) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+	})
+}
+
+func (s *Server) handleAdminUILoginPage(w http.ResponseWriter, r *http.Request) {
+	if s.adminToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	renderAdminPage(w, r, http.StatusOK, adminPage{Page: "login", Title: "Admin login"})
+}
+
+// handleAdminUILogin exchanges the admin token for a session cookie. The
+// cookie is SameSite=Strict, so other sites cannot submit the UI's forms.
+func (s *Server) handleAdminUILogin(w http.ResponseWriter, r *http.Request) {
+	if s.adminToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<12)
+	token := r.PostFormValue("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		slog.WarnContext(r.Context(), "admin.login_rejected")
+		renderAdminPage(w, r, http.StatusUnauthorized, adminPage{Page: "login", Title: "Admin login", Error: "Invalid admin token."})
+		return
+	}
+	expires := time.Now().Add(adminSessionTTL)
+	value := strconv.FormatInt(expires.Unix(), 10)
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookie,
+		Value:    value + "." + s.adminSessionMAC(value),
+		Path:     "/admin",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	slog.InfoContext(r.Context(), "admin.session_started")
+	http.Redirect(w, r, "/admin/", http.StatusSeeOther)
+}
+
+func (s *Server) handleAdminUILogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookie,
+		Value:    "",
+		Path:     "/admin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+func (s *Server) handleAdminUIOverview(w http.ResponseWriter, r *http.Request) {
+	renderAdminPage(w, r, http.StatusOK, adminPage{Page: "overview", Title: "Overview", Data: map[string]any{
+		"Version":  usage.Version(),
+		"Storage":  db.Backend(s.store),
+		"Features": s.Features(),
+	}})
+}
+
+func (s *Server) handleAdminUIUsers(w http.ResponseWriter, r *http.Request) {
+	page := adminPage{Page: "users", Title: "Users"}
+	users, ok := s.store.(db.UserProvisioner)
+	if !ok {
+		page.Error = "The storage backend does not list users."
+		renderAdminPage(w, r, http.StatusOK, page)
+		return
+	}
+	n, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	n = max(n, 1)
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, total, err := users.ListUsers(ctx, "", (n-1)*adminUsersPerPage, adminUsersPerPage)
+	if err != nil {
+		slog.ErrorContext(ctx, "admin.users_failed", "error", err)
+		page.Error = "Failed to list users."
+		renderAdminPage(w, r, http.StatusInternalServerError, page)
+		return
+	}
+	data := struct {
+		Users      []db.User
+		Total      int
+		Prev, Next int
+	}{Users: items, Total: total}
+	if n > 1 {
+		data.Prev = n - 1
+	}
+	if n*adminUsersPerPage < total {
+		data.Next = n + 1
+	}
+	page.Data = data
+	renderAdminPage(w, r, http.StatusOK, page)
+}
+
+func (s *Server) handleAdminUIJobs(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	renderAdminPage(w, r, http.StatusOK, adminPage{Page: "jobs", Title: "Background jobs", Data: s.jobStats(ctx)})
+}
+
+func (s *Server) handleAdminUIWebhooks(w http.ResponseWriter, r *http.Request) {
+	page := adminPage{Page: "webhooks", Title: "Webhooks"}
+	webhooks, ok := s.store.(db.WebhookStore)
+	if !ok {
+		page.Error = "The storage backend does not support webhooks."
+		renderAdminPage(w, r, http.StatusOK, page)
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := webhooks.ListWebhooks(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "admin.webhooks_failed", "error", err)
+		page.Error = "Failed to list webhooks."
+		renderAdminPage(w, r, http.StatusInternalServerError, page)
+		return
+	}
+	page.Data = items
+	renderAdminPage(w, r, http.StatusOK, page)
+}
+
+func (s *Server) handleAdminUIResumeWebhook(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.store.(db.WebhookStore)
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if !ok || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if _, err := s.resumeWebhook(ctx, webhooks, id); err != nil && !errors.Is(err, db.ErrWebhookNotFound) {
+		slog.ErrorContext(ctx, "admin.resume_failed", "id", id, "error", err)
+		http.Error(w, "failed to resume webhook", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+func (s *Server) handleAdminUIMigrations(w http.ResponseWriter, r *http.Request) {
+	page := adminPage{Page: "migrations", Title: "Schema migrations"}
+	migrator, ok := s.store.(db.Migrator)
+	if !ok {
+		page.Error = "The storage backend has no versioned schema."
+		renderAdminPage(w, r, http.StatusOK, page)
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	status, err := migrator.MigrationStatus(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "admin.migrations_failed", "error", err)
+		page.Error = "Failed to read the migration status."
+		renderAdminPage(w, r, http.StatusInternalServerError, page)
+		return
+	}
+	page.Data = status
+	renderAdminPage(w, r, http.StatusOK, page)
+}
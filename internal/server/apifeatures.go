@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/telemetry"
+)
+
+// apiFeaturesHeader lets clients opt into experimental response fields, as
+// a comma-separated list of feature names. Responses list the ones applied
+// in the same header; names the server does not know are ignored, so
+// clients can ask for features before every deployment has them.
+const apiFeaturesHeader = "X-Api-Features"
+
+const (
+	// featureLinks adds _links to todos, as the v2 media type does.
+	featureLinks = "links"
+	// featureScoreExplanation adds scoreExplanation to todos.
+	featureScoreExplanation = "score-explanation"
+)
+
+// apiFeatures are the experimental features clients can opt into. They may
+// change or go away without a new API version.
+var apiFeatures = []string{featureLinks, featureScoreExplanation}
+
+type apiFeaturesKey struct{}
+
+// negotiateFeatures records the experimental features a request opts into
+// for writeTodo and writeTodos, and counts requests per feature.
+func negotiateFeatures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", apiFeaturesHeader)
+		header := r.Header.Values(apiFeaturesHeader)
+		if len(header) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var applied []string
+		for _, value := range header {
+			for _, name := range strings.Split(value, ",") {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if name == "" || slices.Contains(applied, name) {
+					continue
+				}
+				if !slices.Contains(apiFeatures, name) {
+					telemetry.APIFeatureRequests.WithLabelValues("unknown").Inc()
+					continue
+				}
+				telemetry.APIFeatureRequests.WithLabelValues(name).Inc()
+				applied = append(applied, name)
+			}
+		}
+		if len(applied) > 0 {
+			w.Header().Set(apiFeaturesHeader, strings.Join(applied, ", "))
+			r = r.WithContext(context.WithValue(r.Context(), apiFeaturesKey{}, applied))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wantsFeature reports whether r opted into the experimental feature name.
+func wantsFeature(r *http.Request, name string) bool {
+	applied, _ := r.Context().Value(apiFeaturesKey{}).([]string)
+	return slices.Contains(applied, name)
+}
+
+// scoreExplanation breaks a todo's priority down into what the server
+// knows of it: the score the model gave, and how it decayed since.
+type scoreExplanation struct {
+	// Score is the stored priority score, calibrated and weighted by the
+	// owner's tag preferences when it was computed.
+	Score float64 `json:"score"`
+	// ModelVersion is the calibrated model's version; empty when the
+	// scorer is not calibrated.
+	ModelVersion string `json:"modelVersion,omitempty"`
+	// Decay is set when priority decay is enabled.
+	Decay *scoreDecay `json:"decay,omitempty"`
+	// Effective is the priority the todo ranks by.
+	Effective float64 `json:"effective"`
+}
+
+type scoreDecay struct {
+	HalfLifeHours float64 `json:"halfLifeHours"`
+	AgeHours      float64 `json:"ageHours"`
+	// Factor is what the score was multiplied by: 1 for done todos.
+	Factor float64 `json:"factor"`
+}
+
+// explainScore explains t's priority as present shows it.
+func (s *Server) explainScore(t db.Todo, now time.Time) *scoreExplanation {
+	e := &scoreExplanation{Score: t.PriorityScore, Effective: t.PriorityScore}
+	if cal, ok := s.scorer.(calibrator); ok {
+		e.ModelVersion = cal.Calibration().ModelVersion
+	}
+	if s.priorityHalfLife > 0 {
+		age := max(now.Sub(t.UpdatedAt), 0)
+		factor := 1.0
+		if !t.Completed {
+			factor = math.Exp2(-age.Seconds() / s.priorityHalfLife.Seconds())
+		}
+		e.Decay = &scoreDecay{
+			HalfLifeHours: round4(s.priorityHalfLife.Hours()),
+			AgeHours:      round4(age.Hours()),
+			Factor:        round4(factor),
+		}
+		e.Effective = effectivePriority(t, s.priorityHalfLife, now)
+	}
+	return e
+}
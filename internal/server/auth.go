@@ -0,0 +1,234 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/auth"
+	"todoapp/internal/db"
+	"todoapp/internal/logging"
+)
+
+// sessionCookie carries the session token for the embedded frontend; API
+// clients may send the same token as "Authorization: Bearer <token>".
+const sessionCookie = "todo_session"
+
+// WithAuth turns on user accounts: /api/auth issues session tokens signed by
+// signer, and the todo, list, view and stats routes require one, scoping
+// every store call to the authenticated user. The store must implement
+// db.UserStore.
+func WithAuth(signer *auth.Signer) Option {
+	return func(s *Server) {
+		s.sessions = signer
+	}
+}
+
+func (s *Server) authRoutes(r chi.Router) {
+	r.Post("/signup", s.handleSignup)
+	r.Post("/login", s.handleLogin)
+	r.Post("/logout", s.handleLogout)
+	r.With(s.requireUser).Get("/me", s.handleMe)
+}
+
+type credentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// userStore returns the backend's account support, writing 501 when missing.
+func (s *Server) userStore(w http.ResponseWriter) (db.UserStore, bool) {
+	users, ok := s.store.(db.UserStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "user accounts not supported by storage backend")
+	}
+	return users, ok
+}
+
+func decodeCredentials(w http.ResponseWriter, r *http.Request) (credentialsRequest, bool) {
+	var req credentialsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<12)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return req, false
+	}
+	return req, true
+}
+
+func (s *Server) handleSignup(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.userStore(w)
+	if !ok {
+		return
+	}
+	req, ok := decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+	email, err := db.NormalizeEmail(req.Email)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	user, err := users.CreateUser(ctx, email, hash)
+	if errors.Is(err, db.ErrEmailTaken) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "user.signup_failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to create user")
+		return
+	}
+	s.startSession(w, r, http.StatusCreated, user)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.userStore(w)
+	if !ok {
+		return
+	}
+	req, ok := decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	user, hash, err := users.UserByEmail(ctx, req.Email)
+	if err != nil && !errors.Is(err, db.ErrUserNotFound) {
+		slog.ErrorContext(ctx, "user.login_failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to log in")
+		return
+	}
+	// Unknown emails still pay for a hash comparison so timing does not
+	// reveal which accounts exist.
+	if err != nil && !auth.CheckNoUser(req.Password) || err == nil && !auth.CheckPassword(hash, req.Password) {
+		slog.WarnContext(ctx, "user.login_rejected")
+		writeError(w, http.StatusUnauthorized, "invalid email or password")
+		return
+	}
+	if user.Deactivated {
+		slog.WarnContext(ctx, "user.login_rejected", "user_id", user.ID, "reason", "deactivated")
+		writeError(w, http.StatusForbidden, "account deactivated")
+		return
+	}
+	s.startSession(w, r, http.StatusOK, user)
+}
+
+// startSession issues a token for user, sets it as a cookie and returns it
+// in the body for API clients.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, status int, user db.User) {
+	token, expires, err := s.sessions.Issue(user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to issue session")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.InfoContext(r.Context(), "user.session_started", "user_id", user.ID)
+	body := map[string]any{"user": user, "token": token, "expiresAt": expires.UTC()}
+	if s.signing != nil {
+		body["signingKey"] = hex.EncodeToString(s.sessions.RequestKey(token))
+	}
+	writeJSON(w, status, body)
+}
+
+// handleLogout clears the session cookie. Tokens are stateless, so a token
+// copied elsewhere stays valid until it expires.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.userStore(w)
+	if !ok {
+		return
+	}
+	id, _ := db.OwnerFrom(r.Context())
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	user, err := users.GetUser(ctx, id)
+	if errors.Is(err, db.ErrUserNotFound) {
+		writeError(w, http.StatusUnauthorized, "user no longer exists")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// requireUser rejects requests without a valid session token, unsigned
+// bearer requests when signing is on, and requests of a deactivated user,
+// and scopes the rest to the token's user. It is a no-op
+// when accounts are disabled.
+func (s *Server) requireUser(next http.Handler) http.Handler {
+	if s.sessions == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !bearer {
+			if c, err := r.Cookie(sessionCookie); err == nil {
+				token = c.Value
+			}
+		}
+		id, err := s.sessions.Verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="todos"`)
+			writeError(w, http.StatusUnauthorized, "login required")
+			return
+		}
+		if bearer && s.signing != nil {
+			if reason, ok := s.signing.verify(r, s.sessions.RequestKey(token), id); !ok {
+				slog.WarnContext(r.Context(), "user.signature_rejected", "user_id", id, "reason", reason)
+				writeError(w, http.StatusUnauthorized, reason)
+				return
+			}
+		}
+		active, err := s.UserActive(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load user")
+			return
+		}
+		if !active {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="todos"`)
+			writeError(w, http.StatusUnauthorized, "account deactivated")
+			return
+		}
+		noteUser(r.Context(), id)
+		ctx := logging.With(db.WithOwner(r.Context(), id), "user_id", id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
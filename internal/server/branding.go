@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// brandingStylesheet carries the theme colors as CSS custom properties;
+// index.html links it after styles.css so they override the defaults there.
+const brandingStylesheet = "/branding.css"
+
+var brandColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// Branding white-labels a self-hosted instance. index.html is rendered as a
+// template with it, so a frontend on disk can use the same fields. Empty
+// fields keep the stock look.
+type Branding struct {
+	AppName string
+	// LogoURL is a path on this server or an https URL.
+	LogoURL string
+	// Colors are "#rrggbb" hex values.
+	PrimaryColor    string
+	BackgroundColor string
+	TextColor       string
+}
+
+// Validate checks and normalizes b.
+func (b *Branding) Validate() error {
+	b.AppName = strings.TrimSpace(b.AppName)
+	if len(b.AppName) > 100 {
+		return errors.New("app name must be at most 100 characters")
+	}
+	b.LogoURL = strings.TrimSpace(b.LogoURL)
+	if b.LogoURL != "" && logoOrigin(b.LogoURL) == "" && !isLocalPath(b.LogoURL) {
+		return errors.New("logo url must be a path on this server or an https url")
+	}
+	for name, c := range map[string]*string{"primary": &b.PrimaryColor, "background": &b.BackgroundColor, "text": &b.TextColor} {
+		*c = strings.ToLower(strings.TrimSpace(*c))
+		if *c != "" && !brandColorPattern.MatchString(*c) {
+			return fmt.Errorf("%s color must be a hex value like #1e90ff", name)
+		}
+	}
+	return nil
+}
+
+// WithBranding sets the app name, logo and theme colors the frontend is
+// rendered with. b must have been validated.
+func WithBranding(b Branding) Option {
+	return func(s *Server) {
+		s.branding = b
+	}
+}
+
+// logoOrigin returns the origin of an https logo URL, which the content
+// security policy must allow images from, or "" for anything else.
+func logoOrigin(logo string) string {
+	u, err := url.Parse(logo)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func isLocalPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//")
+}
+
+// serveIndex renders index.html with the branding, injecting the live reload
+// script when that is enabled. A page that does not parse as a template is
+// served as it is.
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	page, err := fs.ReadFile(s.static, "index.html")
+	if err != nil {
+		http.Error(w, "index.html not found", http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	tmpl, err := template.New("index.html").Parse(string(page))
+	if err == nil {
+		err = tmpl.Execute(&buf, s.branding)
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "index.render_failed", "error", err)
+	} else {
+		page = buf.Bytes()
+	}
+	if s.liveReload {
+		tag := []byte(`<script src="` + liveReloadScript + `" defer></script>`)
+		if i := bytes.LastIndex(page, []byte("</body>")); i >= 0 {
+			page = append(page[:i:i], append(tag, page[i:]...)...)
+		} else {
+			page = append(page, tag...)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if !s.liveReload {
+		s.cacheAtEdge(w, cdnTagStatic)
+	}
+	_, _ = w.Write(page)
+}
+
+// serveBrandingStylesheet overrides the stock theme with the configured
+// colors; it is empty when none are set.
+func (s *Server) serveBrandingStylesheet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	s.cacheAtEdge(w, cdnTagStatic)
+	var vars strings.Builder
+	for _, v := range []struct{ name, value string }{
+		{"--brand-primary", s.branding.PrimaryColor},
+		{"--brand-background", s.branding.BackgroundColor},
+		{"--brand-text", s.branding.TextColor},
+	} {
+		if v.value != "" {
+			fmt.Fprintf(&vars, " %s: %s;", v.name, v.value)
+		}
+	}
+	if vars.Len() > 0 {
+		_, _ = io.WriteString(w, ":root {"+vars.String()+" }\n")
+	}
+}
+
+// contentSecurityPolicy is the policy for the frontend, which also allows
+// images from an external logo's origin.
+func (s *Server) contentSecurityPolicy() string {
+	img := "img-src 'self' data:"
+	if origin := logoOrigin(s.branding.LogoURL); origin != "" {
+		img += " " + origin
+	}
+	return "default-src 'self'; script-src 'self'; style-src 'self'; " + img + "; object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+}
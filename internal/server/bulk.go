@@ -0,0 +1,340 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/hooks"
+)
+
+// maxBulkOps caps the operations of one POST /api/todos/bulk request.
+const maxBulkOps = 1000
+
+// bulkOperation is one element of a bulk request: "create" takes the fields
+// of a new todo, "update" the id and every field as for PUT, "delete" only
+// the id.
+type bulkOperation struct {
+	Op string `json:"op"`
+	ID int64  `json:"id"`
+	updateTodoRequest
+}
+
+// bulkResult reports one operation. Status is the code the equivalent
+// single-todo request would have returned; when the batch fails the others
+// report 424, as nothing was applied.
+type bulkResult struct {
+	Index  int      `json:"index"`
+	Op     string   `json:"op"`
+	Status int      `json:"status"`
+	ID     int64    `json:"id,omitempty"`
+	Todo   *db.Todo `json:"todo,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// handleBulkTodos applies an array of create, update and delete operations
+// in a single transaction: either all succeed (200) or none are applied
+// (422), with a result per operation either way.
+func (s *Server) handleBulkTodos(w http.ResponseWriter, r *http.Request) {
+	saver, ok := s.store.(db.BulkSaver)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "bulk operations not supported by storage backend")
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, 4<<20)
+	defer body.Close()
+	var req []bulkOperation
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, bodyError(err, "invalid JSON body; expected an array of operations"))
+		return
+	}
+	if len(req) == 0 || len(req) > maxBulkOps {
+		writeError(w, http.StatusBadRequest, "expected between 1 and "+strconv.Itoa(maxBulkOps)+" operations")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	ops, before, err := s.bulkOps(ctx, req)
+	var bulkErr *db.BulkError
+	if err == nil {
+		var creates, open int64
+		for _, op := range ops {
+			if op.Action == db.BulkCreate {
+				creates++
+				if !op.Input.Completed {
+					open++
+				}
+			}
+		}
+		if creates > 0 && !s.checkTodoLimits(ctx, w, creates, open) {
+			return
+		}
+		var saved []db.Todo
+		if saved, err = saver.BulkSave(ctx, ops); err == nil {
+			for i, op := range ops {
+				if op.Action == db.BulkUpdate {
+					saved[i] = s.todoUpdated(ctx, before[i], saved[i])
+				}
+				if op.Action == db.BulkCreate {
+					s.todoCreated(ctx, saved[i])
+				}
+				if op.Action == db.BulkDelete && before[i].ID != 0 {
+					s.todoDeleted(ctx, before[i])
+				}
+			}
+			s.publish(ctx, todoEvent{Type: eventTodosChanged})
+			writeJSON(w, http.StatusOK, map[string]any{"results": s.bulkResults(req, saved)})
+			return
+		}
+	}
+	if !errors.As(err, &bulkErr) {
+		writeError(w, http.StatusInternalServerError, "bulk operation failed")
+		return
+	}
+	results := make([]bulkResult, len(req))
+	for i, op := range req {
+		results[i] = bulkResult{Index: i, Op: op.Op, ID: op.ID, Status: http.StatusFailedDependency}
+	}
+	failed := &results[bulkErr.Index]
+	failed.Status, failed.Error = http.StatusBadRequest, bulkErr.Err.Error()
+	var rejection *hooks.Rejection
+	switch {
+	case errors.Is(bulkErr, db.ErrNotFound):
+		failed.Status, failed.Error = http.StatusNotFound, "todo not found"
+	case errors.As(bulkErr, &rejection):
+		failed.Status, failed.Error = http.StatusUnprocessableEntity, rejection.Reason
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": bulkErr.Error(), "results": results})
+}
+
+// bulkOps normalizes the request into store operations, running the
+// before-create hooks and scoring created and updated todos as the
+// single-todo handlers do. Updates need the current todo, returned in
+// before, so one that does not exist fails the batch here.
+func (s *Server) bulkOps(ctx context.Context, req []bulkOperation) (ops []db.BulkOp, before []db.Todo, err error) {
+	ops = make([]db.BulkOp, len(req))
+	before = make([]db.Todo, len(req))
+	var scored []int
+	var candidates []db.Todo
+	for i, op := range req {
+		ops[i] = db.BulkOp{Action: db.BulkAction(op.Op), ID: op.ID}
+		var existing db.Todo
+		switch ops[i].Action {
+		case db.BulkDelete:
+			// Loaded only for the todo.deleted webhooks; a missing todo is
+			// left for BulkSave to skip.
+			if existing, err := s.store.GetTodo(ctx, op.ID); err == nil {
+				before[i] = existing
+			}
+			continue
+		case db.BulkUpdate:
+			if existing, err = s.store.GetTodo(ctx, op.ID); errors.Is(err, db.ErrNotFound) {
+				return nil, nil, &db.BulkError{Index: i, Err: err}
+			} else if err != nil {
+				return nil, nil, err
+			}
+			before[i] = existing
+		case db.BulkCreate:
+			existing.CreatedAt = time.Now().UTC()
+		default:
+			return nil, nil, &db.BulkError{Index: i, Err: errors.New(`op must be "create", "update" or "delete"`)}
+		}
+		ops[i].Input = db.SaveTodoInput{
+			Title:           strings.TrimSpace(op.Title),
+			Completed:       op.Completed,
+			Tags:            normalizeTags(op.Tags),
+			DurationMinutes: clampDuration(op.DurationMinutes),
+			PriorityScore:   existing.PriorityScore,
+			DueAt:           utcTime(op.DueAt),
+			StartAt:         utcTime(op.StartAt),
+			Effort:          s.effortInput(op.Effort, existing.Effort),
+			ReminderOffsets: op.ReminderOffsets,
+			Recurrence:      strings.TrimSpace(op.Recurrence),
+			ListID:          op.ListID,
+		}
+		if ops[i].Action == db.BulkCreate {
+			var rejection *hooks.Rejection
+			if err := s.runBeforeCreate(ctx, &ops[i].Input); errors.As(err, &rejection) {
+				return nil, nil, &db.BulkError{Index: i, Err: err}
+			} else if err != nil {
+				return nil, nil, err
+			}
+		}
+		scored = append(scored, i)
+		candidates = append(candidates, db.Todo{
+			Title:           ops[i].Input.Title,
+			Completed:       ops[i].Input.Completed,
+			Tags:            ops[i].Input.Tags,
+			DurationMinutes: ops[i].Input.DurationMinutes,
+			CreatedAt:       existing.CreatedAt,
+		})
+	}
+	if s.scorer != nil && len(candidates) > 0 {
+		// Scores fall back to the current ones, as for single requests.
+		scores, err := s.scoreBatch(ctx, candidates)
+		s.alerts.Record("ml", err != nil)
+		if err != nil {
+			slog.WarnContext(ctx, "ml.score_failed", "error", err)
+		} else {
+			for j, i := range scored {
+				ops[i].Input.PriorityScore = scores[j]
+			}
+		}
+	}
+	return ops, before, nil
+}
+
+func (s *Server) bulkResults(req []bulkOperation, saved []db.Todo) []bulkResult {
+	results := make([]bulkResult, len(req))
+	for i, op := range req {
+		results[i] = bulkResult{Index: i, Op: op.Op}
+		switch db.BulkAction(op.Op) {
+		case db.BulkCreate:
+			results[i].Status = http.StatusCreated
+		case db.BulkUpdate:
+			results[i].Status = http.StatusOK
+		default:
+			results[i].Status, results[i].ID = http.StatusNoContent, op.ID
+			continue
+		}
+		t := s.present(saved[i])
+		results[i].ID, results[i].Todo = t.ID, &t
+	}
+	return results
+}
+
+// handleBulkDeleteTodos deletes every todo matching the list filter. The
+// request must carry confirm=true so a stray DELETE on the collection cannot
+// wipe it. The deleted todos are never loaded, so no todo.deleted webhooks
+// are queued for them.
+func (s *Server) handleBulkDeleteTodos(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("confirm") != "true" {
+		writeError(w, http.StatusBadRequest, "bulk delete requires confirm=true")
+		return
+	}
+	deleter, ok := s.store.(db.BulkDeleter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "bulk delete not supported by storage backend")
+		return
+	}
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	n, err := deleter.DeleteTodos(ctx, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete todos")
+		return
+	}
+	if n > 0 {
+		s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	}
+	writeJSON(w, http.StatusOK, map[string]int64{"deleted": n})
+}
+
+// bulkFilter selects the todos of a bulk request; it takes the same fields
+// as the list filter query.
+type bulkFilter struct {
+	Completed *bool  `json:"completed"`
+	Tag       string `json:"tag"`
+	ListID    int64  `json:"listId"`
+	OlderThan string `json:"olderThan"`
+}
+
+func (b bulkFilter) todoFilter() (db.TodoFilter, error) {
+	f := db.TodoFilter{
+		Completed: b.Completed,
+		Tag:       strings.TrimSpace(strings.ToLower(b.Tag)),
+		ListID:    b.ListID,
+	}
+	if f.ListID < 0 {
+		return db.TodoFilter{}, errors.New("invalid listId filter")
+	}
+	if b.OlderThan != "" {
+		age, err := parseAge(b.OlderThan)
+		if err != nil {
+			return db.TodoFilter{}, errors.New("invalid olderThan filter")
+		}
+		f.CreatedBefore = time.Now().Add(-age)
+	}
+	return f, nil
+}
+
+// retagRequest is the body of POST /api/todos/retag.
+type retagRequest struct {
+	Filter bulkFilter `json:"filter"`
+	Add    []string   `json:"add"`
+	Remove []string   `json:"remove"`
+	DryRun bool       `json:"dryRun"`
+}
+
+// handleRetagTodos adds and removes tags on every todo matching a filter
+// and reports how many changed; with dryRun it only counts them. Like bulk
+// delete, the changed todos are never loaded, so clients get a single
+// todos.changed event and no per-todo webhooks are queued.
+func (s *Server) handleRetagTodos(w http.ResponseWriter, r *http.Request) {
+	retagger, ok := s.store.(db.Retagger)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "bulk retag not supported by storage backend")
+		return
+	}
+	var req retagRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	filter, err := req.Filter.todoFilter()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	n, err := retagger.RetagTodos(ctx, filter, req.Add, req.Remove, req.DryRun)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidRetag) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to retag todos")
+		return
+	}
+	if n > 0 && !req.DryRun {
+		s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"affected": n, "dryRun": req.DryRun})
+}
+
+// parseAge parses a relative age such as "30d", "2w" or "12h". Day and week
+// units are added on top of time.ParseDuration.
+func parseAge(v string) (time.Duration, error) {
+	unit := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, d := range unit {
+		if n, ok := strings.CutSuffix(v, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, errors.New("invalid age")
+			}
+			return time.Duration(count) * d, nil
+		}
+	}
+	age, err := time.ParseDuration(v)
+	if err != nil || age < 0 {
+		return 0, errors.New("invalid age")
+	}
+	return age, nil
+}
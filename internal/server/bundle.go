@@ -0,0 +1,159 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// An export bundle (format=bundle) is a zip of the todos, as a JSON array
+// in the json export's layout, and a manifest listing each file's record
+// count and checksum. Import checks the manifest before applying anything,
+// so a truncated or altered bundle restores nothing. Entries carry no
+// timestamps, so exporting the same todos twice yields the same bytes.
+const (
+	bundleFormat = "todoapp-export"
+	// bundleVersion is the bundle layout version; imports reject newer ones.
+	bundleVersion = 1
+
+	bundleManifestFile = "manifest.json"
+	bundleTodosFile    = "todos.json"
+
+	// maxBundleFileBytes caps a file unpacked from a bundle.
+	maxBundleFileBytes = 4 * maxImportBytes
+)
+
+type bundleManifest struct {
+	Format  string       `json:"format"`
+	Version int          `json:"version"`
+	Files   []bundleFile `json:"files"`
+}
+
+type bundleFile struct {
+	Name    string `json:"name"`
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+// exportBundle writes a bundle to a stream; the manifest goes last, once
+// the todos have been counted and hashed.
+type exportBundle struct {
+	zw   *zip.Writer
+	hash hash.Hash
+}
+
+// newExportBundle starts a bundle on w and returns the writer the todos
+// file is written to.
+func newExportBundle(w io.Writer) (*exportBundle, io.Writer, error) {
+	b := &exportBundle{zw: zip.NewWriter(w), hash: sha256.New()}
+	f, err := b.zw.CreateHeader(&zip.FileHeader{Name: bundleTodosFile, Method: zip.Deflate})
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, io.MultiWriter(f, b.hash), nil
+}
+
+func (b *exportBundle) flush() error {
+	return b.zw.Flush()
+}
+
+// close writes the manifest for records todos and completes the zip.
+func (b *exportBundle) close(records int) error {
+	data, err := json.MarshalIndent(bundleManifest{
+		Format:  bundleFormat,
+		Version: bundleVersion,
+		Files:   []bundleFile{{Name: bundleTodosFile, Records: records, SHA256: hex.EncodeToString(b.hash.Sum(nil))}},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := b.zw.CreateHeader(&zip.FileHeader{Name: bundleManifestFile, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return b.zw.Close()
+}
+
+// readBundleImport reads the todos of a bundle after checking its manifest:
+// the format and version, and the checksum of every file it lists and the
+// record count of the todos.
+func readBundleImport(r io.Reader) ([]importRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.New("failed to read bundle")
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("invalid bundle; the file may be truncated")
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	read := func(name string) ([]byte, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("bundle is missing %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", name)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxBundleFileBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", name)
+		}
+		if len(data) > maxBundleFileBytes {
+			return nil, fmt.Errorf("%s is too large", name)
+		}
+		return data, nil
+	}
+
+	raw, err := read(bundleManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var m bundleManifest
+	if err := json.Unmarshal(raw, &m); err != nil || m.Format != bundleFormat {
+		return nil, errors.New("invalid bundle manifest")
+	}
+	if m.Version < 1 || m.Version > bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", m.Version)
+	}
+	var records []importRecord
+	found := false
+	for _, bf := range m.Files {
+		data, err := read(bf.Name)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != bf.SHA256 {
+			return nil, fmt.Errorf("%s does not match its checksum", bf.Name)
+		}
+		if bf.Name != bundleTodosFile {
+			continue
+		}
+		if records, err = readJSONImport(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("invalid %s", bf.Name)
+		}
+		if len(records) != bf.Records {
+			return nil, fmt.Errorf("%s has %d records, the manifest lists %d", bf.Name, len(records), bf.Records)
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("bundle manifest does not list %s", bundleTodosFile)
+	}
+	return records, nil
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/mlclient"
+)
+
+// calibrator is implemented by scorers that calibrate scores to a common
+// scale; *mlclient.Client is one.
+type calibrator interface {
+	Calibration() mlclient.Calibration
+	RefreshCalibration(ctx context.Context) (bool, error)
+}
+
+// RunCalibration refreshes the ML service's score calibration now and then
+// every interval until ctx is cancelled. When it changes, typically with a
+// new model version, every todo is re-scored so stored scores stay
+// comparable; a backfill that fails or finds a rescore running is retried
+// on the next tick. Scores stored before the server started are not
+// checked: after upgrading the model while it was down, backfill with POST
+// /api/admin/ml/recalibrate.
+func (s *Server) RunCalibration(ctx context.Context, interval time.Duration) {
+	cal, ok := s.scorer.(calibrator)
+	if !ok {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	first, backfill := true, false
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, interval)
+		changed, err := cal.RefreshCalibration(runCtx)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "ml.calibration_failed", "error", err)
+		case changed:
+			c := cal.Calibration()
+			slog.InfoContext(ctx, "ml.calibration_changed", "model_version", c.ModelVersion, "method", c.Method)
+			backfill = backfill || !first
+		}
+		if err == nil {
+			first = false
+		}
+		if backfill {
+			if _, err := s.rescore(runCtx, true); err != nil {
+				slog.WarnContext(ctx, "ml.recalibrate_failed", "error", err)
+			} else {
+				backfill = false
+			}
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// handleAdminCalibration reports the calibration applied to ML scores.
+func (s *Server) handleAdminCalibration(w http.ResponseWriter, r *http.Request) {
+	cal, ok := s.scorer.(calibrator)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "score calibration not supported by the scorer")
+		return
+	}
+	writeJSON(w, http.StatusOK, cal.Calibration())
+}
+
+// handleAdminRecalibrate refreshes the calibration and re-scores every
+// todo with it.
+func (s *Server) handleAdminRecalibrate(w http.ResponseWriter, r *http.Request) {
+	cal, ok := s.scorer.(calibrator)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "score calibration not supported by the scorer")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Minute)
+	defer cancel()
+	if _, err := cal.RefreshCalibration(ctx); err != nil {
+		slog.ErrorContext(ctx, "ml.calibration_failed", "error", err)
+		writeError(w, http.StatusBadGateway, "failed to fetch calibration")
+		return
+	}
+	res, err := s.rescore(ctx, true)
+	switch {
+	case errors.Is(err, errRescoreRunning):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, errRescoreUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+	case err != nil:
+		slog.ErrorContext(ctx, "ml.recalibrate_failed", "error", err, "scored", res.Scored)
+		writeError(w, http.StatusBadGateway, "recalibration failed")
+	default:
+		writeJSON(w, http.StatusOK, struct {
+			Calibration mlclient.Calibration `json:"calibration"`
+			rescoreResult
+		}{cal.Calibration(), res})
+	}
+}
+
+// handleAdminScoreDistribution histograms stored priority scores by how
+// recently each todo was updated. Every model change re-scores all todos
+// (see RunCalibration), so the scores are those of the current model
+// version; a band that differs from the others, or one flagged constant,
+// points at drift or a broken model. Re-scoring leaves updated_at alone,
+// so the bands follow edits rather than scoring runs.
+func (s *Server) handleAdminScoreDistribution(w http.ResponseWriter, r *http.Request) {
+	dist, ok := s.store.(db.ScoreDistributor)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "score distribution not supported by storage backend")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	bands, err := dist.ScoreDistribution(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "ml.score_distribution_failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to aggregate scores")
+		return
+	}
+	res := struct {
+		// ModelVersion is empty when the scorer is not calibrated.
+		ModelVersion string         `json:"modelVersion"`
+		Bands        []db.ScoreBand `json:"bands"`
+	}{Bands: bands}
+	if cal, ok := s.scorer.(calibrator); ok {
+		res.ModelVersion = cal.Calibration().ModelVersion
+	}
+	writeJSON(w, http.StatusOK, res)
+}
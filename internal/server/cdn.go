@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache tags of the responses a CDN may keep, for purging them by kind.
+const (
+	cdnTagStatic  = "static"  // the frontend: index.html, assets, branding
+	cdnTagStatus  = "status"  // the public status page
+	cdnTagAPIDocs = "openapi" // the OpenAPI document
+)
+
+var cdnTags = []string{cdnTagStatic, cdnTagStatus, cdnTagAPIDocs}
+
+// CDN describes the CDN in front of the server.
+type CDN struct {
+	// MaxAge is how long the CDN may keep the frontend and the API docs;
+	// a purge ends it sooner. The status page is kept for as long as it
+	// caches its own checks.
+	MaxAge time.Duration
+	// Provider ("fastly" or "cloudflare") selects the purge API, PurgeURL
+	// is its endpoint (a Fastly service's /purge, a Cloudflare zone's
+	// /purge_cache) and PurgeToken the API token. Purging is off without a
+	// URL.
+	Provider   string
+	PurgeURL   string
+	PurgeToken string
+}
+
+// Validate checks the provider and purge endpoint.
+func (c CDN) Validate() error {
+	if c.MaxAge <= 0 {
+		return errors.New("cdn max age must be positive")
+	}
+	if c.PurgeURL == "" {
+		return nil
+	}
+	if c.Provider != "fastly" && c.Provider != "cloudflare" {
+		return fmt.Errorf("cdn provider must be fastly or cloudflare, not %q", c.Provider)
+	}
+	u, err := url.Parse(c.PurgeURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid cdn purge url %q", c.PurgeURL)
+	}
+	return nil
+}
+
+// WithCDN lets a CDN cache the public, user-independent responses: they
+// carry Surrogate-Control and CDN-Cache-Control for the CDN, leaving the
+// browser's Cache-Control as it is, and Cache-Tag and Surrogate-Key so they
+// can be purged by kind. Everything else is marked no-store for the CDN, so
+// per-user API responses are never shared. c must have been validated.
+func WithCDN(c CDN) Option {
+	return func(s *Server) {
+		s.cdn = &c
+	}
+}
+
+// noSurrogateStore keeps every response out of the CDN unless its handler
+// marks it cacheable.
+func (s *Server) noSurrogateStore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Surrogate-Control", "no-store")
+		w.Header().Set("CDN-Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cacheAtEdge lets the CDN keep the response under tag; it does nothing
+// without a CDN. Call it before writing the header.
+func (s *Server) cacheAtEdge(w http.ResponseWriter, tag string) {
+	if s.cdn == nil {
+		return
+	}
+	maxAge := s.cdn.MaxAge
+	if tag == cdnTagStatus {
+		maxAge = statusCacheTTL
+	}
+	control := "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	h := w.Header()
+	h.Set("Surrogate-Control", control)
+	h.Set("CDN-Cache-Control", control)
+	h.Set("Cache-Tag", tag)
+	h.Set("Surrogate-Key", tag)
+}
+
+// cacheStatic marks the static files that exist as cacheable; unknown paths
+// stay uncached, so a 404 probed before a deploy does not outlive it.
+func (s *Server) cacheStatic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cdn != nil {
+			name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+			if _, err := fs.Stat(s.static, name); err == nil {
+				s.cacheAtEdge(w, cdnTagStatic)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PurgeCDN drops the responses tagged with tags from the CDN, or all of
+// them without tags. It does nothing unless purging is configured; call it
+// after a deploy, as the frontend and API docs may have changed.
+func (s *Server) PurgeCDN(ctx context.Context, tags ...string) error {
+	if s.cdn == nil || s.cdn.PurgeURL == "" {
+		return nil
+	}
+	if len(tags) == 0 {
+		tags = cdnTags
+	}
+	var req *http.Request
+	var err error
+	switch s.cdn.Provider {
+	case "fastly":
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.cdn.PurgeURL, nil)
+		if err == nil {
+			req.Header.Set("Fastly-Key", s.cdn.PurgeToken)
+			req.Header.Set("Surrogate-Key", strings.Join(tags, " "))
+		}
+	default:
+		body, _ := json.Marshal(map[string][]string{"tags": tags})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.cdn.PurgeURL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Authorization", "Bearer "+s.cdn.PurgeToken)
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("cdn purge: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("cdn purge: %s", resp.Status)
+	}
+	slog.InfoContext(ctx, "cdn.purged", "provider", s.cdn.Provider, "tags", tags)
+	return nil
+}
+
+// handleAdminPurgeCDN purges the tags of the body ({"tags": [...]}), or
+// everything without a body.
+func (s *Server) handleAdminPurgeCDN(w http.ResponseWriter, r *http.Request) {
+	if s.cdn == nil || s.cdn.PurgeURL == "" {
+		writeError(w, http.StatusNotImplemented, "cdn purging not configured")
+		return
+	}
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	for _, tag := range req.Tags {
+		if !slices.Contains(cdnTags, tag) {
+			writeError(w, http.StatusBadRequest, "tags must be among "+strings.Join(cdnTags, ", "))
+			return
+		}
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := s.PurgeCDN(ctx, req.Tags...); err != nil {
+		slog.WarnContext(ctx, "cdn.purge_failed", "error", err)
+		writeError(w, http.StatusBadGateway, "cdn purge failed")
+		return
+	}
+	tags := req.Tags
+	if len(tags) == 0 {
+		tags = cdnTags
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"purged": tags})
+}
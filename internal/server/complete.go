@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// WithCompletionUndo sets how long the last batch a user completed through
+// POST /api/todos/complete can be undone. The completion rules, webhooks and
+// next occurrences of recurring todos are held back until the window
+// closes, so an undo leaves no trace beyond the todos' update times. Zero
+// runs them at once and disables undo.
+func WithCompletionUndo(window time.Duration) Option {
+	return func(s *Server) {
+		s.completions.window = window
+	}
+}
+
+// completionBatch is a batch of completed todos whose completion hooks are
+// held back.
+type completionBatch struct {
+	id      int64
+	owner   int64
+	before  []db.Todo // the todos as they were before completing them
+	expires time.Time
+	timer   *time.Timer
+}
+
+// completionUndo keeps each user's last batch until it expires or is
+// undone. Without accounts everyone shares a single batch.
+type completionUndo struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	lastID  int64
+	batches map[int64]*completionBatch
+}
+
+func newCompletionUndo() *completionUndo {
+	return &completionUndo{batches: make(map[int64]*completionBatch)}
+}
+
+// take removes and returns owner's batch, if it is batch id when id is
+// non-zero, or nil; only the caller that takes a batch may finish or undo
+// it.
+func (c *completionUndo) take(owner int64, id int64) *completionBatch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := c.batches[owner]
+	if b == nil || (id != 0 && b.id != id) {
+		return nil
+	}
+	delete(c.batches, owner)
+	b.timer.Stop()
+	return b
+}
+
+// todoInput is the input saving t as it is.
+func todoInput(t db.Todo) db.SaveTodoInput {
+	return db.SaveTodoInput{
+		Title:           t.Title,
+		Completed:       t.Completed,
+		Tags:            t.Tags,
+		DurationMinutes: t.DurationMinutes,
+		PriorityScore:   t.PriorityScore,
+		DueAt:           t.DueAt,
+		StartAt:         t.StartAt,
+		Effort:          t.Effort,
+		ReminderOffsets: t.ReminderOffsets,
+		Recurrence:      t.Recurrence,
+		ListID:          t.ListID,
+	}
+}
+
+// completeRequest is the body of POST /api/todos/complete: either the ids
+// of the todos to complete or a filter selecting them. A filter only ever
+// selects open todos.
+type completeRequest struct {
+	IDs    []int64     `json:"ids"`
+	Filter *bulkFilter `json:"filter"`
+}
+
+// handleCompleteTodos completes the todos a request selects in one
+// transaction. Those already completed are left alone. The batch can be
+// undone through POST /api/todos/uncomplete-last-batch until undoUntil.
+func (s *Server) handleCompleteTodos(w http.ResponseWriter, r *http.Request) {
+	saver, ok := s.store.(db.BulkSaver)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "bulk operations not supported by storage backend")
+		return
+	}
+	var req completeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if (len(req.IDs) == 0) == (req.Filter == nil) {
+		writeError(w, http.StatusBadRequest, "expected either ids or a filter")
+		return
+	}
+	if len(req.IDs) > maxBulkOps {
+		writeError(w, http.StatusBadRequest, "expected at most "+strconv.Itoa(maxBulkOps)+" ids")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var todos []db.Todo
+	if req.Filter != nil {
+		lister, ok := s.store.(db.FilteredLister)
+		if !ok {
+			writeError(w, http.StatusNotImplemented, "filtering not supported by storage backend")
+			return
+		}
+		filter, err := req.Filter.todoFilter()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		open := false
+		filter.Completed = &open
+		if todos, err = lister.ListTodosFiltered(ctx, filter); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to list todos")
+			return
+		}
+		if len(todos) > maxBulkOps {
+			writeError(w, http.StatusBadRequest, "filter matches more than "+strconv.Itoa(maxBulkOps)+" todos")
+			return
+		}
+	} else {
+		seen := make(map[int64]bool, len(req.IDs))
+		for _, id := range req.IDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			t, err := s.store.GetTodo(ctx, id)
+			if errors.Is(err, db.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "todo "+strconv.FormatInt(id, 10)+" not found")
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to load todo")
+				return
+			}
+			if !t.Completed {
+				todos = append(todos, t)
+			}
+		}
+	}
+
+	ids := make([]int64, len(todos))
+	ops := make([]db.BulkOp, len(todos))
+	for i, t := range todos {
+		ids[i] = t.ID
+		ops[i] = db.BulkOp{Action: db.BulkUpdate, ID: t.ID, Input: todoInput(t)}
+		ops[i].Input.Completed = true
+	}
+	if len(ops) > 0 {
+		if _, err := saver.BulkSave(ctx, ops); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to complete todos")
+			return
+		}
+		s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	}
+	resp := map[string]any{"completed": len(ids), "ids": ids}
+	if b := s.holdCompletions(ctx, todos); b != nil {
+		resp["batchId"], resp["undoUntil"] = b.id, b.expires
+	}
+	slog.InfoContext(ctx, "todos.batch_completed", "count", len(ids))
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// holdCompletions keeps the completed todos, as they were before, as the
+// caller's batch open to undo and returns it. The caller's previous batch
+// can no longer be undone, so its hooks run now. Without an undo window,
+// the hooks of todos run at once and nil is returned.
+func (s *Server) holdCompletions(ctx context.Context, before []db.Todo) *completionBatch {
+	owner, _ := db.OwnerFrom(ctx)
+	if prev := s.completions.take(owner, 0); prev != nil {
+		go s.finishCompletions(prev)
+	}
+	if len(before) == 0 {
+		return nil
+	}
+	b := &completionBatch{owner: owner, before: before}
+	c := s.completions
+	if c.window <= 0 {
+		s.finishCompletions(b)
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastID++
+	b.id = c.lastID
+	b.expires = time.Now().Add(c.window).UTC()
+	b.timer = time.AfterFunc(c.window, func() {
+		if b := c.take(b.owner, b.id); b != nil {
+			s.finishCompletions(b)
+		}
+	})
+	c.batches[owner] = b
+	return b
+}
+
+// finishCompletions runs the update hooks of the batch's todos that are
+// still completed, as if each had been completed on its own.
+func (s *Server) finishCompletions(b *completionBatch) {
+	ctx, cancel := context.WithTimeout(ownerContext(context.Background(), b.owner), 30*time.Second)
+	defer cancel()
+	for _, before := range b.before {
+		current, err := s.store.GetTodo(ctx, before.ID)
+		if err != nil || !current.Completed {
+			continue
+		}
+		after := s.todoUpdated(ctx, before, current)
+		if before.Recurrence != "" {
+			s.publishTodo(ctx, eventTodoUpdated, after)
+		}
+	}
+}
+
+// FlushCompletions runs the held hooks of every batch still open to undo;
+// call it on shutdown so they are not lost.
+func (s *Server) FlushCompletions() {
+	c := s.completions
+	c.mu.Lock()
+	var batches []*completionBatch
+	for owner, b := range c.batches {
+		b.timer.Stop()
+		delete(c.batches, owner)
+		batches = append(batches, b)
+	}
+	c.mu.Unlock()
+	for _, b := range batches {
+		s.finishCompletions(b)
+	}
+}
+
+// handleUncompleteLastBatch reopens the todos of the caller's last batch,
+// while it can still be undone. Todos reopened or deleted meanwhile are
+// skipped; their other fields stay as they are now. If reopening fails the
+// batch stays completed and its hooks run.
+func (s *Server) handleUncompleteLastBatch(w http.ResponseWriter, r *http.Request) {
+	saver, ok := s.store.(db.BulkSaver)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "bulk operations not supported by storage backend")
+		return
+	}
+	owner, _ := db.OwnerFrom(r.Context())
+	b := s.completions.take(owner, 0)
+	if b == nil {
+		writeError(w, http.StatusNotFound, "no completed batch to undo")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	ids := []int64{}
+	var ops []db.BulkOp
+	for _, before := range b.before {
+		current, err := s.store.GetTodo(ctx, before.ID)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			go s.finishCompletions(b)
+			writeError(w, http.StatusInternalServerError, "failed to load todo")
+			return
+		}
+		if !current.Completed {
+			continue
+		}
+		ids = append(ids, current.ID)
+		ops = append(ops, db.BulkOp{Action: db.BulkUpdate, ID: current.ID, Input: todoInput(current)})
+		ops[len(ops)-1].Input.Completed = false
+	}
+	if len(ops) > 0 {
+		if _, err := saver.BulkSave(ctx, ops); err != nil {
+			go s.finishCompletions(b)
+			writeError(w, http.StatusInternalServerError, "failed to reopen todos")
+			return
+		}
+		s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	}
+	slog.InfoContext(ctx, "todos.batch_uncompleted", "batch_id", b.id, "count", len(ids))
+	writeJSON(w, http.StatusOK, map[string]any{"batchId": b.id, "uncompleted": len(ids), "ids": ids})
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"todoapp/internal/db"
+)
+
+// debugQueriesHeader asks for every SQL statement of one request to be
+// logged. Only admins may: the admin token goes in X-Admin-Token, since
+// Authorization may carry the user's own session, or as the bearer token
+// of admin API requests. Everyone else's header is ignored.
+const debugQueriesHeader = "X-Debug-Queries"
+
+// isAdminRequest reports whether r carries the admin token.
+func (s *Server) isAdminRequest(r *http.Request) bool {
+	if s.adminToken == "" {
+		return false
+	}
+	token := r.Header.Get("X-Admin-Token")
+	if token == "" {
+		token, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// debugQueries logs the statements of requests carrying
+// X-Debug-Queries: 1 from an admin, and their total.
+func (s *Server) debugQueries(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(debugQueriesHeader) != "1" || !s.isAdminRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, ql := db.WithQueryLog(r.Context())
+		next.ServeHTTP(w, r.WithContext(ctx))
+		n, total := ql.Totals()
+		slog.InfoContext(ctx, "request.queries", "method", r.Method, "path", redactPath(r.URL.Path),
+			"statements", n, "total_ms", float64(total.Microseconds())/1000, "backend", db.Backend(s.store))
+	})
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/stats"
+	"todoapp/internal/telemetry"
+)
+
+// DeferredScoring configures scoring off the request path.
+type DeferredScoring struct {
+	// MinBudget is the least time a request must have left before its
+	// deadline to wait on the ML service. With less, the todo is saved with
+	// its fallback score and scored in the background.
+	MinBudget time.Duration
+	// QueueSize bounds the todos waiting to be scored; when it is full they
+	// keep the fallback score until the next rescore.
+	QueueSize int
+}
+
+// WithDeferredScoring skips the synchronous ML call of requests that are
+// about to run out of time, keeping create and update latency bounded when
+// the database or hooks were slow. Background scoring needs a store that
+// can update scores; without one, or a zero MinBudget, every todo is scored
+// inline.
+func WithDeferredScoring(d DeferredScoring) Option {
+	return func(s *Server) {
+		if d.MinBudget <= 0 || d.QueueSize <= 0 {
+			return
+		}
+		if _, ok := s.store.(db.ScoreUpdater); !ok {
+			return
+		}
+		s.deferred = &scoreDeferrer{minBudget: d.MinBudget, queue: make(chan int64, d.QueueSize)}
+	}
+}
+
+// scoreDeferrer holds the todos waiting to be scored.
+type scoreDeferrer struct {
+	minBudget time.Duration
+	queue     chan int64
+}
+
+type scoreDeferralKey struct{}
+
+// scoreDeferral records whether scoring was skipped during a request; the
+// todos the request then saves are queued.
+type scoreDeferral struct {
+	skipped atomic.Bool
+}
+
+// withScoreDeferral lets computePriority skip scoring under ctx. Only
+// operations that run the todo hooks after saving may use it, or a skipped
+// todo would never be queued.
+func (s *Server) withScoreDeferral(ctx context.Context) context.Context {
+	if s.deferred == nil {
+		return ctx
+	}
+	if _, ok := ctx.Value(scoreDeferralKey{}).(*scoreDeferral); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, scoreDeferralKey{}, &scoreDeferral{})
+}
+
+// deferScores applies withScoreDeferral to each API request.
+func (s *Server) deferScores(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(s.withScoreDeferral(r.Context())))
+	})
+}
+
+// skipScore reports whether ctx has too little time left to wait on the ML
+// service, and if so marks the request's todos for background scoring.
+func (s *Server) skipScore(ctx context.Context) bool {
+	if s.deferred == nil {
+		return false
+	}
+	d, ok := ctx.Value(scoreDeferralKey{}).(*scoreDeferral)
+	if !ok {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) >= s.deferred.minBudget {
+		return false
+	}
+	d.skipped.Store(true)
+	telemetry.MLScoresDeferred.WithLabelValues("skipped").Inc()
+	stats.MLScoresDeferred.Inc()
+	return true
+}
+
+// queueDeferredScore queues t for scoring if its request skipped scoring.
+// Every todo the request saves is queued, since which of them skipped is not
+// tracked; rescoring an already scored one changes nothing.
+func (s *Server) queueDeferredScore(ctx context.Context, t db.Todo) {
+	if s.deferred == nil {
+		return
+	}
+	d, ok := ctx.Value(scoreDeferralKey{}).(*scoreDeferral)
+	if !ok || !d.skipped.Load() {
+		return
+	}
+	select {
+	case s.deferred.queue <- t.ID:
+	default:
+		telemetry.MLScoresDeferred.WithLabelValues("dropped").Inc()
+		slog.WarnContext(ctx, "ml.deferred_score_dropped", "todo_id", t.ID)
+	}
+}
+
+// RunDeferredScoring scores queued todos, in batches of whatever is waiting,
+// until ctx is cancelled. It returns at once when deferred scoring is off.
+func (s *Server) RunDeferredScoring(ctx context.Context) {
+	if s.deferred == nil {
+		return
+	}
+	for {
+		var ids []int64
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.deferred.queue:
+			ids = append(ids, id)
+		}
+	collect:
+		for len(ids) < rescoreBatch {
+			select {
+			case id := <-s.deferred.queue:
+				ids = append(ids, id)
+			default:
+				break collect
+			}
+		}
+		if err := s.scoreDeferred(ctx, ids); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "ml.deferred_score_failed", "todos", len(ids), "error", err)
+		}
+	}
+}
+
+// scoreDeferred scores the todos ids and persists the scores that changed.
+// Todos deleted meanwhile are skipped; failed ones keep the fallback score
+// until the next rescore.
+func (s *Server) scoreDeferred(ctx context.Context, ids []int64) (err error) {
+	done := s.jobs.start(jobDeferredScores)
+	defer func() { done(err) }()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	todos := make([]db.Todo, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		t, err := s.store.GetTodo(ctx, id)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		todos = append(todos, t)
+	}
+	if len(todos) == 0 {
+		return nil
+	}
+	scores, err := s.scoreBatch(ctx, todos)
+	s.alerts.Record("ml", err != nil)
+	if err != nil {
+		return err
+	}
+	changed := make(map[int64]float64)
+	for i, t := range todos {
+		if math.Abs(scores[i]-t.PriorityScore) > 1e-9 {
+			changed[t.ID] = scores[i]
+		}
+	}
+	if err := s.store.(db.ScoreUpdater).UpdatePriorityScores(ctx, changed); err != nil {
+		return err
+	}
+	telemetry.MLScoresDeferred.WithLabelValues("scored").Add(float64(len(todos)))
+	if len(changed) > 0 {
+		s.lists.clear()
+		s.events.broadcast(ctx, todoEvent{Type: eventTodosChanged})
+	}
+	slog.DebugContext(ctx, "ml.deferred_scored", "scored", len(todos), "changed", len(changed))
+	return nil
+}
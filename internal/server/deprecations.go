@@ -0,0 +1,301 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todoapp/internal/telemetry"
+)
+
+const (
+	// maxDeprecationClients bounds the clients remembered per deprecated
+	// operation, parameter or field; requests from others are only counted.
+	maxDeprecationClients = 1000
+	// maxUserAgent is how much of a User-Agent identifies a client.
+	maxUserAgent = 256
+)
+
+// deprecationTracker records who still uses what openapi.json marks
+// deprecated, so maintainers can tell when it is safe to remove. Usage is
+// kept in memory since the process started; the counts, without clients,
+// are also exported as todo_deprecated_requests_total for all replicas.
+type deprecationTracker struct {
+	spec     *specValidator
+	declared []declaredDeprecation
+	since    time.Time
+
+	mu    sync.Mutex
+	usage map[deprecationKey]*deprecationUsage
+}
+
+// deprecationKey names one deprecated thing. kind is "operation",
+// "parameter" (a query parameter) or "field" (a request body property),
+// the last two named by name.
+type deprecationKey struct {
+	method, path, kind, name string
+}
+
+type declaredDeprecation struct {
+	deprecationKey
+	spec *specDeprecation
+}
+
+// deprecationClient identifies a client: its user, zero when accounts are
+// disabled or the request was not authenticated, and its User-Agent.
+type deprecationClient struct {
+	userID    int64
+	userAgent string
+}
+
+type deprecationUsage struct {
+	requests            int64
+	firstSeen, lastSeen time.Time
+	clients             map[deprecationClient]*clientUsage
+	otherRequests       int64
+}
+
+type clientUsage struct {
+	requests            int64
+	firstSeen, lastSeen time.Time
+}
+
+// newDeprecationTracker returns a tracker for the deprecations in the
+// embedded spec, or nil when it marks nothing deprecated.
+func newDeprecationTracker(now time.Time) *deprecationTracker {
+	v, err := newSpecValidator(openAPISpec)
+	if err != nil {
+		// The spec is embedded; a broken one is a build mistake.
+		panic("openapi.json: " + err.Error())
+	}
+	t := &deprecationTracker{spec: v, since: now, usage: make(map[deprecationKey]*deprecationUsage)}
+	for _, op := range v.operations {
+		d := op.deprecated
+		if d == nil {
+			continue
+		}
+		declare := func(kind, name string) {
+			t.declared = append(t.declared, declaredDeprecation{deprecationKey{op.method, d.path, kind, name}, d})
+		}
+		if d.operation {
+			declare("operation", "")
+		}
+		for _, name := range d.params {
+			declare("parameter", name)
+		}
+		for _, name := range d.fields {
+			declare("field", name)
+		}
+	}
+	if len(t.declared) == 0 {
+		return nil
+	}
+	slices.SortFunc(t.declared, func(a, b declaredDeprecation) int {
+		return cmp.Or(strings.Compare(a.path, b.path), strings.Compare(a.method, b.method),
+			strings.Compare(a.kind, b.kind), strings.Compare(a.name, b.name))
+	})
+	return t
+}
+
+// requestUserKey holds a *int64 that requireUser fills in with the
+// authenticated user, for middleware running outside it.
+type requestUserKey struct{}
+
+// noteUser records the request's user for the middleware that asked for it.
+func noteUser(ctx context.Context, id int64) {
+	if user, ok := ctx.Value(requestUserKey{}).(*int64); ok {
+		*user = id
+	}
+}
+
+// trackDeprecations records requests to deprecated operations, and those
+// using deprecated query parameters or body fields. Deprecated operations
+// answer with a Deprecation header (RFC 9745) when the spec dates them,
+// and a Sunset header (RFC 8594) when it says until when they are served.
+func (s *Server) trackDeprecations(next http.Handler) http.Handler {
+	t := s.deprecations
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := t.spec.operation(r)
+		if op == nil || op.deprecated == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		d := op.deprecated
+		var used []deprecationKey
+		if d.operation {
+			used = append(used, deprecationKey{op.method, d.path, "operation", ""})
+			if !d.at.IsZero() {
+				w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.at.Unix(), 10))
+			}
+			if !d.sunset.IsZero() {
+				w.Header().Set("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+			}
+		}
+		query := r.URL.Query()
+		for _, name := range d.params {
+			if query.Has(name) {
+				used = append(used, deprecationKey{op.method, d.path, "parameter", name})
+			}
+		}
+		if len(d.fields) > 0 {
+			fields := bodyFields(r)
+			for _, name := range d.fields {
+				if _, ok := fields[name]; ok {
+					used = append(used, deprecationKey{op.method, d.path, "field", name})
+				}
+			}
+		}
+		if len(used) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var user int64
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestUserKey{}, &user)))
+
+		agent := r.UserAgent()
+		if len(agent) > maxUserAgent {
+			agent = agent[:maxUserAgent]
+		}
+		client := deprecationClient{userID: user, userAgent: agent}
+		for _, key := range used {
+			telemetry.DeprecatedRequests.WithLabelValues(key.method, key.path, key.kind, key.name).Inc()
+			if t.record(key, client, time.Now()) {
+				slog.InfoContext(r.Context(), "api.deprecated_used", "method", key.method, "path", key.path,
+					"kind", key.kind, "name", key.name, "user_id", user, "user_agent", agent)
+			}
+		}
+	})
+}
+
+// bodyFields returns the top-level properties of r's JSON body, putting the
+// body back. Bodies that are too large or not JSON objects have none.
+func bodyFields(r *http.Request) map[string]json.RawMessage {
+	if r.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSpecBody+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxSpecBody {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return nil
+	}
+	return fields
+}
+
+// record counts a use of key by client, reporting whether the client is
+// new to it.
+func (t *deprecationTracker) record(key deprecationKey, client deprecationClient, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.usage[key]
+	if !ok {
+		u = &deprecationUsage{firstSeen: now, clients: make(map[deprecationClient]*clientUsage)}
+		t.usage[key] = u
+	}
+	u.requests++
+	u.lastSeen = now
+	c, ok := u.clients[client]
+	if !ok {
+		if len(u.clients) >= maxDeprecationClients {
+			u.otherRequests++
+			return false
+		}
+		c = &clientUsage{firstSeen: now}
+		u.clients[client] = c
+	}
+	c.requests++
+	c.lastSeen = now
+	return !ok
+}
+
+// deprecationReport is the usage of everything the spec marks deprecated.
+type deprecationReport struct {
+	// Since is when this process started counting.
+	Since        time.Time         `json:"since"`
+	Deprecations []deprecationItem `json:"deprecations"`
+}
+
+type deprecationItem struct {
+	Method       string     `json:"method"`
+	Path         string     `json:"path"`
+	Kind         string     `json:"kind"`
+	Name         string     `json:"name,omitempty"`
+	DeprecatedAt *time.Time `json:"deprecatedAt,omitempty"`
+	Sunset       *time.Time `json:"sunset,omitempty"`
+	Requests     int64      `json:"requests"`
+	FirstSeen    *time.Time `json:"firstSeen,omitempty"`
+	LastSeen     *time.Time `json:"lastSeen,omitempty"`
+	// Clients are the clients seen, most requests first; requests from
+	// clients beyond maxDeprecationClients are in OtherRequests.
+	Clients       []deprecationClientUsage `json:"clients"`
+	OtherRequests int64                    `json:"otherRequests,omitempty"`
+}
+
+type deprecationClientUsage struct {
+	UserID    int64     `json:"userId,omitempty"`
+	UserAgent string    `json:"userAgent"`
+	Requests  int64     `json:"requests"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+func (t *deprecationTracker) report() deprecationReport {
+	optional := func(v time.Time) *time.Time {
+		if v.IsZero() {
+			return nil
+		}
+		return &v
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := deprecationReport{Since: t.since, Deprecations: make([]deprecationItem, 0, len(t.declared))}
+	for _, d := range t.declared {
+		item := deprecationItem{Method: d.method, Path: d.path, Kind: d.kind, Name: d.name, Clients: []deprecationClientUsage{}}
+		if d.kind == "operation" {
+			item.DeprecatedAt, item.Sunset = optional(d.spec.at), optional(d.spec.sunset)
+		}
+		if u, ok := t.usage[d.deprecationKey]; ok {
+			item.Requests, item.OtherRequests = u.requests, u.otherRequests
+			item.FirstSeen, item.LastSeen = optional(u.firstSeen), optional(u.lastSeen)
+			for client, c := range u.clients {
+				item.Clients = append(item.Clients, deprecationClientUsage{
+					UserID: client.userID, UserAgent: client.userAgent,
+					Requests: c.requests, FirstSeen: c.firstSeen, LastSeen: c.lastSeen,
+				})
+			}
+			slices.SortFunc(item.Clients, func(a, b deprecationClientUsage) int {
+				return cmp.Or(cmp.Compare(b.Requests, a.Requests), b.LastSeen.Compare(a.LastSeen))
+			})
+		}
+		out.Deprecations = append(out.Deprecations, item)
+	}
+	return out
+}
+
+// handleAdminDeprecations reports who used the deprecated operations,
+// query parameters and body fields of the API since the process started.
+// An operation no client used for long enough is safe to remove.
+func (s *Server) handleAdminDeprecations(w http.ResponseWriter, r *http.Request) {
+	if s.deprecations == nil {
+		// The spec marks nothing deprecated.
+		writeJSON(w, http.StatusOK, map[string]any{"deprecations": []deprecationItem{}})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.deprecations.report())
+}
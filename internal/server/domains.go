@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+)
+
+// domainResponse is a custom domain with the TXT record that verifies it.
+type domainResponse struct {
+	db.Domain
+	Verified bool   `json:"verified"`
+	TXTName  string `json:"txtName"`
+	TXTValue string `json:"txtValue"`
+}
+
+func newDomainResponse(d db.Domain) domainResponse {
+	return domainResponse{Domain: d, Verified: d.VerifiedAt != nil, TXTName: db.DomainVerificationPrefix + d.Name, TXTValue: d.Token}
+}
+
+// domainStore returns the backend's custom domain support, writing 501 when
+// missing.
+func (s *Server) domainStore(w http.ResponseWriter) (db.DomainStore, bool) {
+	domains, ok := s.store.(db.DomainStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "custom domains not supported by storage backend")
+	}
+	return domains, ok
+}
+
+func (s *Server) handleAdminListDomains(w http.ResponseWriter, r *http.Request) {
+	domains, ok := s.domainStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := domains.ListDomains(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list domains")
+		return
+	}
+	out := make([]domainResponse, len(items))
+	for i, d := range items {
+		out[i] = newDomainResponse(d)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleAdminAddDomain registers a domain. It is served, and gets a
+// certificate, only once handleAdminVerifyDomain finds the TXT record named
+// in the response.
+func (s *Server) handleAdminAddDomain(w http.ResponseWriter, r *http.Request) {
+	domains, ok := s.domainStore(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	name, err := db.NormalizeDomain(req.Name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	d, err := domains.AddDomain(ctx, name)
+	if errors.Is(err, db.ErrDomainTaken) {
+		writeError(w, http.StatusConflict, "domain already registered")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to add domain")
+		return
+	}
+	writeJSON(w, http.StatusCreated, newDomainResponse(d))
+}
+
+// handleAdminVerifyDomain looks up the domain's TXT record and marks it
+// verified when the token is there.
+func (s *Server) handleAdminVerifyDomain(w http.ResponseWriter, r *http.Request) {
+	domains, ok := s.domainStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	d, err := domains.GetDomain(ctx, chi.URLParam(r, "name"))
+	if errors.Is(err, db.ErrDomainNotFound) {
+		writeError(w, http.StatusNotFound, "domain not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load domain")
+		return
+	}
+	if d.VerifiedAt == nil {
+		records, err := net.DefaultResolver.LookupTXT(ctx, db.DomainVerificationPrefix+d.Name)
+		if err != nil || !slices.Contains(records, d.Token) {
+			slog.InfoContext(ctx, "domain.verify_failed", "domain", d.Name, "error", err)
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("TXT record %s%s does not contain %s", db.DomainVerificationPrefix, d.Name, d.Token))
+			return
+		}
+		if d, err = domains.MarkDomainVerified(ctx, d.Name); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to verify domain")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, newDomainResponse(d))
+}
+
+func (s *Server) handleAdminDeleteDomain(w http.ResponseWriter, r *http.Request) {
+	domains, ok := s.domainStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := domains.DeleteDomain(ctx, chi.URLParam(r, "name")); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete domain")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HostPolicy admits the verified custom domains, for an autocert.Manager:
+// certificates are requested for nothing else.
+func (s *Server) HostPolicy(ctx context.Context, host string) error {
+	domains, ok := s.store.(db.DomainStore)
+	if !ok {
+		return errors.New("custom domains not supported by storage backend")
+	}
+	d, err := domains.GetDomain(ctx, strings.ToLower(host))
+	if err != nil {
+		return fmt.Errorf("host %q: %w", host, err)
+	}
+	if d.VerifiedAt == nil {
+		return fmt.Errorf("host %q is not verified", host)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// streamReconnectDelay is the reconnect delay advertised to streaming
+// clients when the server drains them during shutdown.
+const streamReconnectDelay = 2 * time.Second
+
+// ErrDraining ends a stream because the server is shutting down.
+var ErrDraining = errors.New("server draining")
+
+// streamTracker lets long-lived streaming responses end cleanly on shutdown
+// instead of being cut when the process exits, so rolling deploys look like
+// a planned reconnect to browsers rather than a network error.
+type streamTracker struct {
+	mu       sync.Mutex
+	draining chan struct{}
+	closed   bool
+
+	active  atomic.Int64
+	drained atomic.Int64
+}
+
+func newStreamTracker() *streamTracker {
+	return &streamTracker{draining: make(chan struct{})}
+}
+
+// join registers a stream. The returned channel is closed when the stream
+// must wind down; leave must be called when it has. ok is false once the
+// server has started draining.
+func (t *streamTracker) join() (done <-chan struct{}, leave func(drained bool), ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, nil, false
+	}
+	t.active.Add(1)
+	return t.draining, func(drained bool) {
+		t.active.Add(-1)
+		if drained {
+			t.drained.Add(1)
+		}
+	}, true
+}
+
+// drain signals every open stream to send its restart notice and close.
+func (t *streamTracker) drain() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	close(t.draining)
+	slog.Info("server.streams_draining", "active", t.active.Load())
+}
+
+func (t *streamTracker) isDraining() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// DrainStreams tells connected streaming clients that the server is
+// restarting and closes their streams. Register it with
+// http.Server.RegisterOnShutdown so Shutdown does not wait on them.
+func (s *Server) DrainStreams() {
+	s.streams.drain()
+}
+
+// StreamStats reports how many streams are open and how many were drained.
+func (s *Server) StreamStats() (active, drained int64) {
+	return s.streams.active.Load(), s.streams.drained.Load()
+}
+
+// restartNotice is the final record sent on a drained stream.
+type restartNotice struct {
+	Event            string `json:"event"`
+	ReconnectDelayMs int64  `json:"reconnectDelayMs"`
+}
+
+func newRestartNotice() restartNotice {
+	return restartNotice{Event: "server_restarting", ReconnectDelayMs: streamReconnectDelay.Milliseconds()}
+}
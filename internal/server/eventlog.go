@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"todoapp/internal/blob"
+	"todoapp/internal/db"
+	"todoapp/internal/telemetry"
+)
+
+const (
+	// eventLogFlush is how often buffered events are written out, so a
+	// file on disk lags the event stream by at most this much.
+	eventLogFlush = time.Second
+	// eventLogUploadTimeout bounds the upload of the finished hourly files.
+	eventLogUploadTimeout = 5 * time.Minute
+)
+
+// EventLog configures the export of the live event stream as hourly JSONL
+// files, a change stream for analytics that never queries the database.
+type EventLog struct {
+	// Dir is where the files are written, under YYYY/MM/DD/HH/. With a
+	// Bucket it is only a spool: each file is uploaded to the same key once
+	// its hour is over, then removed.
+	Dir    string
+	Bucket blob.Bucket
+	// Buffer is how many events may wait for the writer (default 4096).
+	// Events beyond it are dropped rather than slowing down writes.
+	Buffer int
+}
+
+// eventRecord is one line of an event log: the event as /api/todos/events
+// streams it, with when it happened and whose todo it was.
+type eventRecord struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	OwnerID int64     `json:"ownerId,omitempty"`
+	Todo    *db.Todo  `json:"todo,omitempty"`
+	ID      int64     `json:"id,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// eventLog writes the events the hub tees to it. Only RunEventLog touches
+// the open file.
+type eventLog struct {
+	config   EventLog
+	ch       chan eventRecord
+	instance string
+	// anonymizedBefore is the cutoff of the last anonymization pass, in
+	// Unix nanoseconds; see scrub.
+	anonymizedBefore atomic.Int64
+
+	hour time.Time
+	path string
+	file *os.File
+	w    *bufio.Writer
+}
+
+// WithEventLog appends every event published to live subscribers to hourly
+// JSONL files, once RunEventLog is started.
+func WithEventLog(c EventLog) Option {
+	return func(s *Server) {
+		if c.Buffer <= 0 {
+			c.Buffer = 4096
+		}
+		s.eventLog = &eventLog{config: c, ch: make(chan eventRecord, c.Buffer), instance: eventLogInstance()}
+	}
+}
+
+// eventLogInstance names this process's files, so replicas sharing a
+// directory or bucket, and restarts within the hour, never write the same
+// one.
+func eventLogInstance() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "todo"
+	}
+	host = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, host)
+	return host + "-" + strconv.FormatInt(time.Now().Unix(), 36)
+}
+
+// offer queues ev for the writer without blocking; the hub calls it with
+// its lock held.
+func (l *eventLog) offer(ev todoEvent) {
+	rec := eventRecord{Time: time.Now().UTC(), Type: ev.Type, Todo: ev.Todo, ID: ev.ID, Message: ev.Message}
+	if ev.scoped {
+		rec.OwnerID = ev.owner
+	}
+	select {
+	case l.ch <- rec:
+	default:
+		telemetry.EventLogEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// anonymize notes an anonymization pass up to cutoff. Records written from
+// now on carry completed todos last updated before it anonymized, as the
+// store now has them; files already written are left as they are.
+func (l *eventLog) anonymize(cutoff time.Time) {
+	if l == nil {
+		return
+	}
+	l.anonymizedBefore.Store(cutoff.UnixNano())
+}
+
+// scrub anonymizes the todo of rec if the last anonymization pass covered
+// it, so events queued before the pass, or carrying an old copy of the
+// todo, do not bring its title back.
+func (l *eventLog) scrub(rec *eventRecord) {
+	cutoff := l.anonymizedBefore.Load()
+	t := rec.Todo
+	if cutoff == 0 || t == nil || !t.Completed || !t.UpdatedAt.Before(time.Unix(0, cutoff)) || strings.HasPrefix(t.Title, db.AnonymizedPrefix) {
+		return
+	}
+	// The todo is shared with live subscribers.
+	scrubbed := *t
+	scrubbed.Title = db.AnonymizedTitle(t.UUID, t.Title)
+	rec.Todo = &scrubbed
+}
+
+// RunEventLog writes the teed events until ctx is cancelled, then writes
+// out what is buffered and, with a bucket, uploads the unfinished hour
+// before returning. Files a previous run left in the spool are uploaded
+// first.
+func (s *Server) RunEventLog(ctx context.Context) {
+	l := s.eventLog
+	if l == nil {
+		return
+	}
+	if l.config.Bucket != nil {
+		l.upload(ctx)
+	}
+	flush := time.NewTicker(eventLogFlush)
+	defer flush.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			l.drain(ctx)
+			l.close(ctx)
+			if l.config.Bucket != nil {
+				l.upload(context.WithoutCancel(ctx))
+			}
+			return
+		case rec := <-l.ch:
+			l.write(ctx, rec)
+		case now := <-flush.C:
+			if l.file != nil && !now.UTC().Truncate(time.Hour).Equal(l.hour) {
+				l.close(ctx)
+				if l.config.Bucket != nil {
+					l.upload(ctx)
+				}
+				continue
+			}
+			l.flush(ctx)
+		}
+	}
+}
+
+// drain writes the events still queued.
+func (l *eventLog) drain(ctx context.Context) {
+	for {
+		select {
+		case rec := <-l.ch:
+			l.write(ctx, rec)
+		default:
+			return
+		}
+	}
+}
+
+// write appends rec to the file of its hour, rotating to it if needed.
+func (l *eventLog) write(ctx context.Context, rec eventRecord) {
+	if hour := rec.Time.Truncate(time.Hour); l.file == nil || !hour.Equal(l.hour) {
+		if l.file != nil {
+			l.close(ctx)
+			if l.config.Bucket != nil {
+				l.upload(ctx)
+			}
+		}
+		if err := l.open(hour); err != nil {
+			telemetry.EventLogEvents.WithLabelValues("failed").Inc()
+			slog.WarnContext(ctx, "event_log.open_failed", "path", l.path, "error", err)
+			return
+		}
+	}
+	l.scrub(&rec)
+	line, err := json.Marshal(rec)
+	if err == nil {
+		line = append(line, '\n')
+		_, err = l.w.Write(line)
+	}
+	if err != nil {
+		telemetry.EventLogEvents.WithLabelValues("failed").Inc()
+		slog.WarnContext(ctx, "event_log.write_failed", "path", l.path, "error", err)
+		return
+	}
+	telemetry.EventLogEvents.WithLabelValues("written").Inc()
+}
+
+func (l *eventLog) open(hour time.Time) error {
+	l.hour = hour
+	l.path = filepath.Join(l.config.Dir, filepath.FromSlash(hour.Format("2006/01/02/15")), l.instance+".jsonl")
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	l.file, l.w = f, bufio.NewWriter(f)
+	return nil
+}
+
+func (l *eventLog) flush(ctx context.Context) {
+	if l.w == nil || l.w.Buffered() == 0 {
+		return
+	}
+	if err := l.w.Flush(); err != nil {
+		slog.WarnContext(ctx, "event_log.write_failed", "path", l.path, "error", err)
+		l.w.Reset(l.file)
+	}
+}
+
+func (l *eventLog) close(ctx context.Context) {
+	if l.file == nil {
+		return
+	}
+	l.flush(ctx)
+	if err := l.file.Close(); err != nil {
+		slog.WarnContext(ctx, "event_log.write_failed", "path", l.path, "error", err)
+	}
+	l.file, l.w = nil, nil
+}
+
+// upload moves every finished file in the spool to the bucket. Files that
+// fail to upload stay, and are retried at the next rotation.
+func (l *eventLog) upload(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, eventLogUploadTimeout)
+	defer cancel()
+	err := filepath.WalkDir(l.config.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".jsonl" || (l.file != nil && path == l.path) {
+			return err
+		}
+		rel, err := filepath.Rel(l.config.Dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if err := l.put(ctx, key, path); err != nil {
+			slog.WarnContext(ctx, "event_log.upload_failed", "key", key, "error", err)
+			return ctx.Err()
+		}
+		slog.DebugContext(ctx, "event_log.uploaded", "key", key)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		// Drop the hour's directories once empty; Remove fails otherwise.
+		for dir := filepath.Dir(path); dir != filepath.Clean(l.config.Dir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.WarnContext(ctx, "event_log.upload_failed", "error", err)
+	}
+}
+
+func (l *eventLog) put(ctx context.Context, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return l.config.Bucket.Put(ctx, key, f, "application/x-ndjson")
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/scheduler"
+	"todoapp/internal/stats"
+)
+
+// Event types published to /api/todos/events subscribers.
+const (
+	eventTodoCreated = "todo.created"
+	eventTodoUpdated = "todo.updated"
+	eventTodoDeleted = "todo.deleted"
+	// eventTodosChanged reports a set-based change; clients reload the list.
+	eventTodosChanged = "todos.changed"
+	// eventRuleNotification carries a rule's notify message about a todo.
+	eventRuleNotification = "rule.notification"
+	// eventTodoReminder carries a reminder that a todo is coming due.
+	eventTodoReminder = "todo.reminder"
+	// eventSubscriptionMatch carries a todo newly matching a subscription.
+	eventSubscriptionMatch = "subscription.match"
+	// eventTodoStale nudges about a todo left untouched for long.
+	eventTodoStale = "todo.stale"
+)
+
+const (
+	// eventBuffer is how many events a subscriber may lag behind before it
+	// is disconnected; EventSource reconnects and the client reloads.
+	eventBuffer = 64
+	// eventHeartbeat keeps idle connections alive through proxies that
+	// close silent streams.
+	eventHeartbeat = 25 * time.Second
+)
+
+// todoEvent is one change. Todo is set for creates and updates, ID for
+// deletes; set-based changes carry neither. Rule notifications, reminders,
+// subscription matches and stale nudges carry the todo and a Message.
+type todoEvent struct {
+	Type    string   `json:"type"`
+	Todo    *db.Todo `json:"todo,omitempty"`
+	ID      int64    `json:"id,omitempty"`
+	Message string   `json:"message,omitempty"`
+
+	owner  int64
+	scoped bool
+}
+
+// eventHub fans todo changes out to live subscribers. Delivery is best
+// effort: it never blocks a write handler, and subscribers that fall too far
+// behind are dropped.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+	// tee, when set, is offered every event, whoever may see it.
+	tee *eventLog
+}
+
+type subscriber struct {
+	ch chan todoEvent
+	// owner and scoped mirror db.OwnerFrom for the subscriber's request.
+	owner  int64
+	scoped bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[*subscriber]struct{})}
+}
+
+func (h *eventHub) subscribe(ctx context.Context) *subscriber {
+	sub := &subscriber{ch: make(chan todoEvent, eventBuffer)}
+	sub.owner, sub.scoped = db.OwnerFrom(ctx)
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// unsubscribe removes sub; it is safe to call after a drop.
+func (h *eventHub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// publish delivers ev to every subscriber allowed to see it, attributing it
+// to the owner ctx is scoped to. Users only receive their own changes;
+// unscoped subscribers (accounts disabled) receive everything.
+func (h *eventHub) publish(ctx context.Context, ev todoEvent) {
+	ev.owner, ev.scoped = db.OwnerFrom(ctx)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tee != nil {
+		h.tee.offer(ev)
+	}
+	for sub := range h.subs {
+		if sub.scoped && (!ev.scoped || ev.owner != sub.owner) {
+			continue
+		}
+		h.deliverLocked(ctx, sub, ev)
+	}
+}
+
+// deliverLocked queues ev for sub, dropping sub if its buffer is full.
+func (h *eventHub) deliverLocked(ctx context.Context, sub *subscriber, ev todoEvent) {
+	select {
+	case sub.ch <- ev:
+	default:
+		delete(h.subs, sub)
+		close(sub.ch)
+		slog.WarnContext(ctx, "todo.events_subscriber_dropped", "buffer", eventBuffer)
+	}
+}
+
+// publish records a todo change made through ctx: it drops the cached list
+// of the user who made it and notifies their live subscribers.
+func (s *Server) publish(ctx context.Context, ev todoEvent) {
+	s.lists.invalidate(ctx)
+	s.events.publish(ctx, ev)
+}
+
+// broadcast delivers ev to every subscriber, for changes spanning all users.
+func (h *eventHub) broadcast(ctx context.Context, ev todoEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tee != nil {
+		h.tee.offer(ev)
+	}
+	for sub := range h.subs {
+		h.deliverLocked(ctx, sub, ev)
+	}
+}
+
+func (s *Server) publishTodo(ctx context.Context, typ string, t db.Todo) {
+	t = s.present(t)
+	s.publish(ctx, todoEvent{Type: typ, Todo: &t})
+}
+
+// PublishReminder delivers r to the live subscribers of the todo's owner as
+// a todo.reminder event; it is a scheduler.NotifierFunc.
+func (s *Server) PublishReminder(ctx context.Context, r scheduler.Reminder) error {
+	if r.Todo.OwnerID != 0 {
+		ctx = db.WithOwner(ctx, r.Todo.OwnerID)
+	}
+	t := s.present(r.Todo)
+	s.events.publish(ctx, todoEvent{Type: eventTodoReminder, Todo: &t, Message: r.String()})
+	return nil
+}
+
+// handleTodoEvents streams todo changes as Server-Sent Events. Every event
+// is named after its type and carries the JSON-encoded todoEvent as data.
+func (s *Server) handleTodoEvents(w http.ResponseWriter, r *http.Request) {
+	done, leave, ok := s.streams.join()
+	if !ok {
+		writeThrottled(w, throttle{Reason: reasonShuttingDown, RetryAfter: streamReconnectDelay}, "server is restarting")
+		return
+	}
+	sub := s.events.subscribe(r.Context())
+	defer s.events.unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	// Tell nginx not to buffer the stream.
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(format string, args ...any) error {
+		// The deadline must outlast the quiet period until the next
+		// heartbeat, not just this write.
+		_ = rc.SetWriteDeadline(time.Now().Add(eventHeartbeat + streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	if err := write("retry: %d\n\n", streamReconnectDelay.Milliseconds()); err != nil {
+		leave(false)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			leave(false)
+			return
+		case <-done:
+			data, _ := json.Marshal(newRestartNotice())
+			_ = write("event: %s\ndata: %s\n\n", newRestartNotice().Event, data)
+			leave(true)
+			return
+		case <-heartbeat.C:
+			err = write(": keepalive\n\n")
+		case ev, open := <-sub.ch:
+			if !open {
+				leave(false)
+				return
+			}
+			data, _ := json.Marshal(ev)
+			err = write("event: %s\ndata: %s\n\n", ev.Type, data)
+			stats.SSEMessages.Inc()
+		}
+		if err != nil {
+			leave(false)
+			return
+		}
+	}
+}
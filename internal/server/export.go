@@ -0,0 +1,425 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+const (
+	// exportFlushRows is how many todos an export writes between flushes.
+	exportFlushRows = 100
+	// maxImportBytes caps an import upload.
+	maxImportBytes = 32 << 20
+)
+
+// exportColumns is the CSV header of an export; import reads the same names
+// and ignores columns it does not know, such as id and uuid.
+var exportColumns = []string{
+	"id", "uuid", "title", "completed", "tags", "durationMinutes", "priorityScore",
+	"dueAt", "startAt", "effort", "reminderOffsets", "recurrence", "createdAt", "updatedAt",
+}
+
+// importRecord is one todo of an import, in the shape todos are exported.
+type importRecord struct {
+	Title           string     `json:"title"`
+	Completed       bool       `json:"completed"`
+	Tags            []string   `json:"tags"`
+	DurationMinutes int        `json:"durationMinutes"`
+	PriorityScore   float64    `json:"priorityScore"`
+	DueAt           *time.Time `json:"dueAt"`
+	StartAt         *time.Time `json:"startAt"`
+	Effort          *int       `json:"effort"`
+	ReminderOffsets []int      `json:"reminderOffsets"`
+	Recurrence      string     `json:"recurrence"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// handleExportTodos streams every todo matching the list filter as a CSV
+// file, a JSON array or a bundle (format=csv|json|bundle, default json),
+// reading and writing one row at a time. Timestamps are in UTC, or in the
+// time zone tz names for CSV and JSON.
+func (s *Server) handleExportTodos(w http.ResponseWriter, r *http.Request) {
+	streamer, ok := s.store.(db.TodoStreamer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "export not supported by storage backend")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "bundle" {
+		writeError(w, http.StatusBadRequest, "format must be csv, json or bundle")
+		return
+	}
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	loc, err := parseRenderZone(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if format == "bundle" && loc != time.UTC {
+		writeError(w, http.StatusBadRequest, "tz is not supported for bundles")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	contentType, ext := "application/json; charset=utf-8", format
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "bundle":
+		contentType, ext = "application/zip", "zip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "todos-" + time.Now().UTC().Format("20060102") + "." + ext,
+	}))
+	w.WriteHeader(http.StatusOK)
+
+	var out io.Writer = w
+	var bundle *exportBundle
+	if format == "bundle" {
+		if bundle, out, err = newExportBundle(w); err != nil {
+			slog.WarnContext(r.Context(), "todo.export_aborted", "format", format, "items", 0, "error", err)
+			return
+		}
+	}
+	write, flush, finish := exportWriter(out, format, loc)
+	count := 0
+	err = streamer.StreamTodos(r.Context(), filter, func(t db.Todo) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		t = s.present(t)
+		if bundle != nil {
+			// Decayed priority changes by the minute; bundles are
+			// deterministic.
+			t.EffectivePriority = nil
+		}
+		if err := write(t); err != nil {
+			return err
+		}
+		if count++; count%exportFlushRows != 0 {
+			return nil
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if bundle != nil {
+			if err := bundle.flush(); err != nil {
+				return err
+			}
+		}
+		return rc.Flush()
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err == nil && bundle != nil {
+		err = bundle.close(count)
+	}
+	if err != nil {
+		// Headers are already sent; the truncated file signals the failure.
+		slog.WarnContext(r.Context(), "todo.export_aborted", "format", format, "items", count, "error", err)
+		return
+	}
+	slog.InfoContext(r.Context(), "todo.exported", "format", format, "items", count)
+}
+
+// exportWriter writes todos to w as a CSV file, or a JSON array for any
+// other format, with their timestamps in loc. flush pushes
+// buffered rows to w mid-stream; finish completes the document.
+func exportWriter(w io.Writer, format string, loc *time.Location) (write func(db.Todo) error, flush, finish func() error) {
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		_ = cw.Write(exportColumns)
+		write = func(t db.Todo) error { return cw.Write(csvRecord(t, loc)) }
+		flush = func() error { cw.Flush(); return cw.Error() }
+		return write, flush, flush
+	}
+	sep := "[\n"
+	write = func(t db.Todo) error {
+		if loc != time.UTC {
+			t = inZone(t, loc)
+		}
+		b, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, sep+string(b))
+		sep = ",\n"
+		return err
+	}
+	flush = func() error { return nil }
+	finish = func() error {
+		end := "\n]\n"
+		if sep == "[\n" {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(w, end)
+		return err
+	}
+	return write, flush, finish
+}
+
+func csvRecord(t db.Todo, loc *time.Location) []string {
+	formatTime := func(v *time.Time) string {
+		if v == nil {
+			return ""
+		}
+		return v.In(loc).Format(time.RFC3339Nano)
+	}
+	effort := ""
+	if t.Effort != nil {
+		effort = strconv.Itoa(*t.Effort)
+	}
+	reminders := make([]string, len(t.ReminderOffsets))
+	for i, m := range t.ReminderOffsets {
+		reminders[i] = strconv.Itoa(m)
+	}
+	return []string{
+		strconv.FormatInt(t.ID, 10),
+		t.UUID,
+		t.Title,
+		strconv.FormatBool(t.Completed),
+		strings.Join(t.Tags, ","),
+		strconv.Itoa(t.DurationMinutes),
+		strconv.FormatFloat(t.PriorityScore, 'f', -1, 64),
+		formatTime(t.DueAt),
+		formatTime(t.StartAt),
+		effort,
+		strings.Join(reminders, ","),
+		t.Recurrence,
+		formatTime(&t.CreatedAt),
+		formatTime(&t.UpdatedAt),
+	}
+}
+
+// handleImportTodos imports todos from a CSV file, JSON array or bundle as
+// produced by export; format=csv|json|bundle, defaulting to the
+// Content-Type. Todos whose
+// title and createdAt match an existing one are skipped. The import is
+// applied in one transaction, so an invalid record imports nothing.
+func (s *Server) handleImportTodos(w http.ResponseWriter, r *http.Request) {
+	importer, ok := s.store.(db.Importer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "import not supported by storage backend")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+		switch mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt {
+		case "text/csv":
+			format = "csv"
+		case "application/zip":
+			format = "bundle"
+		}
+	}
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	defer body.Close()
+	var records []importRecord
+	var err error
+	switch format {
+	case "csv":
+		records, err = readCSVImport(body)
+	case "json":
+		records, err = readJSONImport(body)
+	case "bundle":
+		records, err = readBundleImport(body)
+	default:
+		writeError(w, http.StatusBadRequest, "format must be csv, json or bundle")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(records) == 0 {
+		writeJSON(w, http.StatusOK, db.ImportResult{})
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r.Context(), time.Minute)
+	defer cancel()
+	todos := make([]db.ImportTodo, len(records))
+	var open int64
+	for i, rec := range records {
+		todos[i] = db.ImportTodo{
+			Input: db.SaveTodoInput{
+				Title:           strings.TrimSpace(rec.Title),
+				Completed:       rec.Completed,
+				Tags:            normalizeTags(rec.Tags),
+				DurationMinutes: clampDuration(rec.DurationMinutes),
+				PriorityScore:   rec.PriorityScore,
+				DueAt:           utcTime(rec.DueAt),
+				StartAt:         utcTime(rec.StartAt),
+				Effort:          s.effortInput(rec.Effort, nil),
+				ReminderOffsets: rec.ReminderOffsets,
+				Recurrence:      strings.TrimSpace(rec.Recurrence),
+			},
+			CreatedAt: rec.CreatedAt,
+			UpdatedAt: rec.UpdatedAt,
+		}
+		if !rec.Completed {
+			open++
+		}
+	}
+	if !s.checkTodoLimits(ctx, w, int64(len(todos)), open) {
+		return
+	}
+	res, err := importer.ImportTodos(ctx, todos)
+	var bulkErr *db.BulkError
+	if errors.As(err, &bulkErr) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("record %d: %v", bulkErr.Index+1, bulkErr.Err))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "import failed")
+		return
+	}
+	if res.Imported > 0 {
+		s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func readJSONImport(r io.Reader) ([]importRecord, error) {
+	var records []importRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, errors.New(bodyError(err, "invalid JSON body; expected an array of todos"))
+	}
+	return records, nil
+}
+
+// readCSVImport reads records by header name; only title is required.
+func readCSVImport(r io.Reader) ([]importRecord, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("invalid CSV header")
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[strings.TrimSpace(name)] = i
+	}
+	if _, ok := col["title"]; !ok {
+		return nil, errors.New("CSV header must include a title column")
+	}
+	cr.FieldsPerRecord = len(header)
+
+	var records []importRecord
+	for n := 1; ; n++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", n, err)
+		}
+		rec, err := parseCSVRecord(row, col)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", n, err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func parseCSVRecord(row []string, col map[string]int) (importRecord, error) {
+	get := func(name string) string {
+		if i, ok := col[name]; ok {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	parseTime := func(name string) (*time.Time, error) {
+		v := get(name)
+		if v == "" {
+			return nil, nil
+		}
+		t, err := parseTimestamp(name, v)
+		if err != nil {
+			return nil, err
+		}
+		return &t, nil
+	}
+	splitInts := func(name string) ([]int, error) {
+		var out []int
+		for _, part := range strings.Split(get(name), ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s", name)
+			}
+			out = append(out, n)
+		}
+		return out, nil
+	}
+
+	rec := importRecord{Title: row[col["title"]], Recurrence: get("recurrence")}
+	var err error
+	if v := get("completed"); v != "" {
+		if rec.Completed, err = strconv.ParseBool(v); err != nil {
+			return rec, errors.New("invalid completed")
+		}
+	}
+	if v := get("tags"); v != "" {
+		rec.Tags = strings.Split(v, ",")
+	}
+	if v := get("durationMinutes"); v != "" {
+		if rec.DurationMinutes, err = strconv.Atoi(v); err != nil {
+			return rec, errors.New("invalid durationMinutes")
+		}
+	}
+	if v := get("priorityScore"); v != "" {
+		if rec.PriorityScore, err = strconv.ParseFloat(v, 64); err != nil {
+			return rec, errors.New("invalid priorityScore")
+		}
+	}
+	if v := get("effort"); v != "" {
+		effort, err := strconv.Atoi(v)
+		if err != nil {
+			return rec, errors.New("invalid effort")
+		}
+		rec.Effort = &effort
+	}
+	if rec.ReminderOffsets, err = splitInts("reminderOffsets"); err != nil {
+		return rec, err
+	}
+	if rec.DueAt, err = parseTime("dueAt"); err != nil {
+		return rec, err
+	}
+	if rec.StartAt, err = parseTime("startAt"); err != nil {
+		return rec, err
+	}
+	for name, dst := range map[string]*time.Time{"createdAt": &rec.CreatedAt, "updatedAt": &rec.UpdatedAt} {
+		t, err := parseTime(name)
+		if err != nil {
+			return rec, err
+		}
+		if t != nil {
+			*dst = *t
+		}
+	}
+	return rec, nil
+}
@@ -0,0 +1,46 @@
+package server
+
+import "slices"
+
+// Features names the optional behaviour this server was configured with, for
+// usage reports. It lists switches only, never their settings.
+func (s *Server) Features() []string {
+	var out []string
+	for name, on := range map[string]bool{
+		"accounts":         s.sessions != nil,
+		"access_log":       s.accessLog != nil,
+		"admin_api":        s.adminToken != "",
+		"alerts":           s.alerts != nil,
+		"anonymization":    s.anonymization.After > 0,
+		"api_only":         s.static == nil,
+		"branding":         s.branding != Branding{},
+		"cdn":              s.cdn != nil,
+		"deferred_scoring": s.deferred != nil,
+		"effort_hidden":    s.hideEffort,
+		"event_log":        s.eventLog != nil,
+		"hooks":            s.hooks.Len() > 0,
+		"hypermedia":       s.hypermedia,
+		"id_obfuscation":   s.ids != nil,
+		"inbound_webhooks": s.inbound != nil,
+		"internal_admin":   s.internalAdmin,
+		"list_cache":       s.lists != nil,
+		"live_reload":      s.liveReload,
+		"load_shedding":    s.shedder != nil,
+		"metrics":          s.metrics,
+		"proof_of_work":    s.pow != nil,
+		"rate_limit":       s.ipLimiter != nil || s.userLimiter != nil,
+		"request_journal":  s.journal != nil,
+		"request_signing":  s.signing != nil,
+		"scim":             s.scimToken != "",
+		"slo":              s.objectives != nil,
+		"spec_validation":  s.spec != nil,
+		"status_page":      s.status != nil,
+		"todo_limits":      s.limits.enabled(),
+	} {
+		if on {
+			out = append(out, name)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
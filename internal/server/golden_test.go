@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata/golden")
+
+// goldenResponse is what a golden file records of a response.
+type goldenResponse struct {
+	Status      int             `json:"status"`
+	ContentType string          `json:"contentType"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// TestGoldenResponses compares API responses with the files under
+// testdata/golden, so changes to their shape show up in review. Run with
+// -update to record them again after an intended change.
+func TestGoldenResponses(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	store, err := db.NewBoltStore(filepath.Join(t.TempDir(), "todo.bolt"),
+		db.WithClock(func() time.Time { return now }), db.WithUUIDs(db.SequentialUUIDs()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	// Lists are stamped by the system clock, so theirs are kept out of the
+	// recordings.
+	if _, err := store.CreateList(context.Background(), db.SaveListInput{Name: "Errands"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewServer(store, fstest.MapFS{}, nil).Handler()
+
+	// The steps run in order against one store; later ones see the todos
+	// earlier ones created.
+	steps := []struct {
+		name, method, target, body string
+	}{
+		{"create_todo", http.MethodPost, "/api/todos", `{"title":"Buy milk","tags":["Shopping","shopping"],"durationMinutes":15,"listId":1}`},
+		{"create_todo_due", http.MethodPost, "/api/todos", `{"title":"File taxes","tags":["admin"],"dueAt":"2024-04-15T17:00:00Z","effort":3,"reminderOffsets":[60,1440]}`},
+		{"list_todos", http.MethodGet, "/api/todos", ""},
+		{"list_todos_filtered", http.MethodGet, "/api/todos?tag=admin", ""},
+		{"get_todo", http.MethodGet, "/api/todos/2", ""},
+		{"update_todo", http.MethodPut, "/api/todos/1", `{"title":"Buy oat milk","completed":true,"tags":["shopping"],"durationMinutes":10,"listId":1}`},
+		{"error_not_found", http.MethodGet, "/api/todos/99", ""},
+		{"error_invalid_id", http.MethodGet, "/api/todos/abc", ""},
+		{"error_invalid_json", http.MethodPost, "/api/todos", `{"title":`},
+		{"error_empty_title", http.MethodPost, "/api/todos", `{"title":"  "}`},
+		{"error_unknown_list", http.MethodPost, "/api/todos", `{"title":"Orphan","listId":42}`},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			r := httptest.NewRequest(step.method, step.target, strings.NewReader(step.body))
+			if step.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			got, err := json.MarshalIndent(goldenResponse{
+				Status:      w.Code,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        bytes.TrimSpace(w.Body.Bytes()),
+			}, "", "  ")
+			if err != nil {
+				t.Fatalf("response is not JSON: %v\n%s", err, w.Body)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", "golden", step.name+".json")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v; run with -update to record it", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s %s differs from %s; run with -update if the change is intended\ngot:\n%s\nwant:\n%s",
+					step.method, step.target, path, got, want)
+			}
+		})
+	}
+}
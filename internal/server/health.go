@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// Readiness configures the /ready probe.
+type Readiness struct {
+	// Timeout bounds each dependency check (default 2s).
+	Timeout time.Duration
+	// CheckML adds the ML service to the report. Scoring falls back when it
+	// is down, so it only fails readiness with RequireML.
+	CheckML   bool
+	RequireML bool
+}
+
+// WithReadiness configures which dependencies /ready checks. Without it only
+// the database is checked.
+func WithReadiness(r Readiness) Option {
+	return func(s *Server) {
+		s.readiness = r
+	}
+}
+
+// mlHealth is implemented by scorers that can check their service is up;
+// *mlclient.Client's Warm calls the service's health endpoint.
+type mlHealth interface {
+	Warm(ctx context.Context) error
+}
+
+// dependencyStatus is one dependency's entry in the /ready report.
+type dependencyStatus struct {
+	Status    string `json:"status"` // "up" or "down"
+	Required  bool   `json:"required"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+type readyReport struct {
+	Status string                      `json:"status"` // "ready", "unavailable" or "draining"
+	Checks map[string]dependencyStatus `json:"checks"`
+}
+
+// handleHealth is the liveness probe: the process is up and serving. It
+// checks no dependency, so an outage of one does not get pods restarted.
+func handleHealth(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady is the readiness probe. It checks the dependencies in
+// parallel and answers 503 when a required one is down or the server is
+// shutting down, so load balancers stop routing to the instance.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	report := readyReport{Status: "ready", Checks: s.checkDependencies(r.Context())}
+	status := http.StatusOK
+	for _, st := range report.Checks {
+		if st.Required && st.Status != "up" {
+			report.Status, status = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	if s.streams.isDraining() {
+		report.Status, status = "draining", http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// checkDependencies checks the database, and the ML service when
+// Readiness.CheckML is set, in parallel.
+func (s *Server) checkDependencies(ctx context.Context) map[string]dependencyStatus {
+	timeout := s.readiness.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	checks := map[string]func(context.Context) error{}
+	required := map[string]bool{"database": true}
+	if pinger, ok := s.store.(db.Pinger); ok {
+		checks["database"] = pinger.Ping
+	}
+	if ml, ok := s.scorer.(mlHealth); ok && s.readiness.CheckML {
+		checks["ml"] = ml.Warm
+		required["ml"] = s.readiness.RequireML
+	}
+
+	out := make(map[string]dependencyStatus, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := contextWithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			err := check(ctx)
+			st := dependencyStatus{Status: "up", Required: required[name], LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				slog.WarnContext(ctx, "ready.check_failed", "dependency", name, "error", err)
+				st.Status, st.Error = "down", "unreachable"
+				if errors.Is(err, context.DeadlineExceeded) {
+					st.Error = "timed out"
+				}
+			}
+			mu.Lock()
+			out[name] = st
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"todoapp/internal/db"
+	"todoapp/internal/mlclient"
+	"todoapp/internal/rolling"
+)
+
+// The health score folds the signals operators otherwise alert on one by
+// one into a single number from 0 (down) to 1 (healthy). Each component
+// scores 0 to 1 between a healthy and a failing bound; the score is their
+// weighted average, over the components this deployment has.
+const (
+	// Database ping latency.
+	healthDBGood, healthDBBad = 50 * time.Millisecond, time.Second
+	healthDBWeight            = 0.4
+	// Share of 5xx responses over the last healthErrorWindow, counted in
+	// healthErrorBuckets slices, once at least healthMinRequests were served.
+	healthErrorsGood, healthErrorsBad = 0.0, 0.05
+	healthErrorsWeight                = 0.3
+	healthErrorWindow                 = 5 * time.Minute
+	healthErrorBuckets                = 30
+	healthMinRequests                 = 20
+	// Pending webhook deliveries.
+	healthQueueGood, healthQueueBad = 100, 5000
+	healthQueueWeight               = 0.15
+	// ML client circuit breaker: closed 1, half-open 0.5, open 0.
+	healthMLWeight = 0.15
+)
+
+// Health score statuses, by score.
+const (
+	healthHealthy   = "healthy"   // 0.8 and above
+	healthDegraded  = "degraded"  // 0.5 and above
+	healthUnhealthy = "unhealthy" // below 0.5
+)
+
+// healthComponent is one signal of the health score.
+type healthComponent struct {
+	Score  float64 `json:"score"`
+	Weight float64 `json:"weight"`
+	// Value is the raw signal: seconds, a ratio, a count or a breaker state.
+	Value  float64 `json:"value"`
+	Detail string  `json:"detail"`
+}
+
+type healthScore struct {
+	Score      float64                    `json:"score"`
+	Status     string                     `json:"status"`
+	Components map[string]healthComponent `json:"components"`
+}
+
+// breakerReporter is implemented by scorers with a circuit breaker;
+// *mlclient.Client is one.
+type breakerReporter interface {
+	BreakerState() mlclient.BreakerState
+}
+
+// linearScore maps v to 1 at good or better, 0 at bad or worse and linearly
+// in between.
+func linearScore(v, good, bad float64) float64 {
+	return math.Max(0, math.Min(1, (bad-v)/(bad-good)))
+}
+
+// computeHealthScore checks every component, each within timeout.
+func (s *Server) computeHealthScore(ctx context.Context, timeout time.Duration) healthScore {
+	components := map[string]healthComponent{}
+
+	if pinger, ok := s.store.(db.Pinger); ok {
+		pctx, cancel := contextWithTimeout(ctx, timeout)
+		start := time.Now()
+		err := pinger.Ping(pctx)
+		latency := time.Since(start)
+		cancel()
+		c := healthComponent{Weight: healthDBWeight, Value: latency.Seconds(), Detail: fmt.Sprintf("ping %s", latency.Round(time.Microsecond))}
+		if err != nil {
+			c.Detail = "unreachable"
+		} else {
+			c.Score = linearScore(latency.Seconds(), healthDBGood.Seconds(), healthDBBad.Seconds())
+		}
+		components["database"] = c
+	}
+
+	counts := s.errorRate.sum(time.Now())
+	c := healthComponent{Score: 1, Weight: healthErrorsWeight, Detail: fmt.Sprintf("%d of %d requests failed", counts.Failed, counts.Total)}
+	if counts.Total > 0 {
+		c.Value = float64(counts.Failed) / float64(counts.Total)
+	}
+	if counts.Total >= healthMinRequests {
+		c.Score = linearScore(c.Value, healthErrorsGood, healthErrorsBad)
+	}
+	components["errors"] = c
+
+	if webhooks, ok := s.store.(db.WebhookStore); ok {
+		qctx, cancel := contextWithTimeout(ctx, timeout)
+		pending, err := webhooks.CountPendingDeliveries(qctx)
+		cancel()
+		c := healthComponent{Weight: healthQueueWeight, Value: float64(pending), Detail: fmt.Sprintf("%d deliveries pending", pending)}
+		if err != nil {
+			slog.WarnContext(ctx, "health_score.queue_failed", "error", err)
+			c.Detail = "unknown"
+		} else {
+			c.Score = linearScore(float64(pending), healthQueueGood, healthQueueBad)
+		}
+		components["webhook_queue"] = c
+	}
+
+	if ml, ok := s.scorer.(breakerReporter); ok {
+		state := ml.BreakerState()
+		c := healthComponent{Weight: healthMLWeight, Value: float64(state), Detail: "breaker " + state.String()}
+		switch state {
+		case mlclient.BreakerClosed:
+			c.Score = 1
+		case mlclient.BreakerHalfOpen:
+			c.Score = 0.5
+		}
+		components["ml"] = c
+	}
+
+	var sum, weights float64
+	for _, c := range components {
+		sum += c.Score * c.Weight
+		weights += c.Weight
+	}
+	out := healthScore{Score: 1, Status: healthHealthy, Components: components}
+	if weights > 0 {
+		out.Score = math.Round(sum/weights*1000) / 1000
+	}
+	switch {
+	case out.Score < 0.5:
+		out.Status = healthUnhealthy
+	case out.Score < 0.8:
+		out.Status = healthDegraded
+	}
+	return out
+}
+
+// handleAdminHealthScore reports the health score and its components.
+func (s *Server) handleAdminHealthScore(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.computeHealthScore(r.Context(), 2*time.Second))
+}
+
+// trackErrors counts API responses and 5xx ones for the health score.
+func (s *Server) trackErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		s.errorRate.add(time.Now(), ww.Status() >= http.StatusInternalServerError)
+	})
+}
+
+// errorWindow is a rolling.Window shared by every request.
+type errorWindow struct {
+	mu sync.Mutex
+	w  *rolling.Window
+}
+
+func newErrorWindow(d time.Duration) *errorWindow {
+	return &errorWindow{w: rolling.New(d, healthErrorBuckets)}
+}
+
+func (w *errorWindow) add(now time.Time, failed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.w.Add(now, failed, false)
+}
+
+func (w *errorWindow) sum(now time.Time) rolling.Counts {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Sum(now)
+}
+
+var (
+	healthScoreDesc = prometheus.NewDesc("todo_health_score",
+		"Composite health score from 0 (down) to 1 (healthy).", nil, nil)
+	healthComponentDesc = prometheus.NewDesc("todo_health_component_score",
+		"Health score of one component, from 0 to 1.", []string{"component"}, nil)
+)
+
+// healthCollector computes the health score at scrape time.
+type healthCollector struct{ s *Server }
+
+func (c healthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- healthScoreDesc
+	ch <- healthComponentDesc
+}
+
+func (c healthCollector) Collect(ch chan<- prometheus.Metric) {
+	score := c.s.computeHealthScore(context.Background(), time.Second)
+	ch <- prometheus.MustNewConstMetric(healthScoreDesc, prometheus.GaugeValue, score.Score)
+	for name, comp := range score.Components {
+		ch <- prometheus.MustNewConstMetric(healthComponentDesc, prometheus.GaugeValue, comp.Score, name)
+	}
+}
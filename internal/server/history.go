@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"todoapp/internal/db"
+)
+
+// handleTodoHistory returns the changes recorded for a todo, oldest first.
+// A deleted todo keeps its history; an id with neither is not found.
+func (s *Server) handleTodoHistory(w http.ResponseWriter, r *http.Request) {
+	history, ok := s.store.(db.HistoryStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "todo history not supported by storage backend")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	changes, err := history.TodoHistory(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load todo history")
+		return
+	}
+	if len(changes) == 0 {
+		// Todos created before history was recorded have none.
+		if _, err := s.store.GetTodo(ctx, id); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "todo not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to load todo")
+			return
+		}
+	}
+	for i, c := range changes {
+		if c.Old != nil {
+			old := s.present(*c.Old)
+			changes[i].Old = &old
+		}
+		if c.New != nil {
+			cur := s.present(*c.New)
+			changes[i].New = &cur
+		}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
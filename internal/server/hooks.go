@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"todoapp/internal/db"
+	"todoapp/internal/hooks"
+)
+
+// WithHooks runs the registry's plugins when todos are created, updated and
+// completed through the API, including bulk operations. Imports restore data
+// as exported and skip them.
+func WithHooks(r *hooks.Registry) Option {
+	return func(s *Server) {
+		s.hooks = r
+	}
+}
+
+// runBeforeCreate runs the before-create hooks on input and normalizes what
+// they return. A rejection comes back as a *hooks.Rejection.
+func (s *Server) runBeforeCreate(ctx context.Context, input *db.SaveTodoInput) error {
+	if s.hooks.Len() == 0 {
+		return nil
+	}
+	if err := s.hooks.BeforeCreate(ctx, input); err != nil {
+		var rejection *hooks.Rejection
+		if !errors.As(err, &rejection) {
+			slog.WarnContext(ctx, "hooks.before_create_failed", "error", err)
+		}
+		return err
+	}
+	input.Title = strings.TrimSpace(input.Title)
+	input.Tags = normalizeTags(input.Tags)
+	input.DurationMinutes = clampDuration(input.DurationMinutes)
+	input.DueAt = utcTime(input.DueAt)
+	input.StartAt = utcTime(input.StartAt)
+	return nil
+}
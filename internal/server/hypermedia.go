@@ -0,0 +1,170 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+const (
+	// mediaTypeV2 opts a request into hypermedia responses regardless of the
+	// server-wide setting.
+	mediaTypeV2 = "application/vnd.todo.v2+json"
+	// mediaTypeJSONAPI selects the JSON:API (jsonapi.org) document format.
+	mediaTypeJSONAPI = "application/vnd.api+json"
+)
+
+type link struct {
+	Href   string `json:"href"`
+	Method string `json:"method,omitempty"`
+}
+
+// todoResource is a todo with its hypermedia links and the experimental
+// fields the request opted into.
+type todoResource struct {
+	db.Todo
+	Links            map[string]link   `json:"_links,omitempty"`
+	ScoreExplanation *scoreExplanation `json:"scoreExplanation,omitempty"`
+}
+
+// wantsHypermedia reports whether the response should carry _links.
+func (s *Server) wantsHypermedia(r *http.Request) bool {
+	return s.hypermedia || strings.Contains(r.Header.Get("Accept"), mediaTypeV2) || wantsFeature(r, featureLinks)
+}
+
+// todoResource adds to t the links and experimental fields r asks for;
+// ok is false when it asks for none.
+func (s *Server) todoResource(r *http.Request, t db.Todo, now time.Time) (res todoResource, ok bool) {
+	res.Todo = t
+	if s.wantsHypermedia(r) {
+		res.Links, ok = todoLinks(t.ID), true
+	}
+	if wantsFeature(r, featureScoreExplanation) {
+		res.ScoreExplanation, ok = s.explainScore(t, now), true
+	}
+	return res, ok
+}
+
+// writeResources writes v, holding todoResources, as the v2 media type when
+// they carry links.
+func (s *Server) writeResources(w http.ResponseWriter, r *http.Request, status int, v any) {
+	if s.wantsHypermedia(r) {
+		writeJSONAs(w, status, mediaTypeV2, v)
+		return
+	}
+	writeJSON(w, status, v)
+}
+
+func todoLinks(id int64) map[string]link {
+	self := "/api/todos/" + strconv.FormatInt(id, 10)
+	return map[string]link{
+		"self":       {Href: self},
+		"update":     {Href: self, Method: http.MethodPut},
+		"patch":      {Href: self, Method: http.MethodPatch},
+		"delete":     {Href: self, Method: http.MethodDelete},
+		"collection": {Href: "/api/todos/"},
+	}
+}
+
+func wantsJSONAPI(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), mediaTypeJSONAPI)
+}
+
+// jsonAPIResource is a JSON:API resource object.
+type jsonAPIResource struct {
+	Type       string                     `json:"type"`
+	ID         string                     `json:"id"`
+	Attributes map[string]json.RawMessage `json:"attributes"`
+	Links      map[string]string          `json:"links"`
+}
+
+// jsonAPIDocument is a top-level JSON:API document; Data holds one resource
+// or a slice of them.
+type jsonAPIDocument struct {
+	Data  any               `json:"data"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// toJSONAPI converts a todo into a resource object. Attributes are derived
+// from the regular JSON encoding so new Todo fields appear automatically.
+func toJSONAPI(t db.Todo) (jsonAPIResource, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return jsonAPIResource{}, err
+	}
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return jsonAPIResource{}, err
+	}
+	delete(attrs, "id")
+	id := strconv.FormatInt(t.ID, 10)
+	return jsonAPIResource{
+		Type:       "todos",
+		ID:         id,
+		Attributes: attrs,
+		Links:      map[string]string{"self": "/api/todos/" + id},
+	}, nil
+}
+
+// writeTodo writes a single todo in the negotiated representation.
+func (s *Server) writeTodo(w http.ResponseWriter, r *http.Request, status int, t db.Todo) {
+	t = s.present(t)
+	w.Header().Set("ETag", todoETag(t))
+	if wantsJSONAPI(r) {
+		res, err := toJSONAPI(t)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to encode todo")
+			return
+		}
+		writeJSONAs(w, status, mediaTypeJSONAPI, jsonAPIDocument{Data: res})
+		return
+	}
+	res, ok := s.todoResource(r, t, time.Now())
+	if !ok {
+		writeJSON(w, status, t)
+		return
+	}
+	s.writeResources(w, r, status, res)
+}
+
+// writeTodos writes a todo list in the negotiated representation.
+func (s *Server) writeTodos(w http.ResponseWriter, r *http.Request, status int, items []db.Todo) {
+	if s.hideEffort || s.priorityHalfLife > 0 {
+		shown := make([]db.Todo, len(items))
+		for i, t := range items {
+			shown[i] = s.present(t)
+		}
+		items = shown
+	}
+	if wantsJSONAPI(r) {
+		data := make([]jsonAPIResource, 0, len(items))
+		for _, t := range items {
+			res, err := toJSONAPI(t)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to encode todos")
+				return
+			}
+			data = append(data, res)
+		}
+		writeJSONAs(w, status, mediaTypeJSONAPI, jsonAPIDocument{
+			Data:  data,
+			Links: map[string]string{"self": r.URL.RequestURI()},
+		})
+		return
+	}
+	now := time.Now()
+	out := make([]todoResource, 0, len(items))
+	for _, t := range items {
+		res, ok := s.todoResource(r, t, now)
+		if !ok {
+			writeJSON(w, status, items)
+			return
+		}
+		out = append(out, res)
+	}
+	s.writeResources(w, r, status, out)
+}
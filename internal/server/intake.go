@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+)
+
+// intakePath prefixes the intake hook tokens in request paths.
+const intakePath = "/api/hooks/"
+
+// redactPath hides an intake hook token in path, so it stays out of logs
+// and traces.
+func redactPath(path string) string {
+	if strings.HasPrefix(path, intakePath) {
+		return intakePath + "{token}"
+	}
+	return path
+}
+
+// redactTokens hides intake hook tokens from the access log, which logs
+// RequestURI. Routing uses URL, so it is unaffected.
+func redactTokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, intakePath) {
+			r.RequestURI = redactPath(r.URL.Path)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type intakeHookRequest struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled"`
+}
+
+func (r intakeHookRequest) input() db.SaveIntakeHookInput {
+	return db.SaveIntakeHookInput{
+		Name:     r.Name,
+		Template: r.Template,
+		Enabled:  r.Enabled == nil || *r.Enabled,
+	}
+}
+
+// intakeHookStore returns the backend's intake hook support, writing 501
+// when missing.
+func (s *Server) intakeHookStore(w http.ResponseWriter) (db.IntakeHookStore, bool) {
+	hooks, ok := s.store.(db.IntakeHookStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "intake hooks not supported by storage backend")
+	}
+	return hooks, ok
+}
+
+func (s *Server) handleListIntakeHooks(w http.ResponseWriter, r *http.Request) {
+	hooks, ok := s.intakeHookStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := hooks.ListIntakeHooks(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list intake hooks")
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateIntakeHook creates an intake hook. Its token is in the
+// response and never shown again.
+func (s *Server) handleCreateIntakeHook(w http.ResponseWriter, r *http.Request) {
+	hooks, ok := s.intakeHookStore(w)
+	if !ok {
+		return
+	}
+	var req intakeHookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	hook, err := hooks.CreateIntakeHook(ctx, req.input())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+func (s *Server) handleUpdateIntakeHook(w http.ResponseWriter, r *http.Request) {
+	hooks, ok := s.intakeHookStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req intakeHookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	hook, err := hooks.UpdateIntakeHook(ctx, id, req.input())
+	if err != nil {
+		if errors.Is(err, db.ErrIntakeHookNotFound) {
+			writeError(w, http.StatusNotFound, "intake hook not found")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, hook)
+}
+
+func (s *Server) handleDeleteIntakeHook(w http.ResponseWriter, r *http.Request) {
+	hooks, ok := s.intakeHookStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := hooks.DeleteIntakeHook(ctx, id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRotateIntakeHookToken gives an intake hook a new token, e.g. after
+// the old one leaked.
+func (s *Server) handleRotateIntakeHookToken(w http.ResponseWriter, r *http.Request) {
+	hooks, ok := s.intakeHookStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	hook, err := hooks.RotateIntakeHookToken(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrIntakeHookNotFound) {
+			writeError(w, http.StatusNotFound, "intake hook not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to rotate token")
+		return
+	}
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// handleIntake creates a todo for the owner of the intake hook the token in
+// the path identifies, from the JSON body mapped through the hook's
+// template. The token is the only credential, so the route sits outside
+// requireUser.
+func (s *Server) handleIntake(w http.ResponseWriter, r *http.Request) {
+	hooks, ok := s.intakeHookStore(w)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "payload too large")
+		return
+	}
+	if !json.Valid(body) {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	hook, err := hooks.UseIntakeHook(ctx, chi.URLParam(r, "token"))
+	if err != nil {
+		if errors.Is(err, db.ErrIntakeHookNotFound) {
+			writeError(w, http.StatusNotFound, "intake hook not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load intake hook")
+		return
+	}
+	if !hook.Enabled {
+		writeError(w, http.StatusForbidden, "intake hook disabled")
+		return
+	}
+	if hook.OwnerID != 0 {
+		ctx = db.WithOwner(ctx, hook.OwnerID)
+	}
+	mapped, err := hook.Map(body)
+	if err != nil {
+		slog.WarnContext(ctx, "intake.map_failed", "hook_id", hook.ID, "error", err)
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	var req createTodoRequest
+	if err := json.Unmarshal(mapped, &req); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "payload does not map to a todo")
+		return
+	}
+	slog.InfoContext(ctx, "intake.received", "hook_id", hook.ID)
+	s.createTodo(ctx, w, r, req.input())
+}
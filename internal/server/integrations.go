@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+	"todoapp/internal/inbound"
+)
+
+func (s *Server) handleInboundWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.inbound == nil {
+		writeError(w, http.StatusNotFound, "integrations disabled")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "payload too large")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	duplicate, err := s.inbound.Receive(ctx, chi.URLParam(r, "provider"), r.Header, body)
+	switch {
+	case errors.Is(err, inbound.ErrUnknownProvider):
+		writeError(w, http.StatusNotFound, "unknown provider")
+	case errors.Is(err, inbound.ErrInvalidSignature):
+		writeError(w, http.StatusUnauthorized, "invalid signature")
+	case err != nil:
+		slog.ErrorContext(ctx, "inbound.failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to process webhook")
+	case duplicate:
+		// Acknowledge replays so the provider stops retrying.
+		writeJSON(w, http.StatusOK, map[string]string{"status": "duplicate"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "accepted"})
+	}
+}
+
+type webhookDeliveryResponse struct {
+	db.WebhookDelivery
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (s *Server) handleAdminInboundWebhooks(w http.ResponseWriter, r *http.Request) {
+	deliveries, ok := s.store.(db.WebhookDeliveryStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "not supported by storage backend")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := deliveries.ListWebhookDeliveries(ctx, r.URL.Query().Get("provider"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list deliveries")
+		return
+	}
+	out := make([]webhookDeliveryResponse, 0, len(items))
+	for _, d := range items {
+		payload := json.RawMessage(d.Payload)
+		if !json.Valid(payload) {
+			payload, _ = json.Marshal(string(d.Payload))
+		}
+		out = append(out, webhookDeliveryResponse{WebhookDelivery: d, Payload: payload})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
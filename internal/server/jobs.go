@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/telemetry"
+)
+
+// Background job types, the job label of the job metrics.
+const (
+	jobWebhookDelivery = "webhook_delivery" // one delivery attempt
+	jobRescore         = "rescore"          // one re-scoring pass
+	jobStatsRollup     = "stats_rollup"     // one stats rollup refresh
+	jobDueSoonRules    = "due_soon_rules"   // one due_soon rule pass
+	jobReport          = "report"           // one report export
+	jobSubscriptions   = "subscriptions"    // one subscription check
+	jobAnonymize       = "anonymize"        // one retention anonymization pass
+	jobStaleNudges     = "stale_nudges"     // one stale todo check
+	jobDeferredScores  = "deferred_scores"  // one batch of deferred scores
+)
+
+// jobStats summarises the runs of one job type since the server started.
+type jobStats struct {
+	Job      string `json:"job"`
+	InFlight int    `json:"inFlight"`
+	// Pending is the number of queued jobs, for job types with a queue.
+	Pending  *int  `json:"pending,omitempty"`
+	Runs     int64 `json:"runs"`
+	Failures int64 `json:"failures"`
+	// AverageMS and MaxMS cover the finished runs.
+	AverageMS      float64    `json:"averageMs"`
+	MaxMS          int64      `json:"maxMs"`
+	LastStartedAt  *time.Time `json:"lastStartedAt,omitempty"`
+	LastFinishedAt *time.Time `json:"lastFinishedAt,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+
+	total time.Duration
+}
+
+// jobTracker counts background job runs per type for /api/admin/jobs/stats
+// and the job metrics.
+type jobTracker struct {
+	mu   sync.Mutex
+	jobs map[string]*jobStats
+}
+
+func newJobTracker() *jobTracker {
+	return &jobTracker{jobs: map[string]*jobStats{}}
+}
+
+// start records a run of job starting; the returned func records its end.
+func (t *jobTracker) start(job string) func(error) {
+	begin := time.Now()
+	telemetry.JobsInFlight.WithLabelValues(job).Inc()
+	t.mu.Lock()
+	st := t.jobs[job]
+	if st == nil {
+		st = &jobStats{Job: job}
+		t.jobs[job] = st
+	}
+	st.InFlight++
+	started := begin.UTC()
+	st.LastStartedAt = &started
+	t.mu.Unlock()
+
+	return func(err error) {
+		took := time.Since(begin)
+		telemetry.JobsInFlight.WithLabelValues(job).Dec()
+		telemetry.JobDuration.WithLabelValues(job, telemetry.Outcome(err)).Observe(took.Seconds())
+		if err != nil {
+			telemetry.JobsFailed.WithLabelValues(job).Inc()
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		st.InFlight--
+		st.Runs++
+		st.total += took
+		st.MaxMS = max(st.MaxMS, took.Milliseconds())
+		finished := time.Now().UTC()
+		st.LastFinishedAt = &finished
+		if err != nil {
+			st.Failures++
+			st.LastError = err.Error()
+		}
+	}
+}
+
+// snapshot returns the stats of every job type that ran, by type.
+func (t *jobTracker) snapshot() []jobStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]jobStats, 0, len(t.jobs))
+	for _, st := range t.jobs {
+		c := *st
+		if c.Runs > 0 {
+			c.AverageMS = float64(c.total.Microseconds()) / float64(c.Runs) / 1000
+		}
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b jobStats) int { return strings.Compare(a.Job, b.Job) })
+	return out
+}
+
+// pendingDeliveries counts the queued webhook deliveries; ok is false when
+// the backend has no queue.
+func (s *Server) pendingDeliveries(ctx context.Context) (n int, ok bool) {
+	webhooks, ok := s.store.(db.WebhookStore)
+	if !ok {
+		return 0, false
+	}
+	n, err := webhooks.CountPendingDeliveries(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "jobs.pending_failed", "job", jobWebhookDelivery, "error", err)
+		return 0, false
+	}
+	return n, true
+}
+
+// handleAdminJobStats reports background job runs per type since the
+// server started, with the webhook delivery queue depth.
+func (s *Server) handleAdminJobStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	writeJSON(w, http.StatusOK, struct {
+		Jobs []jobStats `json:"jobs"`
+	}{s.jobStats(ctx)})
+}
+
+// jobStats returns the stats of every job type that ran, with the queue
+// depth of webhook deliveries.
+func (s *Server) jobStats(ctx context.Context) []jobStats {
+	jobs := s.jobs.snapshot()
+	if n, ok := s.pendingDeliveries(ctx); ok {
+		i, found := slices.BinarySearchFunc(jobs, jobWebhookDelivery, func(j jobStats, job string) int { return strings.Compare(j.Job, job) })
+		if !found {
+			jobs = slices.Insert(jobs, i, jobStats{Job: jobWebhookDelivery})
+		}
+		jobs[i].Pending = &n
+	}
+	return jobs
+}
@@ -0,0 +1,336 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	// maxJournalBody is the largest request body recorded; larger ones are
+	// journaled without it and cannot be replayed.
+	maxJournalBody = 64 << 10
+	// journalRedacted replaces the values of sensitive fields.
+	journalRedacted = "[redacted]"
+)
+
+// journalHeaders are the only request headers recorded; credentials,
+// cookies, signatures and proof-of-work stamps never are.
+var journalHeaders = []string{"Accept", "Content-Type", "If-Match"}
+
+// RequestJournal configures the request journal.
+type RequestJournal struct {
+	// Size is how many requests are kept, oldest dropped first.
+	Size int
+	// ReplayURL is the base URL of the staging instance requests are
+	// replayed against, and ReplayToken the bearer token sent with them.
+	// Replay is disabled without a URL.
+	ReplayURL   string
+	ReplayToken string
+}
+
+// Validate checks the replay target.
+func (j RequestJournal) Validate() error {
+	if j.Size <= 0 {
+		return errors.New("journal size must be positive")
+	}
+	if j.ReplayURL == "" {
+		return nil
+	}
+	u, err := url.Parse(j.ReplayURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid journal replay url %q", j.ReplayURL)
+	}
+	return nil
+}
+
+// WithRequestJournal keeps the last Size mutating API requests in memory,
+// sanitized, for admins to inspect under /api/admin/journal and replay
+// against a staging instance. Journaled bodies hold user data, so the
+// journal is off unless configured. j must have been validated.
+func WithRequestJournal(j RequestJournal) Option {
+	return func(s *Server) {
+		s.journal = &requestJournal{config: j, entries: make([]journalEntry, j.Size)}
+	}
+}
+
+// journalEntry is one recorded request.
+type journalEntry struct {
+	ID         int64             `json:"id"`
+	Time       time.Time         `json:"time"`
+	RequestID  string            `json:"requestId,omitempty"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Query      string            `json:"query,omitempty"`
+	Header     map[string]string `json:"header"`
+	Body       string            `json:"body,omitempty"`
+	Truncated  bool              `json:"truncated,omitempty"`
+	Status     int               `json:"status"`
+	DurationMs int64             `json:"durationMs"`
+}
+
+// requestJournal is a ring buffer of journalEntry.
+type requestJournal struct {
+	config RequestJournal
+
+	mu      sync.Mutex
+	entries []journalEntry
+	lastID  int64
+}
+
+func (j *requestJournal) add(e journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.lastID++
+	e.ID = j.lastID
+	j.entries[(e.ID-1)%int64(len(j.entries))] = e
+}
+
+func (j *requestJournal) get(id int64) (journalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if id <= 0 || id > j.lastID {
+		return journalEntry{}, false
+	}
+	e := j.entries[(id-1)%int64(len(j.entries))]
+	return e, e.ID == id
+}
+
+// list returns up to limit entries, newest first.
+func (j *requestJournal) list(limit int) []journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := []journalEntry{}
+	for id := j.lastID; id > 0 && len(out) < limit; id-- {
+		e := j.entries[(id-1)%int64(len(j.entries))]
+		if e.ID != id {
+			break
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// journaled reports whether r is recorded: mutating API requests, except
+// those carrying credentials or tokens of their own (auth, admin, SCIM,
+// intake hooks) and signed inbound webhooks, which cannot be replayed.
+func journaled(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return false
+	}
+	path := r.URL.Path
+	if !strings.HasPrefix(path, "/api/") {
+		return false
+	}
+	for _, prefix := range []string{"/api/auth/", "/api/admin/", "/api/hooks/", "/api/integrations/", scimPath + "/"} {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// sensitiveField reports whether a JSON or query key names a credential.
+func sensitiveField(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "password") || strings.Contains(key, "secret") || strings.Contains(key, "token")
+}
+
+// redactJSON replaces the values of sensitive fields anywhere in v.
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			if sensitiveField(k) {
+				v[k] = journalRedacted
+			} else {
+				v[k] = redactJSON(x)
+			}
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = redactJSON(x)
+		}
+	}
+	return v
+}
+
+// sanitizeBody redacts a JSON body; other bodies are kept as they are.
+func sanitizeBody(body []byte) string {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	out, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
+
+func sanitizeQuery(q url.Values) string {
+	for k := range q {
+		if sensitiveField(k) {
+			q[k] = []string{journalRedacted}
+		}
+	}
+	return q.Encode()
+}
+
+// recordRequest journals the requests journaled selects, with their outcome.
+func (s *Server) recordRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !journaled(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		e := journalEntry{
+			Time:      time.Now().UTC(),
+			RequestID: middleware.GetReqID(r.Context()),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Query:     sanitizeQuery(r.URL.Query()),
+			Header:    map[string]string{},
+		}
+		for _, h := range journalHeaders {
+			if v := r.Header.Get(h); v != "" {
+				e.Header[h] = v
+			}
+		}
+		if r.Body != nil {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxJournalBody+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			switch {
+			case err != nil || len(body) > maxJournalBody:
+				e.Truncated = true
+			case len(body) > 0:
+				e.Body = sanitizeBody(body)
+			}
+		}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		e.Status = ww.Status()
+		if e.Status == 0 {
+			e.Status = http.StatusOK
+		}
+		e.DurationMs = time.Since(e.Time).Milliseconds()
+		s.journal.add(e)
+	})
+}
+
+// requireJournal writes 404 when the journal is disabled.
+func (s *Server) requireJournal(w http.ResponseWriter) bool {
+	if s.journal == nil {
+		writeError(w, http.StatusNotFound, "request journal not enabled")
+		return false
+	}
+	return true
+}
+
+// handleAdminJournal lists journaled requests, newest first (?limit=,
+// default 50).
+func (s *Server) handleAdminJournal(w http.ResponseWriter, r *http.Request) {
+	if !s.requireJournal(w) {
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, s.journal.list(limit))
+}
+
+func (s *Server) journalEntry(w http.ResponseWriter, r *http.Request) (journalEntry, bool) {
+	if !s.requireJournal(w) {
+		return journalEntry{}, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return journalEntry{}, false
+	}
+	e, ok := s.journal.get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "journal entry not found")
+	}
+	return e, ok
+}
+
+func (s *Server) handleAdminJournalEntry(w http.ResponseWriter, r *http.Request) {
+	if e, ok := s.journalEntry(w, r); ok {
+		writeJSON(w, http.StatusOK, e)
+	}
+}
+
+// handleAdminReplayJournal sends a journaled request to the configured
+// staging instance, authenticated with the replay token, and reports its
+// response. Redacted fields are sent as recorded, so requests that relied
+// on them fail there.
+func (s *Server) handleAdminReplayJournal(w http.ResponseWriter, r *http.Request) {
+	e, ok := s.journalEntry(w, r)
+	if !ok {
+		return
+	}
+	cfg := s.journal.config
+	if cfg.ReplayURL == "" {
+		writeError(w, http.StatusNotImplemented, "journal replay target not configured")
+		return
+	}
+	if e.Truncated {
+		writeError(w, http.StatusUnprocessableEntity, "request body was not recorded")
+		return
+	}
+	target := strings.TrimSuffix(cfg.ReplayURL, "/") + e.Path
+	if e.Query != "" {
+		target += "?" + e.Query
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, e.Method, target, strings.NewReader(e.Body))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build replay request")
+		return
+	}
+	for k, v := range e.Header {
+		req.Header.Set(k, v)
+	}
+	if cfg.ReplayToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.ReplayToken)
+	}
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "journal.replay_failed", "id", e.ID, "error", err)
+		writeError(w, http.StatusBadGateway, "replay target unreachable")
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxJournalBody))
+	slog.InfoContext(ctx, "journal.replayed", "id", e.ID, "method", e.Method, "path", e.Path, "status", resp.StatusCode)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":     e.ID,
+		"status": resp.StatusCode,
+		"body":   string(body),
+	})
+}
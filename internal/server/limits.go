@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"todoapp/internal/db"
+)
+
+// TodoLimits bounds how many todos may exist. Zero disables a limit. Until
+// user accounts exist the limits apply to the whole deployment.
+type TodoLimits struct {
+	MaxOpen  int64
+	MaxTotal int64
+}
+
+func (l TodoLimits) enabled() bool {
+	return l.MaxOpen > 0 || l.MaxTotal > 0
+}
+
+// LimitError reports that creating todos would exceed a TodoLimits limit.
+type LimitError struct {
+	Reason string
+	Limit  int64
+}
+
+func (e *LimitError) Error() string { return e.Reason }
+
+// checkTodoLimits writes a 422 response and returns false when creating
+// total more todos, open of them incomplete, would exceed a limit.
+func (s *Server) checkTodoLimits(ctx context.Context, w http.ResponseWriter, total, open int64) bool {
+	if err := s.todoLimitError(ctx, total, open); err != nil {
+		writeLimitError(w, err.Reason, err.Limit)
+		return false
+	}
+	return true
+}
+
+// todoLimitError returns the limit creating total more todos, open of them
+// incomplete, would exceed. The check and the insert are not atomic, so
+// concurrent creates may overshoot by a few items; this is a guardrail, not
+// a quota.
+func (s *Server) todoLimitError(ctx context.Context, total, open int64) *LimitError {
+	if !s.limits.enabled() {
+		return nil
+	}
+	counter, ok := s.store.(db.TodoCounter)
+	if !ok {
+		return nil
+	}
+	counts, err := counter.CountTodos(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "limits.count_failed", "error", err)
+		return nil
+	}
+	if s.limits.MaxTotal > 0 && counts.Total+total > s.limits.MaxTotal {
+		return &LimitError{Reason: "total todo limit reached", Limit: s.limits.MaxTotal}
+	}
+	if s.limits.MaxOpen > 0 && counts.Open+open > s.limits.MaxOpen {
+		return &LimitError{Reason: "open todo limit reached", Limit: s.limits.MaxOpen}
+	}
+	return nil
+}
+
+func writeLimitError(w http.ResponseWriter, msg string, limit int64) {
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": msg, "limit": limit})
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"todoapp/internal/db"
+)
+
+// WithListCache keeps the encoded default todo list (GET /api/todos with no
+// filters, sort, view or hypermedia) of up to maxEntries users in memory.
+// Each hit is checked against the store's db.ListVersion, so writes from
+// other replicas are picked up; writes through this server drop the entry
+// straight away. Backends without db.ListVersioner are never cached.
+func WithListCache(maxEntries int) Option {
+	return func(s *Server) {
+		if maxEntries > 0 {
+			s.lists = &listCache{maxEntries: maxEntries, entries: make(map[int64]listCacheEntry)}
+		}
+	}
+}
+
+// listCache maps an owner (0 when accounts are disabled) to their encoded
+// default list. A nil *listCache is a valid, disabled cache.
+type listCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[int64]listCacheEntry
+}
+
+type listCacheEntry struct {
+	version db.ListVersion
+	body    []byte
+}
+
+func ownerKey(ctx context.Context) int64 {
+	id, _ := db.OwnerFrom(ctx)
+	return id
+}
+
+func (c *listCache) get(ctx context.Context, v db.ListVersion) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[ownerKey(ctx)]
+	if !ok || e.version != v {
+		return nil, false
+	}
+	return e.body, true
+}
+
+func (c *listCache) put(ctx context.Context, v db.ListVersion, body []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := ownerKey(ctx)
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		// Evict an arbitrary entry; a miss only costs one regular list.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = listCacheEntry{version: v, body: body}
+}
+
+// invalidate drops the entry of the owner ctx is scoped to. Unscoped writes
+// (accounts disabled) share key 0 with unscoped reads, so that entry covers
+// them too.
+func (c *listCache) invalidate(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, ownerKey(ctx))
+}
+
+// clear drops every entry, after changes spanning all users.
+func (c *listCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// serveCachedList writes the default list from the cache, or lists, encodes
+// and caches it. It reports false without writing anything when the fast
+// path does not apply, leaving the request to the regular handler.
+func (s *Server) serveCachedList(ctx context.Context, w http.ResponseWriter, r *http.Request, filter db.TodoFilter) bool {
+	// Decayed priorities change with time alone, which the version misses.
+	if s.lists == nil || s.priorityHalfLife > 0 || wantsJSONAPI(r) || s.wantsHypermedia(r) || wantsFeature(r, featureScoreExplanation) {
+		return false
+	}
+	versioner, ok := s.store.(db.ListVersioner)
+	if !ok {
+		return false
+	}
+	lister, ok := s.store.(db.FilteredLister)
+	if !ok {
+		return false
+	}
+	v, err := versioner.ListVersion(ctx, filter.AvailableAt)
+	if err != nil {
+		return false
+	}
+	// A deferred todo becoming available changes the list without a write;
+	// it also moves NextStart on, so the version still changes.
+	body, hit := s.lists.get(ctx, v)
+	if !hit {
+		items, err := lister.ListTodosFiltered(ctx, filter)
+		if err != nil {
+			return false
+		}
+		for i, t := range items {
+			items[i] = s.present(t)
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(true)
+		if err := enc.Encode(items); err != nil {
+			return false
+		}
+		body = buf.Bytes()
+		s.lists.put(ctx, v, body)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+	return true
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/mlclient"
+)
+
+// fixedScorer scores every todo the same.
+type fixedScorer float64
+
+func (f fixedScorer) Score(context.Context, mlclient.TodoPayload) (float64, error) {
+	return float64(f), nil
+}
+
+// newCachingServer serves a SQLite store whose clock stands still, so a
+// write through the server need not change the db.ListVersion and only
+// dropping the cached entry keeps the list fresh.
+func newCachingServer(tb testing.TB, opts ...Option) (*Server, *db.SQLStore) {
+	tb.Helper()
+	long := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	store, err := db.NewSQLiteStore(filepath.Join(tb.TempDir(), "todo.sqlite"), db.WithClock(func() time.Time { return long }))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = store.Close() })
+	return NewServer(store, fstest.MapFS{}, fixedScorer(0.9), opts...), store
+}
+
+func serve(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func (c *listCache) cached(ctx context.Context) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[ownerKey(ctx)]
+	return ok
+}
+
+func TestListCacheInvalidation(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		change func(t *testing.T, s *Server, store *db.SQLStore, todo db.Todo)
+		want   func(todos []db.Todo) bool
+	}{
+		{
+			name: "create",
+			change: func(t *testing.T, s *Server, _ *db.SQLStore, _ db.Todo) {
+				if w := serve(s.Handler(), http.MethodPost, "/api/todos", `{"title":"call the plumber"}`); w.Code != http.StatusCreated {
+					t.Fatalf("create: %d %s", w.Code, w.Body)
+				}
+			},
+			want: func(todos []db.Todo) bool { return len(todos) == 2 },
+		},
+		{
+			name: "update",
+			change: func(t *testing.T, s *Server, _ *db.SQLStore, todo db.Todo) {
+				if w := serve(s.Handler(), http.MethodPut, fmt.Sprintf("/api/todos/%d", todo.ID), `{"title":"see the dentist","completed":true}`); w.Code != http.StatusOK {
+					t.Fatalf("update: %d %s", w.Code, w.Body)
+				}
+			},
+			want: func(todos []db.Todo) bool { return len(todos) == 1 && todos[0].Title == "see the dentist" },
+		},
+		{
+			name: "delete",
+			change: func(t *testing.T, s *Server, _ *db.SQLStore, todo db.Todo) {
+				if w := serve(s.Handler(), http.MethodDelete, fmt.Sprintf("/api/todos/%d", todo.ID), ""); w.Code != http.StatusNoContent {
+					t.Fatalf("delete: %d %s", w.Code, w.Body)
+				}
+			},
+			want: func(todos []db.Todo) bool { return len(todos) == 0 },
+		},
+		{
+			name: "rescore",
+			change: func(t *testing.T, s *Server, _ *db.SQLStore, _ db.Todo) {
+				if res, err := s.rescore(context.Background(), true); err != nil || res.Changed != 1 {
+					t.Fatalf("rescore: %+v, %v", res, err)
+				}
+			},
+			want: func(todos []db.Todo) bool {
+				return len(todos) == 1 && todos[0].PriorityScore == 0.9
+			},
+		},
+		{
+			name: "anonymize",
+			change: func(t *testing.T, s *Server, store *db.SQLStore, _ db.Todo) {
+				if res, err := s.anonymize(context.Background(), store, false); err != nil || res.Todos != 1 {
+					t.Fatalf("anonymize: %+v, %v", res, err)
+				}
+			},
+			want: func(todos []db.Todo) bool {
+				return len(todos) == 1 && strings.HasPrefix(todos[0].Title, db.AnonymizedPrefix)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, store := newCachingServer(t, WithListCache(10), WithAnonymization(Anonymization{After: time.Hour}))
+			ctx := context.Background()
+			todo, err := store.CreateTodo(ctx, db.SaveTodoInput{Title: "see the doctor", Completed: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			list := func() []db.Todo {
+				t.Helper()
+				w := serve(s.Handler(), http.MethodGet, "/api/todos", "")
+				var todos []db.Todo
+				if err := json.Unmarshal(w.Body.Bytes(), &todos); err != nil {
+					t.Fatalf("list: %d %s", w.Code, w.Body)
+				}
+				return todos
+			}
+
+			if got := list(); len(got) != 1 || !s.lists.cached(ctx) {
+				t.Fatalf("first list: %+v, cached %v", got, s.lists.cached(ctx))
+			}
+			tc.change(t, s, store, todo)
+			if s.lists.cached(ctx) {
+				t.Error("the cached list survived the change")
+			}
+			if got := list(); !tc.want(got) {
+				t.Errorf("list after the change: %+v", got)
+			}
+		})
+	}
+}
+
+func BenchmarkListTodos(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"uncached", nil},
+		{"cached", []Option{WithListCache(10)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s, store := newCachingServer(b, bc.opts...)
+			for i := range 200 {
+				in := db.SaveTodoInput{Title: fmt.Sprintf("todo %d", i), Tags: []string{"work", "home"}[i%2:][:1]}
+				if _, err := store.CreateTodo(context.Background(), in); err != nil {
+					b.Fatal(err)
+				}
+			}
+			h := s.Handler()
+			if w := serve(h, http.MethodGet, "/api/todos", ""); w.Code != http.StatusOK {
+				b.Fatalf("list: %d %s", w.Code, w.Body)
+			}
+			b.ResetTimer()
+			for range b.N {
+				if w := serve(h, http.MethodGet, "/api/todos", ""); w.Code != http.StatusOK {
+					b.Fatalf("list: %d", w.Code)
+				}
+			}
+		})
+	}
+}
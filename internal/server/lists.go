@@ -0,0 +1,434 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+)
+
+type listRequest struct {
+	Name             string   `json:"name"`
+	Color            string   `json:"color"`
+	Icon             string   `json:"icon"`
+	DefaultTags      []string `json:"defaultTags"`
+	DefaultReminders []int    `json:"defaultReminders"`
+}
+
+func (r listRequest) input() db.SaveListInput {
+	return db.SaveListInput{Name: r.Name, Color: r.Color, Icon: r.Icon, DefaultTags: r.DefaultTags, DefaultReminders: r.DefaultReminders}
+}
+
+// listStore returns the backend's list support, writing 501 when missing.
+func (s *Server) listStore(w http.ResponseWriter) (db.ListStore, bool) {
+	lists, ok := s.store.(db.ListStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "lists not supported by storage backend")
+	}
+	return lists, ok
+}
+
+// handleListLists lists the lists, archived ones only with
+// ?includeArchived=true.
+func (s *Server) handleListLists(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := lists.ListLists(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list lists")
+		return
+	}
+	if r.URL.Query().Get("includeArchived") != "true" {
+		items = slices.DeleteFunc(items, func(l db.List) bool { return l.Archived })
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) handleListIcons(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, db.ListIcons)
+}
+
+func (s *Server) handleGetList(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	l, err := lists.GetList(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrListNotFound) {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load list")
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+func (s *Server) handleCreateList(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	var req listRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	l, err := lists.CreateList(ctx, req.input())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, l)
+}
+
+func (s *Server) handleUpdateList(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req listRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	l, err := lists.UpdateList(ctx, id, req.input())
+	if err != nil {
+		if errors.Is(err, db.ErrListNotFound) {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		if errors.Is(err, db.ErrListLocked) {
+			writeError(w, http.StatusLocked, err.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+// handleDeleteList deletes a list. Its todos are left in no list, or with
+// ?todos=delete deleted, or with ?todos=move&to={id} moved to another list.
+func (s *Server) handleDeleteList(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var todos db.ListDeletion
+	q := r.URL.Query()
+	switch q.Get("todos") {
+	case "", "unassign":
+	case "delete":
+		todos.DeleteTodos = true
+	case "move":
+		if todos.MoveTo, err = strconv.ParseInt(q.Get("to"), 10, 64); err != nil || todos.MoveTo <= 0 || todos.MoveTo == id {
+			writeError(w, http.StatusBadRequest, "invalid target list")
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, `todos must be "unassign", "delete" or "move"`)
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := lists.DeleteList(ctx, id, todos); err != nil {
+		switch {
+		case errors.Is(err, db.ErrListLocked):
+			writeError(w, http.StatusLocked, err.Error())
+		case errors.Is(err, db.ErrListNotFound):
+			writeError(w, http.StatusBadRequest, "target list not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to delete")
+		}
+		return
+	}
+	// The list's todos were deleted, moved or unassigned.
+	s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListListTodos lists the todos of a list, taking the filters of
+// GET /api/todos.
+func (s *Server) handleListListTodos(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.ListID = id
+	if r.URL.Query().Get("includeDeferred") != "true" {
+		filter.AvailableAt = time.Now()
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if _, err := lists.GetList(ctx, id); err != nil {
+		if errors.Is(err, db.ErrListNotFound) {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load list")
+		return
+	}
+	items, err := s.ListTodos(ctx, filter)
+	if err != nil {
+		if errors.Is(err, ErrFilterUnsupported) {
+			writeError(w, http.StatusNotImplemented, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to list todos")
+		return
+	}
+	s.writeTodos(w, r, http.StatusOK, items)
+}
+
+// handleCreateListTodo creates a todo in a list, as POST /api/todos with
+// listId set, applying the list's defaults: its default tags are added to
+// the todo's, and its default reminders apply unless the todo sets
+// reminderOffsets (an empty array for none).
+func (s *Server) handleCreateListTodo(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req createTodoRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, bodyError(err, "invalid JSON body"))
+		return
+	}
+	req.ListID = &id
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	l, err := lists.GetList(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrListNotFound) {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load list")
+		return
+	}
+	req.Tags = append(slices.Clone(l.DefaultTags), req.Tags...)
+	if req.ReminderOffsets == nil {
+		req.ReminderOffsets = l.DefaultReminders
+	}
+	s.createTodo(ctx, w, r, req.input())
+}
+
+// handleArchiveList archives (PUT) or restores (DELETE) a list. Archived
+// lists and their todos drop out of GET /api/lists and GET /api/todos
+// unless includeArchived=true; the list's own routes keep working.
+func (s *Server) handleArchiveList(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	l, err := lists.ArchiveList(ctx, id, r.Method == http.MethodPut)
+	if err != nil {
+		if errors.Is(err, db.ErrListNotFound) {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to archive list")
+		return
+	}
+	// The list's todos left or rejoined the default view.
+	s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	writeJSON(w, http.StatusOK, l)
+}
+
+// handleDuplicateList copies a list with its open todos. The body may name
+// the copy ({"name": "..."}); it defaults to the original's name with
+// " (copy)".
+func (s *Server) handleDuplicateList(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if s.limits.enabled() {
+		open := false
+		items, err := s.ListTodos(ctx, db.TodoFilter{ListID: id, Completed: &open})
+		if err == nil && !s.checkTodoLimits(ctx, w, int64(len(items)), int64(len(items))) {
+			return
+		}
+	}
+	l, n, err := lists.DuplicateList(ctx, id, req.Name)
+	if err != nil {
+		if errors.Is(err, db.ErrListNotFound) {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if n > 0 {
+		s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	}
+	writeJSON(w, http.StatusCreated, struct {
+		db.List
+		Todos int `json:"todos"`
+	}{l, n})
+}
+
+// handleAdminListHold places (PUT) or lifts (DELETE) a legal hold on any
+// user's list.
+func (s *Server) handleAdminListHold(w http.ResponseWriter, r *http.Request) {
+	lists, ok := s.listStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	l, err := lists.SetListHold(ctx, id, r.Method == http.MethodPut)
+	if err != nil {
+		if errors.Is(err, db.ErrListNotFound) {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to update legal hold")
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+// handleMoveTodo moves a todo to the list named by the body's listId, or out
+// of its list when listId is null. Unlike a PUT with a new listId, the move
+// is recorded as such in the todo's history and carries the todo's place in
+// the lists' manual orderings along.
+func (s *Server) handleMoveTodo(w http.ResponseWriter, r *http.Request) {
+	mover, ok := s.store.(db.TodoMover)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "moving todos not supported by storage backend")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req struct {
+		ListID json.RawMessage `json:"listId"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if req.ListID == nil {
+		writeError(w, http.StatusBadRequest, "listId is required; null moves the todo out of its list")
+		return
+	}
+	var listID *int64
+	if err := json.Unmarshal(req.ListID, &listID); err != nil || (listID != nil && *listID <= 0) {
+		writeError(w, http.StatusBadRequest, "invalid listId")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	existing, err := s.store.GetTodo(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "todo not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load todo")
+		return
+	}
+	if !ifMatch(r.Header.Get("If-Match"), existing) {
+		writeError(w, http.StatusPreconditionFailed, "todo was modified; reload and retry")
+		return
+	}
+	item, err := mover.MoveTodo(ctx, id, listID)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			writeError(w, http.StatusNotFound, "todo not found")
+		case errors.Is(err, db.ErrListNotFound):
+			writeError(w, http.StatusBadRequest, "list not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to move todo")
+		}
+		return
+	}
+	if item.UpdatedAt.Equal(existing.UpdatedAt) {
+		// Already in that list.
+		s.writeTodo(w, r, http.StatusOK, item)
+		return
+	}
+	item = s.todoUpdated(ctx, existing, item)
+	s.publishTodo(ctx, eventTodoUpdated, item)
+	s.writeTodo(w, r, http.StatusOK, item)
+}
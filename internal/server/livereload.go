@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+const (
+	liveReloadScript = "/_livereload.js"
+	// liveReloadEvents ends in /events so it is treated as a long-lived
+	// stream by load shedding, SLOs and metrics.
+	liveReloadEvents = "/_livereload/events"
+	// liveReloadPoll is how often a connected page's files are checked.
+	liveReloadPoll = 500 * time.Millisecond
+)
+
+// liveReloadJS reloads the page once the server reports a changed file.
+const liveReloadJS = `new EventSource('` + liveReloadEvents + `').addEventListener('reload', () => location.reload())
+`
+
+// WithLiveReload makes the served frontend reload itself in the browser
+// whenever one of its files changes. It is meant for frontend development
+// against a static directory on disk and should stay off in production.
+func WithLiveReload(enabled bool) Option {
+	return func(s *Server) {
+		s.liveReload = enabled
+	}
+}
+
+func serveLiveReloadScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = io.WriteString(w, liveReloadJS)
+}
+
+// handleLiveReload polls the frontend's files for as long as the page stays
+// connected and sends a "reload" event after the first change.
+func (s *Server) handleLiveReload(w http.ResponseWriter, r *http.Request) {
+	done, leave, ok := s.streams.join()
+	if !ok {
+		writeThrottled(w, throttle{Reason: reasonShuttingDown, RetryAfter: streamReconnectDelay}, "server is restarting")
+		return
+	}
+	defer leave(false)
+	initial := staticFingerprint(s.static)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	write := func(format string, args ...any) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(eventHeartbeat + streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	if write("retry: %d\n\n", streamReconnectDelay.Milliseconds()) != nil {
+		return
+	}
+
+	poll := time.NewTicker(liveReloadPoll)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-done:
+			return
+		case <-heartbeat.C:
+			if write(": keepalive\n\n") != nil {
+				return
+			}
+		case <-poll.C:
+			if staticFingerprint(s.static) != initial {
+				_ = write("event: reload\ndata: {}\n\n")
+				return
+			}
+		}
+	}
+}
+
+// staticFingerprint summarizes the names, sizes and modification times of
+// every file in fsys; it changes whenever a file is added, removed or saved.
+func staticFingerprint(fsys fs.FS) string {
+	var b bytes.Buffer
+	_ = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return b.String()
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"todoapp/internal/db"
+	"todoapp/internal/logging"
+	"todoapp/internal/slo"
+	"todoapp/internal/telemetry"
+)
+
+// WithMetrics serves Prometheus metrics at /metrics: on the admin handler
+// when the admin API is internal, otherwise on the public one. The endpoint
+// is unauthenticated, like the /debug profiles.
+func WithMetrics(enabled bool) Option {
+	return func(s *Server) {
+		s.metrics = enabled
+	}
+}
+
+// WithPushedMetrics registers the server's own metrics for an exporter that
+// pushes Registry elsewhere (StatsD), without serving /metrics.
+func WithPushedMetrics(enabled bool) Option {
+	return func(s *Server) {
+		s.pushMetrics = enabled
+	}
+}
+
+// registerBusinessMetrics exports the todo counts for the admin API's
+// /metrics.
+func (s *Server) registerBusinessMetrics() {
+	counter, ok := s.store.(db.TodoCounter)
+	if !ok {
+		return
+	}
+	if err := telemetry.Business.Register(businessCollector{counter}); err != nil {
+		slog.Warn("metrics.register_failed", "error", err)
+	}
+}
+
+// instrument traces each request, continuing a trace propagated by the
+// caller, and counts and times it by chi route pattern so metric labels stay
+// bounded. Streaming responses are counted but not timed.
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := telemetry.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", redactPath(r.URL.Path)),
+			))
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsValid() {
+			ctx = logging.With(ctx, "trace_id", sc.TraceID().String())
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := "unmatched"
+		if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+			route = rc.RoutePattern()
+		}
+		status := ww.Status()
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(attribute.String("http.route", route), attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		telemetry.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		if !isLongLived(r.URL.Path) {
+			telemetry.HTTPDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		}
+	})
+}
+
+// registerMetrics exports the server's own state: open streams, the load
+// shedding limit, SLO burn rates, the health score and the webhook
+// delivery queue depth.
+func (s *Server) registerMetrics() {
+	collectors := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "todo_streams_active",
+			Help: "Open streaming responses (NDJSON and Server-Sent Events).",
+		}, func() float64 { return float64(s.streams.active.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "todo_streams_drained_total",
+			Help: "Streams closed with a restart notice during shutdown.",
+		}, func() float64 { return float64(s.streams.drained.Load()) }),
+	}
+	if s.shedder != nil {
+		collectors = append(collectors,
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "todo_load_shedding_limit",
+				Help: "Current adaptive concurrency limit for API requests.",
+			}, func() float64 { limit, _ := s.shedder.snapshot(); return limit }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "todo_load_shedding_inflight",
+				Help: "API requests currently holding a concurrency slot.",
+			}, func() float64 { _, inflight := s.shedder.snapshot(); return float64(inflight) }),
+		)
+	}
+	if s.objectives != nil {
+		collectors = append(collectors, sloCollector{s})
+	}
+	collectors = append(collectors, healthCollector{s})
+	if _, ok := s.store.(db.WebhookStore); ok {
+		collectors = append(collectors, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "todo_jobs_pending",
+			Help:        "Queued background jobs waiting to run, by job type.",
+			ConstLabels: prometheus.Labels{"job": jobWebhookDelivery},
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			n, _ := s.pendingDeliveries(ctx)
+			return float64(n)
+		}))
+	}
+	for _, c := range collectors {
+		if err := telemetry.Registry.Register(c); err != nil {
+			slog.Warn("metrics.register_failed", "error", err)
+		}
+	}
+}
+
+var (
+	todosOpenDesc = prometheus.NewDesc("todo_todos_open",
+		"Todos not completed yet.", nil, nil)
+	todosOverdueDesc = prometheus.NewDesc("todo_todos_overdue",
+		"Open todos past their due time.", nil, nil)
+)
+
+// businessCollector counts the todos of every user at scrape time.
+type businessCollector struct{ counter db.TodoCounter }
+
+func (c businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- todosOpenDesc
+	ch <- todosOverdueDesc
+}
+
+func (c businessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	counts, err := c.counter.CountTodos(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(todosOpenDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(todosOpenDesc, prometheus.GaugeValue, float64(counts.Open))
+	ch <- prometheus.MustNewConstMetric(todosOverdueDesc, prometheus.GaugeValue, float64(counts.Overdue))
+}
+
+var (
+	sloBudgetDesc = prometheus.NewDesc("todo_slo_error_budget_remaining",
+		"Share of the error budget left over the SLO period.", []string{"group", "sli"}, nil)
+	sloBurnDesc = prometheus.NewDesc("todo_slo_burn_rate",
+		"Error budget burn rate; 1 spends the budget exactly over the period.", []string{"group", "sli", "window"}, nil)
+)
+
+// sloCollector reads the SLO summary at scrape time.
+type sloCollector struct{ s *Server }
+
+func (c sloCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sloBudgetDesc
+	ch <- sloBurnDesc
+}
+
+func (c sloCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, rep := range c.s.objectives.Summary() {
+		for sli, v := range map[string]slo.SLI{"availability": rep.Availability, "latency": rep.Latency} {
+			if v.Target == 0 {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(sloBudgetDesc, prometheus.GaugeValue, v.BudgetRemaining, rep.Group, sli)
+			ch <- prometheus.MustNewConstMetric(sloBurnDesc, prometheus.GaugeValue, v.BurnRateFast, rep.Group, sli, "5m")
+			ch <- prometheus.MustNewConstMetric(sloBurnDesc, prometheus.GaugeValue, v.BurnRateSlow, rep.Group, sli, "1h")
+		}
+	}
+}
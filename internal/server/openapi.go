@@ -0,0 +1,21 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes the public API. It is maintained by hand next to the
+// handlers: a route or request field added here must be added there too.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// handleOpenAPI serves the OpenAPI 3 document, for client generators and API
+// explorers. It needs no session, so tools can fetch it before logging in.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	s.cacheAtEdge(w, cdnTagAPIDocs)
+	_, _ = w.Write(openAPISpec)
+}
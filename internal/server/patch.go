@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+)
+
+// todoETag identifies a todo's version by its updated_at. Background
+// re-scoring leaves updated_at alone, so it does not invalidate tags that
+// clients hold for If-Match.
+func todoETag(t db.Todo) string {
+	return `"` + strconv.FormatInt(t.UpdatedAt.UnixNano(), 36) + `"`
+}
+
+// ifMatch reports whether an If-Match header allows writing over t. An
+// absent header always does; weak tags never match, since If-Match uses
+// strong comparison.
+func ifMatch(header string, t db.Todo) bool {
+	if header == "" {
+		return true
+	}
+	etag := todoETag(t)
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// saveTodo writes input over existing and reports whether it succeeded,
+// having written the error response otherwise. Requests with If-Match are
+// applied conditionally on the version already checked, so a write racing
+// in between is still rejected; backends without conditional updates only
+// get that check.
+func (s *Server) saveTodo(ctx context.Context, w http.ResponseWriter, r *http.Request, existing db.Todo, input db.SaveTodoInput) (db.Todo, bool) {
+	var item db.Todo
+	var err error
+	if cu, ok := s.store.(db.ConditionalUpdater); ok && r.Header.Get("If-Match") != "" {
+		item, err = cu.UpdateTodoIfUnmodified(ctx, existing.ID, input, existing.UpdatedAt)
+	} else {
+		item, err = s.store.UpdateTodo(ctx, existing.ID, input)
+	}
+	switch {
+	case errors.Is(err, db.ErrPreconditionFailed):
+		writeError(w, http.StatusPreconditionFailed, "todo was modified; reload and retry")
+		return db.Todo{}, false
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, http.StatusNotFound, "todo not found")
+		return db.Todo{}, false
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return db.Todo{}, false
+	}
+	return item, true
+}
+
+// handlePatchTodo applies a JSON merge patch (RFC 7396): only the fields
+// present change, and null clears the optional ones. Read-only fields such
+// as id and createdAt are ignored, so a fetched todo can be sent back whole.
+func (s *Server) handlePatchTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, 1<<20)
+	defer body.Close()
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&fields); err != nil || fields == nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	existing, err := s.store.GetTodo(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "todo not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load todo")
+		return
+	}
+	if !ifMatch(r.Header.Get("If-Match"), existing) {
+		writeError(w, http.StatusPreconditionFailed, "todo was modified; reload and retry")
+		return
+	}
+
+	input := db.SaveTodoInput{
+		Title:           existing.Title,
+		Completed:       existing.Completed,
+		Tags:            existing.Tags,
+		DurationMinutes: existing.DurationMinutes,
+		PriorityScore:   existing.PriorityScore,
+		DueAt:           existing.DueAt,
+		StartAt:         existing.StartAt,
+		Effort:          existing.Effort,
+		ReminderOffsets: existing.ReminderOffsets,
+		Recurrence:      existing.Recurrence,
+		ListID:          existing.ListID,
+	}
+	rescore, err := applyPatch(&input, fields)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	input.Effort = s.effortInput(input.Effort, existing.Effort)
+	if rescore {
+		input.PriorityScore = s.computePriority(ctx, priorityCandidate{
+			Title:           input.Title,
+			Completed:       input.Completed,
+			Tags:            input.Tags,
+			DurationMinutes: input.DurationMinutes,
+			CreatedAt:       existing.CreatedAt,
+		}, existing.PriorityScore)
+	}
+
+	item, ok := s.saveTodo(ctx, w, r, existing, input)
+	if !ok {
+		return
+	}
+	item = s.todoUpdated(ctx, existing, item)
+	s.publishTodo(ctx, eventTodoUpdated, item)
+	s.writeTodo(w, r, http.StatusOK, item)
+}
+
+// applyPatch overlays the patch fields onto input, normalizing them as the
+// full update does. It reports whether a field the priority score depends
+// on was given.
+func applyPatch(input *db.SaveTodoInput, fields map[string]json.RawMessage) (rescore bool, err error) {
+	for name, raw := range fields {
+		switch name {
+		case "title", "completed", "durationMinutes":
+			if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				return false, fmt.Errorf("%s must not be null", name)
+			}
+		}
+		// Each field decodes into a fresh value: input still shares slices
+		// and pointers with the stored todo.
+		switch name {
+		case "title":
+			input.Title, err = patchField[string](name, raw)
+			rescore = true
+		case "completed":
+			input.Completed, err = patchField[bool](name, raw)
+			rescore = true
+		case "tags":
+			input.Tags, err = patchField[[]string](name, raw)
+			rescore = true
+		case "durationMinutes":
+			input.DurationMinutes, err = patchField[int](name, raw)
+			rescore = true
+		case "dueAt":
+			input.DueAt, err = patchField[*time.Time](name, raw)
+		case "startAt":
+			input.StartAt, err = patchField[*time.Time](name, raw)
+		case "effort":
+			input.Effort, err = patchField[*int](name, raw)
+		case "reminderOffsets":
+			input.ReminderOffsets, err = patchField[[]int](name, raw)
+		case "recurrence":
+			input.Recurrence, err = patchField[string](name, raw)
+		case "listId":
+			input.ListID, err = patchField[*int64](name, raw)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	input.Title = strings.TrimSpace(input.Title)
+	input.Recurrence = strings.TrimSpace(input.Recurrence)
+	input.Tags = normalizeTags(input.Tags)
+	input.DurationMinutes = clampDuration(input.DurationMinutes)
+	input.DueAt = utcTime(input.DueAt)
+	input.StartAt = utcTime(input.StartAt)
+	return rescore, nil
+}
+
+func patchField[T any](name string, raw json.RawMessage) (T, error) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		if _, ok := timestampError(err); ok {
+			return v, fmt.Errorf("%s must be an RFC 3339 timestamp with a time zone, e.g. %s", name, timestampExample)
+		}
+		return v, fmt.Errorf("invalid %s", name)
+	}
+	return v, nil
+}
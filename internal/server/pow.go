@@ -0,0 +1,123 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"math/bits"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// powChallengeTTL is how long an issued challenge may be redeemed.
+const powChallengeTTL = 2 * time.Minute
+
+// proofOfWork is a hashcash-style gate for anonymous endpoints. Clients
+// receive a signed challenge with a 428 response and retry with
+// "X-PoW: <challenge>:<nonce>", where SHA-256("<challenge>:<nonce>") starts
+// with at least difficulty zero bits. Challenges are stateless (HMAC-signed
+// and timestamped); redeemed ones are remembered until they expire so a
+// solution cannot be replayed.
+type proofOfWork struct {
+	difficulty int
+	secret     []byte
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func newProofOfWork(difficulty int) *proofOfWork {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return &proofOfWork{difficulty: difficulty, secret: secret, used: make(map[string]time.Time)}
+}
+
+func (p *proofOfWork) issue() string {
+	payload := make([]byte, 24)
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Unix()))
+	_, _ = rand.Read(payload[8:])
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(p.sign(payload))
+}
+
+func (p *proofOfWork) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// verify checks the signature, age, work and single use of a solution.
+func (p *proofOfWork) verify(solution string) bool {
+	challenge, nonce, ok := strings.Cut(solution, ":")
+	if !ok || nonce == "" || len(nonce) > 32 {
+		return false
+	}
+	rawPayload, rawSig, ok := strings.Cut(challenge, ".")
+	if !ok {
+		return false
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(rawPayload)
+	if err != nil || len(payload) != 24 {
+		return false
+	}
+	sig, err := enc.DecodeString(rawSig)
+	if err != nil || !hmac.Equal(sig, p.sign(payload)) {
+		return false
+	}
+	issued := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	expires := issued.Add(powChallengeTTL)
+	if time.Now().After(expires) {
+		return false
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(solution))) < p.difficulty {
+		return false
+	}
+	return p.redeem(challenge, expires)
+}
+
+func (p *proofOfWork) redeem(challenge string, expires time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for c, exp := range p.used {
+		if now.After(exp) {
+			delete(p.used, c)
+		}
+	}
+	if _, seen := p.used[challenge]; seen {
+		return false
+	}
+	p.used[challenge] = expires
+	return true
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// requireProofOfWork guards an anonymous endpoint when proof of work is enabled.
+func (s *Server) requireProofOfWork(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.pow == nil || s.pow.verify(r.Header.Get("X-PoW")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeJSON(w, http.StatusPreconditionRequired, map[string]any{
+			"error":      "proof of work required",
+			"challenge":  s.pow.issue(),
+			"difficulty": s.pow.difficulty,
+		})
+	})
+}
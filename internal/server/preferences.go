@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// preferences returns the preferences of the owner ctx is scoped to; none
+// when the backend keeps none or they fail to load, so scoring goes on.
+func (s *Server) preferences(ctx context.Context) db.Preferences {
+	prefs, ok := s.store.(db.PreferenceStore)
+	if !ok {
+		return db.Preferences{}
+	}
+	p, err := prefs.Preferences(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "preferences.load_failed", "error", err)
+		return db.Preferences{}
+	}
+	return p
+}
+
+// preferenceStore returns the backend's preference support, writing 501
+// when missing.
+func (s *Server) preferenceStore(w http.ResponseWriter) (db.PreferenceStore, bool) {
+	prefs, ok := s.store.(db.PreferenceStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "preferences not supported by storage backend")
+	}
+	return prefs, ok
+}
+
+func (s *Server) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, ok := s.preferenceStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	p, err := prefs.Preferences(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load preferences")
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// handleSetPreferences replaces the caller's preferences. Tag boosts apply
+// to scores computed from then on; stored scores follow on the next
+// rescore.
+func (s *Server) handleSetPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, ok := s.preferenceStore(w)
+	if !ok {
+		return
+	}
+	var req db.Preferences
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	p, err := prefs.SetPreferences(ctx, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	slog.InfoContext(ctx, "preferences.updated", "tag_boosts", len(p.TagBoosts), "locale", p.Locale)
+	writeJSON(w, http.StatusOK, p)
+}
@@ -0,0 +1,499 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"todoapp/internal/idcodec"
+)
+
+// maxIDTranslatedBody caps the request bodies whose ids are translated;
+// imports are the largest.
+const maxIDTranslatedBody = 32 << 20
+
+// idKind is the kind of record an id belongs to. Each kind has its own
+// codec domain, so a todo and a list with the same id get unrelated public
+// ids.
+type idKind int
+
+const (
+	noIDs idKind = iota
+	todoIDs
+	listIDs
+	snapshotIDs
+)
+
+// idDomains name the codec domains of the kinds.
+var idDomains = map[idKind]string{todoIDs: "todo", listIDs: "list", snapshotIDs: "snapshot"}
+
+// idFields are the JSON fields holding ids of one kind wherever they
+// appear. "id" and "ids" hold those of the record they are in.
+var idFields = map[string]idKind{"todoId": todoIDs, "listId": listIDs, "listIds": listIDs, "snapshot": snapshotIDs, "backup": snapshotIDs}
+
+// recordFields are the JSON fields holding records, or arrays of them, of
+// one kind. Records nested under other fields are of the kind of the one
+// they are in.
+var recordFields = map[string]idKind{"todo": todoIDs, "todos": todoIDs, "old": todoIDs, "new": todoIDs, "list": listIDs, "lists": listIDs}
+
+// WithIDCodec spells the ids of todos, lists and list snapshots through
+// codec wherever the API shows them: in URLs, query parameters, and JSON,
+// event stream and NDJSON bodies, links included. Each kind is spelled in
+// its own domain of codec. Raw numeric ids are refused, so the ids of other
+// users' records cannot be enumerated. Handlers and storage keep working
+// with int64 ids; the OpenAPI document still describes them as integers,
+// and CSV exports and outgoing webhooks carry them unchanged.
+func WithIDCodec(codec idcodec.Domains) Option {
+	return func(s *Server) {
+		s.ids = make(map[idKind]idcodec.Codec, len(idDomains))
+		for kind, domain := range idDomains {
+			s.ids[kind] = codec.Domain(domain)
+		}
+	}
+}
+
+// translateIDs decodes the public ids of an API request before routing,
+// and encodes those of its response. The request as the client sent it is
+// kept for checking its signature.
+func (s *Server) translateIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasPublicIDs(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path, ok := s.decodePath(r.URL.Path)
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		var body []byte
+		if r.Body != nil && r.ContentLength != 0 && isJSON(r.Header.Get("Content-Type")) {
+			data, err := io.ReadAll(io.LimitReader(r.Body, maxIDTranslatedBody+1))
+			r.Body.Close()
+			if err != nil || len(data) > maxIDTranslatedBody {
+				writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			body = data
+		}
+		r = keepSigned(r, body)
+
+		r.URL.Path, r.URL.RawPath = path, ""
+		q := r.URL.Query()
+		keys := []string{"listId"}
+		if strings.HasPrefix(path, "/api/lists/") {
+			// DELETE /api/lists/{id}?to= moves the todos to another list.
+			keys = append(keys, "to")
+		}
+		for _, key := range keys {
+			if !q.Has(key) {
+				continue
+			}
+			id, err := s.ids[listIDs].Decode(q.Get(key))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+key)
+				return
+			}
+			q.Set(key, strconv.FormatInt(id, 10))
+			r.URL.RawQuery = q.Encode()
+		}
+		if body != nil {
+			data, err := s.decodeBodyIDs(path, body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			r.ContentLength = int64(len(data))
+			r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+		}
+		rec := &idRecorder{ResponseWriter: w, s: s, kind: bodyIDKind(path)}
+		next.ServeHTTP(rec, r)
+		rec.finish()
+	})
+}
+
+// hasPublicIDs reports whether path is an API route whose ids are
+// translated: all of them but the OpenAPI document and the receivers of
+// third-party webhooks, whose payloads are checked as sent.
+func hasPublicIDs(path string) bool {
+	return strings.HasPrefix(path, "/api/") && path != "/api/openapi.json" && !strings.HasPrefix(path, "/api/integrations/")
+}
+
+// pathIDKind returns the kind of the id at segments[i] of an API path
+// split on "/": "", "api", the tree, then ids and route names.
+func pathIDKind(segments []string, i int) idKind {
+	switch {
+	case segments[2] == "todos" && i == 3:
+		return todoIDs
+	case segments[2] == "lists" && i == 3:
+		return listIDs
+	case segments[2] == "lists" && i == 5 && segments[4] == "restore":
+		return snapshotIDs
+	case segments[2] == "admin" && i == 4 && segments[3] == "lists":
+		return listIDs
+	}
+	return noIDs
+}
+
+// bodyIDKind returns the kind of the records the bodies of the API route
+// at path carry at the top level.
+func bodyIDKind(path string) idKind {
+	segments := strings.Split(path, "/")
+	if len(segments) < 3 {
+		return noIDs
+	}
+	sub := ""
+	if len(segments) > 4 {
+		sub = segments[4]
+	}
+	switch segments[2] {
+	case "todos":
+		if sub == "history" || sub == "time" {
+			return noIDs
+		}
+		return todoIDs
+	case "lists":
+		switch sub {
+		case "todos":
+			return todoIDs
+		case "snapshots", "snapshot":
+			return snapshotIDs
+		case "restore":
+			return noIDs
+		}
+		return listIDs
+	case "views":
+		return todoIDs
+	case "admin":
+		if len(segments) > 3 && segments[3] == "export" {
+			return todoIDs
+		}
+	}
+	return noIDs
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodePath replaces the public ids in path with numeric ones. Segments
+// where a route has an id but that do not decode are route names
+// ("search", "icons") and are kept, unless they are numeric: raw ids are
+// not accepted.
+func (s *Server) decodePath(path string) (string, bool) {
+	segments := strings.Split(path, "/")
+	for i := 3; i < len(segments); i++ {
+		kind := pathIDKind(segments, i)
+		if kind == noIDs || segments[i] == "" {
+			continue
+		}
+		if id, err := s.ids[kind].Decode(segments[i]); err == nil {
+			segments[i] = strconv.FormatInt(id, 10)
+		} else if isDigits(segments[i]) {
+			return "", false
+		}
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// encodePath replaces the numeric ids in an API path with public ones.
+func (s *Server) encodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 3; i < len(segments); i++ {
+		kind := pathIDKind(segments, i)
+		if kind == noIDs || !isDigits(segments[i]) {
+			continue
+		}
+		if id, err := strconv.ParseInt(segments[i], 10, 64); err == nil {
+			segments[i] = s.ids[kind].Encode(id)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeBodyIDs replaces the public ids of a JSON request body to the route
+// at path with numbers. Bodies that are not JSON are left for the handler
+// to refuse.
+func (s *Server) decodeBodyIDs(path string, data []byte) ([]byte, error) {
+	return rewriteIDs(data, bodyIDKind(path), func(kind idKind, key string, id any) (any, error) {
+		str, ok := id.(string)
+		if !ok {
+			return nil, &idError{key}
+		}
+		n, err := s.ids[kind].Decode(str)
+		if err != nil {
+			return nil, &idError{key}
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	}, nil)
+}
+
+type idError struct{ key string }
+
+func (e *idError) Error() string { return "invalid " + e.key }
+
+// encodeBodyIDs replaces the numeric ids of a JSON response body, whose
+// top-level records are of kind, and those in its API links, with public
+// ones.
+func (s *Server) encodeBodyIDs(data []byte, kind idKind) []byte {
+	out, err := rewriteIDs(data, kind, func(kind idKind, key string, id any) (any, error) {
+		switch id := id.(type) {
+		case json.Number:
+			if n, err := id.Int64(); err == nil {
+				return s.ids[kind].Encode(n), nil
+			}
+		case string:
+			// JSON:API spells ids as strings.
+			if n, err := strconv.ParseInt(id, 10, 64); err == nil && isDigits(id) {
+				return s.ids[kind].Encode(n), nil
+			}
+		}
+		return id, nil
+	}, s.encodePath)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// rewriteIDs rewrites the values of the id fields in data, and the elements
+// of arrays of them, with fn, keeping everything else as it is. The records
+// at the top level of data are of kind; see idFields and recordFields for
+// the others. null ids and zero ones, which stand for none, are left alone.
+// When link is set, strings holding API paths go through it. data that is
+// not JSON, or has no ids, is returned as is.
+func rewriteIDs(data []byte, kind idKind, fn func(kind idKind, key string, id any) (any, error), link func(string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out bytes.Buffer
+	changed := false
+	// value rewrites the value of field key of a record of kind record,
+	// or an element of it when it is an array.
+	var value func(key string, record idKind) error
+	value = func(key string, record idKind) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case json.Delim:
+			if k, ok := recordFields[key]; ok {
+				record = k
+			}
+			if tok == '{' {
+				out.WriteByte('{')
+				for n := 0; dec.More(); n++ {
+					k, err := dec.Token()
+					if err != nil {
+						return err
+					}
+					if n > 0 {
+						out.WriteByte(',')
+					}
+					name, _ := k.(string)
+					writeJSONValue(&out, name)
+					out.WriteByte(':')
+					if err := value(name, record); err != nil {
+						return err
+					}
+				}
+				out.WriteByte('}')
+			} else {
+				out.WriteByte('[')
+				for n := 0; dec.More(); n++ {
+					if n > 0 {
+						out.WriteByte(',')
+					}
+					// The elements of an id array are ids.
+					if err := value(key, record); err != nil {
+						return err
+					}
+				}
+				out.WriteByte(']')
+			}
+			_, err = dec.Token() // the closing delimiter
+			return err
+		case nil:
+			out.WriteString("null")
+			return nil
+		}
+		of, ok := idFields[key]
+		if key == "id" || key == "ids" {
+			of, ok = record, record != noIDs
+		}
+		if ok && tok != json.Number("0") {
+			if tok, err = fn(of, key, tok); err != nil {
+				return err
+			}
+			changed = true
+		} else if str, isStr := tok.(string); isStr && link != nil && hasPublicIDs(str) {
+			if linked := link(str); linked != str {
+				tok, changed = linked, true
+			}
+		}
+		writeJSONValue(&out, tok)
+		return nil
+	}
+	if err := value("", kind); err != nil {
+		var idErr *idError
+		if errors.As(err, &idErr) {
+			return nil, err
+		}
+		return data, nil
+	}
+	if !changed {
+		return data, nil
+	}
+	if bytes.HasSuffix(data, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// writeJSONValue writes a scalar token, escaped as writeJSON would.
+func writeJSONValue(out *bytes.Buffer, v any) {
+	data, _ := json.Marshal(v)
+	out.Write(data)
+}
+
+// idRecorder encodes the ids of a response: JSON bodies are buffered and
+// rewritten whole; event streams and NDJSON line by line as they are
+// flushed. Other bodies pass through.
+type idRecorder struct {
+	http.ResponseWriter
+	s *Server
+	// kind is that of the records at the top level of the body.
+	kind idKind
+
+	wrote  bool
+	status int
+	mode   idMode
+	body   bytes.Buffer
+}
+
+type idMode int
+
+const (
+	idPassThrough idMode = iota
+	idBuffered
+	idLines
+)
+
+func (r *idRecorder) WriteHeader(status int) {
+	if r.wrote {
+		return
+	}
+	r.wrote, r.status = true, status
+	mediaType, _, _ := mime.ParseMediaType(r.Header().Get("Content-Type"))
+	switch {
+	case status == http.StatusNoContent || status == http.StatusNotModified:
+	case isJSON(mediaType):
+		r.mode = idBuffered
+	case mediaType == "text/event-stream" || mediaType == "application/x-ndjson":
+		r.mode = idLines
+	}
+	if loc := r.Header().Get("Location"); hasPublicIDs(loc) {
+		r.Header().Set("Location", r.s.encodePath(loc))
+	}
+	if r.mode != idPassThrough {
+		r.Header().Del("Content-Length")
+	}
+	if r.mode != idBuffered {
+		r.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (r *idRecorder) Write(b []byte) (int, error) {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	switch r.mode {
+	case idBuffered:
+		return r.body.Write(b)
+	case idLines:
+		r.body.Write(b)
+		return len(b), r.writeLines(false)
+	default:
+		return r.ResponseWriter.Write(b)
+	}
+}
+
+// writeLines writes the complete lines buffered, or all of them at the end.
+func (r *idRecorder) writeLines(final bool) error {
+	data := r.body.Bytes()
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if final {
+		end = len(data)
+	}
+	if end == 0 {
+		return nil
+	}
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(data[:end]))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		line := sc.Bytes()
+		switch {
+		case bytes.HasPrefix(line, []byte("data: ")):
+			out.WriteString("data: ")
+			out.Write(r.s.encodeBodyIDs(line[len("data: "):], r.kind))
+		case len(line) > 0 && line[0] == '{':
+			out.Write(r.s.encodeBodyIDs(line, r.kind))
+		default:
+			out.Write(line)
+		}
+		out.WriteByte('\n')
+	}
+	if final && end > 0 && data[end-1] != '\n' {
+		out.Truncate(out.Len() - 1)
+	}
+	r.body.Next(end)
+	_, err := r.ResponseWriter.Write(out.Bytes())
+	return err
+}
+
+// Flush writes out the complete lines of a stream.
+func (r *idRecorder) Flush() {
+	if r.mode == idBuffered {
+		return
+	}
+	if r.mode == idLines {
+		_ = r.writeLines(false)
+	}
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *idRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// finish writes what is still held back once the handler returns.
+func (r *idRecorder) finish() {
+	switch r.mode {
+	case idBuffered:
+		r.ResponseWriter.WriteHeader(r.status)
+		_, _ = r.ResponseWriter.Write(r.s.encodeBodyIDs(r.body.Bytes(), r.kind))
+	case idLines:
+		_ = r.writeLines(true)
+	}
+}
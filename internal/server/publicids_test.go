@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"todoapp/internal/auth"
+	"todoapp/internal/db"
+	"todoapp/internal/idcodec"
+)
+
+// signedClient sends requests signed as requestSigning expects.
+type signedClient struct {
+	t       *testing.T
+	handler http.Handler
+	token   string
+	key     []byte
+	nonce   int
+}
+
+func (c *signedClient) do(method, target string, body any) (int, map[string]any) {
+	c.t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, target, bytes.NewReader(data))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+c.token)
+	c.nonce++
+	ts, nonce := strconv.FormatInt(time.Now().Unix(), 10), strconv.Itoa(c.nonce)
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(method + "\n" + r.URL.RequestURI() + "\n" + ts + "\n" + nonce + "\n"))
+	mac.Write(data)
+	r.Header.Set("X-Signature-Timestamp", ts)
+	r.Header.Set("X-Signature-Nonce", nonce)
+	r.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Code, out
+}
+
+func TestSignedRequestsWithObfuscatedIDs(t *testing.T) {
+	store, err := db.NewBoltStore(filepath.Join(t.TempDir(), "todo.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	signer, err := auth.NewSigner(bytes.Repeat([]byte("s"), 32), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := idcodec.NewObfuscator("test secret", 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(store, fstest.MapFS{}, nil, WithAuth(signer), WithRequestSigning(time.Minute), WithIDCodec(codec))
+	token, _, err := signer.Issue(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &signedClient{t: t, handler: s.Handler(), token: token, key: signer.RequestKey(token)}
+
+	status, list := c.do(http.MethodPost, "/api/lists", map[string]any{"name": "Errands"})
+	if status != http.StatusCreated {
+		t.Fatalf("create list: %d %v", status, list)
+	}
+	listID, _ := list["id"].(string)
+
+	status, todo := c.do(http.MethodPost, "/api/todos", map[string]any{"title": "buy milk", "listId": listID})
+	if status != http.StatusCreated {
+		t.Fatalf("create todo: %d %v", status, todo)
+	}
+	todoID, _ := todo["id"].(string)
+	if len(todoID) != 11 || todo["listId"] != listID {
+		t.Fatalf("todo ids not translated: %v", todo)
+	}
+	// Both are record 1 of their kind.
+	if todoID == listID {
+		t.Fatalf("todo and list share the public id %q", todoID)
+	}
+
+	if status, got := c.do(http.MethodGet, "/api/todos/"+todoID, nil); status != http.StatusOK || got["id"] != todoID {
+		t.Fatalf("get todo: %d %v", status, got)
+	}
+	if status, got := c.do(http.MethodGet, "/api/todos/1", nil); status != http.StatusNotFound {
+		t.Fatalf("raw id accepted: %d %v", status, got)
+	}
+	if status, got := c.do(http.MethodGet, "/api/todos/"+listID, nil); status == http.StatusOK {
+		t.Fatalf("list id accepted as a todo id: %d %v", status, got)
+	}
+
+	if status, got := c.do(http.MethodPut, "/api/views/today/order", map[string]any{"items": []any{map[string]any{"id": todoID, "pinned": true}}}); status != http.StatusNoContent {
+		t.Fatalf("set view order: %d %v", status, got)
+	}
+	if status, got := c.do(http.MethodPut, "/api/views/today/order", map[string]any{"items": []any{map[string]any{"id": 1}}}); status != http.StatusBadRequest {
+		t.Fatalf("raw id accepted in view order: %d %v", status, got)
+	}
+
+	status, sub := c.do(http.MethodPost, "/api/subscriptions", map[string]any{"name": "errands", "listId": listID})
+	if status != http.StatusCreated || sub["listId"] != listID {
+		t.Fatalf("create subscription: %d %v", status, sub)
+	}
+	if id, ok := sub["id"].(float64); !ok || id != 1 {
+		t.Fatalf("subscription id translated: %v", sub)
+	}
+
+	// A tampered signature is still refused.
+	c.key = []byte("wrong key")
+	if status, got := c.do(http.MethodGet, "/api/todos/"+todoID, nil); status != http.StatusUnauthorized {
+		t.Fatalf("bad signature accepted: %d %v", status, got)
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// RateLimit configures token-bucket limits on API requests: PerIP applies to
+// every client address, PerUser to each signed-in user across all of their
+// addresses. A zero Rate disables that limit.
+type RateLimit struct {
+	PerIP   Rate
+	PerUser Rate
+}
+
+// Rate allows Burst requests at once, refilled at PerSecond.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+func (r Rate) enabled() bool { return r.PerSecond > 0 }
+
+// WithRateLimit enables per-client rate limiting of /api routes. Requests
+// over the limit get 429 with Retry-After. Non-API paths (static assets,
+// /metrics, health probes) and the admin API are never limited.
+func WithRateLimit(cfg RateLimit) Option {
+	return func(s *Server) {
+		if cfg.PerIP.enabled() {
+			s.ipLimiter = newRateLimiter(cfg.PerIP)
+		}
+		if cfg.PerUser.enabled() {
+			s.userLimiter = newRateLimiter(cfg.PerUser)
+		}
+	}
+}
+
+// rateLimiter holds one token bucket per key. Buckets that have refilled
+// completely are indistinguishable from new ones and are swept periodically.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(r Rate) *rateLimiter {
+	return &rateLimiter{
+		rate:      r.PerSecond,
+		burst:     float64(max(r.Burst, 1)),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from key's bucket. When none is left it reports false
+// and the throttle to send.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, throttle) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) > full {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) >= full {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	t := throttle{Reason: reasonRateLimited, Limit: int(l.burst)}
+	if b.tokens < 1 {
+		t.RetryAfter = time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		t.Reset = time.Duration((l.burst - b.tokens) / l.rate * float64(time.Second))
+		return false, t
+	}
+	b.tokens--
+	t.Remaining = int(b.tokens)
+	t.Reset = time.Duration((l.burst - b.tokens) / l.rate * float64(time.Second))
+	return true, t
+}
+
+// rateLimited reports whether path is subject to rate limiting.
+func rateLimited(path string) bool {
+	return strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/api/admin/")
+}
+
+// limitByIP applies the per-IP limit. It runs after realIP, so behind a
+// trusted proxy the address is the client's.
+func (s *Server) limitByIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rateLimited(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if ok, t := s.ipLimiter.allow(ip, time.Now()); !ok {
+			slog.DebugContext(r.Context(), "request.rate_limited", "ip", ip)
+			writeThrottled(w, t, "too many requests from this address")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitByUser applies the per-user limit to requests requireUser scoped to
+// a user; without accounts there are no users and it passes everything.
+func (s *Server) limitByUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, scoped := db.OwnerFrom(r.Context())
+		if !scoped || !rateLimited(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, t := s.userLimiter.allow(strconv.FormatInt(id, 10), time.Now()); !ok {
+			slog.DebugContext(r.Context(), "request.rate_limited", "user_id", id)
+			writeThrottled(w, t, "too many requests for this user")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
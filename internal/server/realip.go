@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies sets the networks of the reverse proxies in front of
+// the server. Only requests whose connection comes from one of them have
+// their client address taken from X-Forwarded-For or X-Real-IP; anyone else
+// could put any address there, and so pick their own rate-limit bucket.
+// Without trusted proxies the connection's address is always used.
+func WithTrustedProxies(networks []netip.Prefix) Option {
+	return func(s *Server) {
+		s.trustedProxies = networks
+	}
+}
+
+// realIP sets r.RemoteAddr to the client's address as the trusted proxies
+// report it, for the rate limits, logs and everything else after it.
+func (s *Server) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := s.forwardedClient(r); ok {
+			r.RemoteAddr = ip.String()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClient returns the client address forwarded by a trusted proxy.
+// Each proxy appends the address it was connected from to X-Forwarded-For,
+// so the client is the rightmost entry not added by a trusted proxy; what
+// lies further left was sent by the client and proves nothing.
+func (s *Server) forwardedClient(r *http.Request) (netip.Addr, bool) {
+	peer, ok := remoteIP(r.RemoteAddr)
+	if !ok || !s.trustedProxy(peer) {
+		return netip.Addr{}, false
+	}
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		if i == 0 || !s.trustedProxy(addr) {
+			return addr.Unmap(), true
+		}
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+func (s *Server) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range s.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP parses a RemoteAddr, with or without its port.
+func remoteIP(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr.Unmap(), err == nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name       string
+		trusted    []netip.Prefix
+		remote     string
+		forwarded  []string
+		realIP     string
+		wantRemote string
+	}{
+		{"no trusted proxies", nil, "203.0.113.9:5000", []string{"198.51.100.1"}, "", "203.0.113.9:5000"},
+		{"untrusted peer", proxies, "203.0.113.9:5000", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.9:5000"},
+		{"trusted proxy", proxies, "10.0.0.2:5000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"spoofed left entries", proxies, "10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"proxy chain", proxies, "10.0.0.2:5000", []string{"198.51.100.1", "10.0.0.7"}, "", "198.51.100.1"},
+		{"all trusted", proxies, "10.0.0.2:5000", []string{"10.0.0.8"}, "", "10.0.0.8"},
+		{"garbage", proxies, "10.0.0.2:5000", []string{"not-an-ip"}, "", "10.0.0.2:5000"},
+		{"x-real-ip", proxies, "10.0.0.2:5000", nil, "198.51.100.3", "198.51.100.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{trustedProxies: tt.trusted}
+			var got string
+			h := s.realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if got != tt.wantRemote {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func TestLimitByIPIgnoresSpoofedHeaders(t *testing.T) {
+	s := &Server{ipLimiter: newRateLimiter(Rate{PerSecond: 0.001, Burst: 1})}
+	h := s.realIP(s.limitByIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	for i, forwarded := range []string{"198.51.100.1", "198.51.100.2"} {
+		r := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+		r.RemoteAddr = "203.0.113.9:5000"
+		r.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; w.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// nextOccurrence spawns the next todo of the series t belongs to, t having
+// just been completed, and moves the recurrence rule over to it so
+// completing t again spawns nothing. It returns t as stored afterwards; on
+// failure t is left as it was and the error logged.
+//
+// The next occurrence falls on the first date of the rule after both t's due
+// date and now; its start date keeps the same distance from the due date.
+// A todo with neither date gets its next due date counted from now.
+func (s *Server) nextOccurrence(ctx context.Context, t db.Todo) db.Todo {
+	rule, err := db.ParseRecurrence(t.Recurrence)
+	if err != nil {
+		slog.WarnContext(ctx, "recurrence.invalid", "todo_id", t.ID, "error", err)
+		return t
+	}
+	now := time.Now().UTC()
+	base := now
+	if t.DueAt != nil {
+		base = *t.DueAt
+	} else if t.StartAt != nil {
+		base = *t.StartAt
+	}
+	next, rest, ok := rule.Next(base, now)
+	if !ok {
+		return t
+	}
+	shift := func(v *time.Time) *time.Time {
+		if v == nil {
+			return nil
+		}
+		shifted := v.Add(next.Sub(base))
+		return &shifted
+	}
+	spawned := db.SaveTodoInput{
+		Title:           t.Title,
+		Tags:            t.Tags,
+		DurationMinutes: t.DurationMinutes,
+		DueAt:           shift(t.DueAt),
+		StartAt:         shift(t.StartAt),
+		Effort:          t.Effort,
+		ReminderOffsets: t.ReminderOffsets,
+		Recurrence:      rest.String(),
+		ListID:          t.ListID,
+	}
+	if spawned.DueAt == nil && spawned.StartAt == nil {
+		spawned.DueAt = &next
+	}
+	spawned.PriorityScore = s.computePriority(ctx, priorityCandidate{
+		Title:           spawned.Title,
+		Tags:            spawned.Tags,
+		DurationMinutes: spawned.DurationMinutes,
+		CreatedAt:       now,
+	}, 0)
+	done := db.SaveTodoInput{
+		Title:           t.Title,
+		Completed:       t.Completed,
+		Tags:            t.Tags,
+		DurationMinutes: t.DurationMinutes,
+		PriorityScore:   t.PriorityScore,
+		DueAt:           t.DueAt,
+		StartAt:         t.StartAt,
+		Effort:          t.Effort,
+		ReminderOffsets: t.ReminderOffsets,
+		ListID:          t.ListID,
+	}
+
+	var created db.Todo
+	if saver, ok := s.store.(db.BulkSaver); ok {
+		var saved []db.Todo
+		saved, err = saver.BulkSave(ctx, []db.BulkOp{
+			{Action: db.BulkUpdate, ID: t.ID, Input: done},
+			{Action: db.BulkCreate, Input: spawned},
+		})
+		if err == nil {
+			t, created = saved[0], saved[1]
+		}
+	} else if created, err = s.store.CreateTodo(ctx, spawned); err == nil {
+		// Without transactions a failure here leaves both todos carrying the
+		// rule, which at worst spawns a duplicate later; better than
+		// dropping the series.
+		if updated, err := s.store.UpdateTodo(ctx, t.ID, done); err == nil {
+			t = updated
+		} else {
+			slog.WarnContext(ctx, "recurrence.clear_failed", "todo_id", t.ID, "error", err)
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "recurrence.spawn_failed", "todo_id", t.ID, "error", err)
+		return t
+	}
+	slog.InfoContext(ctx, "recurrence.spawned", "todo_id", t.ID, "next_id", created.ID, "due_at", created.DueAt)
+	s.publishTodo(ctx, eventTodoCreated, created)
+	s.todoCreated(ctx, created)
+	return t
+}
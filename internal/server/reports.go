@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"todoapp/internal/blob"
+	"todoapp/internal/cron"
+	"todoapp/internal/db"
+)
+
+const (
+	// maxReportRuns is how many past report exports are kept for the admin API.
+	maxReportRuns = 20
+	// reportTimeout bounds one report export, upload included.
+	reportTimeout = 30 * time.Minute
+)
+
+var errReportRunning = errors.New("a report export is already running")
+
+// ReportSchedule configures recurring exports of every todo to object
+// storage, for BI pipelines that ingest them.
+type ReportSchedule struct {
+	Bucket blob.Bucket
+	Format string // "csv" or "json"
+	// Schedule is when exports run, once RunSchedules is started.
+	Schedule cron.Schedule
+	// WebhookURL, when set, is posted a report.completed or report.failed
+	// event after each run, signed like outbound hooks when WebhookSecret
+	// is set.
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// ReportRun records one report export.
+type ReportRun struct {
+	Key        string    `json:"key"`
+	Format     string    `json:"format"`
+	Items      int       `json:"items"`
+	Bytes      int64     `json:"bytes"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// reports holds the schedule and recent runs of report exports.
+type reports struct {
+	schedule ReportSchedule
+	// running is held while an export is in progress.
+	running sync.Mutex
+
+	mu   sync.Mutex
+	runs []ReportRun
+}
+
+// WithReportSchedule exports every todo to object storage on the given
+// schedule, once RunSchedules is started. Admins can list past runs and
+// trigger one through /api/admin/reports.
+func WithReportSchedule(c ReportSchedule) Option {
+	return func(s *Server) {
+		s.reports = &reports{schedule: c}
+	}
+}
+
+// exportReport writes every todo to a temporary file, uploads it and
+// notifies the webhook. ctx must not be scoped to a user.
+func (s *Server) exportReport(ctx context.Context) (ReportRun, error) {
+	if !s.reports.running.TryLock() {
+		return ReportRun{}, errReportRunning
+	}
+	defer s.reports.running.Unlock()
+
+	done := s.jobs.start(jobReport)
+	c := s.reports.schedule
+	run := ReportRun{Format: c.Format, StartedAt: time.Now().UTC()}
+	run.Key = "todos-" + run.StartedAt.Format("20060102T150405Z") + "." + c.Format
+	err := s.uploadReport(ctx, &run)
+	done(err)
+	run.FinishedAt = time.Now().UTC()
+	event := "report.completed"
+	if err != nil {
+		run.Error = err.Error()
+		event = "report.failed"
+	} else {
+		slog.InfoContext(ctx, "report.exported", "key", run.Key, "items", run.Items, "bytes", run.Bytes)
+	}
+
+	s.reports.mu.Lock()
+	s.reports.runs = append(s.reports.runs, run)
+	if len(s.reports.runs) > maxReportRuns {
+		s.reports.runs = slices.Delete(s.reports.runs, 0, len(s.reports.runs)-maxReportRuns)
+	}
+	s.reports.mu.Unlock()
+
+	if c.WebhookURL != "" {
+		if werr := s.notifyReport(ctx, event, run); werr != nil {
+			slog.WarnContext(ctx, "report.webhook_failed", "key", run.Key, "error", werr)
+		}
+	}
+	return run, err
+}
+
+func (s *Server) uploadReport(ctx context.Context, run *ReportRun) error {
+	streamer, ok := s.store.(db.TodoStreamer)
+	if !ok {
+		return errors.New("export not supported by storage backend")
+	}
+	f, err := os.CreateTemp("", "todos-report-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	buf := bufio.NewWriter(f)
+	write, _, finish := exportWriter(buf, run.Format, time.UTC)
+	err = streamer.StreamTodos(ctx, db.TodoFilter{}, func(t db.Todo) error {
+		run.Items++
+		return write(s.present(t))
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("write export: %w", err)
+	}
+	if run.Bytes, err = f.Seek(0, io.SeekCurrent); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	contentType := "application/json; charset=utf-8"
+	if run.Format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+	return s.reports.schedule.Bucket.Put(ctx, run.Key, f, contentType)
+}
+
+// notifyReport posts {"event": event, "report": run} to the webhook.
+func (s *Server) notifyReport(ctx context.Context, event string, run ReportRun) error {
+	c := s.reports.schedule
+	body, err := json.Marshal(map[string]any{"event": event, "report": run})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(c.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set("X-Hook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// handleAdminReports lists the recent report exports, newest first, with
+// when the next one is due.
+func (s *Server) handleAdminReports(w http.ResponseWriter, r *http.Request) {
+	if s.reports == nil {
+		writeError(w, http.StatusNotFound, "no report schedule configured")
+		return
+	}
+	s.reports.mu.Lock()
+	runs := slices.Clone(s.reports.runs)
+	s.reports.mu.Unlock()
+	slices.Reverse(runs)
+	if runs == nil {
+		runs = []ReportRun{}
+	}
+	resp := map[string]any{"runs": runs}
+	if next, err := s.reports.schedule.Schedule.Next(time.Now()); err == nil {
+		resp["next"] = next
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAdminRunReport runs a report export now, outside the schedule.
+func (s *Server) handleAdminRunReport(w http.ResponseWriter, r *http.Request) {
+	if s.reports == nil {
+		writeError(w, http.StatusNotFound, "no report schedule configured")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), reportTimeout)
+	defer cancel()
+	run, err := s.exportReport(ctx)
+	switch {
+	case errors.Is(err, errReportRunning):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		slog.ErrorContext(ctx, "report.export_failed", "error", err)
+		writeJSON(w, http.StatusBadGateway, run)
+	default:
+		writeJSON(w, http.StatusOK, run)
+	}
+}
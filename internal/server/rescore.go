@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/mlclient"
+)
+
+// rescoreBatch is how many todos go to the ML service per request.
+const rescoreBatch = 100
+
+// batchScorer is implemented by scorers that accept several todos per call.
+type batchScorer interface {
+	ScoreBatch(ctx context.Context, todos []mlclient.TodoPayload) ([]float64, error)
+}
+
+var (
+	errRescoreRunning     = errors.New("a rescore is already running")
+	errRescoreUnsupported = errors.New("rescoring not supported by storage backend")
+)
+
+// rescoreResult summarises one re-scoring run.
+type rescoreResult struct {
+	Scored  int   `json:"scored"`
+	Changed int   `json:"changed"`
+	TookMS  int64 `json:"tookMs"`
+}
+
+// rescore re-scores every incomplete todo, or with all every todo, in
+// batches and persists the scores that changed. The ML service's score
+// includes an age bonus, so open todos rise in priority the longer they
+// wait; all backfills scores after a model or calibration change. ctx must
+// not be scoped to a user: runs cover everyone's todos.
+func (s *Server) rescore(ctx context.Context, all bool) (res rescoreResult, err error) {
+	if !s.rescoring.TryLock() {
+		return rescoreResult{}, errRescoreRunning
+	}
+	defer s.rescoring.Unlock()
+
+	lister, ok := s.store.(db.FilteredLister)
+	updater, ok2 := s.store.(db.ScoreUpdater)
+	if !ok || !ok2 {
+		return rescoreResult{}, errRescoreUnsupported
+	}
+	done := s.jobs.start(jobRescore)
+	defer func() { done(err) }()
+	start := time.Now()
+	var filter db.TodoFilter
+	if !all {
+		open := false
+		filter.Completed = &open
+	}
+	items, err := lister.ListTodosFiltered(ctx, filter)
+	if err != nil {
+		return rescoreResult{}, err
+	}
+
+	defer func() {
+		if res.Changed > 0 {
+			s.lists.clear()
+			s.events.broadcast(ctx, todoEvent{Type: eventTodosChanged})
+		}
+	}()
+	for len(items) > 0 {
+		batch := items[:min(rescoreBatch, len(items))]
+		items = items[len(batch):]
+
+		scores, err := s.scoreBatch(ctx, batch)
+		s.alerts.Record("ml", err != nil)
+		if err != nil {
+			return res, err
+		}
+		changed := make(map[int64]float64)
+		for i, t := range batch {
+			if math.Abs(scores[i]-t.PriorityScore) > 1e-9 {
+				changed[t.ID] = scores[i]
+			}
+		}
+		if err := updater.UpdatePriorityScores(ctx, changed); err != nil {
+			return res, err
+		}
+		res.Scored += len(batch)
+		res.Changed += len(changed)
+	}
+	res.TookMS = time.Since(start).Milliseconds()
+	slog.InfoContext(ctx, "ml.rescored", "all", all, "scored", res.Scored, "changed", res.Changed, "took_ms", res.TookMS)
+	return res, nil
+}
+
+// scoreBatch scores todos, in one call when the scorer supports batches,
+// and applies their owners' tag boosts.
+func (s *Server) scoreBatch(ctx context.Context, todos []db.Todo) ([]float64, error) {
+	scores, err := s.scoreRaw(ctx, todos)
+	if err != nil {
+		return nil, err
+	}
+	prefs := map[int64]db.Preferences{}
+	for i, t := range todos {
+		p, ok := prefs[t.OwnerID]
+		if !ok {
+			p = s.preferences(ownerContext(ctx, t.OwnerID))
+			prefs[t.OwnerID] = p
+		}
+		scores[i] = p.Apply(scores[i], t.Tags)
+	}
+	return scores, nil
+}
+
+// ownerContext scopes ctx to ownerID, leaving it unscoped for unowned todos.
+func ownerContext(ctx context.Context, ownerID int64) context.Context {
+	if ownerID != 0 {
+		return db.WithOwner(ctx, ownerID)
+	}
+	return ctx
+}
+
+func (s *Server) scoreRaw(ctx context.Context, todos []db.Todo) ([]float64, error) {
+	payloads := make([]mlclient.TodoPayload, len(todos))
+	for i, t := range todos {
+		created := t.CreatedAt
+		payloads[i] = mlclient.TodoPayload{
+			Title:           t.Title,
+			Completed:       t.Completed,
+			Tags:            t.Tags,
+			DurationMinutes: t.DurationMinutes,
+			CreatedAt:       &created,
+		}
+		payloads[i].User = s.userFeatures(ownerContext(ctx, t.OwnerID), t.Tags)
+	}
+	if b, ok := s.scorer.(batchScorer); ok {
+		return b.ScoreBatch(ctx, payloads)
+	}
+	scores := make([]float64, len(payloads))
+	for i, p := range payloads {
+		score, err := s.scorer.Score(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		scores[i] = score
+	}
+	return scores, nil
+}
+
+// handleRescore runs a re-scoring pass on demand.
+func (s *Server) handleRescore(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := contextWithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+	res, err := s.rescore(ctx, false)
+	switch {
+	case errors.Is(err, errRescoreRunning):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, errRescoreUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+	case err != nil:
+		slog.ErrorContext(ctx, "ml.rescore_failed", "error", err, "scored", res.Scored)
+		writeError(w, http.StatusBadGateway, "rescore failed")
+	default:
+		writeJSON(w, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todoapp/internal/cron"
+	"todoapp/internal/db"
+)
+
+// Anonymization configures the retention job that anonymizes old completed
+// todos instead of deleting them (see db.TodoAnonymizer).
+type Anonymization struct {
+	// After is how long a completed todo stays untouched before it is
+	// anonymized. Zero disables anonymization.
+	After time.Duration
+	// DryRun only reports what would be anonymized.
+	DryRun bool
+	// Schedule is when the job runs, once RunSchedules is started.
+	Schedule cron.Schedule
+}
+
+// anonymizationResult reports one anonymization pass.
+type anonymizationResult struct {
+	Cutoff time.Time `json:"cutoff"`
+	DryRun bool      `json:"dryRun"`
+	// Todos is how many todos were anonymized, or would be on a dry run.
+	Todos int `json:"todos"`
+}
+
+// WithAnonymization enables the anonymization job and its admin endpoints.
+func WithAnonymization(a Anonymization) Option {
+	return func(s *Server) {
+		s.anonymization = a
+	}
+}
+
+// anonymize runs one pass over every user's todos. Anonymizing leaves the
+// db.ListVersion as it was, so the cached lists are dropped here, and the
+// event log scrubs the titles it has yet to write.
+func (s *Server) anonymize(ctx context.Context, anonymizer db.TodoAnonymizer, dryRun bool) (anonymizationResult, error) {
+	res := anonymizationResult{Cutoff: time.Now().UTC().Add(-s.anonymization.After), DryRun: dryRun}
+	var err error
+	res.Todos, err = anonymizer.AnonymizeTodos(ctx, res.Cutoff, dryRun)
+	if !dryRun && res.Todos > 0 {
+		s.lists.clear()
+		s.eventLog.anonymize(res.Cutoff)
+		s.events.broadcast(ctx, todoEvent{Type: eventTodosChanged})
+	}
+	return res, err
+}
+
+// runAnonymizer is one scheduled anonymization pass. In dry-run mode it
+// only logs how many todos it would change.
+func (s *Server) runAnonymizer(ctx context.Context, anonymizer db.TodoAnonymizer) error {
+	done := s.jobs.start(jobAnonymize)
+	res, err := s.anonymize(ctx, anonymizer, s.anonymization.DryRun)
+	done(err)
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "retention.anonymize_failed", "error", err, "anonymized", res.Todos)
+	case res.DryRun:
+		slog.InfoContext(ctx, "retention.dry_run", "would_anonymize", res.Todos, "cutoff", res.Cutoff)
+	}
+	return err
+}
+
+// handleAdminRetention reports how many todos the next pass would
+// anonymize, without changing any.
+func (s *Server) handleAdminRetention(w http.ResponseWriter, r *http.Request) {
+	s.serveAnonymize(w, r, true)
+}
+
+// handleAdminAnonymize runs a pass now, honouring the configured dry-run
+// mode.
+func (s *Server) handleAdminAnonymize(w http.ResponseWriter, r *http.Request) {
+	s.serveAnonymize(w, r, s.anonymization.DryRun)
+}
+
+func (s *Server) serveAnonymize(w http.ResponseWriter, r *http.Request, dryRun bool) {
+	anonymizer, ok := s.store.(db.TodoAnonymizer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "anonymization not supported by storage backend")
+		return
+	}
+	if s.anonymization.After <= 0 {
+		writeError(w, http.StatusNotFound, "anonymization not configured")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+	res, err := s.anonymize(ctx, anonymizer, dryRun)
+	if err != nil {
+		slog.ErrorContext(ctx, "retention.anonymize_failed", "error", err, "anonymized", res.Todos)
+		writeError(w, http.StatusInternalServerError, "anonymization failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,405 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+	"todoapp/internal/telemetry"
+)
+
+// ruleTimeout bounds one evaluation of the rules for a todo event.
+const ruleTimeout = 30 * time.Second
+
+type ruleRequest struct {
+	Name             string             `json:"name"`
+	Trigger          string             `json:"trigger"`
+	DueWithinMinutes int                `json:"dueWithinMinutes"`
+	Conditions       []db.RuleCondition `json:"conditions"`
+	Actions          []db.RuleAction    `json:"actions"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled"`
+}
+
+func (r ruleRequest) input() db.SaveRuleInput {
+	return db.SaveRuleInput{
+		Name:             r.Name,
+		Trigger:          r.Trigger,
+		DueWithinMinutes: r.DueWithinMinutes,
+		Conditions:       r.Conditions,
+		Actions:          r.Actions,
+		Enabled:          r.Enabled == nil || *r.Enabled,
+	}
+}
+
+// ruleStore returns the backend's rule support, writing 501 when missing.
+func (s *Server) ruleStore(w http.ResponseWriter) (db.RuleStore, bool) {
+	rules, ok := s.store.(db.RuleStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "rules not supported by storage backend")
+	}
+	return rules, ok
+}
+
+func (s *Server) handleListRules(w http.ResponseWriter, r *http.Request) {
+	rules, ok := s.ruleStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := rules.ListRules(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list rules")
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) handleGetRule(w http.ResponseWriter, r *http.Request) {
+	rules, ok := s.ruleStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	rule, err := rules.GetRule(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrRuleNotFound) {
+			writeError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load rule")
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) handleCreateRule(w http.ResponseWriter, r *http.Request) {
+	rules, ok := s.ruleStore(w)
+	if !ok {
+		return
+	}
+	var req ruleRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	rule, err := rules.CreateRule(ctx, req.input())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *Server) handleUpdateRule(w http.ResponseWriter, r *http.Request) {
+	rules, ok := s.ruleStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req ruleRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	rule, err := rules.UpdateRule(ctx, id, req.input())
+	if err != nil {
+		if errors.Is(err, db.ErrRuleNotFound) {
+			writeError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) handleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	rules, ok := s.ruleStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := rules.DeleteRule(ctx, id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListRuleRuns returns a rule's execution log, newest first
+// (?limit=N, at most 100).
+func (s *Server) handleListRuleRuns(w http.ResponseWriter, r *http.Request) {
+	rules, ok := s.ruleStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > 100 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if _, err := rules.GetRule(ctx, id); err != nil {
+		if errors.Is(err, db.ErrRuleNotFound) {
+			writeError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load rule")
+		return
+	}
+	runs, err := rules.ListRuleRuns(ctx, id, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list rule runs")
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// todoCreated runs the created rules for t and queues its webhooks, and its
+// scoring if the request skipped that.
+func (s *Server) todoCreated(ctx context.Context, t db.Todo) {
+	s.fireRules(ctx, db.RuleTriggerCreated, t)
+	s.queueWebhooks(ctx, db.WebhookEventCreated, t)
+	s.queueDeferredScore(ctx, t)
+}
+
+// todoUpdated runs the update hooks for a todo that changed from before to
+// after. If that completed it, the next occurrence of a recurring todo is
+// spawned and the completed rules run. Webhooks, and skipped scoring, are
+// queued either way. It returns after as now stored.
+func (s *Server) todoUpdated(ctx context.Context, before, after db.Todo) db.Todo {
+	completed := after.Completed && !before.Completed
+	if completed && after.Recurrence != "" {
+		after = s.nextOccurrence(ctx, after)
+	}
+	s.hooks.Updated(ctx, before, after)
+	s.queueWebhooks(ctx, db.WebhookEventUpdated, after)
+	s.queueDeferredScore(ctx, after)
+	if completed {
+		telemetry.TodoCompletions.Inc()
+		s.fireRules(ctx, db.RuleTriggerCompleted, after)
+		s.queueWebhooks(ctx, db.WebhookEventCompleted, after)
+	}
+	return after
+}
+
+// fireRules applies the enabled rules with trigger that t passes. They run
+// in the background, so changes they make reach clients as later events.
+func (s *Server) fireRules(ctx context.Context, trigger string, t db.Todo) {
+	rules, ok := s.store.(db.RuleStore)
+	if !ok {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, ruleTimeout)
+		defer cancel()
+		all, err := rules.ListRules(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "rule.load_failed", "trigger", trigger, "error", err)
+			return
+		}
+		for _, rule := range all {
+			if rule.Enabled && rule.Trigger == trigger && rule.OwnerID == t.OwnerID {
+				s.applyRule(ctx, rules, rule, trigger, t.ID)
+			}
+		}
+	}()
+}
+
+// applyRule runs rule's actions on the todo with the given id, provided its
+// current state still passes the rule's conditions, and logs the run.
+func (s *Server) applyRule(ctx context.Context, rules db.RuleStore, rule db.Rule, trigger string, id int64) {
+	current, err := s.store.GetTodo(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return
+	}
+	run := db.RuleRun{RuleID: rule.ID, TodoID: id, Trigger: trigger, Status: db.RuleRunApplied}
+	if err == nil && !rule.Matches(current) {
+		return
+	}
+
+	var applied, notices []string
+	var moveTo *int64
+	if err == nil {
+		input := db.SaveTodoInput{
+			Title:           current.Title,
+			Completed:       current.Completed,
+			Tags:            current.Tags,
+			DurationMinutes: current.DurationMinutes,
+			PriorityScore:   current.PriorityScore,
+			DueAt:           current.DueAt,
+			StartAt:         current.StartAt,
+			Effort:          current.Effort,
+			ReminderOffsets: current.ReminderOffsets,
+			Recurrence:      current.Recurrence,
+			ListID:          current.ListID,
+		}
+		changed := false
+		for _, a := range rule.Actions {
+			switch a.Type {
+			case db.RuleActionAddTag:
+				if !slices.Contains(input.Tags, a.Tag) {
+					input.Tags = append(slices.Clone(input.Tags), a.Tag)
+					changed = true
+				}
+				applied = append(applied, "add_tag "+a.Tag)
+			case db.RuleActionSetPriority:
+				input.PriorityScore = *a.Priority
+				changed = changed || *a.Priority != current.PriorityScore
+				applied = append(applied, fmt.Sprintf("set_priority %g", *a.Priority))
+			case db.RuleActionNotify:
+				notices = append(notices, a.Message)
+				applied = append(applied, "notify")
+			case db.RuleActionMoveToList:
+				moveTo = a.ListID
+				applied = append(applied, fmt.Sprintf("move_to_list %d", *a.ListID))
+			}
+		}
+		if changed {
+			var updated db.Todo
+			if updated, err = s.store.UpdateTodo(ctx, id, input); err == nil {
+				s.hooks.Updated(ctx, current, updated)
+				s.queueWebhooks(ctx, db.WebhookEventUpdated, updated)
+				s.publishTodo(ctx, eventTodoUpdated, updated)
+				current = updated
+			}
+		}
+		// Moves go through db.TodoMover, as POST /api/todos/{id}/move-to-list
+		// does, so history records them as moves.
+		if err == nil && moveTo != nil && (current.ListID == nil || *current.ListID != *moveTo) {
+			mover, ok := s.store.(db.TodoMover)
+			if !ok {
+				err = errors.New("moving todos not supported by storage backend")
+			} else if moved, moveErr := mover.MoveTodo(ctx, id, moveTo); moveErr != nil {
+				err = moveErr
+			} else {
+				s.hooks.Updated(ctx, current, moved)
+				s.queueWebhooks(ctx, db.WebhookEventUpdated, moved)
+				s.publishTodo(ctx, eventTodoUpdated, moved)
+				current = moved
+			}
+		}
+	}
+	if err != nil {
+		run.Status, run.Detail = db.RuleRunFailed, err.Error()
+		slog.WarnContext(ctx, "rule.failed", "rule_id", rule.ID, "todo_id", id, "trigger", trigger, "error", err)
+	} else {
+		run.Detail = strings.Join(applied, "; ")
+		for _, msg := range notices {
+			t := s.present(current)
+			s.events.publish(ctx, todoEvent{Type: eventRuleNotification, Todo: &t, Message: msg})
+		}
+		slog.InfoContext(ctx, "rule.applied", "rule_id", rule.ID, "todo_id", id, "trigger", trigger)
+	}
+	if err := rules.RecordRuleRun(ctx, run); err != nil {
+		slog.WarnContext(ctx, "rule.log_failed", "rule_id", rule.ID, "error", err)
+	}
+}
+
+// RunRuleSchedule fires due_soon rules every interval until ctx is
+// cancelled. Each pass covers the todos whose trigger time (due date minus
+// the rule's window) arrived since the previous successful pass; a todo due
+// sooner than the window when it is created or the server starts does not
+// fire.
+func (s *Server) RunRuleSchedule(ctx context.Context, interval time.Duration) {
+	rules, ok := s.store.(db.RuleStore)
+	streamer, ok2 := s.store.(db.TodoStreamer)
+	if !ok || !ok2 {
+		return
+	}
+	since := time.Now()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			done := s.jobs.start(jobDueSoonRules)
+			err := s.fireDueSoonRules(runCtx, rules, streamer, since, now)
+			done(err)
+			if err != nil {
+				slog.WarnContext(ctx, "rule.schedule_failed", "error", err)
+			} else {
+				since = now
+			}
+			cancel()
+		}
+	}
+}
+
+func (s *Server) fireDueSoonRules(ctx context.Context, rules db.RuleStore, streamer db.TodoStreamer, since, now time.Time) error {
+	all, err := rules.ListRules(ctx)
+	if err != nil {
+		return err
+	}
+	open := false
+	for _, rule := range all {
+		if !rule.Enabled || rule.Trigger != db.RuleTriggerDueSoon {
+			continue
+		}
+		within := time.Duration(rule.DueWithinMinutes) * time.Minute
+		ruleCtx := ctx
+		if rule.OwnerID != 0 {
+			ruleCtx = db.WithOwner(ctx, rule.OwnerID)
+		}
+		// Collect first: applying actions while streaming would write
+		// under an open read.
+		var due []int64
+		filter := db.TodoFilter{Completed: &open, DueAfter: since.Add(within), DueBefore: now.Add(within)}
+		err := streamer.StreamTodos(ruleCtx, filter, func(t db.Todo) error {
+			if t.OwnerID == rule.OwnerID && rule.Matches(t) {
+				due = append(due, t.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range due {
+			s.applyRule(ruleCtx, rules, rule, db.RuleTriggerDueSoon, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+func TestRuleMovesTodoToList(t *testing.T) {
+	store, err := db.NewBoltStore(filepath.Join(t.TempDir(), "todo.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+	inbox, err := store.CreateList(ctx, db.SaveListInput{Name: "Inbox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errands, err := store.CreateList(ctx, db.SaveListInput{Name: "Errands"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateRule(ctx, db.SaveRuleInput{Name: "file errands", Trigger: db.RuleTriggerCreated, Enabled: true}); err == nil {
+		t.Fatal("rule without actions accepted")
+	}
+	missing := int64(42)
+	if _, err := store.CreateRule(ctx, db.SaveRuleInput{
+		Name: "file errands", Trigger: db.RuleTriggerCreated, Enabled: true,
+		Actions: []db.RuleAction{{Type: db.RuleActionMoveToList, ListID: &missing}},
+	}); err == nil {
+		t.Fatal("rule moving to a missing list accepted")
+	}
+	if _, err := store.CreateRule(ctx, db.SaveRuleInput{
+		Name: "file errands", Trigger: db.RuleTriggerCreated, Enabled: true,
+		Conditions: []db.RuleCondition{{ListID: &inbox.ID}, {Tag: "errand"}},
+		Actions:    []db.RuleAction{{Type: db.RuleActionMoveToList, ListID: &errands.ID}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewServer(store, fstest.MapFS{}, nil).Handler()
+	create := func(body string) db.Todo {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create: %d %s", w.Code, w.Body)
+		}
+		todos, err := store.ListTodos(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return todos[len(todos)-1]
+	}
+
+	moved := create(`{"title":"Buy stamps","tags":["errand"],"listId":1}`)
+	kept := create(`{"title":"Call the bank","tags":["errand"]}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := store.GetTodo(ctx, moved.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ListID != nil && *got.ListID == errands.ID {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("todo in list %v, want %d", got.ListID, errands.ID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, err := store.GetTodo(ctx, kept.ID); err != nil || got.ListID != nil {
+		t.Fatalf("todo outside the inbox moved to %v (%v)", got.ListID, err)
+	}
+	history, err := store.TodoHistory(ctx, moved.ID)
+	if err != nil || history[len(history)-1].Action != db.ActionMoved {
+		t.Fatalf("history %+v (%v)", history, err)
+	}
+}
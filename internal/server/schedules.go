@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/cron"
+	"todoapp/internal/db"
+)
+
+// scheduledJobTimeout bounds one run of a scheduled job.
+const scheduledJobTimeout = 30 * time.Minute
+
+// errJobSkipped is returned by a scheduled job that did not run, because
+// another run of it, started elsewhere, is in progress.
+var errJobSkipped = errors.New("already running")
+
+// WithRescoreSchedule re-scores open todos on schedule, once RunSchedules
+// is started, so their priority keeps up with their age.
+func WithRescoreSchedule(schedule cron.Schedule) Option {
+	return func(s *Server) {
+		s.rescoreSchedule = schedule
+	}
+}
+
+// ScheduledRun describes one run of a scheduled job.
+type ScheduledRun struct {
+	// Trigger is "schedule", or "manual" for runs started through the
+	// admin API.
+	Trigger    string     `json:"trigger"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Skipped    bool       `json:"skipped,omitempty"`
+}
+
+// scheduledJob is a background job run on a cron schedule.
+type scheduledJob struct {
+	name     string
+	schedule cron.Schedule
+	run      func(ctx context.Context) error
+
+	mu      sync.Mutex
+	next    time.Time
+	running bool
+	last    *ScheduledRun
+}
+
+// scheduledJobs returns the jobs the server was configured to run on a
+// schedule, by name.
+func (s *Server) scheduledJobs() []*scheduledJob {
+	var jobs []*scheduledJob
+	if s.reports != nil && !s.reports.schedule.Schedule.IsZero() {
+		jobs = append(jobs, &scheduledJob{name: jobReport, schedule: s.reports.schedule.Schedule, run: func(ctx context.Context) error {
+			_, err := s.exportReport(ctx)
+			if errors.Is(err, errReportRunning) {
+				return errJobSkipped
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "report.export_failed", "error", err)
+			}
+			return err
+		}})
+	}
+	if !s.rescoreSchedule.IsZero() {
+		jobs = append(jobs, &scheduledJob{name: jobRescore, schedule: s.rescoreSchedule, run: func(ctx context.Context) error {
+			_, err := s.rescore(ctx, false)
+			if errors.Is(err, errRescoreRunning) {
+				return errJobSkipped
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "ml.rescore_failed", "error", err)
+			}
+			return err
+		}})
+	}
+	if anonymizer, ok := s.store.(db.TodoAnonymizer); ok && s.anonymization.After > 0 && !s.anonymization.Schedule.IsZero() {
+		jobs = append(jobs, &scheduledJob{name: jobAnonymize, schedule: s.anonymization.Schedule, run: func(ctx context.Context) error {
+			return s.runAnonymizer(ctx, anonymizer)
+		}})
+	}
+	slices.SortFunc(jobs, func(a, b *scheduledJob) int { return strings.Compare(a.name, b.name) })
+	return jobs
+}
+
+// RunSchedules runs the scheduled jobs until ctx is cancelled. A job still
+// running when it is due again is skipped; runs missed while the process
+// was down are not caught up.
+func (s *Server) RunSchedules(ctx context.Context) {
+	if len(s.schedules) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, job := range s.schedules {
+		job.advance(now)
+	}
+	for {
+		var wake time.Time
+		for _, job := range s.schedules {
+			job.mu.Lock()
+			if !job.next.IsZero() && (wake.IsZero() || job.next.Before(wake)) {
+				wake = job.next
+			}
+			job.mu.Unlock()
+		}
+		if wake.IsZero() {
+			return // no job ever fires again
+		}
+		t := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case now = <-t.C:
+		}
+		for _, job := range s.schedules {
+			job.mu.Lock()
+			due := !job.next.IsZero() && !job.next.After(now)
+			job.mu.Unlock()
+			if due {
+				job.advance(now)
+				if !s.startScheduledJob(ctx, job, "schedule") {
+					slog.WarnContext(ctx, "schedule.skipped", "job", job.name, "reason", "previous run still in progress")
+				}
+			}
+		}
+	}
+}
+
+// advance sets the job's next run to the first one after now.
+func (j *scheduledJob) advance(now time.Time) {
+	next, err := j.schedule.Next(now)
+	if err != nil {
+		next = time.Time{}
+	}
+	j.mu.Lock()
+	j.next = next
+	j.mu.Unlock()
+}
+
+// startScheduledJob runs job in the background under ctx, unless it is
+// already running, and reports whether it started it.
+func (s *Server) startScheduledJob(ctx context.Context, job *scheduledJob, trigger string) bool {
+	job.mu.Lock()
+	if job.running {
+		job.mu.Unlock()
+		return false
+	}
+	job.running = true
+	run := &ScheduledRun{Trigger: trigger, StartedAt: time.Now().UTC()}
+	job.last = run
+	job.mu.Unlock()
+
+	go func() {
+		runCtx, cancel := context.WithTimeout(ctx, scheduledJobTimeout)
+		defer cancel()
+		err := job.run(runCtx)
+		finished := time.Now().UTC()
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		job.running = false
+		run.FinishedAt = &finished
+		switch {
+		case errors.Is(err, errJobSkipped):
+			run.Skipped = true
+		case err != nil:
+			run.Error = err.Error()
+		}
+		slog.DebugContext(ctx, "schedule.finished", "job", job.name, "trigger", trigger, "took_ms", finished.Sub(run.StartedAt).Milliseconds(), "error", run.Error)
+	}()
+	return true
+}
+
+// scheduleStatus is a scheduled job as the admin API lists it.
+type scheduleStatus struct {
+	Name     string        `json:"name"`
+	Schedule string        `json:"schedule"`
+	NextRun  *time.Time    `json:"nextRun"`
+	Running  bool          `json:"running"`
+	LastRun  *ScheduledRun `json:"lastRun,omitempty"`
+}
+
+func (j *scheduledJob) status() scheduleStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := scheduleStatus{Name: j.name, Schedule: j.schedule.String(), Running: j.running}
+	next := j.next
+	if next.IsZero() {
+		// RunSchedules has not started; show when it would fire.
+		next, _ = j.schedule.Next(time.Now())
+	}
+	if !next.IsZero() {
+		next = next.UTC()
+		st.NextRun = &next
+	}
+	if j.last != nil {
+		last := *j.last
+		st.LastRun = &last
+	}
+	return st
+}
+
+// handleAdminSchedules lists the scheduled jobs with their next and last
+// runs.
+func (s *Server) handleAdminSchedules(w http.ResponseWriter, r *http.Request) {
+	out := make([]scheduleStatus, len(s.schedules))
+	for i, job := range s.schedules {
+		out[i] = job.status()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleAdminRunSchedule starts a scheduled job now, outside its schedule.
+// It answers 202 once the job has started; its outcome shows in lastRun.
+func (s *Server) handleAdminRunSchedule(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	i := slices.IndexFunc(s.schedules, func(j *scheduledJob) bool { return j.name == name })
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "no scheduled job "+name)
+		return
+	}
+	job := s.schedules[i]
+	if !s.startScheduledJob(context.WithoutCancel(r.Context()), job, "manual") {
+		writeError(w, http.StatusConflict, name+" is already running")
+		return
+	}
+	slog.InfoContext(r.Context(), "schedule.triggered", "job", name)
+	writeJSON(w, http.StatusAccepted, job.status())
+}
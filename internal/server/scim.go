@@ -0,0 +1,444 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+)
+
+const (
+	scimPath        = "/scim/v2"
+	scimMediaType   = "application/scim+json"
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+	// maxSCIMPage bounds the count of a user listing.
+	maxSCIMPage = 200
+)
+
+// WithSCIM serves the SCIM 2.0 Users endpoints under /scim/v2 to identity
+// providers presenting token as a bearer token, so they provision and
+// deprovision accounts. Deactivating a user also ends their sessions. It
+// needs accounts (WithAuth) and a store implementing db.UserProvisioner.
+func WithSCIM(token string) Option {
+	return func(s *Server) {
+		s.scimToken = token
+	}
+}
+
+func (s *Server) scimRoutes(r chi.Router) {
+	r.Use(s.requireSCIM)
+	r.Get("/Users", s.handleSCIMListUsers)
+	r.Post("/Users", s.handleSCIMCreateUser)
+	r.Get("/Users/{id}", s.handleSCIMGetUser)
+	r.Put("/Users/{id}", s.handleSCIMReplaceUser)
+	r.Patch("/Users/{id}", s.handleSCIMPatchUser)
+	r.Delete("/Users/{id}", s.handleSCIMDeleteUser)
+}
+
+// requireSCIM rejects requests without the SCIM bearer token.
+func (s *Server) requireSCIM(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.scimToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			writeSCIMError(w, http.StatusUnauthorized, "", "scim token required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UserActive reports whether user id may keep using their session. Only
+// provisioned deployments deactivate users, so it is true otherwise.
+func (s *Server) UserActive(ctx context.Context, id int64) (bool, error) {
+	if s.scimToken == "" {
+		return true, nil
+	}
+	users, ok := s.store.(db.UserStore)
+	if !ok {
+		return true, nil
+	}
+	u, err := users.GetUser(ctx, id)
+	if errors.Is(err, db.ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !u.Deactivated, nil
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	Location     string    `json:"location"`
+}
+
+// scimUser is a User resource. userName is the user's email; requests
+// that leave it out fall back to the primary email. Attributes this server
+// does not keep, such as name, are accepted and dropped.
+type scimUser struct {
+	Schemas    []string    `json:"schemas"`
+	ID         string      `json:"id,omitempty"`
+	ExternalID string      `json:"externalId,omitempty"`
+	UserName   string      `json:"userName"`
+	Active     *bool       `json:"active,omitempty"`
+	Emails     []scimEmail `json:"emails,omitempty"`
+	Meta       *scimMeta   `json:"meta,omitempty"`
+}
+
+func toSCIMUser(u db.User) scimUser {
+	active := !u.Deactivated
+	return scimUser{
+		Schemas:    []string{scimUserSchema},
+		ID:         strconv.FormatInt(u.ID, 10),
+		ExternalID: u.ExternalID,
+		UserName:   u.Email,
+		Active:     &active,
+		Emails:     []scimEmail{{Value: u.Email, Type: "work", Primary: true}},
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      u.CreatedAt.UTC(),
+			Location:     scimPath + "/Users/" + strconv.FormatInt(u.ID, 10),
+		},
+	}
+}
+
+// input returns the provisioned fields of u; active defaults to true.
+func (u scimUser) input() db.ProvisionedUser {
+	email := u.UserName
+	if email == "" {
+		for _, e := range u.Emails {
+			if e.Primary || email == "" {
+				email = e.Value
+			}
+		}
+	}
+	return db.ProvisionedUser{Email: email, ExternalID: u.ExternalID, Deactivated: u.Active != nil && !*u.Active}
+}
+
+func writeSCIM(w http.ResponseWriter, status int, v any) {
+	writeJSONAs(w, status, scimMediaType, v)
+}
+
+// writeSCIMError writes a SCIM error; scimType may be empty.
+func writeSCIMError(w http.ResponseWriter, status int, scimType, detail string) {
+	writeSCIM(w, status, struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		SCIMType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail"`
+	}{[]string{scimErrorSchema}, strconv.Itoa(status), scimType, detail})
+}
+
+// scimProvisioner returns the backend's provisioning support, writing 501
+// when missing.
+func (s *Server) scimProvisioner(w http.ResponseWriter) (db.UserProvisioner, bool) {
+	users, ok := s.store.(db.UserProvisioner)
+	if !ok {
+		writeSCIMError(w, http.StatusNotImplemented, "", "user provisioning not supported by storage backend")
+	}
+	return users, ok
+}
+
+// writeProvisionError maps a failed create or update to its SCIM error.
+func writeProvisionError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrEmailTaken):
+		writeSCIMError(w, http.StatusConflict, "uniqueness", "userName already in use")
+	case errors.Is(err, db.ErrUserNotFound):
+		writeSCIMError(w, http.StatusNotFound, "", "user not found")
+	case errors.Is(err, db.ErrInvalidUser):
+		writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+	default:
+		slog.ErrorContext(ctx, "scim.write_failed", "error", err)
+		writeSCIMError(w, http.StatusInternalServerError, "", "failed to save user")
+	}
+}
+
+// scimUserFilter matches the one filter identity providers send before
+// creating a user: userName eq "...".
+var scimUserFilter = regexp.MustCompile(`(?i)^\s*userName\s+eq\s+"([^"]*)"\s*$`)
+
+// handleSCIMListUsers lists users, 1-based by startIndex and count (default
+// 100), optionally filtered by userName.
+func (s *Server) handleSCIMListUsers(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.scimProvisioner(w)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	var email string
+	if f := q.Get("filter"); f != "" {
+		m := scimUserFilter.FindStringSubmatch(f)
+		if m == nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", `only userName eq "..." filters are supported`)
+			return
+		}
+		if email = m[1]; email == "" {
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", "userName must not be empty")
+			return
+		}
+	}
+	start, count := 1, 100
+	if v := q.Get("startIndex"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", "invalid startIndex")
+			return
+		}
+		start = max(n, 1)
+	}
+	if v := q.Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", "invalid count")
+			return
+		}
+		count = min(max(n, 0), maxSCIMPage)
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	page, total, err := users.ListUsers(ctx, email, start-1, count)
+	if err != nil {
+		writeSCIMError(w, http.StatusInternalServerError, "", "failed to list users")
+		return
+	}
+	resources := make([]scimUser, len(page))
+	for i, u := range page {
+		resources[i] = toSCIMUser(u)
+	}
+	writeSCIM(w, http.StatusOK, struct {
+		Schemas      []string   `json:"schemas"`
+		TotalResults int        `json:"totalResults"`
+		StartIndex   int        `json:"startIndex"`
+		ItemsPerPage int        `json:"itemsPerPage"`
+		Resources    []scimUser `json:"Resources"`
+	}{[]string{scimListSchema}, total, start, len(resources), resources})
+}
+
+func decodeSCIMUser(w http.ResponseWriter, r *http.Request) (scimUser, bool) {
+	var req scimUser
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", "invalid JSON body")
+		return req, false
+	}
+	return req, true
+}
+
+// scimUserID parses the {id} of a user route; SCIM answers unknown ids,
+// malformed ones included, with 404.
+func scimUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeSCIMError(w, http.StatusNotFound, "", "user not found")
+		return 0, false
+	}
+	return id, true
+}
+
+func (s *Server) handleSCIMCreateUser(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.scimProvisioner(w)
+	if !ok {
+		return
+	}
+	req, ok := decodeSCIMUser(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	u, err := users.ProvisionUser(ctx, req.input())
+	if err != nil {
+		writeProvisionError(ctx, w, err)
+		return
+	}
+	res := toSCIMUser(u)
+	w.Header().Set("Location", res.Meta.Location)
+	writeSCIM(w, http.StatusCreated, res)
+}
+
+func (s *Server) handleSCIMGetUser(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.scimProvisioner(w)
+	if !ok {
+		return
+	}
+	id, ok := scimUserID(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	u, err := users.GetUser(ctx, id)
+	if errors.Is(err, db.ErrUserNotFound) {
+		writeSCIMError(w, http.StatusNotFound, "", "user not found")
+		return
+	}
+	if err != nil {
+		writeSCIMError(w, http.StatusInternalServerError, "", "failed to load user")
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMUser(u))
+}
+
+// handleSCIMReplaceUser replaces a user; an omitted active means active.
+func (s *Server) handleSCIMReplaceUser(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.scimProvisioner(w)
+	if !ok {
+		return
+	}
+	id, ok := scimUserID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeSCIMUser(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	u, err := users.UpdateUser(ctx, id, req.input())
+	if err != nil {
+		writeProvisionError(ctx, w, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMUser(u))
+}
+
+type scimPatchRequest struct {
+	Schemas    []string `json:"schemas"`
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+// handleSCIMPatchUser applies add, replace and remove operations to active,
+// userName and externalId, with a path or as a value object without one.
+// Operations on attributes this server does not keep are ignored.
+func (s *Server) handleSCIMPatchUser(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.scimProvisioner(w)
+	if !ok {
+		return
+	}
+	id, ok := scimUserID(w, r)
+	if !ok {
+		return
+	}
+	var req scimPatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	u, err := users.GetUser(ctx, id)
+	if err != nil {
+		writeProvisionError(ctx, w, err)
+		return
+	}
+	input := db.ProvisionedUser{Email: u.Email, ExternalID: u.ExternalID, Deactivated: u.Deactivated}
+	for _, op := range req.Operations {
+		var err error
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path == "" {
+				var values map[string]json.RawMessage
+				if err = json.Unmarshal(op.Value, &values); err == nil {
+					for path, value := range values {
+						if err = patchSCIMAttribute(&input, path, value); err != nil {
+							break
+						}
+					}
+				}
+			} else {
+				err = patchSCIMAttribute(&input, op.Path, op.Value)
+			}
+		case "remove":
+			if strings.EqualFold(op.Path, "externalId") {
+				input.ExternalID = ""
+			}
+		default:
+			err = errors.New("unsupported op " + op.Op)
+		}
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+	if u, err = users.UpdateUser(ctx, id, input); err != nil {
+		writeProvisionError(ctx, w, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMUser(u))
+}
+
+// patchSCIMAttribute sets one attribute of input. Some providers send
+// active as the string "True" or "False".
+func patchSCIMAttribute(input *db.ProvisionedUser, path string, value json.RawMessage) error {
+	switch strings.ToLower(path) {
+	case "active":
+		var active bool
+		if err := json.Unmarshal(value, &active); err != nil {
+			var text string
+			if json.Unmarshal(value, &text) != nil {
+				return errors.New("active must be a boolean")
+			}
+			if active, err = strconv.ParseBool(text); err != nil {
+				return errors.New("active must be a boolean")
+			}
+		}
+		input.Deactivated = !active
+	case "username":
+		if err := json.Unmarshal(value, &input.Email); err != nil {
+			return errors.New("userName must be a string")
+		}
+	case "externalid":
+		if err := json.Unmarshal(value, &input.ExternalID); err != nil {
+			return errors.New("externalId must be a string")
+		}
+	}
+	return nil
+}
+
+// handleSCIMDeleteUser deprovisions a user by deactivating them: deleting
+// the account would delete their todos and lists with it.
+func (s *Server) handleSCIMDeleteUser(w http.ResponseWriter, r *http.Request) {
+	users, ok := s.scimProvisioner(w)
+	if !ok {
+		return
+	}
+	id, ok := scimUserID(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	u, err := users.GetUser(ctx, id)
+	if err == nil {
+		_, err = users.UpdateUser(ctx, id, db.ProvisionedUser{Email: u.Email, ExternalID: u.ExternalID, Deactivated: true})
+	}
+	if err != nil {
+		writeProvisionError(ctx, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
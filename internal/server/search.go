@@ -0,0 +1,161 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// maxSearchQuery bounds the length of a search query in bytes.
+const maxSearchQuery = 256
+
+// defaultRecencyHalfLife is the SearchRanking recency half-life when unset.
+const defaultRecencyHalfLife = 7 * 24 * time.Hour
+
+// SearchRanking weighs what orders search results. Each component is on a
+// 0 to 1 scale: text relevance relative to the best match, the priority
+// score (effectivePriority when decay is on) and recency, which halves
+// every RecencyHalfLife since the todo's last update. The zero value ranks
+// by text relevance alone.
+type SearchRanking struct {
+	Text            float64
+	Priority        float64
+	Recency         float64
+	RecencyHalfLife time.Duration
+}
+
+// blended reports whether anything besides text relevance counts.
+func (r SearchRanking) blended() bool {
+	return r.Priority > 0 || r.Recency > 0
+}
+
+// WithSearchRanking blends priority and recency into the order of search
+// results. The recency half-life defaults to a week.
+func WithSearchRanking(r SearchRanking) Option {
+	return func(s *Server) {
+		s.searchRanking = r
+	}
+}
+
+// searchComponents are the parts of a result's blended score, returned
+// with ?debug=true for tuning the weights.
+type searchComponents struct {
+	Text     float64 `json:"text"`
+	Priority float64 `json:"priority"`
+	Recency  float64 `json:"recency"`
+	Score    float64 `json:"score"`
+}
+
+type searchHit struct {
+	db.SearchResult
+	Components *searchComponents `json:"components,omitempty"`
+}
+
+// handleSearchTodos runs a full-text search over todo titles and tags
+// (?q=..., optional limit, default 20) and returns the matches best first,
+// each with its rank and a highlighted snippet of the title. With
+// ?debug=true each result also carries the components of its score.
+func (s *Server) handleSearchTodos(w http.ResponseWriter, r *http.Request) {
+	searcher, ok := s.store.(db.Searcher)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, db.ErrSearchUnsupported.Error())
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	if len(q) > maxSearchQuery {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("q must be at most %d bytes", maxSearchQuery))
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > db.MaxSearchResults {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", db.MaxSearchResults))
+			return
+		}
+		limit = n
+	}
+	debug := r.URL.Query().Get("debug") == "true"
+
+	// Blending can promote a match the text rank alone would cut, so fetch
+	// more candidates than asked for.
+	fetch := limit
+	if s.searchRanking.blended() {
+		fetch = min(4*limit, db.MaxSearchResults)
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	results, err := searcher.SearchTodos(ctx, q, fetch)
+	if errors.Is(err, db.ErrSearchUnsupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "search failed")
+		return
+	}
+	for i := range results {
+		results[i].Todo = s.present(results[i].Todo)
+	}
+	hits := s.rankSearch(results, time.Now())
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	if !debug {
+		for i := range hits {
+			hits[i].Components = nil
+		}
+	}
+	writeJSON(w, http.StatusOK, hits)
+}
+
+// rankSearch scores results, which come best text rank first, and orders
+// them by blended score. Ties keep the text order.
+func (s *Server) rankSearch(results []db.SearchResult, now time.Time) []searchHit {
+	weights := s.searchRanking
+	if !weights.blended() {
+		weights.Text = 1
+	}
+	if weights.RecencyHalfLife <= 0 {
+		weights.RecencyHalfLife = defaultRecencyHalfLife
+	}
+	var best float64
+	for _, res := range results {
+		best = math.Max(best, res.Rank)
+	}
+	hits := make([]searchHit, len(results))
+	for i, res := range results {
+		c := &searchComponents{Priority: res.PriorityScore, Recency: 1}
+		if best > 0 {
+			c.Text = res.Rank / best
+		}
+		if res.EffectivePriority != nil {
+			c.Priority = *res.EffectivePriority
+		}
+		if age := now.Sub(res.UpdatedAt); age > 0 {
+			c.Recency = math.Exp2(-age.Seconds() / weights.RecencyHalfLife.Seconds())
+		}
+		c.Text, c.Priority, c.Recency = round4(c.Text), round4(c.Priority), round4(c.Recency)
+		c.Score = round4(weights.Text*c.Text + weights.Priority*c.Priority + weights.Recency*c.Recency)
+		hits[i] = searchHit{SearchResult: res, Components: c}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Components.Score > hits[j].Components.Score
+	})
+	return hits
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
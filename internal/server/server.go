@@ -2,75 +2,424 @@ package server
 
 import (
 	"context"
-	"database/sql"
 	"embed"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"todoapp/internal/accesslog"
+	"todoapp/internal/alert"
+	"todoapp/internal/auth"
+	"todoapp/internal/cron"
 	"todoapp/internal/db"
+	"todoapp/internal/hooks"
+	"todoapp/internal/idcodec"
+	"todoapp/internal/inbound"
+	"todoapp/internal/logging"
 	"todoapp/internal/mlclient"
+	"todoapp/internal/slo"
+	"todoapp/internal/telemetry"
 )
 
 // We declare a dummy variable to ensure the embed package is retained in builds even if not used directly elsewhere in this file.
 var _ embed.FS
 
 type Server struct {
-	store  *db.Store
-	static fs.FS
-	scorer priorityScorer
+	store      db.Store
+	static     fs.FS
+	scorer     priorityScorer
+	adminToken string
+	limits     TodoLimits
+	pow        *proofOfWork
+	inbound    *inbound.Receiver
+	hypermedia bool
+	hideEffort bool
+	streams    *streamTracker
+	// internalAdmin moves the admin API off the public handler; it is then
+	// only reachable through AdminHandler.
+	internalAdmin bool
+	accessLog     *accesslog.Logger
+	alerts        *alert.Monitor
+	objectives    *slo.Tracker
+	shedder       *gradientLimiter
+	ipLimiter     *rateLimiter
+	userLimiter   *rateLimiter
+	sessions      *auth.Signer
+	events        *eventHub
+	lists         *listCache
+	features      *featureCache
+	metrics       bool
+	pushMetrics   bool
+	liveReload    bool
+	branding      Branding
+	readiness     Readiness
+	hooks         *hooks.Registry
+	reports       *reports
+	// webhookWake nudges RunWebhookDeliveries when deliveries are queued.
+	webhookWake     chan struct{}
+	webhookDispatch WebhookDispatch
+	trustedProxies  []netip.Prefix
+	// rescoring is held while a re-scoring run is in progress.
+	rescoring sync.Mutex
+	// completions holds batch completions open to undo.
+	completions *completionUndo
+	// errorRate feeds the health score's error component.
+	errorRate *errorWindow
+	// jobs counts background job runs.
+	jobs *jobTracker
+	// priorityHalfLife decays effectivePriority; zero disables it.
+	priorityHalfLife time.Duration
+	// searchRanking blends search relevance with priority and recency.
+	searchRanking SearchRanking
+	// scimToken enables the SCIM provisioning API.
+	scimToken string
+	// signing, when set, requires bearer-token requests to be signed.
+	signing *requestSigning
+	// anonymization configures the retention job.
+	anonymization Anonymization
+	// status serves the public status page when set.
+	status *statusPage
+	// journal records mutating requests when set.
+	journal *requestJournal
+	// cdn marks the responses a CDN may cache when set.
+	cdn *CDN
+	// spec checks request and response bodies against the OpenAPI
+	// document when set.
+	spec *specValidator
+	// deprecations is nil when openapi.json marks nothing deprecated.
+	deprecations *deprecationTracker
+	// rescoreSchedule is when open todos are re-scored; schedules holds
+	// the jobs RunSchedules runs, built from it and the other jobs'
+	// configuration.
+	rescoreSchedule cron.Schedule
+	schedules       []*scheduledJob
+	// eventLog exports the live events to files when set.
+	eventLog *eventLog
+	// ids spells the ids of todos, lists and snapshots in the API, each
+	// kind through its own codec, when set.
+	ids map[idKind]idcodec.Codec
+	// deferred queues todos whose scoring a request skipped; nil scores
+	// every todo inline.
+	deferred *scoreDeferrer
+	// logLevel is the process's adjustable log level.
+	logLevel *slog.LevelVar
+}
+
+// Option configures optional Server behaviour.
+type Option func(*Server)
+
+// WithAdminToken enables the /api/admin routes, guarded by the given bearer token.
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
 }
 
 type priorityScorer interface {
 	Score(ctx context.Context, todo mlclient.TodoPayload) (float64, error)
 }
 
-func NewServer(store *db.Store, staticFS fs.FS, scorer priorityScorer) *Server {
-	return &Server{store: store, static: staticFS, scorer: scorer}
+// WithTodoLimits caps how many todos may exist; creates beyond a limit are
+// rejected with 422.
+func WithTodoLimits(limits TodoLimits) Option {
+	return func(s *Server) {
+		s.limits = limits
+	}
+}
+
+// WithProofOfWork requires anonymous clients to solve a hashcash challenge
+// of the given difficulty (leading zero bits) before creating todos.
+// Zero disables the check.
+func WithProofOfWork(difficulty int) Option {
+	return func(s *Server) {
+		if difficulty > 0 {
+			s.pow = newProofOfWork(difficulty)
+		}
+	}
+}
+
+// WithInboundWebhooks serves POST /api/integrations/{provider}/webhook
+// through the given receiver.
+func WithInboundWebhooks(rcv *inbound.Receiver) Option {
+	return func(s *Server) {
+		s.inbound = rcv
+	}
+}
+
+// WithHypermedia adds _links to every todo response. Clients can also opt in
+// per request with "Accept: application/vnd.todo.v2+json".
+func WithHypermedia(enabled bool) Option {
+	return func(s *Server) {
+		s.hypermedia = enabled
+	}
+}
+
+// WithPriorityDecay adds effectivePriority to todos: their priority score
+// halved every halfLife since their last update. Zero leaves it out.
+func WithPriorityDecay(halfLife time.Duration) Option {
+	return func(s *Server) {
+		s.priorityHalfLife = halfLife
+	}
+}
+
+// WithEffort toggles effort estimates. When disabled, effort is neither
+// accepted nor returned and the velocity stats endpoint is unavailable, for
+// teams that do not estimate.
+func WithEffort(enabled bool) Option {
+	return func(s *Server) {
+		s.hideEffort = !enabled
+	}
+}
+
+// WithInternalAdmin serves the admin API only from AdminHandler, meant for a
+// separate internal-only listener, instead of the public handler.
+func WithInternalAdmin(enabled bool) Option {
+	return func(s *Server) {
+		s.internalAdmin = enabled
+	}
+}
+
+// WithAccessLog writes a web-server style access log line for every request
+// on the public handler.
+func WithAccessLog(l *accesslog.Logger) Option {
+	return func(s *Server) {
+		s.accessLog = l
+	}
+}
+
+// WithAlerts feeds request outcomes ("http", 5xx counts as failure) and ML
+// scoring outcomes ("ml") into m.
+func WithAlerts(m *alert.Monitor) Option {
+	return func(s *Server) {
+		s.alerts = m
+	}
+}
+
+// WithSLO records every request's status and latency against t's objectives.
+func WithSLO(t *slo.Tracker) Option {
+	return func(s *Server) {
+		s.objectives = t
+	}
+}
+
+// NewServer returns a Server for store. staticFS is the root of the
+// frontend, holding index.html; a nil staticFS serves the API only.
+func NewServer(store db.Store, staticFS fs.FS, scorer priorityScorer, opts ...Option) *Server {
+	s := &Server{store: store, static: staticFS, scorer: scorer, streams: newStreamTracker(), events: newEventHub(), webhookWake: make(chan struct{}, 1), errorRate: newErrorWindow(healthErrorWindow), jobs: newJobTracker(), completions: newCompletionUndo()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.schedules = s.scheduledJobs()
+	s.deprecations = newDeprecationTracker(time.Now())
+	s.events.tee = s.eventLog
+	if s.metrics || s.pushMetrics {
+		s.registerMetrics()
+	}
+	if s.adminToken != "" {
+		s.registerBusinessMetrics()
+	}
+	return s
 }
 
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 
 	// Basic hardening headers and middleware
-	r.Use(middleware.RealIP)
+	r.Use(s.realIP)
+	r.Use(redactTokens)
 	r.Use(middleware.RequestID)
+	r.Use(logContext)
+	r.Use(instrument)
 	r.Use(middleware.Recoverer)
+	if s.accessLog != nil {
+		r.Use(s.accessLog.Middleware)
+	}
 	r.Use(requestLogger)
+	r.Use(s.debugQueries)
+	if s.alerts != nil {
+		r.Use(s.recordOutcome)
+	}
+	r.Use(s.trackErrors)
+	if s.objectives != nil {
+		r.Use(s.trackSLO)
+	}
+	if s.journal != nil {
+		r.Use(s.recordRequest)
+	}
+	if s.ipLimiter != nil {
+		r.Use(s.limitByIP)
+	}
+	if s.shedder != nil {
+		r.Use(s.shedLoad)
+	}
 	r.Use(s.securityHeaders)
+	r.Use(negotiateFeatures)
+	if s.cdn != nil {
+		r.Use(s.noSurrogateStore)
+	}
+	// Ids are translated outside spec validation, which expects numbers.
+	if s.ids != nil {
+		r.Use(s.translateIDs)
+	}
+	if s.deprecations != nil {
+		r.Use(s.trackDeprecations)
+	}
+	if s.spec != nil {
+		r.Use(s.validateAgainstSpec)
+	}
+
+	if s.sessions != nil {
+		r.Route("/api/auth", s.authRoutes)
+	}
+
+	// Everything a user owns; requireUser passes through when accounts
+	// are disabled.
+	r.Group(func(r chi.Router) {
+		r.Use(s.requireUser)
+		if s.userLimiter != nil {
+			r.Use(s.limitByUser)
+		}
+		if s.deferred != nil {
+			r.Use(s.deferScores)
+		}
+
+		r.Route("/api/todos", func(r chi.Router) {
+			r.Get("/", s.handleListTodos)
+			r.Get("/stream", s.handleStreamTodos)
+			r.Get("/events", s.handleTodoEvents)
+			r.Get("/search", s.handleSearchTodos)
+			r.With(s.requireProofOfWork).Post("/", s.handleCreateTodo)
+			r.Delete("/", s.handleBulkDeleteTodos)
+			r.Post("/retag", s.handleRetagTodos)
+			r.Post("/complete", s.handleCompleteTodos)
+			r.Post("/uncomplete-last-batch", s.handleUncompleteLastBatch)
+			r.With(s.requireProofOfWork).Post("/bulk", s.handleBulkTodos)
+			r.Get("/export", s.handleExportTodos)
+			r.Post("/import", s.handleImportTodos)
+			r.Get("/{id}", s.handleGetTodo)
+			r.Put("/{id}", s.handleUpdateTodo)
+			r.Patch("/{id}", s.handlePatchTodo)
+			r.Delete("/{id}", s.handleDeleteTodo)
+			r.Get("/{id}/history", s.handleTodoHistory)
+			r.Get("/{id}/time", s.handleTimeEntries)
+			r.Post("/{id}/time", s.handleTrackTime)
+			r.Post("/{id}/move-to-list", s.handleMoveTodo)
+		})
+
+		r.Route("/api/lists", func(r chi.Router) {
+			r.Get("/", s.handleListLists)
+			r.Post("/", s.handleCreateList)
+			r.Get("/icons", s.handleListIcons)
+			r.Get("/{id}", s.handleGetList)
+			r.Put("/{id}", s.handleUpdateList)
+			r.Delete("/{id}", s.handleDeleteList)
+			r.Put("/{id}/archive", s.handleArchiveList)
+			r.Delete("/{id}/archive", s.handleArchiveList)
+			r.With(s.requireProofOfWork).Post("/{id}/duplicate", s.handleDuplicateList)
+			r.Get("/{id}/snapshots", s.handleListSnapshots)
+			r.Post("/{id}/snapshot", s.handleSnapshotList)
+			r.Post("/{id}/restore/{snapshotId}", s.handleRestoreList)
+			r.Get("/{id}/todos", s.handleListListTodos)
+			r.With(s.requireProofOfWork).Post("/{id}/todos", s.handleCreateListTodo)
+		})
+
+		r.Route("/api/rules", func(r chi.Router) {
+			r.Get("/", s.handleListRules)
+			r.Post("/", s.handleCreateRule)
+			r.Get("/{id}", s.handleGetRule)
+			r.Put("/{id}", s.handleUpdateRule)
+			r.Delete("/{id}", s.handleDeleteRule)
+			r.Get("/{id}/runs", s.handleListRuleRuns)
+		})
+		r.Route("/api/subscriptions", func(r chi.Router) {
+			r.Get("/", s.handleListSubscriptions)
+			r.Post("/", s.handleCreateSubscription)
+			r.Delete("/{id}", s.handleDeleteSubscription)
+		})
+		r.Route("/api/webhooks", func(r chi.Router) {
+			r.Get("/", s.handleListWebhooks)
+			r.Post("/", s.handleCreateWebhook)
+			r.Get("/{id}", s.handleGetWebhook)
+			r.Put("/{id}", s.handleUpdateWebhook)
+			r.Delete("/{id}", s.handleDeleteWebhook)
+			r.Get("/{id}/deliveries", s.handleListWebhookDeliveries)
+		})
+		r.Route("/api/intake-hooks", func(r chi.Router) {
+			r.Get("/", s.handleListIntakeHooks)
+			r.Post("/", s.handleCreateIntakeHook)
+			r.Put("/{id}", s.handleUpdateIntakeHook)
+			r.Delete("/{id}", s.handleDeleteIntakeHook)
+			r.Post("/{id}/token", s.handleRotateIntakeHookToken)
+		})
+
+		r.Route("/api/views/{view}", func(r chi.Router) {
+			r.Get("/order", s.handleGetViewOrder)
+			r.Put("/order", s.handleSetViewOrder)
+		})
 
-	r.Route("/api/todos", func(r chi.Router) {
-		r.Get("/", s.handleListTodos)
-		r.Post("/", s.handleCreateTodo)
-		r.Put("/{id}", s.handleUpdateTodo)
-		r.Delete("/{id}", s.handleDeleteTodo)
+		r.Get("/api/stats", s.handleStats)
+		r.Get("/api/stats/velocity", s.handleVelocity)
+		r.Get("/api/stats/estimates", s.handleEstimateAccuracy)
+		r.Get("/api/reports/stale", s.handleStaleReport)
+
+		r.Get("/api/preferences", s.handleGetPreferences)
+		r.Put("/api/preferences", s.handleSetPreferences)
 	})
 
-	// Serve static frontend
-	web, err := fs.Sub(s.static, "web")
-	if err != nil {
-		// If embedding fails, provide a helpful message
-		r.Get("/*", func(w http.ResponseWriter, r *http.Request) {
-			http.Error(w, "static assets not found", http.StatusInternalServerError)
+	r.Get("/health", handleHealth)
+	r.Get("/ready", s.handleReady)
+	if s.status != nil {
+		r.Get("/status", s.handleStatusPage)
+		r.Get("/status.css", s.serveStatusCSS)
+	}
+	r.Get("/api/openapi.json", s.handleOpenAPI)
+	r.Post("/api/integrations/{provider}/webhook", s.handleInboundWebhook)
+	r.Post("/api/hooks/{token}", s.handleIntake)
+	if s.scimToken != "" {
+		r.Route(scimPath, s.scimRoutes)
+	}
+
+	if !s.internalAdmin {
+		r.Route("/api/admin", s.adminRoutes)
+		r.Route("/admin", s.adminUIRoutes)
+		r.With(s.requireAdmin).Post("/api/todos/rescore", s.handleRescore)
+		if s.metrics {
+			r.Handle("/metrics", telemetry.Handler())
+		}
+	}
+
+	// API-only mode: the frontend is served elsewhere (e.g. a CDN), so
+	// everything outside the API is a JSON 404.
+	if s.static == nil {
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusNotFound, "not found")
 		})
 		return r
 	}
-	fileServer := http.FileServer(http.FS(web))
+
+	// Serve static frontend
+	fileServer := http.FileServer(http.FS(s.static))
 
 	// Serve index.html for root and unknown paths (client-side navigation not needed but friendly)
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		http.ServeFileFS(w, r, web, "index.html")
-	})
-	r.Handle("/*", fileServer)
+	r.Get("/", s.serveIndex)
+	r.Get(brandingStylesheet, s.serveBrandingStylesheet)
+	if s.liveReload {
+		r.Get(liveReloadScript, serveLiveReloadScript)
+		r.Get(liveReloadEvents, s.handleLiveReload)
+	}
+	r.Handle("/*", s.cacheStatic(fileServer))
 
 	return r
 }
@@ -80,9 +429,9 @@ func requestLogger(next http.Handler) http.Handler {
 		start := time.Now()
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 		next.ServeHTTP(ww, r)
-		slog.Info("request",
+		slog.InfoContext(r.Context(), "request",
 			"method", r.Method,
-			"path", r.URL.Path,
+			"path", redactPath(r.URL.Path),
 			"status", ww.Status(),
 			"bytes", ww.BytesWritten(),
 			"duration_ms", time.Since(start).Milliseconds(),
@@ -90,13 +439,51 @@ func requestLogger(next http.Handler) http.Handler {
 	})
 }
 
+// recordOutcome reports each response to the alert monitor.
+func (s *Server) recordOutcome(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		s.alerts.Record("http", ww.Status() >= http.StatusInternalServerError)
+	})
+}
+
+// trackSLO reports each response's status and latency to the SLO tracker.
+// Streaming responses are skipped: their duration is the client's session
+// length, not latency.
+func (s *Server) trackSLO(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		if !isLongLived(r.URL.Path) {
+			s.objectives.Record(redactPath(r.URL.Path), ww.Status(), time.Since(start))
+		}
+	})
+}
+
+// logContext tags every log record written and every todo change made while
+// serving the request with its request id.
+func logContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := middleware.GetReqID(r.Context())
+		ctx := db.WithRequestID(logging.With(r.Context(), "request_id", id), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (s *Server) securityHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "DENY")
 		w.Header().Set("Referrer-Policy", "no-referrer")
 		w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self'; style-src 'self'; img-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'none'")
+		if s.static == nil {
+			// Nothing served here is meant to be rendered by a browser.
+			w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+		} else {
+			w.Header().Set("Content-Security-Policy", s.contentSecurityPolicy())
+		}
 		// HSTS only makes sense behind HTTPS; harmless if HTTP
 		w.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains; preload")
 		next.ServeHTTP(w, r)
@@ -106,18 +493,207 @@ func (s *Server) securityHeaders(next http.Handler) http.Handler {
 func (s *Server) handleListTodos(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	items, err := s.store.ListTodos(ctx)
+
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Deferred todos stay out of the default view until their start date.
+	if r.URL.Query().Get("includeDeferred") != "true" {
+		filter.AvailableAt = time.Now()
+	}
+	// So do the todos of archived lists, unless that list is asked for.
+	if r.URL.Query().Get("includeArchived") != "true" && filter.ListID == 0 {
+		filter.HideArchived = true
+	}
+
+	opts, paged, err := parseListOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	view := r.URL.Query().Get("view")
+	if paged {
+		if view != "" {
+			writeError(w, http.StatusBadRequest, "view cannot be combined with sort or pagination")
+			return
+		}
+		s.listTodosPage(ctx, w, r, filter, opts)
+		return
+	}
+
+	if view == "" && filter == (db.TodoFilter{AvailableAt: filter.AvailableAt, HideArchived: filter.HideArchived}) && s.serveCachedList(ctx, w, r, filter) {
+		return
+	}
+
+	var items []db.Todo
+	if filter == (db.TodoFilter{}) {
+		items, err = s.store.ListTodos(ctx)
+	} else if lister, ok := s.store.(db.FilteredLister); ok {
+		items, err = lister.ListTodosFiltered(ctx, filter)
+	} else {
+		writeError(w, http.StatusNotImplemented, "filtering not supported by storage backend")
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list todos")
 		return
 	}
-	writeJSON(w, http.StatusOK, items)
+	if view != "" {
+		if !db.ValidViewKey(view) {
+			writeError(w, http.StatusBadRequest, "invalid view")
+			return
+		}
+		items, err = s.orderByView(ctx, view, items)
+		if errors.Is(err, errUnsupportedView) {
+			writeError(w, http.StatusNotImplemented, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to order todos")
+			return
+		}
+	}
+	s.writeTodos(w, r, http.StatusOK, items)
+}
+
+// listTodosPage serves a sorted page. The body stays a plain array; the next
+// page is advertised through X-Next-Cursor and a Link rel="next" header.
+// Titles are sorted for the caller's locale preference.
+func (s *Server) listTodosPage(ctx context.Context, w http.ResponseWriter, r *http.Request, filter db.TodoFilter, opts db.ListOptions) {
+	pager, ok := s.store.(db.PagedLister)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "sorting not supported by storage backend")
+		return
+	}
+	if opts.Sort == db.SortTitle {
+		opts.Locale = s.preferences(ctx).Locale
+	}
+	items, next, err := pager.ListTodosPage(ctx, filter, opts)
+	if errors.Is(err, db.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list todos")
+		return
+	}
+	if next != "" {
+		q := r.URL.Query()
+		q.Set("cursor", next)
+		u := *r.URL
+		u.RawQuery = q.Encode()
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", "<"+u.RequestURI()+`>; rel="next"`)
+	}
+	s.writeTodos(w, r, http.StatusOK, items)
+}
+
+// parseListOptions reads sort, order, limit and cursor. paged reports whether
+// any of them was given; without them the list keeps its unpaged behaviour.
+func parseListOptions(r *http.Request) (opts db.ListOptions, paged bool, err error) {
+	q := r.URL.Query()
+	for _, key := range []string{"sort", "order", "limit", "cursor"} {
+		if q.Has(key) {
+			paged = true
+		}
+	}
+	opts.Sort = q.Get("sort")
+	if opts.Sort != "" && !db.ValidSort(opts.Sort) {
+		return opts, paged, errors.New("sort must be one of created_at, priority_score, duration, title")
+	}
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, paged, errors.New("order must be asc or desc")
+	}
+	if v := q.Get("limit"); v != "" {
+		opts.Limit, err = strconv.Atoi(v)
+		if err != nil || opts.Limit < 1 || opts.Limit > db.MaxPageSize {
+			return opts, paged, fmt.Errorf("limit must be between 1 and %d", db.MaxPageSize)
+		}
+	}
+	opts.Cursor = q.Get("cursor")
+	return opts, paged, nil
+}
+
+// parseTodoFilter reads the list filter query parameters shared by the list,
+// stream and bulk delete endpoints.
+func parseTodoFilter(r *http.Request) (db.TodoFilter, error) {
+	q := r.URL.Query()
+	var filter db.TodoFilter
+	if v := q.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return db.TodoFilter{}, errors.New("invalid completed filter")
+		}
+		filter.Completed = &completed
+	}
+	filter.Tag = strings.TrimSpace(strings.ToLower(q.Get("tag")))
+	if v := q.Get("listId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return db.TodoFilter{}, errors.New("invalid listId filter")
+		}
+		filter.ListID = id
+	}
+	if v := q.Get("olderThan"); v != "" {
+		age, err := parseAge(v)
+		if err != nil {
+			return db.TodoFilter{}, errors.New("invalid olderThan filter")
+		}
+		filter.CreatedBefore = time.Now().Add(-age)
+	}
+	return filter, nil
+}
+
+func (s *Server) handleGetTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	item, err := s.store.GetTodo(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "todo not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load todo")
+		return
+	}
+	s.writeTodo(w, r, http.StatusOK, item)
 }
 
 type createTodoRequest struct {
-	Title           string   `json:"title"`
-	Tags            []string `json:"tags"`
-	DurationMinutes int      `json:"durationMinutes"`
+	Title           string     `json:"title"`
+	Tags            []string   `json:"tags"`
+	DurationMinutes int        `json:"durationMinutes"`
+	DueAt           *time.Time `json:"dueAt"`
+	StartAt         *time.Time `json:"startAt"`
+	Effort          *int       `json:"effort"`
+	ReminderOffsets []int      `json:"reminderOffsets"`
+	Recurrence      string     `json:"recurrence"`
+	ListID          *int64     `json:"listId"`
+}
+
+func (r createTodoRequest) input() db.SaveTodoInput {
+	return db.SaveTodoInput{
+		Title:           r.Title,
+		Tags:            r.Tags,
+		DurationMinutes: r.DurationMinutes,
+		DueAt:           r.DueAt,
+		StartAt:         r.StartAt,
+		Effort:          r.Effort,
+		ReminderOffsets: r.ReminderOffsets,
+		Recurrence:      r.Recurrence,
+		ListID:          r.ListID,
+	}
 }
 
 func (s *Server) handleCreateTodo(w http.ResponseWriter, r *http.Request) {
@@ -125,43 +701,47 @@ func (s *Server) handleCreateTodo(w http.ResponseWriter, r *http.Request) {
 	defer body.Close()
 	var req createTodoRequest
 	if err := json.NewDecoder(body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		writeError(w, http.StatusBadRequest, bodyError(err, "invalid JSON body"))
 		return
 	}
-	// Trim spaces
-	req.Title = strings.TrimSpace(req.Title)
 	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
+	s.createTodo(ctx, w, r, req.input())
+}
 
-	tags := normalizeTags(req.Tags)
-	duration := clampDuration(req.DurationMinutes)
-	priority := s.computePriority(ctx, priorityCandidate{
-		Title:           req.Title,
-		Completed:       false,
-		Tags:            tags,
-		DurationMinutes: duration,
-		CreatedAt:       time.Now().UTC(),
-	}, 0)
-
-	item, err := s.store.CreateTodo(ctx, db.SaveTodoInput{
-		Title:           req.Title,
-		Completed:       false,
-		Tags:            tags,
-		DurationMinutes: duration,
-		PriorityScore:   priority,
-	})
-	if err != nil {
+// createTodo creates a todo from input and writes it, or the error.
+func (s *Server) createTodo(ctx context.Context, w http.ResponseWriter, r *http.Request, input db.SaveTodoInput) {
+	item, err := s.CreateTodo(ctx, input)
+	var limit *LimitError
+	var rejection *hooks.Rejection
+	switch {
+	case errors.As(err, &limit):
+		writeLimitError(w, limit.Reason, limit.Limit)
+		return
+	case errors.As(err, &rejection):
+		writeError(w, http.StatusUnprocessableEntity, rejection.Reason)
+		return
+	case errors.Is(err, ErrHookFailed):
+		writeError(w, http.StatusBadGateway, "todo hook failed")
+		return
+	case err != nil:
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	writeJSON(w, http.StatusCreated, item)
+	s.writeTodo(w, r, http.StatusCreated, item)
 }
 
 type updateTodoRequest struct {
-	Title           string   `json:"title"`
-	Completed       bool     `json:"completed"`
-	Tags            []string `json:"tags"`
-	DurationMinutes int      `json:"durationMinutes"`
+	Title           string     `json:"title"`
+	Completed       bool       `json:"completed"`
+	Tags            []string   `json:"tags"`
+	DurationMinutes int        `json:"durationMinutes"`
+	DueAt           *time.Time `json:"dueAt"`
+	StartAt         *time.Time `json:"startAt"`
+	Effort          *int       `json:"effort"`
+	ReminderOffsets []int      `json:"reminderOffsets"`
+	Recurrence      string     `json:"recurrence"`
+	ListID          *int64     `json:"listId"`
 }
 
 func (s *Server) handleUpdateTodo(w http.ResponseWriter, r *http.Request) {
@@ -175,7 +755,7 @@ func (s *Server) handleUpdateTodo(w http.ResponseWriter, r *http.Request) {
 	defer body.Close()
 	var req updateTodoRequest
 	if err := json.NewDecoder(body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		writeError(w, http.StatusBadRequest, bodyError(err, "invalid JSON body"))
 		return
 	}
 	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
@@ -183,13 +763,17 @@ func (s *Server) handleUpdateTodo(w http.ResponseWriter, r *http.Request) {
 
 	existing, err := s.store.GetTodo(ctx, id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, db.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "todo not found")
 			return
 		}
 		writeError(w, http.StatusInternalServerError, "failed to load todo")
 		return
 	}
+	if !ifMatch(r.Header.Get("If-Match"), existing) {
+		writeError(w, http.StatusPreconditionFailed, "todo was modified; reload and retry")
+		return
+	}
 
 	title := strings.TrimSpace(req.Title)
 	tags := normalizeTags(req.Tags)
@@ -203,18 +787,25 @@ func (s *Server) handleUpdateTodo(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:       existing.CreatedAt,
 	}, existing.PriorityScore)
 
-	item, err := s.store.UpdateTodo(ctx, id, db.SaveTodoInput{
+	item, ok := s.saveTodo(ctx, w, r, existing, db.SaveTodoInput{
 		Title:           title,
 		Completed:       req.Completed,
 		Tags:            tags,
 		DurationMinutes: duration,
 		PriorityScore:   priority,
+		DueAt:           utcTime(req.DueAt),
+		StartAt:         utcTime(req.StartAt),
+		Effort:          s.effortInput(req.Effort, existing.Effort),
+		ReminderOffsets: req.ReminderOffsets,
+		Recurrence:      strings.TrimSpace(req.Recurrence),
+		ListID:          req.ListID,
 	})
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	if !ok {
 		return
 	}
-	writeJSON(w, http.StatusOK, item)
+	item = s.todoUpdated(ctx, existing, item)
+	s.publishTodo(ctx, eventTodoUpdated, item)
+	s.writeTodo(w, r, http.StatusOK, item)
 }
 
 func (s *Server) handleDeleteTodo(w http.ResponseWriter, r *http.Request) {
@@ -226,7 +817,7 @@ func (s *Server) handleDeleteTodo(w http.ResponseWriter, r *http.Request) {
 	}
 	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	if err := s.store.DeleteTodo(ctx, id); err != nil {
+	if err := s.DeleteTodo(ctx, id); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to delete")
 		return
 	}
@@ -235,7 +826,11 @@ func (s *Server) handleDeleteTodo(w http.ResponseWriter, r *http.Request) {
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSONAs(w, status, "application/json", v)
+}
+
+func writeJSONAs(w http.ResponseWriter, status int, mediaType string, v any) {
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
 	w.WriteHeader(status)
 	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(true)
@@ -259,7 +854,7 @@ type priorityCandidate struct {
 }
 
 func (s *Server) computePriority(ctx context.Context, candidate priorityCandidate, fallback float64) float64 {
-	if s.scorer == nil {
+	if s.scorer == nil || s.skipScore(ctx) {
 		return fallback
 	}
 	payload := mlclient.TodoPayload{
@@ -272,14 +867,25 @@ func (s *Server) computePriority(ctx context.Context, candidate priorityCandidat
 		c := candidate.CreatedAt
 		payload.CreatedAt = &c
 	}
-	score, err := s.scorer.Score(ctx, payload)
+	payload.User = s.userFeatures(ctx, candidate.Tags)
+	// The request waits on the score, so the client may hedge it.
+	score, err := s.scorer.Score(mlclient.LatencySensitive(ctx), payload)
+	s.alerts.Record("ml", err != nil)
+	if errors.Is(err, mlclient.ErrCircuitOpen) {
+		return fallback
+	}
 	if err != nil {
-		slog.Warn("ml.score_failed", "error", err)
+		slog.WarnContext(ctx, "ml.score_failed", "error", err)
 		return fallback
 	}
-	return score
+	return s.preferences(ctx).Apply(score, candidate.Tags)
 }
 
+// maxTagBytes caps the length of a tag.
+const maxTagBytes = 32
+
+// normalizeTags lowercases and trims tags, cuts them to maxTagBytes and
+// drops empty and repeated ones, keeping the order of the rest.
 func normalizeTags(tags []string) []string {
 	if len(tags) == 0 {
 		return []string{}
@@ -291,8 +897,8 @@ func normalizeTags(tags []string) []string {
 		if tag == "" {
 			continue
 		}
-		if len(tag) > 32 {
-			tag = tag[:32]
+		if len(tag) > maxTagBytes {
+			tag = strings.TrimSpace(truncateUTF8(tag, maxTagBytes))
 		}
 		if _, ok := seen[tag]; ok {
 			continue
@@ -303,6 +909,26 @@ func normalizeTags(tags []string) []string {
 	return out
 }
 
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// utcTime normalizes an optional client timestamp to UTC.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
 func clampDuration(val int) int {
 	if val < 0 {
 		return 0
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"unicode/utf8"
+
+	"todoapp/internal/db"
+)
+
+func FuzzNormalizeTags(f *testing.F) {
+	for _, seed := range []string{
+		"work,Home , work",
+		" ,\t,",
+		"ÄRGER,ärger",
+		strings.Repeat("ü", 20),
+		"abcdefghijklmnopqrstuvwxyz01234 x",
+		"\xff\xfe,ok",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, joined string) {
+		tags := normalizeTags(strings.Split(joined, ","))
+		seen := make(map[string]bool, len(tags))
+		for _, tag := range tags {
+			if tag == "" || tag != strings.TrimSpace(tag) || len(tag) > 32 {
+				t.Fatalf("tag %q not normalized", tag)
+			}
+			if utf8.ValidString(joined) && !utf8.ValidString(tag) {
+				t.Fatalf("tag %q of valid input is not UTF-8", tag)
+			}
+			if seen[tag] {
+				t.Fatalf("tag %q repeated", tag)
+			}
+			seen[tag] = true
+		}
+		if again := normalizeTags(tags); !slices.Equal(again, tags) {
+			t.Fatalf("normalizing again changed %q to %q", tags, again)
+		}
+	})
+}
+
+func FuzzDecodeTodo(f *testing.F) {
+	// Todos in the shape an export writes them.
+	for _, seed := range []string{
+		`{"title":"Renew passport","tags":["admin","travel"],"durationMinutes":30,"dueAt":"2024-06-01T09:00:00Z"}`,
+		`{"title":"Water plants","recurrence":"FREQ=WEEKLY;BYDAY=SA","reminderOffsets":[60,1440]}`,
+		`{"title":"Ship v2","effort":5,"startAt":"2024-05-20T08:00:00+02:00","listId":null}`,
+		`{"title":"  ","tags":[""]}`,
+		`{"title":"café 😀","tags":["ÉTÉ"]}`,
+		`{"title":7}`,
+		`[]`,
+	} {
+		f.Add([]byte(seed))
+	}
+	store, err := db.NewBoltStore(filepath.Join(f.TempDir(), "todo.bolt"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { _ = store.Close() })
+	h := NewServer(store, fstest.MapFS{}, nil).Handler()
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		r := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(string(body)))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code >= 500 {
+			t.Fatalf("status %d for %q: %s", w.Code, body, w.Body)
+		}
+		if !json.Valid(w.Body.Bytes()) {
+			t.Fatalf("response to %q is not JSON: %q", body, w.Body)
+		}
+		if w.Code != http.StatusCreated {
+			return
+		}
+		var todo db.Todo
+		if err := json.Unmarshal(w.Body.Bytes(), &todo); err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(todo.Title) == "" || !utf8.ValidString(todo.Title) {
+			t.Fatalf("created todo with title %q", todo.Title)
+		}
+		for _, tag := range todo.Tags {
+			if !utf8.ValidString(tag) || len(tag) > 32 {
+				t.Fatalf("created todo with tag %q", tag)
+			}
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/hooks"
+)
+
+// The exported todo operations below are what the REST handlers do, for
+// other front ends such as the gRPC API: they apply the same limits, hooks,
+// scoring, rules, webhooks and live events. When accounts are enabled ctx
+// must be scoped to the caller with db.WithOwner. Todos are returned as the
+// API presents them.
+
+var (
+	// ErrHookFailed is returned by CreateTodo when a before-create hook
+	// fails, as opposed to rejecting the todo with a *hooks.Rejection.
+	ErrHookFailed = errors.New("todo hook failed")
+	// ErrFilterUnsupported is returned by ListTodos for a filter the
+	// storage backend cannot apply.
+	ErrFilterUnsupported = errors.New("filtering not supported by storage backend")
+	// ErrWatchLagged ends WatchTodos when the watcher fell too far behind;
+	// it should reload and watch again.
+	ErrWatchLagged = errors.New("watcher fell too far behind")
+)
+
+// TodoEvent is a todo change delivered by WatchTodos. Type is one of the
+// /api/todos/events event names; Todo, ID and Message are set as there.
+type TodoEvent struct {
+	Type    string
+	Todo    *db.Todo
+	ID      int64
+	Message string
+}
+
+// ListTodos returns the todos matching filter.
+func (s *Server) ListTodos(ctx context.Context, filter db.TodoFilter) ([]db.Todo, error) {
+	var items []db.Todo
+	var err error
+	if filter == (db.TodoFilter{}) {
+		items, err = s.store.ListTodos(ctx)
+	} else if lister, ok := s.store.(db.FilteredLister); ok {
+		items, err = lister.ListTodosFiltered(ctx, filter)
+	} else {
+		return nil, ErrFilterUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i] = s.present(items[i])
+	}
+	return items, nil
+}
+
+// CreateTodo creates a todo from input, which it normalizes and scores.
+// It fails with a *LimitError when a todo limit is reached, a
+// *hooks.Rejection or ErrHookFailed from the before-create hooks, and the
+// store's validation errors otherwise.
+func (s *Server) CreateTodo(ctx context.Context, input db.SaveTodoInput) (db.Todo, error) {
+	ctx = s.withScoreDeferral(ctx)
+	input.Title = strings.TrimSpace(input.Title)
+	input.Completed = false
+	input.Tags = normalizeTags(input.Tags)
+	input.DurationMinutes = clampDuration(input.DurationMinutes)
+	input.DueAt = utcTime(input.DueAt)
+	input.StartAt = utcTime(input.StartAt)
+	input.Effort = s.effortInput(input.Effort, nil)
+	input.Recurrence = strings.TrimSpace(input.Recurrence)
+
+	if err := s.todoLimitError(ctx, 1, 1); err != nil {
+		return db.Todo{}, err
+	}
+	if err := s.runBeforeCreate(ctx, &input); err != nil {
+		var rejection *hooks.Rejection
+		if errors.As(err, &rejection) {
+			return db.Todo{}, err
+		}
+		return db.Todo{}, fmt.Errorf("%w: %v", ErrHookFailed, err)
+	}
+	input.PriorityScore = s.computePriority(ctx, priorityCandidate{
+		Title:           input.Title,
+		Completed:       input.Completed,
+		Tags:            input.Tags,
+		DurationMinutes: input.DurationMinutes,
+		CreatedAt:       time.Now().UTC(),
+	}, 0)
+
+	item, err := s.store.CreateTodo(ctx, input)
+	if err != nil {
+		return db.Todo{}, err
+	}
+	s.publishTodo(ctx, eventTodoCreated, item)
+	s.todoCreated(ctx, item)
+	return s.present(item), nil
+}
+
+// UpdateTodo replaces the fields of todo id with input and rescores it.
+// It fails with db.ErrNotFound if there is no such todo.
+func (s *Server) UpdateTodo(ctx context.Context, id int64, input db.SaveTodoInput) (db.Todo, error) {
+	ctx = s.withScoreDeferral(ctx)
+	existing, err := s.store.GetTodo(ctx, id)
+	if err != nil {
+		return db.Todo{}, err
+	}
+	input.Title = strings.TrimSpace(input.Title)
+	input.Tags = normalizeTags(input.Tags)
+	input.DurationMinutes = clampDuration(input.DurationMinutes)
+	input.DueAt = utcTime(input.DueAt)
+	input.StartAt = utcTime(input.StartAt)
+	input.Effort = s.effortInput(input.Effort, existing.Effort)
+	input.Recurrence = strings.TrimSpace(input.Recurrence)
+	input.PriorityScore = s.computePriority(ctx, priorityCandidate{
+		Title:           input.Title,
+		Completed:       input.Completed,
+		Tags:            input.Tags,
+		DurationMinutes: input.DurationMinutes,
+		CreatedAt:       existing.CreatedAt,
+	}, existing.PriorityScore)
+
+	item, err := s.store.UpdateTodo(ctx, id, input)
+	if err != nil {
+		return db.Todo{}, err
+	}
+	item = s.todoUpdated(ctx, existing, item)
+	s.publishTodo(ctx, eventTodoUpdated, item)
+	return s.present(item), nil
+}
+
+// DeleteTodo deletes todo id. Deleting a todo that does not exist succeeds.
+func (s *Server) DeleteTodo(ctx context.Context, id int64) error {
+	existing, getErr := s.store.GetTodo(ctx, id)
+	if err := s.store.DeleteTodo(ctx, id); err != nil {
+		return err
+	}
+	if getErr == nil {
+		s.todoDeleted(ctx, existing)
+	}
+	s.publish(ctx, todoEvent{Type: eventTodoDeleted, ID: id})
+	return nil
+}
+
+// WatchTodos calls send with every todo change the user ctx is scoped to
+// may see, as /api/todos/events streams them, until ctx is done or send
+// fails. It returns ErrDraining when the server starts shutting down and
+// ErrWatchLagged when the watcher could not keep up.
+func (s *Server) WatchTodos(ctx context.Context, send func(TodoEvent) error) error {
+	done, leave, ok := s.streams.join()
+	if !ok {
+		return ErrDraining
+	}
+	sub := s.events.subscribe(ctx)
+	defer s.events.unsubscribe(sub)
+	for {
+		select {
+		case <-ctx.Done():
+			leave(false)
+			return ctx.Err()
+		case <-done:
+			leave(true)
+			return ErrDraining
+		case ev, open := <-sub.ch:
+			if !open {
+				leave(false)
+				return ErrWatchLagged
+			}
+			if err := send(TodoEvent{Type: ev.Type, Todo: ev.Todo, ID: ev.ID, Message: ev.Message}); err != nil {
+				leave(false)
+				return err
+			}
+		}
+	}
+}
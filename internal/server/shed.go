@@ -0,0 +1,186 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadShedding bounds how many API requests run at once. The limit adapts
+// between MinLimit and MaxLimit: it grows while latency stays near its
+// long-term baseline and shrinks as soon as requests start queueing behind
+// Postgres or the ML service, so excess traffic fails fast with 503 instead
+// of piling up.
+type LoadShedding struct {
+	MinLimit     int
+	MaxLimit     int
+	InitialLimit int
+}
+
+// WithLoadShedding enables the adaptive concurrency limiter for /api routes.
+func WithLoadShedding(cfg LoadShedding) Option {
+	return func(s *Server) {
+		s.shedder = newGradientLimiter(cfg)
+	}
+}
+
+// gradientLimiter is a gradient-style adaptive limiter: every completed
+// request compares its latency with a slowly moving baseline, and the ratio
+// (clamped to [0.5, 1]) scales the limit down while a sqrt(limit) headroom
+// lets it probe upwards when latency is healthy.
+type gradientLimiter struct {
+	minLimit, maxLimit float64
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	// baseline is an exponentially weighted average of request latency in
+	// nanoseconds, tracking what "healthy" looks like for this deployment.
+	baseline float64
+}
+
+const (
+	// baselineWeight is how much one sample moves the baseline.
+	baselineWeight = 0.01
+	// limitSmoothing damps each adjustment to avoid oscillation.
+	limitSmoothing = 0.2
+)
+
+func newGradientLimiter(cfg LoadShedding) *gradientLimiter {
+	minLimit := max(cfg.MinLimit, 1)
+	maxLimit := max(cfg.MaxLimit, minLimit)
+	initial := cfg.InitialLimit
+	if initial <= 0 {
+		initial = minLimit
+	}
+	initial = min(max(initial, minLimit), maxLimit)
+	return &gradientLimiter{minLimit: float64(minLimit), maxLimit: float64(maxLimit), limit: float64(initial)}
+}
+
+// requestClass ranks API requests for admission while shedding. Each class
+// may only fill its share of the concurrency limit, so as load rises heavy
+// work is turned away first and the remaining headroom is kept for the
+// requests users notice most.
+type requestClass int
+
+const (
+	// classCritical covers reads and edits of single todos, including
+	// marking one complete.
+	classCritical requestClass = iota
+	// classNormal covers other writes: creates, deletes, lists and views.
+	classNormal
+	// classHeavy covers set-based work: bulk deletes and aggregate stats.
+	classHeavy
+)
+
+// share is the fraction of the limit a class may occupy.
+func (c requestClass) share() float64 {
+	switch c {
+	case classCritical:
+		return 1
+	case classNormal:
+		return 0.8
+	default:
+		return 0.5
+	}
+}
+
+func (c requestClass) String() string {
+	return [...]string{"critical", "normal", "heavy"}[c]
+}
+
+// classify assigns r its admission class.
+func classify(r *http.Request) requestClass {
+	path := r.URL.Path
+	switch {
+	case path == "/api/stats" || strings.HasPrefix(path, "/api/stats/"):
+		return classHeavy
+	case r.Method == http.MethodDelete && strings.TrimSuffix(path, "/") == "/api/todos":
+		return classHeavy
+	case path == "/api/todos/bulk" || path == "/api/todos/export" || path == "/api/todos/import" ||
+		path == "/api/todos/search":
+		return classHeavy
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return classCritical
+	case (r.Method == http.MethodPut || r.Method == http.MethodPatch) && strings.HasPrefix(path, "/api/todos/"):
+		return classCritical
+	}
+	return classNormal
+}
+
+// acquire reserves a slot for a request of class c, reporting false when
+// the class's share of the limit is in use.
+func (l *gradientLimiter) acquire(c requestClass) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if float64(l.inflight) >= math.Max(1, math.Floor(l.limit*c.share())) {
+		return false
+	}
+	l.inflight++
+	return true
+}
+
+// snapshot returns the current limit and how many slots are in use.
+func (l *gradientLimiter) snapshot() (limit float64, inflight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.inflight
+}
+
+// release frees a slot and feeds the request's latency into the limit.
+func (l *gradientLimiter) release(rtt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inflight := l.inflight
+	l.inflight--
+
+	sample := float64(rtt)
+	if l.baseline == 0 {
+		l.baseline = sample
+		return
+	}
+	l.baseline += (sample - l.baseline) * baselineWeight
+
+	// Do not grow while mostly idle: low utilisation says nothing about how
+	// the backends would cope with more concurrency.
+	if float64(inflight) < l.limit/2 && sample <= l.baseline {
+		return
+	}
+	gradient := math.Max(0.5, math.Min(1, l.baseline/sample))
+	target := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.limit*(1-limitSmoothing) + target*limitSmoothing
+	l.limit = math.Max(l.minLimit, math.Min(l.maxLimit, l.limit))
+}
+
+// shedLoad rejects API requests beyond the adaptive concurrency limit,
+// weighted by request class. Non-API paths (static assets, probes),
+// long-lived streams and the admin API bypass it: streams would hold slots
+// for minutes and skew the latency baseline, and the rest must stay
+// reachable precisely when the server is struggling.
+func (s *Server) shedLoad(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/api/admin/") || isLongLived(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		class := classify(r)
+		if !s.shedder.acquire(class) {
+			slog.DebugContext(r.Context(), "request.shed", "class", class.String())
+			writeThrottled(w, throttle{Reason: reasonOverloaded, RetryAfter: time.Second}, "server is at its concurrency limit")
+			return
+		}
+		start := time.Now()
+		defer func() { s.shedder.release(time.Since(start)) }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLongLived reports whether path serves a streaming response that stays
+// open for as long as the client wants.
+func isLongLived(path string) bool {
+	return strings.HasSuffix(path, "/stream") || strings.HasSuffix(path, "/events")
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"todoapp/internal/db"
+)
+
+// snapshotStore returns the store's db.ListSnapshotStore, writing 501 when
+// it has none.
+func (s *Server) snapshotStore(w http.ResponseWriter) (db.ListSnapshotStore, bool) {
+	snaps, ok := s.store.(db.ListSnapshotStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "list snapshots not supported by storage backend")
+	}
+	return snaps, ok
+}
+
+// writeSnapshotError writes err from a snapshot store call.
+func writeSnapshotError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrListNotFound):
+		writeError(w, http.StatusNotFound, "list not found")
+	case errors.Is(err, db.ErrSnapshotNotFound):
+		writeError(w, http.StatusNotFound, "snapshot not found")
+	default:
+		writeError(w, http.StatusInternalServerError, "list snapshot failed")
+	}
+}
+
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	snaps, ok := s.snapshotStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := snaps.ListSnapshots(ctx, id)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleSnapshotList records the todos of a list as they are now.
+func (s *Server) handleSnapshotList(w http.ResponseWriter, r *http.Request) {
+	snaps, ok := s.snapshotStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	snap, err := snaps.SnapshotList(ctx, id)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, snap)
+}
+
+// restoreInput is the input putting t back into list id as snapshotted.
+func restoreInput(t db.Todo, id int64) db.SaveTodoInput {
+	input := todoInput(t)
+	input.ListID = &id
+	return input
+}
+
+// sameInput reports whether a and b save the same todo.
+func sameInput(a, b db.SaveTodoInput) bool {
+	x, errX := json.Marshal(a)
+	y, errY := json.Marshal(b)
+	return errX == nil && errY == nil && bytes.Equal(x, y)
+}
+
+// handleRestoreList puts the todos of a list back as a snapshot recorded
+// them, in one batch: todos added since are deleted, changed ones and ones
+// moved to other lists are reverted, and deleted ones are recreated with
+// their uuids under new ids. The list is snapshotted first, so a restore can itself be
+// undone. Restored todos keep their snapshotted scores, and no rules, hooks
+// or recurrences run; webhooks are still told of every change.
+func (s *Server) handleRestoreList(w http.ResponseWriter, r *http.Request) {
+	snaps, ok := s.snapshotStore(w)
+	if !ok {
+		return
+	}
+	saver, ok := s.store.(db.BulkSaver)
+	lister, ok2 := s.store.(db.FilteredLister)
+	if !ok || !ok2 {
+		writeError(w, http.StatusNotImplemented, "list restore not supported by storage backend")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	snapshotID, err := strconv.ParseInt(chi.URLParam(r, "snapshotId"), 10, 64)
+	if err != nil || snapshotID <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid snapshot id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	target, err := snaps.SnapshotTodos(ctx, id, snapshotID)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	current, err := lister.ListTodosFiltered(ctx, db.TodoFilter{ListID: id})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list todos")
+		return
+	}
+
+	var ops []db.BulkOp
+	var before []db.Todo
+	var creates, open int64
+	kept := make(map[string]bool, len(target))
+	inList := make(map[string]db.Todo, len(current))
+	for _, t := range current {
+		inList[t.UUID] = t
+	}
+	for _, t := range target {
+		kept[t.UUID] = true
+		input := restoreInput(t, id)
+		existing, ok := inList[t.UUID]
+		if !ok {
+			// Moved to another list, or deleted.
+			if moved, err := s.store.GetTodo(ctx, t.ID); err == nil && moved.UUID == t.UUID {
+				existing, ok = moved, true
+			}
+		}
+		switch {
+		case !ok:
+			ops = append(ops, db.BulkOp{Action: db.BulkCreate, Input: input, UUID: t.UUID})
+			before = append(before, db.Todo{})
+			creates++
+			if !input.Completed {
+				open++
+			}
+		case existing.ListID == nil || *existing.ListID != id || !sameInput(input, restoreInput(existing, id)):
+			ops = append(ops, db.BulkOp{Action: db.BulkUpdate, ID: existing.ID, Input: input})
+			before = append(before, existing)
+		}
+	}
+	for _, t := range current {
+		if !kept[t.UUID] {
+			ops = append(ops, db.BulkOp{Action: db.BulkDelete, ID: t.ID})
+			before = append(before, t)
+		}
+	}
+	if creates > 0 && !s.checkTodoLimits(ctx, w, creates, open) {
+		return
+	}
+
+	res := struct {
+		Snapshot int64 `json:"snapshot"`
+		// Backup is the snapshot taken just before restoring; none is
+		// taken when the list already matches.
+		Backup  int64 `json:"backup,omitempty"`
+		Created int   `json:"created"`
+		Updated int   `json:"updated"`
+		Deleted int   `json:"deleted"`
+	}{Snapshot: snapshotID}
+	if len(ops) == 0 {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	backup, err := snaps.SnapshotList(ctx, id)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	res.Backup = backup.ID
+	saved, err := saver.BulkSave(ctx, ops)
+	if err != nil {
+		slog.ErrorContext(ctx, "list.restore_failed", "id", id, "snapshot_id", snapshotID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to restore list")
+		return
+	}
+	for i, op := range ops {
+		switch op.Action {
+		case db.BulkCreate:
+			res.Created++
+			s.queueWebhooks(ctx, db.WebhookEventCreated, saved[i])
+		case db.BulkUpdate:
+			res.Updated++
+			s.queueWebhooks(ctx, db.WebhookEventUpdated, saved[i])
+		case db.BulkDelete:
+			res.Deleted++
+			s.queueWebhooks(ctx, db.WebhookEventDeleted, before[i])
+		}
+	}
+	slog.InfoContext(ctx, "list.restored", "id", id, "snapshot_id", snapshotID, "created", res.Created, "updated", res.Updated, "deleted", res.Deleted)
+	s.publish(ctx, todoEvent{Type: eventTodosChanged})
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,621 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todoapp/internal/telemetry"
+)
+
+// SpecValidation is what to do with requests and responses that do not
+// match the OpenAPI document.
+type SpecValidation string
+
+const (
+	// SpecValidationLog logs mismatches and lets them through.
+	SpecValidationLog SpecValidation = "log"
+	// SpecValidationEnforce also rejects them: a request with 400, a
+	// response by replacing it with a 500.
+	SpecValidationEnforce SpecValidation = "enforce"
+)
+
+// maxSpecBody is the largest request body checked; bigger ones (imports)
+// are passed on unchecked.
+const maxSpecBody = 1 << 20
+
+// WithSpecValidation checks the JSON bodies of documented operations against
+// openapi.json, to catch handlers drifting from the spec in development and
+// staging before clients do. Responses are buffered to be checked, so leave
+// it off in production. Streams and other non-JSON responses are not
+// checked; nor are routes the spec leaves out, such as the admin API.
+func WithSpecValidation(mode SpecValidation) Option {
+	return func(s *Server) {
+		if mode != SpecValidationLog && mode != SpecValidationEnforce {
+			return
+		}
+		v, err := newSpecValidator(openAPISpec)
+		if err != nil {
+			// The spec is embedded; a broken one is a build mistake.
+			panic("openapi.json: " + err.Error())
+		}
+		v.enforce = mode == SpecValidationEnforce
+		s.spec = v
+	}
+}
+
+// specOperation is one documented method of a path.
+type specOperation struct {
+	segments []string // the path split on "/", "{...}" matching any segment
+	literals int      // how many segments are not parameters
+	method   string
+	// body maps request media types to their schema; bodyRequired is the
+	// request body's required flag.
+	body         map[string]any
+	bodyRequired bool
+	// responses maps status codes ("200", "default") to their JSON schema,
+	// nil for responses without a JSON body.
+	responses map[string]any
+	// deprecated describes what of the operation is deprecated; nil when
+	// nothing is.
+	deprecated *specDeprecation
+}
+
+// specDeprecation is what the document marks deprecated in an operation:
+// the operation itself, or some of its query parameters or top-level JSON
+// body properties. x-deprecated-at and x-sunset, dates on the operation,
+// say since when and until when it is served.
+type specDeprecation struct {
+	path      string // the documented path, such as /api/todos/{id}
+	operation bool
+	params    []string
+	fields    []string
+	at        time.Time
+	sunset    time.Time
+}
+
+// specParameter is a parameter object, or a reference to one.
+type specParameter struct {
+	Ref        string `json:"$ref"`
+	Name       string `json:"name"`
+	In         string `json:"in"`
+	Deprecated bool   `json:"deprecated"`
+}
+
+// specValidator holds the parsed document.
+type specValidator struct {
+	enforce    bool
+	schemas    map[string]any
+	operations []specOperation // most literal segments first
+
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+func newSpecValidator(doc []byte) (*specValidator, error) {
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas    map[string]any           `json:"schemas"`
+			Parameters map[string]specParameter `json:"parameters"`
+			Responses  map[string]struct {
+				Content map[string]struct {
+					Schema any `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, err
+	}
+	v := &specValidator{schemas: spec.Components.Schemas, patterns: make(map[string]*regexp.Regexp)}
+	for path, item := range spec.Paths {
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		literals := 0
+		for _, seg := range segments {
+			if !strings.HasPrefix(seg, "{") {
+				literals++
+			}
+		}
+		var pathParams []specParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &pathParams); err != nil {
+				return nil, fmt.Errorf("%s parameters: %w", path, err)
+			}
+		}
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var op struct {
+				Deprecated   bool            `json:"deprecated"`
+				DeprecatedAt string          `json:"x-deprecated-at"`
+				Sunset       string          `json:"x-sunset"`
+				Parameters   []specParameter `json:"parameters"`
+				RequestBody  *struct {
+					Required bool `json:"required"`
+					Content  map[string]struct {
+						Schema any `json:"schema"`
+					} `json:"content"`
+				} `json:"requestBody"`
+				Responses map[string]struct {
+					Ref     string `json:"$ref"`
+					Content map[string]struct {
+						Schema any `json:"schema"`
+					} `json:"content"`
+				} `json:"responses"`
+			}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			o := specOperation{
+				segments:  segments,
+				literals:  literals,
+				method:    strings.ToUpper(method),
+				responses: make(map[string]any),
+			}
+			if op.RequestBody != nil {
+				o.bodyRequired = op.RequestBody.Required
+				o.body = make(map[string]any)
+				for mediaType, c := range op.RequestBody.Content {
+					o.body[mediaType] = c.Schema
+				}
+			}
+			for code, resp := range op.Responses {
+				content := resp.Content
+				if name, ok := strings.CutPrefix(resp.Ref, "#/components/responses/"); ok {
+					ref, ok := spec.Components.Responses[name]
+					if !ok {
+						return nil, fmt.Errorf("%s %s: unknown response %s", method, path, resp.Ref)
+					}
+					content = ref.Content
+				}
+				if c, ok := content["application/json"]; ok {
+					o.responses[code] = c.Schema
+				} else {
+					o.responses[code] = nil
+				}
+			}
+			d := &specDeprecation{path: path, operation: op.Deprecated}
+			for _, p := range append(slices.Clip(pathParams), op.Parameters...) {
+				if name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/"); ok {
+					p = spec.Components.Parameters[name]
+				}
+				if p.Deprecated && p.In == "query" {
+					d.params = append(d.params, p.Name)
+				}
+			}
+			d.fields = v.deprecatedProperties(o.body["application/json"])
+			for _, date := range []struct {
+				value string
+				dst   *time.Time
+			}{{op.DeprecatedAt, &d.at}, {op.Sunset, &d.sunset}} {
+				if date.value == "" {
+					continue
+				}
+				t, err := time.Parse(time.DateOnly, date.value)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: x-deprecated-at and x-sunset must be dates: %w", method, path, err)
+				}
+				*date.dst = t
+			}
+			if d.operation || len(d.params) > 0 || len(d.fields) > 0 {
+				o.deprecated = d
+			}
+			v.operations = append(v.operations, o)
+		}
+	}
+	sort.SliceStable(v.operations, func(i, j int) bool {
+		return v.operations[i].literals > v.operations[j].literals
+	})
+	return v, nil
+}
+
+// deprecatedProperties returns the top-level properties schema marks
+// deprecated.
+func (v *specValidator) deprecatedProperties(schema any) []string {
+	sch, _ := schema.(map[string]any)
+	if ref, ok := sch["$ref"].(string); ok {
+		name, _ := strings.CutPrefix(ref, "#/components/schemas/")
+		sch, _ = v.schemas[name].(map[string]any)
+	}
+	var out []string
+	props, _ := sch["properties"].(map[string]any)
+	for name, prop := range props {
+		if p, _ := prop.(map[string]any); p["deprecated"] == true {
+			out = append(out, name)
+		}
+	}
+	all, _ := sch["allOf"].([]any)
+	for _, sub := range all {
+		out = append(out, v.deprecatedProperties(sub)...)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// operation returns the documented operation r is for, preferring literal
+// segments to parameters (/api/todos/search over /api/todos/{id}).
+func (v *specValidator) operation(r *http.Request) *specOperation {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for i := range v.operations {
+		op := &v.operations[i]
+		if op.method != r.Method || len(op.segments) != len(segments) {
+			continue
+		}
+		match := true
+		for j, seg := range op.segments {
+			if !strings.HasPrefix(seg, "{") && seg != segments[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return op
+		}
+	}
+	return nil
+}
+
+// validateAgainstSpec is the middleware checking bodies against the spec.
+func (s *Server) validateAgainstSpec(next http.Handler) http.Handler {
+	v := s.spec
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := v.operation(r)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if problem := v.checkRequest(op, r); problem != "" {
+			telemetry.SpecViolations.WithLabelValues("request").Inc()
+			slog.WarnContext(r.Context(), "openapi.request_invalid", "method", r.Method, "path", r.URL.Path, "problem", problem)
+			if v.enforce {
+				writeError(w, http.StatusBadRequest, "request does not match the API spec: "+problem)
+				return
+			}
+		}
+
+		rec := &specRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if !rec.buffered() {
+			return
+		}
+		if problem := v.checkResponse(op, rec.status, rec.body.Bytes()); problem != "" {
+			telemetry.SpecViolations.WithLabelValues("response").Inc()
+			slog.ErrorContext(r.Context(), "openapi.response_invalid", "method", r.Method, "path", r.URL.Path, "status", rec.status, "problem", problem)
+			if v.enforce {
+				for _, h := range []string{"Content-Length", "ETag", "Last-Modified", "Location"} {
+					w.Header().Del(h)
+				}
+				writeError(w, http.StatusInternalServerError, "response does not match the API spec: "+problem)
+				return
+			}
+		}
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(rec.body.Bytes())
+	})
+}
+
+// checkRequest describes how r's body violates op, or returns "". The body
+// is read and put back.
+func (v *specValidator) checkRequest(op *specOperation, r *http.Request) string {
+	if op.body == nil || r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSpecBody+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxSpecBody {
+		return ""
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.bodyRequired {
+			return "missing request body"
+		}
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	schema, ok := op.body[mediaType]
+	if !ok {
+		// Handlers accept JSON without a Content-Type.
+		if schema, ok = op.body["application/json"]; !ok {
+			return ""
+		}
+	}
+	doc, err := decodeSpecJSON(body)
+	if err != nil {
+		return "" // the handler reports malformed JSON itself
+	}
+	return v.check(schema, doc, "$", true)
+}
+
+// checkResponse describes how a JSON response violates op, or returns "".
+// Error statuses op leaves out must still be the API's error object.
+func (v *specValidator) checkResponse(op *specOperation, status int, body []byte) string {
+	code := strconv.Itoa(status)
+	schema, ok := op.responses[code]
+	if !ok {
+		schema, ok = op.responses[code[:1]+"XX"]
+	}
+	if !ok {
+		schema, ok = op.responses["default"]
+	}
+	if !ok {
+		if status < 400 {
+			return "undocumented status " + code
+		}
+		schema = map[string]any{"$ref": "#/components/schemas/Error"}
+	}
+	if schema == nil {
+		if len(bytes.TrimSpace(body)) > 0 {
+			return "status " + code + " is documented without a JSON body"
+		}
+		return ""
+	}
+	doc, err := decodeSpecJSON(body)
+	if err != nil {
+		return "malformed JSON: " + err.Error()
+	}
+	return v.check(schema, doc, "$", false)
+}
+
+func decodeSpecJSON(body []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// check validates value at path against the subset of JSON Schema the
+// document uses. In requests, readOnly properties need not be present; in
+// responses, writeOnly ones.
+func (v *specValidator) check(schema any, value any, path string, request bool) string {
+	sch, ok := schema.(map[string]any)
+	if !ok {
+		return ""
+	}
+	if ref, ok := sch["$ref"].(string); ok {
+		name, _ := strings.CutPrefix(ref, "#/components/schemas/")
+		target, ok := v.schemas[name]
+		if !ok {
+			return path + ": unknown schema " + ref
+		}
+		return v.check(target, value, path, request)
+	}
+	all, _ := sch["allOf"].([]any)
+	if value == nil {
+		if nullable, _ := sch["nullable"].(bool); nullable || (sch["type"] == nil && all == nil) {
+			return ""
+		}
+		return path + ": must not be null"
+	}
+	for _, sub := range all {
+		if problem := v.check(sub, value, path, request); problem != "" {
+			return problem
+		}
+	}
+	if enum, ok := sch["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool {
+		return fmt.Sprint(e) == fmt.Sprint(value)
+	}) {
+		return fmt.Sprintf("%s: %v is not one of %v", path, value, enum)
+	}
+
+	switch typ, _ := sch["type"].(string); typ {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return path + ": expected a string, got " + jsonKind(value)
+		}
+		if max, ok := sch["maxLength"].(float64); ok && float64(len([]rune(str))) > max {
+			return fmt.Sprintf("%s: longer than %v characters", path, max)
+		}
+		if pattern, ok := sch["pattern"].(string); ok {
+			re, err := v.pattern(pattern)
+			if err == nil && !re.MatchString(str) {
+				return path + ": does not match " + pattern
+			}
+		}
+		var err error
+		switch sch["format"] {
+		case "date-time":
+			_, err = time.Parse(time.RFC3339, str)
+		case "date":
+			_, err = time.Parse(time.DateOnly, str)
+		case "uuid":
+			if !uuidPattern.MatchString(str) {
+				err = fmt.Errorf("not a uuid")
+			}
+		case "uri":
+			var u *url.URL
+			if u, err = url.Parse(str); err == nil && u.Scheme == "" {
+				err = fmt.Errorf("not an absolute uri")
+			}
+		}
+		if err != nil && sch["format"] == "date-time" {
+			return fmt.Sprintf("%s: %q is not an RFC 3339 timestamp with a time zone, e.g. %s", path, str, timestampExample)
+		}
+		if err != nil {
+			return fmt.Sprintf("%s: %q is not a valid %s", path, str, sch["format"])
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return path + ": expected a " + typ + ", got " + jsonKind(value)
+		}
+		if typ == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return path + ": expected an integer, got " + n.String()
+			}
+		}
+		f, _ := n.Float64()
+		if min, ok := sch["minimum"].(float64); ok && f < min {
+			return fmt.Sprintf("%s: %s is below the minimum %v", path, n, min)
+		}
+		if max, ok := sch["maximum"].(float64); ok && f > max {
+			return fmt.Sprintf("%s: %s is above the maximum %v", path, n, max)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return path + ": expected a boolean, got " + jsonKind(value)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return path + ": expected an array, got " + jsonKind(value)
+		}
+		if max, ok := sch["maxItems"].(float64); ok && float64(len(items)) > max {
+			return fmt.Sprintf("%s: more than %v items", path, max)
+		}
+		for i, item := range items {
+			if problem := v.check(sch["items"], item, path+"["+strconv.Itoa(i)+"]", request); problem != "" {
+				return problem
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return path + ": expected an object, got " + jsonKind(value)
+		}
+		props, _ := sch["properties"].(map[string]any)
+		required, _ := sch["required"].([]any)
+		for _, name := range required {
+			name, _ := name.(string)
+			if _, ok := obj[name]; ok {
+				continue
+			}
+			prop, _ := props[name].(map[string]any)
+			if readOnly, _ := prop["readOnly"].(bool); readOnly && request {
+				continue
+			}
+			if writeOnly, _ := prop["writeOnly"].(bool); writeOnly && !request {
+				continue
+			}
+			return path + ": missing required property " + name
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			sub, known := props[name]
+			if !known {
+				switch extra := sch["additionalProperties"].(type) {
+				case bool:
+					if !extra {
+						return path + ": unknown property " + name
+					}
+					continue
+				case map[string]any:
+					sub = extra
+				default:
+					continue
+				}
+			}
+			if problem := v.check(sub, obj[name], path+"."+name, request); problem != "" {
+				return problem
+			}
+		}
+	}
+	return ""
+}
+
+// pattern compiles and caches a schema pattern.
+func (v *specValidator) pattern(p string) (*regexp.Regexp, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if re, ok := v.patterns[p]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	v.patterns[p] = re
+	return re, nil
+}
+
+func jsonKind(value any) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	default:
+		return "null"
+	}
+}
+
+// specRecorder holds back JSON responses for checking; anything else (event
+// streams, CSV, empty responses) is written through as it comes.
+type specRecorder struct {
+	http.ResponseWriter
+	status  int
+	wrote   bool
+	through bool
+	body    bytes.Buffer
+}
+
+func (r *specRecorder) WriteHeader(status int) {
+	if r.wrote {
+		return
+	}
+	r.wrote, r.status = true, status
+	mediaType, _, _ := mime.ParseMediaType(r.Header().Get("Content-Type"))
+	if mediaType != "application/json" || status == http.StatusNotModified {
+		r.through = true
+		r.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (r *specRecorder) Write(b []byte) (int, error) {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.through {
+		return r.ResponseWriter.Write(b)
+	}
+	return r.body.Write(b)
+}
+
+// Flush passes through for streamed responses.
+func (r *specRecorder) Flush() {
+	if r.through {
+		_ = http.NewResponseController(r.ResponseWriter).Flush()
+	}
+}
+
+func (r *specRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// buffered reports whether the response was held back and is still to be
+// written.
+func (r *specRecorder) buffered() bool {
+	return r.wrote && !r.through
+}
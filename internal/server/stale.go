@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+const (
+	// defaultStaleAge is the ?olderThan of GET /api/reports/stale.
+	defaultStaleAge = "30d"
+	// maxStaleReport caps the todos one stale report lists.
+	maxStaleReport = 500
+)
+
+// staleTodo is an open todo with how long it has gone untouched.
+type staleTodo struct {
+	db.Todo
+	IdleDays int `json:"idleDays"`
+}
+
+// staleFilter selects the open todos last updated before cutoff.
+func staleFilter(cutoff time.Time) db.TodoFilter {
+	open := false
+	return db.TodoFilter{Completed: &open, UpdatedBefore: cutoff}
+}
+
+// handleStaleReport lists the open todos not updated for ?olderThan
+// (default 30d), longest untouched first, so they can be completed, snoozed
+// or deleted. ?listId narrows it to one list.
+func (s *Server) handleStaleReport(w http.ResponseWriter, r *http.Request) {
+	lister, ok := s.store.(db.FilteredLister)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "filtering not supported by storage backend")
+		return
+	}
+	q := r.URL.Query()
+	olderThan := q.Get("olderThan")
+	if olderThan == "" {
+		olderThan = defaultStaleAge
+	}
+	age, err := parseAge(olderThan)
+	if err != nil || age <= 0 {
+		writeError(w, http.StatusBadRequest, "olderThan must be a positive age such as 30d, 2w or 12h")
+		return
+	}
+	now := time.Now()
+	filter := staleFilter(now.Add(-age))
+	if v := q.Get("listId"); v != "" {
+		if filter.ListID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.ListID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid listId")
+			return
+		}
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	items, err := lister.ListTodosFiltered(ctx, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list todos")
+		return
+	}
+	slices.SortFunc(items, func(a, b db.Todo) int { return a.UpdatedAt.Compare(b.UpdatedAt) })
+	total := len(items)
+	out := make([]staleTodo, 0, min(total, maxStaleReport))
+	for _, t := range items[:min(total, maxStaleReport)] {
+		out = append(out, staleTodo{Todo: s.present(t), IdleDays: int(now.Sub(t.UpdatedAt).Hours() / 24)})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"olderThan": olderThan,
+		"cutoff":    filter.UpdatedBefore.UTC(),
+		"total":     total,
+		"todos":     out,
+	})
+}
+
+// RunStaleNudges checks every interval for open todos that went untouched
+// for after, sending a todo.stale event to the owner's open clients for
+// each. A todo is nudged once, in the check after it crosses the line;
+// updating it starts the wait over.
+func (s *Server) RunStaleNudges(ctx context.Context, interval, after time.Duration) {
+	streamer, ok := s.store.(db.TodoStreamer)
+	if !ok {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			done := s.jobs.start(jobStaleNudges)
+			n, err := s.nudgeStale(runCtx, streamer, last.Add(-after), now.Add(-after), now)
+			done(err)
+			if err != nil {
+				slog.WarnContext(ctx, "stale.nudge_failed", "error", err)
+			} else {
+				last = now
+				if n > 0 {
+					slog.InfoContext(ctx, "stale.nudged", "todos", n)
+				}
+			}
+			cancel()
+		}
+	}
+}
+
+// nudgeStale nudges the open todos last updated in [from, to) and returns
+// how many there were.
+func (s *Server) nudgeStale(ctx context.Context, streamer db.TodoStreamer, from, to, now time.Time) (int, error) {
+	filter := staleFilter(to)
+	filter.UpdatedSince = from
+	n := 0
+	err := streamer.StreamTodos(ctx, filter, func(t db.Todo) error {
+		n++
+		t = s.present(t)
+		days := int(now.Sub(t.UpdatedAt).Hours() / 24)
+		s.events.publish(ownerContext(ctx, t.OwnerID), todoEvent{
+			Type:    eventTodoStale,
+			Todo:    &t,
+			Message: fmt.Sprintf("Untouched for %d days: complete, snooze or delete it?", days),
+		})
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// maxVelocityWeeks bounds how far back velocity stats look.
+const maxVelocityWeeks = 52
+
+// statsRanges are the ?range values of GET /api/stats, in days.
+var statsRanges = map[string]int{"7d": 7, "30d": 30, "90d": 90}
+
+// maxStatsDays is the longest stats range, and so what the rollup covers.
+const maxStatsDays = 90
+
+// handleStats reports completion, todos created per day, average duration
+// by tag and the priority distribution of the todos created in the last
+// ?range=7d|30d|90d (default 30d), today included.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, ok := s.store.(db.StatsStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "stats not supported by storage backend")
+		return
+	}
+	name := r.URL.Query().Get("range")
+	if name == "" {
+		name = "30d"
+	}
+	days, ok := statsRanges[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "range must be 7d, 30d or 90d")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1))
+	st, err := stats.TodoStats(ctx, since)
+	if err != nil {
+		slog.ErrorContext(ctx, "stats.failed", "range", name, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to compute stats")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Range string `json:"range"`
+		db.TodoStats
+	}{name, st})
+}
+
+// RunStatsRollup refreshes the stats rollup now and then every interval
+// until ctx is cancelled. Backends without one are left alone.
+func (s *Server) RunStatsRollup(ctx context.Context, interval time.Duration) {
+	rollup, ok := s.store.(db.StatsRollup)
+	if !ok {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		start := time.Now()
+		runCtx, cancel := context.WithTimeout(ctx, interval)
+		done := s.jobs.start(jobStatsRollup)
+		err := rollup.RefreshStatsRollup(runCtx, start.UTC().AddDate(0, 0, -maxStatsDays))
+		done(err)
+		if err != nil {
+			slog.WarnContext(ctx, "stats.rollup_failed", "error", err)
+		} else {
+			slog.DebugContext(ctx, "stats.rollup_refreshed", "took_ms", time.Since(start).Milliseconds())
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+type weekVelocity struct {
+	WeekStart string `json:"weekStart"`
+	Points    int    `json:"points"`
+	Completed int    `json:"completed"`
+}
+
+// handleVelocity reports effort points completed per ISO week (Monday, UTC)
+// for the last ?weeks=N weeks, oldest first. A todo's completion time is
+// approximated by its last update.
+func (s *Server) handleVelocity(w http.ResponseWriter, r *http.Request) {
+	if s.hideEffort {
+		writeError(w, http.StatusNotFound, "effort tracking disabled")
+		return
+	}
+	streamer, ok := s.store.(db.TodoStreamer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "stats not supported by storage backend")
+		return
+	}
+	weeks := 8
+	if v := r.URL.Query().Get("weeks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxVelocityWeeks {
+			writeError(w, http.StatusBadRequest, "weeks must be between 1 and 52")
+			return
+		}
+		weeks = n
+	}
+
+	current := weekStart(time.Now().UTC())
+	since := current.AddDate(0, 0, -7*(weeks-1))
+	out := make([]weekVelocity, weeks)
+	for i := range out {
+		out[i].WeekStart = since.AddDate(0, 0, 7*i).Format(time.DateOnly)
+	}
+
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	completed := true
+	err := streamer.StreamTodos(ctx, db.TodoFilter{Completed: &completed, UpdatedSince: since}, func(t db.Todo) error {
+		i := int(weekStart(t.UpdatedAt.UTC()).Sub(since).Hours() / (7 * 24))
+		if i < 0 || i >= weeks {
+			return nil
+		}
+		out[i].Completed++
+		if t.Effort != nil {
+			out[i].Points += *t.Effort
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to compute velocity")
+		return
+	}
+
+	total := 0
+	for _, wk := range out {
+		total += wk.Points
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"weeks":   out,
+		"average": float64(total) / float64(weeks),
+	})
+}
+
+// weekStart returns midnight UTC of the Monday starting t's week.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// present hides fields the deployment has switched off.
+func (s *Server) present(t db.Todo) db.Todo {
+	if s.hideEffort {
+		t.Effort = nil
+	}
+	if s.priorityHalfLife > 0 {
+		p := effectivePriority(t, s.priorityHalfLife, time.Now())
+		t.EffectivePriority = &p
+	}
+	return t
+}
+
+// effectivePriority halves an open todo's score every halfLife since its
+// last update, so a todo left alone stops outranking fresh work on the
+// strength of an old score. Done todos keep their score.
+func effectivePriority(t db.Todo, halfLife time.Duration, now time.Time) float64 {
+	age := now.Sub(t.UpdatedAt)
+	if t.Completed || age <= 0 {
+		return t.PriorityScore
+	}
+	p := t.PriorityScore * math.Exp2(-age.Seconds()/halfLife.Seconds())
+	return math.Round(p*1e4) / 1e4
+}
+
+// effortInput returns the effort to store: the requested value, or the
+// current one when effort tracking is disabled so hidden estimates survive
+// updates.
+func (s *Server) effortInput(requested, current *int) *int {
+	if s.hideEffort {
+		return current
+	}
+	return requested
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"todoapp/internal/slo"
+)
+
+// statusPageFiles holds the status page template and stylesheet.
+//
+//go:embed statuspage
+var statusPageFiles embed.FS
+
+var statusPageTemplate = template.Must(template.New("status.html").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) },
+	"when":    func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 UTC") },
+	"uptime":  formatUptime,
+}).ParseFS(statusPageFiles, "statuspage/status.html"))
+
+const (
+	// statusCacheTTL bounds how often the page checks dependencies, however
+	// many clients poll it.
+	statusCacheTTL = 15 * time.Second
+	// statusPagePolicy lets the page load nothing but its stylesheet.
+	statusPagePolicy = "default-src 'none'; style-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+)
+
+// statusRate is the per-IP limit on /status, which is served outside the
+// API and its limiter.
+var statusRate = Rate{PerSecond: 1, Burst: 10}
+
+// statusPage serves the public status summary.
+type statusPage struct {
+	started time.Time
+	limiter *rateLimiter
+
+	mu      sync.Mutex
+	cached  statusSummary
+	expires time.Time
+}
+
+// WithStatusPage serves a public, unauthenticated status page at /status:
+// uptime, component health and the incidents the SLO tracker saw. It
+// leaves out error details and anything else only operators should see.
+func WithStatusPage(enabled bool) Option {
+	return func(s *Server) {
+		if enabled {
+			s.status = &statusPage{started: time.Now(), limiter: newRateLimiter(statusRate)}
+		}
+	}
+}
+
+// statusSummary is what /status renders.
+type statusSummary struct {
+	Name string `json:"name"`
+	// Status is "operational", "degraded" (an optional component is down or
+	// an incident is ongoing) or "outage" (a required component is down).
+	Status        string            `json:"status"`
+	Since         time.Time         `json:"since"`
+	UptimeSeconds int64             `json:"uptimeSeconds"`
+	Components    []componentStatus `json:"components"`
+	Availability  []groupStatus     `json:"availability"`
+	Incidents     []slo.Incident    `json:"incidents"`
+	CheckedAt     time.Time         `json:"checkedAt"`
+}
+
+type componentStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "up" or "down"
+}
+
+// groupStatus is one SLO group's observed availability over its period.
+type groupStatus struct {
+	Group    string  `json:"group"`
+	Observed float64 `json:"observed"`
+	Target   float64 `json:"target"`
+	Period   string  `json:"period"`
+}
+
+// statusSummary returns the cached summary, refreshing it when stale.
+func (s *Server) statusSummary(r *http.Request) statusSummary {
+	p := s.status
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if now.Before(p.expires) {
+		sum := p.cached
+		sum.UptimeSeconds = int64(now.Sub(p.started).Seconds())
+		return sum
+	}
+
+	sum := statusSummary{
+		Name:          s.branding.AppName,
+		Status:        "operational",
+		Since:         p.started.UTC(),
+		UptimeSeconds: int64(now.Sub(p.started).Seconds()),
+		Components:    []componentStatus{},
+		Availability:  []groupStatus{},
+		Incidents:     s.objectives.Incidents(),
+		CheckedAt:     now.UTC(),
+	}
+	if sum.Name == "" {
+		sum.Name = "Todo"
+	}
+	if sum.Incidents == nil {
+		sum.Incidents = []slo.Incident{}
+	}
+	for name, st := range s.checkDependencies(r.Context()) {
+		sum.Components = append(sum.Components, componentStatus{Name: name, Status: st.Status})
+		switch {
+		case st.Status == "up":
+		case st.Required:
+			sum.Status = "outage"
+		case sum.Status == "operational":
+			sum.Status = "degraded"
+		}
+	}
+	sort.Slice(sum.Components, func(i, j int) bool { return sum.Components[i].Name < sum.Components[j].Name })
+	if len(sum.Incidents) > 0 && sum.Incidents[0].End == nil && sum.Status == "operational" {
+		sum.Status = "degraded"
+	}
+	if s.objectives != nil {
+		for _, rep := range s.objectives.Summary() {
+			sum.Availability = append(sum.Availability, groupStatus{
+				Group:    rep.Group,
+				Observed: rep.Availability.Observed,
+				Target:   rep.Availability.Target,
+				Period:   rep.Period,
+			})
+		}
+	}
+	p.cached, p.expires = sum, now.Add(statusCacheTTL)
+	return sum
+}
+
+// handleStatusPage renders the status summary as HTML, or as JSON for
+// clients that accept it. It is rate limited per IP on its own, since it
+// sits outside the API.
+func (s *Server) handleStatusPage(w http.ResponseWriter, r *http.Request) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if ok, t := s.status.limiter.allow(ip, time.Now()); !ok {
+		slog.DebugContext(r.Context(), "status.rate_limited", "ip", ip)
+		writeThrottled(w, t, "too many requests from this address")
+		return
+	}
+	sum := s.statusSummary(r)
+	w.Header().Set("Cache-Control", "public, max-age=15")
+	w.Header().Set("Vary", "Accept")
+	s.cacheAtEdge(w, cdnTagStatus)
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		writeJSON(w, http.StatusOK, sum)
+		return
+	}
+	var buf bytes.Buffer
+	if err := statusPageTemplate.Execute(&buf, sum); err != nil {
+		slog.ErrorContext(r.Context(), "status.render_failed", "error", err)
+		http.Error(w, "failed to render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Security-Policy", statusPagePolicy)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// formatUptime renders seconds as days, hours and minutes.
+func formatUptime(seconds int64) string {
+	d, h, m := seconds/86400, seconds%86400/3600, seconds%3600/60
+	switch {
+	case d > 0:
+		return fmt.Sprintf("%dd %dh", d, h)
+	case h > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+	return fmt.Sprintf("%dm", m)
+}
+
+func (s *Server) serveStatusCSS(w http.ResponseWriter, r *http.Request) {
+	css, _ := statusPageFiles.ReadFile("statuspage/status.css")
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	s.cacheAtEdge(w, cdnTagStatus)
+	_, _ = w.Write(css)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// streamWriteTimeout bounds each item write, replacing the server-wide write
+// timeout so large exports are limited by client progress rather than size.
+const streamWriteTimeout = 15 * time.Second
+
+// handleStreamTodos writes matching todos as newline-delimited JSON, flushing
+// after every item so consumers (jq, data pipelines) can process the stream
+// incrementally.
+func (s *Server) handleStreamTodos(w http.ResponseWriter, r *http.Request) {
+	streamer, ok := s.store.(db.TodoStreamer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "streaming not supported by storage backend")
+		return
+	}
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	done, leave, ok := s.streams.join()
+	if !ok {
+		writeThrottled(w, throttle{Reason: reasonShuttingDown, RetryAfter: streamReconnectDelay}, "server is restarting")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	count := 0
+	err = streamer.StreamTodos(r.Context(), filter, func(t db.Todo) error {
+		select {
+		case <-done:
+			return ErrDraining
+		default:
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err := enc.Encode(s.present(t)); err != nil {
+			return err
+		}
+		count++
+		return rc.Flush()
+	})
+	if errors.Is(err, ErrDraining) {
+		// The notice is the only non-todo record a stream can carry; clients
+		// tell it apart by its "event" field.
+		_ = enc.Encode(newRestartNotice())
+		_ = rc.Flush()
+		leave(true)
+		slog.InfoContext(r.Context(), "todo.stream_drained", "items", count)
+		return
+	}
+	leave(false)
+	if err != nil {
+		// Headers are already sent; the truncated stream signals the failure.
+		slog.WarnContext(r.Context(), "todo.stream_aborted", "items", count, "error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+)
+
+type subscriptionRequest struct {
+	Name           string `json:"name"`
+	Tag            string `json:"tag"`
+	ListID         *int64 `json:"listId"`
+	DueWithinHours int    `json:"dueWithinHours"`
+}
+
+// subscriptionStore returns the backend's subscription support, writing 501
+// when missing.
+func (s *Server) subscriptionStore(w http.ResponseWriter) (db.SubscriptionStore, bool) {
+	subs, ok := s.store.(db.SubscriptionStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "subscriptions not supported by storage backend")
+	}
+	return subs, ok
+}
+
+func (s *Server) handleListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, ok := s.subscriptionStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := subs.ListSubscriptions(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list subscriptions")
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateSubscription saves a subscription. Todos already matching it
+// are recorded as notified, so only todos that start matching later are
+// announced.
+func (s *Server) handleCreateSubscription(w http.ResponseWriter, r *http.Request) {
+	subs, ok := s.subscriptionStore(w)
+	if !ok {
+		return
+	}
+	streamer, ok := s.store.(db.TodoStreamer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "subscriptions not supported by storage backend")
+		return
+	}
+	var req subscriptionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	sub, err := subs.CreateSubscription(ctx, db.SaveSubscriptionInput{
+		Name:           req.Name,
+		Tag:            req.Tag,
+		ListID:         req.ListID,
+		DueWithinHours: req.DueWithinHours,
+	})
+	if errors.Is(err, db.ErrListNotFound) {
+		writeError(w, http.StatusBadRequest, "list not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := s.matchSubscription(ctx, subs, streamer, sub, time.Now()); err != nil {
+		// The first scheduled check announces what is missed here.
+		slog.WarnContext(ctx, "subscription.seed_failed", "id", sub.ID, "error", err)
+	}
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+func (s *Server) handleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	subs, ok := s.subscriptionStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := subs.DeleteSubscription(ctx, id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunSubscriptions checks every subscription every interval until ctx is
+// cancelled, sending a subscription.match event to the owner's open clients
+// for each todo that matches one for the first time.
+func (s *Server) RunSubscriptions(ctx context.Context, interval time.Duration) {
+	subs, ok := s.store.(db.SubscriptionStore)
+	streamer, ok2 := s.store.(db.TodoStreamer)
+	if !ok || !ok2 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			done := s.jobs.start(jobSubscriptions)
+			err := s.checkSubscriptions(runCtx, subs, streamer, now)
+			done(err)
+			if err != nil {
+				slog.WarnContext(ctx, "subscription.check_failed", "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (s *Server) checkSubscriptions(ctx context.Context, subs db.SubscriptionStore, streamer db.TodoStreamer, now time.Time) error {
+	all, err := subs.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sub := range all {
+		subCtx := ownerContext(ctx, sub.OwnerID)
+		fresh, err := s.matchSubscription(subCtx, subs, streamer, sub, now)
+		if err != nil {
+			return err
+		}
+		for _, t := range fresh {
+			t = s.present(t)
+			s.events.publish(subCtx, todoEvent{Type: eventSubscriptionMatch, Todo: &t, Message: fmt.Sprintf("New match for %q", sub.Name)})
+		}
+		if len(fresh) > 0 {
+			slog.InfoContext(ctx, "subscription.matched", "id", sub.ID, "todos", len(fresh))
+		}
+	}
+	return nil
+}
+
+// matchSubscription records the todos matching sub at now and returns those
+// it had not matched before. ctx must be scoped to sub's owner.
+func (s *Server) matchSubscription(ctx context.Context, subs db.SubscriptionStore, streamer db.TodoStreamer, sub db.Subscription, now time.Time) ([]db.Todo, error) {
+	// Collect first: recording matches while streaming would write under
+	// an open read.
+	matching := map[int64]db.Todo{}
+	var ids []int64
+	err := streamer.StreamTodos(ctx, sub.Filter(now), func(t db.Todo) error {
+		if t.OwnerID == sub.OwnerID {
+			matching[t.ID] = t
+			ids = append(ids, t.ID)
+		}
+		return nil
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	fresh, err := subs.MarkNotified(ctx, sub.ID, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]db.Todo, len(fresh))
+	for i, id := range fresh {
+		out[i] = matching[id]
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// throttleReason is the machine-readable cause of a 429 or 503 response,
+// returned as "reason" in the problem+json body so clients can choose a
+// backoff strategy without parsing prose:
+//
+//   - rate_limited: the client exceeded its request quota; retry after the
+//     window resets (429).
+//   - overloaded: the server is shedding load to protect its dependencies;
+//     retry with jittered backoff (503).
+//   - upstream_unavailable: a dependency's circuit breaker is open; retry once
+//     it half-opens (503).
+//   - shutting_down: this instance is draining; retry immediately against
+//     another replica (503).
+type throttleReason string
+
+const (
+	reasonRateLimited         throttleReason = "rate_limited"
+	reasonOverloaded          throttleReason = "overloaded"
+	reasonUpstreamUnavailable throttleReason = "upstream_unavailable"
+	reasonShuttingDown        throttleReason = "shutting_down"
+)
+
+// throttle describes the limiter or breaker state behind a rejection. Limit
+// and Remaining are only meaningful for quota-based limiters; zero Limit
+// omits the RateLimit-* headers.
+type throttle struct {
+	Reason     throttleReason
+	RetryAfter time.Duration
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+}
+
+// problem is an RFC 9457 problem details body. Error repeats Title so
+// clients reading the usual {"error": ...} shape keep working.
+type problem struct {
+	Type       string         `json:"type"`
+	Title      string         `json:"title"`
+	Status     int            `json:"status"`
+	Detail     string         `json:"detail,omitempty"`
+	Reason     throttleReason `json:"reason"`
+	RetryAfter int            `json:"retryAfter"`
+	Error      string         `json:"error"`
+}
+
+// writeThrottled rejects a request with 429 (rate limits) or 503 (every
+// other reason), advertising when to retry through Retry-After and the
+// draft-ietf-httpapi-ratelimit-headers fields.
+func writeThrottled(w http.ResponseWriter, t throttle, detail string) {
+	status := http.StatusServiceUnavailable
+	if t.Reason == reasonRateLimited {
+		status = http.StatusTooManyRequests
+	}
+	retry := ceilSeconds(t.RetryAfter)
+	h := w.Header()
+	h.Set("Retry-After", strconv.Itoa(retry))
+	if t.Limit > 0 {
+		h.Set("RateLimit-Limit", strconv.Itoa(t.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(max(t.Remaining, 0)))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(t.Reset)))
+	}
+	title := http.StatusText(status)
+	writeJSONAs(w, status, "application/problem+json", problem{
+		Type:       "/problems/" + string(t.Reason),
+		Title:      title,
+		Status:     status,
+		Detail:     detail,
+		Reason:     t.Reason,
+		RetryAfter: retry,
+		Error:      title,
+	})
+}
+
+// ceilSeconds rounds d up to whole seconds, with a floor of one so clients
+// never busy-loop on "Retry-After: 0".
+func ceilSeconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
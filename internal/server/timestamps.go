@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// timestampExample shows clients the one form of timestamp the API takes.
+const timestampExample = "2024-05-01T09:00:00Z"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timestampError explains why a JSON body was refused when a timestamp in
+// it is to blame. Timestamps must be RFC 3339 with a time zone: dates
+// alone, local times and other layouts are ambiguous and refused.
+func timestampError(err error) (string, bool) {
+	var parseErr *time.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Sprintf("invalid timestamp %q: use RFC 3339 with a time zone, e.g. %s", parseErr.Value, timestampExample), true
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Type == timeType {
+		return fmt.Sprintf("%s must be an RFC 3339 timestamp string, e.g. %s", typeErr.Field, timestampExample), true
+	}
+	return "", false
+}
+
+// bodyError is the message for a JSON body that failed to decode:
+// timestampError's when it applies, fallback otherwise.
+func bodyError(err error, fallback string) string {
+	if msg, ok := timestampError(err); ok {
+		return msg
+	}
+	return fallback
+}
+
+// parseTimestamp parses a timestamp given outside JSON, as in CSV imports.
+func parseTimestamp(name, v string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: use RFC 3339 with a time zone, e.g. %s", name, v, timestampExample)
+	}
+	return t, nil
+}
+
+// parseRenderZone reads the tz parameter, the IANA time zone timestamps are
+// rendered in; they keep their offset, so they stay unambiguous. Without
+// it they are rendered in UTC.
+func parseRenderZone(r *http.Request) (*time.Location, error) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		return time.UTC, nil
+	}
+	// LoadLocation takes "" and "Local" for the server's own zone, and
+	// file paths; only zone names are meant here.
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" || strings.HasPrefix(name, "/") {
+		return nil, errors.New("tz must be an IANA time zone such as Europe/Berlin")
+	}
+	return loc, nil
+}
+
+// inZone returns t with its timestamps in loc.
+func inZone(t db.Todo, loc *time.Location) db.Todo {
+	at := func(v *time.Time) *time.Time {
+		if v == nil {
+			return nil
+		}
+		u := v.In(loc)
+		return &u
+	}
+	t.DueAt, t.StartAt = at(t.DueAt), at(t.StartAt)
+	t.CreatedAt, t.UpdatedAt = t.CreatedAt.In(loc), t.UpdatedAt.In(loc)
+	return t
+}
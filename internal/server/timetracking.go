@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"todoapp/internal/db"
+)
+
+type trackTimeRequest struct {
+	Minutes int `json:"minutes"`
+}
+
+// timeTracker returns the store's db.TimeTracker, writing 501 when it has
+// none.
+func (s *Server) timeTracker(w http.ResponseWriter) (db.TimeTracker, bool) {
+	tracker, ok := s.store.(db.TimeTracker)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "time tracking not supported by storage backend")
+	}
+	return tracker, ok
+}
+
+// handleTrackTime records time spent on a todo; its durationMinutes stays
+// the estimate the tracked time is compared with.
+func (s *Server) handleTrackTime(w http.ResponseWriter, r *http.Request) {
+	tracker, ok := s.timeTracker(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req trackTimeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<12)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	entry, err := tracker.TrackTime(ctx, id, req.Minutes)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, http.StatusNotFound, "todo not found")
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+// handleTimeEntries lists the time tracked on a todo, oldest first, with
+// its total.
+func (s *Server) handleTimeEntries(w http.ResponseWriter, r *http.Request) {
+	tracker, ok := s.timeTracker(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	entries, err := tracker.TimeEntries(ctx, id)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, http.StatusNotFound, "todo not found")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to list time entries")
+		return
+	}
+	total := 0
+	for _, e := range entries {
+		total += e.Minutes
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"totalMinutes": total, "entries": entries})
+}
+
+// handleEstimateAccuracy reports how the time tracked on completed todos
+// compared with their durationMinutes estimates, overall and per tag.
+func (s *Server) handleEstimateAccuracy(w http.ResponseWriter, r *http.Request) {
+	tracker, ok := s.timeTracker(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	acc, err := tracker.EstimateAccuracy(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "stats.estimates_failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to compute estimate accuracy")
+		return
+	}
+	writeJSON(w, http.StatusOK, acc)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"todoapp/internal/db"
+	"todoapp/internal/mlclient"
+)
+
+// maxFeatureEntries bounds how many owners' features are kept.
+const maxFeatureEntries = 4096
+
+// WithUserFeatures sends aggregates over the owner's todos (completion
+// rate, open todos, average duration of the todo's tags, how their
+// estimates compare with tracked time) with every score request. Each
+// owner's are recomputed at most every ttl; zero disables them, as do
+// backends without db.FeatureStore.
+func WithUserFeatures(ttl time.Duration) Option {
+	return func(s *Server) {
+		if ttl > 0 {
+			s.features = &featureCache{ttl: ttl, entries: make(map[int64]featureEntry)}
+		}
+	}
+}
+
+// featureCache maps an owner (0 when accounts are disabled) to their
+// features. A nil *featureCache is a valid, disabled cache.
+type featureCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[int64]featureEntry
+}
+
+type featureEntry struct {
+	features  db.UserFeatures
+	expiresAt time.Time
+}
+
+func (c *featureCache) get(ctx context.Context, now time.Time) (db.UserFeatures, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[ownerKey(ctx)]
+	if !ok || now.After(e.expiresAt) {
+		return db.UserFeatures{}, false
+	}
+	return e.features, true
+}
+
+func (c *featureCache) put(ctx context.Context, f db.UserFeatures, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := ownerKey(ctx)
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxFeatureEntries {
+		// Evict an arbitrary entry; a miss only costs one aggregation.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = featureEntry{features: f, expiresAt: now.Add(c.ttl)}
+}
+
+// userFeatures returns the features of the owner ctx is scoped to for a
+// todo tagged tags, or nil when they are disabled or failed: a score
+// without them is still a score.
+func (s *Server) userFeatures(ctx context.Context, tags []string) *mlclient.UserFeatures {
+	if s.features == nil {
+		return nil
+	}
+	store, ok := s.store.(db.FeatureStore)
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	f, hit := s.features.get(ctx, now)
+	if !hit {
+		var err error
+		if f, err = store.UserFeatures(ctx); err != nil {
+			slog.WarnContext(ctx, "ml.features_failed", "error", err)
+			return nil
+		}
+		s.features.put(ctx, f, now)
+	}
+	out := &mlclient.UserFeatures{CompletionRate: f.CompletionRate, OpenTodos: f.OpenTodos, DurationCorrection: f.DurationRatio}
+	for _, tag := range tags {
+		if avg, ok := f.AverageMinutesByTag[tag]; ok {
+			if out.AvgDurationByTag == nil {
+				out.AvgDurationByTag = map[string]float64{}
+			}
+			out.AvgDurationByTag[tag] = avg
+		}
+		if ratio, ok := f.DurationRatioByTag[tag]; ok {
+			if out.DurationCorrectionByTag == nil {
+				out.DurationCorrectionByTag = map[string]float64{}
+			}
+			out.DurationCorrectionByTag[tag] = ratio
+		}
+	}
+	return out
+}
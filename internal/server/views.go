@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+)
+
+type viewOrderRequest struct {
+	Items []struct {
+		ID     int64 `json:"id"`
+		Pinned bool  `json:"pinned"`
+	} `json:"items"`
+}
+
+func (s *Server) handleGetViewOrder(w http.ResponseWriter, r *http.Request) {
+	orderer, view, ok := s.viewOrderer(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	positions, err := orderer.ViewOrder(ctx, view)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load view order")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"view": view, "items": positions})
+}
+
+// handleSetViewOrder replaces a view's manual ordering. Items are listed in
+// display order; pinned items stay on top regardless of their place in the list.
+func (s *Server) handleSetViewOrder(w http.ResponseWriter, r *http.Request) {
+	orderer, view, ok := s.viewOrderer(w, r)
+	if !ok {
+		return
+	}
+	var req viewOrderRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	positions := make([]db.ViewPosition, 0, len(req.Items))
+	seen := make(map[int64]bool, len(req.Items))
+	for i, item := range req.Items {
+		if item.ID <= 0 || seen[item.ID] {
+			writeError(w, http.StatusBadRequest, "items must be distinct todo ids")
+			return
+		}
+		seen[item.ID] = true
+		positions = append(positions, db.ViewPosition{TodoID: item.ID, Position: i, Pinned: item.Pinned})
+	}
+
+	ctx, cancel := contextWithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := orderer.SetViewOrder(ctx, view, positions); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save view order")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) viewOrderer(w http.ResponseWriter, r *http.Request) (db.ViewOrderer, string, bool) {
+	orderer, ok := s.store.(db.ViewOrderer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "view ordering not supported by storage backend")
+		return nil, "", false
+	}
+	view := chi.URLParam(r, "view")
+	if !db.ValidViewKey(view) {
+		writeError(w, http.StatusBadRequest, "invalid view")
+		return nil, "", false
+	}
+	return orderer, view, true
+}
+
+// errUnsupportedView is returned when a list asks for a view ordering the
+// backend cannot provide.
+var errUnsupportedView = errors.New("view ordering not supported by storage backend")
+
+// orderByView rearranges items according to the stored ordering of view:
+// pinned items first, then manually positioned ones, then the rest in their
+// original order.
+func (s *Server) orderByView(ctx context.Context, view string, items []db.Todo) ([]db.Todo, error) {
+	orderer, ok := s.store.(db.ViewOrderer)
+	if !ok {
+		return nil, errUnsupportedView
+	}
+	positions, err := orderer.ViewOrder(ctx, view)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]int, len(items))
+	for i, t := range items {
+		byID[t.ID] = i
+	}
+	out := make([]db.Todo, 0, len(items))
+	placed := make([]bool, len(items))
+	for _, p := range positions {
+		if i, ok := byID[p.TodoID]; ok && !placed[i] {
+			out = append(out, items[i])
+			placed[i] = true
+		}
+	}
+	for i, t := range items {
+		if !placed[i] {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
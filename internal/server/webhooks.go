@@ -0,0 +1,601 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todoapp/internal/db"
+	"todoapp/internal/telemetry"
+)
+
+const (
+	// webhookTimeout bounds one delivery attempt.
+	webhookTimeout = 10 * time.Second
+	// webhookMaxAttempts is how many times a delivery is tried before it is
+	// marked failed.
+	webhookMaxAttempts = 8
+	// webhookRetryBase is the wait after the first failed attempt; it
+	// doubles with each further one, up to webhookRetryMax.
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax  = time.Hour
+	// webhookBatch is how many due deliveries one worker pass claims.
+	webhookBatch = 50
+)
+
+// WebhookDispatch tunes outbound webhook delivery.
+type WebhookDispatch struct {
+	// PerEndpoint caps the concurrent deliveries to one webhook (default 2).
+	PerEndpoint int
+	// PauseAfter pauses a webhook after that many failed attempts in a row
+	// until an admin resumes it; zero never pauses.
+	PauseAfter int
+	// AllowedNetworks are private networks webhooks may target anyway;
+	// otherwise only public addresses are delivered to.
+	AllowedNetworks []netip.Prefix
+}
+
+// WithWebhookDispatch configures how RunWebhookDeliveries delivers.
+func WithWebhookDispatch(d WebhookDispatch) Option {
+	return func(s *Server) {
+		s.webhookDispatch = d
+	}
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	// Enabled defaults to true.
+	Enabled  *bool            `json:"enabled"`
+	Filter   db.WebhookFilter `json:"filter"`
+	Template string           `json:"template"`
+}
+
+func (r webhookRequest) input() db.SaveWebhookInput {
+	return db.SaveWebhookInput{
+		URL:      r.URL,
+		Secret:   r.Secret,
+		Events:   r.Events,
+		Enabled:  r.Enabled == nil || *r.Enabled,
+		Filter:   r.Filter,
+		Template: r.Template,
+	}
+}
+
+// webhookResponse is a webhook as the API shows it: the secret is write-only.
+type webhookResponse struct {
+	db.Webhook
+	Secret    string `json:"secret,omitempty"`
+	HasSecret bool   `json:"hasSecret"`
+}
+
+func presentWebhook(w db.Webhook) webhookResponse {
+	return webhookResponse{Webhook: w, HasSecret: w.Secret != ""}
+}
+
+// webhookStore returns the backend's webhook support, writing 501 when missing.
+func (s *Server) webhookStore(w http.ResponseWriter) (db.WebhookStore, bool) {
+	webhooks, ok := s.store.(db.WebhookStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "webhooks not supported by storage backend")
+	}
+	return webhooks, ok
+}
+
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.webhookStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := webhooks.ListWebhooks(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list webhooks")
+		return
+	}
+	out := make([]webhookResponse, len(items))
+	for i, hook := range items {
+		out[i] = presentWebhook(hook)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.webhookStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	hook, err := webhooks.GetWebhook(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrWebhookNotFound) {
+			writeError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load webhook")
+		return
+	}
+	writeJSON(w, http.StatusOK, presentWebhook(hook))
+}
+
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.webhookStore(w)
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := checkWebhookURL(ctx, req.URL, s.webhookDispatch.AllowedNetworks); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hook, err := webhooks.CreateWebhook(ctx, req.input())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, presentWebhook(hook))
+}
+
+// handleUpdateWebhook replaces a webhook. An omitted secret keeps the
+// current one, since it is never shown back.
+func (s *Server) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.webhookStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req struct {
+		webhookRequest
+		Secret *string `json:"secret"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := checkWebhookURL(ctx, req.URL, s.webhookDispatch.AllowedNetworks); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	input := req.input()
+	if req.Secret != nil {
+		input.Secret = *req.Secret
+	} else if existing, err := webhooks.GetWebhook(ctx, id); err == nil {
+		input.Secret = existing.Secret
+	}
+	hook, err := webhooks.UpdateWebhook(ctx, id, input)
+	if err != nil {
+		if errors.Is(err, db.ErrWebhookNotFound) {
+			writeError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, presentWebhook(hook))
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.webhookStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := webhooks.DeleteWebhook(ctx, id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListWebhookDeliveries returns a webhook's latest deliveries, newest
+// first (?limit=N, at most 100).
+func (s *Server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.webhookStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > 100 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if _, err := webhooks.GetWebhook(ctx, id); err != nil {
+		if errors.Is(err, db.ErrWebhookNotFound) {
+			writeError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load webhook")
+		return
+	}
+	deliveries, err := webhooks.ListOutboundDeliveries(ctx, id, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list deliveries")
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// todoDeleted queues the todo.deleted webhooks for t, as it was before the
+// delete.
+func (s *Server) todoDeleted(ctx context.Context, t db.Todo) {
+	s.queueWebhooks(ctx, db.WebhookEventDeleted, t)
+}
+
+// queueWebhooks queues a delivery of event for t to each of its owner's
+// webhooks subscribed to it whose filter matches t, and wakes the delivery
+// worker. The body is rendered now, so a template sees the todo as of the
+// event. Failing to queue is logged: the change itself has already been
+// made.
+func (s *Server) queueWebhooks(ctx context.Context, event string, t db.Todo) {
+	webhooks, ok := s.store.(db.WebhookStore)
+	if !ok {
+		return
+	}
+	all, err := webhooks.ListWebhooks(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "webhook.load_failed", "event", event, "error", err)
+		return
+	}
+	p := db.WebhookPayload{Event: event, OccurredAt: time.Now().UTC(), Todo: s.present(t)}
+	queued := false
+	for _, hook := range all {
+		if hook.OwnerID != t.OwnerID || !hook.Wants(event, t) {
+			continue
+		}
+		payload, err := hook.Render(p)
+		if err != nil {
+			slog.WarnContext(ctx, "webhook.render_failed", "webhook_id", hook.ID, "event", event, "error", err)
+			continue
+		}
+		d := db.OutboundDelivery{WebhookID: hook.ID, Event: event, Payload: payload}
+		if err := webhooks.EnqueueDelivery(ctx, d); err != nil {
+			slog.WarnContext(ctx, "webhook.enqueue_failed", "webhook_id", hook.ID, "event", event, "error", err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		select {
+		case s.webhookWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// RunWebhookDeliveries delivers queued webhook events until ctx is
+// cancelled, polling every interval and as soon as events are queued.
+// Deliveries are claimed through the store, so several replicas can run it
+// against one database.
+func (s *Server) RunWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	webhooks, ok := s.store.(db.WebhookStore)
+	if !ok {
+		return
+	}
+	client := webhookClient(s.webhookDispatch.AllowedNetworks)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-s.webhookWake:
+		}
+		for {
+			n, err := s.deliverWebhooks(ctx, webhooks, client)
+			if err != nil {
+				slog.WarnContext(ctx, "webhook.claim_failed", "error", err)
+			}
+			if err != nil || n < webhookBatch {
+				break
+			}
+		}
+	}
+}
+
+// endpoint is a webhook within one delivery pass: the slots bounding its
+// concurrent deliveries and whether it stopped taking them, because the
+// receiver asked to slow down or the webhook was paused.
+type endpoint struct {
+	hook  db.Webhook
+	slots chan struct{}
+
+	mu        sync.Mutex
+	held      bool
+	heldUntil time.Time
+}
+
+// hold stops the endpoint's remaining deliveries of this pass; they are
+// due again at until.
+func (e *endpoint) hold(until time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.held = true
+	if until.After(e.heldUntil) {
+		e.heldUntil = until
+	}
+}
+
+func (e *endpoint) holding() (time.Time, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.heldUntil, e.held
+}
+
+// deliverWebhooks attempts each due delivery once, returning how many it
+// claimed. Deliveries run concurrently, at most PerEndpoint at a time to
+// the same webhook.
+func (s *Server) deliverWebhooks(ctx context.Context, webhooks db.WebhookStore, client *http.Client) (int, error) {
+	// The lease outlasts every attempt of the batch, so a delivery is not
+	// claimed again while it is in flight.
+	due, err := webhooks.ClaimDueDeliveries(ctx, time.Now(), webhookBatch*webhookTimeout, webhookBatch)
+	if err != nil {
+		return 0, err
+	}
+	perEndpoint := s.webhookDispatch.PerEndpoint
+	if perEndpoint <= 0 {
+		perEndpoint = 2
+	}
+	endpoints := map[int64]*endpoint{}
+	var wg sync.WaitGroup
+	for _, d := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		ep, seen := endpoints[d.WebhookID]
+		if !seen {
+			hook, err := webhooks.GetWebhook(ctx, d.WebhookID)
+			switch {
+			case errors.Is(err, db.ErrWebhookNotFound):
+			case err != nil:
+				slog.WarnContext(ctx, "webhook.load_failed", "webhook_id", d.WebhookID, "error", err)
+			default:
+				ep = &endpoint{hook: hook, slots: make(chan struct{}, perEndpoint)}
+			}
+			endpoints[d.WebhookID] = ep
+		}
+		if ep == nil {
+			continue
+		}
+		telemetry.JobWait.WithLabelValues(jobWebhookDelivery).Observe(max(time.Since(d.NextAttemptAt), 0).Seconds())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ep.slots <- struct{}{}
+			defer func() { <-ep.slots }()
+			s.attemptDelivery(ctx, webhooks, client, ep, d)
+		}()
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// attemptDelivery posts d to ep's webhook and records the outcome: a 2xx
+// response completes it; anything else schedules a retry with exponential
+// backoff until webhookMaxAttempts is reached. A 429, or a 503 with
+// Retry-After, is backpressure rather than failure: the attempt is not
+// counted and the whole webhook is held back for as long as the receiver
+// asked, or webhookRetryBase. Deliveries of a webhook disabled since they
+// were queued fail without an attempt; those of an endpoint held back
+// during this pass are put back without one.
+func (s *Server) attemptDelivery(ctx context.Context, webhooks db.WebhookStore, client *http.Client, ep *endpoint, d db.OutboundDelivery) {
+	hook := ep.hook
+	if until, held := ep.holding(); held {
+		d.NextAttemptAt = until
+		if err := webhooks.RecordDeliveryAttempt(ctx, d); err != nil {
+			slog.WarnContext(ctx, "webhook.record_failed", "delivery_id", d.ID, "error", err)
+		}
+		return
+	}
+	var err error
+	done := s.jobs.start(jobWebhookDelivery)
+	defer func() { done(err) }()
+	var retryAfter time.Duration
+	if hook.Enabled {
+		d.Attempts++
+		d.LastStatusCode, retryAfter, err = postWebhook(ctx, client, hook, d)
+	} else {
+		err = errors.New("webhook disabled")
+	}
+	throttled := err != nil && (d.LastStatusCode == http.StatusTooManyRequests || retryAfter > 0)
+	d.LastError = ""
+	switch {
+	case err == nil:
+		d.Status = db.DeliverySucceeded
+		slog.InfoContext(ctx, "webhook.delivered", "webhook_id", hook.ID, "delivery_id", d.ID, "event", d.Event, "attempts", d.Attempts)
+	case throttled:
+		d.Attempts--
+		if retryAfter <= 0 {
+			retryAfter = webhookRetryBase
+		}
+		d.LastError = err.Error()
+		d.NextAttemptAt = time.Now().UTC().Add(min(retryAfter, webhookRetryMax))
+		ep.hold(d.NextAttemptAt)
+		if err := webhooks.ThrottleWebhook(ctx, hook.ID, d.NextAttemptAt); err != nil {
+			slog.WarnContext(ctx, "webhook.throttle_failed", "webhook_id", hook.ID, "error", err)
+		}
+		slog.InfoContext(ctx, "webhook.throttled", "webhook_id", hook.ID, "delivery_id", d.ID, "status", d.LastStatusCode, "until", d.NextAttemptAt)
+	case !hook.Enabled || d.Attempts >= webhookMaxAttempts:
+		d.Status, d.LastError = db.DeliveryFailed, err.Error()
+		slog.WarnContext(ctx, "webhook.failed", "webhook_id", hook.ID, "delivery_id", d.ID, "event", d.Event, "attempts", d.Attempts, "error", err)
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = time.Now().UTC().Add(min(webhookRetryBase<<(d.Attempts-1), webhookRetryMax))
+		slog.InfoContext(ctx, "webhook.retry", "webhook_id", hook.ID, "delivery_id", d.ID, "event", d.Event, "attempts", d.Attempts, "next", d.NextAttemptAt, "error", err)
+	}
+	if err := webhooks.RecordDeliveryAttempt(ctx, d); err != nil {
+		slog.WarnContext(ctx, "webhook.record_failed", "delivery_id", d.ID, "error", err)
+	}
+	if hook.Enabled && !throttled {
+		paused, perr := webhooks.RecordEndpointOutcome(ctx, hook.ID, err != nil, s.webhookDispatch.PauseAfter)
+		if perr != nil {
+			slog.WarnContext(ctx, "webhook.record_failed", "webhook_id", hook.ID, "error", perr)
+		}
+		if paused {
+			// Paused webhooks are not claimed, so the rest are due as soon
+			// as it is resumed.
+			ep.hold(time.Now().UTC())
+		}
+	}
+}
+
+// postWebhook posts d's payload to hook and returns the response status,
+// zero when there was none, and for a 429 or 503 the wait the receiver
+// asked for in Retry-After. With a secret, X-Webhook-Signature carries
+// "sha256=" and the hex HMAC-SHA256 of the body.
+func postWebhook(ctx context.Context, client *http.Client, hook db.Webhook, d db.OutboundDelivery) (int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.ID, 10))
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(d.Payload)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var retryAfter time.Duration
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		if msg := bytes.TrimSpace(body); len(msg) > 0 {
+			return resp.StatusCode, retryAfter, fmt.Errorf("status %d: %s", resp.StatusCode, msg)
+		}
+		return resp.StatusCode, retryAfter, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, 0, nil
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP
+// date; zero means absent, invalid or already passed.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(0, time.Duration(secs)*time.Second)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(0, t.Sub(now))
+	}
+	return 0
+}
+
+// handleAdminPausedWebhooks lists the webhooks of every user that were
+// paused for failing persistently.
+func (s *Server) handleAdminPausedWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.webhookStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, err := webhooks.ListWebhooks(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list webhooks")
+		return
+	}
+	out := []webhookResponse{}
+	for _, hook := range items {
+		if hook.PausedAt != nil {
+			out = append(out, presentWebhook(hook))
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleAdminResumeWebhook resumes a paused or throttled webhook; its
+// pending deliveries are sent right away.
+func (s *Server) handleAdminResumeWebhook(w http.ResponseWriter, r *http.Request) {
+	webhooks, ok := s.webhookStore(w)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	hook, err := s.resumeWebhook(ctx, webhooks, id)
+	if err != nil {
+		if errors.Is(err, db.ErrWebhookNotFound) {
+			writeError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to resume webhook")
+		return
+	}
+	writeJSON(w, http.StatusOK, presentWebhook(hook))
+}
+
+// resumeWebhook resumes webhook id and wakes the delivery worker.
+func (s *Server) resumeWebhook(ctx context.Context, webhooks db.WebhookStore, id int64) (db.Webhook, error) {
+	hook, err := webhooks.ResumeWebhook(ctx, id)
+	if err != nil {
+		return db.Webhook{}, err
+	}
+	select {
+	case s.webhookWake <- struct{}{}:
+	default:
+	}
+	return hook, nil
+}
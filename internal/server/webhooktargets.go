@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Webhook URLs are chosen by users, so deliveries must not reach the
+// server's own network: loopback, private and link-local addresses (cloud
+// metadata services among them) are refused, both when a webhook is saved
+// and, against DNS rebinding, on every connection. Admins can open up
+// internal networks with WebhookDispatch.AllowedNetworks.
+
+// nonPublicNetworks are ranges outside the address families' own
+// classification that are still not the public internet.
+var nonPublicNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can reach IPv4 private ranges
+}
+
+// webhookTargetAllowed reports whether webhooks may connect to addr: public
+// unicast addresses, and those in allowed.
+func webhookTargetAllowed(addr netip.Addr, allowed []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range allowed {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicNetworks {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookURL resolves the host of a webhook URL and refuses it when
+// any of its addresses may not be delivered to.
+func checkWebhookURL(ctx context.Context, rawURL string, allowed []netip.Prefix) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// Left to the store's validation to explain.
+		return nil
+	}
+	host := u.Hostname()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("url host %q does not resolve", host)
+	}
+	for _, addr := range addrs {
+		if !webhookTargetAllowed(addr, allowed) {
+			return fmt.Errorf("url host %q resolves to %s, which is not a public address", host, addr.Unmap())
+		}
+	}
+	return nil
+}
+
+// webhookClient is the HTTP client deliveries use. Its dialer checks the
+// address actually connected to, so a host that resolved to a public
+// address when the webhook was saved cannot be pointed inward later;
+// redirects are dialed through it too. Proxies from the environment are
+// not used, since they would connect on the client's behalf.
+func webhookClient(allowed []netip.Prefix) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !webhookTargetAllowed(ap.Addr(), allowed) {
+				return fmt.Errorf("webhook target %s is not a public address", ap.Addr().Unmap())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = 5 * time.Second
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
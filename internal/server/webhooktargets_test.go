@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestWebhookTargetAllowed(t *testing.T) {
+	tests := []struct {
+		addr    string
+		allowed []netip.Prefix
+		want    bool
+	}{
+		{"93.184.216.34", nil, true},
+		{"2606:2800:220:1::1", nil, true},
+		{"127.0.0.1", nil, false},
+		{"::1", nil, false},
+		{"10.1.2.3", nil, false},
+		{"172.16.0.1", nil, false},
+		{"192.168.1.1", nil, false},
+		{"169.254.169.254", nil, false},
+		{"fe80::1", nil, false},
+		{"fd00::1", nil, false},
+		{"100.64.0.1", nil, false},
+		{"0.0.0.0", nil, false},
+		{"::ffff:127.0.0.1", nil, false},
+		{"64:ff9b::a00:1", nil, false},
+		{"10.1.2.3", []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, true},
+		{"::ffff:10.1.2.3", []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, true},
+	}
+	for _, tt := range tests {
+		if got := webhookTargetAllowed(netip.MustParseAddr(tt.addr), tt.allowed); got != tt.want {
+			t.Errorf("webhookTargetAllowed(%s, %v) = %v, want %v", tt.addr, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	ctx := context.Background()
+	for _, u := range []string{"http://127.0.0.1:8080/hook", "http://[::1]/hook", "http://169.254.169.254/latest/meta-data", "http://localhost/hook"} {
+		if err := checkWebhookURL(ctx, u, nil); err == nil {
+			t.Errorf("checkWebhookURL(%q) accepted a non-public target", u)
+		}
+	}
+	if err := checkWebhookURL(ctx, "http://127.0.0.1/hook", []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}); err != nil {
+		t.Errorf("checkWebhookURL with 127.0.0.0/8 allowed: %v", err)
+	}
+}
+
+func TestWebhookClientRefusesPrivateTargets(t *testing.T) {
+	hits := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer target.Close()
+
+	if _, err := webhookClient(nil).Get(target.URL); err == nil {
+		t.Fatal("delivery to a loopback address succeeded")
+	}
+	if hits != 0 {
+		t.Fatalf("loopback target received %d requests", hits)
+	}
+
+	resp, err := webhookClient([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}).Get(target.URL)
+	if err != nil {
+		t.Fatalf("allowed network refused: %v", err)
+	}
+	resp.Body.Close()
+}
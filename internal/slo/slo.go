@@ -0,0 +1,286 @@
+// Package slo measures availability and latency per route group against
+// service level objectives and reports how fast each group is spending its
+// error budget. Counts live in memory, so budgets restart with the process.
+package slo
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todoapp/internal/rolling"
+)
+
+// bucketCount is the resolution of each rolling window.
+const bucketCount = 60
+
+// Burn-rate windows follow the multiwindow convention: a fast window catches
+// sharp regressions, a slow one sustained erosion.
+const (
+	FastWindow = 5 * time.Minute
+	SlowWindow = time.Hour
+)
+
+// An incident opens when a group spends its availability budget at
+// IncidentBurnRate or faster over FastWindow, the usual paging threshold
+// (a 30-day budget gone in about two days), and closes once it drops below.
+const (
+	IncidentBurnRate = 14.4
+	// incidentMinRequests keeps a few failures on a quiet group from
+	// counting as an incident.
+	incidentMinRequests = 10
+	// incidentCheckEvery bounds how often Record re-evaluates a group.
+	incidentCheckEvery = 10 * time.Second
+	// maxIncidents bounds the resolved incidents kept.
+	maxIncidents = 20
+)
+
+// Objective is the target for one route group. A request is good when it did
+// not fail with a 5xx; it is fast when it finished within Latency.
+// Availability and LatencyTarget are fractions such as 0.999.
+type Objective struct {
+	Group         string
+	Prefix        string
+	Availability  float64
+	Latency       time.Duration
+	LatencyTarget float64
+}
+
+// Report summarises one objective.
+type Report struct {
+	Group            string `json:"group"`
+	Prefix           string `json:"prefix"`
+	Availability     SLI    `json:"availability"`
+	Latency          SLI    `json:"latency"`
+	LatencyThreshold string `json:"latencyThreshold"`
+	Period           string `json:"period"`
+	Requests         int    `json:"requests"`
+	Since            string `json:"since"`
+}
+
+// SLI is one indicator's state: the observed ratio over the budget period,
+// the share of the error budget left, and burn rates over the fast and slow
+// windows (1 means spending exactly at the sustainable pace).
+type SLI struct {
+	Target          float64 `json:"target"`
+	Observed        float64 `json:"observed"`
+	BudgetRemaining float64 `json:"budgetRemaining"`
+	BurnRateFast    float64 `json:"burnRate5m"`
+	BurnRateSlow    float64 `json:"burnRate1h"`
+}
+
+// Incident is a stretch of time during which one group failed fast enough
+// to page.
+type Incident struct {
+	Group string    `json:"group"`
+	Start time.Time `json:"start"`
+	// End is nil while the incident is ongoing.
+	End *time.Time `json:"end,omitempty"`
+}
+
+// Tracker records request outcomes per objective.
+type Tracker struct {
+	period  time.Duration
+	started time.Time
+
+	mu         sync.Mutex
+	objectives []Objective
+	windows    map[string]*windowSet
+	resolved   []Incident
+}
+
+type windowSet struct {
+	fast, slow, period *rolling.Window
+	// checked is when the group was last evaluated for incidents; ongoing
+	// is when its current incident started, zero when there is none.
+	checked, ongoing time.Time
+}
+
+// NewTracker tracks objectives with error budgets computed over period.
+// Objectives are matched by longest path prefix.
+func NewTracker(period time.Duration, objectives ...Objective) *Tracker {
+	t := &Tracker{
+		period:     period,
+		started:    time.Now(),
+		objectives: append([]Objective(nil), objectives...),
+		windows:    make(map[string]*windowSet),
+	}
+	sort.SliceStable(t.objectives, func(i, j int) bool {
+		return len(t.objectives[i].Prefix) > len(t.objectives[j].Prefix)
+	})
+	for _, o := range t.objectives {
+		t.windows[o.Group] = &windowSet{
+			fast:   rolling.New(FastWindow, bucketCount),
+			slow:   rolling.New(SlowWindow, bucketCount),
+			period: rolling.New(period, bucketCount),
+		}
+	}
+	return t
+}
+
+// Record counts one request. Paths outside every objective are ignored, as
+// is everything on a nil Tracker.
+func (t *Tracker) Record(path string, status int, elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+	o, ok := t.match(path)
+	if !ok {
+		return
+	}
+	failed := status >= http.StatusInternalServerError
+	slow := elapsed > o.Latency
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ws := t.windows[o.Group]
+	ws.fast.Add(now, failed, slow)
+	ws.slow.Add(now, failed, slow)
+	ws.period.Add(now, failed, slow)
+	if now.Sub(ws.checked) >= incidentCheckEvery {
+		t.evaluate(o, ws, now)
+	}
+}
+
+// evaluate opens or closes o's incident. t.mu must be held.
+func (t *Tracker) evaluate(o Objective, ws *windowSet, now time.Time) {
+	ws.checked = now
+	c := ws.fast.Sum(now)
+	burning := c.Total >= incidentMinRequests && burnRate(1-o.Availability, c.Total, c.Failed) >= IncidentBurnRate
+	switch {
+	case burning && ws.ongoing.IsZero():
+		ws.ongoing = now
+	case !burning && !ws.ongoing.IsZero():
+		end := now
+		t.resolved = append(t.resolved, Incident{Group: o.Group, Start: ws.ongoing, End: &end})
+		if len(t.resolved) > maxIncidents {
+			t.resolved = t.resolved[len(t.resolved)-maxIncidents:]
+		}
+		ws.ongoing = time.Time{}
+	}
+}
+
+// Incidents lists ongoing and recently resolved incidents, newest first.
+// A nil Tracker has none.
+func (t *Tracker) Incidents() []Incident {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Incident, 0, len(t.resolved)+len(t.objectives))
+	for _, o := range t.objectives {
+		ws := t.windows[o.Group]
+		t.evaluate(o, ws, now)
+		if !ws.ongoing.IsZero() {
+			out = append(out, Incident{Group: o.Group, Start: ws.ongoing})
+		}
+	}
+	out = append(out, t.resolved...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.After(out[j].Start) })
+	return out
+}
+
+func (t *Tracker) match(path string) (Objective, bool) {
+	for _, o := range t.objectives {
+		if path == o.Prefix || strings.HasPrefix(path, strings.TrimSuffix(o.Prefix, "/")+"/") {
+			return o, true
+		}
+	}
+	return Objective{}, false
+}
+
+// Summary reports every objective, ordered by group name.
+func (t *Tracker) Summary() []Report {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Report, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		ws := t.windows[o.Group]
+		fast, slow, period := ws.fast.Sum(now), ws.slow.Sum(now), ws.period.Sum(now)
+		out = append(out, Report{
+			Group:            o.Group,
+			Prefix:           o.Prefix,
+			Availability:     sli(o.Availability, fast.Total, fast.Failed, slow.Total, slow.Failed, period.Total, period.Failed),
+			Latency:          sli(o.LatencyTarget, fast.Total, fast.Slow, slow.Total, slow.Slow, period.Total, period.Slow),
+			LatencyThreshold: o.Latency.String(),
+			Period:           t.period.String(),
+			Requests:         period.Total,
+			Since:            t.started.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Group < out[j].Group })
+	return out
+}
+
+func sli(target float64, fastTotal, fastBad, slowTotal, slowBad, total, bad int) SLI {
+	budget := 1 - target
+	s := SLI{
+		Target:          target,
+		Observed:        1,
+		BudgetRemaining: 1,
+		BurnRateFast:    burnRate(budget, fastTotal, fastBad),
+		BurnRateSlow:    burnRate(budget, slowTotal, slowBad),
+	}
+	if total > 0 {
+		s.Observed = 1 - float64(bad)/float64(total)
+		if budget > 0 {
+			s.BudgetRemaining = 1 - float64(bad)/float64(total)/budget
+		}
+	}
+	return s
+}
+
+func burnRate(budget float64, total, bad int) float64 {
+	if total == 0 || budget <= 0 {
+		return 0
+	}
+	return float64(bad) / float64(total) / budget
+}
+
+// ParseObjectives reads a ";"-separated list of
+// "group:availability%:latency[:latency%]" entries, e.g.
+// "todos:99.9:300ms:99;lists:99.5:500ms". Each group covers /api/<group>;
+// the latency target defaults to 99%.
+func ParseObjectives(spec string) ([]Objective, error) {
+	var out []Objective
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 3 || len(parts) > 4 || parts[0] == "" {
+			return nil, fmt.Errorf("slo %q: want group:availability:latency[:latency%%]", entry)
+		}
+		o := Objective{Group: parts[0], Prefix: "/api/" + parts[0], LatencyTarget: 0.99}
+		var err error
+		if o.Availability, err = parsePercent(parts[1]); err != nil {
+			return nil, fmt.Errorf("slo %q: availability: %w", entry, err)
+		}
+		if o.Latency, err = time.ParseDuration(parts[2]); err != nil || o.Latency <= 0 {
+			return nil, fmt.Errorf("slo %q: invalid latency threshold", entry)
+		}
+		if len(parts) == 4 {
+			if o.LatencyTarget, err = parsePercent(parts[3]); err != nil {
+				return nil, fmt.Errorf("slo %q: latency target: %w", entry, err)
+			}
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || v <= 0 || v >= 100 {
+		return 0, fmt.Errorf("%q must be a percentage between 0 and 100", s)
+	}
+	return v / 100, nil
+}
@@ -0,0 +1,134 @@
+// Package stats counts high-frequency events in memory and flushes daily
+// totals to the database now and then, so hot paths such as score cache
+// lookups and SSE sends never wait on a write. Counts not yet flushed are
+// lost if the process dies without shutting down.
+package stats
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"todoapp/internal/db"
+)
+
+// Counter is a named event count, safe for concurrent use.
+type Counter struct {
+	name string
+	n    atomic.Int64
+}
+
+var (
+	mu       sync.Mutex
+	counters []*Counter
+)
+
+// NewCounter registers a counter. Names must be unique and should be
+// registered at package init.
+func NewCounter(name string) *Counter {
+	mu.Lock()
+	defer mu.Unlock()
+	c := &Counter{name: name}
+	counters = append(counters, c)
+	return c
+}
+
+// Inc counts one event.
+func (c *Counter) Inc() {
+	c.n.Add(1)
+}
+
+// Add counts n events.
+func (c *Counter) Add(n int64) {
+	c.n.Add(n)
+}
+
+var (
+	// MLCacheHits and MLCacheMisses count ML score cache lookups.
+	MLCacheHits   = NewCounter("ml_cache_hits")
+	MLCacheMisses = NewCounter("ml_cache_misses")
+	// SSEMessages counts events sent to /api/todos/events subscribers,
+	// heartbeats aside.
+	SSEMessages = NewCounter("sse_messages")
+	// MLScoresDeferred counts scores skipped on tight request deadlines.
+	MLScoresDeferred = NewCounter("ml_scores_deferred")
+)
+
+// Pending returns the counts not yet flushed, by name.
+func Pending() map[string]int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make(map[string]int64, len(counters))
+	for _, c := range counters {
+		out[c.name] = c.n.Load()
+	}
+	return out
+}
+
+// drain takes the counts not yet flushed, leaving zero behind.
+func drain() map[string]int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make(map[string]int64, len(counters))
+	for _, c := range counters {
+		if n := c.n.Swap(0); n != 0 {
+			out[c.name] = n
+		}
+	}
+	return out
+}
+
+// restore puts counts a failed flush took back.
+func restore(counts map[string]int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, c := range counters {
+		c.n.Add(counts[c.name])
+	}
+}
+
+// Flusher writes the counters to a store.
+type Flusher struct {
+	store    db.EventCounter
+	interval time.Duration
+}
+
+// NewFlusher returns a Flusher writing to store every interval.
+func NewFlusher(store db.EventCounter, interval time.Duration) *Flusher {
+	return &Flusher{store: store, interval: interval}
+}
+
+// Run flushes every interval until ctx is cancelled. Call Flush once more
+// on shutdown to keep the last interval's counts.
+func (f *Flusher) Run(ctx context.Context) {
+	t := time.NewTicker(f.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := f.Flush(ctx); err != nil {
+				slog.WarnContext(ctx, "stats.flush_failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush adds the pending counts to today's totals; the counts of an
+// interval spanning midnight all go to the day it is flushed on. On failure
+// the counts stay pending for the next flush.
+func (f *Flusher) Flush(ctx context.Context) error {
+	counts := drain()
+	if len(counts) == 0 {
+		return nil
+	}
+	if err := f.store.AddEventCounts(ctx, time.Now().UTC().Format(time.DateOnly), counts); err != nil {
+		restore(counts)
+		return err
+	}
+	slog.DebugContext(ctx, "stats.flushed", "counters", len(counts))
+	return nil
+}
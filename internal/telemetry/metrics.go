@@ -0,0 +1,190 @@
+// Package telemetry holds the Prometheus metrics and OpenTelemetry tracing
+// shared by the HTTP server, the storage layer and the ML client.
+package telemetry
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric the process exports. It is separate from the
+// Prometheus default registry so third-party packages cannot add to it.
+var Registry = prometheus.NewRegistry()
+
+// Latency buckets in seconds, from sub-millisecond queries to slow ML calls.
+var latencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	// HTTPRequests counts responses by method, chi route pattern and status.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_http_requests_total",
+		Help: "HTTP responses by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	// HTTPDuration observes request latency by method and route. Long-lived
+	// streams are left out.
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "todo_http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: latencyBuckets,
+	}, []string{"method", "route"})
+
+	// DBQueryDuration observes SQL statement latency by dialect, statement
+	// kind (select, insert, ...) and outcome.
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "todo_db_query_duration_seconds",
+		Help:    "SQL statement latency by dialect, statement kind and outcome.",
+		Buckets: latencyBuckets,
+	}, []string{"dialect", "statement", "outcome"})
+
+	// MLRequests counts calls to the ML service by operation and outcome.
+	MLRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_ml_requests_total",
+		Help: "Calls to the ML scoring service by operation and outcome.",
+	}, []string{"operation", "outcome"})
+	// MLDuration observes ML service latency by operation.
+	MLDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "todo_ml_request_duration_seconds",
+		Help:    "ML scoring service latency by operation.",
+		Buckets: latencyBuckets,
+	}, []string{"operation"})
+	// MLRetries counts ML calls retried after a transient failure.
+	MLRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "todo_ml_retries_total",
+		Help: "ML scoring calls retried after a transient failure.",
+	})
+	// MLCacheLookups counts score cache lookups by result (hit, miss).
+	MLCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_ml_cache_lookups_total",
+		Help: "ML score cache lookups by result.",
+	}, []string{"result"})
+	// MLBreakerState is the ML client's circuit breaker state: 0 closed,
+	// 1 half-open, 2 open.
+	MLBreakerState = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "todo_ml_breaker_state",
+		Help: "ML client circuit breaker state (0 closed, 1 half-open, 2 open).",
+	})
+
+	// MLHedges counts hedged ML calls by result: sent, won (the hedge
+	// answered first) or over_budget (due but not sent).
+	MLHedges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_ml_hedges_total",
+		Help: "Hedged ML scoring requests by result (sent, won, over_budget).",
+	}, []string{"result"})
+
+	// MLScoresDeferred counts scores a request skipped because too little
+	// of its deadline was left, by result: skipped (the todo kept its
+	// fallback score), scored (scored later in the background) or dropped
+	// (the queue was full; the next rescore catches up).
+	MLScoresDeferred = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_ml_scores_deferred_total",
+		Help: "ML scores deferred off the request path by result (skipped, scored, dropped).",
+	}, []string{"result"})
+
+	// AuditForwarded counts audit log entries sent to the syslog or SIEM
+	// collector by result: sent, or failed (the batch is retried).
+	AuditForwarded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_audit_forwarded_total",
+		Help: "Audit log entries forwarded to an external collector by result (sent, failed).",
+	}, []string{"result"})
+
+	// EventLogEvents counts live events exported to the event log by
+	// result: written, dropped (the writer fell behind) or failed.
+	EventLogEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_event_log_events_total",
+		Help: "Live events exported to the event log by result (written, dropped, failed).",
+	}, []string{"result"})
+
+	// APIFeatureRequests counts requests opting into an experimental API
+	// feature through X-Api-Features, by feature ("unknown" for names the
+	// server does not know).
+	APIFeatureRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_api_feature_requests_total",
+		Help: "Requests opting into an experimental API feature, by feature.",
+	}, []string{"feature"})
+
+	// DeprecatedRequests counts requests using what the OpenAPI document
+	// marks deprecated, by method and documented path, and what of it:
+	// kind (operation, parameter or field) and name.
+	DeprecatedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_deprecated_requests_total",
+		Help: "Requests using deprecated API operations, query parameters or body fields.",
+	}, []string{"method", "path", "kind", "name"})
+
+	// SpecViolations counts request and response bodies that did not match
+	// the OpenAPI document, by direction (request, response).
+	SpecViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_openapi_violations_total",
+		Help: "Request and response bodies not matching the OpenAPI document by direction.",
+	}, []string{"direction"})
+
+	// JobDuration observes background job run time by job type (one webhook
+	// delivery attempt, one rescoring pass, ...) and outcome.
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "todo_job_duration_seconds",
+		Help:    "Background job processing time by job type and outcome.",
+		Buckets: latencyBuckets,
+	}, []string{"job", "outcome"})
+	// JobWait observes how long queued jobs waited past their due time
+	// before being picked up, by job type.
+	JobWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "todo_job_wait_seconds",
+		Help:    "Time queued jobs waited past their due time, by job type.",
+		Buckets: latencyBuckets,
+	}, []string{"job"})
+	// JobsInFlight is the number of jobs running, by job type.
+	JobsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "todo_jobs_in_flight",
+		Help: "Background jobs currently running, by job type.",
+	}, []string{"job"})
+	// JobsFailed counts failed job runs by job type.
+	JobsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_jobs_failed_total",
+		Help: "Failed background job runs by job type.",
+	}, []string{"job"})
+)
+
+// Business holds product-level metrics: how many todos are open, overdue
+// and being completed. They describe users' data rather than the process,
+// so they are kept off Registry and served only to admins.
+var Business = prometheus.NewRegistry()
+
+// TodoCompletions counts todos marked completed since the process started.
+var TodoCompletions = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "todo_todos_completed_total",
+	Help: "Todos marked completed.",
+})
+
+func init() {
+	Business.MustRegister(TodoCompletions)
+
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, DBQueryDuration, MLRequests, MLDuration,
+		MLRetries, MLCacheLookups, MLBreakerState, MLHedges, MLScoresDeferred,
+		AuditForwarded, EventLogEvents, APIFeatureRequests, DeprecatedRequests, SpecViolations,
+		JobDuration, JobWait, JobsInFlight, JobsFailed,
+	)
+}
+
+// Outcome labels a result for the outcome label.
+func Outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// BusinessHandler serves Business, in the OpenMetrics format when the
+// scraper accepts it.
+func BusinessHandler() http.Handler {
+	return promhttp.HandlerFor(Business, promhttp.HandlerOpts{Registry: Business, EnableOpenMetrics: true})
+}
+
+// Handler serves Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
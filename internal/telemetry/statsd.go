@@ -0,0 +1,194 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// statsdPacketSize keeps datagrams under a typical path MTU.
+const statsdPacketSize = 1432
+
+// StatsDOptions configures a StatsD exporter.
+type StatsDOptions struct {
+	// Prefix is prepended to every metric name, e.g. "todo." .
+	Prefix string
+	// Tags are added to every metric, as "key:value" (DogStatsD only).
+	Tags []string
+	// Plain sends plain StatsD, which has no tags: label values are appended
+	// to the metric name instead. The default is DogStatsD.
+	Plain bool
+}
+
+// StatsD pushes Registry to a StatsD or DogStatsD agent over UDP, for
+// deployments that do not scrape Prometheus. Gauges are sent as gauges;
+// counters, and the count, sum and buckets of histograms and summaries, as
+// counters of their increase since the previous push.
+type StatsD struct {
+	conn net.Conn
+	opts StatsDOptions
+	// last holds the counter values of the previous push.
+	last map[string]float64
+}
+
+// NewStatsD returns an exporter sending to addr (host:port).
+func NewStatsD(addr string, opts StatsDOptions) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	return &StatsD{conn: conn, opts: opts, last: map[string]float64{}}, nil
+}
+
+// Run pushes every interval until ctx is cancelled, then pushes once more
+// and closes the connection.
+func (e *StatsD) Run(ctx context.Context, interval time.Duration) {
+	defer e.conn.Close()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := e.Push(); err != nil {
+				slog.Warn("metrics.push_failed", "exporter", "statsd", "error", err)
+			}
+			return
+		case <-t.C:
+			if err := e.Push(); err != nil {
+				slog.Warn("metrics.push_failed", "exporter", "statsd", "error", err)
+			}
+		}
+	}
+}
+
+// Push gathers Registry and sends it. Metrics that gathered are sent even
+// when others failed.
+func (e *StatsD) Push() error {
+	families, gatherErr := Registry.Gather()
+	var lines []string
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			lines = e.appendMetric(lines, mf, m)
+		}
+	}
+	if err := e.send(lines); err != nil {
+		return err
+	}
+	return gatherErr
+}
+
+func (e *StatsD) appendMetric(lines []string, mf *dto.MetricFamily, m *dto.Metric) []string {
+	name := mf.GetName()
+	labels := m.GetLabel()
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		lines = e.appendCount(lines, name, labels, m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		lines = append(lines, e.line(name, labels, m.GetGauge().GetValue(), "g"))
+	case dto.MetricType_UNTYPED:
+		lines = append(lines, e.line(name, labels, m.GetUntyped().GetValue(), "g"))
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		lines = e.appendCount(lines, name+".count", labels, float64(h.GetSampleCount()))
+		lines = e.appendCount(lines, name+".sum", labels, h.GetSampleSum())
+		for _, b := range h.GetBucket() {
+			le := &dto.LabelPair{Name: ptr("le"), Value: ptr(strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64))}
+			lines = e.appendCount(lines, name+".bucket", append(labels[:len(labels):len(labels)], le), float64(b.GetCumulativeCount()))
+		}
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		lines = e.appendCount(lines, name+".count", labels, float64(s.GetSampleCount()))
+		lines = e.appendCount(lines, name+".sum", labels, s.GetSampleSum())
+	}
+	return lines
+}
+
+// appendCount appends the increase of a cumulative value since the previous
+// push; after a reset, the whole value.
+func (e *StatsD) appendCount(lines []string, name string, labels []*dto.LabelPair, v float64) []string {
+	key := e.line(name, labels, 0, "")
+	delta := v - e.last[key]
+	if delta < 0 {
+		delta = v
+	}
+	e.last[key] = v
+	if delta == 0 {
+		return lines
+	}
+	return append(lines, e.line(name, labels, delta, "c"))
+}
+
+// line formats one metric: "name:value|type", with DogStatsD tags.
+func (e *StatsD) line(name string, labels []*dto.LabelPair, v float64, kind string) string {
+	var b strings.Builder
+	b.WriteString(e.opts.Prefix)
+	b.WriteString(name)
+	if e.opts.Plain {
+		for _, l := range labels {
+			b.WriteByte('.')
+			b.WriteString(sanitizeStatsD(l.GetValue()))
+		}
+	}
+	b.WriteByte(':')
+	if !math.IsNaN(v) && !math.IsInf(v, 0) {
+		b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	} else {
+		b.WriteByte('0')
+	}
+	b.WriteByte('|')
+	b.WriteString(kind)
+	if !e.opts.Plain && len(labels)+len(e.opts.Tags) > 0 {
+		tags := make([]string, 0, len(labels)+len(e.opts.Tags))
+		for _, l := range labels {
+			tags = append(tags, l.GetName()+":"+sanitizeStatsD(l.GetValue()))
+		}
+		slices.Sort(tags)
+		b.WriteString("|#")
+		b.WriteString(strings.Join(append(tags, e.opts.Tags...), ","))
+	}
+	return b.String()
+}
+
+// send writes lines, several per datagram.
+func (e *StatsD) send(lines []string) error {
+	var packet []byte
+	for _, l := range lines {
+		if len(packet) > 0 && len(packet)+1+len(l) > statsdPacketSize {
+			if _, err := e.conn.Write(packet); err != nil {
+				return fmt.Errorf("statsd: %w", err)
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, l...)
+	}
+	if len(packet) > 0 {
+		if _, err := e.conn.Write(packet); err != nil {
+			return fmt.Errorf("statsd: %w", err)
+		}
+	}
+	return nil
+}
+
+// sanitizeStatsD replaces the characters the StatsD line format reserves.
+func sanitizeStatsD(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', ',', '#', '@', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -1,12 +1,14 @@
 from __future__ import annotations
 
+import json
+import os
 from datetime import datetime
 from typing import List
 
 from fastapi import FastAPI, HTTPException
 from pydantic import BaseModel, Field, field_validator
 
-from .scoring import TodoFeatures, priority_score
+from .scoring import TodoFeatures, UserHistory, priority_score
 
 app = FastAPI(
     title="Smart Todo Priority Service",
@@ -15,6 +17,12 @@ app = FastAPI(
 )
 
 
+class UserFeatures(BaseModel):
+    completion_rate: float = Field(default=0.0, ge=0.0, le=1.0)
+    open_todos: int = Field(default=0, ge=0)
+    avg_duration_by_tag: dict[str, float] = Field(default_factory=dict)
+
+
 class TodoPayload(BaseModel):
     title: str = Field(..., min_length=1, max_length=200)
     completed: bool = False
@@ -22,6 +30,7 @@ class TodoPayload(BaseModel):
     due_date: datetime | None = None
     tags: List[str] = Field(default_factory=list, max_items=20)
     duration_minutes: int = Field(default=0, ge=0, le=1440)
+    user_features: UserFeatures | None = None
 
     @field_validator("tags", mode="before")
     @classmethod
@@ -55,6 +64,21 @@ def health() -> dict[str, str]:
     return {"status": "ok"}
 
 
+@app.get("/calibration", tags=["scoring"])
+def calibration() -> dict:
+    """Parameters the API uses to map scores onto a common scale.
+
+    CALIBRATION_FILE names a JSON file with model_version, method (identity,
+    minmax or isotonic) and its parameters, written alongside a new model;
+    without it the heuristic scores are already on the common scale.
+    """
+    path = os.environ.get("CALIBRATION_FILE")
+    if path:
+        with open(path, encoding="utf-8") as f:
+            return json.load(f)
+    return {"model_version": f"heuristic-{app.version}", "method": "identity"}
+
+
 @app.post("/score", response_model=ScoreResponse, tags=["scoring"])
 def score(request: ScoreRequest) -> ScoreResponse:
     if len(request.todos) == 0:
@@ -69,6 +93,7 @@ def score(request: ScoreRequest) -> ScoreResponse:
             due_date=todo.due_date,
             tags=todo.tags,
             duration_minutes=todo.duration_minutes,
+            user=_user_history(todo.user_features),
         )
         results.append(
             ScoreResult(
@@ -82,3 +107,13 @@ def score(request: ScoreRequest) -> ScoreResponse:
         )
     return ScoreResponse(results=results)
 
+
+def _user_history(features: UserFeatures | None) -> UserHistory | None:
+    if features is None:
+        return None
+    return UserHistory(
+        completion_rate=features.completion_rate,
+        open_todos=features.open_todos,
+        avg_duration_by_tag=dict(features.avg_duration_by_tag),
+    )
+
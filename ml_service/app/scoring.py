@@ -22,6 +22,15 @@ TAG_WEIGHTS = {
 }
 
 
+@dataclass(frozen=True, slots=True)
+class UserHistory:
+    """Aggregates over the owner's todos, sent by the API when enabled."""
+
+    completion_rate: float = 0.0
+    open_todos: int = 0
+    avg_duration_by_tag: dict[str, float] = field(default_factory=dict)
+
+
 @dataclass(frozen=True, slots=True)
 class TodoFeatures:
     title: str
@@ -30,6 +39,7 @@ class TodoFeatures:
     due_date: datetime | None = None
     tags: Iterable[str] = field(default_factory=tuple)
     duration_minutes: int = 0
+    user: UserHistory | None = None
 
 
 def priority_score(features: TodoFeatures) -> float:
@@ -39,7 +49,9 @@ def priority_score(features: TodoFeatures) -> float:
     score += _tag_bonus(features.tags)
     score += _age_bonus(_normalize_dt(features.created_at))
     score += _due_date_bonus(_normalize_dt(features.due_date))
-    score += _duration_bonus(features.duration_minutes)
+    minutes = _expected_minutes(features)
+    score += _duration_bonus(minutes)
+    score += _history_bonus(features.user, minutes)
 
     if features.completed:
         score -= 0.6
@@ -110,6 +122,34 @@ def _duration_bonus(minutes: int) -> float:
     return -0.12
 
 
+def _expected_minutes(features: TodoFeatures) -> int:
+    """The todo's duration, or failing that how long its tags usually take."""
+    if features.duration_minutes > 0 or features.user is None:
+        return features.duration_minutes
+    averages = [
+        features.user.avg_duration_by_tag[tag]
+        for tag in features.tags
+        if tag in features.user.avg_duration_by_tag
+    ]
+    if not averages:
+        return 0
+    return round(sum(averages) / len(averages))
+
+
+def _history_bonus(user: UserHistory | None, minutes: int) -> float:
+    """Favour quick wins for owners who fall behind on a long backlog."""
+    if user is None or not 0 < minutes <= 30:
+        return 0.0
+    bonus = 0.0
+    if user.open_todos >= 50:
+        bonus += 0.05
+    elif user.open_todos >= 20:
+        bonus += 0.03
+    if user.completion_rate < 0.3:
+        bonus += 0.03
+    return bonus
+
+
 def _normalize_dt(value: datetime | None) -> datetime | None:
     if value is None:
         return None
@@ -118,5 +158,5 @@ def _normalize_dt(value: datetime | None) -> datetime | None:
     return value.astimezone(timezone.utc)
 
 
-__all__ = ["TodoFeatures", "priority_score"]
+__all__ = ["TodoFeatures", "UserHistory", "priority_score"]
 
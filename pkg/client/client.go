@@ -0,0 +1,282 @@
+// Package client is a Go client for the todo REST API, shared by Go services
+// that talk to a deployment and by the commands in cmd/. It covers todos and
+// lists; Do reaches the rest of the API.
+//
+//	c, err := client.New("https://todo.example.com", client.WithToken(token))
+//	todo, err := c.CreateTodo(ctx, client.TodoInput{Title: "Write docs"})
+//
+// Every call takes a context. Requests the server turned away without
+// handling them (429, 503) are retried for any method, honouring
+// Retry-After; network errors and 502/504 only for idempotent ones, so a
+// create is never sent twice. Proof-of-work challenges (428) are solved
+// transparently.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResponse caps the response bodies read, exports included.
+const maxResponse = 64 << 20
+
+// maxRetryAfter caps how long a Retry-After makes a call wait.
+const maxRetryAfter = 30 * time.Second
+
+// Client calls one deployment. It is safe for concurrent use.
+type Client struct {
+	base    string
+	token   string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithToken authenticates as the user of a session or API token, for
+// deployments with accounts.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient sends requests through hc instead of a client with a 30s
+// timeout.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithRetries sets how many times a failed request is retried (default 2)
+// and the backoff before the first retry (default 200ms), doubling after
+// each. Zero retries disables them.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries, c.backoff = max(n, 0), backoff
+	}
+}
+
+// New returns a Client for the deployment at baseURL.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("client: base url must be an http(s) URL, got %q", baseURL)
+	}
+	c := &Client{
+		base:    strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+		retries: 2,
+		backoff: 200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// BaseURL returns the deployment's URL.
+func (c *Client) BaseURL() string {
+	return c.base
+}
+
+// APIError is a response with an error status.
+type APIError struct {
+	StatusCode int
+	// Message is the server's error message, or the status text when the
+	// body held none.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+}
+
+// StatusCode returns the status of an *APIError in err's chain, or 0.
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err is a 404.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsNotImplemented reports whether err is a 501: the deployment's storage
+// backend does not support the call.
+func IsNotImplemented(err error) bool {
+	return StatusCode(err) == http.StatusNotImplemented
+}
+
+// Do sends body, if not nil, as JSON to path (with its query) and decodes
+// a JSON response into out, if not nil. It returns an *APIError for error
+// statuses.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	_, err := c.do(ctx, method, path, body, out)
+	return err
+}
+
+// do is Do that also returns the response headers.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) (http.Header, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	pow := ""
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		resp, data, err := c.send(ctx, method, path, payload, body != nil, pow)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !idempotent(method) || attempt >= c.retries {
+				return nil, err
+			}
+			wait = backoff
+		case resp.StatusCode == http.StatusPreconditionRequired && pow == "":
+			if pow, err = solveChallenge(ctx, data); err != nil {
+				return nil, err
+			}
+			attempt--
+			continue
+		case retryable(method, resp.StatusCode) && attempt < c.retries:
+			wait = max(backoff, retryAfter(resp.Header.Get("Retry-After")))
+		case resp.StatusCode >= 400:
+			return resp.Header, apiError(resp, data)
+		default:
+			if out != nil && len(data) > 0 {
+				if err := json.Unmarshal(data, out); err != nil {
+					return resp.Header, fmt.Errorf("decode response: %w", err)
+				}
+			}
+			return resp.Header, nil
+		}
+		backoff *= 2
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send makes one attempt and reads the whole response.
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, hasBody bool, pow string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if hasBody {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if pow != "" {
+		req.Header.Set("X-PoW", pow)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a status may be retried: 429 and 503 mean the
+// request was rate limited or shed before it ran; a gateway error may have
+// reached the server, so only idempotent requests retry it.
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
+
+// retryAfter reads a Retry-After in seconds, capped at maxRetryAfter.
+func retryAfter(v string) time.Duration {
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return min(time.Duration(secs)*time.Second, maxRetryAfter)
+}
+
+func apiError(resp *http.Response, data []byte) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	msg := http.StatusText(resp.StatusCode)
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		msg = body.Error
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: msg}
+}
+
+// solveChallenge finds a nonce for a proof-of-work challenge response.
+func solveChallenge(ctx context.Context, data []byte) (string, error) {
+	var ch struct {
+		Challenge  string `json:"challenge"`
+		Difficulty int    `json:"difficulty"`
+	}
+	if err := json.Unmarshal(data, &ch); err != nil || ch.Challenge == "" {
+		return "", errors.New("malformed proof-of-work challenge")
+	}
+	for nonce := 0; ; nonce++ {
+		if nonce%100000 == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		solution := ch.Challenge + ":" + strconv.Itoa(nonce)
+		if leadingZeroBits(sha256.Sum256([]byte(solution))) >= ch.Difficulty {
+			return solution, nil
+		}
+	}
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
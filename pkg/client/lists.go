@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// List is a todo list as the API returns it.
+type List struct {
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	Color            string    `json:"color"`
+	Icon             string    `json:"icon"`
+	LegalHold        bool      `json:"legalHold"`
+	Archived         bool      `json:"archived"`
+	DefaultTags      []string  `json:"defaultTags,omitempty"`
+	DefaultReminders []int     `json:"defaultReminders"`
+	OwnerID          int64     `json:"ownerId,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// ListInput is the body of a list create or update. Empty Color and Icon
+// use the defaults. DefaultTags and DefaultReminders apply to the todos
+// created with CreateListTodo; nil DefaultReminders leaves them to the
+// server's defaults.
+type ListInput struct {
+	Name             string   `json:"name"`
+	Color            string   `json:"color,omitempty"`
+	Icon             string   `json:"icon,omitempty"`
+	DefaultTags      []string `json:"defaultTags,omitempty"`
+	DefaultReminders []int    `json:"defaultReminders"`
+}
+
+func listPath(id int64) string {
+	return "/api/lists/" + strconv.FormatInt(id, 10)
+}
+
+// Lists returns the caller's lists.
+func (c *Client) Lists(ctx context.Context) ([]List, error) {
+	var lists []List
+	err := c.Do(ctx, http.MethodGet, "/api/lists", nil, &lists)
+	return lists, err
+}
+
+// CreateList creates a list.
+func (c *Client) CreateList(ctx context.Context, in ListInput) (List, error) {
+	var l List
+	err := c.Do(ctx, http.MethodPost, "/api/lists", in, &l)
+	return l, err
+}
+
+// GetList returns list id.
+func (c *Client) GetList(ctx context.Context, id int64) (List, error) {
+	var l List
+	err := c.Do(ctx, http.MethodGet, listPath(id), nil, &l)
+	return l, err
+}
+
+// UpdateList replaces list id's name, color, icon and defaults.
+func (c *Client) UpdateList(ctx context.Context, id int64, in ListInput) (List, error) {
+	var l List
+	err := c.Do(ctx, http.MethodPut, listPath(id), in, &l)
+	return l, err
+}
+
+// DeleteList deletes list id, leaving its todos in no list.
+func (c *Client) DeleteList(ctx context.Context, id int64) error {
+	return c.Do(ctx, http.MethodDelete, listPath(id), nil, nil)
+}
+
+// DeleteListAndTodos deletes list id with its todos.
+func (c *Client) DeleteListAndTodos(ctx context.Context, id int64) error {
+	return c.Do(ctx, http.MethodDelete, withQuery(listPath(id), url.Values{"todos": {"delete"}}), nil, nil)
+}
+
+// ListListTodos returns the todos of list id.
+func (c *Client) ListListTodos(ctx context.Context, id int64) ([]Todo, error) {
+	var todos []Todo
+	err := c.Do(ctx, http.MethodGet, listPath(id)+"/todos", nil, &todos)
+	return todos, err
+}
+
+// CreateListTodo creates a todo in list id, with the list's default tags
+// added and its default reminders applied unless in sets ReminderOffsets.
+// in.ListID is ignored.
+func (c *Client) CreateListTodo(ctx context.Context, id int64, in TodoInput) (Todo, error) {
+	var t Todo
+	err := c.Do(ctx, http.MethodPost, listPath(id)+"/todos", in, &t)
+	return t, err
+}
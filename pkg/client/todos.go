@@ -0,0 +1,268 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Todo is a todo as the API returns it.
+type Todo struct {
+	ID              int64    `json:"id"`
+	UUID            string   `json:"uuid"`
+	Title           string   `json:"title"`
+	Completed       bool     `json:"completed"`
+	Tags            []string `json:"tags"`
+	DurationMinutes int      `json:"durationMinutes"`
+	PriorityScore   float64  `json:"priorityScore"`
+	// EffectivePriority is PriorityScore decayed by the time since the
+	// last update, when the server computes it.
+	EffectivePriority *float64   `json:"effectivePriority,omitempty"`
+	DueAt             *time.Time `json:"dueAt"`
+	StartAt           *time.Time `json:"startAt"`
+	Effort            *int       `json:"effort,omitempty"`
+	ReminderOffsets   []int      `json:"reminderOffsets"`
+	Recurrence        string     `json:"recurrence,omitempty"`
+	ListID            *int64     `json:"listId"`
+	OwnerID           int64      `json:"ownerId,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+}
+
+// TodoInput is the body of a create or a full update. Completed is ignored
+// on create.
+type TodoInput struct {
+	Title           string     `json:"title"`
+	Completed       bool       `json:"completed"`
+	Tags            []string   `json:"tags"`
+	DurationMinutes int        `json:"durationMinutes"`
+	DueAt           *time.Time `json:"dueAt"`
+	StartAt         *time.Time `json:"startAt"`
+	Effort          *int       `json:"effort"`
+	ReminderOffsets []int      `json:"reminderOffsets"`
+	Recurrence      string     `json:"recurrence"`
+	ListID          *int64     `json:"listId"`
+}
+
+// TodoPatch changes the fields that are set and leaves the others alone.
+// Use UpdateTodo to clear a due date, start date or list.
+type TodoPatch struct {
+	Title           *string    `json:"title,omitempty"`
+	Completed       *bool      `json:"completed,omitempty"`
+	Tags            []string   `json:"tags,omitempty"`
+	DurationMinutes *int       `json:"durationMinutes,omitempty"`
+	DueAt           *time.Time `json:"dueAt,omitempty"`
+	StartAt         *time.Time `json:"startAt,omitempty"`
+	Effort          *int       `json:"effort,omitempty"`
+	Recurrence      *string    `json:"recurrence,omitempty"`
+	ListID          *int64     `json:"listId,omitempty"`
+}
+
+// TodoFilter selects todos; the zero value selects all of them.
+type TodoFilter struct {
+	Completed *bool
+	Tag       string
+	ListID    int64
+}
+
+func (f TodoFilter) query() url.Values {
+	q := url.Values{}
+	if f.Completed != nil {
+		q.Set("completed", strconv.FormatBool(*f.Completed))
+	}
+	if f.Tag != "" {
+		q.Set("tag", f.Tag)
+	}
+	if f.ListID != 0 {
+		q.Set("listId", strconv.FormatInt(f.ListID, 10))
+	}
+	return q
+}
+
+// PageOptions orders a paged listing.
+type PageOptions struct {
+	// Sort is created_at (the default), priority_score, duration or title.
+	Sort string
+	Desc bool
+	// PageSize is how many todos each request fetches (default 100).
+	PageSize int
+}
+
+func todoPath(id int64) string {
+	return "/api/todos/" + strconv.FormatInt(id, 10)
+}
+
+func withQuery(path string, q url.Values) string {
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
+
+// CreateTodo creates a todo.
+func (c *Client) CreateTodo(ctx context.Context, in TodoInput) (Todo, error) {
+	var t Todo
+	err := c.Do(ctx, http.MethodPost, "/api/todos", in, &t)
+	return t, err
+}
+
+// GetTodo returns todo id.
+func (c *Client) GetTodo(ctx context.Context, id int64) (Todo, error) {
+	var t Todo
+	err := c.Do(ctx, http.MethodGet, todoPath(id), nil, &t)
+	return t, err
+}
+
+// UpdateTodo replaces todo id with in.
+func (c *Client) UpdateTodo(ctx context.Context, id int64, in TodoInput) (Todo, error) {
+	var t Todo
+	err := c.Do(ctx, http.MethodPut, todoPath(id), in, &t)
+	return t, err
+}
+
+// PatchTodo applies p to todo id. Patches are not retried on network
+// errors, as a merge patch is not idempotent in general.
+func (c *Client) PatchTodo(ctx context.Context, id int64, p TodoPatch) (Todo, error) {
+	var t Todo
+	err := c.Do(ctx, http.MethodPatch, todoPath(id), p, &t)
+	return t, err
+}
+
+// DeleteTodo deletes todo id.
+func (c *Client) DeleteTodo(ctx context.Context, id int64) error {
+	return c.Do(ctx, http.MethodDelete, todoPath(id), nil, nil)
+}
+
+// ListTodos returns every todo f selects, unpaged.
+func (c *Client) ListTodos(ctx context.Context, f TodoFilter) ([]Todo, error) {
+	var todos []Todo
+	err := c.Do(ctx, http.MethodGet, withQuery("/api/todos", f.query()), nil, &todos)
+	return todos, err
+}
+
+// SearchTodos returns the todos matching the full-text query q, best match
+// first. Backends without search return an error IsNotImplemented accepts.
+func (c *Client) SearchTodos(ctx context.Context, q string) ([]Todo, error) {
+	var todos []Todo
+	err := c.Do(ctx, http.MethodGet, withQuery("/api/todos/search", url.Values{"q": {q}}), nil, &todos)
+	return todos, err
+}
+
+// ExportTodos returns the todos f selects as exported in JSON.
+func (c *Client) ExportTodos(ctx context.Context, f TodoFilter) ([]Todo, error) {
+	q := f.query()
+	q.Set("format", "json")
+	var todos []Todo
+	err := c.Do(ctx, http.MethodGet, withQuery("/api/todos/export", q), nil, &todos)
+	return todos, err
+}
+
+// CompletedBatch is the result of CompleteTodos.
+type CompletedBatch struct {
+	Completed int     `json:"completed"`
+	IDs       []int64 `json:"ids"`
+	// BatchID and UndoUntil are set when the batch can be undone through
+	// UncompleteLastBatch.
+	BatchID   int64      `json:"batchId,omitempty"`
+	UndoUntil *time.Time `json:"undoUntil,omitempty"`
+}
+
+// CompleteTodos completes the todos ids in one batch.
+func (c *Client) CompleteTodos(ctx context.Context, ids []int64) (CompletedBatch, error) {
+	var b CompletedBatch
+	err := c.Do(ctx, http.MethodPost, "/api/todos/complete", map[string]any{"ids": ids}, &b)
+	return b, err
+}
+
+// UncompleteLastBatch reopens the todos of the caller's last CompleteTodos
+// batch while it can still be undone, returning their ids.
+func (c *Client) UncompleteLastBatch(ctx context.Context) ([]int64, error) {
+	var resp struct {
+		IDs []int64 `json:"ids"`
+	}
+	err := c.Do(ctx, http.MethodPost, "/api/todos/uncomplete-last-batch", nil, &resp)
+	return resp.IDs, err
+}
+
+// TodoPages iterates over the todos a filter selects, a page at a time:
+//
+//	it := c.TodoPages(f, client.PageOptions{Sort: "priority_score", Desc: true})
+//	for it.Next(ctx) {
+//		use(it.Todo())
+//	}
+//	if err := it.Err(); err != nil { ... }
+type TodoPages struct {
+	c      *Client
+	query  url.Values
+	page   []Todo
+	pos    int
+	cursor string
+	done   bool
+	err    error
+}
+
+// TodoPages returns an iterator over the todos f selects, in o's order.
+// Backends without paging make Err return an error IsNotImplemented
+// accepts.
+func (c *Client) TodoPages(f TodoFilter, o PageOptions) *TodoPages {
+	q := f.query()
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+	if o.Desc {
+		q.Set("order", "desc")
+	}
+	size := o.PageSize
+	if size <= 0 {
+		size = 100
+	}
+	q.Set("limit", strconv.Itoa(size))
+	return &TodoPages{c: c, query: q, pos: -1}
+}
+
+// Next advances to the next todo, fetching the next page when needed, and
+// reports whether there is one.
+func (it *TodoPages) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.pos+1 < len(it.page) {
+		it.pos++
+		return true
+	}
+	for !it.done {
+		q := it.query
+		if it.cursor != "" {
+			q = url.Values{}
+			for k, v := range it.query {
+				q[k] = v
+			}
+			q.Set("cursor", it.cursor)
+		}
+		var page []Todo
+		header, err := it.c.do(ctx, http.MethodGet, withQuery("/api/todos", q), nil, &page)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.cursor = header.Get("X-Next-Cursor")
+		it.done = it.cursor == ""
+		it.page, it.pos = page, 0
+		if len(page) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Todo returns the current todo.
+func (it *TodoPages) Todo() Todo {
+	return it.page[it.pos]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *TodoPages) Err() error {
+	return it.err
+}